@@ -25,7 +25,7 @@ var ChpasswdCmd = &cobra.Command{
 			cmd.Help()
 			return
 		}
-		if _, err := os.Stat(flags.DatabaseFile); os.IsNotExist(err) {
+		if _, err := os.Stat(flags.DatabaseFile); flags.IsSQLite() && os.IsNotExist(err) {
 			cmd.Println("Database file does not exist.")
 			return
 		}
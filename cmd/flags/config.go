@@ -3,21 +3,32 @@ package flags
 import "strings"
 
 const (
-	DatabaseTypeSQLite = "sqlite"
+	DatabaseTypeSQLite     = "sqlite"
+	DatabaseTypeMySQL      = "mysql"
+	DatabaseTypePostgreSQL = "postgresql"
 )
 
 var (
 	// 数据库配置
-	DatabaseType string // 数据库类型：sqlite
+	DatabaseType string // 数据库类型：sqlite, mysql, postgresql
 	DatabaseFile string // SQLite数据库文件路径
+	DatabaseDSN  string // MySQL/PostgreSQL 连接串
 
 	Listen string
 )
 
+// NormalizeDatabaseType 统一数据库类型写法，与 metric store 的 driver 名称保持一致。
 func NormalizeDatabaseType(databaseType string) string {
 	databaseType = strings.ToLower(strings.TrimSpace(databaseType))
-	if databaseType == "" {
+	switch databaseType {
+	case "":
 		return DatabaseTypeSQLite
+	case "sqlite3":
+		return DatabaseTypeSQLite
+	case "mariadb":
+		return DatabaseTypeMySQL
+	case "postgres", "pg", "pgsql":
+		return DatabaseTypePostgreSQL
 	}
 	return databaseType
 }
@@ -32,5 +43,5 @@ func IsSQLite() bool {
 }
 
 func SupportedDatabaseTypes() string {
-	return DatabaseTypeSQLite
+	return strings.Join([]string{DatabaseTypeSQLite, DatabaseTypeMySQL, DatabaseTypePostgreSQL}, ", ")
 }
@@ -4,12 +4,19 @@ import "testing"
 
 func TestNormalizeDatabaseType(t *testing.T) {
 	tests := map[string]string{
-		"":          DatabaseTypeSQLite,
-		"sqlite":    DatabaseTypeSQLite,
-		" SQLite ":  DatabaseTypeSQLite,
-		"SQLITE":    DatabaseTypeSQLite,
-		"postgres":  "postgres",
-		" postgres": "postgres",
+		"":            DatabaseTypeSQLite,
+		"sqlite":      DatabaseTypeSQLite,
+		" SQLite ":    DatabaseTypeSQLite,
+		"SQLITE":      DatabaseTypeSQLite,
+		"sqlite3":     DatabaseTypeSQLite,
+		"mysql":       DatabaseTypeMySQL,
+		"MariaDB":     DatabaseTypeMySQL,
+		"postgres":    DatabaseTypePostgreSQL,
+		" postgres":   DatabaseTypePostgreSQL,
+		"PostgreSQL":  DatabaseTypePostgreSQL,
+		"pg":          DatabaseTypePostgreSQL,
+		"oracle":      "oracle",
+		" cockroach ": "cockroach",
 	}
 
 	for input, want := range tests {
@@ -0,0 +1,54 @@
+package cmd
+
+import (
+	"context"
+	"os"
+	"sort"
+
+	"github.com/komari-monitor/komari/cmd/flags"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ImportSQLiteSource    string
+	ImportSQLiteOverwrite bool
+)
+
+var ImportSQLiteCmd = &cobra.Command{
+	Use:   "import-sqlite",
+	Short: "Import an existing SQLite database into MySQL/PostgreSQL",
+	Long: `Copy every table of an existing Komari SQLite database into the main
+database configured by --db-type/--db-dsn. The source file is not modified.
+Monitoring history lives in the metric store and is migrated separately.`,
+	Example: `komari import-sqlite -t postgresql --db-dsn "postgres://komari:pass@db:5432/komari" --source ./data/komari.db`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if flags.ApplyDatabaseTypeNormalization() == flags.DatabaseTypeSQLite {
+			cmd.Println("Target database type must be mysql or postgresql (set --db-type and --db-dsn).")
+			os.Exit(1)
+		}
+		stats, err := dbcore.ImportSQLiteFile(context.Background(), ImportSQLiteSource, ImportSQLiteOverwrite)
+		if err != nil {
+			cmd.Println("Error:", err)
+			if !ImportSQLiteOverwrite {
+				cmd.Println("Use --overwrite to replace existing data in the target database.")
+			}
+			os.Exit(1)
+		}
+		tables := make([]string, 0, len(stats))
+		for table := range stats {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+		for _, table := range tables {
+			cmd.Printf("%-32s %d rows\n", table, stats[table])
+		}
+		cmd.Println("Import completed.")
+	},
+}
+
+func init() {
+	ImportSQLiteCmd.Flags().StringVarP(&ImportSQLiteSource, "source", "s", "./data/komari.db", "SQLite database file to import")
+	ImportSQLiteCmd.Flags().BoolVar(&ImportSQLiteOverwrite, "overwrite", false, "Delete existing rows in the target database before importing")
+	RootCmd.AddCommand(ImportSQLiteCmd)
+}
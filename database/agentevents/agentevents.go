@@ -0,0 +1,396 @@
+// Package agentevents 保存 Agent 通过 agent.event / agent.message 上报的自定义事件
+// （models.AgentEvent），并管理事件的通知规则（models.AgentEventRule）。
+//
+// 事件由主机上的脚本经 Agent 转发，例如 backup.finished、oom.killed、smart.warning。
+// 每个客户端的写入频率受限，附带数据与消息长度有上限；插件可以通过 Subscribe 收到新事件。
+package agentevents
+
+import (
+	"encoding/json"
+	"errors"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// Retention 是事件的保留时间。
+	Retention = 30 * 24 * time.Hour
+	// RateLimit 是每个客户端每分钟最多记录的事件数，超出的事件被拒绝。
+	RateLimit = 60
+
+	maxTypeLength    = 100
+	maxMessageLength = 4096
+	maxDataBytes     = 16 << 10
+	maxListLimit     = 1000
+	// 事件自带时间允许的范围，超出时使用服务端接收时间。
+	maxEventAge   = 24 * time.Hour
+	maxClockSkew  = time.Minute
+	messageType   = "message"
+	rateLimitSpan = time.Minute
+)
+
+// typePattern 限制事件类型为点分的小写标识，便于规则按前缀匹配。
+var typePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.:-]*$`)
+
+var severityRank = map[string]int{
+	models.AgentEventInfo:     0,
+	models.AgentEventWarning:  1,
+	models.AgentEventCritical: 2,
+}
+
+// ErrRateLimited 表示客户端在一分钟内上报的事件超过 RateLimit。
+var ErrRateLimited = errors.New("too many agent events, try again later")
+
+// Event 是对外返回的事件，Data 为解码后的附带数据。
+type Event struct {
+	models.AgentEvent
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+func toEvent(row models.AgentEvent) Event {
+	event := Event{AgentEvent: row}
+	event.Time = row.Time.UTC()
+	if row.Data != "" {
+		event.Data = json.RawMessage(row.Data)
+	}
+	return event
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+var (
+	rateMu      sync.Mutex
+	rateWindows = map[string]*rateWindow{}
+
+	subscribersMu sync.RWMutex
+	subscribers   []func(Event)
+)
+
+// Subscribe 注册事件记录后的回调。回调在独立的 goroutine 中执行，不应阻塞过久。
+func Subscribe(fn func(Event)) {
+	subscribersMu.Lock()
+	subscribers = append(subscribers, fn)
+	subscribersMu.Unlock()
+}
+
+func publish(event Event) {
+	subscribersMu.RLock()
+	fns := slices.Clone(subscribers)
+	subscribersMu.RUnlock()
+	for _, fn := range fns {
+		go fn(event)
+	}
+}
+
+// FromMessage 将 agent.message 转换为 info 级别的事件，类型为空时使用 message。
+func FromMessage(params v2.MessageParams) v2.EventParams {
+	eventType := params.Type
+	if strings.TrimSpace(eventType) == "" {
+		eventType = messageType
+	}
+	return v2.EventParams{Type: eventType, Severity: models.AgentEventInfo, Message: params.Message, Data: params.Data}
+}
+
+// Record 校验并保存客户端上报的事件。receivedAt 为服务端接收时间，事件自带时间
+// 缺失或偏差过大时使用它。
+func Record(clientUUID string, params v2.EventParams, receivedAt time.Time) (*Event, error) {
+	row, err := normalize(clientUUID, params, receivedAt.UTC())
+	if err != nil {
+		return nil, err
+	}
+	if !allow(clientUUID) {
+		return nil, ErrRateLimited
+	}
+	if err := dbcore.GetDBInstance().Create(&row).Error; err != nil {
+		return nil, err
+	}
+	event := toEvent(row)
+	publish(event)
+	return &event, nil
+}
+
+func normalize(clientUUID string, params v2.EventParams, receivedAt time.Time) (models.AgentEvent, error) {
+	eventType := strings.ToLower(strings.TrimSpace(params.Type))
+	if eventType == "" || len(eventType) > maxTypeLength || !typePattern.MatchString(eventType) {
+		return models.AgentEvent{}, invalid.Errorf("type must be 1-%d characters of a-z, 0-9, '.', '_', ':' or '-'", maxTypeLength)
+	}
+	severity := strings.ToLower(strings.TrimSpace(params.Severity))
+	if severity == "" {
+		severity = models.AgentEventInfo
+	}
+	if _, ok := severityRank[severity]; !ok {
+		return models.AgentEvent{}, invalid.Errorf("severity must be info, warning or critical")
+	}
+	row := models.AgentEvent{
+		ClientUUID: clientUUID,
+		Type:       eventType,
+		Severity:   severity,
+		Message:    truncate(strings.TrimSpace(params.Message), maxMessageLength),
+		Time:       receivedAt,
+	}
+	if params.Time != nil {
+		at := params.Time.UTC()
+		if at.After(receivedAt.Add(-maxEventAge)) && !at.After(receivedAt.Add(maxClockSkew)) {
+			row.Time = at
+		}
+	}
+	if params.Data != nil {
+		data, err := json.Marshal(params.Data)
+		if err != nil {
+			return models.AgentEvent{}, invalid.Errorf("data must be JSON: %v", err)
+		}
+		if len(data) > maxDataBytes {
+			return models.AgentEvent{}, invalid.Errorf("data must be at most %d bytes", maxDataBytes)
+		}
+		row.Data = string(data)
+	}
+	return row, nil
+}
+
+// allow 按一分钟的固定窗口限制每个客户端的事件数。
+func allow(clientUUID string) bool {
+	rateMu.Lock()
+	defer rateMu.Unlock()
+	window, ok := rateWindows[clientUUID]
+	now := time.Now()
+	if !ok || now.Sub(window.start) >= rateLimitSpan {
+		rateWindows[clientUUID] = &rateWindow{start: now, count: 1}
+		return true
+	}
+	if window.count >= RateLimit {
+		return false
+	}
+	window.count++
+	return true
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Query 是事件查询条件，零值字段不参与过滤。Type 以 * 结尾时按前缀匹配。
+type Query struct {
+	ClientUUID  string
+	Type        string
+	MinSeverity string
+	Start       time.Time
+	End         time.Time
+	Limit       int
+}
+
+// List 按时间倒序返回匹配的事件，最多 Limit 条（默认且最多 1000 条）。
+func List(query Query) ([]Event, error) {
+	db := dbcore.GetDBInstance().Model(&models.AgentEvent{})
+	if query.ClientUUID != "" {
+		db = db.Where("client_uuid = ?", query.ClientUUID)
+	}
+	if eventType := strings.ToLower(strings.TrimSpace(query.Type)); eventType != "" {
+		if prefix, ok := strings.CutSuffix(eventType, "*"); ok {
+			// 类型只含 typePattern 中的字符，均小于 '~'，按区间比较即为前缀匹配。
+			db = db.Where("type >= ? AND type < ?", prefix, prefix+"~")
+		} else {
+			db = db.Where("type = ?", eventType)
+		}
+	}
+	if query.MinSeverity != "" {
+		rank, ok := severityRank[strings.ToLower(query.MinSeverity)]
+		if !ok {
+			return nil, invalid.Errorf("severity must be info, warning or critical")
+		}
+		var severities []string
+		for severity, r := range severityRank {
+			if r >= rank {
+				severities = append(severities, severity)
+			}
+		}
+		db = db.Where("severity IN ?", severities)
+	}
+	if !query.Start.IsZero() {
+		db = db.Where("time >= ?", query.Start.UTC())
+	}
+	if !query.End.IsZero() {
+		db = db.Where("time <= ?", query.End.UTC())
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > maxListLimit {
+		limit = maxListLimit
+	}
+	var rows []models.AgentEvent
+	if err := db.Order("time DESC").Order("id DESC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, toEvent(row))
+	}
+	return events, nil
+}
+
+// Delete 删除指定 ID 的事件。
+func Delete(ids []uint) error {
+	result := dbcore.GetDBInstance().Where("id IN ?", ids).Delete(&models.AgentEvent{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteBefore 删除早于 before 的事件。
+func DeleteBefore(before time.Time) error {
+	return dbcore.GetDBInstance().Where("time < ?", before.UTC()).Delete(&models.AgentEvent{}).Error
+}
+
+// ListRules 列出全部事件通知规则
+func ListRules() ([]models.AgentEventRule, error) {
+	var rules []models.AgentEventRule
+	if err := dbcore.GetDBInstance().Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetRule 根据 ID 获取事件通知规则
+func GetRule(id uint) (*models.AgentEventRule, error) {
+	var rule models.AgentEventRule
+	if err := dbcore.GetDBInstance().First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func normalizeRule(rule *models.AgentEventRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	if rule.Name == "" || len(rule.Name) > 255 {
+		return invalid.Errorf("name is required and must be at most 255 characters")
+	}
+	rule.MinSeverity = strings.ToLower(strings.TrimSpace(rule.MinSeverity))
+	if rule.MinSeverity == "" {
+		rule.MinSeverity = models.AgentEventInfo
+	}
+	if _, ok := severityRank[rule.MinSeverity]; !ok {
+		return invalid.Errorf("min_severity must be info, warning or critical")
+	}
+	if rule.Cooldown < 0 {
+		return invalid.Errorf("cooldown must not be negative")
+	}
+	types := make(models.StringArray, 0, len(rule.Types))
+	for _, eventType := range rule.Types {
+		eventType = strings.ToLower(strings.TrimSpace(eventType))
+		if eventType == "" {
+			continue
+		}
+		if !typePattern.MatchString(strings.TrimSuffix(eventType, "*")) && eventType != "*" {
+			return invalid.Errorf("invalid event type %q", eventType)
+		}
+		types = append(types, eventType)
+	}
+	rule.Types = types
+	return nil
+}
+
+// CreateRule 创建事件通知规则
+func CreateRule(rule *models.AgentEventRule) error {
+	rule.Id = 0
+	if err := normalizeRule(rule); err != nil {
+		return err
+	}
+	return dbcore.GetDBInstance().Create(rule).Error
+}
+
+// UpdateRule 更新事件通知规则
+func UpdateRule(rule *models.AgentEventRule) error {
+	if _, err := GetRule(rule.Id); err != nil {
+		return err
+	}
+	if err := normalizeRule(rule); err != nil {
+		return err
+	}
+	return dbcore.GetDBInstance().Model(&models.AgentEventRule{}).Where("id = ?", rule.Id).
+		Select("name", "clients", "selector", "types", "min_severity", "cooldown", "enabled").Updates(rule).Error
+}
+
+// DeleteRules 删除事件通知规则
+func DeleteRules(ids []uint) error {
+	result := dbcore.GetDBInstance().Where("id IN ?", ids).Delete(&models.AgentEventRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// MatchingRules 返回适用于事件的已启用通知规则。
+func MatchingRules(event Event) ([]models.AgentEventRule, error) {
+	var rules []models.AgentEventRule
+	if err := dbcore.GetDBInstance().Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	out := rules[:0]
+	for _, rule := range rules {
+		if ruleMatches(rule, event) {
+			out = append(out, rule)
+		}
+	}
+	return out, nil
+}
+
+func ruleMatches(rule models.AgentEventRule, event Event) bool {
+	if severityRank[event.Severity] < severityRank[rule.MinSeverity] {
+		return false
+	}
+	if !matchesType(rule.Types, event.Type) {
+		return false
+	}
+	if len(rule.Clients) == 0 && strings.TrimSpace(rule.Selector) == "" {
+		return true
+	}
+	for _, uuid := range clientselector.Resolve(rule.Clients, rule.Selector) {
+		if uuid == event.ClientUUID {
+			return true
+		}
+	}
+	return false
+}
+
+// matchesType 判断事件类型是否匹配规则中的任一类型；类型以 * 结尾时按前缀匹配。
+func matchesType(types []string, eventType string) bool {
+	if len(types) == 0 {
+		return true
+	}
+	for _, pattern := range types {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(eventType, prefix) {
+				return true
+			}
+		} else if pattern == eventType {
+			return true
+		}
+	}
+	return false
+}
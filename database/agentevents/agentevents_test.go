@@ -0,0 +1,61 @@
+package agentevents
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+func TestNormalizeValidatesAndClampsEvents(t *testing.T) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	stale := now.Add(-48 * time.Hour)
+
+	row, err := normalize("node", v2.EventParams{
+		Type:    " Backup.Finished ",
+		Message: strings.Repeat("x", maxMessageLength+10),
+		Data:    map[string]any{"job": "nightly"},
+		Time:    &stale,
+	}, now)
+	if err != nil {
+		t.Fatalf("normalize: %v", err)
+	}
+	if row.Type != "backup.finished" || row.Severity != models.AgentEventInfo || len(row.Message) != maxMessageLength {
+		t.Fatalf("normalized row = %+v", row)
+	}
+	if !row.Time.Equal(now) || row.Data != `{"job":"nightly"}` {
+		t.Fatalf("time = %v data = %s, want receive time and JSON data", row.Time, row.Data)
+	}
+
+	for _, params := range []v2.EventParams{
+		{Type: ""},
+		{Type: "bad type"},
+		{Type: "ok", Severity: "fatal"},
+		{Type: "ok", Data: strings.Repeat("x", maxDataBytes)},
+	} {
+		if _, err := normalize("node", params, now); !invalid.Is(err) {
+			t.Fatalf("normalize(%+v) err = %v, want invalid", params, err)
+		}
+	}
+}
+
+func TestRuleMatchesSeverityAndType(t *testing.T) {
+	event := Event{AgentEvent: models.AgentEvent{ClientUUID: "node", Type: "smart.warning", Severity: models.AgentEventWarning}}
+	for _, tc := range []struct {
+		name string
+		rule models.AgentEventRule
+		want bool
+	}{
+		{"all events", models.AgentEventRule{MinSeverity: models.AgentEventInfo}, true},
+		{"severity too low", models.AgentEventRule{MinSeverity: models.AgentEventCritical}, false},
+		{"prefix type", models.AgentEventRule{MinSeverity: models.AgentEventInfo, Types: models.StringArray{"backup.*", "smart.*"}}, true},
+		{"other type", models.AgentEventRule{MinSeverity: models.AgentEventInfo, Types: models.StringArray{"smart.failed"}}, false},
+	} {
+		if got := ruleMatches(tc.rule, event); got != tc.want {
+			t.Fatalf("%s: ruleMatches = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
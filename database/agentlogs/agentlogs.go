@@ -0,0 +1,236 @@
+// Package agentlogs 保存 Agent 通过 agent.logs 上报的日志行（models.AgentLogLine），
+// 并提供按时间范围的子串 / 正则搜索与日志告警规则（models.LogAlertRule）。
+//
+// 采集的日志来源由 Agent 配置（AgentConfig.LogSources）下发。日志按客户端限制保留时间
+// （agent_log_retention_hours）与行数（agent_log_max_lines_per_client），超出部分由定时
+// 清理删除；这里只回答“这台机器在出问题前后记录了什么”，不是完整的日志平台。
+package agentlogs
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"gorm.io/gorm"
+)
+
+const (
+	// MaxBatchLines 是单次 agent.logs 允许的最大行数。
+	MaxBatchLines = 1000
+	// DefaultRetentionHours 与 DefaultMaxLinesPerClient 是对应设置项的默认值。
+	DefaultRetentionHours    = 72
+	DefaultMaxLinesPerClient = 100000
+
+	maxLineLength   = 8 << 10
+	maxSourceLength = 100
+	maxClockSkew    = time.Minute
+	maxSearchLimit  = 1000
+	// 单次搜索最多扫描的行数，超出时返回 Truncated。
+	maxSearchScan   = 200000
+	searchPageLines = 5000
+)
+
+// Retention 返回日志保留时间。
+func Retention() time.Duration {
+	hours, err := config.GetAs[int](config.AgentLogRetentionHoursKey, DefaultRetentionHours)
+	if err != nil || hours <= 0 {
+		hours = DefaultRetentionHours
+	}
+	return time.Duration(hours) * time.Hour
+}
+
+func maxLinesPerClient() int {
+	lines, err := config.GetAs[int](config.AgentLogMaxLinesPerClientKey, DefaultMaxLinesPerClient)
+	if err != nil || lines <= 0 {
+		return DefaultMaxLinesPerClient
+	}
+	return lines
+}
+
+// Ingest 保存一批日志行并返回实际写入的行。早于保留时间的行被丢弃，晚于接收时间的行
+// 按接收时间记录，过长的行被截断。
+func Ingest(clientUUID string, lines []v2.LogLine, receivedAt time.Time) ([]models.AgentLogLine, error) {
+	if len(lines) > MaxBatchLines {
+		return nil, invalid.Errorf("at most %d lines per batch", MaxBatchLines)
+	}
+	receivedAt = receivedAt.UTC()
+	oldest := receivedAt.Add(-Retention())
+	rows := make([]models.AgentLogLine, 0, len(lines))
+	for i, line := range lines {
+		source := strings.TrimSpace(line.Source)
+		if source == "" || len(source) > maxSourceLength {
+			return nil, invalid.Errorf("lines[%d].source is required and must be at most %d characters", i, maxSourceLength)
+		}
+		at := line.Time.UTC()
+		switch {
+		case line.Time.IsZero() || at.After(receivedAt.Add(maxClockSkew)):
+			at = receivedAt
+		case at.Before(oldest):
+			continue
+		}
+		rows = append(rows, models.AgentLogLine{
+			ClientUUID: clientUUID,
+			Source:     source,
+			Time:       at,
+			Line:       truncate(strings.TrimRight(line.Line, "\r\n"), maxLineLength),
+		})
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+	if err := dbcore.GetDBInstance().CreateInBatches(&rows, 500).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Matcher 判断日志行是否匹配搜索词或告警规则的模式。
+type Matcher func(line string) bool
+
+// NewMatcher 构造匹配器：regex 为 true 时按正则匹配，否则按不区分大小写的子串匹配；
+// pattern 为空时匹配全部行。
+func NewMatcher(pattern string, regex bool) (Matcher, error) {
+	if pattern == "" {
+		return func(string) bool { return true }, nil
+	}
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, invalid.Errorf("invalid regular expression: %v", err)
+		}
+		return re.MatchString, nil
+	}
+	lower := strings.ToLower(pattern)
+	return func(line string) bool { return strings.Contains(strings.ToLower(line), lower) }, nil
+}
+
+// SearchQuery 是日志搜索条件，零值字段不参与过滤。
+type SearchQuery struct {
+	ClientUUID string
+	Source     string
+	Text       string
+	Regex      bool
+	Start      time.Time
+	End        time.Time
+	Limit      int
+}
+
+// SearchResult 是搜索结果，按时间倒序。
+type SearchResult struct {
+	Lines     []models.AgentLogLine `json:"lines"`
+	Truncated bool                  `json:"truncated"` // 扫描行数达到上限，更早的匹配行未被检查
+}
+
+// Search 在客户端的日志中按时间倒序查找匹配行，最多返回 Limit 行（默认且最多 1000 行）。
+func Search(query SearchQuery) (*SearchResult, error) {
+	if query.ClientUUID == "" {
+		return nil, invalid.Errorf("uuid is required")
+	}
+	match, err := NewMatcher(query.Text, query.Regex)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.Limit
+	if limit <= 0 || limit > maxSearchLimit {
+		limit = maxSearchLimit
+	}
+	base := dbcore.GetDBInstance().Model(&models.AgentLogLine{}).Where("client_uuid = ?", query.ClientUUID)
+	if query.Source != "" {
+		base = base.Where("source = ?", query.Source)
+	}
+	if !query.Start.IsZero() {
+		base = base.Where("time >= ?", query.Start.UTC())
+	}
+	if !query.End.IsZero() {
+		base = base.Where("time <= ?", query.End.UTC())
+	}
+
+	result := &SearchResult{Lines: []models.AgentLogLine{}}
+	var last *models.AgentLogLine
+	for scanned := 0; ; {
+		if scanned >= maxSearchScan {
+			result.Truncated = true
+			break
+		}
+		page := base.Session(&gorm.Session{})
+		if last != nil {
+			page = page.Where("time < ? OR (time = ? AND id < ?)", last.Time, last.Time, last.ID)
+		}
+		var rows []models.AgentLogLine
+		if err := page.Order("time DESC").Order("id DESC").Limit(searchPageLines).Find(&rows).Error; err != nil {
+			return nil, err
+		}
+		for _, row := range rows {
+			if match(row.Line) {
+				row.Time = row.Time.UTC()
+				result.Lines = append(result.Lines, row)
+				if len(result.Lines) >= limit {
+					return result, nil
+				}
+			}
+		}
+		scanned += len(rows)
+		if len(rows) < searchPageLines {
+			break
+		}
+		last = &rows[len(rows)-1]
+	}
+	return result, nil
+}
+
+// Sources 返回客户端已上报过的日志来源名称。
+func Sources(clientUUID string) ([]string, error) {
+	var sources []string
+	err := dbcore.GetDBInstance().Model(&models.AgentLogLine{}).Where("client_uuid = ?", clientUUID).
+		Distinct("source").Order("source ASC").Pluck("source", &sources).Error
+	return sources, err
+}
+
+// Cleanup 删除超出保留时间的日志，以及每个客户端超出行数上限的最早日志。
+func Cleanup() error {
+	db := dbcore.GetDBInstance()
+	if err := db.Where("time < ?", time.Now().UTC().Add(-Retention())).Delete(&models.AgentLogLine{}).Error; err != nil {
+		return err
+	}
+	limit := maxLinesPerClient()
+	var over []string
+	if err := db.Model(&models.AgentLogLine{}).Group("client_uuid").Having("COUNT(*) > ?", limit).
+		Pluck("client_uuid", &over).Error; err != nil {
+		return err
+	}
+	var errs []error
+	for _, uuid := range over {
+		// 按写入顺序保留最新的 limit 行。
+		var cutoff []uint
+		if err := db.Model(&models.AgentLogLine{}).Where("client_uuid = ?", uuid).
+			Order("id DESC").Offset(limit).Limit(1).Pluck("id", &cutoff).Error; err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(cutoff) == 0 {
+			continue
+		}
+		if err := db.Where("client_uuid = ? AND id <= ?", uuid, cutoff[0]).Delete(&models.AgentLogLine{}).Error; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,127 @@
+package agentlogs_test
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentlogs"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+func TestIngestAndSearch(t *testing.T) {
+	dbtest.Open(t, "agentlogs")
+	uuid := "log-search-node"
+	now := time.Now().UTC().Truncate(time.Second)
+	saved, err := agentlogs.Ingest(uuid, []v2.LogLine{
+		{Source: "app", Time: now.Add(-3 * time.Minute), Line: "INFO started\n"},
+		{Source: "app", Time: now.Add(-2 * time.Minute), Line: "ERROR db timeout"},
+		{Source: "nginx", Time: now.Add(-time.Minute), Line: "error 502 upstream"},
+		{Source: "app", Time: now.Add(-365 * 24 * time.Hour), Line: "ERROR too old"},
+		{Source: "app", Time: now.Add(time.Hour), Line: "ERROR from the future"},
+	}, now)
+	if err != nil {
+		t.Fatalf("ingest: %v", err)
+	}
+	if len(saved) != 4 || saved[0].Line != "INFO started" || !saved[3].Time.Equal(now) {
+		t.Fatalf("saved = %+v, want old line dropped, newline trimmed and future time clamped", saved)
+	}
+
+	result, err := agentlogs.Search(agentlogs.SearchQuery{ClientUUID: uuid, Text: "error"})
+	if err != nil {
+		t.Fatalf("search: %v", err)
+	}
+	if len(result.Lines) != 3 || result.Lines[0].Line != "ERROR from the future" || result.Truncated {
+		t.Fatalf("text search = %+v", result)
+	}
+	result, err = agentlogs.Search(agentlogs.SearchQuery{
+		ClientUUID: uuid, Text: `^ERROR \w+ timeout$`, Regex: true,
+		Start: now.Add(-150 * time.Second), End: now.Add(-90 * time.Second),
+	})
+	if err != nil {
+		t.Fatalf("regex search: %v", err)
+	}
+	if len(result.Lines) != 1 || result.Lines[0].Source != "app" {
+		t.Fatalf("regex search = %+v", result)
+	}
+	if _, err := agentlogs.Search(agentlogs.SearchQuery{ClientUUID: uuid, Text: "(", Regex: true}); !invalid.Is(err) {
+		t.Fatalf("invalid regex err = %v", err)
+	}
+	if sources, err := agentlogs.Sources(uuid); err != nil || fmt.Sprint(sources) != "[app nginx]" {
+		t.Fatalf("sources = %v, %v", sources, err)
+	}
+}
+
+func TestEvaluateAlertsWhenThresholdExceeded(t *testing.T) {
+	dbtest.Open(t, "agentlogs")
+	uuid := "log-alert-node"
+	rule := models.LogAlertRule{Name: "errors", Pattern: "ERROR", Threshold: 3, Window: 5, Source: "app", Enabled: true}
+	if err := agentlogs.CreateRule(&rule); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	t.Cleanup(func() { _ = agentlogs.DeleteRules([]uint{rule.Id}) })
+
+	now := time.Now().UTC()
+	batch := func(n int, source string) []models.AgentLogLine {
+		lines := make([]models.AgentLogLine, 0, n)
+		for i := 0; i < n; i++ {
+			lines = append(lines, models.AgentLogLine{Source: source, Time: now, Line: fmt.Sprintf("ERROR %d", i)})
+		}
+		return lines
+	}
+	for _, step := range []struct {
+		name   string
+		lines  []models.AgentLogLine
+		alerts int
+	}{
+		{"below threshold", batch(2, "app"), 0},
+		{"other source", batch(5, "nginx"), 0},
+		{"exceeds threshold", batch(2, "app"), 1},
+		{"cooldown", batch(2, "app"), 0},
+	} {
+		alerts, err := agentlogs.Evaluate(uuid, step.lines, now)
+		if err != nil {
+			t.Fatalf("%s: %v", step.name, err)
+		}
+		if len(alerts) != step.alerts {
+			t.Fatalf("%s: alerts = %+v, want %d", step.name, alerts, step.alerts)
+		}
+		if step.alerts == 1 && (alerts[0].Count != 4 || alerts[0].Sample != "ERROR 1") {
+			t.Fatalf("%s: alert = %+v", step.name, alerts[0])
+		}
+	}
+}
+
+func TestAlertWindowExpiresOldMatches(t *testing.T) {
+	dbtest.Open(t, "agentlogs")
+	uuid := "log-window-node"
+	rule := models.LogAlertRule{Name: "panics", Pattern: "panic", Threshold: 3, Window: 2, Enabled: true}
+	if err := agentlogs.CreateRule(&rule); err != nil {
+		t.Fatalf("create rule: %v", err)
+	}
+	t.Cleanup(func() { _ = agentlogs.DeleteRules([]uint{rule.Id}) })
+
+	start := time.Now().UTC()
+	lines := func(n int, at time.Time) []models.AgentLogLine {
+		out := make([]models.AgentLogLine, 0, n)
+		for i := 0; i < n; i++ {
+			out = append(out, models.AgentLogLine{Source: "app", Time: at, Line: "panic: boom"})
+		}
+		return out
+	}
+	if alerts, _ := agentlogs.Evaluate(uuid, lines(3, start), start); len(alerts) != 0 {
+		t.Fatalf("threshold reached but not exceeded: %+v", alerts)
+	}
+	// 三分钟后先前的匹配已离开两分钟的窗口，只计入新的匹配。
+	later := start.Add(3 * time.Minute)
+	if alerts, _ := agentlogs.Evaluate(uuid, lines(1, later), later); len(alerts) != 0 {
+		t.Fatalf("expired matches were counted: %+v", alerts)
+	}
+	alerts, err := agentlogs.Evaluate(uuid, lines(3, later), later)
+	if err != nil || len(alerts) != 1 || alerts[0].Count != 4 {
+		t.Fatalf("alerts = %+v, %v", alerts, err)
+	}
+}
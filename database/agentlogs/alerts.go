@@ -0,0 +1,210 @@
+package agentlogs
+
+import (
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"gorm.io/gorm"
+)
+
+const maxRuleWindow = 24 * 60
+
+// Alert 是一次触发的日志告警。
+type Alert struct {
+	Rule       models.LogAlertRule
+	ClientUUID string
+	Count      int    // 窗口内匹配的行数
+	Sample     string // 最近一行匹配的日志
+}
+
+type ruleKey struct {
+	rule   uint
+	client string
+}
+
+// ruleWindow 按分钟计数窗口内的匹配行，占用的内存只与窗口长度（至多 maxRuleWindow 个桶）有关，
+// 与匹配行数无关。桶的任一部分仍在窗口内时整桶计入，窗口边界因此有至多一分钟的误差。
+type ruleWindow struct {
+	buckets      map[int64]int // 分钟起点（Unix 秒）→ 匹配数
+	lastNotified time.Time
+}
+
+var (
+	windowsMu sync.Mutex
+	windows   = map[ruleKey]*ruleWindow{}
+)
+
+// ListRules 列出全部日志告警规则
+func ListRules() ([]models.LogAlertRule, error) {
+	var rules []models.LogAlertRule
+	if err := dbcore.GetDBInstance().Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// GetRule 根据 ID 获取日志告警规则
+func GetRule(id uint) (*models.LogAlertRule, error) {
+	var rule models.LogAlertRule
+	if err := dbcore.GetDBInstance().First(&rule, id).Error; err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func normalizeRule(rule *models.LogAlertRule) error {
+	rule.Name = strings.TrimSpace(rule.Name)
+	rule.Source = strings.TrimSpace(rule.Source)
+	if rule.Name == "" || len(rule.Name) > 255 {
+		return invalid.Errorf("name is required and must be at most 255 characters")
+	}
+	if rule.Pattern == "" || len(rule.Pattern) > 500 {
+		return invalid.Errorf("pattern is required and must be at most 500 characters")
+	}
+	if _, err := NewMatcher(rule.Pattern, rule.Regex); err != nil {
+		return err
+	}
+	if len(rule.Source) > maxSourceLength {
+		return invalid.Errorf("source must be at most %d characters", maxSourceLength)
+	}
+	if rule.Threshold < 1 {
+		return invalid.Errorf("threshold must be at least 1")
+	}
+	if rule.Window < 1 || rule.Window > maxRuleWindow {
+		return invalid.Errorf("window must be between 1 and %d minutes", maxRuleWindow)
+	}
+	return nil
+}
+
+// CreateRule 创建日志告警规则
+func CreateRule(rule *models.LogAlertRule) error {
+	rule.Id = 0
+	if err := normalizeRule(rule); err != nil {
+		return err
+	}
+	return dbcore.GetDBInstance().Create(rule).Error
+}
+
+// UpdateRule 更新日志告警规则，并清空该规则已累计的匹配计数。
+func UpdateRule(rule *models.LogAlertRule) error {
+	if _, err := GetRule(rule.Id); err != nil {
+		return err
+	}
+	if err := normalizeRule(rule); err != nil {
+		return err
+	}
+	if err := dbcore.GetDBInstance().Model(&models.LogAlertRule{}).Where("id = ?", rule.Id).
+		Select("name", "clients", "selector", "source", "pattern", "regex", "threshold", "window_minutes", "enabled").Updates(rule).Error; err != nil {
+		return err
+	}
+	forget(rule.Id)
+	return nil
+}
+
+// DeleteRules 删除日志告警规则
+func DeleteRules(ids []uint) error {
+	result := dbcore.GetDBInstance().Where("id IN ?", ids).Delete(&models.LogAlertRule{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	for _, id := range ids {
+		forget(id)
+	}
+	return nil
+}
+
+func forget(id uint) {
+	windowsMu.Lock()
+	defer windowsMu.Unlock()
+	for key := range windows {
+		if key.rule == id {
+			delete(windows, key)
+		}
+	}
+}
+
+// Evaluate 用新写入的日志行更新各规则的匹配计数，返回需要通知的告警。
+// 同一规则在同一客户端上的两次告警至少间隔一个统计窗口。
+func Evaluate(clientUUID string, lines []models.AgentLogLine, now time.Time) ([]Alert, error) {
+	if len(lines) == 0 {
+		return nil, nil
+	}
+	var rules []models.LogAlertRule
+	if err := dbcore.GetDBInstance().Where("enabled = ?", true).Order("id ASC").Find(&rules).Error; err != nil {
+		return nil, err
+	}
+	var alerts []Alert
+	for _, rule := range rules {
+		if !appliesTo(rule, clientUUID) {
+			continue
+		}
+		match, err := NewMatcher(rule.Pattern, rule.Regex)
+		if err != nil {
+			continue
+		}
+		if alert, ok := observe(rule, clientUUID, lines, match, now); ok {
+			alerts = append(alerts, alert)
+		}
+	}
+	return alerts, nil
+}
+
+func appliesTo(rule models.LogAlertRule, clientUUID string) bool {
+	if len(rule.Clients) == 0 && strings.TrimSpace(rule.Selector) == "" {
+		return true
+	}
+	return slices.Contains(clientselector.Resolve(rule.Clients, rule.Selector), clientUUID)
+}
+
+// observe 把匹配的行计入规则窗口，窗口内的匹配数超过阈值且不在冷却期内时返回告警。
+func observe(rule models.LogAlertRule, clientUUID string, lines []models.AgentLogLine, match Matcher, now time.Time) (Alert, bool) {
+	window := time.Duration(rule.Window) * time.Minute
+	var matched []time.Time
+	sample := ""
+	for _, line := range lines {
+		if rule.Source != "" && line.Source != rule.Source {
+			continue
+		}
+		if now.Sub(line.Time) < window && match(line.Line) {
+			matched = append(matched, line.Time)
+			sample = line.Line
+		}
+	}
+
+	windowsMu.Lock()
+	defer windowsMu.Unlock()
+	key := ruleKey{rule.Id, clientUUID}
+	state := windows[key]
+	if state == nil {
+		if len(matched) == 0 {
+			return Alert{}, false
+		}
+		state = &ruleWindow{buckets: map[int64]int{}}
+		windows[key] = state
+	}
+	for _, t := range matched {
+		state.buckets[t.Truncate(time.Minute).Unix()]++
+	}
+	count := 0
+	for minute, n := range state.buckets {
+		if now.Sub(time.Unix(minute, 0).Add(time.Minute)) >= window {
+			delete(state.buckets, minute)
+			continue
+		}
+		count += n
+	}
+	if len(matched) == 0 || count <= rule.Threshold || now.Sub(state.lastNotified) < window {
+		return Alert{}, false
+	}
+	state.lastNotified = now
+	return Alert{Rule: rule, ClientUUID: clientUUID, Count: count, Sample: sample}, true
+}
@@ -0,0 +1,340 @@
+// Package agentoutbox 将 v2 事件队列持久化到数据库（models.AgentOutboxEvent）。
+//
+// 内存队列（web/agent）仍是事件的来源，本包通过 agent.V2EventStore 记录事件的入队、
+// 下发、确认与丢弃，并在服务端启动时把未完成的事件放回内存队列。持久化可通过
+// agent_event_outbox_enabled 关闭；无论是否开启，未送达的 agent.exec 事件被丢弃时
+// 都会把对应的任务结果标记为失败。
+package agentoutbox
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/agent"
+	"gorm.io/gorm"
+)
+
+const (
+	// TickInterval 是清理过期事件的间隔。
+	TickInterval = time.Minute
+	// DefaultTTL 是持久化事件的默认有效期（秒），见 agent_event_outbox_ttl。
+	DefaultTTL = 3600
+	// historyRetention 是已结束事件的保留时间。
+	historyRetention = 7 * 24 * time.Hour
+	// historyLimit 是查询历史时每个客户端返回的已结束事件数量上限。
+	historyLimit = 100
+)
+
+var openStatus = []string{models.AgentEventPending, models.AgentEventDelivered}
+
+// Enabled 返回是否开启了事件持久化。
+func Enabled() bool {
+	enabled, err := config.GetAs[bool](config.AgentEventOutboxEnabledKey, false)
+	return err == nil && enabled
+}
+
+func outboxTTL() time.Duration {
+	seconds, err := config.GetAs[int](config.AgentEventOutboxTTLKey, DefaultTTL)
+	if err != nil || seconds <= 0 {
+		seconds = DefaultTTL
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// Init 注册事件存储，并恢复上次运行时未完成的事件。
+func Init() {
+	agent.SetV2EventStore(Store{})
+	// 早期版本会持久化 agent.rotateToken 事件，其中含有明文 token。
+	if err := dbcore.GetDBInstance().Where("method = ?", v2.MethodAgentToken).
+		Delete(&models.AgentOutboxEvent{}).Error; err != nil {
+		logger.Warnf("agentoutbox", "Failed to purge persisted token events: %v", err)
+	}
+	restored, err := Restore()
+	if err != nil {
+		logger.Errorf("agentoutbox", "Failed to restore queued agent events: %v", err)
+		return
+	}
+	if restored > 0 {
+		logger.Infof("agentoutbox", "Restored %d queued agent events", restored)
+	}
+}
+
+// Store 实现 agent.V2EventStore。
+type Store struct{}
+
+// transient 判断事件是否只保存在内存队列中：ping 事件只在数秒内有效；
+// agent.rotateToken 事件含有明文 token，不能写入数据库（及其备份）。
+func transient(method string) bool {
+	return method == v2.MethodAgentPing || method == v2.MethodAgentToken
+}
+
+// redactParams 隐藏事件参数中的敏感内容，用于管理接口展示。
+func redactParams(method, params string) string {
+	if method != v2.MethodAgentToken {
+		return params
+	}
+	var p v2.RotateTokenParams
+	_ = json.Unmarshal([]byte(params), &p)
+	p.Token = ""
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+// Persist 在开启持久化时保存新事件，transient 的事件不做持久化。
+func (Store) Persist(uuid string, event *v2.Event) bool {
+	if transient(event.Method) || !Enabled() {
+		return false
+	}
+	params, err := json.Marshal(event.Params)
+	if err != nil {
+		logger.Warnf("agentoutbox", "Failed to encode %s event for %s: %v", event.Method, uuid, err)
+		return false
+	}
+	// 持久化的目的是跨越重启与短暂离线，有效期不短于 agent_event_outbox_ttl。
+	expiresAt := event.ExpiresAt
+	if extended := event.CreatedAt.Add(outboxTTL()); extended.After(expiresAt) {
+		expiresAt = extended
+	}
+	row := models.AgentOutboxEvent{
+		ID:          event.ID,
+		ClientUUID:  uuid,
+		Method:      event.Method,
+		Params:      string(params),
+		Status:      models.AgentEventPending,
+		MaxAttempts: agent.V2EventMaxAttempts,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   event.CreatedAt,
+	}
+	if err := dbcore.GetDBInstance().Create(&row).Error; err != nil {
+		logger.Warnf("agentoutbox", "Failed to persist %s event for %s: %v", event.Method, uuid, err)
+		return false
+	}
+	event.ExpiresAt = expiresAt
+	return true
+}
+
+func (Store) Delivered(uuid string, ids []string) {
+	now := time.Now().UTC()
+	err := openEvents(uuid, ids).Updates(map[string]any{
+		"status":       models.AgentEventDelivered,
+		"attempts":     gorm.Expr("attempts + 1"),
+		"delivered_at": now,
+	}).Error
+	if err != nil {
+		logger.Warnf("agentoutbox", "Failed to record delivered events for %s: %v", uuid, err)
+	}
+}
+
+func (Store) Acked(uuid string, ids []string) {
+	now := time.Now().UTC()
+	err := openEvents(uuid, ids).Updates(map[string]any{
+		"status":    models.AgentEventAcked,
+		"acked_at":  now,
+		"closed_at": now,
+	}).Error
+	if err != nil {
+		logger.Warnf("agentoutbox", "Failed to record acknowledged events for %s: %v", uuid, err)
+	}
+}
+
+func (Store) Dropped(uuid string, events []v2.Event, reason string) {
+	ids := make([]string, 0, len(events))
+	for _, event := range events {
+		// transient 事件从不持久化，不必访问数据库。
+		if !transient(event.Method) {
+			ids = append(ids, event.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := closeEvents(uuid, ids, reason); err != nil {
+		logger.Warnf("agentoutbox", "Failed to record %s events for %s: %v", reason, uuid, err)
+	}
+	for _, event := range events {
+		failExecTask(uuid, event, reason)
+	}
+}
+
+func openEvents(uuid string, ids []string) *gorm.DB {
+	return dbcore.GetDBInstance().Model(&models.AgentOutboxEvent{}).
+		Where("client_uuid = ? AND id IN ? AND status IN ?", uuid, ids, openStatus)
+}
+
+func closeEvents(uuid string, ids []string, status string) error {
+	return openEvents(uuid, ids).Updates(map[string]any{
+		"status":    status,
+		"closed_at": time.Now().UTC(),
+	}).Error
+}
+
+// failExecTask 将未确认就被丢弃的 agent.exec 事件对应的任务结果标记为失败。
+// 已有结果（Agent 已经执行并回传）时不做修改。
+func failExecTask(uuid string, event v2.Event, reason string) {
+	if event.Method != v2.MethodAgentExec || reason == models.AgentEventReplaced {
+		return
+	}
+	var params v2.ExecParams
+	b, err := json.Marshal(event.Params)
+	if err == nil {
+		err = json.Unmarshal(b, &params)
+	}
+	if err != nil || params.TaskID == "" {
+		return
+	}
+	var result string
+	switch reason {
+	case models.AgentEventExpired:
+		result = "Command expired before the agent acknowledged it"
+	case models.AgentEventCancelled:
+		result = "Command cancelled before the agent acknowledged it"
+	default:
+		result = "Command was not acknowledged by the agent"
+	}
+	if _, err := tasks.FailPendingTaskResult(params.TaskID, uuid, result, time.Now()); err != nil {
+		logger.Warnf("agentoutbox", "Failed to mark task %s on %s as %s: %v", params.TaskID, uuid, reason, err)
+	}
+}
+
+func toEvent(row models.AgentOutboxEvent) v2.Event {
+	return v2.Event{
+		ID:        row.ID,
+		Method:    row.Method,
+		Params:    json.RawMessage(row.Params),
+		CreatedAt: row.CreatedAt.UTC(),
+		ExpiresAt: row.ExpiresAt.UTC(),
+	}
+}
+
+// Restore 把尚未过期的未完成事件放回内存队列，返回恢复的事件数量。
+func Restore() (int, error) {
+	var rows []models.AgentOutboxEvent
+	err := dbcore.GetDBInstance().
+		Where("status IN ? AND expires_at > ?", openStatus, time.Now().UTC()).
+		Order("created_at ASC").
+		Find(&rows).Error
+	if err != nil {
+		return 0, err
+	}
+	events := make(map[string][]v2.Event)
+	attempts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		events[row.ClientUUID] = append(events[row.ClientUUID], toEvent(row))
+		attempts[row.ID] = row.Attempts
+	}
+	for uuid, list := range events {
+		agent.RestoreV2Events(uuid, list, attempts)
+	}
+	return len(rows), nil
+}
+
+// Tick 清理内存队列与数据库中过期的事件，并删除过旧的历史记录。
+func Tick(ctx context.Context) {
+	agent.PruneV2Events()
+	if err := expireStale(ctx); err != nil {
+		logger.Warnf("agentoutbox", "Failed to expire queued agent events: %v", err)
+	}
+	before := time.Now().UTC().Add(-historyRetention)
+	if err := dbcore.GetDBInstance().WithContext(ctx).
+		Where("status NOT IN ? AND closed_at < ?", openStatus, before).
+		Delete(&models.AgentOutboxEvent{}).Error; err != nil {
+		logger.Warnf("agentoutbox", "Failed to clean agent event history: %v", err)
+	}
+}
+
+// expireStale 处理数据库中已过期但不在内存队列中的事件（例如客户端在重启后再未出现）。
+func expireStale(ctx context.Context) error {
+	db := dbcore.GetDBInstance().WithContext(ctx)
+	var rows []models.AgentOutboxEvent
+	if err := db.Where("status IN ? AND expires_at <= ?", openStatus, time.Now().UTC()).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		res := db.Model(&models.AgentOutboxEvent{}).
+			Where("id = ? AND status IN ?", row.ID, openStatus).
+			Updates(map[string]any{"status": models.AgentEventExpired, "closed_at": time.Now().UTC()})
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected > 0 {
+			failExecTask(row.ClientUUID, toEvent(row), models.AgentEventExpired)
+		}
+	}
+	return nil
+}
+
+// Event 是管理接口中展示的事件。Durable 为 false 的事件只存在于内存队列中。
+type Event struct {
+	models.AgentOutboxEvent
+	Durable bool `json:"durable"`
+}
+
+// List 列出客户端尚未完成的事件；history 为 true 时同时返回最近已结束的持久化事件。
+func List(uuid string, history bool) ([]Event, error) {
+	db := dbcore.GetDBInstance()
+	var rows []models.AgentOutboxEvent
+	if err := db.Where("client_uuid = ? AND status IN ?", uuid, openStatus).Order("created_at ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	if history {
+		var closed []models.AgentOutboxEvent
+		if err := db.Where("client_uuid = ? AND status NOT IN ?", uuid, openStatus).
+			Order("created_at DESC").Limit(historyLimit).Find(&closed).Error; err != nil {
+			return nil, err
+		}
+		rows = append(rows, closed...)
+	}
+
+	events := make([]Event, 0, len(rows))
+	for _, info := range agent.PendingV2Events(uuid) {
+		if info.Durable {
+			continue
+		}
+		params, _ := json.Marshal(info.Params)
+		status := models.AgentEventPending
+		if info.Attempts > 0 {
+			status = models.AgentEventDelivered
+		}
+		events = append(events, Event{AgentOutboxEvent: models.AgentOutboxEvent{
+			ID:          info.ID,
+			ClientUUID:  uuid,
+			Method:      info.Method,
+			Params:      redactParams(info.Method, string(params)),
+			Status:      status,
+			Attempts:    info.Attempts,
+			MaxAttempts: agent.V2EventMaxAttempts,
+			ExpiresAt:   info.ExpiresAt,
+			CreatedAt:   info.CreatedAt,
+		}})
+	}
+	for _, row := range rows {
+		row.Params = redactParams(row.Method, row.Params)
+		events = append(events, Event{AgentOutboxEvent: row, Durable: true})
+	}
+	return events, nil
+}
+
+// Cancel 取消客户端尚未确认的事件。
+func Cancel(uuid, id string) error {
+	if _, ok := agent.CancelV2Event(uuid, id); ok {
+		return nil
+	}
+	// 不在内存队列中：可能是尚未恢复或已过期的持久化事件。
+	var row models.AgentOutboxEvent
+	if err := dbcore.GetDBInstance().Where("id = ? AND client_uuid = ?", id, uuid).First(&row).Error; err != nil {
+		return err
+	}
+	if row.Status != models.AgentEventPending && row.Status != models.AgentEventDelivered {
+		return invalid.Errorf("event is already %s", row.Status)
+	}
+	Store{}.Dropped(uuid, []v2.Event{toEvent(row)}, models.AgentEventCancelled)
+	return nil
+}
@@ -0,0 +1,170 @@
+package agentoutbox_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentoutbox"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"github.com/komari-monitor/komari/web/agent"
+	"gorm.io/gorm"
+)
+
+func setupOutbox(t *testing.T) *gorm.DB {
+	t.Helper()
+	db := dbtest.Open(t, "agentoutbox")
+	if err := config.Set(config.AgentEventOutboxEnabledKey, true); err != nil {
+		t.Fatalf("enable outbox: %v", err)
+	}
+	agent.SetV2EventStore(agentoutbox.Store{})
+	t.Cleanup(func() { agent.SetV2EventStore(nil) })
+	return db
+}
+
+func eventRow(t *testing.T, db *gorm.DB, id string) models.AgentOutboxEvent {
+	t.Helper()
+	var row models.AgentOutboxEvent
+	if err := db.First(&row, "id = ?", id).Error; err != nil {
+		t.Fatalf("load event %s: %v", id, err)
+	}
+	return row
+}
+
+func taskResult(t *testing.T, taskID, uuid string) *models.TaskResult {
+	t.Helper()
+	result, err := tasks.GetSpecificTaskResult(taskID, uuid)
+	if err != nil {
+		t.Fatalf("load task result: %v", err)
+	}
+	return result
+}
+
+func TestOutboxLifecycle(t *testing.T) {
+	db := setupOutbox(t)
+	const uuid = "outbox-client"
+	if err := tasks.CreateTask("task-acked", []string{uuid}, "uptime"); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+
+	event := agent.EnqueueV2Event(uuid, v2.MethodAgentExec, v2.ExecParams{TaskID: "task-acked", Command: "uptime"})
+	row := eventRow(t, db, event.ID)
+	if row.Status != models.AgentEventPending || row.ClientUUID != uuid {
+		t.Fatalf("event should be persisted as pending, got %#v", row)
+	}
+	if event.ExpiresAt.Sub(event.CreatedAt) < time.Duration(agentoutbox.DefaultTTL)*time.Second {
+		t.Fatalf("persisted events should use the outbox TTL, expires at %v", event.ExpiresAt)
+	}
+
+	if events := agent.TakeV2Events(uuid, nil, 0); len(events) != 1 || events[0].ID != event.ID {
+		t.Fatalf("expected the queued exec event, got %#v", events)
+	}
+	if row := eventRow(t, db, event.ID); row.Status != models.AgentEventDelivered || row.Attempts != 1 || row.DeliveredAt == nil {
+		t.Fatalf("event should be recorded as delivered once, got %#v", row)
+	}
+	if events := agent.TakeV2Events(uuid, []string{event.ID}, 0); len(events) != 0 {
+		t.Fatalf("acknowledged event should leave the queue, got %#v", events)
+	}
+	if row := eventRow(t, db, event.ID); row.Status != models.AgentEventAcked || row.AckedAt == nil {
+		t.Fatalf("event should be recorded as acknowledged, got %#v", row)
+	}
+
+	// 取消未确认的 exec 事件时任务结果标记为失败。
+	if err := tasks.CreateTask("task-cancelled", []string{uuid}, "reboot"); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	cancelled := agent.EnqueueV2Event(uuid, v2.MethodAgentExec, v2.ExecParams{TaskID: "task-cancelled", Command: "reboot"})
+	if err := agentoutbox.Cancel(uuid, cancelled.ID); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	if row := eventRow(t, db, cancelled.ID); row.Status != models.AgentEventCancelled {
+		t.Fatalf("event should be cancelled, got %#v", row)
+	}
+	if result := taskResult(t, "task-cancelled", uuid); result.ExitCode == nil || *result.ExitCode != -1 || result.FinishedAt == nil {
+		t.Fatalf("cancelled exec should fail its task, got %#v", result)
+	}
+	if err := agentoutbox.Cancel(uuid, cancelled.ID); !invalid.Is(err) {
+		t.Fatalf("cancelling a finished event should be invalid, got %v", err)
+	}
+}
+
+func TestRestoreAndExpire(t *testing.T) {
+	db := setupOutbox(t)
+	const uuid = "restored-client"
+	now := time.Now().UTC()
+	if err := tasks.CreateTask("task-expired", []string{uuid}, "df -h"); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	// 上次运行留下的事件：一个仍然有效，一个已过期。
+	rows := []models.AgentOutboxEvent{
+		{ID: "restored-live", ClientUUID: uuid, Method: v2.MethodAgentExec, Params: `{"task_id":"task-live","command":"ls"}`,
+			Status: models.AgentEventDelivered, Attempts: 2, MaxAttempts: agent.V2EventMaxAttempts, ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{ID: "restored-expired", ClientUUID: uuid, Method: v2.MethodAgentExec, Params: `{"task_id":"task-expired","command":"df -h"}`,
+			Status: models.AgentEventPending, MaxAttempts: agent.V2EventMaxAttempts, ExpiresAt: now.Add(-time.Minute), CreatedAt: now.Add(-time.Hour)},
+	}
+	if err := db.Create(&rows).Error; err != nil {
+		t.Fatalf("create rows: %v", err)
+	}
+
+	if restored, err := agentoutbox.Restore(); err != nil || restored != 1 {
+		t.Fatalf("restore = %d (%v), want 1", restored, err)
+	}
+	t.Cleanup(func() { agent.SetClientProtocolVersion(uuid, 0) })
+	if !agent.IsV2Client(uuid) {
+		t.Fatal("clients with restored events should be treated as v2 agents")
+	}
+	pending := agent.PendingV2Events(uuid)
+	if len(pending) != 1 || pending[0].ID != "restored-live" || pending[0].Attempts != 2 || !pending[0].Durable {
+		t.Fatalf("unexpected restored queue %#v", pending)
+	}
+
+	agentoutbox.Tick(context.Background())
+	if row := eventRow(t, db, "restored-expired"); row.Status != models.AgentEventExpired || row.ClosedAt == nil {
+		t.Fatalf("stale event should expire, got %#v", row)
+	}
+	if result := taskResult(t, "task-expired", uuid); result.ExitCode == nil || *result.ExitCode != -1 || result.Result == "" {
+		t.Fatalf("expired exec should fail its task, got %#v", result)
+	}
+
+	list, err := agentoutbox.List(uuid, true)
+	if err != nil || len(list) != 2 || list[0].ID != "restored-live" || !list[0].Durable {
+		t.Fatalf("list = %#v (%v)", list, err)
+	}
+	if open, _ := agentoutbox.List(uuid, false); len(open) != 1 {
+		t.Fatalf("history should be excluded by default, got %#v", open)
+	}
+}
+
+func TestRotateTokenNeverStored(t *testing.T) {
+	db := setupOutbox(t)
+	const uuid = "token-client"
+	const secret = "plaintext-client-token"
+	event := agent.EnqueueV2Event(uuid, v2.MethodAgentToken, v2.RotateTokenParams{Token: secret})
+	t.Cleanup(func() { agent.CancelV2Event(uuid, event.ID) })
+
+	var count int64
+	if err := db.Model(&models.AgentOutboxEvent{}).Where("id = ? OR params LIKE ?", event.ID, "%"+secret+"%").Count(&count).Error; err != nil {
+		t.Fatalf("count rows: %v", err)
+	}
+	if count != 0 {
+		t.Fatalf("rotate-token event should not be persisted, found %d rows", count)
+	}
+	pending := agent.PendingV2Events(uuid)
+	if len(pending) != 1 || pending[0].Durable {
+		t.Fatalf("rotate-token event should stay in the memory queue, got %#v", pending)
+	}
+
+	list, err := agentoutbox.List(uuid, true)
+	if err != nil || len(list) != 1 {
+		t.Fatalf("list = %#v (%v)", list, err)
+	}
+	if strings.Contains(list[0].Params, secret) {
+		t.Fatalf("list should not expose the token, got %s", list[0].Params)
+	}
+}
@@ -0,0 +1,391 @@
+// Package agentprofiles 管理服务端下发的 Agent 配置（models.AgentProfile）。
+//
+// 客户端的配置来自 Client.AgentProfileID，可以直接分配，也可以由分组策略
+// （GroupPolicy.AgentProfile）物化而来。配置通过 agent.config 事件在 Agent 连接时
+// 以及配置变更时下发，Agent 在 ack_event_ids 中确认后记录为已生效版本。
+package agentprofiles
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/agent"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// MaxReportInterval 是允许下发的最大上报间隔（秒）。
+const MaxReportInterval = 3600
+
+// MaxLogSources 是单个配置允许的最大日志来源数。
+const MaxLogSources = 32
+
+// Version 返回配置内容的摘要，内容相同的配置版本相同。
+func Version(config models.AgentConfig) string {
+	b, _ := json.Marshal(config)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ListProfiles 按名称列出全部配置
+func ListProfiles() ([]models.AgentProfile, error) {
+	db := dbcore.GetDBInstance()
+	var profiles []models.AgentProfile
+	if err := db.Order("name ASC").Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	return profiles, nil
+}
+
+// GetProfile 根据 ID 获取配置
+func GetProfile(id uint) (*models.AgentProfile, error) {
+	db := dbcore.GetDBInstance()
+	var profile models.AgentProfile
+	if err := db.First(&profile, id).Error; err != nil {
+		return nil, err
+	}
+	return &profile, nil
+}
+
+// Exists 检查配置是否存在。
+func Exists(tx *gorm.DB, id uint) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.AgentProfile{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func cleanList(values []string, lower bool) []string {
+	if values == nil {
+		return nil
+	}
+	out := make([]string, 0, len(values))
+	for _, v := range values {
+		v = strings.TrimSpace(v)
+		if lower {
+			v = strings.ToLower(v)
+		}
+		if v != "" && !slices.Contains(out, v) {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+func normalize(profile *models.AgentProfile) error {
+	profile.Name = strings.TrimSpace(profile.Name)
+	if profile.Name == "" || len(profile.Name) > 100 {
+		return invalid.Errorf("name is required and must be at most 100 characters")
+	}
+	var count int64
+	db := dbcore.GetDBInstance()
+	if err := db.Model(&models.AgentProfile{}).Where("name = ? AND id <> ?", profile.Name, profile.Id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return invalid.Errorf("profile %q already exists", profile.Name)
+	}
+	c := &profile.Config
+	if c.ReportInterval != nil && (*c.ReportInterval < 1 || *c.ReportInterval > MaxReportInterval) {
+		return invalid.Errorf("config.report_interval must be between 1 and %d seconds", MaxReportInterval)
+	}
+	c.Collectors = cleanList(c.Collectors, true)
+	c.ExcludeInterfaces = cleanList(c.ExcludeInterfaces, false)
+	c.ExcludeMounts = cleanList(c.ExcludeMounts, false)
+	return normalizeLogSources(c)
+}
+
+// isAbsPath 判断 Agent 上的路径是否为绝对路径。Agent 可能运行在 Windows 上，
+// 因此同时接受 /var/log/syslog 与 C:\logs\app.log 两种形式。
+func isAbsPath(p string) bool {
+	if strings.HasPrefix(p, "/") {
+		return true
+	}
+	return len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') &&
+		(p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z')
+}
+
+func normalizeLogSources(c *models.AgentConfig) error {
+	if len(c.LogSources) > MaxLogSources {
+		return invalid.Errorf("config.log_sources must have at most %d entries", MaxLogSources)
+	}
+	names := make([]string, 0, len(c.LogSources))
+	for i := range c.LogSources {
+		source := &c.LogSources[i]
+		source.Type = strings.ToLower(strings.TrimSpace(source.Type))
+		source.Target = strings.TrimSpace(source.Target)
+		source.Name = strings.TrimSpace(source.Name)
+		if source.Name == "" {
+			source.Name = source.Target
+		}
+		switch source.Type {
+		case models.LogSourceFile:
+			if !isAbsPath(source.Target) {
+				return invalid.Errorf("config.log_sources[%d].target must be an absolute file path", i)
+			}
+		case models.LogSourceJournald:
+			if source.Target == "" {
+				return invalid.Errorf("config.log_sources[%d].target must be a systemd unit", i)
+			}
+		default:
+			return invalid.Errorf("config.log_sources[%d].type must be %s or %s", i, models.LogSourceFile, models.LogSourceJournald)
+		}
+		if len(source.Name) > 100 || len(source.Target) > 255 {
+			return invalid.Errorf("config.log_sources[%d] name or target is too long", i)
+		}
+		if slices.Contains(names, source.Name) {
+			return invalid.Errorf("config.log_sources name %q is duplicated", source.Name)
+		}
+		names = append(names, source.Name)
+	}
+	return nil
+}
+
+// CreateProfile 创建配置。新配置没有客户端使用，无需下发。
+func CreateProfile(profile *models.AgentProfile) error {
+	profile.Id = 0
+	if err := normalize(profile); err != nil {
+		return err
+	}
+	db := dbcore.GetDBInstance()
+	return db.Create(profile).Error
+}
+
+// UpdateProfile 更新配置，并向使用它的在线客户端下发新配置。
+func UpdateProfile(profile *models.AgentProfile) error {
+	if err := normalize(profile); err != nil {
+		return err
+	}
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.AgentProfile{}).Where("id = ?", profile.Id).
+		Select("name", "description", "config", "updated_at").Updates(profile)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	var uuids []string
+	if err := db.Model(&models.Client{}).Where("agent_profile_id = ?", profile.Id).Pluck("uuid", &uuids).Error; err != nil {
+		return err
+	}
+	Push(uuids...)
+	return nil
+}
+
+// DeleteProfile 删除配置，并清除客户端与分组策略中的引用；受影响的在线客户端恢复使用本地配置。
+func DeleteProfile(id uint) error {
+	var uuids []string
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.AgentProfile{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		if err := tx.Model(&models.Client{}).Where("agent_profile_id = ?", id).Pluck("uuid", &uuids).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Client{}).Where("agent_profile_id = ?", id).Update("agent_profile_id", nil).Error; err != nil {
+			return err
+		}
+		var groups []models.ClientGroup
+		if err := tx.Find(&groups).Error; err != nil {
+			return err
+		}
+		for _, g := range groups {
+			if g.Policy.AgentProfile == nil || *g.Policy.AgentProfile != id {
+				continue
+			}
+			g.Policy.AgentProfile = nil
+			if err := tx.Model(&models.ClientGroup{}).Where("id = ?", g.Id).Update("policy", g.Policy).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	Push(uuids...)
+	return nil
+}
+
+// AssignClient 为客户端直接分配配置（profileID 为 nil 表示取消），并立即下发。
+func AssignClient(uuid string, profileID *uint) error {
+	db := dbcore.GetDBInstance()
+	if profileID != nil {
+		ok, err := Exists(db, *profileID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return invalid.Errorf("agent profile %d not found", *profileID)
+		}
+	}
+	result := db.Model(&models.Client{}).Where("uuid = ?", uuid).Update("agent_profile_id", profileID)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	Push(uuid)
+	return nil
+}
+
+// effective 返回客户端当前应使用的配置；未分配或配置已不存在时返回 nil。
+func effective(db *gorm.DB, uuid string) (*models.AgentProfile, error) {
+	var client models.Client
+	if err := db.Select("uuid", "agent_profile_id").Where("uuid = ?", uuid).First(&client).Error; err != nil {
+		return nil, err
+	}
+	if client.AgentProfileID == nil {
+		return nil, nil
+	}
+	var profiles []models.AgentProfile
+	if err := db.Where("id = ?", *client.AgentProfileID).Limit(1).Find(&profiles).Error; err != nil {
+		return nil, err
+	}
+	if len(profiles) == 0 {
+		return nil, nil
+	}
+	return &profiles[0], nil
+}
+
+// Push 向在线的 v2 客户端下发当前配置；离线客户端在下次连接时由 Sync 下发。
+func Push(uuids ...string) {
+	for _, uuid := range uuids {
+		if !agent.IsAgentOnline(uuid) {
+			continue
+		}
+		if err := Sync(uuid); err != nil {
+			logger.Warnf("agentprofiles", "Failed to push agent config to %s: %v", uuid, err)
+		}
+	}
+}
+
+// Sync 向客户端下发当前配置，在 v2 Agent 连接（或上报基础信息）时调用。
+func Sync(uuid string) error {
+	if !agent.IsV2Client(uuid) {
+		return nil
+	}
+	db := dbcore.GetDBInstance()
+	profile, err := effective(db, uuid)
+	if err != nil {
+		return err
+	}
+	params := v2.ConfigParams{EventID: agent.NewV2EventID()}
+	if profile != nil {
+		params.Version = Version(profile.Config)
+		params.Profile = profile.Name
+		params.Config = profile.Config
+	}
+	if !agent.DispatchV2EventWithID(uuid, params.EventID, v2.MethodAgentConfig, params) {
+		return nil
+	}
+	now := time.Now().UTC()
+	state := models.ClientAgentConfig{ClientUUID: uuid, PendingEventID: params.EventID, PendingVersion: params.Version, SentAt: &now}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "client_uuid"}},
+		DoUpdates: clause.AssignmentColumns([]string{"pending_event_id", "pending_version", "sent_at"}),
+	}).Create(&state).Error
+}
+
+// Acknowledge 处理 Agent 确认的事件 ID：确认的是最近一次下发的配置时，记录为已生效版本。
+func Acknowledge(uuid string, eventIDs []string) {
+	if len(eventIDs) == 0 {
+		return
+	}
+	db := dbcore.GetDBInstance()
+	var state models.ClientAgentConfig
+	if err := db.Where("client_uuid = ?", uuid).Limit(1).Find(&state).Error; err != nil || state.PendingEventID == "" {
+		return
+	}
+	if !slices.Contains(eventIDs, state.PendingEventID) {
+		return
+	}
+	now := time.Now().UTC()
+	if err := db.Model(&models.ClientAgentConfig{}).
+		Where("client_uuid = ? AND pending_event_id = ?", uuid, state.PendingEventID).
+		Updates(map[string]any{"applied_version": state.PendingVersion, "applied_at": now, "pending_event_id": ""}).Error; err != nil {
+		logger.Warnf("agentprofiles", "Failed to record applied agent config for %s: %v", uuid, err)
+	}
+}
+
+// ClientStatus 是客户端配置的生效情况。
+type ClientStatus struct {
+	UUID           string     `json:"uuid"`
+	Name           string     `json:"name"`
+	ProfileID      *uint      `json:"profile_id"`
+	Profile        string     `json:"profile,omitempty"`
+	Version        string     `json:"version"`         // 当前应使用的版本
+	AppliedVersion string     `json:"applied_version"` // Agent 最近确认的版本
+	AppliedAt      *time.Time `json:"applied_at"`
+	SentAt         *time.Time `json:"sent_at"`
+	Outdated       bool       `json:"outdated"`
+	Online         bool       `json:"online"`
+}
+
+// ListStatus 列出全部客户端的配置生效情况；outdatedOnly 时只返回未生效最新配置的客户端。
+func ListStatus(outdatedOnly bool) ([]ClientStatus, error) {
+	db := dbcore.GetDBInstance()
+	var clientList []models.Client
+	if err := db.Select("uuid", "name", "agent_profile_id").Order("uuid ASC").Find(&clientList).Error; err != nil {
+		return nil, err
+	}
+	profiles, err := ListProfiles()
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.AgentProfile, len(profiles))
+	for _, p := range profiles {
+		byID[p.Id] = p
+	}
+	var states []models.ClientAgentConfig
+	if err := db.Find(&states).Error; err != nil {
+		return nil, err
+	}
+	stateOf := make(map[string]models.ClientAgentConfig, len(states))
+	for _, s := range states {
+		stateOf[s.ClientUUID] = s
+	}
+
+	out := make([]ClientStatus, 0, len(clientList))
+	for _, c := range clientList {
+		state := stateOf[c.UUID]
+		status := ClientStatus{
+			UUID:           c.UUID,
+			Name:           c.Name,
+			ProfileID:      c.AgentProfileID,
+			AppliedVersion: state.AppliedVersion,
+			AppliedAt:      state.AppliedAt,
+			SentAt:         state.SentAt,
+			Online:         agent.IsAgentOnline(c.UUID),
+		}
+		if c.AgentProfileID != nil {
+			if p, ok := byID[*c.AgentProfileID]; ok {
+				status.Profile = p.Name
+				status.Version = Version(p.Config)
+			}
+		}
+		status.Outdated = status.Version != status.AppliedVersion
+		if outdatedOnly && !status.Outdated {
+			continue
+		}
+		out = append(out, status)
+	}
+	return out, nil
+}
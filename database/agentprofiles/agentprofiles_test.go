@@ -0,0 +1,137 @@
+package agentprofiles_test
+
+import (
+	"testing"
+
+	"github.com/komari-monitor/komari/database/agentprofiles"
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"github.com/komari-monitor/komari/web/agent"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func statusOf(t *testing.T, uuid string) agentprofiles.ClientStatus {
+	t.Helper()
+	list, err := agentprofiles.ListStatus(false)
+	if err != nil {
+		t.Fatalf("list status: %v", err)
+	}
+	for _, s := range list {
+		if s.UUID == uuid {
+			return s
+		}
+	}
+	t.Fatalf("client %s missing from status", uuid)
+	return agentprofiles.ClientStatus{}
+}
+
+func TestGroupProfileDeliveryAndAcknowledgement(t *testing.T) {
+	db := dbtest.Open(t, "agentprofiles")
+	profile := models.AgentProfile{Name: "edge", Config: models.AgentConfig{
+		ReportInterval: ptr(5),
+		Collectors:     []string{" CPU ", "memory", "cpu"},
+		AllowTerminal:  ptr(false),
+	}}
+	if err := agentprofiles.CreateProfile(&profile); err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	if len(profile.Config.Collectors) != 2 || profile.Config.Collectors[0] != "cpu" {
+		t.Fatalf("collectors should be normalized, got %v", profile.Config.Collectors)
+	}
+	if err := agentprofiles.CreateProfile(&models.AgentProfile{Name: "edge"}); !invalid.Is(err) {
+		t.Fatalf("duplicate name should be invalid, got %v", err)
+	}
+
+	client := models.Client{UUID: "profile-client", Token: "t", Name: "node"}
+	if err := db.Create(&client).Error; err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	group := models.ClientGroup{Name: "edge", Policy: models.GroupPolicy{AgentProfile: &profile.Id}}
+	if err := clientgroups.CreateGroup(&group); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+
+	// 模拟一个在线的 v2 轮询 Agent：加入分组后配置进入事件队列。
+	agent.SetClientProtocolVersion(client.UUID, 2)
+	t.Cleanup(func() { agent.SetClientProtocolVersion(client.UUID, 0) })
+	if err := clientgroups.SetClientGroup(client.UUID, &group.Id); err != nil {
+		t.Fatalf("set group: %v", err)
+	}
+	if err := db.First(&client, "uuid = ?", client.UUID).Error; err != nil || client.AgentProfileID == nil || *client.AgentProfileID != profile.Id {
+		t.Fatalf("group profile should be materialized onto the client, got %v (%v)", client.AgentProfileID, err)
+	}
+
+	events := agent.TakeV2Events(client.UUID, nil, 0)
+	if len(events) != 1 || events[0].Method != v2.MethodAgentConfig {
+		t.Fatalf("expected one config event, got %#v", events)
+	}
+	params := events[0].Params.(v2.ConfigParams)
+	if params.EventID != events[0].ID || params.Version != agentprofiles.Version(profile.Config) {
+		t.Fatalf("unexpected config params %#v", params)
+	}
+	if s := statusOf(t, client.UUID); !s.Outdated || s.Profile != "edge" {
+		t.Fatalf("client should be outdated before acknowledging, got %#v", s)
+	}
+
+	agentprofiles.Acknowledge(client.UUID, []string{"unrelated"})
+	if s := statusOf(t, client.UUID); !s.Outdated {
+		t.Fatalf("unrelated ack must not mark the config applied: %#v", s)
+	}
+	agentprofiles.Acknowledge(client.UUID, []string{params.EventID})
+	if s := statusOf(t, client.UUID); s.Outdated || s.AppliedVersion != params.Version || s.AppliedAt == nil {
+		t.Fatalf("acknowledged config should be applied, got %#v", s)
+	}
+
+	// 修改配置后版本变化，客户端重新变为过期，直到确认新的事件。
+	profile.Config.ReportInterval = ptr(10)
+	if err := agentprofiles.UpdateProfile(&profile); err != nil {
+		t.Fatalf("update profile: %v", err)
+	}
+	if s := statusOf(t, client.UUID); !s.Outdated {
+		t.Fatalf("client should be outdated after the profile changed: %#v", s)
+	}
+	if outdated, err := agentprofiles.ListStatus(true); err != nil || len(outdated) != 1 {
+		t.Fatalf("outdated filter = %v (%v)", outdated, err)
+	}
+
+	if err := agentprofiles.DeleteProfile(profile.Id); err != nil {
+		t.Fatalf("delete profile: %v", err)
+	}
+	stored, _ := clientgroups.GetGroup(group.Id)
+	if stored.Policy.AgentProfile != nil {
+		t.Fatalf("deleting a profile should clear group references, got %v", *stored.Policy.AgentProfile)
+	}
+	events = agent.TakeV2Events(client.UUID, nil, 0)
+	if len(events) != 1 || events[0].Params.(v2.ConfigParams).Config != nil {
+		t.Fatalf("client should be told to fall back to its local config, got %#v", events)
+	}
+}
+
+func TestLogSourcesAreValidated(t *testing.T) {
+	dbtest.Open(t, "agentprofiles")
+	profile := models.AgentProfile{Name: "logs", Config: models.AgentConfig{LogSources: []models.LogSource{
+		{Type: " File ", Target: "/var/log/syslog"},
+		{Name: "app", Type: "journald", Target: "app.service"},
+		{Name: "win", Type: "file", Target: `C:\logs\app.log`},
+	}}}
+	if err := agentprofiles.CreateProfile(&profile); err != nil {
+		t.Fatalf("create profile: %v", err)
+	}
+	if source := profile.Config.LogSources[0]; source.Name != "/var/log/syslog" || source.Type != models.LogSourceFile {
+		t.Fatalf("log source should be normalized, got %+v", source)
+	}
+	for _, sources := range [][]models.LogSource{
+		{{Type: "file", Target: "relative.log"}},
+		{{Type: "syslog", Target: "/dev/log"}},
+		{{Name: "a", Type: "journald", Target: "x"}, {Name: "a", Type: "journald", Target: "y"}},
+	} {
+		bad := models.AgentProfile{Name: "bad-logs", Config: models.AgentConfig{LogSources: sources}}
+		if err := agentprofiles.CreateProfile(&bad); !invalid.Is(err) {
+			t.Fatalf("log sources %+v should be invalid, got %v", sources, err)
+		}
+	}
+}
@@ -0,0 +1,519 @@
+// Package agentupdates 管理 Agent 自更新使用的版本（models.AgentRelease）与
+// 分阶段更新批次（models.AgentRollout / models.AgentRolloutTarget）。
+// 批次的推进与下发由 web/agentupdate 负责。
+package agentupdates
+
+import (
+	"encoding/hex"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultTimeout = 600
+	MinTimeout     = 60
+	MaxTimeout     = 24 * 60 * 60
+)
+
+// ErrActiveRollout 表示已有进行中（或暂停）的更新批次。
+var ErrActiveRollout = invalid.Errorf("another rollout is still running or paused; finish or cancel it first")
+
+// NormalizeOS 把 Agent 上报的系统名称（如 "Ubuntu 24.04 LTS"、"Windows Server 2022"）
+// 归一化为发布平台名称。
+func NormalizeOS(os string) string {
+	lower := strings.ToLower(strings.TrimSpace(os))
+	switch {
+	case lower == "":
+		return ""
+	case strings.Contains(lower, "windows"):
+		return "windows"
+	case strings.Contains(lower, "darwin"), strings.Contains(lower, "macos"), strings.Contains(lower, "mac os"):
+		return "darwin"
+	case strings.Contains(lower, "freebsd"):
+		return "freebsd"
+	default:
+		return "linux"
+	}
+}
+
+// NormalizeArch 把常见的架构别名归一化为 Go 的 GOARCH 名称。
+func NormalizeArch(arch string) string {
+	lower := strings.ToLower(strings.TrimSpace(arch))
+	switch lower {
+	case "x86_64", "x64", "amd64":
+		return "amd64"
+	case "aarch64", "arm64":
+		return "arm64"
+	case "i386", "i686", "x86", "386":
+		return "386"
+	case "armv7l", "armv7", "armv6l", "arm":
+		return "arm"
+	}
+	return lower
+}
+
+// SameVersion 比较两个版本号，忽略大小写与 v 前缀。
+func SameVersion(a, b string) bool {
+	trim := func(v string) string { return strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "v") }
+	return a != "" && b != "" && trim(a) == trim(b)
+}
+
+// DownloadPath 返回上传到 Komari 的版本的下载路径（相对于服务端地址）。
+func DownloadPath(id uint) string {
+	return fmt.Sprintf("/api/clients/agent-releases/%d/download", id)
+}
+
+// ListReleases 按版本与平台列出全部版本
+func ListReleases() ([]models.AgentRelease, error) {
+	db := dbcore.GetDBInstance()
+	var releases []models.AgentRelease
+	if err := db.Order("version DESC").Order("os ASC").Order("arch ASC").Find(&releases).Error; err != nil {
+		return nil, err
+	}
+	return releases, nil
+}
+
+// GetRelease 根据 ID 获取版本
+func GetRelease(id uint) (*models.AgentRelease, error) {
+	db := dbcore.GetDBInstance()
+	var release models.AgentRelease
+	if err := db.First(&release, id).Error; err != nil {
+		return nil, err
+	}
+	return &release, nil
+}
+
+// FindRelease 查找指定版本在某个平台上的发布，不存在时返回 nil。
+func FindRelease(version, os, arch string) (*models.AgentRelease, error) {
+	db := dbcore.GetDBInstance()
+	var releases []models.AgentRelease
+	if err := db.Where("os = ? AND arch = ?", os, arch).Find(&releases).Error; err != nil {
+		return nil, err
+	}
+	for i := range releases {
+		if SameVersion(releases[i].Version, version) {
+			return &releases[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// CreateRelease 校验并保存版本。URL 与 FilePath 至少设置一个。
+func CreateRelease(release *models.AgentRelease) error {
+	release.Version = strings.TrimSpace(release.Version)
+	release.OS = strings.ToLower(strings.TrimSpace(release.OS))
+	release.Arch = NormalizeArch(release.Arch)
+	release.URL = strings.TrimSpace(release.URL)
+	release.SHA256 = strings.ToLower(strings.TrimSpace(release.SHA256))
+	if release.Version == "" || release.OS == "" || release.Arch == "" {
+		return invalid.Errorf("version, os and arch are required")
+	}
+	switch release.OS {
+	case "linux", "windows", "darwin", "freebsd":
+	default:
+		return invalid.Errorf("unsupported os %q, expected linux, windows, darwin or freebsd", release.OS)
+	}
+	if release.URL == "" && release.FilePath == "" {
+		return invalid.Errorf("either a download url or an uploaded binary is required")
+	}
+	if release.FilePath != "" {
+		if release.URL != "" {
+			return invalid.Errorf("url and file are mutually exclusive")
+		}
+		file, _, err := OpenFile(release.FilePath)
+		if err != nil {
+			return err
+		}
+		if release.SHA256 != "" && release.SHA256 != file.SHA256 {
+			return invalid.Errorf("sha256 does not match the uploaded file")
+		}
+		release.SHA256 = file.SHA256
+		release.Size = file.Size
+	}
+	if release.URL != "" && !strings.HasPrefix(release.URL, "https://") && !strings.HasPrefix(release.URL, "http://") {
+		return invalid.Errorf("url must be an http(s) URL")
+	}
+	if b, err := hex.DecodeString(release.SHA256); err != nil || len(b) != 32 {
+		return invalid.Errorf("sha256 must be a hex encoded SHA-256 checksum")
+	}
+	existing, err := FindRelease(release.Version, release.OS, release.Arch)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return invalid.Errorf("release %s for %s/%s already exists", release.Version, release.OS, release.Arch)
+	}
+	release.Id = 0
+	db := dbcore.GetDBInstance()
+	return db.Create(release).Error
+}
+
+// DeleteRelease 删除版本，上传的二进制不再被引用时一并删除。
+func DeleteRelease(id uint) (*models.AgentRelease, error) {
+	release, err := GetRelease(id)
+	if err != nil {
+		return nil, err
+	}
+	db := dbcore.GetDBInstance()
+	if err := db.Delete(&models.AgentRelease{}, id).Error; err != nil {
+		return nil, err
+	}
+	if release.FilePath != "" {
+		if err := removeUnusedFile(release.FilePath); err != nil {
+			return release, err
+		}
+	}
+	return release, nil
+}
+
+// ListRollouts 按创建时间倒序列出更新批次
+func ListRollouts() ([]models.AgentRollout, error) {
+	db := dbcore.GetDBInstance()
+	var rollouts []models.AgentRollout
+	if err := db.Order("id DESC").Find(&rollouts).Error; err != nil {
+		return nil, err
+	}
+	return rollouts, nil
+}
+
+// GetRollout 根据 ID 获取更新批次
+func GetRollout(id uint) (*models.AgentRollout, error) {
+	db := dbcore.GetDBInstance()
+	var rollout models.AgentRollout
+	if err := db.First(&rollout, id).Error; err != nil {
+		return nil, err
+	}
+	return &rollout, nil
+}
+
+// ActiveRollout 返回进行中或暂停的更新批次，没有时返回 nil。
+func ActiveRollout() (*models.AgentRollout, error) {
+	db := dbcore.GetDBInstance()
+	var rollouts []models.AgentRollout
+	if err := db.Where("status IN ?", []string{models.RolloutRunning, models.RolloutPaused}).
+		Order("id ASC").Limit(1).Find(&rollouts).Error; err != nil {
+		return nil, err
+	}
+	if len(rollouts) == 0 {
+		return nil, nil
+	}
+	return &rollouts[0], nil
+}
+
+// ListTargets 列出更新批次中的客户端进度
+func ListTargets(rolloutID uint) ([]models.AgentRolloutTarget, error) {
+	db := dbcore.GetDBInstance()
+	var targets []models.AgentRolloutTarget
+	if err := db.Where("rollout_id = ?", rolloutID).Order("stage ASC").Order("id ASC").Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	return targets, nil
+}
+
+// normalizePercentages 校验阶段比例：严格递增、位于 1..100，且最后一个阶段为 100。
+func normalizePercentages(percentages []int) ([]int, error) {
+	out := make([]int, 0, len(percentages)+1)
+	prev := 0
+	for _, p := range percentages {
+		if p <= prev || p > 100 {
+			return nil, invalid.Errorf("percentages must be strictly increasing values between 1 and 100")
+		}
+		out = append(out, p)
+		prev = p
+	}
+	if prev != 100 {
+		out = append(out, 100)
+	}
+	return out, nil
+}
+
+// AssignStages 为客户端分配阶段：金丝雀成员为阶段 0，其余客户端按 (批次, UUID) 的哈希
+// 稳定排序后按累计比例依次分配到阶段 1..len(percentages)。
+func AssignStages(uuids []string, canary map[string]bool, percentages []int, seed uint) map[string]int {
+	stages := make(map[string]int, len(uuids))
+	var rest []string
+	for _, uuid := range uuids {
+		if canary[uuid] {
+			stages[uuid] = 0
+		} else {
+			rest = append(rest, uuid)
+		}
+	}
+	key := func(uuid string) uint64 {
+		h := fnv.New64a()
+		fmt.Fprintf(h, "%d:%s", seed, uuid)
+		return h.Sum64()
+	}
+	sort.Slice(rest, func(i, j int) bool {
+		ki, kj := key(rest[i]), key(rest[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return rest[i] < rest[j]
+	})
+	stage := 0
+	for i, uuid := range rest {
+		for stage < len(percentages)-1 && float64(i) >= math.Ceil(float64(len(rest))*float64(percentages[stage])/100) {
+			stage++
+		}
+		stages[uuid] = stage + 1
+	}
+	return stages
+}
+
+// groupSubtree 返回分组及其全部下级分组的 ID。
+func groupSubtree(tx *gorm.DB, root uint) (map[uint]bool, error) {
+	var groups []models.ClientGroup
+	if err := tx.Select("id", "parent_id").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	found := false
+	children := map[uint][]uint{}
+	for _, g := range groups {
+		if g.Id == root {
+			found = true
+		}
+		if g.ParentID != nil {
+			children[*g.ParentID] = append(children[*g.ParentID], g.Id)
+		}
+	}
+	if !found {
+		return nil, invalid.Errorf("canary group %d not found", root)
+	}
+	subtree := map[uint]bool{}
+	queue := []uint{root}
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if subtree[id] {
+			continue
+		}
+		subtree[id] = true
+		queue = append(queue, children[id]...)
+	}
+	return subtree, nil
+}
+
+// CreateRollout 创建更新批次并为全部客户端生成进度记录。已是目标版本的客户端直接记为成功。
+func CreateRollout(rollout *models.AgentRollout) error {
+	rollout.Version = strings.TrimSpace(rollout.Version)
+	if rollout.Version == "" {
+		return invalid.Errorf("version is required")
+	}
+	percentages, err := normalizePercentages(rollout.Percentages)
+	if err != nil {
+		return err
+	}
+	if rollout.FailureThreshold <= 0 {
+		rollout.FailureThreshold = 1
+	}
+	if rollout.Timeout == 0 {
+		rollout.Timeout = DefaultTimeout
+	}
+	if rollout.Timeout < MinTimeout || rollout.Timeout > MaxTimeout {
+		return invalid.Errorf("timeout must be between %d and %d seconds", MinTimeout, MaxTimeout)
+	}
+
+	db := dbcore.GetDBInstance()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var releases int64
+		if err := tx.Model(&models.AgentRelease{}).Where("version = ?", rollout.Version).Count(&releases).Error; err != nil {
+			return err
+		}
+		if releases == 0 {
+			return invalid.Errorf("no release is registered for version %s", rollout.Version)
+		}
+		var active int64
+		if err := tx.Model(&models.AgentRollout{}).
+			Where("status IN ?", []string{models.RolloutRunning, models.RolloutPaused}).
+			Count(&active).Error; err != nil {
+			return err
+		}
+		if active > 0 {
+			return ErrActiveRollout
+		}
+
+		var clients []models.Client
+		if err := tx.Select("uuid", "version", "group_id").Order("uuid ASC").Find(&clients).Error; err != nil {
+			return err
+		}
+		canary := map[string]bool{}
+		if rollout.CanaryGroupID != nil {
+			subtree, err := groupSubtree(tx, *rollout.CanaryGroupID)
+			if err != nil {
+				return err
+			}
+			for _, c := range clients {
+				if c.GroupID != nil && subtree[*c.GroupID] {
+					canary[c.UUID] = true
+				}
+			}
+		}
+
+		now := time.Now().UTC()
+		rollout.Id = 0
+		rollout.Percentages = percentages
+		rollout.Stage = 0
+		rollout.Status = models.RolloutRunning
+		rollout.AcknowledgedFailures = 0
+		rollout.PauseReason = ""
+		rollout.FinishedAt = nil
+		if err := tx.Create(rollout).Error; err != nil {
+			return err
+		}
+
+		uuids := make([]string, 0, len(clients))
+		for _, c := range clients {
+			uuids = append(uuids, c.UUID)
+		}
+		stages := AssignStages(uuids, canary, percentages, rollout.Id)
+		targets := make([]models.AgentRolloutTarget, 0, len(clients))
+		for _, c := range clients {
+			target := models.AgentRolloutTarget{
+				RolloutID:   rollout.Id,
+				ClientUUID:  c.UUID,
+				Stage:       stages[c.UUID],
+				Status:      models.TargetPending,
+				FromVersion: c.Version,
+			}
+			if SameVersion(c.Version, rollout.Version) {
+				target.Status = models.TargetSucceeded
+				target.FinishedAt = &now
+			}
+			targets = append(targets, target)
+		}
+		if len(targets) > 0 {
+			if err := tx.CreateInBatches(targets, 200).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// setRolloutStatus 只在批次处于 from 中的某个状态时修改，避免与后台推进相互覆盖。
+func setRolloutStatus(id uint, from []string, updates map[string]any) error {
+	db := dbcore.GetDBInstance()
+	updates["updated_at"] = time.Now().UTC()
+	result := db.Model(&models.AgentRollout{}).Where("id = ? AND status IN ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := GetRollout(id); err != nil {
+			return err
+		}
+		return invalid.Errorf("rollout %d is not in a state that allows this operation", id)
+	}
+	return nil
+}
+
+// PauseRollout 暂停下发。已下发的客户端继续跟踪结果。
+func PauseRollout(id uint, reason string) error {
+	return setRolloutStatus(id, []string{models.RolloutRunning}, map[string]any{
+		"status":       models.RolloutPaused,
+		"pause_reason": reason,
+	})
+}
+
+// ResumeRollout 恢复下发。当前的失败数不再计入自动暂停阈值；retryFailed 时失败的客户端重新排队。
+func ResumeRollout(id uint, retryFailed bool) error {
+	db := dbcore.GetDBInstance()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if retryFailed {
+			if err := tx.Model(&models.AgentRolloutTarget{}).
+				Where("rollout_id = ? AND status = ?", id, models.TargetFailed).
+				Updates(map[string]any{"status": models.TargetPending, "error": "", "started_at": nil, "finished_at": nil}).Error; err != nil {
+				return err
+			}
+		}
+		var failed int64
+		if err := tx.Model(&models.AgentRolloutTarget{}).
+			Where("rollout_id = ? AND status = ?", id, models.TargetFailed).Count(&failed).Error; err != nil {
+			return err
+		}
+		result := tx.Model(&models.AgentRollout{}).Where("id = ? AND status = ?", id, models.RolloutPaused).Updates(map[string]any{
+			"status":                models.RolloutRunning,
+			"pause_reason":          "",
+			"acknowledged_failures": failed,
+			"updated_at":            time.Now().UTC(),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			var rollout models.AgentRollout
+			if err := tx.First(&rollout, id).Error; err != nil {
+				return err
+			}
+			return invalid.Errorf("rollout %d is not paused", id)
+		}
+		return nil
+	})
+}
+
+// CancelRollout 取消批次，尚未下发的客户端记为跳过。
+func CancelRollout(id uint) error {
+	now := time.Now().UTC()
+	if err := setRolloutStatus(id, []string{models.RolloutRunning, models.RolloutPaused}, map[string]any{
+		"status":      models.RolloutCancelled,
+		"finished_at": now,
+	}); err != nil {
+		return err
+	}
+	db := dbcore.GetDBInstance()
+	return db.Model(&models.AgentRolloutTarget{}).
+		Where("rollout_id = ? AND status = ?", id, models.TargetPending).
+		Updates(map[string]any{"status": models.TargetSkipped, "error": "rollout cancelled", "finished_at": now}).Error
+}
+
+// SetStage 把批次推进到指定阶段。
+func SetStage(id uint, stage int) error {
+	return setRolloutStatus(id, []string{models.RolloutRunning}, map[string]any{"stage": stage})
+}
+
+// CompleteRollout 把进行中的批次标记为完成。
+func CompleteRollout(id uint) error {
+	return setRolloutStatus(id, []string{models.RolloutRunning}, map[string]any{
+		"status":      models.RolloutCompleted,
+		"finished_at": time.Now().UTC(),
+	})
+}
+
+// MarkUpdating 记录已向客户端下发更新。
+func MarkUpdating(targetID, releaseID uint) error {
+	db := dbcore.GetDBInstance()
+	now := time.Now().UTC()
+	return db.Model(&models.AgentRolloutTarget{}).Where("id = ? AND status = ?", targetID, models.TargetPending).
+		Updates(map[string]any{"status": models.TargetUpdating, "release_id": releaseID, "started_at": now, "error": ""}).Error
+}
+
+// FinishTarget 把仍处于 from 状态的客户端标记为最终状态，返回是否发生了修改。
+func FinishTarget(targetID uint, from, status, message string) (bool, error) {
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.AgentRolloutTarget{}).Where("id = ? AND status = ?", targetID, from).
+		Updates(map[string]any{"status": status, "error": message, "finished_at": time.Now().UTC()})
+	return result.RowsAffected > 0, result.Error
+}
+
+// GetTarget 获取客户端在更新批次中的进度，不存在时返回 nil。
+func GetTarget(rolloutID uint, uuid string) (*models.AgentRolloutTarget, error) {
+	db := dbcore.GetDBInstance()
+	var targets []models.AgentRolloutTarget
+	if err := db.Where("rollout_id = ? AND client_uuid = ?", rolloutID, uuid).Limit(1).Find(&targets).Error; err != nil {
+		return nil, err
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+	return &targets[0], nil
+}
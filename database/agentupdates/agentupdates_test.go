@@ -0,0 +1,152 @@
+package agentupdates
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+)
+
+func TestAssignStagesIsDeterministicAndCumulative(t *testing.T) {
+	uuids := make([]string, 20)
+	for i := range uuids {
+		uuids[i] = fmt.Sprintf("client-%02d", i)
+	}
+	canary := map[string]bool{"client-03": true, "client-07": true}
+
+	stages := AssignStages(uuids, canary, []int{10, 50, 100}, 1)
+	counts := map[int]int{}
+	for _, uuid := range uuids {
+		counts[stages[uuid]]++
+	}
+	// 金丝雀 2 个，其余 18 个按 10%/50%/100% 累计：2、7、9。
+	if counts[0] != 2 || counts[1] != 2 || counts[2] != 7 || counts[3] != 9 {
+		t.Fatalf("unexpected stage sizes %v", counts)
+	}
+	if stages["client-03"] != 0 || stages["client-07"] != 0 {
+		t.Fatalf("canary clients must be in stage 0: %v", stages)
+	}
+	again := AssignStages(uuids, canary, []int{10, 50, 100}, 1)
+	for _, uuid := range uuids {
+		if again[uuid] != stages[uuid] {
+			t.Fatalf("assignment must be deterministic, %s moved from %d to %d", uuid, stages[uuid], again[uuid])
+		}
+	}
+}
+
+func TestNormalizePlatform(t *testing.T) {
+	cases := []struct{ os, arch, wantOS, wantArch string }{
+		{"Ubuntu 24.04 LTS", "x86_64", "linux", "amd64"},
+		{"Windows Server 2022", "amd64", "windows", "amd64"},
+		{"macOS 14.5", "arm64", "darwin", "arm64"},
+		{"FreeBSD 14.1", "aarch64", "freebsd", "arm64"},
+	}
+	for _, c := range cases {
+		if got := NormalizeOS(c.os); got != c.wantOS {
+			t.Errorf("NormalizeOS(%q) = %q, want %q", c.os, got, c.wantOS)
+		}
+		if got := NormalizeArch(c.arch); got != c.wantArch {
+			t.Errorf("NormalizeArch(%q) = %q, want %q", c.arch, got, c.wantArch)
+		}
+	}
+	if !SameVersion("v1.2.0", "1.2.0") || SameVersion("1.2.0", "1.2.1") || SameVersion("", "") {
+		t.Fatal("SameVersion mismatch")
+	}
+}
+
+func TestRolloutLifecycle(t *testing.T) {
+	db := dbtest.Open(t, "agentupdates")
+	canary := models.ClientGroup{Name: "canary"}
+	if err := db.Create(&canary).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	child := models.ClientGroup{Name: "canary-eu", ParentID: &canary.Id}
+	if err := db.Create(&child).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	clientsToCreate := []models.Client{
+		{UUID: "a", Token: "ta", Version: "1.0.0", GroupID: &child.Id},
+		{UUID: "b", Token: "tb", Version: "1.0.0"},
+		{UUID: "c", Token: "tc", Version: "v1.1.0"},
+	}
+	for _, c := range clientsToCreate {
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+
+	if err := CreateRollout(&models.AgentRollout{Version: "1.1.0"}); !invalid.Is(err) {
+		t.Fatalf("rollout without a release should be rejected, got %v", err)
+	}
+	release := models.AgentRelease{Version: "1.1.0", OS: "linux", Arch: "x86_64", URL: "https://example.com/agent", SHA256: strings.Repeat("ab", 32)}
+	if err := CreateRelease(&release); err != nil {
+		t.Fatalf("create release: %v", err)
+	}
+	if release.Arch != "amd64" {
+		t.Fatalf("arch should be normalized, got %q", release.Arch)
+	}
+	if err := CreateRollout(&models.AgentRollout{Version: "1.1.0", Percentages: models.IntArray{60, 30}}); !invalid.Is(err) {
+		t.Fatalf("decreasing percentages should be rejected, got %v", err)
+	}
+
+	rollout := models.AgentRollout{Version: "1.1.0", CanaryGroupID: &canary.Id, Percentages: models.IntArray{50}}
+	if err := CreateRollout(&rollout); err != nil {
+		t.Fatalf("create rollout: %v", err)
+	}
+	if len(rollout.Percentages) != 2 || rollout.Percentages[1] != 100 || rollout.Status != models.RolloutRunning {
+		t.Fatalf("unexpected rollout %#v", rollout)
+	}
+	if err := CreateRollout(&models.AgentRollout{Version: "1.1.0"}); !errors.Is(err, ErrActiveRollout) {
+		t.Fatalf("second active rollout should be rejected, got %v", err)
+	}
+
+	targets, err := ListTargets(rollout.Id)
+	if err != nil || len(targets) != 3 {
+		t.Fatalf("targets = %v (%v)", targets, err)
+	}
+	byUUID := map[string]models.AgentRolloutTarget{}
+	for _, target := range targets {
+		byUUID[target.ClientUUID] = target
+	}
+	if byUUID["a"].Stage != 0 {
+		t.Fatalf("client in a canary subgroup should be in stage 0, got %d", byUUID["a"].Stage)
+	}
+	if byUUID["c"].Status != models.TargetSucceeded {
+		t.Fatalf("client already on the target version should succeed immediately, got %q", byUUID["c"].Status)
+	}
+
+	if err := MarkUpdating(byUUID["a"].Id, release.Id); err != nil {
+		t.Fatalf("mark updating: %v", err)
+	}
+	if changed, err := FinishTarget(byUUID["a"].Id, models.TargetUpdating, models.TargetFailed, "timeout"); err != nil || !changed {
+		t.Fatalf("finish target = %v, %v", changed, err)
+	}
+	if err := PauseRollout(rollout.Id, "threshold"); err != nil {
+		t.Fatalf("pause: %v", err)
+	}
+	if err := PauseRollout(rollout.Id, "again"); !invalid.Is(err) {
+		t.Fatalf("pausing a paused rollout should be invalid, got %v", err)
+	}
+	if err := ResumeRollout(rollout.Id, false); err != nil {
+		t.Fatalf("resume: %v", err)
+	}
+	resumed, _ := GetRollout(rollout.Id)
+	if resumed.Status != models.RolloutRunning || resumed.AcknowledgedFailures != 1 || resumed.PauseReason != "" {
+		t.Fatalf("resumed rollout = %#v", resumed)
+	}
+
+	if err := CancelRollout(rollout.Id); err != nil {
+		t.Fatalf("cancel: %v", err)
+	}
+	target, _ := GetTarget(rollout.Id, "b")
+	if target == nil || target.Status != models.TargetSkipped {
+		t.Fatalf("pending client should be skipped on cancel, got %#v", target)
+	}
+	if active, err := ActiveRollout(); err != nil || active != nil {
+		t.Fatalf("no rollout should be active after cancel, got %#v (%v)", active, err)
+	}
+}
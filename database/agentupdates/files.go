@@ -0,0 +1,90 @@
+package agentupdates
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+)
+
+// ReleaseDir 是上传的 Agent 二进制的存放目录，文件以 SHA-256 命名。
+var ReleaseDir = filepath.Join(".", "data", "agent-releases")
+
+// UploadedFile 是一个已上传、尚未（或已经）登记为版本的二进制。
+type UploadedFile struct {
+	File   string `json:"file"`
+	SHA256 string `json:"sha256"`
+	Size   int64  `json:"size"`
+}
+
+func validFileName(name string) bool {
+	b, err := hex.DecodeString(name)
+	return err == nil && len(b) == sha256.Size
+}
+
+// StoreFile 计算 src 的 SHA-256 并把它移动到 ReleaseDir。相同内容的文件只保存一份。
+func StoreFile(src string) (*UploadedFile, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.MkdirAll(ReleaseDir, 0o755); err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(ReleaseDir, sum)
+	if _, err := os.Stat(dst); err == nil {
+		return &UploadedFile{File: sum, SHA256: sum, Size: size}, nil
+	}
+	if err := os.Rename(src, dst); err != nil {
+		return nil, fmt.Errorf("store agent binary: %w", err)
+	}
+	return &UploadedFile{File: sum, SHA256: sum, Size: size}, nil
+}
+
+// OpenFile 打开已上传的二进制。
+func OpenFile(name string) (*UploadedFile, string, error) {
+	if !validFileName(name) {
+		return nil, "", invalid.Errorf("invalid uploaded file %q", name)
+	}
+	path := filepath.Join(ReleaseDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, "", invalid.Errorf("uploaded file %q not found", name)
+		}
+		return nil, "", err
+	}
+	return &UploadedFile{File: name, SHA256: name, Size: info.Size()}, path, nil
+}
+
+// removeUnusedFile 在没有版本引用时删除上传的二进制。
+func removeUnusedFile(name string) error {
+	if !validFileName(name) {
+		return nil
+	}
+	db := dbcore.GetDBInstance()
+	var count int64
+	if err := db.Model(&models.AgentRelease{}).Where("file_path = ?", name).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+	if err := os.Remove(filepath.Join(ReleaseDir, name)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,114 @@
+package backupdestinations
+
+import (
+	"errors"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/gorm"
+)
+
+// uploadHistoryLimit 每个目标保留的上传记录条数。
+const uploadHistoryLimit = 200
+
+// ListBackupDestinations 列出所有异地备份目标
+func ListBackupDestinations() ([]models.BackupDestination, error) {
+	db := dbcore.GetDBInstance()
+	var destinations []models.BackupDestination
+	if err := db.Order("id ASC").Find(&destinations).Error; err != nil {
+		return nil, err
+	}
+	return destinations, nil
+}
+
+// ListEnabledBackupDestinations 列出启用的异地备份目标
+func ListEnabledBackupDestinations() ([]models.BackupDestination, error) {
+	db := dbcore.GetDBInstance()
+	var destinations []models.BackupDestination
+	if err := db.Where("enabled = ?", true).Order("id ASC").Find(&destinations).Error; err != nil {
+		return nil, err
+	}
+	return destinations, nil
+}
+
+// GetBackupDestination 根据 ID 获取异地备份目标
+func GetBackupDestination(id uint) (*models.BackupDestination, error) {
+	db := dbcore.GetDBInstance()
+	var destination models.BackupDestination
+	if err := db.First(&destination, id).Error; err != nil {
+		return nil, err
+	}
+	return &destination, nil
+}
+
+// CreateBackupDestination 创建异地备份目标
+func CreateBackupDestination(destination *models.BackupDestination) error {
+	db := dbcore.GetDBInstance()
+	// Select("*") 保证 enabled=false 等零值不会被数据库默认值覆盖。
+	return db.Select("*").Omit("id").Create(destination).Error
+}
+
+// UpdateBackupDestination 整体覆盖异地备份目标配置
+func UpdateBackupDestination(destination *models.BackupDestination) error {
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.BackupDestination{}).Where("id = ?", destination.Id).
+		Select("*").Omit("id", "created_at").Updates(destination)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteBackupDestination 删除异地备份目标及其上传记录
+func DeleteBackupDestination(id uint) error {
+	db := dbcore.GetDBInstance()
+	return db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", id).Delete(&models.BackupDestination{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return tx.Where("destination_id = ?", id).Delete(&models.BackupUpload{}).Error
+	})
+}
+
+// RecordUpload 保存一次上传结果，并只保留该目标最近的 uploadHistoryLimit 条记录。
+func RecordUpload(upload *models.BackupUpload) error {
+	db := dbcore.GetDBInstance()
+	if err := db.Create(upload).Error; err != nil {
+		return err
+	}
+	var cutoff models.BackupUpload
+	err := db.Where("destination_id = ?", upload.DestinationID).
+		Order("id DESC").Offset(uploadHistoryLimit).Limit(1).Take(&cutoff).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return db.Where("destination_id = ? AND id <= ?", upload.DestinationID, cutoff.Id).
+		Delete(&models.BackupUpload{}).Error
+}
+
+// ListUploads 按时间倒序列出上传记录。destinationID 为 0 时列出全部目标。
+func ListUploads(destinationID uint, limit int) ([]models.BackupUpload, error) {
+	db := dbcore.GetDBInstance()
+	query := db.Order("id DESC")
+	if destinationID != 0 {
+		query = query.Where("destination_id = ?", destinationID)
+	}
+	if limit > 0 {
+		query = query.Limit(limit)
+	}
+	var uploads []models.BackupUpload
+	if err := query.Find(&uploads).Error; err != nil {
+		return nil, err
+	}
+	return uploads, nil
+}
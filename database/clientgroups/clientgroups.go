@@ -0,0 +1,485 @@
+// Package clientgroups 管理客户端分组及其继承策略（见 models.ClientGroup）。
+//
+// 所有会改变成员生效策略的操作（加入/离开分组、修改分组策略或上级、删除分组、
+// 取消客户端覆盖）都在同一事务中把策略差异物化到成员上，并在提交后重新加载
+// Ping 与负载通知调度。
+package clientgroups
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentprofiles"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/notification"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"gorm.io/gorm"
+)
+
+// ErrInvalidParent 表示上级分组不存在，或会使分组成为自身的下级。
+var ErrInvalidParent error = invalid.Errorf("parent group does not exist or would create a cycle")
+
+// EffectivePolicy 是某个客户端当前从分组继承的策略。
+type EffectivePolicy struct {
+	GroupID   *uint              `json:"group_id"`
+	Chain     []string           `json:"chain"` // 根 → 所属分组的名称
+	Policy    models.GroupPolicy `json:"policy"`
+	Overrides []string           `json:"overrides"`
+}
+
+// ListGroups 按 sort_order、id 列出全部分组
+func ListGroups() ([]models.ClientGroup, error) {
+	db := dbcore.GetDBInstance()
+	var groups []models.ClientGroup
+	if err := db.Order("sort_order ASC").Order("id ASC").Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	return groups, nil
+}
+
+// GetGroup 根据 ID 获取分组
+func GetGroup(id uint) (*models.ClientGroup, error) {
+	db := dbcore.GetDBInstance()
+	var group models.ClientGroup
+	if err := db.First(&group, id).Error; err != nil {
+		return nil, err
+	}
+	return &group, nil
+}
+
+// MemberCounts 返回各分组的直接成员数量
+func MemberCounts() (map[uint]int64, error) {
+	db := dbcore.GetDBInstance()
+	var rows []struct {
+		GroupID uint
+		Count   int64
+	}
+	if err := db.Model(&models.Client{}).Select("group_id, COUNT(*) AS count").
+		Where("group_id IS NOT NULL").Group("group_id").Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]int64, len(rows))
+	for _, r := range rows {
+		out[r.GroupID] = r.Count
+	}
+	return out, nil
+}
+
+// ResolvePolicy 返回分组沿上级链路合并后的生效策略
+func ResolvePolicy(id uint) (models.GroupPolicy, error) {
+	groups, err := loadGroups(dbcore.GetDBInstance())
+	if err != nil {
+		return models.GroupPolicy{}, err
+	}
+	return resolveGroup(groups, &id)
+}
+
+// GetClientPolicy 返回客户端当前继承的策略
+func GetClientPolicy(uuid string) (*EffectivePolicy, error) {
+	db := dbcore.GetDBInstance()
+	var client models.Client
+	if err := db.Where("uuid = ?", uuid).First(&client).Error; err != nil {
+		return nil, err
+	}
+	out := &EffectivePolicy{GroupID: client.GroupID, Chain: []string{}, Overrides: client.PolicyOverrides}
+	if out.Overrides == nil {
+		out.Overrides = []string{}
+	}
+	if client.GroupID == nil {
+		return out, nil
+	}
+	groups, err := loadGroups(db)
+	if err != nil {
+		return nil, err
+	}
+	chain, err := chainOf(groups, *client.GroupID)
+	if err != nil {
+		return nil, err
+	}
+	for _, g := range chain {
+		out.Chain = append(out.Chain, g.Name)
+	}
+	out.Policy = resolve(chain)
+	return out, nil
+}
+
+func normalizeGroup(group *models.ClientGroup) error {
+	group.Name = strings.TrimSpace(group.Name)
+	if group.Name == "" || len(group.Name) > 100 {
+		return invalid.Errorf("name is required and must be at most 100 characters")
+	}
+	if err := ValidatePolicy(group.Policy); err != nil {
+		return err
+	}
+	if group.Policy.AgentProfile != nil {
+		ok, err := agentprofiles.Exists(dbcore.GetDBInstance(), *group.Policy.AgentProfile)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return invalid.Errorf("agent profile %d not found", *group.Policy.AgentProfile)
+		}
+	}
+	return nil
+}
+
+// CreateGroup 创建分组。新分组没有成员，无需物化策略。
+func CreateGroup(group *models.ClientGroup) error {
+	if err := normalizeGroup(group); err != nil {
+		return err
+	}
+	db := dbcore.GetDBInstance()
+	return db.Transaction(func(tx *gorm.DB) error {
+		if group.ParentID != nil {
+			if err := tx.First(&models.ClientGroup{}, *group.ParentID).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return ErrInvalidParent
+				}
+				return err
+			}
+		}
+		return tx.Select("*").Omit("id").Create(group).Error
+	})
+}
+
+// UpdateGroup 整体覆盖分组配置，并把策略变化物化到该分组及其下级分组的全部成员。
+func UpdateGroup(group *models.ClientGroup) error {
+	if err := normalizeGroup(group); err != nil {
+		return err
+	}
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		before, err := loadGroups(tx)
+		if err != nil {
+			return err
+		}
+		existing, ok := before[group.Id]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		after := make(map[uint]models.ClientGroup, len(before))
+		for id, g := range before {
+			after[id] = g
+		}
+		after[group.Id] = *group
+		if group.ParentID != nil {
+			if _, err := chainOf(after, group.Id); err != nil {
+				return ErrInvalidParent
+			}
+		}
+
+		if err := tx.Model(&models.ClientGroup{}).Where("id = ?", group.Id).
+			Select("*").Omit("id", "created_at").Updates(group).Error; err != nil {
+			return err
+		}
+		if existing.Name != group.Name {
+			if err := tx.Model(&models.Client{}).Where("group_id = ?", group.Id).
+				Update("group", group.Name).Error; err != nil {
+				return err
+			}
+		}
+		res, err = rematerialize(tx, before, after, descendantsOf(after, group.Id))
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+// DeleteGroup 删除分组。下级分组与成员改挂到被删分组的上级（或成为顶级/未分组），
+// 成员的策略随之切换为新上级的生效策略。
+func DeleteGroup(id uint) error {
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		before, err := loadGroups(tx)
+		if err != nil {
+			return err
+		}
+		deleted, ok := before[id]
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		after := make(map[uint]models.ClientGroup, len(before))
+		for gid, g := range before {
+			if gid == id {
+				continue
+			}
+			if g.ParentID != nil && *g.ParentID == id {
+				g.ParentID = deleted.ParentID
+			}
+			after[gid] = g
+		}
+		affected := descendantsOf(before, id)
+
+		if err := tx.Model(&models.ClientGroup{}).Where("parent_id = ?", id).
+			Update("parent_id", deleted.ParentID).Error; err != nil {
+			return err
+		}
+		var members []models.Client
+		if err := tx.Where("group_id = ?", id).Find(&members).Error; err != nil {
+			return err
+		}
+		parentName := ""
+		if deleted.ParentID != nil {
+			parentName = before[*deleted.ParentID].Name
+		}
+		for _, m := range members {
+			prev, err := resolveGroup(before, m.GroupID)
+			if err != nil {
+				return err
+			}
+			next, err := resolveGroup(after, deleted.ParentID)
+			if err != nil {
+				return err
+			}
+			r, err := applyPolicy(tx, m, prev, next)
+			if err != nil {
+				return fmt.Errorf("client %s: %w", m.UUID, err)
+			}
+			res.merge(r)
+		}
+		if err := tx.Model(&models.Client{}).Where("group_id = ?", id).
+			Updates(map[string]any{"group_id": deleted.ParentID, "group": parentName}).Error; err != nil {
+			return err
+		}
+		if err := tx.Delete(&models.ClientGroup{}, id).Error; err != nil {
+			return err
+		}
+		// 下级分组的成员没有换组，但链路少了被删分组的策略。
+		rest := slices.DeleteFunc(affected, func(gid uint) bool { return gid == id })
+		r, err := rematerialize(tx, before, after, rest)
+		res.merge(r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+// rematerialize 对 groupIDs 下的成员应用从 before 到 after 的策略变化。
+func rematerialize(tx *gorm.DB, before, after map[uint]models.ClientGroup, groupIDs []uint) (applyResult, error) {
+	var res applyResult
+	if len(groupIDs) == 0 {
+		return res, nil
+	}
+	var members []models.Client
+	if err := tx.Where("group_id IN ?", groupIDs).Find(&members).Error; err != nil {
+		return res, err
+	}
+	for _, m := range members {
+		prev, err := resolveGroup(before, m.GroupID)
+		if err != nil {
+			return res, err
+		}
+		next, err := resolveGroup(after, m.GroupID)
+		if err != nil {
+			return res, err
+		}
+		r, err := applyPolicy(tx, m, prev, next)
+		if err != nil {
+			return res, fmt.Errorf("client %s: %w", m.UUID, err)
+		}
+		res.merge(r)
+	}
+	return res, nil
+}
+
+// SetClientGroup 把客户端移入分组（groupID 为 nil 表示移出分组），
+// 并应用新旧分组生效策略的差异。
+func SetClientGroup(uuid string, groupID *uint) error {
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		res, err = setClientGroup(tx, uuid, groupID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+// SetClientGroupByName 按名称设置客户端分组，兼容旧的自由文本 group 字段：
+// 不存在的名称会创建为顶级分组，空名称表示移出分组。
+func SetClientGroupByName(uuid, name string) error {
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		id, err := EnsureGroupID(tx, name)
+		if err != nil {
+			return err
+		}
+		res, err = setClientGroup(tx, uuid, id)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+// ApplyDefaults 为新客户端设置初始分组（groupID 可为 nil），并在分组策略之后追加
+// extra 中的配置（例如注册令牌的默认值）。extra 只增加规则成员，不撤销任何已有配置。
+func ApplyDefaults(uuid string, groupID *uint, extra models.GroupPolicy) error {
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if groupID != nil {
+			r, err := setClientGroup(tx, uuid, groupID)
+			if err != nil {
+				return err
+			}
+			res.merge(r)
+		}
+		var client models.Client
+		if err := tx.Where("uuid = ?", uuid).First(&client).Error; err != nil {
+			return err
+		}
+		r, err := applyPolicy(tx, client, models.GroupPolicy{}, extra)
+		res.merge(r)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+func setClientGroup(tx *gorm.DB, uuid string, groupID *uint) (applyResult, error) {
+	var client models.Client
+	if err := tx.Where("uuid = ?", uuid).First(&client).Error; err != nil {
+		return applyResult{}, err
+	}
+	groups, err := loadGroups(tx)
+	if err != nil {
+		return applyResult{}, err
+	}
+	name := ""
+	if groupID != nil {
+		g, ok := groups[*groupID]
+		if !ok {
+			return applyResult{}, invalid.Errorf("client group %d not found", *groupID)
+		}
+		name = g.Name
+	}
+	prev, err := resolveGroup(groups, client.GroupID)
+	if err != nil {
+		return applyResult{}, err
+	}
+	next, err := resolveGroup(groups, groupID)
+	if err != nil {
+		return applyResult{}, err
+	}
+	if err := tx.Model(&models.Client{}).Where("uuid = ?", uuid).
+		Updates(map[string]any{"group_id": groupID, "group": name, "updated_at": time.Now().UTC()}).Error; err != nil {
+		return applyResult{}, err
+	}
+	return applyPolicy(tx, client, prev, next)
+}
+
+// EnsureGroupID 返回名称对应的分组 ID，不存在时创建为顶级分组；空名称返回 nil。
+func EnsureGroupID(tx *gorm.DB, name string) (*uint, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return nil, nil
+	}
+	var group models.ClientGroup
+	err := tx.Where("name = ?", name).First(&group).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		group = models.ClientGroup{Name: name}
+		if len(name) > 100 {
+			return nil, invalid.Errorf("group name must be at most 100 characters")
+		}
+		err = tx.Select("*").Omit("id").Create(&group).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &group.Id, nil
+}
+
+// SetClientOverrides 设置客户端不再继承的策略项。被取消覆盖的策略项会立即按
+// 当前分组策略重新下发；新增覆盖的策略项保持现状，之后由管理员单独维护。
+func SetClientOverrides(uuid string, overrides []string) error {
+	clean := make(models.StringArray, 0, len(overrides))
+	for _, key := range overrides {
+		if !slices.Contains(models.GroupPolicyKeys, key) {
+			return invalid.Errorf("unknown policy %q, expected one of %s", key, strings.Join(models.GroupPolicyKeys, ", "))
+		}
+		if !slices.Contains(clean, key) {
+			clean = append(clean, key)
+		}
+	}
+	var res applyResult
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var client models.Client
+		if err := tx.Where("uuid = ?", uuid).First(&client).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Client{}).Where("uuid = ?", uuid).
+			Update("policy_overrides", clean).Error; err != nil {
+			return err
+		}
+		if client.GroupID == nil {
+			return nil
+		}
+		groups, err := loadGroups(tx)
+		if err != nil {
+			return err
+		}
+		next, err := resolveGroup(groups, client.GroupID)
+		if err != nil {
+			return err
+		}
+		// 仅对取消覆盖的策略项下发：其余策略项在本次应用中视为覆盖；
+		// 以空策略为起点，列表项只增不减。
+		released := slices.DeleteFunc(slices.Clone(client.PolicyOverrides), func(key string) bool {
+			return slices.Contains(clean, key)
+		})
+		if len(released) == 0 {
+			return nil
+		}
+		client.PolicyOverrides = slices.DeleteFunc(slices.Clone(models.GroupPolicyKeys), func(key string) bool {
+			return slices.Contains(released, key)
+		})
+		res, err = applyPolicy(tx, client, models.GroupPolicy{}, next)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	reload(res)
+	return nil
+}
+
+// reload 在物化改动了规则成员后重新加载相应调度。
+func reload(res applyResult) {
+	if res.pingTasks {
+		if err := tasks.ReloadPingSchedule(); err != nil {
+			logger.Warnf("clientgroups", "Failed to reload ping schedule: %v", err)
+		}
+	}
+	if res.loadNotifications {
+		if err := notification.ReloadLoadNotificationSchedule(); err != nil {
+			logger.Warnf("clientgroups", "Failed to reload load notification schedule: %v", err)
+		}
+	}
+	agentprofiles.Push(res.agentProfiles...)
+}
@@ -0,0 +1,186 @@
+package clientgroups
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"gorm.io/gorm"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func createClient(t *testing.T, db *gorm.DB, uuid string) {
+	t.Helper()
+	if err := db.Create(&models.Client{UUID: uuid, Token: "token-" + uuid, Name: uuid}).Error; err != nil {
+		t.Fatalf("create client %s: %v", uuid, err)
+	}
+}
+
+func createPingTask(t *testing.T, db *gorm.DB, name string) uint {
+	t.Helper()
+	task := models.PingTask{Name: name, Type: "icmp", Target: name + ".example", Interval: 60, Clients: models.StringArray{}}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("create ping task: %v", err)
+	}
+	return task.Id
+}
+
+func pingClients(t *testing.T, db *gorm.DB, id uint) models.StringArray {
+	t.Helper()
+	var task models.PingTask
+	if err := db.First(&task, id).Error; err != nil {
+		t.Fatalf("load ping task %d: %v", id, err)
+	}
+	return task.Clients
+}
+
+func loadClient(t *testing.T, db *gorm.DB, uuid string) models.Client {
+	t.Helper()
+	var c models.Client
+	if err := db.Where("uuid = ?", uuid).First(&c).Error; err != nil {
+		t.Fatalf("load client: %v", err)
+	}
+	return c
+}
+
+func TestGroupPolicyInheritanceAndMembership(t *testing.T) {
+	db := dbtest.Open(t, "client_groups")
+	base := createPingTask(t, db, "hk-base")
+	premium := createPingTask(t, db, "hk-premium")
+	createClient(t, db, "node-hk")
+
+	hk := models.ClientGroup{Name: "HK", Policy: models.GroupPolicy{
+		PingTasks: []uint{base},
+		Offline:   &models.OfflineNotificationPolicy{Enable: ptr(true), GracePeriod: ptr(60)},
+		Billing:   &models.BillingPolicy{Price: ptr(5.0), Currency: ptr("$")},
+	}}
+	if err := CreateGroup(&hk); err != nil {
+		t.Fatalf("create HK: %v", err)
+	}
+	child := models.ClientGroup{Name: "HK Premium", ParentID: &hk.Id, Policy: models.GroupPolicy{
+		PingTasks: []uint{premium},
+		Billing:   &models.BillingPolicy{Currency: ptr("HK$")},
+	}}
+	if err := CreateGroup(&child); err != nil {
+		t.Fatalf("create child: %v", err)
+	}
+
+	// 加入子分组：继承上级的任务、离线通知与价格，币种由子分组覆盖。
+	if err := SetClientGroup("node-hk", &child.Id); err != nil {
+		t.Fatalf("set group: %v", err)
+	}
+	if !slices.Contains(pingClients(t, db, base), "node-hk") || !slices.Contains(pingClients(t, db, premium), "node-hk") {
+		t.Fatal("member should be added to inherited and own ping tasks")
+	}
+	var offline models.OfflineNotification
+	if err := db.Where("client = ?", "node-hk").First(&offline).Error; err != nil || !offline.Enable || offline.GracePeriod != 60 {
+		t.Fatalf("offline notification = %#v (%v), want enabled with 60s grace", offline, err)
+	}
+	c := loadClient(t, db, "node-hk")
+	if c.Price != 5 || c.Currency != "HK$" || c.Group != "HK Premium" {
+		t.Fatalf("client billing/group = %v %q %q", c.Price, c.Currency, c.Group)
+	}
+
+	// 分组移除任务：成员随之移除。
+	hk.Policy.PingTasks = nil
+	if err := UpdateGroup(&hk); err != nil {
+		t.Fatalf("update HK: %v", err)
+	}
+	if slices.Contains(pingClients(t, db, base), "node-hk") {
+		t.Fatal("member should be removed from a task dropped by the parent group")
+	}
+
+	// 覆盖 ping_tasks 后，分组新增的任务不再下发；取消覆盖后补齐。
+	if err := SetClientOverrides("node-hk", []string{models.PolicyPingTasks}); err != nil {
+		t.Fatalf("set overrides: %v", err)
+	}
+	hk.Policy.PingTasks = []uint{base}
+	if err := UpdateGroup(&hk); err != nil {
+		t.Fatalf("update HK: %v", err)
+	}
+	if slices.Contains(pingClients(t, db, base), "node-hk") {
+		t.Fatal("overridden policy must not be applied")
+	}
+	if err := SetClientOverrides("node-hk", nil); err != nil {
+		t.Fatalf("clear overrides: %v", err)
+	}
+	if !slices.Contains(pingClients(t, db, base), "node-hk") {
+		t.Fatal("clearing the override should apply the group's ping tasks")
+	}
+
+	// 删除子分组：成员回到上级分组，子分组独有的任务被移除。
+	if err := DeleteGroup(child.Id); err != nil {
+		t.Fatalf("delete child: %v", err)
+	}
+	c = loadClient(t, db, "node-hk")
+	if c.GroupID == nil || *c.GroupID != hk.Id || c.Group != "HK" {
+		t.Fatalf("member should move to parent, got %v %q", c.GroupID, c.Group)
+	}
+	if slices.Contains(pingClients(t, db, premium), "node-hk") || !slices.Contains(pingClients(t, db, base), "node-hk") {
+		t.Fatal("member should keep parent tasks and lose child-only tasks")
+	}
+
+	// 离开分组：列表类策略撤销。
+	if err := SetClientGroup("node-hk", nil); err != nil {
+		t.Fatalf("ungroup: %v", err)
+	}
+	if slices.Contains(pingClients(t, db, base), "node-hk") || loadClient(t, db, "node-hk").Group != "" {
+		t.Fatal("ungrouped client should leave group tasks")
+	}
+}
+
+func TestGroupHierarchyValidation(t *testing.T) {
+	dbtest.Open(t, "client_groups")
+	root := models.ClientGroup{Name: "root-v"}
+	if err := CreateGroup(&root); err != nil {
+		t.Fatalf("create root: %v", err)
+	}
+	leaf := models.ClientGroup{Name: "leaf-v", ParentID: &root.Id}
+	if err := CreateGroup(&leaf); err != nil {
+		t.Fatalf("create leaf: %v", err)
+	}
+
+	root.ParentID = &leaf.Id
+	if err := UpdateGroup(&root); !invalid.Is(err) {
+		t.Fatalf("cycle should be rejected as invalid, got %v", err)
+	}
+	missing := uint(9999)
+	orphan := models.ClientGroup{Name: "orphan-v", ParentID: &missing}
+	if err := CreateGroup(&orphan); !invalid.Is(err) {
+		t.Fatalf("missing parent should be rejected as invalid, got %v", err)
+	}
+	bad := models.ClientGroup{Name: "bad-v", Policy: models.GroupPolicy{
+		Billing: &models.BillingPolicy{TrafficLimitType: ptr("weird")},
+	}}
+	if err := CreateGroup(&bad); !invalid.Is(err) {
+		t.Fatalf("invalid policy should be rejected, got %v", err)
+	}
+	if err := SetClientOverrides("whatever", []string{"nope"}); !invalid.Is(err) {
+		t.Fatalf("unknown override should be rejected, got %v", err)
+	}
+}
+
+func TestSetClientGroupByNameCreatesTopLevelGroup(t *testing.T) {
+	db := dbtest.Open(t, "client_groups")
+	createClient(t, db, "node-legacy")
+	if err := SetClientGroupByName("node-legacy", "  Tokyo "); err != nil {
+		t.Fatalf("set by name: %v", err)
+	}
+	c := loadClient(t, db, "node-legacy")
+	if c.GroupID == nil || c.Group != "Tokyo" {
+		t.Fatalf("client group = %v %q", c.GroupID, c.Group)
+	}
+	group, err := GetGroup(*c.GroupID)
+	if err != nil || group.Name != "Tokyo" || group.ParentID != nil {
+		t.Fatalf("group = %#v (%v)", group, err)
+	}
+	if err := SetClientGroupByName("node-legacy", ""); err != nil {
+		t.Fatalf("clear by name: %v", err)
+	}
+	if c := loadClient(t, db, "node-legacy"); c.GroupID != nil || c.Group != "" {
+		t.Fatalf("client should be ungrouped, got %v %q", c.GroupID, c.Group)
+	}
+}
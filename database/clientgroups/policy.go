@@ -0,0 +1,345 @@
+package clientgroups
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 分组策略以“物化”的方式生效：成员加入分组、分组策略变更时，把解析后的策略直接写入
+// Ping 任务 / 负载通知的 clients 列表、离线与流量报告通知表以及客户端计费字段。
+// 这些数据的读取方（调度器、通知、主题）因此无需感知分组。
+//
+// 列表类策略按差集增删：离开分组或分组移除某个任务时，成员会从对应任务中移除；
+// 标量类策略只在设置时写入，离开分组后保留最后一次下发的值。
+
+// errGroupCycle 表示分组的上级链路形成了环。
+var errGroupCycle = errors.New("client group hierarchy contains a cycle")
+
+// loadGroups 读取全部分组，以 ID 为键。
+func loadGroups(tx *gorm.DB) (map[uint]models.ClientGroup, error) {
+	var groups []models.ClientGroup
+	if err := tx.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	out := make(map[uint]models.ClientGroup, len(groups))
+	for _, g := range groups {
+		out[g.Id] = g
+	}
+	return out, nil
+}
+
+// chainOf 返回从根分组到 id 的链路。
+func chainOf(groups map[uint]models.ClientGroup, id uint) ([]models.ClientGroup, error) {
+	var chain []models.ClientGroup
+	seen := map[uint]struct{}{}
+	for cur := &id; cur != nil; {
+		g, ok := groups[*cur]
+		if !ok {
+			return nil, fmt.Errorf("client group %d not found", *cur)
+		}
+		if _, dup := seen[g.Id]; dup {
+			return nil, errGroupCycle
+		}
+		seen[g.Id] = struct{}{}
+		chain = append(chain, g)
+		cur = g.ParentID
+	}
+	slices.Reverse(chain)
+	return chain, nil
+}
+
+// descendantsOf 返回 id 及其所有下级分组的 ID。
+func descendantsOf(groups map[uint]models.ClientGroup, id uint) []uint {
+	children := map[uint][]uint{}
+	for _, g := range groups {
+		if g.ParentID != nil {
+			children[*g.ParentID] = append(children[*g.ParentID], g.Id)
+		}
+	}
+	out := []uint{id}
+	seen := map[uint]struct{}{id: {}}
+	for i := 0; i < len(out); i++ {
+		for _, child := range children[out[i]] {
+			if _, ok := seen[child]; ok {
+				continue
+			}
+			seen[child] = struct{}{}
+			out = append(out, child)
+		}
+	}
+	return out
+}
+
+// resolve 沿链路合并策略：列表取并集，标量由下级覆盖上级。
+func resolve(chain []models.ClientGroup) models.GroupPolicy {
+	var out models.GroupPolicy
+	for _, g := range chain {
+		p := g.Policy
+		out.PingTasks = unionIDs(out.PingTasks, p.PingTasks)
+		out.LoadNotifications = unionIDs(out.LoadNotifications, p.LoadNotifications)
+		if p.Offline != nil {
+			if out.Offline == nil {
+				out.Offline = &models.OfflineNotificationPolicy{}
+			}
+			override(&out.Offline.Enable, p.Offline.Enable)
+			override(&out.Offline.GracePeriod, p.Offline.GracePeriod)
+		}
+		if p.TrafficReport != nil {
+			if out.TrafficReport == nil {
+				out.TrafficReport = &models.TrafficReportPolicy{}
+			}
+			override(&out.TrafficReport.Enable, p.TrafficReport.Enable)
+			override(&out.TrafficReport.Daily, p.TrafficReport.Daily)
+			override(&out.TrafficReport.Weekly, p.TrafficReport.Weekly)
+			override(&out.TrafficReport.Monthly, p.TrafficReport.Monthly)
+		}
+		if p.Billing != nil {
+			if out.Billing == nil {
+				out.Billing = &models.BillingPolicy{}
+			}
+			override(&out.Billing.Price, p.Billing.Price)
+			override(&out.Billing.BillingCycle, p.Billing.BillingCycle)
+			override(&out.Billing.Currency, p.Billing.Currency)
+			override(&out.Billing.AutoRenewal, p.Billing.AutoRenewal)
+			override(&out.Billing.TrafficLimit, p.Billing.TrafficLimit)
+			override(&out.Billing.TrafficLimitType, p.Billing.TrafficLimitType)
+		}
+		override(&out.AgentProfile, p.AgentProfile)
+	}
+	return out
+}
+
+func override[T any](dst **T, src *T) {
+	if src != nil {
+		v := *src
+		*dst = &v
+	}
+}
+
+func unionIDs(a, b []uint) []uint {
+	for _, id := range b {
+		if !slices.Contains(a, id) {
+			a = append(a, id)
+		}
+	}
+	return a
+}
+
+// resolveGroup 返回分组的生效策略；id 为 nil 时返回空策略。
+func resolveGroup(groups map[uint]models.ClientGroup, id *uint) (models.GroupPolicy, error) {
+	if id == nil {
+		return models.GroupPolicy{}, nil
+	}
+	chain, err := chainOf(groups, *id)
+	if err != nil {
+		return models.GroupPolicy{}, err
+	}
+	return resolve(chain), nil
+}
+
+// applyResult 记录一次物化是否改动了需要重新加载调度的数据。
+type applyResult struct {
+	pingTasks         bool
+	loadNotifications bool
+	agentProfiles     []string // 配置发生变化、需要重新下发的客户端
+}
+
+func (r *applyResult) merge(o applyResult) {
+	r.pingTasks = r.pingTasks || o.pingTasks
+	r.loadNotifications = r.loadNotifications || o.loadNotifications
+	r.agentProfiles = append(r.agentProfiles, o.agentProfiles...)
+}
+
+// applyPolicy 把策略从 prev 切换到 next 的差异写入客户端，跳过客户端覆盖的策略项。
+func applyPolicy(tx *gorm.DB, client models.Client, prev, next models.GroupPolicy) (applyResult, error) {
+	var res applyResult
+	overridden := func(key string) bool { return slices.Contains(client.PolicyOverrides, key) }
+
+	if !overridden(models.PolicyPingTasks) {
+		changed, err := syncMembership[models.PingTask](tx, client.UUID, prev.PingTasks, next.PingTasks)
+		if err != nil {
+			return res, fmt.Errorf("apply ping tasks: %w", err)
+		}
+		res.pingTasks = changed
+	}
+	if !overridden(models.PolicyLoadNotifications) {
+		changed, err := syncMembership[models.LoadNotification](tx, client.UUID, prev.LoadNotifications, next.LoadNotifications)
+		if err != nil {
+			return res, fmt.Errorf("apply load notifications: %w", err)
+		}
+		res.loadNotifications = changed
+	}
+	if !overridden(models.PolicyOfflineNotification) && next.Offline != nil {
+		if err := applyOffline(tx, client.UUID, *next.Offline); err != nil {
+			return res, fmt.Errorf("apply offline notification: %w", err)
+		}
+	}
+	if !overridden(models.PolicyTrafficReport) && next.TrafficReport != nil {
+		if err := applyTrafficReport(tx, client.UUID, *next.TrafficReport); err != nil {
+			return res, fmt.Errorf("apply traffic report: %w", err)
+		}
+	}
+	if !overridden(models.PolicyBilling) && next.Billing != nil {
+		if err := applyBilling(tx, client.UUID, *next.Billing); err != nil {
+			return res, fmt.Errorf("apply billing: %w", err)
+		}
+	}
+	if !overridden(models.PolicyAgentProfile) && next.AgentProfile != nil &&
+		(client.AgentProfileID == nil || *client.AgentProfileID != *next.AgentProfile) {
+		if err := tx.Model(&models.Client{}).Where("uuid = ?", client.UUID).
+			Update("agent_profile_id", *next.AgentProfile).Error; err != nil {
+			return res, fmt.Errorf("apply agent profile: %w", err)
+		}
+		res.agentProfiles = append(res.agentProfiles, client.UUID)
+	}
+	return res, nil
+}
+
+// syncMembership 把 uuid 加入 next 中的规则，并从 prev 有而 next 没有的规则中移除。
+// 已删除的规则 ID 会被忽略。
+func syncMembership[T models.PingTask | models.LoadNotification](tx *gorm.DB, uuid string, prev, next []uint) (bool, error) {
+	ids := unionIDs(slices.Clone(prev), next)
+	if len(ids) == 0 {
+		return false, nil
+	}
+	var rows []T
+	if err := tx.Where("id IN ?", ids).Find(&rows).Error; err != nil {
+		return false, err
+	}
+	changed := false
+	for i := range rows {
+		id, clients := ruleClients(&rows[i])
+		want := slices.Contains(next, id)
+		has := slices.Contains(clients, uuid)
+		if want == has {
+			continue
+		}
+		updated := make(models.StringArray, 0, len(clients)+1)
+		for _, c := range clients {
+			if c != uuid {
+				updated = append(updated, c)
+			}
+		}
+		if want {
+			updated = append(updated, uuid)
+		}
+		if err := tx.Model(new(T)).Where("id = ?", id).Update("clients", updated).Error; err != nil {
+			return false, err
+		}
+		changed = true
+	}
+	return changed, nil
+}
+
+func ruleClients(row any) (uint, models.StringArray) {
+	switch r := row.(type) {
+	case *models.PingTask:
+		return r.Id, r.Clients
+	case *models.LoadNotification:
+		return r.Id, r.Clients
+	}
+	return 0, nil
+}
+
+func applyOffline(tx *gorm.DB, uuid string, p models.OfflineNotificationPolicy) error {
+	if p.Enable == nil && p.GracePeriod == nil {
+		return nil
+	}
+	row := models.OfflineNotification{Client: uuid, GracePeriod: 180}
+	if err := tx.Where("client = ?", uuid).Limit(1).Find(&row).Error; err != nil {
+		return err
+	}
+	if p.Enable != nil {
+		row.Enable = *p.Enable
+	}
+	if p.GracePeriod != nil {
+		row.GracePeriod = *p.GracePeriod
+	}
+	return tx.Model(&models.OfflineNotification{}).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "client"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enable", "grace_period"}),
+		}).
+		Select("client", "enable", "grace_period").
+		Create(&row).Error
+}
+
+func applyTrafficReport(tx *gorm.DB, uuid string, p models.TrafficReportPolicy) error {
+	if p.Enable == nil && p.Daily == nil && p.Weekly == nil && p.Monthly == nil {
+		return nil
+	}
+	row := models.TrafficReportNotification{Client: uuid}
+	if err := tx.Where("client = ?", uuid).Limit(1).Find(&row).Error; err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		dst *bool
+		src *bool
+	}{{&row.Enable, p.Enable}, {&row.Daily, p.Daily}, {&row.Weekly, p.Weekly}, {&row.Monthly, p.Monthly}} {
+		if f.src != nil {
+			*f.dst = *f.src
+		}
+	}
+	if row.Enable && !row.Daily && !row.Weekly && !row.Monthly {
+		return invalid.Errorf("at least one cadence must be selected when enabling traffic reports")
+	}
+	return tx.Model(&models.TrafficReportNotification{}).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "client"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enable", "daily", "weekly", "monthly"}),
+		}).
+		Select("client", "enable", "daily", "weekly", "monthly").
+		Create(&row).Error
+}
+
+func applyBilling(tx *gorm.DB, uuid string, p models.BillingPolicy) error {
+	updates := map[string]any{}
+	if p.Price != nil {
+		updates["price"] = *p.Price
+	}
+	if p.BillingCycle != nil {
+		updates["billing_cycle"] = *p.BillingCycle
+	}
+	if p.Currency != nil {
+		updates["currency"] = *p.Currency
+	}
+	if p.AutoRenewal != nil {
+		updates["auto_renewal"] = *p.AutoRenewal
+	}
+	if p.TrafficLimit != nil {
+		updates["traffic_limit"] = *p.TrafficLimit
+	}
+	if p.TrafficLimitType != nil {
+		updates["traffic_limit_type"] = *p.TrafficLimitType
+	}
+	if len(updates) == 0 {
+		return nil
+	}
+	return tx.Model(&models.Client{}).Where("uuid = ?", uuid).Updates(updates).Error
+}
+
+// ValidatePolicy 检查策略字段取值是否合法。
+func ValidatePolicy(p models.GroupPolicy) error {
+	if p.Offline != nil && p.Offline.GracePeriod != nil && *p.Offline.GracePeriod < 0 {
+		return invalid.Errorf("offline_notification.grace_period must not be negative")
+	}
+	if p.Billing != nil {
+		if p.Billing.TrafficLimit != nil && *p.Billing.TrafficLimit < 0 {
+			return invalid.Errorf("billing.traffic_limit must not be negative")
+		}
+		if p.Billing.TrafficLimitType != nil {
+			switch *p.Billing.TrafficLimitType {
+			case "sum", "max", "min", "up", "down":
+			default:
+				return invalid.Errorf("billing.traffic_limit_type must be one of sum, max, min, up, down")
+			}
+		}
+	}
+	return nil
+}
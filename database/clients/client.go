@@ -13,6 +13,7 @@ import (
 	"github.com/komari-monitor/komari/utils"
 
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
 func DeleteClient(clientUuid string) error {
@@ -21,7 +22,23 @@ func DeleteClient(clientUuid string) error {
 	if err != nil {
 		return err
 	}
-	return nil
+	if err := db.Where("client_uuid = ?", clientUuid).Delete(&models.ClientAgentConfig{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("client_uuid = ?", clientUuid).Delete(&models.AgentOutboxEvent{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("client_uuid = ?", clientUuid).Delete(&models.ProcessSnapshot{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("client_uuid = ?", clientUuid).Delete(&models.AgentEvent{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("client_uuid = ?", clientUuid).Delete(&models.AgentLogLine{}).Error; err != nil {
+		return err
+	}
+	// 机器指纹不再对应任何客户端，重新注册时按新机器处理。
+	return db.Where("client_uuid = ?", clientUuid).Delete(&models.ClientEnrollment{}).Error
 }
 
 func SaveClientInfo(update map[string]interface{}) error {
@@ -129,7 +146,7 @@ func CreateClient() (clientUUID, token string, err error) {
 
 	client := models.Client{
 		UUID:      clientUUID,
-		Token:     token,
+		Token:     models.HashClientToken(token),
 		Name:      "client_" + clientUUID[0:8],
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
@@ -154,7 +171,7 @@ func CreateClientWithName(name string) (clientUUID, token string, err error) {
 	clientUUID = uuid.New().String()
 	client := models.Client{
 		UUID:      clientUUID,
-		Token:     token,
+		Token:     models.HashClientToken(token),
 		Name:      name,
 		CreatedAt: time.Now().UTC(),
 		UpdatedAt: time.Now().UTC(),
@@ -191,16 +208,6 @@ func GetClientByUUID(uuid string) (client models.Client, err error) {
 	return client, nil
 }
 
-func GetClientTokenByUUID(uuid string) (token string, err error) {
-	db := dbcore.GetDBInstance()
-	var client models.Client
-	err = db.Where("uuid = ?", uuid).First(&client).Error
-	if err != nil {
-		return "", err
-	}
-	return client.Token, nil
-}
-
 func GetAllClientBasicInfo() (clients []models.Client, err error) {
 	db := dbcore.GetDBInstance()
 	err = db.Find(&clients).Error
@@ -217,6 +224,11 @@ func SaveClient(updates map[string]interface{}) error {
 		return fmt.Errorf("invalid client UUID")
 	}
 
+	// 令牌只能通过 RotateToken 修改，Agent 配置只能通过 agentprofiles.AssignClient 修改（需要下发）
+	for _, key := range []string{"token", "previous_token", "previous_token_expires_at", "token_rotated_at", "agent_profile_id"} {
+		delete(updates, key)
+	}
+
 	// 确保更新的字段不为空
 	if len(updates) == 0 {
 		return fmt.Errorf("no fields to update")
@@ -260,3 +272,20 @@ func SaveClient(updates map[string]interface{}) error {
 	}
 	return nil
 }
+
+// GetClientUUIDByName 按名称查找客户端；名称不唯一时返回错误。
+func GetClientUUIDByName(name string) (string, error) {
+	db := dbcore.GetDBInstance()
+	var found []models.Client
+	if err := db.Select("uuid").Where("name = ?", name).Limit(2).Find(&found).Error; err != nil {
+		return "", err
+	}
+	switch len(found) {
+	case 0:
+		return "", gorm.ErrRecordNotFound
+	case 1:
+		return found[0].UUID, nil
+	default:
+		return "", fmt.Errorf("client name %q is ambiguous", name)
+	}
+}
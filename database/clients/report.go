@@ -3,16 +3,26 @@ package clients
 import (
 	"fmt"
 	"math"
+	"time"
 
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	v1 "github.com/komari-monitor/komari/protocol/v1"
+	"gorm.io/gorm"
 )
 
+// GetClientUUIDByToken 按令牌明文查找客户端。轮换后的旧令牌在重叠期内仍然有效。
 func GetClientUUIDByToken(token string) (clientUUID string, err error) {
+	if token == "" {
+		return "", gorm.ErrRecordNotFound
+	}
 	db := dbcore.GetDBInstance()
+	hashed := models.HashClientToken(token)
 	var client models.Client
-	err = db.Where("token = ?", token).First(&client).Error
+	err = db.Select("uuid").
+		Where("token = ?", hashed).
+		Or("previous_token = ? AND previous_token_expires_at > ?", hashed, time.Now().UTC()).
+		First(&client).Error
 	if err != nil {
 		return "", err
 	}
@@ -100,5 +110,69 @@ func ReportVerify(report v1.Report) error {
 	if report.Connections.UDP < 0 {
 		return fmt.Errorf("Connections.UDP must be non-negative: %d", report.Connections.UDP)
 	}
+	return verifyDeviceReports(report, checkInt64, checkFloat64)
+}
+
+// maxDeviceReports 限制单次上报中每类设备明细的条数。
+const maxDeviceReports = 256
+
+// verifyDeviceReports 校验可选的挂载点、网卡、磁盘 IO 与容器明细。
+func verifyDeviceReports(report v1.Report, checkInt64 func(string, int64) error, checkFloat64 func(string, float64) error) error {
+	if len(report.Mounts) > maxDeviceReports || len(report.Interfaces) > maxDeviceReports || len(report.DiskIO) > maxDeviceReports || len(report.Containers) > maxDeviceReports {
+		return fmt.Errorf("too many device entries, at most %d per kind", maxDeviceReports)
+	}
+	for i, mount := range report.Mounts {
+		for name, val := range map[string]int64{
+			"Total": mount.Total, "Used": mount.Used, "InodesTotal": mount.InodesTotal, "InodesUsed": mount.InodesUsed,
+		} {
+			if err := checkInt64(fmt.Sprintf("Mounts[%d].%s", i, name), val); err != nil {
+				return err
+			}
+		}
+	}
+	for i, iface := range report.Interfaces {
+		for name, val := range map[string]int64{
+			"RxRate": iface.RxRate, "TxRate": iface.TxRate, "RxBytes": iface.RxBytes, "TxBytes": iface.TxBytes,
+			"RxErrors": iface.RxErrors, "TxErrors": iface.TxErrors, "RxDrops": iface.RxDrops, "TxDrops": iface.TxDrops,
+		} {
+			if err := checkInt64(fmt.Sprintf("Interfaces[%d].%s", i, name), val); err != nil {
+				return err
+			}
+		}
+	}
+	for i, disk := range report.DiskIO {
+		if err := checkInt64(fmt.Sprintf("DiskIO[%d].ReadRate", i), disk.ReadRate); err != nil {
+			return err
+		}
+		if err := checkInt64(fmt.Sprintf("DiskIO[%d].WriteRate", i), disk.WriteRate); err != nil {
+			return err
+		}
+		for name, val := range map[string]float64{
+			"ReadIOPS": disk.ReadIOPS, "WriteIOPS": disk.WriteIOPS, "Utilization": disk.Utilization,
+		} {
+			if val < 0 {
+				return fmt.Errorf("DiskIO[%d].%s must be non-negative, got %g", i, name, val)
+			}
+			if err := checkFloat64(fmt.Sprintf("DiskIO[%d].%s", i, name), val); err != nil {
+				return err
+			}
+		}
+	}
+	for i, container := range report.Containers {
+		for name, val := range map[string]int64{
+			"MemoryUsed": container.MemoryUsed, "MemoryLimit": container.MemoryLimit, "NetRx": container.NetRx, "NetTx": container.NetTx,
+			"RestartCount": int64(container.RestartCount),
+		} {
+			if err := checkInt64(fmt.Sprintf("Containers[%d].%s", i, name), val); err != nil {
+				return err
+			}
+		}
+		if container.CPU < 0 {
+			return fmt.Errorf("Containers[%d].CPU must be non-negative, got %g", i, container.CPU)
+		}
+		if err := checkFloat64(fmt.Sprintf("Containers[%d].CPU", i), container.CPU); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -0,0 +1,60 @@
+package clients
+
+import (
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/utils"
+	"gorm.io/gorm"
+)
+
+// RotateToken 为客户端签发新令牌并返回明文。overlap 大于 0 时旧令牌在此期间内仍然有效，
+// 返回旧令牌的失效时间；overlap 为 0 时旧令牌立即失效（吊销），返回 nil。
+// 在上一次轮换的重叠期内再次轮换时，更早的令牌随之失效。
+func RotateToken(uuid string, overlap time.Duration) (token string, previousExpiresAt *time.Time, err error) {
+	db := dbcore.GetDBInstance()
+	err = db.Transaction(func(tx *gorm.DB) error {
+		var client models.Client
+		if err := tx.Select("uuid", "token").Where("uuid = ?", uuid).First(&client).Error; err != nil {
+			return err
+		}
+		now := time.Now().UTC()
+		token = utils.GenerateToken()
+		updates := map[string]any{
+			"token":                     models.HashClientToken(token),
+			"previous_token":            "",
+			"previous_token_expires_at": nil,
+			"token_rotated_at":          now,
+			"updated_at":                now,
+		}
+		if overlap > 0 {
+			expires := now.Add(overlap)
+			previousExpiresAt = &expires
+			updates["previous_token"] = client.Token
+			updates["previous_token_expires_at"] = expires
+		}
+		return tx.Model(&models.Client{}).Where("uuid = ?", uuid).Updates(updates).Error
+	})
+	if err != nil {
+		return "", nil, err
+	}
+	return token, previousExpiresAt, nil
+}
+
+// RevokePreviousToken 提前结束重叠期，使轮换前的旧令牌立即失效。
+func RevokePreviousToken(uuid string) error {
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.Client{}).Where("uuid = ?", uuid).Updates(map[string]any{
+		"previous_token":            "",
+		"previous_token_expires_at": nil,
+		"updated_at":                time.Now().UTC(),
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
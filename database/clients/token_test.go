@@ -0,0 +1,53 @@
+package clients
+
+import (
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+)
+
+func TestRotateTokenOverlapAndRevocation(t *testing.T) {
+	dbtest.Open(t, "client_tokens")
+
+	uuid, original, err := CreateClientWithName("rotating")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	stored, err := GetClientByUUID(uuid)
+	if err != nil || stored.Token == original {
+		t.Fatalf("token should be stored hashed, got %q (%v)", stored.Token, err)
+	}
+	authenticates := func(token string) bool {
+		got, err := GetClientUUIDByToken(token)
+		return err == nil && got == uuid
+	}
+	if !authenticates(original) {
+		t.Fatal("original token should authenticate")
+	}
+
+	rotated, expires, err := RotateToken(uuid, time.Hour)
+	if err != nil || expires == nil {
+		t.Fatalf("rotate: %v (expires %v)", err, expires)
+	}
+	if !authenticates(original) || !authenticates(rotated) {
+		t.Fatal("both tokens should authenticate during the overlap")
+	}
+	if err := RevokePreviousToken(uuid); err != nil {
+		t.Fatalf("revoke previous: %v", err)
+	}
+	if authenticates(original) || !authenticates(rotated) {
+		t.Fatal("only the new token should authenticate after revoking the previous one")
+	}
+
+	revoked, expires, err := RotateToken(uuid, 0)
+	if err != nil || expires != nil {
+		t.Fatalf("rotate without overlap: %v (expires %v)", err, expires)
+	}
+	if authenticates(rotated) || !authenticates(revoked) {
+		t.Fatal("rotating without overlap should revoke the old token immediately")
+	}
+	if authenticates("") {
+		t.Fatal("an empty token must never authenticate")
+	}
+}
@@ -0,0 +1,174 @@
+// Package clientselector 在客户端列表上求值选择器表达式（见 pkg/selector），
+// 供 Ping 任务调度、负载通知与各类展示接口在使用时动态计算目标客户端。
+package clientselector
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/selector"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+// cacheTTL 内复用同一份客户端快照，避免每次调度都查询数据库。
+const cacheTTL = 5 * time.Second
+
+// Target 是参与选择器求值的客户端。
+type Target struct {
+	UUID   string
+	Name   string
+	fields map[string][]string
+}
+
+// Values 实现 selector.Target。group 包含所属分组及其全部上级分组。
+func (t Target) Values(field string) []string {
+	return t.fields[field]
+}
+
+var (
+	mu       sync.Mutex
+	cached   []Target
+	cachedAt time.Time
+)
+
+// Invalidate 丢弃客户端快照，下一次求值时重新加载。
+func Invalidate() {
+	mu.Lock()
+	cached = nil
+	mu.Unlock()
+}
+
+// Targets 返回当前全部客户端的求值快照。
+func Targets() ([]Target, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if cached != nil && time.Since(cachedAt) < cacheTTL {
+		return cached, nil
+	}
+	db := dbcore.GetDBInstance()
+	var clients []models.Client
+	if err := db.Order("weight ASC").Order("uuid ASC").Find(&clients).Error; err != nil {
+		return nil, err
+	}
+	var groups []models.ClientGroup
+	if err := db.Find(&groups).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.ClientGroup, len(groups))
+	for _, g := range groups {
+		byID[g.Id] = g
+	}
+	targets := make([]Target, 0, len(clients))
+	for _, c := range clients {
+		targets = append(targets, newTarget(c, groupNames(byID, c)))
+	}
+	cached, cachedAt = targets, time.Now()
+	return targets, nil
+}
+
+// groupNames 返回客户端所属分组及其上级分组的名称；未关联分组实体时使用 group 字段。
+func groupNames(groups map[uint]models.ClientGroup, c models.Client) []string {
+	var names []string
+	seen := map[uint]struct{}{}
+	for id := c.GroupID; id != nil; {
+		g, ok := groups[*id]
+		if !ok {
+			break
+		}
+		if _, dup := seen[g.Id]; dup {
+			break
+		}
+		seen[g.Id] = struct{}{}
+		names = append(names, g.Name)
+		id = g.ParentID
+	}
+	if len(names) == 0 && strings.TrimSpace(c.Group) != "" {
+		names = []string{strings.TrimSpace(c.Group)}
+	}
+	return names
+}
+
+func newTarget(c models.Client, groups []string) Target {
+	var tags []string
+	for _, tag := range strings.Split(c.Tags, ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	single := func(v string) []string {
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	}
+	return Target{
+		UUID: c.UUID,
+		Name: c.Name,
+		fields: map[string][]string{
+			"uuid":           single(c.UUID),
+			"name":           single(c.Name),
+			"group":          groups,
+			"tag":            tags,
+			"region":         single(c.Region),
+			"os":             single(c.OS),
+			"arch":           single(c.Arch),
+			"virtualization": single(c.Virtualization),
+			"hidden":         {strconv.FormatBool(c.Hidden)},
+		},
+	}
+}
+
+// Match 返回满足表达式的客户端。
+func Match(expr string) ([]Target, error) {
+	s, err := selector.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	targets, err := Targets()
+	if err != nil {
+		return nil, err
+	}
+	var out []Target
+	for _, t := range targets {
+		if s.Match(t) {
+			out = append(out, t)
+		}
+	}
+	return out, nil
+}
+
+// Resolve 返回静态列表与选择器匹配结果的并集（静态列表在前）。
+// 选择器无法求值时记录日志并仅返回静态列表。
+func Resolve(static []string, expr string) []string {
+	if strings.TrimSpace(expr) == "" {
+		return static
+	}
+	matched, err := Match(expr)
+	if err != nil {
+		logger.Warnf("clientselector", "Failed to evaluate selector %q: %v", expr, err)
+		return static
+	}
+	out := append(make([]string, 0, len(static)+len(matched)), static...)
+	for _, t := range matched {
+		if !slices.Contains(out, t.UUID) {
+			out = append(out, t.UUID)
+		}
+	}
+	return out
+}
+
+// ExpandPingTasks 把任务的 Clients 替换为静态列表与选择器匹配结果的并集，
+// 使 AppliesToClient 等只读逻辑无需感知选择器。不要把展开后的任务写回数据库。
+func ExpandPingTasks(tasks []models.PingTask) []models.PingTask {
+	out := make([]models.PingTask, len(tasks))
+	for i, task := range tasks {
+		task.Clients = Resolve(task.Clients, task.Selector)
+		out[i] = task
+	}
+	return out
+}
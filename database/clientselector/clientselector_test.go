@@ -0,0 +1,58 @@
+package clientselector
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func TestResolveMatchesGroupAncestorsAndTags(t *testing.T) {
+	db := dbtest.Open(t, "client_selector")
+
+	parent := models.ClientGroup{Name: "prod"}
+	if err := db.Create(&parent).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	child := models.ClientGroup{Name: "prod-hk", ParentID: &parent.Id}
+	if err := db.Create(&child).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	clients := []models.Client{
+		{UUID: "a", Token: "ta", Name: "db-1", GroupID: &child.Id, Tags: "db;ssd", Region: "HK"},
+		{UUID: "b", Token: "tb", Name: "web-1", GroupID: &parent.Id, Tags: "web", Region: "CN"},
+		{UUID: "c", Token: "tc", Name: "legacy", Group: "staging", Tags: "db"},
+	}
+	for _, c := range clients {
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("create client: %v", err)
+		}
+	}
+	Invalidate()
+
+	cases := []struct {
+		expr string
+		want []string
+	}{
+		{`group = "prod"`, []string{"a", "b"}},
+		{`group = prod and tag in (db, cache) and region != "CN"`, []string{"a"}},
+		{`group = staging`, []string{"c"}},
+		{`name =~ "^db-" or group = staging`, []string{"a", "c"}},
+		{`not tag = db`, []string{"b"}},
+	}
+	for _, tc := range cases {
+		got := Resolve(nil, tc.expr)
+		slices.Sort(got)
+		if !slices.Equal(got, tc.want) {
+			t.Errorf("Resolve(%q) = %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+
+	if got := Resolve([]string{"b"}, `tag = db`); !slices.Equal(got, []string{"b", "a", "c"}) {
+		t.Errorf("static clients should come first, got %v", got)
+	}
+	if got := Resolve([]string{"b"}, `bogus = 1`); !slices.Equal(got, []string{"b"}) {
+		t.Errorf("invalid selector should fall back to static clients, got %v", got)
+	}
+}
@@ -0,0 +1,52 @@
+package dbcore
+
+import (
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/gorm"
+)
+
+// backfillClientGroups 为仍只有分组名称（旧版自由文本 group 字段）的客户端建立分组实体，
+// 并回填 group_id。同名分组已存在时直接关联。每次启动执行，已关联的客户端不受影响。
+func backfillClientGroups(db *gorm.DB) error {
+	var pending []models.Client
+	if err := db.Select("uuid", "group").
+		Where("group_id IS NULL").Not(map[string]any{"group": ""}).
+		Find(&pending).Error; err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		ids := map[string]uint{}
+		for _, client := range pending {
+			name := strings.TrimSpace(client.Group)
+			if name == "" {
+				continue
+			}
+			id, ok := ids[name]
+			if !ok {
+				var group models.ClientGroup
+				err := tx.Where("name = ?", name).First(&group).Error
+				if err == gorm.ErrRecordNotFound {
+					now := time.Now().UTC()
+					group = models.ClientGroup{Name: name, CreatedAt: now, UpdatedAt: now}
+					err = tx.Create(&group).Error
+				}
+				if err != nil {
+					return err
+				}
+				id = group.Id
+				ids[name] = id
+			}
+			if err := tx.Model(&models.Client{}).Where("uuid = ?", client.UUID).
+				Updates(map[string]any{"group_id": id, "group": name}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,57 @@
+package dbcore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func TestBackfillClientGroupsFromLegacyNames(t *testing.T) {
+	db, closeDB, err := openSQLiteFile(filepath.Join(t.TempDir(), "groups.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer closeDB()
+	if err := db.AutoMigrate(mainModels()...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.Create(&models.ClientGroup{Name: "JP"}).Error; err != nil {
+		t.Fatalf("seed group: %v", err)
+	}
+	for _, c := range []models.Client{
+		{UUID: "a", Token: "ta", Group: "HK"},
+		{UUID: "b", Token: "tb", Group: "HK"},
+		{UUID: "c", Token: "tc", Group: "JP"},
+		{UUID: "d", Token: "td"},
+	} {
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("seed client: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := backfillClientGroups(db); err != nil {
+			t.Fatalf("backfill #%d: %v", i, err)
+		}
+	}
+
+	var groups []models.ClientGroup
+	if err := db.Order("id").Find(&groups).Error; err != nil {
+		t.Fatalf("list groups: %v", err)
+	}
+	if len(groups) != 2 || groups[0].Name != "JP" || groups[1].Name != "HK" {
+		t.Fatalf("groups = %#v, want JP and HK", groups)
+	}
+	want := map[string]*uint{"a": &groups[1].Id, "b": &groups[1].Id, "c": &groups[0].Id, "d": nil}
+	var clients []models.Client
+	if err := db.Find(&clients).Error; err != nil {
+		t.Fatalf("list clients: %v", err)
+	}
+	for _, c := range clients {
+		w := want[c.UUID]
+		if (w == nil) != (c.GroupID == nil) || (w != nil && *w != *c.GroupID) {
+			t.Fatalf("client %s group_id = %v, want %v", c.UUID, c.GroupID, w)
+		}
+	}
+}
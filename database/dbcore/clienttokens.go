@@ -0,0 +1,29 @@
+package dbcore
+
+import (
+	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/gorm"
+)
+
+// hashClientTokens 把仍以明文保存的客户端令牌替换为哈希。每次启动执行，
+// 已哈希的令牌不受影响；Agent 继续使用原来的明文令牌即可通过验证。
+func hashClientTokens(db *gorm.DB) error {
+	var pending []models.Client
+	if err := db.Select("uuid", "token").
+		Where("token NOT LIKE ?", models.ClientTokenHashPrefix+"%").
+		Find(&pending).Error; err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, client := range pending {
+			if err := tx.Model(&models.Client{}).Where("uuid = ?", client.UUID).
+				UpdateColumn("token", models.HashClientToken(client.Token)).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
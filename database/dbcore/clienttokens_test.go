@@ -0,0 +1,45 @@
+package dbcore
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func TestHashClientTokensIsIdempotent(t *testing.T) {
+	db, closeDB, err := openSQLiteFile(filepath.Join(t.TempDir(), "tokens.db"))
+	if err != nil {
+		t.Fatalf("open: %v", err)
+	}
+	defer closeDB()
+	if err := db.AutoMigrate(mainModels()...); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	hashed := models.HashClientToken("already")
+	for _, c := range []models.Client{
+		{UUID: "a", Token: "plain-a"},
+		{UUID: "b", Token: hashed},
+	} {
+		if err := db.Create(&c).Error; err != nil {
+			t.Fatalf("seed client: %v", err)
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := hashClientTokens(db); err != nil {
+			t.Fatalf("hash #%d: %v", i, err)
+		}
+	}
+
+	want := map[string]string{"a": models.HashClientToken("plain-a"), "b": hashed}
+	var clients []models.Client
+	if err := db.Find(&clients).Error; err != nil {
+		t.Fatalf("load clients: %v", err)
+	}
+	for _, c := range clients {
+		if c.Token != want[c.UUID] {
+			t.Errorf("client %s token = %q, want %q", c.UUID, c.Token, want[c.UUID])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package dbcore
 
 import (
 	"archive/zip"
+	"context"
 	"fmt"
 	"io"
 	"os"
@@ -20,8 +21,9 @@ import (
 	"gorm.io/gorm"
 )
 
-// zipDirectoryExcluding 将 srcDir 打包为 dstZip，exclude 是绝对路径集合需要排除
-func zipDirectoryExcluding(srcDir, dstZip string, exclude map[string]struct{}) error {
+// zipDirectoryExcluding 将 srcDir 打包为 dstZip，exclude 是绝对路径集合需要排除；
+// extra 为额外写入的文件（zip 内名称 -> 本地路径），用于附带外部主库的 SQLite 导出。
+func zipDirectoryExcluding(srcDir, dstZip string, exclude map[string]struct{}, extra map[string]string) error {
 	// 规范化排除路径为绝对路径
 	normExclude := make(map[string]struct{}, len(exclude))
 	for p := range exclude {
@@ -86,6 +88,22 @@ func zipDirectoryExcluding(srcDir, dstZip string, exclude map[string]struct{}) e
 	if walkErr != nil {
 		return walkErr
 	}
+	for zipName, path := range extra {
+		fh, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		w, err := zw.Create(zipName)
+		if err != nil {
+			fh.Close()
+			return err
+		}
+		_, err = io.Copy(w, fh)
+		fh.Close()
+		if err != nil {
+			return err
+		}
+	}
 	return zw.Close()
 }
 
@@ -243,8 +261,23 @@ func backupOnVersionUpgrade() {
 	// 需要备份（升级或从旧稳定版首次带版本标记启动）。
 	// 先做一次 WAL checkpoint，确保 komari.db 主文件包含最新数据，
 	// 避免备份出的库缺少仍留在 -wal 中的写入。
-	if instance != nil {
+	// MySQL/PostgreSQL 主库不在 ./data 中，导出为 SQLite 一并打包。
+	extra := map[string]string{}
+	if instance != nil && flags.IsSQLite() {
 		instance.Exec("PRAGMA wal_checkpoint(TRUNCATE);")
+	} else if instance != nil {
+		tempDir, err := os.MkdirTemp("", "komari-upgrade-backup-*")
+		if err != nil {
+			logger.Errorf("dbcore", "[upgrade-backup] failed to create temp dir: %v", err)
+			return
+		}
+		defer os.RemoveAll(tempDir)
+		exported := filepath.Join(tempDir, "komari.db")
+		if _, err := exportSQLite(context.Background(), instance, exported); err != nil {
+			logger.Errorf("dbcore", "[upgrade-backup] failed to export %s database: %v", flags.DatabaseType, err)
+			return
+		}
+		extra["komari.db"] = exported
 	}
 
 	backupDir := filepath.Join(".", "data", "backup")
@@ -255,7 +288,7 @@ func backupOnVersionUpgrade() {
 	tsName := time.Now().UTC().Format("20060102-150405")
 	bakPath := filepath.Join(backupDir, fmt.Sprintf("upgrade-%s.zip", tsName))
 	backupZipPath := filepath.Join(".", "data", "backup.zip")
-	if zipErr := zipDirectoryExcluding("./data", bakPath, map[string]struct{}{backupZipPath: {}, backupDir: {}}); zipErr != nil {
+	if zipErr := zipDirectoryExcluding("./data", bakPath, map[string]struct{}{backupZipPath: {}, backupDir: {}}, extra); zipErr != nil {
 		logger.Errorf("dbcore", "[upgrade-backup] failed to backup ./data before upgrade (from %q to %q): %v", prevVersion, versionID, zipErr)
 		return
 	}
@@ -340,6 +373,7 @@ func Close() error {
 
 func doInitialize() error {
 	var err error
+	restoredFromBackup := false
 
 	// 在数据库初始化前执行：如果存在 ./data/backup.zip，则进行恢复逻辑
 	func() {
@@ -352,7 +386,7 @@ func doInitialize() error {
 			} else {
 				tsName := time.Now().UTC().Format("20060102-150405")
 				bakPath := filepath.Join(backupDir, fmt.Sprintf("pre-restore-%s.zip", tsName))
-				if zipErr := zipDirectoryExcluding("./data", bakPath, map[string]struct{}{backupZipPath: {}, backupDir: {}}); zipErr != nil {
+				if zipErr := zipDirectoryExcluding("./data", bakPath, map[string]struct{}{backupZipPath: {}, backupDir: {}}, nil); zipErr != nil {
 					logger.Errorf("dbcore", "[restore] failed to zip current data: %v", zipErr)
 				} else {
 					logger.Infof("dbcore", "[restore] current data zipped to %s", bakPath)
@@ -369,6 +403,7 @@ func doInitialize() error {
 				logger.Errorf("dbcore", "[restore] failed to unzip backup into data: %v", unzipErr)
 			} else {
 				logger.Infof("dbcore", "[restore] backup.zip extracted to ./data")
+				restoredFromBackup = true
 			}
 
 			// 7. 删除 ./data/backup.zip
@@ -389,7 +424,7 @@ func doInitialize() error {
 	// 记录“打开数据库之前”komari.db 是否已存在，用于区分全新安装与旧版升级。
 	// 必须在（可能的）恢复逻辑之后、gorm.Open 之前采集：恢复会解压出旧库，
 	// gorm.Open 会创建空库。
-	if _, statErr := os.Stat(resolveDatabaseFile()); statErr == nil {
+	if _, statErr := os.Stat(resolveDatabaseFile()); statErr == nil && flags.IsSQLite() {
 		dbFileExistedAtStartup = true
 	}
 
@@ -424,6 +459,13 @@ func doInitialize() error {
 		if err := instance.Exec("PRAGMA wal_checkpoint(TRUNCATE)").Error; err != nil {
 			logger.Errorf("dbcore", "Failed to checkpoint SQLite WAL at startup: %v", err)
 		}
+	case flags.DatabaseTypeMySQL, flags.DatabaseTypePostgreSQL:
+		instance, err = openExternalDatabase(logConfig)
+		if err != nil {
+			return err
+		}
+		// 外部库没有本地文件，以 users 表是否存在区分全新安装与升级。
+		dbFileExistedAtStartup = instance.Migrator().HasTable(&models.User{})
 	default:
 		return fmt.Errorf("unsupported database type: %s (supported: %s)", flags.DatabaseType, flags.SupportedDatabaseTypes())
 	}
@@ -458,6 +500,25 @@ func doInitialize() error {
 		&models.MessageSenderProvider{},
 		&models.ThemeConfiguration{},
 		&models.PluginConfiguration{},
+		&models.MetricSink{},
+		&models.BackupDestination{},
+		&models.BackupUpload{},
+		&models.FederationChild{},
+		&models.ClientGroup{},
+		&models.EnrollmentToken{},
+		&models.ClientEnrollment{},
+		&models.AgentRelease{},
+		&models.AgentRollout{},
+		&models.AgentRolloutTarget{},
+		&models.AgentProfile{},
+		&models.ClientAgentConfig{},
+		&models.AgentOutboxEvent{},
+		&models.ProcessSnapshot{},
+		&models.ServiceMonitor{},
+		&models.AgentEvent{},
+		&models.AgentEventRule{},
+		&models.AgentLogLine{},
+		&models.LogAlertRule{},
 	)
 	if err != nil {
 		return fmt.Errorf("failed to create tables: %w", err)
@@ -473,6 +534,26 @@ func doInitialize() error {
 	); err != nil {
 		logger.Errorf("dbcore", "Failed to create Task and TaskResult table, it may already exist: %v", err)
 	}
+	// 外部主库从备份恢复：备份中的 komari.db 是 SQLite 导出，覆盖导入后删除。
+	// 需在下面的数据修正之前导入，旧备份中的分组与明文令牌才会被一并处理。
+	if restoredFromBackup && !flags.IsSQLite() {
+		restoredFile := resolveDatabaseFile()
+		if _, statErr := os.Stat(restoredFile); statErr == nil {
+			if _, importErr := importSQLiteFile(context.Background(), instance, restoredFile, true); importErr != nil {
+				logger.Errorf("dbcore", "[restore] failed to import %s into %s database: %v", restoredFile, flags.DatabaseType, importErr)
+			} else {
+				logger.Infof("dbcore", "[restore] %s imported into %s database", restoredFile, flags.DatabaseType)
+				_ = os.Remove(restoredFile)
+			}
+		}
+	}
+
+	if err := backfillClientGroups(instance); err != nil {
+		logger.Errorf("dbcore", "Failed to create client groups from legacy group names: %v", err)
+	}
+	if err := hashClientTokens(instance); err != nil {
+		logger.Errorf("dbcore", "Failed to hash plaintext client tokens: %v", err)
+	}
 
 	return nil
 }
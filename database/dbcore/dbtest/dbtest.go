@@ -0,0 +1,21 @@
+// Package dbtest 为测试准备内存 SQLite 主库。
+package dbtest
+
+import (
+	"testing"
+
+	"github.com/komari-monitor/komari/cmd/flags"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"gorm.io/gorm"
+)
+
+// Open 切换到临时工作目录，打开名为 name 的内存 SQLite 主库并返回实例。
+// dbcore 的实例是进程级单例，同一测试二进制中只有第一次调用的 name 生效，
+// 因此测试只应统计自己创建的数据。
+func Open(t testing.TB, name string) *gorm.DB {
+	t.Helper()
+	t.Chdir(t.TempDir())
+	flags.DatabaseType = flags.DatabaseTypeSQLite
+	flags.DatabaseFile = "file:" + name + "?mode=memory&cache=shared"
+	return dbcore.GetDBInstance()
+}
@@ -0,0 +1,146 @@
+package dbcore
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	mysqldriver "github.com/go-sql-driver/mysql"
+	"github.com/komari-monitor/komari/cmd/flags"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/internal/config"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/schema"
+)
+
+const (
+	externalMaxOpenConns    = 25
+	externalMaxIdleConns    = 5
+	externalConnMaxLifetime = 30 * time.Minute
+)
+
+// mainModels 是主库中的全部业务表，顺序满足外键依赖（clients 先于通知/任务结果），
+// 供 AutoMigrate 之外的整库复制（SQLite 导入、备份导出）使用。
+func mainModels() []any {
+	return []any{
+		&config.ConfigItem{},
+		&models.User{},
+		&models.Session{},
+		&models.ClientGroup{},
+		&models.Client{},
+		&models.Log{},
+		&models.Clipboard{},
+		&models.LoadNotification{},
+		&models.OfflineNotification{},
+		&models.TrafficReportNotification{},
+		&models.PingTask{},
+		&models.OidcProvider{},
+		&models.MessageSenderProvider{},
+		&models.ThemeConfiguration{},
+		&models.PluginConfiguration{},
+		&models.MetricSink{},
+		&models.BackupDestination{},
+		&models.BackupUpload{},
+		&models.FederationChild{},
+		&models.EnrollmentToken{},
+		&models.ClientEnrollment{},
+		&models.AgentRelease{},
+		&models.AgentRollout{},
+		&models.AgentRolloutTarget{},
+		&models.AgentProfile{},
+		&models.ClientAgentConfig{},
+		&models.AgentOutboxEvent{},
+		&models.ProcessSnapshot{},
+		&models.ServiceMonitor{},
+		&models.AgentEvent{},
+		&models.AgentEventRule{},
+		&models.AgentLogLine{},
+		&models.LogAlertRule{},
+		&models.Task{},
+		&models.TaskResult{},
+	}
+}
+
+// dialectColumnTypes 把模型 tag 中沿用 SQLite/MySQL 写法的列类型映射为目标方言可用的类型。
+// 只改写解析后缓存的 schema，模型定义保持不变，已有 SQLite 库也不会触发列变更。
+var dialectColumnTypes = map[string]map[string]string{
+	// PostgreSQL 没有 longtext；text 本身不限长度。
+	"postgres": {"longtext": "text"},
+	// MySQL TIMESTAMP 只能表示到 2038 年，到期时间等字段需要 DATETIME；
+	// TEXT 仅 64KB，任务输出、主题配置等改用 LONGTEXT。
+	"mysql": {"timestamp": "datetime(3)", "text": "longtext"},
+}
+
+// openExternalDatabase 按 flags.DatabaseType 打开 MySQL/PostgreSQL 主库。
+func openExternalDatabase(logConfig *gorm.Config) (*gorm.DB, error) {
+	dsn := strings.TrimSpace(flags.DatabaseDSN)
+	if dsn == "" {
+		return nil, fmt.Errorf("--db-dsn (or KOMARI_DB_DSN) is required for database type %s", flags.DatabaseType)
+	}
+
+	var dialector gorm.Dialector
+	switch flags.DatabaseType {
+	case flags.DatabaseTypeMySQL:
+		normalized, err := normalizeMySQLDSN(dsn)
+		if err != nil {
+			return nil, err
+		}
+		dialector = mysql.Open(normalized)
+	case flags.DatabaseTypePostgreSQL:
+		dialector = postgres.Open(dsn)
+	default:
+		return nil, fmt.Errorf("unsupported database type: %s (supported: %s)", flags.DatabaseType, flags.SupportedDatabaseTypes())
+	}
+
+	db, err := gorm.Open(dialector, logConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s database: %w", flags.DatabaseType, err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	sqlDB.SetMaxOpenConns(externalMaxOpenConns)
+	sqlDB.SetMaxIdleConns(externalMaxIdleConns)
+	sqlDB.SetConnMaxLifetime(externalConnMaxLifetime)
+
+	if err := applyDialectColumnTypes(db, mainModels()...); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// normalizeMySQLDSN 强制 parseTime 并以 UTC 解释 DATETIME，其余参数保持用户配置。
+func normalizeMySQLDSN(dsn string) (string, error) {
+	cfg, err := mysqldriver.ParseDSN(dsn)
+	if err != nil {
+		return "", fmt.Errorf("invalid MySQL DSN: %w", err)
+	}
+	cfg.ParseTime = true
+	cfg.Loc = time.UTC
+	return cfg.FormatDSN(), nil
+}
+
+// applyDialectColumnTypes 按 dialectColumnTypes 改写 db 的 schema 缓存中对应字段的列类型，
+// 必须在这些模型第一次 AutoMigrate 之前调用。
+func applyDialectColumnTypes(db *gorm.DB, values ...any) error {
+	overrides := dialectColumnTypes[db.Dialector.Name()]
+	if len(overrides) == 0 {
+		return nil
+	}
+	for _, value := range values {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(value); err != nil {
+			return fmt.Errorf("parse schema for %T: %w", value, err)
+		}
+		for _, field := range stmt.Schema.Fields {
+			if replacement, ok := overrides[strings.ToLower(string(field.DataType))]; ok {
+				field.DataType = schema.DataType(replacement)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package dbcore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/komari-monitor/komari/cmd/flags"
+	"github.com/komari-monitor/komari/internal/migrations"
+	"github.com/komari-monitor/komari/internal/sqlitetune"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const transferBatchSize = 500
+
+// ErrTargetNotEmpty 表示导入目标库已有数据且未要求覆盖。
+var ErrTargetNotEmpty = errors.New("target database is not empty")
+
+// TransferStats 记录每张表复制的行数。
+type TransferStats map[string]int64
+
+// ImportSQLiteFile 把 SQLite 主库文件导入当前主库（通常是 MySQL/PostgreSQL）。
+//
+// 源文件先复制到临时目录再执行启动迁移和 AutoMigrate，因此旧版本的库也能导入，
+// 且不会修改原文件。overwrite 为 false 时目标库任一业务表有数据即返回 ErrTargetNotEmpty；
+// 为 true 时在复制的同一事务中先清空目标库的业务表，导入失败时目标库保持原样。
+func ImportSQLiteFile(ctx context.Context, path string, overwrite bool) (TransferStats, error) {
+	if flags.IsSQLite() {
+		return nil, errors.New("the main database is already SQLite")
+	}
+	if err := Initialize(); err != nil {
+		return nil, err
+	}
+	return importSQLiteFile(ctx, instance, path, overwrite)
+}
+
+func importSQLiteFile(ctx context.Context, target *gorm.DB, path string, overwrite bool) (TransferStats, error) {
+	tempDir, err := os.MkdirTemp("", "komari-sqlite-import-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tempDir)
+	tempFile := filepath.Join(tempDir, "komari.db")
+	if err := copySQLiteFileSet(path, tempFile); err != nil {
+		return nil, err
+	}
+
+	src, closeSrc, err := openSQLiteFile(tempFile)
+	if err != nil {
+		return nil, err
+	}
+	defer closeSrc()
+	if err := migrations.Run(migrations.Context{DB: src}); err != nil {
+		return nil, fmt.Errorf("migrate source database: %w", err)
+	}
+	if err := src.AutoMigrate(mainModels()...); err != nil {
+		return nil, fmt.Errorf("migrate source database: %w", err)
+	}
+
+	dst := target.WithContext(ctx)
+	if !overwrite {
+		if table, err := firstNonEmptyTable(dst); err != nil {
+			return nil, err
+		} else if table != "" {
+			return nil, fmt.Errorf("%w: table %s already has rows", ErrTargetNotEmpty, table)
+		}
+	}
+
+	stats, err := copyTables(src.WithContext(ctx), dst, overwrite)
+	if err != nil {
+		return stats, err
+	}
+	logger.Infof("dbcore", "Imported SQLite database %s into %s: %v", path, flags.DatabaseType, stats)
+	return stats, nil
+}
+
+// ExportSQLite 把当前主库的业务表导出为独立的 SQLite 文件 destPath，用于
+// MySQL/PostgreSQL 主库的备份（SQLite 主库直接 VACUUM INTO 即可）。
+// 外部库在一个可重复读的只读事务中读取，得到一致性快照。
+func ExportSQLite(ctx context.Context, destPath string) (TransferStats, error) {
+	if err := Initialize(); err != nil {
+		return nil, err
+	}
+	return exportSQLite(ctx, instance, destPath)
+}
+
+func exportSQLite(ctx context.Context, source *gorm.DB, destPath string) (TransferStats, error) {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return nil, err
+	}
+	_ = os.Remove(destPath)
+
+	dst, closeDst, err := openSQLiteFile(destPath)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDst()
+	if err := dst.AutoMigrate(mainModels()...); err != nil {
+		return nil, fmt.Errorf("create export schema: %w", err)
+	}
+
+	src := source.WithContext(ctx)
+	if source.Dialector.Name() != "sqlite" {
+		src = src.Begin(&sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if src.Error != nil {
+			return nil, fmt.Errorf("begin snapshot transaction: %w", src.Error)
+		}
+		defer src.Rollback()
+	}
+	return copyTables(src, dst, false)
+}
+
+// copyTables 按 mainModels 顺序分批复制全部业务表。clear 为 true 时先在同一事务中
+// 清空目标库的业务表，复制失败时一并回滚，目标库保持原样。
+func copyTables(src, dst *gorm.DB, clear bool) (TransferStats, error) {
+	stats := TransferStats{}
+	err := dst.Transaction(func(tx *gorm.DB) error {
+		tx = tx.Session(&gorm.Session{SkipHooks: true})
+		if clear {
+			if err := clearTables(tx); err != nil {
+				return err
+			}
+		}
+		for _, model := range mainModels() {
+			table, count, err := copyTable(src, tx, model)
+			if table != "" {
+				stats[table] = count
+			}
+			if err != nil {
+				return fmt.Errorf("copy table %s: %w", table, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return stats, err
+	}
+	return stats, resetSequences(dst)
+}
+
+func copyTable(src, dst *gorm.DB, model any) (string, int64, error) {
+	stmt := &gorm.Statement{DB: src}
+	if err := stmt.Parse(model); err != nil {
+		return "", 0, err
+	}
+	table := stmt.Schema.Table
+	if !src.Migrator().HasTable(model) {
+		return table, 0, nil
+	}
+
+	// 按主键（无主键时按全部列）排序分页，保证批次之间不重不漏。
+	var order []clause.OrderByColumn
+	if stmt.Schema.PrioritizedPrimaryField != nil {
+		order = append(order, clause.OrderByColumn{Column: clause.Column{Name: stmt.Schema.PrioritizedPrimaryField.DBName}})
+	} else {
+		for _, name := range stmt.Schema.DBNames {
+			order = append(order, clause.OrderByColumn{Column: clause.Column{Name: name}})
+		}
+	}
+
+	modelType := reflect.TypeOf(model).Elem()
+	var copied int64
+	for offset := 0; ; offset += transferBatchSize {
+		batch := reflect.New(reflect.SliceOf(modelType))
+		if err := src.Model(model).Order(clause.OrderBy{Columns: order}).
+			Limit(transferBatchSize).Offset(offset).Find(batch.Interface()).Error; err != nil {
+			return table, copied, err
+		}
+		n := batch.Elem().Len()
+		if n == 0 {
+			break
+		}
+		// 以 map 写入：结构体 Create 会把带 default tag 的零值（如 enabled=false）
+		// 替换为默认值，导致数据失真。
+		rows := make([]map[string]any, 0, n)
+		for i := 0; i < n; i++ {
+			elem := batch.Elem().Index(i)
+			row := make(map[string]any, len(stmt.Schema.DBNames))
+			for _, name := range stmt.Schema.DBNames {
+				row[name], _ = stmt.Schema.FieldsByDBName[name].ValueOf(src.Statement.Context, elem)
+			}
+			rows = append(rows, row)
+		}
+		if err := dst.Table(table).Create(&rows).Error; err != nil {
+			return table, copied, err
+		}
+		copied += int64(n)
+		if n < transferBatchSize {
+			break
+		}
+	}
+	return table, copied, nil
+}
+
+// clearTables 逆序清空业务表，先删依赖方。
+func clearTables(db *gorm.DB) error {
+	all := mainModels()
+	for i := len(all) - 1; i >= 0; i-- {
+		if !db.Migrator().HasTable(all[i]) {
+			continue
+		}
+		if err := db.Session(&gorm.Session{AllowGlobalUpdate: true}).Delete(all[i]).Error; err != nil {
+			return fmt.Errorf("clear %T: %w", all[i], err)
+		}
+	}
+	return nil
+}
+
+// firstNonEmptyTable 返回第一张有数据的业务表名。configs 表启动时会写入默认值，不计入。
+func firstNonEmptyTable(db *gorm.DB) (string, error) {
+	for _, model := range mainModels()[1:] {
+		if !db.Migrator().HasTable(model) {
+			continue
+		}
+		var count int64
+		if err := db.Model(model).Limit(1).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count > 0 {
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(model); err != nil {
+				return "", err
+			}
+			return stmt.Schema.Table, nil
+		}
+	}
+	return "", nil
+}
+
+// resetSequences 在显式写入自增主键后把 PostgreSQL 序列推进到当前最大值；
+// MySQL 与 SQLite 会自动跟随已插入的最大值。
+func resetSequences(db *gorm.DB) error {
+	if db.Dialector.Name() != "postgres" {
+		return nil
+	}
+	for _, model := range mainModels() {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		field := stmt.Schema.PrioritizedPrimaryField
+		if field == nil || !field.AutoIncrement {
+			continue
+		}
+		query := fmt.Sprintf(
+			"SELECT setval(pg_get_serial_sequence('%s', '%s'), COALESCE((SELECT MAX(%s) FROM %s), 0) + 1, false)",
+			stmt.Schema.Table, field.DBName, stmt.Quote(field.DBName), stmt.Quote(stmt.Schema.Table),
+		)
+		if err := db.Exec(query).Error; err != nil {
+			return fmt.Errorf("reset sequence for %s: %w", stmt.Schema.Table, err)
+		}
+	}
+	return nil
+}
+
+// openSQLiteFile 以独立连接打开 SQLite 文件，用于导入源或导出目标。
+func openSQLiteFile(path string) (*gorm.DB, func(), error) {
+	sqlDB, err := sqlitetune.Open(buildSQLiteDSN(path), mainSQLiteOptions())
+	if err != nil {
+		return nil, nil, err
+	}
+	sqlDB.SetMaxOpenConns(1)
+	db, err := gorm.Open(sqlite.New(sqlite.Config{Conn: sqlDB}), &gorm.Config{
+		Logger:  logger.NewGormLogger(),
+		NowFunc: func() time.Time { return time.Now().UTC() },
+	})
+	if err != nil {
+		_ = sqlDB.Close()
+		return nil, nil, err
+	}
+	return db, func() { _ = sqlDB.Close() }, nil
+}
+
+// copySQLiteFileSet 复制数据库文件及其 -wal 旁路文件，使未 checkpoint 的写入一并导入。
+func copySQLiteFileSet(src, dst string) error {
+	if _, err := os.Stat(src); err != nil {
+		return fmt.Errorf("source database: %w", err)
+	}
+	for _, suffix := range []string{"", "-wal"} {
+		in, err := os.Open(src + suffix)
+		if err != nil {
+			if suffix != "" && errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		out, err := os.Create(dst + suffix)
+		if err != nil {
+			in.Close()
+			return err
+		}
+		_, err = io.Copy(out, in)
+		in.Close()
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
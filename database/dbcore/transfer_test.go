@@ -0,0 +1,141 @@
+package dbcore
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func TestCopyTablesPreservesZeroValuesAndRows(t *testing.T) {
+	dir := t.TempDir()
+	src, closeSrc, err := openSQLiteFile(filepath.Join(dir, "src.db"))
+	if err != nil {
+		t.Fatalf("open source: %v", err)
+	}
+	defer closeSrc()
+	dst, closeDst, err := openSQLiteFile(filepath.Join(dir, "dst.db"))
+	if err != nil {
+		t.Fatalf("open target: %v", err)
+	}
+	defer closeDst()
+	for _, db := range []*gorm.DB{src, dst} {
+		if err := db.AutoMigrate(mainModels()...); err != nil {
+			t.Fatalf("migrate: %v", err)
+		}
+	}
+
+	expires := time.Date(2099, 1, 1, 0, 0, 0, 0, time.UTC)
+	if err := src.Create(&models.Client{UUID: "node-a", Name: "a", ExpiredAt: &expires}).Error; err != nil {
+		t.Fatalf("seed client: %v", err)
+	}
+	if err := src.Create(&models.OfflineNotification{Client: "node-a", Enable: true, GracePeriod: 60}).Error; err != nil {
+		t.Fatalf("seed notification: %v", err)
+	}
+	if err := src.Create(&models.MetricSink{Name: "off", Type: "influxdb", URL: "http://x", Enabled: true}).Error; err != nil {
+		t.Fatalf("seed sink: %v", err)
+	}
+	if err := src.Model(&models.MetricSink{}).Where("name = ?", "off").Update("enabled", false).Error; err != nil {
+		t.Fatalf("disable sink: %v", err)
+	}
+	for i := 0; i < transferBatchSize+3; i++ {
+		if err := src.Create(&models.Log{IP: "127.0.0.1", MsgType: "info", Message: "m"}).Error; err != nil {
+			t.Fatalf("seed log: %v", err)
+		}
+	}
+
+	stats, err := copyTables(src, dst, false)
+	if err != nil {
+		t.Fatalf("copyTables: %v", err)
+	}
+	if stats["logs"] != transferBatchSize+3 || stats["clients"] != 1 || stats["offline_notifications"] != 1 {
+		t.Fatalf("unexpected stats %v", stats)
+	}
+
+	var sink models.MetricSink
+	if err := dst.First(&sink).Error; err != nil {
+		t.Fatalf("load sink: %v", err)
+	}
+	if sink.Enabled {
+		t.Fatal("disabled sink was re-enabled by its column default")
+	}
+	var client models.Client
+	if err := dst.First(&client, "uuid = ?", "node-a").Error; err != nil {
+		t.Fatalf("load client: %v", err)
+	}
+	if client.ExpiredAt == nil || !client.ExpiredAt.Equal(expires) {
+		t.Fatalf("expired_at = %v, want %v", client.ExpiredAt, expires)
+	}
+
+	// 覆盖复制失败时，清空操作随事务回滚，目标库保持原样。
+	if err := dst.Migrator().DropColumn(&models.Log{}, "message"); err != nil {
+		t.Fatalf("drop column: %v", err)
+	}
+	if _, err := copyTables(src, dst, true); err == nil {
+		t.Fatal("copyTables into a broken target should fail")
+	}
+	if err := dst.First(&client, "uuid = ?", "node-a").Error; err != nil {
+		t.Fatalf("target lost rows after a failed overwrite: %v", err)
+	}
+
+	if table, err := firstNonEmptyTable(dst); err != nil || table == "" {
+		t.Fatalf("firstNonEmptyTable = %q, %v; want a table", table, err)
+	}
+	if err := clearTables(dst); err != nil {
+		t.Fatalf("clearTables: %v", err)
+	}
+	if table, err := firstNonEmptyTable(dst); err != nil || table != "" {
+		t.Fatalf("firstNonEmptyTable after clear = %q, %v", table, err)
+	}
+}
+
+func TestApplyDialectColumnTypes(t *testing.T) {
+	tests := []struct {
+		name      string
+		dialector gorm.Dialector
+		model     any
+		field     string
+		want      string
+	}{
+		{"postgres longtext", postgres.New(postgres.Config{DSN: "host=localhost dbname=komari"}), &models.Task{}, "Clients", "text"},
+		{"mysql timestamp", mysql.New(mysql.Config{DSN: "u:p@tcp(localhost:3306)/komari", SkipInitializeWithVersion: true}), &models.Client{}, "ExpiredAt", "datetime(3)"},
+		{"mysql text", mysql.New(mysql.Config{DSN: "u:p@tcp(localhost:3306)/komari", SkipInitializeWithVersion: true}), &models.Task{}, "Command", "longtext"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, err := gorm.Open(tt.dialector, &gorm.Config{DisableAutomaticPing: true, DryRun: true})
+			if err != nil {
+				t.Fatalf("open: %v", err)
+			}
+			if err := applyDialectColumnTypes(db, tt.model); err != nil {
+				t.Fatalf("applyDialectColumnTypes: %v", err)
+			}
+			stmt := &gorm.Statement{DB: db}
+			if err := stmt.Parse(tt.model); err != nil {
+				t.Fatal(err)
+			}
+			got := db.Migrator().FullDataTypeOf(stmt.Schema.LookUpField(tt.field)).SQL
+			if !strings.HasPrefix(strings.ToLower(got), tt.want) {
+				t.Fatalf("column type = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeMySQLDSN(t *testing.T) {
+	got, err := normalizeMySQLDSN("komari:secret@tcp(db:3306)/komari?charset=utf8mb4")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(got, "parseTime=true") || !strings.Contains(got, "charset=utf8mb4") {
+		t.Fatalf("normalized DSN = %q", got)
+	}
+	if _, err := normalizeMySQLDSN("postgres://host/db"); err == nil {
+		t.Fatal("expected invalid DSN error")
+	}
+}
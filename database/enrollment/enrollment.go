@@ -0,0 +1,336 @@
+// Package enrollment 管理 Agent 自动注册使用的注册令牌（见 models.EnrollmentToken）。
+//
+// 每个令牌可以限制使用次数与有效期，并为注册的新客户端预设分组、标签、隐藏状态、
+// Ping 任务与离线通知。机器指纹由客户端自行上报、不能作为身份凭据：只有使用同一注册令牌
+// 并出示原客户端令牌的重新注册才会返回已有客户端（不消耗令牌次数），否则按新机器注册。
+package enrollment
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"github.com/komari-monitor/komari/utils"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// tokenPrefix 是注册令牌明文的固定前缀，便于在脚本和日志中辨认。
+const tokenPrefix = "kme_"
+
+// ReenrollOverlap 是同一机器重新注册后旧客户端令牌的保留时间，
+// 避免安装脚本中途失败时原 Agent 立即失联。
+const ReenrollOverlap = time.Hour
+
+var (
+	// ErrInvalidToken 表示令牌不存在。
+	ErrInvalidToken = errors.New("invalid enrollment token")
+	// ErrTokenDisabled 表示令牌已被停用。
+	ErrTokenDisabled = errors.New("enrollment token is disabled")
+	// ErrTokenExpired 表示令牌已过期。
+	ErrTokenExpired = errors.New("enrollment token has expired")
+	// ErrTokenExhausted 表示令牌的使用次数已用完。
+	ErrTokenExhausted = errors.New("enrollment token has reached its maximum number of uses")
+)
+
+func hash(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
+
+// ListTokens 列出全部注册令牌
+func ListTokens() ([]models.EnrollmentToken, error) {
+	db := dbcore.GetDBInstance()
+	var tokens []models.EnrollmentToken
+	if err := db.Order("id ASC").Find(&tokens).Error; err != nil {
+		return nil, err
+	}
+	return tokens, nil
+}
+
+// GetToken 根据 ID 获取注册令牌
+func GetToken(id uint) (*models.EnrollmentToken, error) {
+	db := dbcore.GetDBInstance()
+	var token models.EnrollmentToken
+	if err := db.First(&token, id).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// normalize 校验令牌配置并整理默认值。
+func normalize(token *models.EnrollmentToken) error {
+	token.Name = strings.TrimSpace(token.Name)
+	if token.Name == "" {
+		return invalid.Errorf("name is required")
+	}
+	if token.MaxUses < 0 {
+		return invalid.Errorf("max_uses must not be negative")
+	}
+	if token.ExpiresAt != nil {
+		expires := token.ExpiresAt.UTC()
+		token.ExpiresAt = &expires
+	}
+	d := &token.Defaults
+	tags := make([]string, 0, len(d.Tags))
+	for _, tag := range d.Tags {
+		tag = strings.TrimSpace(tag)
+		if strings.Contains(tag, ";") {
+			return invalid.Errorf("tag %q must not contain ';'", tag)
+		}
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	d.Tags = tags
+	if d.OfflineNotification != nil && d.OfflineNotification.GracePeriod != nil && *d.OfflineNotification.GracePeriod < 0 {
+		return invalid.Errorf("offline_notification.grace_period must not be negative")
+	}
+
+	db := dbcore.GetDBInstance()
+	if d.GroupID != nil {
+		if _, err := clientgroups.GetGroup(*d.GroupID); err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return invalid.Errorf("client group %d not found", *d.GroupID)
+			}
+			return err
+		}
+	}
+	if len(d.PingTasks) > 0 {
+		var count int64
+		if err := db.Model(&models.PingTask{}).Where("id IN ?", d.PingTasks).Count(&count).Error; err != nil {
+			return err
+		}
+		if int(count) != len(d.PingTasks) {
+			return invalid.Errorf("ping_tasks references a ping task that does not exist")
+		}
+	}
+	return nil
+}
+
+// CreateToken 创建注册令牌，返回只展示这一次的令牌明文。
+func CreateToken(token *models.EnrollmentToken) (string, error) {
+	if err := normalize(token); err != nil {
+		return "", err
+	}
+	plain := tokenPrefix + utils.GenerateRandomString(32)
+	token.Id = 0
+	token.Uses = 0
+	token.LastUsedAt = nil
+	token.TokenHash = hash(plain)
+	token.TokenPrefix = plain[:len(tokenPrefix)+4]
+	enabled := token.Enabled
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(token).Error; err != nil {
+			return err
+		}
+		// enabled 列带有默认值，GORM 创建时会把 false 替换为默认值，需要单独写回。
+		if !enabled {
+			token.Enabled = false
+			return tx.Model(token).Update("enabled", false).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return plain, nil
+}
+
+// UpdateToken 更新令牌的名称、启用状态、次数、有效期与默认值；令牌本身与已用次数不变。
+func UpdateToken(token *models.EnrollmentToken) error {
+	if err := normalize(token); err != nil {
+		return err
+	}
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.EnrollmentToken{}).Where("id = ?", token.Id).
+		Select("name", "enabled", "max_uses", "expires_at", "defaults", "updated_at").Updates(token)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteToken 删除注册令牌。已注册的客户端不受影响。
+func DeleteToken(id uint) error {
+	db := dbcore.GetDBInstance()
+	result := db.Where("id = ?", id).Delete(&models.EnrollmentToken{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Authenticate 根据令牌明文查找可用的注册令牌。
+// 令牌不存在时返回 ErrInvalidToken，调用方可以据此回退到旧版 auto_discovery_key。
+func Authenticate(secret string) (*models.EnrollmentToken, error) {
+	if secret == "" {
+		return nil, ErrInvalidToken
+	}
+	db := dbcore.GetDBInstance()
+	var token models.EnrollmentToken
+	if err := db.Where("token_hash = ?", hash(secret)).First(&token).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrInvalidToken
+		}
+		return nil, err
+	}
+	if !token.Enabled {
+		return &token, ErrTokenDisabled
+	}
+	if token.ExpiresAt != nil && !token.ExpiresAt.After(time.Now()) {
+		return &token, ErrTokenExpired
+	}
+	return &token, nil
+}
+
+// Request 是一次注册请求。Token 为 nil 表示使用旧版 auto_discovery_key 注册，不应用默认值。
+type Request struct {
+	Token       *models.EnrollmentToken
+	Name        string
+	Fingerprint string
+	ClientToken string // 重新安装时 Agent 出示的原客户端令牌，用于证明它就是已注册的客户端
+	IP          string
+}
+
+// Result 是注册结果。Existing 表示返回了已注册的客户端，此时 Token 是新签发的令牌，
+// 旧令牌在 ReenrollOverlap 内仍然有效。
+type Result struct {
+	UUID     string `json:"uuid"`
+	Token    string `json:"token"`
+	Existing bool   `json:"-"`
+}
+
+// Enroll 注册客户端。同一机器指纹使用同一注册令牌、并出示原客户端令牌再次注册时返回
+// 已有客户端（令牌仍需可用，但不消耗次数）；否则消耗一次令牌使用次数，创建客户端并应用
+// 令牌的默认值。使用旧版 auto_discovery_key 注册时总是创建新客户端。
+func Enroll(req Request) (*Result, error) {
+	fingerprint := ""
+	if f := strings.TrimSpace(req.Fingerprint); f != "" {
+		fingerprint = hash(f)
+	}
+	// 出示的客户端令牌所属的客户端；令牌无效时为空。
+	proven := ""
+	if req.ClientToken != "" {
+		if uuid, err := clients.GetClientUUIDByToken(req.ClientToken); err == nil {
+			proven = uuid
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	var (
+		result   Result
+		defaults models.EnrollmentDefaults
+		tokenID  *uint
+	)
+	if req.Token != nil {
+		defaults = req.Token.Defaults
+		tokenID = &req.Token.Id
+	}
+
+	db := dbcore.GetDBInstance()
+	err := db.Transaction(func(tx *gorm.DB) error {
+		now := time.Now().UTC()
+		if fingerprint != "" && tokenID != nil && proven != "" {
+			var existing models.ClientEnrollment
+			if err := tx.Where("fingerprint = ?", fingerprint).Limit(1).Find(&existing).Error; err != nil {
+				return err
+			}
+			// 客户端令牌已经证明了身份；客户端已被删除时 proven 为空，按新机器处理。
+			if existing.ClientUUID == proven && existing.TokenID != nil && *existing.TokenID == *tokenID {
+				var usable int64
+				if err := usableToken(tx, *tokenID, now).Count(&usable).Error; err != nil {
+					return err
+				}
+				if usable == 0 {
+					return ErrTokenExhausted
+				}
+				result = Result{UUID: proven, Existing: true}
+				return nil
+			}
+		}
+
+		if tokenID != nil {
+			res := usableToken(tx, *tokenID, now).
+				Updates(map[string]any{"uses": gorm.Expr("uses + 1"), "last_used_at": now})
+			if res.Error != nil {
+				return res.Error
+			}
+			if res.RowsAffected == 0 {
+				return ErrTokenExhausted
+			}
+		}
+
+		token := utils.GenerateToken()
+		client := models.Client{
+			UUID:      uuid.New().String(),
+			Token:     models.HashClientToken(token),
+			Name:      req.Name,
+			Tags:      strings.Join(defaults.Tags, ";"),
+			Hidden:    defaults.Hidden,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+		if err := tx.Create(&client).Error; err != nil {
+			return err
+		}
+		if fingerprint != "" {
+			record := models.ClientEnrollment{Fingerprint: fingerprint, ClientUUID: client.UUID, TokenID: tokenID, IP: req.IP}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "fingerprint"}},
+				DoUpdates: clause.AssignmentColumns([]string{"client_uuid", "token_id", "ip", "updated_at"}),
+			}).Create(&record).Error; err != nil {
+				return err
+			}
+		}
+		result = Result{UUID: client.UUID, Token: token}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if result.Existing {
+		// 令牌只保存哈希，无法取回原令牌，为重新安装的 Agent 签发新令牌。
+		token, _, err := clients.RotateToken(result.UUID, ReenrollOverlap)
+		if err != nil {
+			return nil, err
+		}
+		result.Token = token
+		return &result, nil
+	}
+
+	if err := tasks.AddDefaultOnClientUUID(result.UUID); err != nil {
+		logger.ErrorArgs("enrollment", "Failed to apply default-on ping tasks to new client:", err)
+	}
+	extra := models.GroupPolicy{PingTasks: defaults.PingTasks, Offline: defaults.OfflineNotification}
+	if err := clientgroups.ApplyDefaults(result.UUID, defaults.GroupID, extra); err != nil {
+		// 客户端已创建，默认值应用失败不影响注册结果，管理员可手动补齐。
+		logger.Warnf("enrollment", "Failed to apply enrollment defaults to client %s: %v", result.UUID, err)
+	}
+	return &result, nil
+}
+
+// usableToken 筛选启用、未过期且仍有剩余次数的注册令牌。
+func usableToken(tx *gorm.DB, id uint, now time.Time) *gorm.DB {
+	return tx.Model(&models.EnrollmentToken{}).
+		Where("id = ? AND enabled = ?", id, true).
+		Where("max_uses = 0 OR uses < max_uses").
+		Where("expires_at IS NULL OR expires_at > ?", now)
+}
@@ -0,0 +1,138 @@
+package enrollment
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/invalid"
+)
+
+func ptr[T any](v T) *T { return &v }
+
+func TestEnrollAppliesDefaultsAndIsIdempotent(t *testing.T) {
+	db := dbtest.Open(t, "enrollment")
+	group := models.ClientGroup{Name: "edge"}
+	if err := db.Create(&group).Error; err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	task := models.PingTask{Name: "edge-ping", Type: "icmp", Target: "1.1.1.1", Interval: 60, Clients: models.StringArray{}}
+	if err := db.Create(&task).Error; err != nil {
+		t.Fatalf("create ping task: %v", err)
+	}
+
+	token := models.EnrollmentToken{Name: "edge", Enabled: true, MaxUses: 3, Defaults: models.EnrollmentDefaults{
+		GroupID:             &group.Id,
+		Tags:                []string{" edge ", "", "new"},
+		Hidden:              true,
+		PingTasks:           []uint{task.Id},
+		OfflineNotification: &models.OfflineNotificationPolicy{Enable: ptr(true), GracePeriod: ptr(30)},
+	}}
+	secret, err := CreateToken(&token)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+
+	auth, err := Authenticate(secret)
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	first, err := Enroll(Request{Token: auth, Name: "Auto-a", Fingerprint: "machine-a"})
+	if err != nil || first.Existing {
+		t.Fatalf("first enroll = %#v, %v", first, err)
+	}
+
+	var client models.Client
+	if err := db.Where("uuid = ?", first.UUID).First(&client).Error; err != nil {
+		t.Fatalf("load client: %v", err)
+	}
+	if client.Tags != "edge;new" || !client.Hidden || client.GroupID == nil || *client.GroupID != group.Id {
+		t.Fatalf("client defaults not applied: tags=%q hidden=%v group=%v", client.Tags, client.Hidden, client.GroupID)
+	}
+	if err := db.First(&task, task.Id).Error; err != nil || !slices.Contains(task.Clients, first.UUID) {
+		t.Fatalf("client should join the default ping task, got %v (%v)", task.Clients, err)
+	}
+	var offline models.OfflineNotification
+	if err := db.Where("client = ?", first.UUID).First(&offline).Error; err != nil || !offline.Enable || offline.GracePeriod != 30 {
+		t.Fatalf("offline notification = %#v (%v)", offline, err)
+	}
+
+	// 同一机器出示原客户端令牌重复注册：返回同一客户端并签发新令牌，不消耗次数。
+	again, err := Enroll(Request{Token: auth, Name: "Auto-a2", Fingerprint: "machine-a", ClientToken: first.Token})
+	if err != nil || !again.Existing || again.UUID != first.UUID || again.Token == first.Token {
+		t.Fatalf("re-enroll = %#v, %v", again, err)
+	}
+	for _, token := range []string{first.Token, again.Token} {
+		if uuid, err := clients.GetClientUUIDByToken(token); err != nil || uuid != first.UUID {
+			t.Fatalf("token %q should authenticate during the overlap, got %q (%v)", token, uuid, err)
+		}
+	}
+
+	// 只知道指纹不能取得已有客户端的令牌，按新机器注册。
+	spoofed, err := Enroll(Request{Token: auth, Name: "Auto-x", Fingerprint: "machine-a", ClientToken: "wrong"})
+	if err != nil || spoofed.Existing || spoofed.UUID == first.UUID {
+		t.Fatalf("fingerprint without client token = %#v, %v", spoofed, err)
+	}
+	// 旧版 auto_discovery_key 没有令牌 ID，即使出示客户端令牌也不复用。
+	legacy, err := Enroll(Request{Name: "Auto-l", Fingerprint: "machine-a", ClientToken: spoofed.Token})
+	if err != nil || legacy.Existing || legacy.UUID == spoofed.UUID {
+		t.Fatalf("legacy re-enroll = %#v, %v", legacy, err)
+	}
+	machineB, err := Enroll(Request{Token: auth, Name: "Auto-b", Fingerprint: "machine-b"})
+	if err != nil {
+		t.Fatalf("third use: %v", err)
+	}
+	// 次数用完的令牌既不能注册新机器，也不能重新注册。
+	if _, err := Enroll(Request{Token: auth, Name: "Auto-c", Fingerprint: "machine-c"}); !errors.Is(err, ErrTokenExhausted) {
+		t.Fatalf("fourth machine should exhaust the token, got %v", err)
+	}
+	second, err := Enroll(Request{Token: auth, Name: "Auto-b2", Fingerprint: "machine-b", ClientToken: machineB.Token})
+	if !errors.Is(err, ErrTokenExhausted) {
+		t.Fatalf("re-enroll with exhausted token = %#v, %v", second, err)
+	}
+	stored, err := GetToken(token.Id)
+	if err != nil || stored.Uses != 3 || stored.LastUsedAt == nil {
+		t.Fatalf("token usage = %#v (%v)", stored, err)
+	}
+
+	var count int64
+	db.Model(&models.Client{}).Count(&count)
+	if count != 4 {
+		t.Fatalf("expected four clients, got %d", count)
+	}
+}
+
+func TestAuthenticateRejectsUnusableTokens(t *testing.T) {
+	dbtest.Open(t, "enrollment")
+	if _, err := Authenticate("kme_unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("unknown token: %v", err)
+	}
+
+	expired := models.EnrollmentToken{Name: "expired", Enabled: true, ExpiresAt: ptr(time.Now().Add(-time.Hour))}
+	secret, err := CreateToken(&expired)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if _, err := Authenticate(secret); !errors.Is(err, ErrTokenExpired) {
+		t.Fatalf("expired token: %v", err)
+	}
+
+	disabled := models.EnrollmentToken{Name: "disabled", Enabled: false}
+	secret, err = CreateToken(&disabled)
+	if err != nil {
+		t.Fatalf("create token: %v", err)
+	}
+	if _, err := Authenticate(secret); !errors.Is(err, ErrTokenDisabled) {
+		t.Fatalf("disabled token: %v", err)
+	}
+
+	missing := uint(9999)
+	bad := models.EnrollmentToken{Name: "bad", Enabled: true, Defaults: models.EnrollmentDefaults{GroupID: &missing}}
+	if _, err := CreateToken(&bad); !invalid.Is(err) {
+		t.Fatalf("unknown group should be rejected as invalid, got %v", err)
+	}
+}
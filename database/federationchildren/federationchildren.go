@@ -0,0 +1,71 @@
+package federationchildren
+
+import (
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/gorm"
+)
+
+// ListFederationChildren 列出所有联邦子实例
+func ListFederationChildren() ([]models.FederationChild, error) {
+	db := dbcore.GetDBInstance()
+	var children []models.FederationChild
+	if err := db.Order("id ASC").Find(&children).Error; err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// ListEnabledFederationChildren 列出启用的联邦子实例
+func ListEnabledFederationChildren() ([]models.FederationChild, error) {
+	db := dbcore.GetDBInstance()
+	var children []models.FederationChild
+	if err := db.Where("enabled = ?", true).Order("id ASC").Find(&children).Error; err != nil {
+		return nil, err
+	}
+	return children, nil
+}
+
+// GetFederationChild 根据 ID 获取联邦子实例
+func GetFederationChild(id uint) (*models.FederationChild, error) {
+	db := dbcore.GetDBInstance()
+	var child models.FederationChild
+	if err := db.First(&child, id).Error; err != nil {
+		return nil, err
+	}
+	return &child, nil
+}
+
+// CreateFederationChild 创建联邦子实例
+func CreateFederationChild(child *models.FederationChild) error {
+	db := dbcore.GetDBInstance()
+	// Select("*") 保证 enabled=false 等零值不会被数据库默认值覆盖。
+	return db.Select("*").Omit("id").Create(child).Error
+}
+
+// UpdateFederationChild 整体覆盖联邦子实例配置
+func UpdateFederationChild(child *models.FederationChild) error {
+	db := dbcore.GetDBInstance()
+	result := db.Model(&models.FederationChild{}).Where("id = ?", child.Id).
+		Select("*").Omit("id", "created_at").Updates(child)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeleteFederationChild 删除联邦子实例
+func DeleteFederationChild(id uint) error {
+	db := dbcore.GetDBInstance()
+	result := db.Where("id = ?", id).Delete(&models.FederationChild{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package metricsinks
+
+import (
+	"fmt"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/internal/metricsink"
+	"gorm.io/gorm"
+)
+
+// ListMetricSinks 列出所有指标转发目标
+func ListMetricSinks() ([]models.MetricSink, error) {
+	db := dbcore.GetDBInstance()
+	var sinks []models.MetricSink
+	if err := db.Order("id ASC").Find(&sinks).Error; err != nil {
+		return nil, err
+	}
+	return sinks, nil
+}
+
+// GetMetricSink 根据 ID 获取指标转发目标
+func GetMetricSink(id uint) (*models.MetricSink, error) {
+	db := dbcore.GetDBInstance()
+	var sink models.MetricSink
+	if err := db.First(&sink, id).Error; err != nil {
+		return nil, err
+	}
+	return &sink, nil
+}
+
+// CreateMetricSink 创建指标转发目标并重新加载转发器，返回重新加载时的错误
+func CreateMetricSink(sink *models.MetricSink) error {
+	db := dbcore.GetDBInstance()
+	if sink.Include == nil {
+		sink.Include = models.StringArray{}
+	}
+	if sink.Exclude == nil {
+		sink.Exclude = models.StringArray{}
+	}
+	// Select("*") 保证 enabled=false 等零值不会被数据库默认值覆盖。
+	if err := db.Select("*").Omit("id").Create(sink).Error; err != nil {
+		return err
+	}
+	return ReloadMetricSinks()
+}
+
+// UpdateMetricSink 整体覆盖指标转发目标配置并重新加载转发器
+func UpdateMetricSink(sink *models.MetricSink) error {
+	db := dbcore.GetDBInstance()
+	if sink.Include == nil {
+		sink.Include = models.StringArray{}
+	}
+	if sink.Exclude == nil {
+		sink.Exclude = models.StringArray{}
+	}
+	result := db.Model(&models.MetricSink{}).Where("id = ?", sink.Id).
+		Select("*").Omit("id", "created_at").Updates(sink)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ReloadMetricSinks()
+}
+
+// DeleteMetricSink 删除指标转发目标并重新加载转发器
+func DeleteMetricSink(id uint) error {
+	db := dbcore.GetDBInstance()
+	result := db.Where("id = ?", id).Delete(&models.MetricSink{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return ReloadMetricSinks()
+}
+
+// ReloadMetricSinks 按数据库中的配置重建所有转发器
+func ReloadMetricSinks() error {
+	sinks, err := ListMetricSinks()
+	if err != nil {
+		return err
+	}
+	if err := metricsink.Apply(sinks); err != nil {
+		return fmt.Errorf("reload metric sinks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// Agent 事件严重程度，按顺序递增。
+const (
+	AgentEventInfo     = "info"
+	AgentEventWarning  = "warning"
+	AgentEventCritical = "critical"
+)
+
+// AgentEvent 是 Agent 通过 agent.event / agent.message 上报的自定义事件，例如备份完成、
+// OOM killer 触发或磁盘 SMART 警告。Data 保存事件附带数据的 JSON。
+type AgentEvent struct {
+	Id         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClientUUID string    `json:"client_uuid" gorm:"type:varchar(36);not null;index:idx_agent_event_client_time,priority:1"`
+	Type       string    `json:"type" gorm:"type:varchar(100);not null;index"`
+	Severity   string    `json:"severity" gorm:"type:varchar(16);not null;default:'info'"`
+	Message    string    `json:"message" gorm:"type:text"`
+	Data       string    `json:"-" gorm:"type:longtext"`
+	Time       time.Time `json:"time" gorm:"type:timestamp;not null;index;index:idx_agent_event_client_time,priority:2"`
+}
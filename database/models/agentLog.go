@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// AgentLogLine 是 Agent 通过 agent.logs 上报的一行日志，按客户端限制保留时间与行数。
+type AgentLogLine struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClientUUID string    `json:"client_uuid" gorm:"type:varchar(36);not null;index:idx_agent_log_client_time,priority:1"`
+	Source     string    `json:"source" gorm:"type:varchar(100);not null"`
+	Time       time.Time `json:"time" gorm:"type:timestamp;not null;index;index:idx_agent_log_client_time,priority:2"`
+	Line       string    `json:"line" gorm:"type:text"`
+}
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// 持久化 v2 事件的状态。pending / delivered 为未完成状态，其余为终态。
+const (
+	AgentEventPending   = "pending"   // 已入队，尚未下发
+	AgentEventDelivered = "delivered" // 已随响应下发，等待 Agent 确认
+	AgentEventAcked     = "acked"
+	AgentEventExpired   = "expired"   // 超过 TTL 仍未确认
+	AgentEventFailed    = "failed"    // 超过重试次数仍未确认，或因队列已满被丢弃
+	AgentEventCancelled = "cancelled" // 管理员取消
+	AgentEventReplaced  = "replaced"  // 被同类型的新事件替换（如新的令牌、配置）
+)
+
+// AgentOutboxEvent 是 v2 事件队列在数据库中的副本（可选，见 agent_event_outbox_enabled），
+// 使轮询模式 Agent 的待处理事件在服务端重启后仍可送达。
+type AgentOutboxEvent struct {
+	ID          string     `json:"id" gorm:"type:varchar(32);primaryKey"`
+	ClientUUID  string     `json:"client_uuid" gorm:"type:varchar(36);not null;index"`
+	Method      string     `json:"method" gorm:"type:varchar(64);not null"`
+	Params      string     `json:"params" gorm:"type:longtext"` // JSON
+	Status      string     `json:"status" gorm:"type:varchar(16);not null;index"`
+	Attempts    int        `json:"attempts" gorm:"not null"` // 已下发次数
+	MaxAttempts int        `json:"max_attempts" gorm:"not null"`
+	ExpiresAt   time.Time  `json:"expires_at" gorm:"type:timestamp;index"`
+	DeliveredAt *time.Time `json:"delivered_at" gorm:"type:timestamp"`
+	AckedAt     *time.Time `json:"acked_at" gorm:"type:timestamp"`
+	ClosedAt    *time.Time `json:"closed_at" gorm:"type:timestamp"` // 进入终态的时间
+	CreatedAt   time.Time  `json:"created_at"`
+}
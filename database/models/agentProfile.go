@@ -0,0 +1,82 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// AgentConfig 是由服务端下发、覆盖 Agent 本地配置的运行参数。未设置（nil / 空）的字段
+// 沿用 Agent 本地配置。
+type AgentConfig struct {
+	ReportInterval    *int        `json:"report_interval,omitempty"`    // 上报间隔（秒）
+	Collectors        []string    `json:"collectors,omitempty"`         // 启用的采集项，如 cpu、memory、disk、network、gpu
+	ExcludeInterfaces []string    `json:"exclude_interfaces,omitempty"` // 不统计的网卡，支持通配符
+	ExcludeMounts     []string    `json:"exclude_mounts,omitempty"`     // 不统计的挂载点，支持通配符
+	AllowTerminal     *bool       `json:"allow_terminal,omitempty"`
+	AllowExec         *bool       `json:"allow_exec,omitempty"`
+	LogSources        []LogSource `json:"log_sources,omitempty"` // 需要采集并通过 agent.logs 上报的日志
+}
+
+// 日志来源类型
+const (
+	LogSourceFile     = "file"     // Target 为日志文件的绝对路径
+	LogSourceJournald = "journald" // Target 为 systemd 单元名
+)
+
+// LogSource 是 Agent 采集的一个日志来源。Name 在同一配置内唯一，上报的日志行以它标识来源。
+type LogSource struct {
+	Name   string `json:"name"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+func (c *AgentConfig) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*c = AgentConfig{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan AgentConfig: unsupported value type %T", value)
+	}
+	if len(bytes) == 0 {
+		*c = AgentConfig{}
+		return nil
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c AgentConfig) Value() (driver.Value, error) {
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// AgentProfile 是可分配给客户端或分组（GroupPolicy.AgentProfile）的 Agent 配置模板。
+type AgentProfile struct {
+	Id          uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name        string      `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	Description string      `json:"description" gorm:"type:text"`
+	Config      AgentConfig `json:"config" gorm:"type:longtext"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// ClientAgentConfig 记录客户端配置的下发与确认情况。Version 是配置内容的摘要，
+// 未分配配置时为空字符串（表示 Agent 应使用本地配置）。
+type ClientAgentConfig struct {
+	ClientUUID     string     `json:"client_uuid" gorm:"type:varchar(36);primaryKey"`
+	PendingEventID string     `json:"-" gorm:"type:varchar(32)"`
+	PendingVersion string     `json:"pending_version" gorm:"type:varchar(32)"`
+	SentAt         *time.Time `json:"sent_at" gorm:"type:timestamp"`
+	AppliedVersion string     `json:"applied_version" gorm:"type:varchar(32)"`
+	AppliedAt      *time.Time `json:"applied_at" gorm:"type:timestamp"`
+}
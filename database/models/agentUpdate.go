@@ -0,0 +1,68 @@
+package models
+
+import "time"
+
+// AgentRelease 是某个平台上可供 Agent 自更新的版本。二进制可以是外部下载地址（URL），
+// 也可以上传到 Komari（FilePath），此时由 /api/clients/agent-releases/:id/download 提供下载。
+type AgentRelease struct {
+	Id        uint      `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Version   string    `json:"version" gorm:"type:varchar(64);not null;uniqueIndex:idx_agent_release_platform"`
+	OS        string    `json:"os" gorm:"type:varchar(32);not null;uniqueIndex:idx_agent_release_platform"`   // linux, windows, darwin, freebsd
+	Arch      string    `json:"arch" gorm:"type:varchar(32);not null;uniqueIndex:idx_agent_release_platform"` // amd64, arm64, 386, arm ...
+	URL       string    `json:"url" gorm:"type:text"`
+	SHA256    string    `json:"sha256" gorm:"type:varchar(64);not null"`
+	FilePath  string    `json:"file,omitempty" gorm:"type:varchar(64)"` // 上传文件名（即 SHA-256），位于 data/agent-releases
+	Size      int64     `json:"size" gorm:"type:bigint"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// Agent 更新批次的状态
+const (
+	RolloutRunning   = "running"
+	RolloutPaused    = "paused"
+	RolloutCompleted = "completed"
+	RolloutCancelled = "cancelled"
+)
+
+// AgentRollout 是一次分阶段的 Agent 版本升级。阶段 0 为金丝雀分组（CanaryGroupID 为空时
+// 没有成员），之后的阶段按 Percentages 累计覆盖其余客户端，最后一个阶段总是 100。
+type AgentRollout struct {
+	Id                   uint       `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Version              string     `json:"version" gorm:"type:varchar(64);not null"`
+	Status               string     `json:"status" gorm:"type:varchar(16);not null;index"`
+	CanaryGroupID        *uint      `json:"canary_group_id"`
+	Percentages          IntArray   `json:"percentages" gorm:"type:longtext"`
+	Stage                int        `json:"stage" gorm:"type:int;not null;default:0"`
+	FailureThreshold     int        `json:"failure_threshold" gorm:"type:int;not null;default:1"`     // 失败数达到阈值时自动暂停
+	AcknowledgedFailures int        `json:"acknowledged_failures" gorm:"type:int;not null;default:0"` // 恢复时已存在的失败数，不再计入阈值
+	Timeout              int        `json:"timeout" gorm:"type:int;not null;default:600"`             // 秒，下发后未升级到目标版本视为失败
+	PauseReason          string     `json:"pause_reason" gorm:"type:text"`
+	FinishedAt           *time.Time `json:"finished_at" gorm:"type:timestamp"`
+	CreatedAt            time.Time  `json:"created_at"`
+	UpdatedAt            time.Time  `json:"updated_at"`
+}
+
+// 单个客户端在更新批次中的状态
+const (
+	TargetPending   = "pending"
+	TargetUpdating  = "updating"
+	TargetSucceeded = "succeeded"
+	TargetFailed    = "failed"
+	TargetSkipped   = "skipped"
+)
+
+// AgentRolloutTarget 记录更新批次中每个客户端的进度。
+type AgentRolloutTarget struct {
+	Id          uint       `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	RolloutID   uint       `json:"rollout_id" gorm:"not null;uniqueIndex:idx_rollout_target"`
+	ClientUUID  string     `json:"client_uuid" gorm:"type:varchar(36);not null;uniqueIndex:idx_rollout_target;index"`
+	Stage       int        `json:"stage" gorm:"type:int;not null"`
+	Status      string     `json:"status" gorm:"type:varchar(16);not null"`
+	FromVersion string     `json:"from_version" gorm:"type:varchar(100)"`
+	ReleaseID   *uint      `json:"release_id"`
+	Error       string     `json:"error" gorm:"type:text"`
+	StartedAt   *time.Time `json:"started_at" gorm:"type:timestamp"`
+	FinishedAt  *time.Time `json:"finished_at" gorm:"type:timestamp"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
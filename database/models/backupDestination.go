@@ -0,0 +1,40 @@
+package models
+
+import "time"
+
+// BackupDestination 描述一个异地备份目标（S3 兼容对象存储 / WebDAV）。
+//
+// S3 目标：URL 为服务端点（如 https://s3.amazonaws.com、http://minio:9000），
+// Username/Password 为 Access Key / Secret Key。WebDAV 目标：URL 为服务地址，
+// Username/Password 为 Basic 认证凭据。Path 为目标内的目录前缀。
+type BackupDestination struct {
+	Id               uint      `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name             string    `json:"name" gorm:"type:varchar(100);not null"`
+	Type             string    `json:"type" gorm:"type:varchar(32);not null"` // s3, webdav
+	URL              string    `json:"url" gorm:"type:text;not null"`
+	Region           string    `json:"region" gorm:"type:varchar(64)"`          // 仅 S3，默认 us-east-1
+	Bucket           string    `json:"bucket" gorm:"type:varchar(255)"`         // 仅 S3
+	PathStyle        bool      `json:"path_style" gorm:"not null;default:true"` // 仅 S3，MinIO 等需要路径风格寻址
+	Path             string    `json:"path" gorm:"type:varchar(255)"`
+	Username         string    `json:"username" gorm:"type:varchar(255)"`
+	Password         string    `json:"password,omitempty" gorm:"type:varchar(255)"`
+	Enabled          bool      `json:"enabled" gorm:"not null;default:true"`
+	KeepLast         int       `json:"keep_last" gorm:"type:int;not null;default:7"`
+	KeepDaily        int       `json:"keep_daily" gorm:"type:int;not null;default:7"`
+	KeepWeekly       int       `json:"keep_weekly" gorm:"type:int;not null;default:4"`
+	AllowUnencrypted bool      `json:"allow_unencrypted" gorm:"not null;default:false"` // 默认拒绝上传未加密归档，避免明文数据库流向第三方存储
+	CreatedAt        time.Time `json:"created_at"`
+	UpdatedAt        time.Time `json:"updated_at"`
+}
+
+// BackupUpload 记录一次向异地目标上传备份归档的结果。
+type BackupUpload struct {
+	Id            uint      `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	DestinationID uint      `json:"destination_id" gorm:"index;not null"`
+	Archive       string    `json:"archive" gorm:"type:varchar(255);not null"`
+	Size          int64     `json:"size"`
+	Status        string    `json:"status" gorm:"type:varchar(16);not null"` // success, failed
+	Error         string    `json:"error,omitempty" gorm:"type:text"`
+	Duration      int64     `json:"duration"` // 毫秒
+	CreatedAt     time.Time `json:"created_at" gorm:"index"`
+}
@@ -0,0 +1,104 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// ClientGroup 是客户端分组。分组可以嵌套（ParentID），成员通过 Client.GroupID 关联，
+// 并继承分组链路（根 → 叶）上配置的 GroupPolicy。
+type ClientGroup struct {
+	Id          uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name        string      `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	ParentID    *uint       `json:"parent_id" gorm:"index"`
+	Description string      `json:"description" gorm:"type:text"`
+	SortOrder   int         `json:"sort_order" gorm:"type:int;not null;default:0"`
+	Policy      GroupPolicy `json:"policy" gorm:"type:longtext"`
+	CreatedAt   time.Time   `json:"created_at"`
+	UpdatedAt   time.Time   `json:"updated_at"`
+}
+
+// 分组策略项，也是 Client.PolicyOverrides 中可用的取值。
+const (
+	PolicyPingTasks           = "ping_tasks"
+	PolicyLoadNotifications   = "load_notifications"
+	PolicyOfflineNotification = "offline_notification"
+	PolicyTrafficReport       = "traffic_report"
+	PolicyBilling             = "billing"
+	PolicyAgentProfile        = "agent_profile"
+)
+
+// GroupPolicyKeys 列出全部策略项。
+var GroupPolicyKeys = []string{
+	PolicyPingTasks,
+	PolicyLoadNotifications,
+	PolicyOfflineNotification,
+	PolicyTrafficReport,
+	PolicyBilling,
+	PolicyAgentProfile,
+}
+
+// GroupPolicy 是分组下发给成员的默认配置。未设置（nil / 空）的字段不做任何修改，
+// 列表类字段沿分组链路取并集，标量字段由更靠近成员的分组覆盖上级分组。
+type GroupPolicy struct {
+	PingTasks         []uint                     `json:"ping_tasks,omitempty"`         // 成员加入的 Ping 任务 ID
+	LoadNotifications []uint                     `json:"load_notifications,omitempty"` // 成员加入的负载通知规则 ID
+	Offline           *OfflineNotificationPolicy `json:"offline_notification,omitempty"`
+	TrafficReport     *TrafficReportPolicy       `json:"traffic_report,omitempty"`
+	Billing           *BillingPolicy             `json:"billing,omitempty"`
+	AgentProfile      *uint                      `json:"agent_profile,omitempty"` // 成员使用的 AgentProfile ID
+}
+
+// OfflineNotificationPolicy 对应 OfflineNotification 的可继承字段。
+type OfflineNotificationPolicy struct {
+	Enable      *bool `json:"enable,omitempty"`
+	GracePeriod *int  `json:"grace_period,omitempty"` // 秒
+}
+
+// TrafficReportPolicy 对应 TrafficReportNotification 的可继承字段。
+type TrafficReportPolicy struct {
+	Enable  *bool `json:"enable,omitempty"`
+	Daily   *bool `json:"daily,omitempty"`
+	Weekly  *bool `json:"weekly,omitempty"`
+	Monthly *bool `json:"monthly,omitempty"`
+}
+
+// BillingPolicy 对应 Client 上的计费与流量限额字段。
+type BillingPolicy struct {
+	Price            *float64 `json:"price,omitempty"`
+	BillingCycle     *int     `json:"billing_cycle,omitempty"`
+	Currency         *string  `json:"currency,omitempty"`
+	AutoRenewal      *bool    `json:"auto_renewal,omitempty"`
+	TrafficLimit     *int64   `json:"traffic_limit,omitempty"`
+	TrafficLimitType *string  `json:"traffic_limit_type,omitempty"`
+}
+
+func (p *GroupPolicy) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*p = GroupPolicy{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan GroupPolicy: unsupported value type %T", value)
+	}
+	if len(bytes) == 0 {
+		*p = GroupPolicy{}
+		return nil
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+func (p GroupPolicy) Value() (driver.Value, error) {
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
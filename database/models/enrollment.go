@@ -0,0 +1,73 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// EnrollmentToken 是 Agent 自动注册使用的令牌。数据库只保存令牌的 SHA-256，
+// 明文仅在创建时返回一次；TokenPrefix 用于在列表中辨认令牌。
+type EnrollmentToken struct {
+	Id          uint               `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name        string             `json:"name" gorm:"type:varchar(100);not null"`
+	TokenHash   string             `json:"-" gorm:"type:varchar(64);not null;uniqueIndex"`
+	TokenPrefix string             `json:"token_prefix" gorm:"type:varchar(16)"`
+	Enabled     bool               `json:"enabled" gorm:"not null;default:true"`
+	MaxUses     int                `json:"max_uses" gorm:"type:int;not null;default:0"` // 0 表示不限次数
+	Uses        int                `json:"uses" gorm:"type:int;not null;default:0"`
+	ExpiresAt   *time.Time         `json:"expires_at" gorm:"type:timestamp"`
+	Defaults    EnrollmentDefaults `json:"defaults" gorm:"type:longtext"`
+	LastUsedAt  *time.Time         `json:"last_used_at" gorm:"type:timestamp"`
+	CreatedAt   time.Time          `json:"created_at"`
+	UpdatedAt   time.Time          `json:"updated_at"`
+}
+
+// EnrollmentDefaults 是通过令牌注册的新客户端的初始配置。
+type EnrollmentDefaults struct {
+	GroupID             *uint                      `json:"group_id,omitempty"`
+	Tags                []string                   `json:"tags,omitempty"`
+	Hidden              bool                       `json:"hidden,omitempty"`
+	PingTasks           []uint                     `json:"ping_tasks,omitempty"`
+	OfflineNotification *OfflineNotificationPolicy `json:"offline_notification,omitempty"`
+}
+
+func (d *EnrollmentDefaults) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*d = EnrollmentDefaults{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan EnrollmentDefaults: unsupported value type %T", value)
+	}
+	if len(bytes) == 0 {
+		*d = EnrollmentDefaults{}
+		return nil
+	}
+	return json.Unmarshal(bytes, d)
+}
+
+func (d EnrollmentDefaults) Value() (driver.Value, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// ClientEnrollment 记录机器指纹与已注册客户端的对应关系，使重复执行安装脚本
+// 返回同一个客户端。Fingerprint 为 Agent 上报指纹的 SHA-256。
+type ClientEnrollment struct {
+	Fingerprint string    `json:"fingerprint" gorm:"type:varchar(64);primaryKey"`
+	ClientUUID  string    `json:"client_uuid" gorm:"type:varchar(36);not null;index"`
+	TokenID     *uint     `json:"token_id" gorm:"index"` // 使用旧版 auto_discovery_key 注册时为空
+	IP          string    `json:"ip" gorm:"type:varchar(100)"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
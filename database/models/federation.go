@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// FederationChild 描述联邦模式下由本实例（父实例）拉取的子实例。
+//
+// URL 为子实例的站点地址，Key 为子实例设置中的 federation_key。Name 同时作为
+// 远程节点的来源标签（origin）展示。
+type FederationChild struct {
+	Id        uint      `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name      string    `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"`
+	URL       string    `json:"url" gorm:"type:text;not null"`
+	Key       string    `json:"key,omitempty" gorm:"type:varchar(255);not null"`
+	Enabled   bool      `json:"enabled" gorm:"not null;default:true"`
+	Interval  int       `json:"interval" gorm:"type:int;not null;default:10"` // 秒
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
@@ -1,14 +1,19 @@
 package messageevent
 
 const (
-	Offline = "Offline"
-	Online  = "Online"
-	Expire  = "Expire"
-	Renew   = "Renew"
-	Login   = "Login"
-	Alert   = "Alert"
-	Traffic = "Traffic"
-	DReport = "DReport" // 日报
-	WReport = "WReport" // 周报
-	MReport = "MReport" // 月报
+	Offline    = "Offline"
+	Online     = "Online"
+	Expire     = "Expire"
+	Renew      = "Renew"
+	Login      = "Login"
+	Alert      = "Alert"
+	Traffic    = "Traffic"
+	DReport    = "DReport"    // 日报
+	WReport    = "WReport"    // 周报
+	MReport    = "MReport"    // 月报
+	Backup     = "Backup"     // 定时备份失败
+	Container  = "Container"  // 容器异常退出、OOM 或反复重启
+	Service    = "Service"    // 受监控的服务停止、失败、恢复或反复重启
+	AgentEvent = "AgentEvent" // Agent 上报的自定义事件匹配了通知规则
+	Log        = "Log"        // Agent 日志在统计窗口内匹配告警规则的行数超过阈值
 )
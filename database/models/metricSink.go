@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// MetricSink 描述一个外部指标转发目标（Prometheus remote-write / InfluxDB）。
+type MetricSink struct {
+	Id            uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name          string      `json:"name" gorm:"type:varchar(100);not null"`
+	Type          string      `json:"type" gorm:"type:varchar(32);not null"` // prometheus, influxdb
+	URL           string      `json:"url" gorm:"type:text;not null"`
+	Headers       string      `json:"headers" gorm:"type:text"` // JSON object of extra HTTP headers
+	Username      string      `json:"username" gorm:"type:varchar(255)"`
+	Password      string      `json:"password,omitempty" gorm:"type:varchar(255)"`
+	Include       StringArray `json:"include" gorm:"type:longtext"` // metric name patterns, empty means all
+	Exclude       StringArray `json:"exclude" gorm:"type:longtext"`
+	Enabled       bool        `json:"enabled" gorm:"not null;default:true"`
+	QueueSize     int         `json:"queue_size" gorm:"type:int;not null;default:10000"`
+	BatchSize     int         `json:"batch_size" gorm:"type:int;not null;default:500"`
+	FlushInterval int         `json:"flush_interval" gorm:"type:int;not null;default:10"` // 秒
+	MaxRetries    int         `json:"max_retries" gorm:"type:int;not null;default:5"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
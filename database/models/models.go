@@ -1,7 +1,9 @@
 package models
 
 import (
+	"crypto/sha256"
 	"database/sql/driver"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"time"
@@ -9,39 +11,54 @@ import (
 
 // Client represents a registered client device
 type Client struct {
-	UUID             string     `json:"uuid,omitempty" gorm:"type:varchar(36);primaryKey"`
-	Token            string     `json:"token,omitempty" gorm:"type:varchar(255);unique;not null"`
-	Name             string     `json:"name" gorm:"type:varchar(100)"`
-	CpuName          string     `json:"cpu_name" gorm:"type:varchar(100)"`
-	Virtualization   string     `json:"virtualization" gorm:"type:varchar(50)"`
-	Arch             string     `json:"arch" gorm:"type:varchar(50)"`
-	CpuCores         int        `json:"cpu_cores" gorm:"type:int"`
-	CpuPhysicalCores int        `json:"cpu_physical_cores" gorm:"type:int"`
-	OS               string     `json:"os" gorm:"type:varchar(100)"`
-	KernelVersion    string     `json:"kernel_version" gorm:"type:varchar(100)"`
-	GpuName          string     `json:"gpu_name" gorm:"type:varchar(100)"`
-	IPv4             string     `json:"ipv4,omitempty" gorm:"type:varchar(100)"`
-	IPv6             string     `json:"ipv6,omitempty" gorm:"type:varchar(100)"`
-	Region           string     `json:"region" gorm:"type:varchar(100)"`
-	Remark           string     `json:"remark,omitempty" gorm:"type:longtext"`
-	PublicRemark     string     `json:"public_remark,omitempty" gorm:"type:longtext"`
-	MemTotal         int64      `json:"mem_total" gorm:"type:bigint"`
-	SwapTotal        int64      `json:"swap_total" gorm:"type:bigint"`
-	DiskTotal        int64      `json:"disk_total" gorm:"type:bigint"`
-	Version          string     `json:"version,omitempty" gorm:"type:varchar(100)"`
-	Weight           int        `json:"weight" gorm:"type:int"`
-	Price            float64    `json:"price"`
-	BillingCycle     int        `json:"billing_cycle"`
-	AutoRenewal      bool       `json:"auto_renewal" gorm:"default:false"` // 是否自动续费
-	Currency         string     `json:"currency" gorm:"type:varchar(20);default:'$'"`
-	ExpiredAt        *time.Time `json:"expired_at" gorm:"type:timestamp"`
-	Group            string     `json:"group" gorm:"type:varchar(100)"`
-	Tags             string     `json:"tags" gorm:"type:text"` // split by ';'
-	Hidden           bool       `json:"hidden" gorm:"default:false"`
-	TrafficLimit     int64      `json:"traffic_limit" gorm:"type:bigint"`
-	TrafficLimitType string     `json:"traffic_limit_type" gorm:"type:varchar(10);default:'max'"` // 流量阈值类型：sum max min up down
-	CreatedAt        time.Time  `json:"created_at"`
-	UpdatedAt        time.Time  `json:"updated_at"`
+	UUID                   string      `json:"uuid,omitempty" gorm:"type:varchar(36);primaryKey"`
+	Token                  string      `json:"-" gorm:"type:varchar(255);unique;not null"` // HashClientToken 的结果，明文只在创建与轮换时返回
+	PreviousToken          string      `json:"-" gorm:"type:varchar(255);index"`           // 轮换前的令牌哈希，在 PreviousTokenExpiresAt 之前仍可使用
+	PreviousTokenExpiresAt *time.Time  `json:"previous_token_expires_at,omitempty" gorm:"type:timestamp"`
+	TokenRotatedAt         *time.Time  `json:"token_rotated_at,omitempty" gorm:"type:timestamp"`
+	Name                   string      `json:"name" gorm:"type:varchar(100)"`
+	CpuName                string      `json:"cpu_name" gorm:"type:varchar(100)"`
+	Virtualization         string      `json:"virtualization" gorm:"type:varchar(50)"`
+	Arch                   string      `json:"arch" gorm:"type:varchar(50)"`
+	CpuCores               int         `json:"cpu_cores" gorm:"type:int"`
+	CpuPhysicalCores       int         `json:"cpu_physical_cores" gorm:"type:int"`
+	OS                     string      `json:"os" gorm:"type:varchar(100)"`
+	KernelVersion          string      `json:"kernel_version" gorm:"type:varchar(100)"`
+	GpuName                string      `json:"gpu_name" gorm:"type:varchar(100)"`
+	IPv4                   string      `json:"ipv4,omitempty" gorm:"type:varchar(100)"`
+	IPv6                   string      `json:"ipv6,omitempty" gorm:"type:varchar(100)"`
+	Region                 string      `json:"region" gorm:"type:varchar(100)"`
+	Remark                 string      `json:"remark,omitempty" gorm:"type:longtext"`
+	PublicRemark           string      `json:"public_remark,omitempty" gorm:"type:longtext"`
+	MemTotal               int64       `json:"mem_total" gorm:"type:bigint"`
+	SwapTotal              int64       `json:"swap_total" gorm:"type:bigint"`
+	DiskTotal              int64       `json:"disk_total" gorm:"type:bigint"`
+	Version                string      `json:"version,omitempty" gorm:"type:varchar(100)"`
+	Weight                 int         `json:"weight" gorm:"type:int"`
+	Price                  float64     `json:"price"`
+	BillingCycle           int         `json:"billing_cycle"`
+	AutoRenewal            bool        `json:"auto_renewal" gorm:"default:false"` // 是否自动续费
+	Currency               string      `json:"currency" gorm:"type:varchar(20);default:'$'"`
+	ExpiredAt              *time.Time  `json:"expired_at" gorm:"type:timestamp"`
+	Group                  string      `json:"group" gorm:"type:varchar(100)"`                  // 所属分组名称，随 GroupID 同步
+	GroupID                *uint       `json:"group_id" gorm:"index"`                           // 所属分组，见 ClientGroup
+	PolicyOverrides        StringArray `json:"policy_overrides,omitempty" gorm:"type:longtext"` // 不再继承分组的策略项，见 GroupPolicyKeys
+	AgentProfileID         *uint       `json:"agent_profile_id" gorm:"index"`                   // 下发给 Agent 的配置，见 AgentProfile
+	Tags                   string      `json:"tags" gorm:"type:text"`                           // split by ';'
+	Hidden                 bool        `json:"hidden" gorm:"default:false"`
+	TrafficLimit           int64       `json:"traffic_limit" gorm:"type:bigint"`
+	TrafficLimitType       string      `json:"traffic_limit_type" gorm:"type:varchar(10);default:'max'"` // 流量阈值类型：sum max min up down
+	CreatedAt              time.Time   `json:"created_at"`
+	UpdatedAt              time.Time   `json:"updated_at"`
+}
+
+// ClientTokenHashPrefix 标记 Client.Token 中保存的是哈希而非明文。
+const ClientTokenHashPrefix = "sha256:"
+
+// HashClientToken 返回客户端令牌在数据库中保存的形式。
+func HashClientToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return ClientTokenHashPrefix + hex.EncodeToString(sum[:])
 }
 
 // User represents an authenticated user
@@ -136,3 +153,29 @@ func (sa *StringArray) Scan(value interface{}) error {
 func (sa StringArray) Value() (driver.Value, error) {
 	return json.Marshal(sa)
 }
+
+type IntArray []int
+
+func (ia *IntArray) Scan(value interface{}) error {
+	var bytes []byte
+	switch v := value.(type) {
+	case nil:
+		*ia = IntArray{}
+		return nil
+	case []byte:
+		bytes = v
+	case string:
+		bytes = []byte(v)
+	default:
+		return fmt.Errorf("failed to scan IntArray: unsupported value type %T", value)
+	}
+	if len(bytes) == 0 {
+		*ia = IntArray{}
+		return nil
+	}
+	return json.Unmarshal(bytes, ia)
+}
+
+func (ia IntArray) Value() (driver.Value, error) {
+	return json.Marshal(ia)
+}
@@ -17,6 +17,7 @@ type LoadNotification struct {
 	Id           uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
 	Name         string      `json:"name" gorm:"type:varchar(255)"`
 	Clients      StringArray `json:"clients" gorm:"type:longtext"`
+	Selector     string      `json:"selector" gorm:"type:text"`                                 // 选择器表达式，匹配的服务器在检查时与 Clients 合并
 	Metric       string      `json:"metric" gorm:"type:varchar(50);not null;default:'cpu'"`     // 监控指标，如 cpu, ram, load
 	Threshold    float32     `json:"threshold" gorm:"type:decimal(5,2);not null;default:80.00"` // 阈值百分比
 	Ratio        float32     `json:"ratio" gorm:"type:decimal(5,2);not null;default:0.80"`      // 达标时间比
@@ -33,3 +34,31 @@ type TrafficReportNotification struct {
 	Weekly     bool   `json:"weekly" gorm:"type:boolean;default:false"`  // 周报
 	Monthly    bool   `json:"monthly" gorm:"type:boolean;default:false"` // 月报
 }
+
+// AgentEventRule 定义 Agent 自定义事件的通知规则：来自匹配客户端、类型匹配且严重程度
+// 不低于 MinSeverity 的事件会发送通知。
+type AgentEventRule struct {
+	Id          uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name        string      `json:"name" gorm:"type:varchar(255)"`
+	Clients     StringArray `json:"clients" gorm:"type:longtext"`
+	Selector    string      `json:"selector" gorm:"type:text"`                                    // 选择器表达式；Clients 与 Selector 均为空时匹配全部服务器
+	Types       StringArray `json:"types" gorm:"type:longtext"`                                   // 事件类型，支持 backup.* 形式的前缀匹配；为空匹配全部类型
+	MinSeverity string      `json:"min_severity" gorm:"type:varchar(16);not null;default:'info'"` // info | warning | critical
+	Cooldown    int         `json:"cooldown" gorm:"type:int;not null;default:0"`                  // 同一服务器同一类型两次通知的最小间隔（秒）
+	Enabled     bool        `json:"enabled" gorm:"not null;default:true"`
+}
+
+// LogAlertRule 定义日志告警规则：匹配客户端在 Window 分钟内出现超过 Threshold 行
+// 匹配 Pattern 的日志时发送通知。
+type LogAlertRule struct {
+	Id        uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name      string      `json:"name" gorm:"type:varchar(255)"`
+	Clients   StringArray `json:"clients" gorm:"type:longtext"`
+	Selector  string      `json:"selector" gorm:"type:text"`       // 选择器表达式；Clients 与 Selector 均为空时匹配全部服务器
+	Source    string      `json:"source" gorm:"type:varchar(100)"` // 日志来源名称，为空匹配全部来源
+	Pattern   string      `json:"pattern" gorm:"type:varchar(500);not null"`
+	Regex     bool        `json:"regex" gorm:"not null;default:false"` // Pattern 为正则表达式；否则按不区分大小写的子串匹配
+	Threshold int         `json:"threshold" gorm:"type:int;not null;default:20"`
+	Window    int         `json:"window" gorm:"column:window_minutes;type:int;not null;default:5"` // 统计窗口（分钟），也是同一服务器两次通知的最小间隔
+	Enabled   bool        `json:"enabled" gorm:"not null;default:true"`
+}
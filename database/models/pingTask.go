@@ -17,13 +17,15 @@ type PingTask struct {
 	Weight    int         `json:"weight" gorm:"type:int;not null;default:0;index"`
 	Name      string      `json:"name" gorm:"type:varchar(255);not null;index"`
 	Clients   StringArray `json:"clients" gorm:"type:longtext"`
+	Selector  string      `json:"selector" gorm:"type:text"`                                   // 选择器表达式，匹配的服务器在调度时与 Clients 合并，见 pkg/selector
 	DefaultOn bool        `json:"default_on" gorm:"column:all_clients;not null;default:false"` // 新加入的服务器是否自动开启此监测；现有服务器不受此字段影响
 	Type      string      `json:"type" gorm:"type:varchar(12);not null;default:'icmp'"`        // icmp tcp http
 	Target    string      `json:"target" gorm:"type:varchar(255);not null"`                    // Ping 目标地址
 	Interval  int         `json:"interval" gorm:"type:int;not null;default:60"`                // 间隔时间
 }
 
-// AppliesToClient 判断当前 PingTask 是否适用于指定服务器。
+// AppliesToClient 判断当前 PingTask 是否适用于指定服务器。仅检查 Clients，
+// 含选择器的任务需先经 clientselector.ExpandPingTasks 展开。
 func (task PingTask) AppliesToClient(uuid string) bool {
 	if uuid == "" {
 		return false
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// ProcessSnapshot 保存 Agent 上报的高占用进程快照（v1.ProcessReport 的 JSON），
+// 保留时间较短，用于告警附带信息和事后排查。
+type ProcessSnapshot struct {
+	ID         uint      `json:"id" gorm:"primaryKey;autoIncrement"`
+	ClientUUID string    `json:"client_uuid" gorm:"type:varchar(36);not null;index:idx_process_snapshot_client_time,priority:1"`
+	Time       time.Time `json:"time" gorm:"type:timestamp;not null;index:idx_process_snapshot_client_time,priority:2"`
+	Processes  string    `json:"-" gorm:"type:longtext"`
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// 服务监控类型
+const (
+	ServiceMonitorSystemd = "systemd" // Target 为 systemd 单元名，如 nginx.service
+	ServiceMonitorProcess = "process" // Target 为进程名
+)
+
+// ServiceMonitor 定义需要在 Agent 上检查存活状态的服务。定义保存在服务端，
+// 通过 agent.services 事件下发给适用的 v2 Agent，状态通过 agent.serviceStatus 上报。
+type ServiceMonitor struct {
+	Id        uint        `json:"id,omitempty" gorm:"primaryKey;autoIncrement"`
+	Name      string      `json:"name" gorm:"type:varchar(100);not null;uniqueIndex"` // 同时作为 service.up 指标的 service 标签
+	Type      string      `json:"type" gorm:"type:varchar(16);not null;default:'systemd'"`
+	Target    string      `json:"target" gorm:"type:varchar(255);not null"`
+	Clients   StringArray `json:"clients" gorm:"type:longtext"`
+	Selector  string      `json:"selector" gorm:"type:text"` // 选择器表达式，匹配的服务器与 Clients 合并，见 pkg/selector
+	Enabled   bool        `json:"enabled" gorm:"not null;default:true"`
+	CreatedAt time.Time   `json:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at"`
+}
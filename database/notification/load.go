@@ -7,10 +7,11 @@ import (
 	"gorm.io/gorm"
 )
 
-func AddLoadNotification(clients []string, name string, metric string, threshold float32, ratio float32, interval int) (uint, error) {
+func AddLoadNotification(clients []string, selector string, name string, metric string, threshold float32, ratio float32, interval int) (uint, error) {
 	db := dbcore.GetDBInstance()
 	notification := models.LoadNotification{
 		Clients:   clients,
+		Selector:  selector,
 		Name:      name,
 		Metric:    metric,
 		Threshold: threshold,
@@ -39,6 +40,11 @@ func EditLoadNotification(notifications []*models.LoadNotification) error {
 		if result.RowsAffected == 0 {
 			return gorm.ErrRecordNotFound
 		}
+		// struct Updates 会跳过空字符串，选择器需要显式写入才能清空。
+		if err := db.Model(&models.LoadNotification{}).Where("id = ?", notification.Id).
+			Update("selector", notification.Selector).Error; err != nil {
+			return err
+		}
 	}
 
 	return ReloadLoadNotificationSchedule()
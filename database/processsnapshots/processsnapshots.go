@@ -0,0 +1,167 @@
+// Package processsnapshots 保存 Agent 上报的高占用进程快照（models.ProcessSnapshot）。
+//
+// 快照按客户端限频写入，只保留较短时间：负载告警发送时附带最近一次快照，管理员也可以
+// 按时间点查询最接近的快照用于事后排查。进程命令行可能含有敏感信息，快照只对管理员可见，
+// 不会进入公开的实时数据。
+package processsnapshots
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+	"gorm.io/gorm"
+)
+
+const (
+	// SaveInterval 是同一客户端两次保存快照的最小间隔。
+	SaveInterval = time.Minute
+	// Retention 是快照的保留时间。
+	Retention = 24 * time.Hour
+	// AlertMaxAge 是告警附带快照允许的最大时间差。
+	AlertMaxAge = 5 * time.Minute
+
+	maxProcesses     = 20
+	maxCmdlineLength = 256
+	maxNameLength    = 128
+	alertProcesses   = 3
+)
+
+// Snapshot 是对外返回的进程快照。
+type Snapshot struct {
+	ClientUUID string           `json:"client_uuid"`
+	Time       time.Time        `json:"time"`
+	Processes  v1.ProcessReport `json:"processes"`
+}
+
+var (
+	lastSavedMu sync.Mutex
+	lastSaved   = map[string]time.Time{}
+)
+
+// Observe 在距上次保存超过 SaveInterval 时保存一份快照，返回是否写入。
+func Observe(clientUUID string, report *v1.ProcessReport, at time.Time) (bool, error) {
+	if clientUUID == "" || report == nil || (len(report.ByCPU) == 0 && len(report.ByMemory) == 0) {
+		return false, nil
+	}
+	at = at.UTC()
+	lastSavedMu.Lock()
+	if last, ok := lastSaved[clientUUID]; ok && at.Sub(last) < SaveInterval {
+		lastSavedMu.Unlock()
+		return false, nil
+	}
+	lastSaved[clientUUID] = at
+	lastSavedMu.Unlock()
+
+	data, err := json.Marshal(normalize(*report))
+	if err != nil {
+		return false, err
+	}
+	snapshot := models.ProcessSnapshot{ClientUUID: clientUUID, Time: at, Processes: string(data)}
+	if err := dbcore.GetDBInstance().Create(&snapshot).Error; err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// normalize 截断过长的列表与字段，避免单个 Agent 写入过大的快照。
+func normalize(report v1.ProcessReport) v1.ProcessReport {
+	return v1.ProcessReport{ByCPU: normalizeList(report.ByCPU), ByMemory: normalizeList(report.ByMemory)}
+}
+
+func normalizeList(items []v1.ProcessInfo) []v1.ProcessInfo {
+	if len(items) > maxProcesses {
+		items = items[:maxProcesses]
+	}
+	out := make([]v1.ProcessInfo, 0, len(items))
+	for _, item := range items {
+		item.Name = truncate(item.Name, maxNameLength)
+		item.User = truncate(item.User, maxNameLength)
+		item.Cmdline = truncate(item.Cmdline, maxCmdlineLength)
+		out = append(out, item)
+	}
+	return out
+}
+
+func truncate(s string, limit int) string {
+	if len(s) <= limit {
+		return s
+	}
+	s = s[:limit]
+	for !utf8.ValidString(s) {
+		s = s[:len(s)-1]
+	}
+	return s
+}
+
+// Closest 返回与 at 时间最接近的快照，不存在时返回 gorm.ErrRecordNotFound。
+func Closest(clientUUID string, at time.Time) (*Snapshot, error) {
+	at = at.UTC()
+	db := dbcore.GetDBInstance()
+	var before, after models.ProcessSnapshot
+	errBefore := db.Where("client_uuid = ? AND time <= ?", clientUUID, at).Order("time DESC").First(&before).Error
+	if errBefore != nil && !errors.Is(errBefore, gorm.ErrRecordNotFound) {
+		return nil, errBefore
+	}
+	errAfter := db.Where("client_uuid = ? AND time > ?", clientUUID, at).Order("time ASC").First(&after).Error
+	if errAfter != nil && !errors.Is(errAfter, gorm.ErrRecordNotFound) {
+		return nil, errAfter
+	}
+	switch {
+	case errBefore != nil && errAfter != nil:
+		return nil, gorm.ErrRecordNotFound
+	case errBefore != nil:
+		return decode(after)
+	case errAfter != nil:
+		return decode(before)
+	case after.Time.Sub(at) < at.Sub(before.Time):
+		return decode(after)
+	default:
+		return decode(before)
+	}
+}
+
+func decode(row models.ProcessSnapshot) (*Snapshot, error) {
+	snapshot := &Snapshot{ClientUUID: row.ClientUUID, Time: row.Time.UTC()}
+	if err := json.Unmarshal([]byte(row.Processes), &snapshot.Processes); err != nil {
+		return nil, fmt.Errorf("decode process snapshot %d: %w", row.ID, err)
+	}
+	return snapshot, nil
+}
+
+// DeleteBefore 删除早于 before 的快照。
+func DeleteBefore(before time.Time) error {
+	return dbcore.GetDBInstance().Where("time < ?", before.UTC()).Delete(&models.ProcessSnapshot{}).Error
+}
+
+// AlertSummary 返回告警消息中附带的进程摘要，没有足够新的快照时返回空串。
+func AlertSummary(clientUUID, clientName string, at time.Time) string {
+	snapshot, err := Closest(clientUUID, at)
+	if err != nil || snapshot.Time.Sub(at).Abs() > AlertMaxAge {
+		return ""
+	}
+	if clientName == "" {
+		clientName = clientUUID
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s top processes (%s):", clientName, snapshot.Time.Format(time.RFC3339))
+	writeAlertProcesses(&b, "CPU", snapshot.Processes.ByCPU)
+	writeAlertProcesses(&b, "MEM", snapshot.Processes.ByMemory)
+	return b.String()
+}
+
+func writeAlertProcesses(b *strings.Builder, label string, items []v1.ProcessInfo) {
+	if len(items) > alertProcesses {
+		items = items[:alertProcesses]
+	}
+	for _, item := range items {
+		fmt.Fprintf(b, "\n  [%s] %s (pid %d) cpu %.1f%% rss %.1f MiB", label, item.Name, item.PID, item.CPU, float64(item.RSS)/(1<<20))
+	}
+}
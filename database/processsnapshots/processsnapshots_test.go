@@ -0,0 +1,69 @@
+package processsnapshots_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/processsnapshots"
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+	"gorm.io/gorm"
+)
+
+func TestObserveThrottlesAndClosestPicksNearestSnapshot(t *testing.T) {
+	dbtest.Open(t, "processsnapshots")
+	uuid := "process-node"
+	base := time.Now().UTC().Truncate(time.Second).Add(-time.Hour)
+	report := func(name string) *v1.ProcessReport {
+		return &v1.ProcessReport{ByCPU: []v1.ProcessInfo{{PID: 1, Name: name, CPU: 90, RSS: 64 << 20, Cmdline: strings.Repeat("x", 1000)}}}
+	}
+
+	for _, step := range []struct {
+		name  string
+		at    time.Time
+		saved bool
+	}{
+		{"first", base, true},
+		{"throttled", base.Add(30 * time.Second), false},
+		{"second", base.Add(2 * time.Minute), true},
+	} {
+		saved, err := processsnapshots.Observe(uuid, report(step.name), step.at)
+		if err != nil {
+			t.Fatalf("observe %s: %v", step.name, err)
+		}
+		if saved != step.saved {
+			t.Fatalf("observe %s saved = %v, want %v", step.name, saved, step.saved)
+		}
+	}
+
+	snapshot, err := processsnapshots.Closest(uuid, base.Add(50*time.Second))
+	if err != nil {
+		t.Fatalf("closest: %v", err)
+	}
+	if snapshot.Processes.ByCPU[0].Name != "first" || len(snapshot.Processes.ByCPU[0].Cmdline) != 256 {
+		t.Fatalf("closest to +50s = %#v, want truncated first snapshot", snapshot)
+	}
+	snapshot, err = processsnapshots.Closest(uuid, base.Add(90*time.Second))
+	if err != nil {
+		t.Fatalf("closest: %v", err)
+	}
+	if snapshot.Processes.ByCPU[0].Name != "second" {
+		t.Fatalf("closest to +90s = %s, want second", snapshot.Processes.ByCPU[0].Name)
+	}
+
+	if summary := processsnapshots.AlertSummary(uuid, "web-1", base.Add(2*time.Minute)); !strings.Contains(summary, "web-1") || !strings.Contains(summary, "second (pid 1)") {
+		t.Fatalf("alert summary = %q", summary)
+	}
+	if summary := processsnapshots.AlertSummary(uuid, "web-1", base.Add(time.Hour)); summary != "" {
+		t.Fatalf("alert summary for stale snapshot = %q, want empty", summary)
+	}
+
+	if err := processsnapshots.DeleteBefore(base.Add(time.Hour)); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := processsnapshots.Closest(uuid, base); !errors.Is(err, gorm.ErrRecordNotFound) {
+		t.Fatalf("closest after delete err = %v, want not found", err)
+	}
+}
@@ -33,6 +33,11 @@ func GetRecordMetricMaxByClientAndTime(uuid, metricName string, start, end time.
 	return metricstore.GetRecordMetricMaxByClientAndTime(context.Background(), uuid, metricName, start, end)
 }
 
+// GetDeviceMetricMaxByClientAndTime 获取挂载点、网卡或磁盘指标在各时间桶内的最大值。
+func GetDeviceMetricMaxByClientAndTime(uuid, metricName string, start, end time.Time) ([]float64, error) {
+	return metricstore.GetDeviceMetricMaxByClientAndTime(context.Background(), uuid, metricName, start, end)
+}
+
 // GetRecordsByTime 获取所有客户端在时间范围内的记录。
 func GetRecordsByTime(start, end time.Time) ([]models.Record, error) {
 	return metricstore.GetRecordsByTime(context.Background(), start, end)
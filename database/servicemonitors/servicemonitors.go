@@ -0,0 +1,344 @@
+// Package servicemonitors 管理在 Agent 上检查存活状态的服务（models.ServiceMonitor）。
+//
+// 服务定义保存在服务端，通过 agent.services 事件在 Agent 连接时以及定义变更时下发；
+// Agent 通过 agent.serviceStatus 上报检查结果。结果写入 service.up 指标（service 标签），
+// 最近状态保存在内存中，用于判断状态变化并生成通知。
+package servicemonitors
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/agent"
+	"gorm.io/gorm"
+)
+
+// 服务状态
+const (
+	StateRunning = "running"
+	StateStopped = "stopped"
+	StateFailed  = "failed"
+)
+
+const (
+	// 在 restartLoopWindow 内重启达到 restartLoopCount 次视为反复重启。
+	restartLoopCount  = 3
+	restartLoopWindow = 10 * time.Minute
+)
+
+// List 按名称列出全部服务监控
+func List() ([]models.ServiceMonitor, error) {
+	var monitors []models.ServiceMonitor
+	if err := dbcore.GetDBInstance().Order("name ASC").Find(&monitors).Error; err != nil {
+		return nil, err
+	}
+	return monitors, nil
+}
+
+// Get 根据 ID 获取服务监控
+func Get(id uint) (*models.ServiceMonitor, error) {
+	var monitor models.ServiceMonitor
+	if err := dbcore.GetDBInstance().First(&monitor, id).Error; err != nil {
+		return nil, err
+	}
+	return &monitor, nil
+}
+
+func normalize(monitor *models.ServiceMonitor) error {
+	monitor.Name = strings.TrimSpace(monitor.Name)
+	monitor.Target = strings.TrimSpace(monitor.Target)
+	monitor.Type = strings.ToLower(strings.TrimSpace(monitor.Type))
+	if monitor.Name == "" || len(monitor.Name) > 100 {
+		return invalid.Errorf("name is required and must be at most 100 characters")
+	}
+	if monitor.Target == "" || len(monitor.Target) > 255 {
+		return invalid.Errorf("target is required and must be at most 255 characters")
+	}
+	if monitor.Type != models.ServiceMonitorSystemd && monitor.Type != models.ServiceMonitorProcess {
+		return invalid.Errorf("type must be %s or %s", models.ServiceMonitorSystemd, models.ServiceMonitorProcess)
+	}
+	if len(monitor.Clients) == 0 && strings.TrimSpace(monitor.Selector) == "" {
+		return invalid.Errorf("clients or selector is required")
+	}
+	var count int64
+	if err := dbcore.GetDBInstance().Model(&models.ServiceMonitor{}).
+		Where("name = ? AND id <> ?", monitor.Name, monitor.Id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return invalid.Errorf("service %q already exists", monitor.Name)
+	}
+	return nil
+}
+
+// Create 创建服务监控，并下发给适用的在线客户端。
+func Create(monitor *models.ServiceMonitor) error {
+	monitor.Id = 0
+	if err := normalize(monitor); err != nil {
+		return err
+	}
+	if err := dbcore.GetDBInstance().Create(monitor).Error; err != nil {
+		return err
+	}
+	Push(clientsOf(*monitor)...)
+	return nil
+}
+
+// Update 更新服务监控，并向变更前后适用的在线客户端重新下发服务列表。
+// 名称变更后，历史状态仍保留在旧的 service 标签下。
+func Update(monitor *models.ServiceMonitor) error {
+	existing, err := Get(monitor.Id)
+	if err != nil {
+		return err
+	}
+	if err := normalize(monitor); err != nil {
+		return err
+	}
+	result := dbcore.GetDBInstance().Model(&models.ServiceMonitor{}).Where("id = ?", monitor.Id).
+		Select("name", "type", "target", "clients", "selector", "enabled", "updated_at").Updates(monitor)
+	if result.Error != nil {
+		return result.Error
+	}
+	forget(monitor.Id)
+	Push(mergeClients(clientsOf(*existing), clientsOf(*monitor))...)
+	return nil
+}
+
+// Delete 删除服务监控及其历史状态，并通知适用的在线客户端停止检查。
+func Delete(ids []uint) error {
+	var monitors []models.ServiceMonitor
+	db := dbcore.GetDBInstance()
+	if err := db.Where("id IN ?", ids).Find(&monitors).Error; err != nil {
+		return err
+	}
+	if len(monitors) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	if err := db.Where("id IN ?", ids).Delete(&models.ServiceMonitor{}).Error; err != nil {
+		return err
+	}
+	var affected []string
+	for _, monitor := range monitors {
+		forget(monitor.Id)
+		affected = mergeClients(affected, clientsOf(monitor))
+		if err := metricstore.DeleteServiceSeries(context.Background(), monitor.Name); err != nil {
+			logger.Warnf("servicemonitors", "Failed to delete status history of service %s: %v", monitor.Name, err)
+		}
+	}
+	Push(affected...)
+	return nil
+}
+
+func clientsOf(monitor models.ServiceMonitor) []string {
+	return clientselector.Resolve(monitor.Clients, monitor.Selector)
+}
+
+func mergeClients(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, uuid := range b {
+		if !slices.Contains(out, uuid) {
+			out = append(out, uuid)
+		}
+	}
+	return out
+}
+
+// ForClient 返回适用于客户端的已启用服务监控。
+func ForClient(uuid string) ([]models.ServiceMonitor, error) {
+	var monitors []models.ServiceMonitor
+	if err := dbcore.GetDBInstance().Where("enabled = ?", true).Order("id ASC").Find(&monitors).Error; err != nil {
+		return nil, err
+	}
+	out := monitors[:0]
+	for _, monitor := range monitors {
+		if slices.Contains(clientsOf(monitor), uuid) {
+			out = append(out, monitor)
+		}
+	}
+	return out, nil
+}
+
+// Push 向在线的 v2 客户端下发服务列表；离线客户端在下次连接时由 Sync 下发。
+func Push(uuids ...string) {
+	for _, uuid := range uuids {
+		if !agent.IsAgentOnline(uuid) {
+			continue
+		}
+		if err := Sync(uuid); err != nil {
+			logger.Warnf("servicemonitors", "Failed to push services to %s: %v", uuid, err)
+		}
+	}
+}
+
+// Sync 向客户端下发适用的服务列表，在 v2 Agent 连接（或上报基础信息）时调用。
+func Sync(uuid string) error {
+	if !agent.IsV2Client(uuid) {
+		return nil
+	}
+	monitors, err := ForClient(uuid)
+	if err != nil {
+		return err
+	}
+	params := v2.ServicesParams{Services: make([]v2.ServiceDefinition, 0, len(monitors))}
+	for _, monitor := range monitors {
+		params.Services = append(params.Services, v2.ServiceDefinition{
+			ID: monitor.Id, Name: monitor.Name, Type: monitor.Type, Target: monitor.Target,
+		})
+	}
+	agent.DispatchV2Event(uuid, v2.MethodAgentServices, params)
+	return nil
+}
+
+// Status 是服务在某个客户端上的最近状态。
+type Status struct {
+	ClientUUID string    `json:"client_uuid"`
+	ServiceID  uint      `json:"service_id"`
+	Service    string    `json:"service"`
+	State      string    `json:"state"`
+	Restarts   int       `json:"restarts"`
+	Message    string    `json:"message,omitempty"`
+	Since      time.Time `json:"since"` // 进入当前状态的时间
+	CheckedAt  time.Time `json:"checked_at"`
+
+	restartTimes []time.Time
+	loopNotified bool
+}
+
+// Transition 是一次需要通知的状态变化。
+type Transition struct {
+	ClientUUID string
+	Service    string
+	Event      string // stopped | failed | recovered | restarting
+	Message    string
+}
+
+type statusKey struct {
+	client string
+	id     uint
+}
+
+var (
+	statusMu sync.Mutex
+	statuses = map[statusKey]*Status{}
+)
+
+// Observe 记录 Agent 上报的服务状态：写入 service.up 指标，更新内存中的最近状态，
+// 返回需要通知的状态变化。未定义或不适用于该客户端的服务 ID 会被忽略。
+func Observe(uuid string, reported []v2.ServiceStatus, at time.Time) ([]Transition, error) {
+	monitors, err := ForClient(uuid)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.ServiceMonitor, len(monitors))
+	for _, monitor := range monitors {
+		byID[monitor.Id] = monitor
+	}
+	at = at.UTC()
+	up := make(map[string]bool, len(reported))
+	var transitions []Transition
+	statusMu.Lock()
+	for _, item := range reported {
+		monitor, ok := byID[item.ID]
+		if !ok {
+			continue
+		}
+		state := strings.ToLower(strings.TrimSpace(item.State))
+		if state != StateRunning && state != StateFailed {
+			state = StateStopped
+		}
+		up[monitor.Name] = state == StateRunning
+		key := statusKey{uuid, monitor.Id}
+		next, event := observeStatus(statuses[key], item, state, at)
+		next.ClientUUID, next.ServiceID, next.Service = uuid, monitor.Id, monitor.Name
+		statuses[key] = next
+		if event != "" {
+			transitions = append(transitions, Transition{ClientUUID: uuid, Service: monitor.Name, Event: event, Message: item.Message})
+		}
+	}
+	statusMu.Unlock()
+
+	if err := metricstore.WriteServiceStatus(context.Background(), uuid, at, up); err != nil {
+		return transitions, err
+	}
+	return transitions, nil
+}
+
+// observeStatus 由上一次状态计算新状态与需要通知的事件。首次上报只记录状态。
+func observeStatus(prev *Status, item v2.ServiceStatus, state string, at time.Time) (*Status, string) {
+	next := &Status{State: state, Restarts: item.Restarts, Message: item.Message, Since: at, CheckedAt: at}
+	if prev == nil {
+		return next, ""
+	}
+	if prev.State == state {
+		next.Since = prev.Since
+	}
+	for _, t := range prev.restartTimes {
+		if at.Sub(t) < restartLoopWindow {
+			next.restartTimes = append(next.restartTimes, t)
+		}
+	}
+	next.loopNotified = prev.loopNotified && len(next.restartTimes) > 0
+	// 计数器可能跳变（例如 Agent 重装后从大数值开始），判断反复重启只需最近 restartLoopCount 次。
+	for i := 0; i < min(item.Restarts-prev.Restarts, restartLoopCount); i++ {
+		next.restartTimes = append(next.restartTimes, at)
+	}
+	if extra := len(next.restartTimes) - restartLoopCount; extra > 0 {
+		next.restartTimes = next.restartTimes[extra:]
+	}
+
+	switch {
+	case state == prev.State:
+	case state == StateFailed:
+		return next, "failed"
+	case state == StateStopped:
+		return next, "stopped"
+	case state == StateRunning:
+		return next, "recovered"
+	}
+	if len(next.restartTimes) >= restartLoopCount && !next.loopNotified {
+		next.loopNotified = true
+		return next, "restarting"
+	}
+	return next, ""
+}
+
+// ListStatus 返回服务的最近状态；uuid 为空时返回全部客户端。
+func ListStatus(uuid string) []Status {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	out := make([]Status, 0, len(statuses))
+	for key, status := range statuses {
+		if uuid != "" && key.client != uuid {
+			continue
+		}
+		out = append(out, *status)
+	}
+	slices.SortFunc(out, func(a, b Status) int {
+		if c := strings.Compare(a.ClientUUID, b.ClientUUID); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Service, b.Service)
+	})
+	return out
+}
+
+// forget 清除服务在所有客户端上的最近状态。
+func forget(id uint) {
+	statusMu.Lock()
+	defer statusMu.Unlock()
+	for key := range statuses {
+		if key.id == id {
+			delete(statuses, key)
+		}
+	}
+}
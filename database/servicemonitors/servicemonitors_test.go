@@ -0,0 +1,49 @@
+package servicemonitors
+
+import (
+	"testing"
+	"time"
+
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+func TestObserveStatusTransitions(t *testing.T) {
+	base := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var status *Status
+	for _, step := range []struct {
+		name     string
+		offset   time.Duration
+		state    string
+		restarts int
+		event    string
+	}{
+		{"first report", 0, StateRunning, 0, ""},
+		{"still running", time.Minute, StateRunning, 0, ""},
+		{"failed", 2 * time.Minute, StateFailed, 0, "failed"},
+		{"still failed", 3 * time.Minute, StateFailed, 0, ""},
+		{"recovered", 4 * time.Minute, StateRunning, 1, "recovered"},
+		{"restarted", 5 * time.Minute, StateRunning, 2, ""},
+		{"restart loop", 6 * time.Minute, StateRunning, 3, "restarting"},
+		{"loop already notified", 7 * time.Minute, StateRunning, 4, ""},
+		{"stopped", 8 * time.Minute, StateStopped, 4, "stopped"},
+	} {
+		item := v2.ServiceStatus{State: step.state, Restarts: step.restarts}
+		next, event := observeStatus(status, item, step.state, base.Add(step.offset))
+		if event != step.event {
+			t.Fatalf("%s: event = %q, want %q", step.name, event, step.event)
+		}
+		status = next
+	}
+	if want := base.Add(8 * time.Minute); !status.Since.Equal(want) {
+		t.Fatalf("since = %v, want %v", status.Since, want)
+	}
+}
+
+func TestObserveStatusClampsRestartJump(t *testing.T) {
+	at := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	prev, _ := observeStatus(nil, v2.ServiceStatus{Restarts: 0}, StateRunning, at)
+	next, event := observeStatus(prev, v2.ServiceStatus{Restarts: 1 << 30}, StateRunning, at.Add(time.Minute))
+	if event != "restarting" || len(next.restartTimes) != restartLoopCount {
+		t.Fatalf("event = %q, %d restart times recorded", event, len(next.restartTimes))
+	}
+}
@@ -2,9 +2,11 @@ package tasks
 
 import (
 	"context"
+	"slices"
 	"sort"
 	"time"
 
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/internal/metricstore"
@@ -12,12 +14,14 @@ import (
 	"gorm.io/gorm"
 )
 
-// AddPingTask 创建延迟监测任务。defaultOn 表示新加入的服务器是否自动开启此监测。
-func AddPingTask(clients []string, defaultOn bool, name string, target, task_type string, interval int) (uint, error) {
+// AddPingTask 创建延迟监测任务。selector 为可选的选择器表达式，匹配的服务器在调度时
+// 与 clients 合并；defaultOn 表示新加入的服务器是否自动开启此监测。
+func AddPingTask(clients []string, selector string, defaultOn bool, name string, target, task_type string, interval int) (uint, error) {
 	db := dbcore.GetDBInstance()
 	normalizedClients := normalizePingClients(models.StringArray(clients))
 	task := models.PingTask{
 		Clients:   normalizedClients,
+		Selector:  selector,
 		DefaultOn: defaultOn,
 		Name:      name,
 		Type:      task_type,
@@ -72,6 +76,7 @@ func EditPingTask(tasks []*models.PingTask) error {
 		updates := map[string]interface{}{
 			"name":        task.Name,
 			"clients":     task.Clients,
+			"selector":    task.Selector,
 			"all_clients": task.DefaultOn,
 			"type":        task.Type,
 			"target":      task.Target,
@@ -103,14 +108,20 @@ func GetAllPingTasks() ([]models.PingTask, error) {
 	return tasks, nil
 }
 
-// GetPingTasksByClient 获取指定服务器需要执行的延迟监测任务。
+// GetPingTasksByClient 获取指定服务器需要执行的延迟监测任务，包括选择器匹配该服务器的任务。
 func GetPingTasksByClient(uuid string) []models.PingTask {
 	db := dbcore.GetDBInstance()
 	var tasks []models.PingTask
-	if err := db.Where("clients LIKE ?", `%"`+uuid+`"%`).Order("weight ASC").Order("id ASC").Find(&tasks).Error; err != nil {
+	if err := whereClientsContain(db, uuid).Or("selector <> ''").Order("weight ASC").Order("id ASC").Find(&tasks).Error; err != nil {
 		return nil
 	}
-	return tasks
+	matched := tasks[:0]
+	for _, task := range tasks {
+		if slices.Contains(task.Clients, uuid) || slices.Contains(clientselector.Resolve(nil, task.Selector), uuid) {
+			matched = append(matched, task)
+		}
+	}
+	return matched
 }
 
 func UpdatePingTaskOrder(order map[uint]int) error {
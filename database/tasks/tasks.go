@@ -1,10 +1,14 @@
 package tasks
 
 import (
+	"encoding/json"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
+	"gorm.io/gorm"
 )
 
 func CreateTask(taskId string, clients []string, command string) error {
@@ -43,10 +47,27 @@ func GetTaskByTaskId(taskId string) (*models.Task, error) {
 }
 func GetTasksByClientId(clientId string) ([]models.Task, error) {
 	var tasks []models.Task
-	if err := dbcore.GetDBInstance().Where("clients LIKE ?", "%"+clientId+"%").Find(&tasks).Error; err != nil {
+	if err := whereClientsContain(dbcore.GetDBInstance(), clientId).Find(&tasks).Error; err != nil {
 		return nil, err
 	}
-	return tasks, nil
+	matched := tasks[:0]
+	for _, task := range tasks {
+		if slices.Contains(task.Clients, clientId) {
+			matched = append(matched, task)
+		}
+	}
+	return matched, nil
+}
+
+var likeEscaper = strings.NewReplacer("!", "!!", "%", "!%", "_", "!_")
+
+// whereClientsContain 粗筛 clients（JSON 字符串数组）中含有 uuid 的行。
+// 以 '!' 作为 ESCAPE 字符：SQLite、MySQL、PostgreSQL 的含义一致，且不受
+// MySQL 字符串中反斜杠转义的影响。MySQL 默认排序规则下 LIKE 不区分大小写，
+// 调用方需再按 Clients 精确过滤。
+func whereClientsContain(db *gorm.DB, uuid string) *gorm.DB {
+	encoded, _ := json.Marshal(uuid)
+	return db.Where("clients LIKE ? ESCAPE '!'", "%"+likeEscaper.Replace(string(encoded))+"%")
 }
 
 func GetSpecificTaskResult(taskId, clientId string) (*models.TaskResult, error) {
@@ -86,3 +107,16 @@ func SaveTaskResult(taskId, clientId, result string, exitCode int, timestamp tim
 func ClearTaskResultsByTimeBefore(before time.Time) error {
 	return dbcore.GetDBInstance().Where("created_at < ?", before.UTC()).Delete(&models.TaskResult{}).Error
 }
+
+// FailPendingTaskResult 为尚未完成的任务结果写入失败信息（退出码 -1），已有结果时不做修改。
+func FailPendingTaskResult(taskId, clientId, result string, timestamp time.Time) (bool, error) {
+	res := dbcore.GetDBInstance().
+		Model(&models.TaskResult{}).
+		Where("task_id = ? AND client = ? AND finished_at IS NULL", taskId, clientId).
+		Updates(map[string]interface{}{
+			"result":      result,
+			"exit_code":   -1,
+			"finished_at": timestamp.UTC(),
+		})
+	return res.RowsAffected > 0, res.Error
+}
@@ -118,3 +118,26 @@ func TestGetPingTasksByClientOrdersByWeightThenID(t *testing.T) {
 		t.Fatalf("ping task order = %#v, want ids [%d %d]", ordered, items[1].Id, items[0].Id)
 	}
 }
+
+func TestGetTasksByClientIdMatchesWholeClient(t *testing.T) {
+	flags.DatabaseType = flags.DatabaseTypeSQLite
+	flags.DatabaseFile = "file:task_client_match?mode=memory&cache=shared"
+
+	if err := CreateTask("task-wildcard", []string{"node_1"}, "uptime"); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+	if err := CreateTask("task-prefix", []string{"nodeX1", "node-10"}, "uptime"); err != nil {
+		t.Fatalf("create task: %v", err)
+	}
+
+	matched, err := GetTasksByClientId("node_1")
+	if err != nil {
+		t.Fatalf("get tasks: %v", err)
+	}
+	if len(matched) != 1 || matched[0].TaskId != "task-wildcard" {
+		t.Fatalf("tasks for node_1 = %#v, want only task-wildcard", matched)
+	}
+	if matched, err := GetTasksByClientId("node-1"); err != nil || len(matched) != 0 {
+		t.Fatalf("tasks for node-1 = %#v, %v; want none", matched, err)
+	}
+}
@@ -18,8 +18,11 @@ require (
 	github.com/spf13/cobra v1.9.1
 	github.com/stretchr/testify v1.11.1
 	golang.org/x/sys v0.33.0
-	gorm.io/driver/sqlite v1.5.7
-	gorm.io/gorm v1.26.1
+	gopkg.in/yaml.v3 v3.0.1
+	gorm.io/driver/mysql v1.6.0
+	gorm.io/driver/postgres v1.6.2
+	gorm.io/driver/sqlite v1.6.0
+	gorm.io/gorm v1.31.2
 )
 
 require github.com/dop251/base64dec v0.0.0-20231022112746-c6c9f9a96217 // indirect
@@ -30,7 +33,7 @@ require golang.org/x/crypto v0.39.0
 
 // HTTP Proxy bypass using IPv6 Zone IDs in golang.org/x/net #2
 // golang.org/x/net vulnerable to Cross-site Scripting #4
-require golang.org/x/net v0.41.0 // indirect
+require golang.org/x/net v0.41.0
 
 require (
 	filippo.io/edwards25519 v1.2.0 // indirect
@@ -71,6 +74,5 @@ require (
 	golang.org/x/arch v0.15.0 // indirect
 	golang.org/x/sync v0.19.0
 	golang.org/x/text v0.33.0
-	google.golang.org/protobuf v1.36.6 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
+	google.golang.org/protobuf v1.36.6
 )
@@ -13,7 +13,7 @@ import (
 )
 
 type ConfigItem struct {
-	Key   string `gorm:"primaryKey;column:key;type:text"`
+	Key   string `gorm:"primaryKey;column:key;type:varchar(255)"`
 	Value string `gorm:"column:value;type:text"` // 存 JSON 字符串
 }
 
@@ -36,7 +36,7 @@ func GetAs[T any](key string, defaul ...any) (T, error) {
 	var t T
 	var item ConfigItem
 
-	err := db.First(&item, "key = ?", key).Error
+	err := db.Where(map[string]any{"key": key}).First(&item).Error
 	if err != nil {
 		if len(defaul) > 0 {
 			// 尝试直接类型断言
@@ -83,7 +83,7 @@ func GetMany(keys map[string]any) (map[string]any, error) {
 	if len(keyList) == 0 {
 		return result, nil
 	}
-	if err := db.Where("key IN ?", keyList).Find(&items).Error; err != nil {
+	if err := db.Where(map[string]any{"key": keyList}).Find(&items).Error; err != nil {
 		return nil, err
 	}
 
@@ -177,7 +177,7 @@ func GetManyAs[T any]() (*T, error) {
 	}
 
 	var items []ConfigItem
-	if err := db.Where("key IN ?", keys).Find(&items).Error; err != nil {
+	if err := db.Where(map[string]any{"key": keys}).Find(&items).Error; err != nil {
 		return nil, err
 	}
 
@@ -375,7 +375,7 @@ func Set(key string, value any) error {
 	oldVal := map[string]any{}
 	{
 		var oldItem ConfigItem
-		if err := db.First(&oldItem, "key = ?", key).Error; err == nil {
+		if err := db.Where(map[string]any{"key": key}).First(&oldItem).Error; err == nil {
 			var parsed any
 			if err := json.Unmarshal([]byte(oldItem.Value), &parsed); err == nil {
 				oldVal[key] = parsed
@@ -435,7 +435,7 @@ func SetMany(cst map[string]any) error {
 	oldVal := map[string]any{}
 	if len(keys) > 0 {
 		var oldItems []ConfigItem
-		if err := db.Where("key IN ?", keys).Find(&oldItems).Error; err == nil {
+		if err := db.Where(map[string]any{"key": keys}).Find(&oldItems).Error; err == nil {
 			for _, oi := range oldItems {
 				var parsed any
 				if err := json.Unmarshal([]byte(oi.Value), &parsed); err == nil {
@@ -14,6 +14,7 @@ type Settings struct {
 	PrivateSite            bool   `json:"private_site" default:"false"`                        // 是否为私有站点，默认 false
 	ApiKey                 string `json:"api_key" default:""`                                  // API 密钥，默认空字符串
 	AutoDiscoveryKey       string `json:"auto_discovery_key" default:""`                       // 自动发现密钥
+	FederationKey          string `json:"federation_key" default:""`                           // 联邦只读密钥，父实例凭此拉取节点列表与状态
 	ScriptDomain           string `json:"script_domain" default:""`                            // 自定义脚本域名
 	SendIpAddrToGuest      bool   `json:"send_ip_addr_to_guest" default:"false"`               // 是否向访客页面发送 IP 地址，默认 false
 	VisitorAuditEnabled    bool   `json:"visitor_audit_enabled" default:"false"`               // 是否允许公开访客事件写入审计日志，默认 false
@@ -30,6 +31,17 @@ type Settings struct {
 	CustomHead string `json:"custom_head" default:""`
 	CustomBody string `json:"custom_body" default:""`
 
+	// v2 事件持久化
+	AgentEventOutboxEnabled bool `json:"agent_event_outbox_enabled" default:"false"` // 将轮询 Agent 的待处理事件写入数据库，重启后仍可送达
+	AgentEventOutboxTTL     int  `json:"agent_event_outbox_ttl" default:"3600"`      // 持久化事件的默认有效期（秒）
+
+	// Agent 日志采集
+	AgentLogRetentionHours    int `json:"agent_log_retention_hours" default:"72"`          // 日志保留时间（小时）
+	AgentLogMaxLinesPerClient int `json:"agent_log_max_lines_per_client" default:"100000"` // 每个客户端最多保留的日志行数
+
+	// 文件传输
+	FileTransferMaxSizeMB int `json:"file_transfer_max_size_mb" default:"1024"` // 单次传输的文件大小上限（MiB）
+
 	// 通知
 	NotificationEnabled        bool    `json:"notification_enabled" default:"true"` // 通知总开关
 	NotificationMethod         string  `json:"notification_method" default:"none"`
@@ -38,6 +50,7 @@ type Settings struct {
 	ExpireNotificationLeadDays int     `json:"expire_notification_lead_days" default:"7"`  // 过期前多少天通知，默认7天
 	LoginNotification          bool    `json:"login_notification" default:"true"`          // 登录通知
 	TrafficLimitPercentage     float64 `json:"traffic_limit_percentage" default:"80.00"`   // 流量限制百分比，默认80.00%
+	ContainerNotification      bool    `json:"container_notification" default:"true"`      // 容器异常退出、OOM、反复重启通知
 	UpdatedAt                  time.Time
 }
 
@@ -52,6 +65,7 @@ const (
 	PrivateSiteKey            = "private_site"
 	ApiKeyKey                 = "api_key"
 	AutoDiscoveryKeyKey       = "auto_discovery_key"
+	FederationKeyKey          = "federation_key"
 	ScriptDomainKey           = "script_domain"
 	SendIpAddrToGuestKey      = "send_ip_addr_to_guest"
 	VisitorAuditEnabledKey    = "visitor_audit_enabled"
@@ -72,8 +86,17 @@ const (
 	ExpireNotificationLeadDaysKey = "expire_notification_lead_days"
 	LoginNotificationKey          = "login_notification"
 	TrafficLimitPercentageKey     = "traffic_limit_percentage"
+	ContainerNotificationKey      = "container_notification"
 	UpdatedAtKey                  = "updated_at"
 	XtermjsSettingsKey            = "xtermjs_settings"
 	ThemeMarketSourcesKey         = "theme_market_sources"
 	PluginMarketSourcesKey        = "plugin_market_sources"
+
+	AgentEventOutboxEnabledKey = "agent_event_outbox_enabled"
+	AgentEventOutboxTTLKey     = "agent_event_outbox_ttl"
+
+	AgentLogRetentionHoursKey    = "agent_log_retention_hours"
+	AgentLogMaxLinesPerClientKey = "agent_log_max_lines_per_client"
+
+	FileTransferMaxSizeMBKey = "file_transfer_max_size_mb"
 )
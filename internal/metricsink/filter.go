@@ -0,0 +1,63 @@
+package metricsink
+
+import (
+	"path"
+	"strings"
+)
+
+// nameFilter selects metric names with shell-style patterns such as "cpu.*".
+// An empty include list accepts every metric; exclusions always win.
+type nameFilter struct {
+	include []string
+	exclude []string
+}
+
+func newNameFilter(include, exclude []string) nameFilter {
+	return nameFilter{include: normalizePatterns(include), exclude: normalizePatterns(exclude)}
+}
+
+func normalizePatterns(patterns []string) []string {
+	out := make([]string, 0, len(patterns))
+	for _, pattern := range patterns {
+		pattern = strings.TrimSpace(pattern)
+		if pattern != "" {
+			out = append(out, pattern)
+		}
+	}
+	return out
+}
+
+func (f nameFilter) Match(name string) bool {
+	for _, pattern := range f.exclude {
+		if matchPattern(pattern, name) {
+			return false
+		}
+	}
+	if len(f.include) == 0 {
+		return true
+	}
+	for _, pattern := range f.include {
+		if matchPattern(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchPattern(pattern, name string) bool {
+	if pattern == name {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	return err == nil && ok
+}
+
+// ValidatePatterns reports the first malformed metric name pattern.
+func ValidatePatterns(patterns []string) error {
+	for _, pattern := range normalizePatterns(patterns) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
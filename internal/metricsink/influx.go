@@ -0,0 +1,59 @@
+package metricsink
+
+import (
+	"bytes"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+// influxEncoder renders points as InfluxDB line protocol with nanosecond
+// timestamps. The configured URL must carry the write target, for example
+// http://influx:8086/api/v2/write?org=o&bucket=b or /write?db=komari.
+type influxEncoder struct{}
+
+func (influxEncoder) ContentType() string { return "text/plain; charset=utf-8" }
+
+func (influxEncoder) Headers() map[string]string { return nil }
+
+func (influxEncoder) Encode(points []metric.Point) ([]byte, error) {
+	var buf bytes.Buffer
+	for _, point := range points {
+		writeInfluxLine(&buf, point)
+	}
+	return buf.Bytes(), nil
+}
+
+func writeInfluxLine(buf *bytes.Buffer, point metric.Point) {
+	buf.WriteString(influxMeasurementEscaper.Replace(point.MetricName))
+	buf.WriteString(",client=")
+	buf.WriteString(influxTagEscaper.Replace(point.EntityID))
+	keys := make([]string, 0, len(point.Tags))
+	for key := range point.Tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := point.Tags[key]
+		// Influx rejects empty tag values; they carry no series identity anyway.
+		if key == "" || value == "" || key == "client" {
+			continue
+		}
+		buf.WriteByte(',')
+		buf.WriteString(influxTagEscaper.Replace(key))
+		buf.WriteByte('=')
+		buf.WriteString(influxTagEscaper.Replace(value))
+	}
+	buf.WriteString(" value=")
+	buf.WriteString(strconv.FormatFloat(point.Value, 'g', -1, 64))
+	buf.WriteByte(' ')
+	buf.WriteString(strconv.FormatInt(point.Timestamp.UnixNano(), 10))
+	buf.WriteByte('\n')
+}
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
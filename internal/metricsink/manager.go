@@ -0,0 +1,104 @@
+package metricsink
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/pkg/metric"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+const (
+	observerName   = "metric-sinks"
+	restartTimeout = 10 * time.Second
+)
+
+var (
+	mu    sync.RWMutex
+	sinks = map[uint]*sink{}
+)
+
+// Apply replaces the running sinks with the enabled entries of cfgs. Sinks
+// that are removed or reconfigured flush what they have queued before the
+// replacements start receiving points.
+func Apply(cfgs []models.MetricSink) error {
+	next := make(map[uint]*sink, len(cfgs))
+	var errs []error
+	for _, cfg := range cfgs {
+		if !cfg.Enabled {
+			continue
+		}
+		s, err := newSink(cfg)
+		if err != nil {
+			errs = append(errs, err)
+			logger.Errorf("metricsink", "skip sink %q: %v", cfg.Name, err)
+			continue
+		}
+		next[cfg.Id] = s
+	}
+
+	mu.Lock()
+	previous := sinks
+	sinks = next
+	for _, s := range next {
+		s.start()
+	}
+	mu.Unlock()
+
+	if len(next) > 0 {
+		metricstore.SetPointObserver(observerName, observe)
+	} else {
+		metricstore.SetPointObserver(observerName, nil)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), restartTimeout)
+	defer cancel()
+	stopAll(ctx, previous)
+	return errors.Join(errs...)
+}
+
+// Stop detaches the sinks from the metric store and flushes their queues.
+func Stop(ctx context.Context) error {
+	metricstore.SetPointObserver(observerName, nil)
+	mu.Lock()
+	previous := sinks
+	sinks = map[uint]*sink{}
+	mu.Unlock()
+	return stopAll(ctx, previous)
+}
+
+func stopAll(ctx context.Context, running map[uint]*sink) error {
+	var errs []error
+	for _, s := range running {
+		if err := s.stop(ctx); err != nil {
+			logger.Warnf("metricsink", "flush sink %q on stop: %v", s.cfg.Name, err)
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func observe(points []metric.Point) {
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, s := range sinks {
+		s.offer(points)
+	}
+}
+
+// Statuses returns the delivery counters of every running sink ordered by id.
+func Statuses() []Status {
+	mu.RLock()
+	out := make([]Status, 0, len(sinks))
+	for _, s := range sinks {
+		out = append(out, s.snapshot())
+	}
+	mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
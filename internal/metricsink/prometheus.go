@@ -0,0 +1,131 @@
+package metricsink
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/komari-monitor/komari/pkg/metric"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// prometheusEncoder builds a Prometheus remote-write 1.0 request: a
+// snappy-compressed prompb.WriteRequest protobuf.
+type prometheusEncoder struct{}
+
+func (prometheusEncoder) ContentType() string { return "application/x-protobuf" }
+
+func (prometheusEncoder) Headers() map[string]string {
+	return map[string]string{
+		"Content-Encoding":                  "snappy",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	}
+}
+
+type promSample struct {
+	value     float64
+	timestamp int64
+}
+
+type promSeries struct {
+	labels  [][2]string
+	samples []promSample
+}
+
+func (prometheusEncoder) Encode(points []metric.Point) ([]byte, error) {
+	series := make(map[string]*promSeries)
+	keys := make([]string, 0)
+	for _, point := range points {
+		labels := prometheusLabels(point)
+		key := prometheusSeriesKey(labels)
+		s := series[key]
+		if s == nil {
+			s = &promSeries{labels: labels}
+			series[key] = s
+			keys = append(keys, key)
+		}
+		s.samples = append(s.samples, promSample{value: point.Value, timestamp: point.Timestamp.UnixMilli()})
+	}
+	sort.Strings(keys)
+
+	var request []byte
+	for _, key := range keys {
+		s := series[key]
+		sort.SliceStable(s.samples, func(i, j int) bool { return s.samples[i].timestamp < s.samples[j].timestamp })
+		request = protowire.AppendTag(request, 1, protowire.BytesType)
+		request = protowire.AppendBytes(request, encodePromTimeSeries(s))
+	}
+	return snappy.Encode(nil, request), nil
+}
+
+func encodePromTimeSeries(s *promSeries) []byte {
+	var b []byte
+	for _, label := range s.labels {
+		var l []byte
+		l = protowire.AppendTag(l, 1, protowire.BytesType)
+		l = protowire.AppendString(l, label[0])
+		l = protowire.AppendTag(l, 2, protowire.BytesType)
+		l = protowire.AppendString(l, label[1])
+		b = protowire.AppendTag(b, 1, protowire.BytesType)
+		b = protowire.AppendBytes(b, l)
+	}
+	for _, sample := range s.samples {
+		var v []byte
+		v = protowire.AppendTag(v, 1, protowire.Fixed64Type)
+		v = protowire.AppendFixed64(v, math.Float64bits(sample.value))
+		v = protowire.AppendTag(v, 2, protowire.VarintType)
+		v = protowire.AppendVarint(v, uint64(sample.timestamp))
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, v)
+	}
+	return b
+}
+
+// prometheusLabels maps a point to sorted labels. The metric name becomes
+// komari_<name> with dots replaced, and the entity is exported as "client".
+func prometheusLabels(point metric.Point) [][2]string {
+	labels := make([][2]string, 0, len(point.Tags)+2)
+	labels = append(labels,
+		[2]string{"__name__", "komari_" + sanitizePrometheusName(point.MetricName)},
+		[2]string{"client", point.EntityID},
+	)
+	for key, value := range point.Tags {
+		name := sanitizePrometheusName(key)
+		if name == "__name__" || name == "client" {
+			name = "tag_" + name
+		}
+		labels = append(labels, [2]string{name, value})
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i][0] < labels[j][0] })
+	return labels
+}
+
+func prometheusSeriesKey(labels [][2]string) string {
+	var sb strings.Builder
+	for _, label := range labels {
+		sb.WriteString(label[0])
+		sb.WriteByte(0)
+		sb.WriteString(label[1])
+		sb.WriteByte(0)
+	}
+	return sb.String()
+}
+
+func sanitizePrometheusName(name string) string {
+	var sb strings.Builder
+	for i, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '_':
+			sb.WriteRune(r)
+		case r >= '0' && r <= '9':
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
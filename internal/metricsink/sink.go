@@ -0,0 +1,467 @@
+// Package metricsink forwards metric points accepted by the metric store to
+// external time-series databases. Each configured sink owns a bounded queue
+// and a single sender goroutine, so a slow or unreachable target only drops its
+// own points and never delays the report write path.
+package metricsink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/metric"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender/outboundhttp"
+)
+
+const (
+	TypePrometheus = "prometheus"
+	TypeInfluxDB   = "influxdb"
+
+	defaultQueueSize     = 10000
+	defaultBatchSize     = 500
+	defaultFlushInterval = 10 * time.Second
+	defaultMaxRetries    = 5
+
+	maxQueueSize   = 1000000
+	requestTimeout = 30 * time.Second
+	initialBackoff = time.Second
+	maxBackoff     = 30 * time.Second
+)
+
+// ErrUnsupportedType reports a sink type without an encoder.
+var ErrUnsupportedType = errors.New("unsupported metric sink type")
+
+type encoder interface {
+	ContentType() string
+	Headers() map[string]string
+	Encode(points []metric.Point) ([]byte, error)
+}
+
+func encoderFor(sinkType string) (encoder, error) {
+	switch strings.ToLower(strings.TrimSpace(sinkType)) {
+	case TypePrometheus:
+		return prometheusEncoder{}, nil
+	case TypeInfluxDB:
+		return influxEncoder{}, nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedType, sinkType)
+	}
+}
+
+// Validate checks a sink definition before it is persisted.
+func Validate(cfg models.MetricSink) error {
+	if strings.TrimSpace(cfg.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	if _, err := encoderFor(cfg.Type); err != nil {
+		return err
+	}
+	url := strings.TrimSpace(cfg.URL)
+	if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+		return fmt.Errorf("url must start with http:// or https://")
+	}
+	if _, err := parseHeaders(cfg.Headers); err != nil {
+		return fmt.Errorf("headers must be a JSON object of strings: %w", err)
+	}
+	if cfg.QueueSize < 0 || cfg.QueueSize > maxQueueSize {
+		return fmt.Errorf("queue_size must be between 0 and %d", maxQueueSize)
+	}
+	if cfg.BatchSize < 0 || cfg.FlushInterval < 0 || cfg.MaxRetries < 0 {
+		return fmt.Errorf("batch_size, flush_interval and max_retries must not be negative")
+	}
+	if err := ValidatePatterns(cfg.Include); err != nil {
+		return fmt.Errorf("invalid include pattern: %w", err)
+	}
+	if err := ValidatePatterns(cfg.Exclude); err != nil {
+		return fmt.Errorf("invalid exclude pattern: %w", err)
+	}
+	return nil
+}
+
+func parseHeaders(raw string) (map[string]string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return nil, nil
+	}
+	var headers map[string]string
+	if err := json.Unmarshal([]byte(raw), &headers); err != nil {
+		return nil, err
+	}
+	return headers, nil
+}
+
+// RedactedHeader replaces the value of a sensitive header when a sink is
+// shown to the admin UI. Submitting it back keeps the stored value.
+const RedactedHeader = "<redacted>"
+
+// isSecretHeader reports whether a header likely carries a credential.
+func isSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	switch name {
+	case "authorization", "proxy-authorization", "cookie":
+		return true
+	}
+	for _, hint := range []string{"token", "key", "secret", "auth", "password", "signature"} {
+		if strings.Contains(name, hint) {
+			return true
+		}
+	}
+	return false
+}
+
+// RedactHeaders returns raw with the values of sensitive headers replaced by
+// RedactedHeader. Malformed input is returned as an empty object rather than
+// echoed back.
+func RedactHeaders(raw string) string {
+	headers, err := parseHeaders(raw)
+	if err != nil {
+		return "{}"
+	}
+	if len(headers) == 0 {
+		return raw
+	}
+	for name := range headers {
+		if isSecretHeader(name) {
+			headers[name] = RedactedHeader
+		}
+	}
+	out, _ := json.Marshal(headers)
+	return string(out)
+}
+
+// RestoreHeaders replaces RedactedHeader values in raw with the value of the
+// same header (compared case-insensitively) in stored, so an edited sink keeps
+// credentials the admin UI never saw.
+func RestoreHeaders(raw, stored string) (string, error) {
+	headers, err := parseHeaders(raw)
+	if err != nil || len(headers) == 0 {
+		return raw, err
+	}
+	previous, _ := parseHeaders(stored)
+	restored := false
+	for name, value := range headers {
+		if value != RedactedHeader {
+			continue
+		}
+		found := false
+		for oldName, oldValue := range previous {
+			if strings.EqualFold(oldName, name) {
+				headers[name], found = oldValue, true
+				break
+			}
+		}
+		if !found {
+			return "", fmt.Errorf("header %q has no stored value to keep", name)
+		}
+		restored = true
+	}
+	if !restored {
+		return raw, nil
+	}
+	out, err := json.Marshal(headers)
+	return string(out), err
+}
+
+// Status is a point-in-time view of one sink's delivery counters.
+type Status struct {
+	ID            uint       `json:"id"`
+	Name          string     `json:"name"`
+	Type          string     `json:"type"`
+	Running       bool       `json:"running"`
+	QueueLength   int        `json:"queue_length"`
+	QueueCapacity int        `json:"queue_capacity"`
+	SentPoints    int64      `json:"sent_points"`
+	SentBatches   int64      `json:"sent_batches"`
+	DroppedPoints int64      `json:"dropped_points"`
+	FailedBatches int64      `json:"failed_batches"`
+	Retries       int64      `json:"retries"`
+	LastError     string     `json:"last_error,omitempty"`
+	LastErrorAt   *time.Time `json:"last_error_at,omitempty"`
+	LastSuccessAt *time.Time `json:"last_success_at,omitempty"`
+}
+
+type sink struct {
+	cfg           models.MetricSink
+	filter        nameFilter
+	encoder       encoder
+	headers       map[string]string
+	client        *http.Client
+	queue         chan metric.Point
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	unsent []metric.Point // batch interrupted by stop, set before done closes
+
+	mu     sync.Mutex
+	status Status
+}
+
+func newSink(cfg models.MetricSink) (*sink, error) {
+	enc, err := encoderFor(cfg.Type)
+	if err != nil {
+		return nil, err
+	}
+	headers, err := parseHeaders(cfg.Headers)
+	if err != nil {
+		return nil, fmt.Errorf("parse headers: %w", err)
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	batchSize := cfg.BatchSize
+	if batchSize <= 0 {
+		batchSize = defaultBatchSize
+	}
+	flushInterval := time.Duration(cfg.FlushInterval) * time.Second
+	if flushInterval <= 0 {
+		flushInterval = defaultFlushInterval
+	}
+	maxRetries := cfg.MaxRetries
+	if maxRetries <= 0 {
+		maxRetries = defaultMaxRetries
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &sink{
+		cfg:           cfg,
+		filter:        newNameFilter(cfg.Include, cfg.Exclude),
+		encoder:       enc,
+		headers:       headers,
+		client:        outboundhttp.NewClient(requestTimeout),
+		queue:         make(chan metric.Point, queueSize),
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		maxRetries:    maxRetries,
+		ctx:           ctx,
+		cancel:        cancel,
+		done:          make(chan struct{}),
+		status: Status{
+			ID:            cfg.Id,
+			Name:          cfg.Name,
+			Type:          cfg.Type,
+			QueueCapacity: queueSize,
+		},
+	}, nil
+}
+
+// offer enqueues matching points without blocking; overflow is counted.
+func (s *sink) offer(points []metric.Point) {
+	dropped := int64(0)
+	for _, point := range points {
+		if !s.filter.Match(point.MetricName) {
+			continue
+		}
+		select {
+		case s.queue <- point:
+		default:
+			dropped++
+		}
+	}
+	if dropped > 0 {
+		s.mu.Lock()
+		s.status.DroppedPoints += dropped
+		s.mu.Unlock()
+	}
+}
+
+func (s *sink) start() {
+	s.mu.Lock()
+	s.status.Running = true
+	s.mu.Unlock()
+	go s.run()
+}
+
+// stop cancels retries, then gives the already queued points one final
+// delivery attempt bounded by ctx.
+func (s *sink) stop(ctx context.Context) error {
+	s.cancel()
+	select {
+	case <-s.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	s.mu.Lock()
+	s.status.Running = false
+	s.mu.Unlock()
+
+	pending := append(s.unsent, drain(s.queue, 0)...)
+	s.unsent = nil
+	for len(pending) > 0 {
+		n := min(len(pending), s.batchSize)
+		if err := s.send(ctx, pending[:n]); err != nil {
+			s.recordFailure(int64(len(pending)), err)
+			return err
+		}
+		s.recordSuccess(n)
+		pending = pending[n:]
+	}
+	return nil
+}
+
+func (s *sink) run() {
+	defer close(s.done)
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]metric.Point, 0, s.batchSize)
+	for {
+		select {
+		case <-s.ctx.Done():
+			// Hand unsent points to stop's final flush.
+			s.unsent = batch
+			return
+		case point := <-s.queue:
+			batch = append(batch, point)
+			if len(batch) >= s.batchSize {
+				if !s.deliver(batch) {
+					s.unsent = batch
+					return
+				}
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			if len(batch) > 0 {
+				if !s.deliver(batch) {
+					s.unsent = batch
+					return
+				}
+				batch = batch[:0]
+			}
+		}
+	}
+}
+
+// deliver sends one batch with exponential backoff. Client errors other than
+// 429 are permanent and drop the batch immediately. It returns false when the
+// sink is stopped (e.g. reconfigured) before the batch was settled; the caller
+// then leaves the batch to stop's final flush instead of dropping it.
+func (s *sink) deliver(batch []metric.Point) bool {
+	backoff := initialBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.send(s.ctx, batch)
+		if err == nil {
+			s.recordSuccess(len(batch))
+			return true
+		}
+		if s.ctx.Err() != nil {
+			return false
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= s.maxRetries {
+			s.recordFailure(int64(len(batch)), err)
+			logger.Warnf("metricsink", "sink %q dropped %d points: %v", s.cfg.Name, len(batch), err)
+			return true
+		}
+		s.mu.Lock()
+		s.status.Retries++
+		s.setErrorLocked(err)
+		s.mu.Unlock()
+		select {
+		case <-time.After(backoff):
+		case <-s.ctx.Done():
+			return false
+		}
+		backoff = min(backoff*2, maxBackoff)
+	}
+}
+
+type permanentError struct {
+	status int
+	body   string
+}
+
+func (e *permanentError) Error() string {
+	return fmt.Sprintf("remote rejected batch with HTTP %d: %s", e.status, e.body)
+}
+
+func (s *sink) send(ctx context.Context, batch []metric.Point) error {
+	body, err := s.encoder.Encode(batch)
+	if err != nil {
+		return &permanentError{body: err.Error()}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSpace(s.cfg.URL), bytes.NewReader(body))
+	if err != nil {
+		return &permanentError{body: err.Error()}
+	}
+	req.Header.Set("Content-Type", s.encoder.ContentType())
+	req.Header.Set("User-Agent", "komari-metricsink")
+	for key, value := range s.encoder.Headers() {
+		req.Header.Set(key, value)
+	}
+	for key, value := range s.headers {
+		req.Header.Set(key, value)
+	}
+	if s.cfg.Username != "" || s.cfg.Password != "" {
+		req.SetBasicAuth(s.cfg.Username, s.cfg.Password)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return &permanentError{status: resp.StatusCode, body: strings.TrimSpace(string(snippet))}
+	}
+	return fmt.Errorf("remote returned HTTP %d: %s", resp.StatusCode, strings.TrimSpace(string(snippet)))
+}
+
+func (s *sink) recordSuccess(points int) {
+	now := time.Now().UTC()
+	s.mu.Lock()
+	s.status.SentPoints += int64(points)
+	s.status.SentBatches++
+	s.status.LastSuccessAt = &now
+	s.mu.Unlock()
+}
+
+func (s *sink) recordFailure(points int64, err error) {
+	s.mu.Lock()
+	s.status.FailedBatches++
+	s.status.DroppedPoints += points
+	s.setErrorLocked(err)
+	s.mu.Unlock()
+}
+
+func (s *sink) setErrorLocked(err error) {
+	now := time.Now().UTC()
+	s.status.LastError = err.Error()
+	s.status.LastErrorAt = &now
+}
+
+func (s *sink) snapshot() Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status := s.status
+	status.QueueLength = len(s.queue)
+	return status
+}
+
+func drain(queue chan metric.Point, limit int) []metric.Point {
+	var out []metric.Point
+	for limit <= 0 || len(out) < limit {
+		select {
+		case point := <-queue:
+			out = append(out, point)
+		default:
+			return out
+		}
+	}
+	return out
+}
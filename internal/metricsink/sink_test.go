@@ -0,0 +1,274 @@
+package metricsink
+
+import (
+	"context"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/metric"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func testPoints() []metric.Point {
+	ts := time.Unix(1700000000, 0).UTC()
+	return []metric.Point{
+		{MetricName: "cpu.usage", EntityID: "client-a", Timestamp: ts, Value: 12.5},
+		{MetricName: "ping.latency_ms", EntityID: "client-a", Tags: map[string]string{"task_id": "3"}, Timestamp: ts, Value: 42},
+	}
+}
+
+func TestNameFilter(t *testing.T) {
+	f := newNameFilter([]string{"cpu.*", "ping.*"}, []string{"ping.loss*"})
+	cases := map[string]bool{
+		"cpu.usage":       true,
+		"ping.latency_ms": true,
+		"ping.loss":       false,
+		"ram.used":        false,
+	}
+	for name, want := range cases {
+		if got := f.Match(name); got != want {
+			t.Fatalf("Match(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if !newNameFilter(nil, nil).Match("anything") {
+		t.Fatal("empty filter should match everything")
+	}
+	if err := ValidatePatterns([]string{"cpu.["}); err == nil {
+		t.Fatal("expected malformed pattern error")
+	}
+}
+
+func TestInfluxEncoder(t *testing.T) {
+	body, err := influxEncoder{}.Encode(testPoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "cpu.usage,client=client-a value=12.5 1700000000000000000\n" +
+		"ping.latency_ms,client=client-a,task_id=3 value=42 1700000000000000000\n"
+	if string(body) != want {
+		t.Fatalf("unexpected line protocol:\n%s", body)
+	}
+}
+
+func TestPrometheusEncoder(t *testing.T) {
+	body, err := prometheusEncoder{}.Encode(testPoints())
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := snappy.Decode(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var series []map[string]string
+	var values []float64
+	for len(raw) > 0 {
+		num, typ, n := protowire.ConsumeTag(raw)
+		raw = raw[n:]
+		if num != 1 || typ != protowire.BytesType {
+			t.Fatalf("unexpected field %d/%d", num, typ)
+		}
+		ts, n := protowire.ConsumeBytes(raw)
+		raw = raw[n:]
+		labels := map[string]string{}
+		for len(ts) > 0 {
+			num, _, n := protowire.ConsumeTag(ts)
+			ts = ts[n:]
+			msg, n := protowire.ConsumeBytes(ts)
+			ts = ts[n:]
+			switch num {
+			case 1:
+				_, _, n := protowire.ConsumeTag(msg)
+				name, m := protowire.ConsumeString(msg[n:])
+				msg = msg[n+m:]
+				_, _, n = protowire.ConsumeTag(msg)
+				value, _ := protowire.ConsumeString(msg[n:])
+				labels[name] = value
+			case 2:
+				_, _, n := protowire.ConsumeTag(msg)
+				bits, _ := protowire.ConsumeFixed64(msg[n:])
+				values = append(values, math.Float64frombits(bits))
+			}
+		}
+		series = append(series, labels)
+	}
+	if len(series) != 2 || len(values) != 2 {
+		t.Fatalf("got %d series / %d samples", len(series), len(values))
+	}
+	if series[0]["__name__"] != "komari_cpu_usage" || series[0]["client"] != "client-a" {
+		t.Fatalf("unexpected labels %v", series[0])
+	}
+	if series[1]["__name__"] != "komari_ping_latency_ms" || series[1]["task_id"] != "3" {
+		t.Fatalf("unexpected labels %v", series[1])
+	}
+}
+
+func TestSinkRetriesAndFlushesOnStop(t *testing.T) {
+	var attempts atomic.Int32
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if attempts.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		if user, pass, _ := r.BasicAuth(); user != "u" || pass != "p" || r.Header.Get("X-Scope") != "komari" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s, err := newSink(models.MetricSink{
+		Name: "test", Type: TypeInfluxDB, URL: server.URL, Username: "u", Password: "p",
+		Headers: `{"X-Scope":"komari"}`, BatchSize: 2, FlushInterval: 60, MaxRetries: 3,
+		Include: models.StringArray{"cpu.*", "ping.*"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	s.offer(testPoints())
+	s.offer([]metric.Point{{MetricName: "ram.used", EntityID: "client-a", Timestamp: time.Now(), Value: 1}})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for s.snapshot().SentBatches == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	s.offer(testPoints()[:1])
+	if err := s.stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	status := s.snapshot()
+	if status.SentPoints != 3 || status.Retries != 1 || status.DroppedPoints != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 2 || strings.Contains(strings.Join(received, ""), "ram.used") {
+		t.Fatalf("unexpected bodies %q", received)
+	}
+}
+
+func TestSinkDropsBatchOnClientError(t *testing.T) {
+	var attempts atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	s, err := newSink(models.MetricSink{Name: "bad", Type: TypePrometheus, URL: server.URL, BatchSize: 2, MaxRetries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	s.offer(testPoints())
+	deadline := time.Now().Add(5 * time.Second)
+	for s.snapshot().FailedBatches == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	_ = s.stop(context.Background())
+	status := s.snapshot()
+	if attempts.Load() != 1 || status.FailedBatches != 1 || status.DroppedPoints != 2 || status.LastError == "" {
+		t.Fatalf("unexpected status %+v after %d attempts", status, attempts.Load())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := models.MetricSink{Name: "a", Type: TypePrometheus, URL: "https://example.com/api/v1/write"}
+	if err := Validate(valid); err != nil {
+		t.Fatal(err)
+	}
+	invalid := []models.MetricSink{
+		{Name: "", Type: TypePrometheus, URL: valid.URL},
+		{Name: "a", Type: "graphite", URL: valid.URL},
+		{Name: "a", Type: TypeInfluxDB, URL: "ftp://x"},
+		{Name: "a", Type: TypeInfluxDB, URL: valid.URL, Headers: "[1]"},
+	}
+	for i, cfg := range invalid {
+		if err := Validate(cfg); err == nil {
+			t.Fatalf("case %d: expected error", i)
+		}
+	}
+}
+
+func TestSinkFlushesInterruptedBatchOnStop(t *testing.T) {
+	var healthy atomic.Bool
+	var mu sync.Mutex
+	var received []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		body, _ := io.ReadAll(r.Body)
+		mu.Lock()
+		received = append(received, string(body))
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s, err := newSink(models.MetricSink{Name: "reload", Type: TypeInfluxDB, URL: server.URL, BatchSize: 2, MaxRetries: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.start()
+	s.offer(testPoints())
+	deadline := time.Now().Add(5 * time.Second)
+	for s.snapshot().Retries == 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	// The batch is waiting for a retry when the sink is reconfigured.
+	healthy.Store(true)
+	if err := s.stop(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	status := s.snapshot()
+	if status.SentPoints != 2 || status.DroppedPoints != 0 || status.FailedBatches != 0 {
+		t.Fatalf("unexpected status %+v", status)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 1 || !strings.Contains(received[0], "cpu.usage") {
+		t.Fatalf("unexpected bodies %q", received)
+	}
+}
+
+func TestRedactAndRestoreHeaders(t *testing.T) {
+	stored := `{"Authorization":"Bearer secret","X-Api-Key":"k","X-Scope":"komari"}`
+	redacted := RedactHeaders(stored)
+	if strings.Contains(redacted, "secret") || strings.Contains(redacted, `"k"`) || !strings.Contains(redacted, "komari") {
+		t.Fatalf("RedactHeaders = %s", redacted)
+	}
+	if RedactHeaders("not json") != "{}" {
+		t.Fatal("malformed headers should not be echoed")
+	}
+
+	restored, err := RestoreHeaders(`{"authorization":"<redacted>","X-Api-Key":"new","X-Scope":"other"}`, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	headers, _ := parseHeaders(restored)
+	if headers["authorization"] != "Bearer secret" || headers["X-Api-Key"] != "new" || headers["X-Scope"] != "other" {
+		t.Fatalf("RestoreHeaders = %s", restored)
+	}
+	if _, err := RestoreHeaders(`{"X-Token":"<redacted>"}`, stored); err == nil {
+		t.Fatal("a redacted header without a stored value should be rejected")
+	}
+}
@@ -0,0 +1,103 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+// containerSeriesGrace is how long a container may be missing from an agent's
+// container list before its series are deleted. It covers containers that are
+// briefly recreated under the same ID and agents skipping a collection.
+const containerSeriesGrace = 10 * time.Minute
+
+// containerIDTagLength keeps tags short; Docker shows the same 12-character
+// prefix by default.
+const containerIDTagLength = 12
+
+type containerSeriesState struct {
+	mu       sync.Mutex
+	lastSeen map[string]time.Time
+}
+
+var containerSeriesStates sync.Map
+
+func containerTags(container v1.ContainerReport) map[string]string {
+	id := container.ID
+	if len(id) > containerIDTagLength {
+		id = id[:containerIDTagLength]
+	}
+	tags := map[string]string{"container_id": id}
+	if container.Name != "" {
+		tags["container"] = container.Name
+	}
+	return tags
+}
+
+// ObserveContainers records the containers an agent currently reports and
+// deletes, in the background, the series of containers that have been missing
+// for longer than containerSeriesGrace. Containers removed while the server was
+// down are only seen again by retention cleanup.
+func ObserveContainers(entityID string, containers []v1.ContainerReport, at time.Time) {
+	removed := staleContainers(entityID, containers, at)
+	if len(removed) == 0 {
+		return
+	}
+	go func() {
+		if err := deleteContainerSeries(context.Background(), entityID, removed); err != nil {
+			logger.Errorf("metricstore", "Failed to delete series of removed containers for %s: %v", entityID, err)
+		}
+	}()
+}
+
+// staleContainers updates the last-seen times and returns the tag IDs of
+// containers past the grace period.
+func staleContainers(entityID string, containers []v1.ContainerReport, at time.Time) []string {
+	stateValue, _ := containerSeriesStates.LoadOrStore(entityID, &containerSeriesState{lastSeen: map[string]time.Time{}})
+	state := stateValue.(*containerSeriesState)
+	state.mu.Lock()
+	defer state.mu.Unlock()
+	for _, container := range containers {
+		if container.ID == "" {
+			continue
+		}
+		state.lastSeen[containerTags(container)["container_id"]] = at
+	}
+	var removed []string
+	for id, seen := range state.lastSeen {
+		if at.Sub(seen) > containerSeriesGrace {
+			removed = append(removed, id)
+			delete(state.lastSeen, id)
+		}
+	}
+	return removed
+}
+
+func deleteContainerSeries(ctx context.Context, entityID string, ids []string) error {
+	s := GetStore()
+	if s == nil {
+		return errors.New("metric store not enabled")
+	}
+	var errs []error
+	for _, id := range ids {
+		for _, metricName := range containerRecordMetricNames {
+			if _, err := s.DeleteSeries(ctx, metric.Query{
+				MetricName: metricName,
+				EntityID:   entityID,
+				Tags:       map[string]string{"container_id": id},
+			}); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func deleteContainerSeriesState(entityID string) {
+	containerSeriesStates.Delete(entityID)
+}
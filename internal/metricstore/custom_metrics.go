@@ -0,0 +1,267 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+// 外部推送（InfluxDB line protocol / OTLP 等）的自定义指标在首次写入时自动
+// 创建定义，定义的 Metadata 中 origin=custom 用于与内置指标区分；内置或管理员
+// 手动创建的同名指标不允许被外部推送写入。
+
+const (
+	// CustomMetricOriginKey / CustomMetricOrigin 标记自动创建的自定义指标定义。
+	CustomMetricOriginKey = "origin"
+	CustomMetricOrigin    = "custom"
+	// CustomMetricSourceKey 记录创建该指标的推送协议，例如 line_protocol。
+	CustomMetricSourceKey = "source"
+
+	maxCustomMetricNameLength = 191
+	maxCustomWriteErrors      = 20
+)
+
+// CustomMetricConfig 自定义指标的数量与基数限制。
+type CustomMetricConfig struct {
+	MaxMetrics         int `json:"custom_metric_max_metrics" default:"200"`       // 自定义指标定义总数上限
+	MaxSeriesPerMetric int `json:"custom_metric_max_series" default:"1000"`       // 单个指标的序列（实体+标签组合）上限
+	RetentionDays      int `json:"custom_metric_retention_days" default:"7"`      // 自动创建定义的保留天数
+	MaxTagsPerPoint    int `json:"custom_metric_max_tags_per_point" default:"16"` // 单个点的标签数量上限
+}
+
+var (
+	ErrCustomMetricReserved = errors.New("metric name is reserved by a built-in metric")
+	ErrCustomMetricLimit    = errors.New("custom metric limit reached")
+	ErrCustomSeriesLimit    = errors.New("series cardinality limit reached")
+)
+
+// CustomPoint 是一条外部推送的采样；Type/Unit 仅在首次创建定义时使用。
+type CustomPoint struct {
+	metric.Point
+	Type metric.MetricType
+	Unit string
+}
+
+// CustomWriteResult 汇总一次自定义指标写入的结果。
+type CustomWriteResult struct {
+	Accepted int      `json:"accepted"`
+	Rejected int      `json:"rejected"`
+	Errors   []string `json:"errors,omitempty"`
+}
+
+func (r *CustomWriteResult) reject(err error) {
+	r.Rejected++
+	if len(r.Errors) >= maxCustomWriteErrors {
+		return
+	}
+	msg := err.Error()
+	for _, existing := range r.Errors {
+		if existing == msg {
+			return
+		}
+	}
+	r.Errors = append(r.Errors, msg)
+}
+
+type customMetricState struct {
+	reserved bool
+	series   map[string]struct{}
+}
+
+// customMetricCache 缓存当前 store 的自定义指标定义与已知序列，store 切换时重建。
+var customMetricCache struct {
+	mu      sync.Mutex
+	store   *metric.Store
+	loaded  bool
+	count   int
+	metrics map[string]*customMetricState
+}
+
+// ResetCustomMetricCache 丢弃缓存，指标定义被删除或修改后调用。
+func ResetCustomMetricCache() {
+	customMetricCache.mu.Lock()
+	defer customMetricCache.mu.Unlock()
+	customMetricCache.store = nil
+	customMetricCache.loaded = false
+	customMetricCache.metrics = nil
+}
+
+// ValidateCustomMetricName 检查外部推送的指标名称。
+func ValidateCustomMetricName(name string) error {
+	if name == "" {
+		return fmt.Errorf("metric name is empty")
+	}
+	if len(name) > maxCustomMetricNameLength {
+		return fmt.Errorf("metric name %q is longer than %d bytes", name[:32]+"...", maxCustomMetricNameLength)
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_', r == '.', r == '-', r == ':':
+		default:
+			return fmt.Errorf("metric name %q contains unsupported character %q", name, r)
+		}
+	}
+	return nil
+}
+
+// WriteCustomPoints 写入外部推送的自定义指标，按需创建定义并执行基数限制。
+// 被拒绝的点不会导致整批失败，只记录在返回结果中。
+func WriteCustomPoints(ctx context.Context, source string, points []CustomPoint) (CustomWriteResult, error) {
+	var result CustomWriteResult
+	if len(points) == 0 {
+		return result, nil
+	}
+	cfg, err := config.GetManyAs[CustomMetricConfig]()
+	if err != nil {
+		return result, fmt.Errorf("load custom metric config: %w", err)
+	}
+	if err := storeOperations.AcquireShared(ctx); err != nil {
+		return result, fmt.Errorf("wait for metric store operation before writing custom metrics: %w", err)
+	}
+	defer storeOperations.ReleaseShared()
+
+	s := GetStore()
+	if s == nil {
+		return result, fmt.Errorf("metric store not enabled")
+	}
+
+	accepted, err := admitCustomPoints(ctx, s, cfg, source, points, &result)
+	if err != nil {
+		return result, err
+	}
+	if len(accepted) == 0 {
+		return result, nil
+	}
+	if err := s.WriteBatch(ctx, accepted); err != nil {
+		// 定义可能已被管理员删除，丢弃缓存以便下次重新创建。
+		ResetCustomMetricCache()
+		return result, err
+	}
+	result.Accepted = len(accepted)
+	notifyPointObservers(accepted)
+	return result, nil
+}
+
+func admitCustomPoints(ctx context.Context, s *metric.Store, cfg *CustomMetricConfig, source string, points []CustomPoint, result *CustomWriteResult) ([]metric.Point, error) {
+	c := &customMetricCache
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.store != s || !c.loaded {
+		if err := loadCustomMetricCacheLocked(ctx, s); err != nil {
+			return nil, err
+		}
+	}
+
+	accepted := make([]metric.Point, 0, len(points))
+	for _, point := range points {
+		if err := ValidateCustomMetricName(point.MetricName); err != nil {
+			result.reject(err)
+			continue
+		}
+		if cfg.MaxTagsPerPoint > 0 && len(point.Tags) > cfg.MaxTagsPerPoint {
+			result.reject(fmt.Errorf("metric %s: more than %d tags", point.MetricName, cfg.MaxTagsPerPoint))
+			continue
+		}
+		state, err := customMetricStateLocked(ctx, s, cfg, source, point)
+		if err != nil {
+			if errors.Is(err, ErrCustomMetricReserved) || errors.Is(err, ErrCustomMetricLimit) || errors.Is(err, metric.ErrInvalidArgument) {
+				result.reject(fmt.Errorf("metric %s: %w", point.MetricName, err))
+				continue
+			}
+			return nil, err
+		}
+		key, err := metric.SeriesKey(point.EntityID, point.Tags)
+		if err != nil {
+			result.reject(fmt.Errorf("metric %s: %w", point.MetricName, err))
+			continue
+		}
+		if _, ok := state.series[key]; !ok {
+			if cfg.MaxSeriesPerMetric > 0 && len(state.series) >= cfg.MaxSeriesPerMetric {
+				result.reject(fmt.Errorf("metric %s: %w (%d)", point.MetricName, ErrCustomSeriesLimit, cfg.MaxSeriesPerMetric))
+				continue
+			}
+			state.series[key] = struct{}{}
+		}
+		accepted = append(accepted, point.Point)
+	}
+	return accepted, nil
+}
+
+func loadCustomMetricCacheLocked(ctx context.Context, s *metric.Store) error {
+	defs, err := s.ListMetrics(ctx)
+	if err != nil {
+		return fmt.Errorf("list metric definitions: %w", err)
+	}
+	c := &customMetricCache
+	c.store = s
+	c.loaded = true
+	c.count = 0
+	c.metrics = make(map[string]*customMetricState, len(defs))
+	for _, def := range defs {
+		if def.Metadata[CustomMetricOriginKey] == CustomMetricOrigin {
+			c.count++
+		}
+	}
+	return nil
+}
+
+// customMetricStateLocked 返回指标的缓存状态，必要时创建定义并加载已有序列。
+func customMetricStateLocked(ctx context.Context, s *metric.Store, cfg *CustomMetricConfig, source string, point CustomPoint) (*customMetricState, error) {
+	c := &customMetricCache
+	if state, ok := c.metrics[point.MetricName]; ok {
+		if state.reserved {
+			return nil, ErrCustomMetricReserved
+		}
+		return state, nil
+	}
+
+	def, err := s.GetMetric(ctx, point.MetricName)
+	switch {
+	case err == nil:
+		if def.Metadata[CustomMetricOriginKey] != CustomMetricOrigin {
+			c.metrics[point.MetricName] = &customMetricState{reserved: true}
+			return nil, ErrCustomMetricReserved
+		}
+	case errors.Is(err, metric.ErrNotFound):
+		if cfg.MaxMetrics > 0 && c.count >= cfg.MaxMetrics {
+			return nil, fmt.Errorf("%w (%d)", ErrCustomMetricLimit, cfg.MaxMetrics)
+		}
+		retention := cfg.RetentionDays
+		if retention < defaultBuiltinMetricRetentionDays {
+			retention = defaultBuiltinMetricRetentionDays
+		}
+		def = metric.Definition{
+			Name:          point.MetricName,
+			Type:          point.Type,
+			Unit:          point.Unit,
+			Description:   "Custom metric pushed via " + source,
+			RetentionDays: retention,
+			Metadata: map[string]string{
+				CustomMetricOriginKey: CustomMetricOrigin,
+				CustomMetricSourceKey: source,
+			},
+		}
+		if err := s.CreateMetric(ctx, def); err != nil && !errors.Is(err, metric.ErrAlreadyExists) {
+			return nil, fmt.Errorf("create custom metric %s: %w", point.MetricName, err)
+		}
+		c.count++
+	default:
+		return nil, fmt.Errorf("get metric %s: %w", point.MetricName, err)
+	}
+
+	keys, err := s.SeriesKeys(ctx, point.MetricName)
+	if err != nil {
+		return nil, fmt.Errorf("list series of %s: %w", point.MetricName, err)
+	}
+	state := &customMetricState{series: make(map[string]struct{}, len(keys))}
+	for _, key := range keys {
+		state.series[key] = struct{}{}
+	}
+	c.metrics[point.MetricName] = state
+	return state, nil
+}
@@ -0,0 +1,96 @@
+package metricstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/metric"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func useCustomMetricTestConfig(t *testing.T, values map[string]any) {
+	t.Helper()
+	configDB, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open config db: %v", err)
+	}
+	config.SetDb(configDB)
+	if err := config.SetMany(values); err != nil {
+		t.Fatalf("save config: %v", err)
+	}
+	ResetCustomMetricCache()
+	t.Cleanup(ResetCustomMetricCache)
+}
+
+func customPoint(name, entity string, tags map[string]string, value float64) CustomPoint {
+	return CustomPoint{Point: metric.Point{MetricName: name, EntityID: entity, Tags: tags, Timestamp: time.Now().UTC(), Value: value}}
+}
+
+func TestWriteCustomPointsCreatesDefinitions(t *testing.T) {
+	s := useReportTestStore(t, nil)
+	useCustomMetricTestConfig(t, map[string]any{"custom_metric_retention_days": 3})
+	ctx := context.Background()
+
+	point := customPoint("nginx.requests", "client-a", map[string]string{"host": "web1"}, 12)
+	point.Type = metric.TypeCounter
+	result, err := WriteCustomPoints(ctx, "line_protocol", []CustomPoint{point, customPoint(MetricCPU, "client-a", nil, 1)})
+	if err != nil {
+		t.Fatalf("write custom points: %v", err)
+	}
+	if result.Accepted != 1 || result.Rejected != 1 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	def, err := s.GetMetric(ctx, "nginx.requests")
+	if err != nil {
+		t.Fatalf("get created definition: %v", err)
+	}
+	if def.Type != metric.TypeCounter || def.RetentionDays != 3 || def.Metadata[CustomMetricOriginKey] != CustomMetricOrigin || def.Metadata[CustomMetricSourceKey] != "line_protocol" {
+		t.Fatalf("unexpected definition %+v", def)
+	}
+	points, err := s.Latest(ctx, "nginx.requests", "client-a", 1)
+	if err != nil || len(points) != 1 || points[0].Value != 12 {
+		t.Fatalf("latest = %+v, %v", points, err)
+	}
+}
+
+func TestWriteCustomPointsEnforcesLimits(t *testing.T) {
+	useReportTestStore(t, nil)
+	useCustomMetricTestConfig(t, map[string]any{
+		"custom_metric_max_metrics": 1,
+		"custom_metric_max_series":  2,
+	})
+	ctx := context.Background()
+
+	points := []CustomPoint{
+		customPoint("app.latency", "client-a", map[string]string{"path": "/a"}, 1),
+		customPoint("app.latency", "client-a", map[string]string{"path": "/b"}, 1),
+		customPoint("app.latency", "client-a", map[string]string{"path": "/a"}, 2),
+		customPoint("app.latency", "client-a", map[string]string{"path": "/c"}, 1),
+		customPoint("app.errors", "client-a", nil, 1),
+		customPoint("bad name", "client-a", nil, 1),
+	}
+	result, err := WriteCustomPoints(ctx, "line_protocol", points)
+	if err != nil {
+		t.Fatalf("write custom points: %v", err)
+	}
+	if result.Accepted != 3 || result.Rejected != 3 || len(result.Errors) != 3 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+
+	// The series set is rebuilt from the store, so limits survive a cache reset.
+	ResetCustomMetricCache()
+	result, err = WriteCustomPoints(ctx, "line_protocol", []CustomPoint{
+		customPoint("app.latency", "client-a", map[string]string{"path": "/b"}, 3),
+		customPoint("app.latency", "client-b", nil, 3),
+	})
+	if err != nil {
+		t.Fatalf("write custom points after reset: %v", err)
+	}
+	if result.Accepted != 1 || result.Rejected != 1 {
+		t.Fatalf("unexpected result after reset %+v", result)
+	}
+}
@@ -81,6 +81,30 @@ func createMetricDefinitionsWithDefaultRetention(ctx context.Context, s *metric.
 		{Name: MetricProcess, Type: metric.TypeGauge, Unit: "count", Description: "Process count", RetentionDays: defaultRetentionDays},
 		{Name: MetricConnections, Type: metric.TypeGauge, Unit: "count", Description: "TCP connections", RetentionDays: defaultRetentionDays},
 		{Name: MetricConnectionsUDP, Type: metric.TypeGauge, Unit: "count", Description: "UDP connections", RetentionDays: defaultRetentionDays},
+		{Name: MetricMountUsed, Type: metric.TypeGauge, Unit: "bytes", Description: "Per-mount disk used", RetentionDays: defaultRetentionDays},
+		{Name: MetricMountTotal, Type: metric.TypeGauge, Unit: "bytes", Description: "Per-mount disk total", RetentionDays: defaultRetentionDays},
+		{Name: MetricMountUsage, Type: metric.TypeGauge, Unit: "%", Description: "Per-mount disk usage percentage", RetentionDays: defaultRetentionDays},
+		{Name: MetricMountInodes, Type: metric.TypeGauge, Unit: "%", Description: "Per-mount inode usage percentage", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceRxRate, Type: metric.TypeGauge, Unit: "bytes/s", Description: "Per-interface receive rate", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceTxRate, Type: metric.TypeGauge, Unit: "bytes/s", Description: "Per-interface transmit rate", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceRxBytes, Type: metric.TypeCounter, Unit: "bytes", Description: "Per-interface total received", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceTxBytes, Type: metric.TypeCounter, Unit: "bytes", Description: "Per-interface total transmitted", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceRxErrors, Type: metric.TypeCounter, Unit: "count", Description: "Per-interface receive errors", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceTxErrors, Type: metric.TypeCounter, Unit: "count", Description: "Per-interface transmit errors", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceRxDrops, Type: metric.TypeCounter, Unit: "count", Description: "Per-interface receive drops", RetentionDays: defaultRetentionDays},
+		{Name: MetricIfaceTxDrops, Type: metric.TypeCounter, Unit: "count", Description: "Per-interface transmit drops", RetentionDays: defaultRetentionDays},
+		{Name: MetricDiskReadRate, Type: metric.TypeGauge, Unit: "bytes/s", Description: "Per-device disk read rate", RetentionDays: defaultRetentionDays},
+		{Name: MetricDiskWriteRate, Type: metric.TypeGauge, Unit: "bytes/s", Description: "Per-device disk write rate", RetentionDays: defaultRetentionDays},
+		{Name: MetricDiskReadIOPS, Type: metric.TypeGauge, Unit: "ops/s", Description: "Per-device disk read operations", RetentionDays: defaultRetentionDays},
+		{Name: MetricDiskWriteIOPS, Type: metric.TypeGauge, Unit: "ops/s", Description: "Per-device disk write operations", RetentionDays: defaultRetentionDays},
+		{Name: MetricDiskUtil, Type: metric.TypeGauge, Unit: "%", Description: "Per-device disk busy percentage", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerCPU, Type: metric.TypeGauge, Unit: "%", Description: "Per-container CPU usage percentage", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerMem, Type: metric.TypeGauge, Unit: "bytes", Description: "Per-container memory used", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerLimit, Type: metric.TypeGauge, Unit: "bytes", Description: "Per-container memory limit", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerRx, Type: metric.TypeCounter, Unit: "bytes", Description: "Per-container total received", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerTx, Type: metric.TypeCounter, Unit: "bytes", Description: "Per-container total transmitted", RetentionDays: defaultRetentionDays},
+		{Name: MetricContainerRuns, Type: metric.TypeCounter, Unit: "count", Description: "Per-container restart count", RetentionDays: defaultRetentionDays},
+		{Name: MetricServiceUp, Type: metric.TypeGauge, Unit: "", Description: "Monitored service is running (1) or down (0)", RetentionDays: defaultRetentionDays},
 		{Name: MetricPingLatency, Type: metric.TypeGauge, Unit: "ms", Description: "Ping latency", RetentionDays: defaultRetentionDays},
 		{Name: MetricPingLoss, Type: metric.TypeGauge, Unit: "ratio", Description: "Ping packet loss indicator", RetentionDays: defaultRetentionDays},
 	}
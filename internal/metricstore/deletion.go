@@ -75,6 +75,7 @@ func DeleteEntity(ctx context.Context, entityID string) error {
 		return fmt.Errorf("failed to delete metric records for entity %s: %w", entityID, err)
 	}
 	deleteReportTrafficState(entityID)
+	deleteContainerSeriesState(entityID)
 	return nil
 }
 
@@ -0,0 +1,80 @@
+package metricstore
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+type deviceNotificationMetric struct {
+	metricName string
+	tagKey     string
+	scale      float64
+}
+
+// deviceNotificationMetrics maps load notification metric names to tagged
+// device metrics. Rates are converted to the units shown in notifications.
+var deviceNotificationMetrics = map[string]deviceNotificationMetric{
+	"mount_usage": {metricName: MetricMountUsage, tagKey: "mount", scale: 1},
+	"inode_usage": {metricName: MetricMountInodes, tagKey: "mount", scale: 1},
+	"iface_in":    {metricName: MetricIfaceRxRate, tagKey: "iface", scale: 8.0 / 1_000_000},
+	"iface_out":   {metricName: MetricIfaceTxRate, tagKey: "iface", scale: 8.0 / 1_000_000},
+	"disk_read":   {metricName: MetricDiskReadRate, tagKey: "device", scale: 1.0 / 1_000_000},
+	"disk_write":  {metricName: MetricDiskWriteRate, tagKey: "device", scale: 1.0 / 1_000_000},
+	"disk_util":   {metricName: MetricDiskUtil, tagKey: "device", scale: 1},
+}
+
+// parseDeviceNotificationMetric splits "name" or "name:tag value". Without a
+// tag value every mount, interface or device of the client is considered.
+func parseDeviceNotificationMetric(name string) (deviceNotificationMetric, string, bool) {
+	base, tagValue, _ := strings.Cut(name, ":")
+	def, ok := deviceNotificationMetrics[base]
+	return def, tagValue, ok
+}
+
+// IsDeviceNotificationMetric reports whether a load notification metric reads
+// per-mount, per-interface or per-device series.
+func IsDeviceNotificationMetric(name string) bool {
+	_, _, ok := parseDeviceNotificationMetric(name)
+	return ok
+}
+
+// GetDeviceMetricMaxByClientAndTime returns the per-bucket maximum of a device
+// notification metric across the matching mounts, interfaces or devices.
+func GetDeviceMetricMaxByClientAndTime(ctx context.Context, clientUUID, name string, start, end time.Time) ([]float64, error) {
+	s := GetStore()
+	if s == nil {
+		return nil, fmt.Errorf("metric store not enabled")
+	}
+	def, tagValue, ok := parseDeviceNotificationMetric(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported device metric %q", name)
+	}
+	query := metric.Query{
+		MetricName: def.metricName,
+		EntityID:   clientUUID,
+		Start:      start,
+		End:        end,
+		Order:      metric.OrderAsc,
+	}
+	if tagValue != "" {
+		query.Tags = map[string]string{def.tagKey: tagValue}
+	}
+	now := time.Now().UTC()
+	points, err := s.Series(ctx, metric.AggregateQuery{
+		Query:       query,
+		Aggregation: metric.AggMax,
+		Interval:    recordSeriesInterval(s, start, end, now),
+	}, now)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query metric %s: %w", def.metricName, err)
+	}
+	values := make([]float64, 0, len(points))
+	for _, point := range points {
+		values = append(values, point.Value*def.scale)
+	}
+	return values, nil
+}
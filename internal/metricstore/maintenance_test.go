@@ -3,6 +3,7 @@ package metricstore
 import (
 	"context"
 	"errors"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -194,3 +195,27 @@ func installTestStore(t *testing.T, s *metric.Store) {
 		_ = s.Close()
 	})
 }
+
+func TestSnapshotSQLiteWritesConsistentCopy(t *testing.T) {
+	ctx := context.Background()
+	s, err := metric.Open(ctx, metric.SQLite(filepath.Join(t.TempDir(), "metrics.db")))
+	if err != nil {
+		t.Fatalf("open metric store: %v", err)
+	}
+	installTestStore(t, s)
+
+	dest := filepath.Join(t.TempDir(), "snapshot", "metrics.db")
+	for i := 0; i < 2; i++ { // the second run must replace the first snapshot
+		if err := SnapshotSQLite(ctx, dest); err != nil {
+			t.Fatalf("snapshot %d: %v", i, err)
+		}
+	}
+	copied, err := metric.Open(ctx, metric.SQLite(dest))
+	if err != nil {
+		t.Fatalf("open snapshot: %v", err)
+	}
+	defer copied.Close()
+	if _, err := copied.ListMetrics(ctx); err != nil {
+		t.Fatalf("list metrics from snapshot: %v", err)
+	}
+}
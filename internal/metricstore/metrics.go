@@ -20,6 +20,30 @@ const (
 	MetricProcess        = "process.count"
 	MetricConnections    = "connections.tcp"
 	MetricConnectionsUDP = "connections.udp"
+	MetricMountUsed      = "mount.used"
+	MetricMountTotal     = "mount.total"
+	MetricMountUsage     = "mount.usage"
+	MetricMountInodes    = "mount.inodes.usage"
+	MetricIfaceRxRate    = "iface.rx.rate"
+	MetricIfaceTxRate    = "iface.tx.rate"
+	MetricIfaceRxBytes   = "iface.rx.bytes"
+	MetricIfaceTxBytes   = "iface.tx.bytes"
+	MetricIfaceRxErrors  = "iface.rx.errors"
+	MetricIfaceTxErrors  = "iface.tx.errors"
+	MetricIfaceRxDrops   = "iface.rx.drops"
+	MetricIfaceTxDrops   = "iface.tx.drops"
+	MetricDiskReadRate   = "diskio.read.rate"
+	MetricDiskWriteRate  = "diskio.write.rate"
+	MetricDiskReadIOPS   = "diskio.read.iops"
+	MetricDiskWriteIOPS  = "diskio.write.iops"
+	MetricDiskUtil       = "diskio.utilization"
+	MetricContainerCPU   = "container.cpu.usage"
+	MetricContainerMem   = "container.memory.used"
+	MetricContainerLimit = "container.memory.limit"
+	MetricContainerRx    = "container.net.rx"
+	MetricContainerTx    = "container.net.tx"
+	MetricContainerRuns  = "container.restarts"
+	MetricServiceUp      = "service.up"
 	MetricPingLatency    = "ping.latency_ms"
 	MetricPingLoss       = "ping.loss"
 )
@@ -38,7 +62,25 @@ var gpuDeviceRecordMetricNames = []string{
 	MetricGPUDeviceUsage, MetricGPUMem, MetricGPUMemTotal, MetricGPUTemp,
 }
 
-var recordMetricNames = joinMetricNames(loadRecordMetricNames, gpuDeviceRecordMetricNames)
+// deviceRecordMetricNames are per-mount, per-interface and per-block-device
+// details tagged with mount, iface or device.
+var deviceRecordMetricNames = []string{
+	MetricMountUsed, MetricMountTotal, MetricMountUsage, MetricMountInodes,
+	MetricIfaceRxRate, MetricIfaceTxRate, MetricIfaceRxBytes, MetricIfaceTxBytes,
+	MetricIfaceRxErrors, MetricIfaceTxErrors, MetricIfaceRxDrops, MetricIfaceTxDrops,
+	MetricDiskReadRate, MetricDiskWriteRate, MetricDiskReadIOPS, MetricDiskWriteIOPS, MetricDiskUtil,
+}
+
+// containerRecordMetricNames are per-container series tagged with
+// container_id and container.
+var containerRecordMetricNames = []string{
+	MetricContainerCPU, MetricContainerMem, MetricContainerLimit, MetricContainerRx, MetricContainerTx, MetricContainerRuns,
+}
+
+// serviceRecordMetricNames hold agent-side service liveness tagged with service.
+var serviceRecordMetricNames = []string{MetricServiceUp}
+
+var recordMetricNames = joinMetricNames(loadRecordMetricNames, gpuDeviceRecordMetricNames, deviceRecordMetricNames, containerRecordMetricNames, serviceRecordMetricNames)
 
 // Ping has an independent retention and cleanup boundary.
 var pingMetricNames = []string{MetricPingLatency, MetricPingLoss}
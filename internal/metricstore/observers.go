@@ -0,0 +1,60 @@
+package metricstore
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+// PointObserver receives points after the metric store accepted them. Observers
+// run on the writer goroutine, so they must hand points off without blocking.
+type PointObserver func(points []metric.Point)
+
+var (
+	pointObserversMu sync.RWMutex
+	pointObservers   = make(map[string]PointObserver)
+)
+
+// SetPointObserver registers or replaces a named observer of the report and
+// ping write path. A nil observer removes the registration.
+func SetPointObserver(name string, observer PointObserver) {
+	pointObserversMu.Lock()
+	defer pointObserversMu.Unlock()
+	if observer == nil {
+		delete(pointObservers, name)
+		return
+	}
+	pointObservers[name] = observer
+}
+
+// notifyPointObservers forwards a successfully written batch. A panicking
+// observer is logged and never fails the write that already committed.
+func notifyPointObservers(points []metric.Point) {
+	if len(points) == 0 {
+		return
+	}
+	pointObserversMu.RLock()
+	names := make([]string, 0, len(pointObservers))
+	for name := range pointObservers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	observers := make([]PointObserver, 0, len(names))
+	for _, name := range names {
+		observers = append(observers, pointObservers[name])
+	}
+	pointObserversMu.RUnlock()
+
+	for i, observer := range observers {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Errorf("metricstore", "point observer %q panicked: %v", names[i], r)
+				}
+			}()
+			observer(points)
+		}()
+	}
+}
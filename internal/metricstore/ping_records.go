@@ -59,7 +59,11 @@ func writePingRecords(ctx context.Context, records []models.PingRecord) error {
 			},
 		)
 	}
-	return s.WriteBatch(ctx, points)
+	if err := s.WriteBatch(ctx, points); err != nil {
+		return err
+	}
+	notifyPointObservers(points)
+	return nil
 }
 
 func GetPingRecords(ctx context.Context, clientUUID string, taskID int, start, end time.Time) ([]models.PingRecord, error) {
@@ -0,0 +1,141 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+)
+
+// WriteBackfillReports persists reports an agent buffered while it could not
+// reach the server. Unlike WriteReport, each report keeps its own UpdatedAt;
+// reports must belong to one client and be strictly increasing in time.
+//
+// Traffic deltas are computed between consecutive counters. Backfilled points
+// newer than the live traffic state advance it, so the next live report only
+// counts traffic after the last buffered point. Points at or before the live
+// state arrived after live reporting resumed: the first live report already
+// counted the whole outage, so their deltas are written as zero instead of
+// counting the same traffic twice.
+func WriteBackfillReports(ctx context.Context, reports []v1.Report) ([]v1.Report, error) {
+	if len(reports) == 0 {
+		return nil, nil
+	}
+	uuid := reports[0].UUID
+	if uuid == "" {
+		return nil, fmt.Errorf("report UUID is required")
+	}
+	for i, report := range reports {
+		if report.UUID != uuid {
+			return nil, fmt.Errorf("backfilled reports must belong to one client")
+		}
+		if report.UpdatedAt.IsZero() {
+			return nil, fmt.Errorf("backfilled report %d has no timestamp", i)
+		}
+		if i > 0 && !report.UpdatedAt.After(reports[i-1].UpdatedAt) {
+			return nil, fmt.Errorf("backfilled report timestamps must be strictly increasing")
+		}
+	}
+	if GetStore() == nil {
+		return nil, fmt.Errorf("metric store not enabled")
+	}
+	// Queued live reports update the traffic state when written; flush them
+	// first so the backfill sees where live accounting currently stands.
+	if err := FlushReportBatch(ctx); err != nil && !errors.Is(err, ErrReportBatchStopped) {
+		return nil, err
+	}
+
+	if err := storeOperations.AcquireShared(ctx); err != nil {
+		return nil, fmt.Errorf("wait for metric store operation before backfilling reports: %w", err)
+	}
+	defer storeOperations.ReleaseShared()
+	s := GetStore()
+	if s == nil {
+		return nil, fmt.Errorf("metric store not enabled")
+	}
+
+	stateValue, _ := reportTrafficStates.LoadOrStore(uuid, &reportTrafficState{})
+	state := stateValue.(*reportTrafficState)
+	state.mu.Lock()
+	values := state.reportTrafficValues
+	state.mu.Unlock()
+	if !values.initialized {
+		restored, err := latestTrafficValues(ctx, s, uuid)
+		if err != nil {
+			return nil, err
+		}
+		values = restored
+	}
+	live := values.timestamp
+
+	prepared := make([]v1.Report, len(reports))
+	points := make([]metric.Point, 0, len(reports)*20)
+	advanced := false
+	for i, report := range reports {
+		report.UpdatedAt = report.UpdatedAt.UTC()
+		prepared[i] = report
+		if !live.IsZero() && !report.UpdatedAt.After(live) {
+			points = append(points, reportMetricPoints(report, 0, 0)...)
+			continue
+		}
+		agentRestart := values.hasUptime && report.Uptime < values.uptime
+		trafficUp := int64(0)
+		if values.hasUp && !agentRestart && report.Network.TotalUp >= values.totalUp {
+			trafficUp = TrafficCounterDelta(report.Network.TotalUp, values.totalUp)
+		}
+		trafficDown := int64(0)
+		if values.hasDown && !agentRestart && report.Network.TotalDown >= values.totalDown {
+			trafficDown = TrafficCounterDelta(report.Network.TotalDown, values.totalDown)
+		}
+		points = append(points, reportMetricPoints(report, trafficUp, trafficDown)...)
+		values.timestamp = report.UpdatedAt
+		values.hasUp = true
+		values.totalUp = report.Network.TotalUp
+		values.hasDown = true
+		values.totalDown = report.Network.TotalDown
+		values.hasUptime = true
+		values.uptime = report.Uptime
+		advanced = true
+	}
+
+	if err := s.WriteBatch(ctx, points); err != nil {
+		return nil, err
+	}
+	notifyPointObservers(points)
+	if advanced {
+		state.mu.Lock()
+		// A live report written meanwhile is newer than any buffered point and
+		// keeps its own state.
+		if !state.initialized || state.timestamp.Before(values.timestamp) {
+			state.reportTrafficValues = values
+		}
+		state.mu.Unlock()
+	}
+	return prepared, nil
+}
+
+// latestTrafficValues restores the traffic state from the newest stored
+// counters, including their timestamp, after a server restart.
+func latestTrafficValues(ctx context.Context, s *metric.Store, uuid string) (reportTrafficValues, error) {
+	values := reportTrafficValues{initialized: true}
+	up, hasUp, err := s.LatestBefore(ctx, MetricNetTotalUp, uuid, farFuture())
+	if err != nil {
+		return values, err
+	}
+	down, hasDown, err := s.LatestBefore(ctx, MetricNetTotalDown, uuid, farFuture())
+	if err != nil {
+		return values, err
+	}
+	if hasUp {
+		values.hasUp, values.totalUp, values.timestamp = true, int64(up.Value), up.Timestamp.UTC()
+	}
+	if hasDown {
+		values.hasDown, values.totalDown = true, int64(down.Value)
+		if down.Timestamp.After(values.timestamp) {
+			values.timestamp = down.Timestamp.UTC()
+		}
+	}
+	return values, nil
+}
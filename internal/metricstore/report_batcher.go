@@ -412,6 +412,7 @@ func writeReportBatch(ctx context.Context, reports []v1.Report) ([]v1.Report, er
 	if err := s.WriteBatch(ctx, points); err != nil {
 		return nil, err
 	}
+	notifyPointObservers(points)
 	for state, values := range pendingStates {
 		state.mu.Lock()
 		state.reportTrafficValues = values
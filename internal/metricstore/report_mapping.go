@@ -30,6 +30,7 @@ func reportMetricPoints(report v1.Report, trafficUp, trafficDown int64) []metric
 		{MetricName: MetricConnections, EntityID: entityID, Timestamp: ts, Value: float64(report.Connections.TCP)},
 		{MetricName: MetricConnectionsUDP, EntityID: entityID, Timestamp: ts, Value: float64(report.Connections.UDP)},
 	}
+	points = append(points, deviceMetricPoints(report)...)
 	if report.GPU == nil {
 		return points
 	}
@@ -49,6 +50,84 @@ func reportMetricPoints(report v1.Report, trafficUp, trafficDown int64) []metric
 	return points
 }
 
+// deviceMetricPoints maps optional per-mount, per-interface, per-device and
+// per-container details to tagged points.
+func deviceMetricPoints(report v1.Report) []metric.Point {
+	entityID := report.UUID
+	ts := report.UpdatedAt
+	points := make([]metric.Point, 0, len(report.Mounts)*4+len(report.Interfaces)*8+len(report.DiskIO)*5+len(report.Containers)*6)
+	for _, mount := range report.Mounts {
+		if mount.Path == "" {
+			continue
+		}
+		tags := map[string]string{"mount": mount.Path}
+		if mount.FSType != "" {
+			tags["fs_type"] = mount.FSType
+		}
+		points = append(points,
+			metric.Point{MetricName: MetricMountUsed, EntityID: entityID, Timestamp: ts, Value: float64(mount.Used), Tags: tags},
+			metric.Point{MetricName: MetricMountTotal, EntityID: entityID, Timestamp: ts, Value: float64(mount.Total), Tags: tags},
+			metric.Point{MetricName: MetricMountUsage, EntityID: entityID, Timestamp: ts, Value: percentage(mount.Used, mount.Total), Tags: tags},
+		)
+		if mount.InodesTotal > 0 {
+			points = append(points, metric.Point{MetricName: MetricMountInodes, EntityID: entityID, Timestamp: ts, Value: percentage(mount.InodesUsed, mount.InodesTotal), Tags: tags})
+		}
+	}
+	for _, iface := range report.Interfaces {
+		if iface.Name == "" {
+			continue
+		}
+		tags := map[string]string{"iface": iface.Name}
+		points = append(points,
+			metric.Point{MetricName: MetricIfaceRxRate, EntityID: entityID, Timestamp: ts, Value: float64(iface.RxRate), Tags: tags},
+			metric.Point{MetricName: MetricIfaceTxRate, EntityID: entityID, Timestamp: ts, Value: float64(iface.TxRate), Tags: tags},
+			metric.Point{MetricName: MetricIfaceRxBytes, EntityID: entityID, Timestamp: ts, Value: float64(iface.RxBytes), Tags: tags},
+			metric.Point{MetricName: MetricIfaceTxBytes, EntityID: entityID, Timestamp: ts, Value: float64(iface.TxBytes), Tags: tags},
+			metric.Point{MetricName: MetricIfaceRxErrors, EntityID: entityID, Timestamp: ts, Value: float64(iface.RxErrors), Tags: tags},
+			metric.Point{MetricName: MetricIfaceTxErrors, EntityID: entityID, Timestamp: ts, Value: float64(iface.TxErrors), Tags: tags},
+			metric.Point{MetricName: MetricIfaceRxDrops, EntityID: entityID, Timestamp: ts, Value: float64(iface.RxDrops), Tags: tags},
+			metric.Point{MetricName: MetricIfaceTxDrops, EntityID: entityID, Timestamp: ts, Value: float64(iface.TxDrops), Tags: tags},
+		)
+	}
+	for _, disk := range report.DiskIO {
+		if disk.Device == "" {
+			continue
+		}
+		tags := map[string]string{"device": disk.Device}
+		points = append(points,
+			metric.Point{MetricName: MetricDiskReadRate, EntityID: entityID, Timestamp: ts, Value: float64(disk.ReadRate), Tags: tags},
+			metric.Point{MetricName: MetricDiskWriteRate, EntityID: entityID, Timestamp: ts, Value: float64(disk.WriteRate), Tags: tags},
+			metric.Point{MetricName: MetricDiskReadIOPS, EntityID: entityID, Timestamp: ts, Value: disk.ReadIOPS, Tags: tags},
+			metric.Point{MetricName: MetricDiskWriteIOPS, EntityID: entityID, Timestamp: ts, Value: disk.WriteIOPS, Tags: tags},
+			metric.Point{MetricName: MetricDiskUtil, EntityID: entityID, Timestamp: ts, Value: disk.Utilization, Tags: tags},
+		)
+	}
+	for _, container := range report.Containers {
+		if container.ID == "" {
+			continue
+		}
+		tags := containerTags(container)
+		points = append(points,
+			metric.Point{MetricName: MetricContainerCPU, EntityID: entityID, Timestamp: ts, Value: container.CPU, Tags: tags},
+			metric.Point{MetricName: MetricContainerMem, EntityID: entityID, Timestamp: ts, Value: float64(container.MemoryUsed), Tags: tags},
+			metric.Point{MetricName: MetricContainerRx, EntityID: entityID, Timestamp: ts, Value: float64(container.NetRx), Tags: tags},
+			metric.Point{MetricName: MetricContainerTx, EntityID: entityID, Timestamp: ts, Value: float64(container.NetTx), Tags: tags},
+			metric.Point{MetricName: MetricContainerRuns, EntityID: entityID, Timestamp: ts, Value: float64(container.RestartCount), Tags: tags},
+		)
+		if container.MemoryLimit > 0 {
+			points = append(points, metric.Point{MetricName: MetricContainerLimit, EntityID: entityID, Timestamp: ts, Value: float64(container.MemoryLimit), Tags: tags})
+		}
+	}
+	return points
+}
+
+func percentage(used, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	return float64(used) / float64(total) * 100
+}
+
 func latestReportCounter(ctx context.Context, s *metric.Store, metricName, entityID string, before time.Time) (int64, bool, error) {
 	point, ok, err := s.LatestBefore(ctx, metricName, entityID, before)
 	if err != nil {
@@ -412,6 +412,168 @@ func TestWriteReportRebasesTrafficAfterAgentRestart(t *testing.T) {
 	assertMetricValues(t, s, MetricTrafficDown, report.UUID, base.Add(-time.Second), base.Add(time.Minute), []float64{0, 60, 0, 35})
 }
 
+func TestWriteReportStoresTaggedDeviceMetrics(t *testing.T) {
+	ctx := context.Background()
+	s := useReportTestStore(t, nil)
+	timestamp := time.Now().UTC().Add(-10 * time.Second)
+	report := v1.Report{
+		UUID:      "device-node",
+		UpdatedAt: timestamp,
+		Mounts: []v1.MountReport{
+			{Path: "/", FSType: "ext4", Total: 1000, Used: 250, InodesTotal: 100, InodesUsed: 10},
+			{Path: "/data", FSType: "xfs", Total: 1000, Used: 950},
+		},
+		Interfaces: []v1.InterfaceReport{{Name: "eth0", RxRate: 2_000_000, TxRate: 500_000, RxBytes: 10, TxBytes: 20, RxErrors: 1}},
+		DiskIO:     []v1.DiskIOReport{{Device: "sda", ReadRate: 3_000_000, WriteRate: 1_000_000, ReadIOPS: 12, WriteIOPS: 4, Utilization: 35}},
+	}
+	if _, err := WriteReport(ctx, report); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+
+	points, err := s.Query(ctx, metric.Query{
+		MetricName: MetricMountUsage,
+		EntityID:   report.UUID,
+		Start:      timestamp.Add(-time.Second),
+		End:        timestamp.Add(time.Second),
+		Tags:       map[string]string{"mount": "/data"},
+	})
+	if err != nil {
+		t.Fatalf("query mount usage: %v", err)
+	}
+	if len(points) != 1 || points[0].Value != 95 || points[0].Tags["fs_type"] != "xfs" {
+		t.Fatalf("mount usage points = %#v", points)
+	}
+	assertMetricValues(t, s, MetricMountInodes, report.UUID, timestamp.Add(-time.Second), timestamp.Add(time.Second), []float64{10})
+	assertMetricValues(t, s, MetricIfaceRxErrors, report.UUID, timestamp.Add(-time.Second), timestamp.Add(time.Second), []float64{1})
+	assertMetricValues(t, s, MetricDiskUtil, report.UUID, timestamp.Add(-time.Second), timestamp.Add(time.Second), []float64{35})
+
+	for name, want := range map[string]float64{
+		"mount_usage":   95,
+		"mount_usage:/": 25,
+		"iface_in:eth0": 16,
+		"disk_read":     3,
+		"disk_util:sda": 35,
+	} {
+		values, err := GetDeviceMetricMaxByClientAndTime(ctx, report.UUID, name, timestamp.Add(-time.Minute), timestamp.Add(time.Minute))
+		if err != nil {
+			t.Fatalf("query %s: %v", name, err)
+		}
+		if len(values) != 1 || values[0] != want {
+			t.Fatalf("%s values = %v, want [%v]", name, values, want)
+		}
+	}
+	if IsDeviceNotificationMetric("cpu") {
+		t.Fatal("cpu must not be treated as a device metric")
+	}
+}
+
+func TestRemovedContainerSeriesAreDeletedAfterGrace(t *testing.T) {
+	ctx := context.Background()
+	s := useReportTestStore(t, nil)
+	t.Cleanup(func() { deleteContainerSeriesState("container-node") })
+	timestamp := time.Now().UTC().Add(-time.Minute)
+	containers := []v1.ContainerReport{
+		{ID: "0123456789abcdef", Name: "web", State: "running", CPU: 12, MemoryUsed: 100},
+		{ID: "fedcba9876543210", Name: "worker", State: "running", CPU: 3, MemoryUsed: 50},
+	}
+	if _, err := WriteReport(ctx, v1.Report{UUID: "container-node", UpdatedAt: timestamp, Containers: containers}); err != nil {
+		t.Fatalf("write report: %v", err)
+	}
+	if removed := staleContainers("container-node", containers, timestamp); len(removed) != 0 {
+		t.Fatalf("removed = %v, want none", removed)
+	}
+	if removed := staleContainers("container-node", containers[:1], timestamp.Add(containerSeriesGrace/2)); len(removed) != 0 {
+		t.Fatalf("removed within grace = %v, want none", removed)
+	}
+	removed := staleContainers("container-node", containers[:1], timestamp.Add(containerSeriesGrace+time.Second))
+	if len(removed) != 1 || removed[0] != "fedcba987654" {
+		t.Fatalf("removed = %v, want worker", removed)
+	}
+	if err := deleteContainerSeries(ctx, "container-node", removed); err != nil {
+		t.Fatalf("delete container series: %v", err)
+	}
+
+	points, err := s.Query(ctx, metric.Query{
+		MetricName: MetricContainerCPU,
+		EntityID:   "container-node",
+		Start:      timestamp.Add(-time.Second),
+		End:        timestamp.Add(time.Second),
+	})
+	if err != nil {
+		t.Fatalf("query container CPU: %v", err)
+	}
+	if len(points) != 1 || points[0].Tags["container"] != "web" || points[0].Tags["container_id"] != "0123456789ab" {
+		t.Fatalf("container CPU points = %#v, want only web", points)
+	}
+}
+
+func TestWriteBackfillReportsKeepsTimestampsAndTrafficContinuity(t *testing.T) {
+	ctx := context.Background()
+	s := useReportTestStore(t, nil)
+	base := time.Now().UTC().Truncate(time.Minute).Add(5 * time.Second)
+	report := v1.Report{
+		UUID:      "buffering-node",
+		UpdatedAt: base,
+		Uptime:    1000,
+		Network:   v1.NetworkReport{TotalUp: 100, TotalDown: 200},
+	}
+	if _, err := WriteReport(ctx, report); err != nil {
+		t.Fatalf("write live report: %v", err)
+	}
+
+	buffered := []v1.Report{report, report}
+	buffered[0].UpdatedAt = base.Add(3 * time.Second)
+	buffered[0].Uptime = 1003
+	buffered[0].Network = v1.NetworkReport{TotalUp: 130, TotalDown: 240}
+	buffered[1].UpdatedAt = base.Add(6 * time.Second)
+	buffered[1].Uptime = 1006
+	buffered[1].Network = v1.NetworkReport{TotalUp: 170, TotalDown: 250}
+	saved, err := WriteBackfillReports(ctx, buffered)
+	if err != nil {
+		t.Fatalf("backfill reports: %v", err)
+	}
+	if len(saved) != 2 || !saved[1].UpdatedAt.Equal(base.Add(6*time.Second)) {
+		t.Fatalf("saved reports = %#v, want original timestamps", saved)
+	}
+
+	report.UpdatedAt = base.Add(9 * time.Second)
+	report.Uptime = 1009
+	report.Network = v1.NetworkReport{TotalUp: 180, TotalDown: 300}
+	if _, err := WriteReport(ctx, report); err != nil {
+		t.Fatalf("write live report after backfill: %v", err)
+	}
+
+	late := report
+	late.UpdatedAt = base.Add(7 * time.Second)
+	late.Uptime = 1007
+	late.Network = v1.NetworkReport{TotalUp: 175, TotalDown: 280}
+	if _, err := WriteBackfillReports(ctx, []v1.Report{late}); err != nil {
+		t.Fatalf("backfill late report: %v", err)
+	}
+
+	assertMetricValues(t, s, MetricTrafficUp, report.UUID, base.Add(-time.Second), base.Add(time.Minute), []float64{0, 30, 40, 0, 10})
+	assertMetricValues(t, s, MetricTrafficDown, report.UUID, base.Add(-time.Second), base.Add(time.Minute), []float64{0, 40, 10, 0, 50})
+	assertMetricValues(t, s, MetricNetTotalUp, report.UUID, base.Add(-time.Second), base.Add(time.Minute), []float64{100, 130, 170, 175, 180})
+}
+
+func TestWriteBackfillReportsRejectsUnorderedReports(t *testing.T) {
+	ctx := context.Background()
+	useReportTestStore(t, nil)
+	base := time.Now().UTC().Add(-time.Minute)
+	reports := []v1.Report{
+		{UUID: "unordered-node", UpdatedAt: base.Add(time.Second)},
+		{UUID: "unordered-node", UpdatedAt: base},
+	}
+	if _, err := WriteBackfillReports(ctx, reports); err == nil {
+		t.Fatal("expected unordered backfill to be rejected")
+	}
+	reports[1].UpdatedAt = base.Add(2 * time.Second)
+	reports[1].UUID = "other-node"
+	if _, err := WriteBackfillReports(ctx, reports); err == nil {
+		t.Fatal("expected mixed-client backfill to be rejected")
+	}
+}
+
 func TestWriteReportNormalizesReceiveTimeToUTC(t *testing.T) {
 	ctx := context.Background()
 	s := useReportTestStore(t, nil)
@@ -0,0 +1,67 @@
+package metricstore
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+// WriteServiceStatus stores one service.up point per monitored service, tagged
+// with the service name: 1 while the service is running and 0 otherwise.
+func WriteServiceStatus(ctx context.Context, entityID string, at time.Time, up map[string]bool) error {
+	if entityID == "" || len(up) == 0 {
+		return nil
+	}
+	if err := storeOperations.AcquireShared(ctx); err != nil {
+		return fmt.Errorf("wait for metric store operation before writing service status: %w", err)
+	}
+	defer storeOperations.ReleaseShared()
+	s := GetStore()
+	if s == nil {
+		return fmt.Errorf("metric store not enabled")
+	}
+
+	names := make([]string, 0, len(up))
+	for name := range up {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	points := make([]metric.Point, 0, len(names))
+	for _, name := range names {
+		value := 0.0
+		if up[name] {
+			value = 1
+		}
+		points = append(points, metric.Point{
+			MetricName: MetricServiceUp,
+			EntityID:   entityID,
+			Timestamp:  at.UTC(),
+			Value:      value,
+			Tags:       map[string]string{"service": name},
+		})
+	}
+	if err := s.WriteBatch(ctx, points); err != nil {
+		return err
+	}
+	notifyPointObservers(points)
+	return nil
+}
+
+// DeleteServiceSeries deletes the status history of one monitored service on
+// every client.
+func DeleteServiceSeries(ctx context.Context, service string) error {
+	s := GetStore()
+	if s == nil {
+		return fmt.Errorf("metric store not enabled")
+	}
+	if _, err := s.DeleteSeries(ctx, metric.Query{
+		MetricName: MetricServiceUp,
+		Tags:       map[string]string{"service": service},
+	}); err != nil {
+		return fmt.Errorf("failed to delete service status for %s: %w", service, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package metricstore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+// ErrSnapshotUnsupported is returned when the active store is not SQLite.
+// External databases are expected to be backed up with their own tooling.
+var ErrSnapshotUnsupported = errors.New("metric store snapshot requires the sqlite driver")
+
+// SnapshotSQLite writes a transactionally consistent copy of the active SQLite
+// metric store to destPath using VACUUM INTO. It only takes the shared
+// operation lock, so report writes and queries continue while the copy runs.
+func SnapshotSQLite(ctx context.Context, destPath string) error {
+	if err := storeOperations.AcquireShared(ctx); err != nil {
+		return fmt.Errorf("wait for metric store operations before snapshot: %w", err)
+	}
+	defer storeOperations.ReleaseShared()
+
+	storeMu.RLock()
+	activeStore := store
+	storeMu.RUnlock()
+	if activeStore == nil {
+		return ErrStoreNotInitialized
+	}
+	if activeStore.Driver() != metric.DriverSQLite {
+		return ErrSnapshotUnsupported
+	}
+
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return err
+	}
+	// VACUUM INTO refuses to overwrite an existing file.
+	if err := os.Remove(destPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if _, err := activeStore.ExecContext(ctx, "VACUUM INTO ?", filepath.ToSlash(destPath)); err != nil {
+		return fmt.Errorf("sqlite VACUUM INTO failed: %w", err)
+	}
+	return nil
+}
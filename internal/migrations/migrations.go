@@ -140,18 +140,18 @@ func migrateDeprecatedMetricRetentionConfig(db *gorm.DB) error {
 	if !db.Migrator().HasTable(&appconfig.ConfigItem{}) {
 		return nil
 	}
-	return db.Delete(&appconfig.ConfigItem{}, "key = ?", "metric_retention_days").Error
+	return db.Where(map[string]any{"key": "metric_retention_days"}).Delete(&appconfig.ConfigItem{}).Error
 }
 
 func migrateRemovedCompatibilityConfig(db *gorm.DB) error {
 	if !db.Migrator().HasTable(&appconfig.ConfigItem{}) {
 		return nil
 	}
-	return db.Delete(&appconfig.ConfigItem{}, "key IN ?", []string{
+	return db.Where(map[string]any{"key": []string{
 		"nezha_compat_enabled",
 		"nezha_compat_listen",
 		"low_resource_mode",
-	}).Error
+	}}).Delete(&appconfig.ConfigItem{}).Error
 }
 
 func hasLegacyConfigTable(db *gorm.DB) bool {
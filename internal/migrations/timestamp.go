@@ -67,6 +67,10 @@ type timestampRow struct {
 // migrateLegacyTimestampColumns makes old offset-free SQLite values
 // unambiguous before any current model scans them as time.Time.
 func migrateLegacyTimestampColumns(db *gorm.DB) error {
+	// 无时区的旧时间戳只可能来自 SQLite；MySQL/PostgreSQL 主库也没有 rowid。
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
 	if timestampMigrationDone(db) {
 		return nil
 	}
@@ -215,7 +219,7 @@ func timestampMigrationDone(db *gorm.DB) bool {
 		return false
 	}
 	var item appconfig.ConfigItem
-	if err := db.Where("key = ?", timestampUTCMigrationKey).First(&item).Error; err != nil {
+	if err := db.Where(map[string]any{"key": timestampUTCMigrationKey}).First(&item).Error; err != nil {
 		return false
 	}
 	return item.Value == "true"
@@ -0,0 +1,64 @@
+package plugin
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/dop251/goja"
+	"github.com/komari-monitor/komari/database/agentevents"
+)
+
+// agentEventsOnce keeps Init from subscribing the manager more than once.
+var agentEventsOnce sync.Once
+
+// registerAgentEventHandler registers a server.onAgentEvent handler for one
+// plugin load. It is called from the plugin's own event loop during script
+// evaluation and takes the manager lock itself. Handlers are dropped with the
+// instance on unload.
+func (m *Manager) registerAgentEventHandler(short string, fn goja.Callable) error {
+	m.mu.RLock()
+	inst, ok := m.instances[short]
+	m.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("plugin %q is not loaded", short)
+	}
+	inst.mu.Lock()
+	inst.onEvents = append(inst.onEvents, fn)
+	inst.mu.Unlock()
+	return nil
+}
+
+// dispatchAgentEvent delivers one recorded agent event to every loaded plugin
+// that registered server.onAgentEvent. The event is passed as a plain object
+// with the same shape as admin:listReportedEvents entries. Handler errors are
+// reported to the plugin log by RunJob.
+func (m *Manager) dispatchAgentEvent(event agentevents.Event) {
+	value, err := normalizeRPCValue(event)
+	if err != nil {
+		return
+	}
+	m.mu.RLock()
+	instances := make([]*Instance, 0, len(m.instances))
+	for _, inst := range m.instances {
+		instances = append(instances, inst)
+	}
+	m.mu.RUnlock()
+	for _, inst := range instances {
+		inst.mu.RLock()
+		handlers := append([]goja.Callable(nil), inst.onEvents...)
+		host := inst.host
+		alive := inst.runtime != nil
+		inst.mu.RUnlock()
+		if !alive || host == nil || len(handlers) == 0 {
+			continue
+		}
+		host.RunOnLoop(func(vm *goja.Runtime) {
+			for _, fn := range handlers {
+				_ = host.RunJob(vm, "plugin onAgentEvent", func() error {
+					_, err := fn(goja.Undefined(), vm.ToValue(value))
+					return err
+				})
+			}
+		})
+	}
+}
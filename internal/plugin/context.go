@@ -72,6 +72,8 @@ func principalTypeString(t rpc.PrincipalType) string {
 		return "user"
 	case rpc.PrincipalAPIKey:
 		return "api_key"
+	case rpc.PrincipalFederation:
+		return "federation"
 	default:
 		return "anonymous"
 	}
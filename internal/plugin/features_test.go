@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/agentevents"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/pkg/rpc"
@@ -291,6 +292,57 @@ func TestCronRunsOnScheduleAndStopsOnUnload(t *testing.T) {
 	}
 }
 
+func TestOnAgentEventReceivesEvents(t *testing.T) {
+	withTempDataDir(t)
+	gin.SetMode(gin.TestMode)
+	Init(gin.New())
+
+	zipPath := writePluginZip(t, map[string]string{
+		"komari-plugin.json": `{"name":"Events","short":"events","version":"1.0.0","permissions":{"node":true,"timeout":5}}`,
+		"script.js": `
+			const fs = require("fs");
+			const server = require("server");
+			function load() {
+				server.onAgentEvent((event) => {
+					fs.appendFileSync("events.txt", event.client_uuid + " " + event.type + " " + event.data.job + "\n");
+				});
+			}
+		`,
+	})
+	if _, err := InstallZip(zipPath); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetEnabled("events", true, true); err != nil {
+		t.Fatal(err)
+	}
+
+	global.dispatchAgentEvent(agentevents.Event{
+		AgentEvent: models.AgentEvent{ClientUUID: "node-1", Type: "backup.finished", Severity: models.AgentEventInfo},
+		Data:       json.RawMessage(`{"job":"nightly"}`),
+	})
+	eventsFile := filepath.Join(DataDir, "events", "events.txt")
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		data, err := os.ReadFile(eventsFile)
+		if err == nil && string(data) == "node-1 backup.finished nightly\n" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("event handler output = %q, %v", data, err)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+
+	if err := SetEnabled("events", false, false); err != nil {
+		t.Fatal(err)
+	}
+	global.dispatchAgentEvent(agentevents.Event{AgentEvent: models.AgentEvent{ClientUUID: "node-1", Type: "late"}})
+	time.Sleep(100 * time.Millisecond)
+	if data, _ := os.ReadFile(eventsFile); strings.Contains(string(data), "late") {
+		t.Fatalf("handler ran after unload: %q", data)
+	}
+}
+
 // TestCronInvalidSpecFailsLoad 验证非法 cron 表达式使插件加载失败并自动禁用。
 func TestCronInvalidSpecFailsLoad(t *testing.T) {
 	withTempDataDir(t)
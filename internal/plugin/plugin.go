@@ -32,6 +32,7 @@ import (
 	"github.com/dop251/goja"
 	"github.com/dop251/goja_nodejs/require"
 	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/agentevents"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/internal/scheduler"
 	"github.com/komari-monitor/komari/pkg/jsruntime"
@@ -122,6 +123,7 @@ type Instance struct {
 	statics    map[string]*staticConfig // mount path -> static folder config
 	rpcMethods map[string]goja.Callable // registered RPC method -> JS handler
 	cronJobs   []string                 // scheduler job names, removed on unload
+	onEvents   []goja.Callable          // server.onAgentEvent handlers
 }
 
 // global is the process-wide plugin manager.
@@ -138,6 +140,7 @@ func Init(engine *gin.Engine) {
 	global.engine = engine
 	global.mu.Unlock()
 	connection.SetFrameInterceptor(global)
+	agentEventsOnce.Do(func() { agentevents.Subscribe(global.dispatchAgentEvent) })
 }
 
 // LoadAll loads every installed plugin whose persisted state is enabled and
@@ -344,6 +347,7 @@ func (m *Manager) dropInstance(short string, inst *Instance) {
 	clear(inst.statics)
 	clear(inst.rpcMethods)
 	inst.cronJobs = nil
+	inst.onEvents = nil
 	inst.mu.Unlock()
 	m.removeHooksLocked(short)
 	m.removeInjectsLocked(short)
@@ -397,6 +401,7 @@ func (m *Manager) unload(short string) error {
 	clear(inst.statics)
 	clear(inst.rpcMethods)
 	inst.cronJobs = nil
+	inst.onEvents = nil
 	inst.mu.Unlock()
 	m.removeHooksLocked(short)
 	m.removeInjectsLocked(short)
@@ -56,8 +56,12 @@ import (
 //	server.getConfig()                    resolve the saved plugin configuration
 //	server.cron(expr, fn)                 run fn on the plugin event loop each
 //	                                      time the cron expression fires
+//	server.onAgentEvent(fn)               run fn with every event an agent
+//	                                      reports through agent.event or
+//	                                      agent.message
 //
-// server.registerRPC, server.getConfig, server.cron and filesystem access
+// server.registerRPC, server.getConfig, server.cron, server.onAgentEvent and
+// filesystem access
 // confined to the plugin directory are always granted without a manifest
 // declaration.
 // server.route, server.hook, server.injectHTML and server.call require the
@@ -208,6 +212,16 @@ func (m *Manager) registerServerModule(host *jsruntime.Host, registry *require.R
 			}
 			return goja.Undefined()
 		})
+		_ = exports.Set("onAgentEvent", func(call goja.FunctionCall) goja.Value {
+			fn, ok := goja.AssertFunction(call.Argument(0))
+			if !ok {
+				panic(vm.NewTypeError("server.onAgentEvent requires a function handler"))
+			}
+			if err := m.registerAgentEventHandler(inst.info.Short, fn); err != nil {
+				panic(vm.NewGoError(err))
+			}
+			return goja.Undefined()
+		})
 		_ = exports.Set("registerRPC", func(call goja.FunctionCall) goja.Value {
 			method := strings.TrimSpace(call.Argument(0).String())
 			fn, ok := goja.AssertFunction(call.Argument(1))
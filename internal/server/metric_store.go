@@ -5,8 +5,10 @@ import (
 	"time"
 
 	"github.com/komari-monitor/komari/database/auditlog"
-	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/database/metricsinks"
 	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/internal/metricsink"
+	"github.com/komari-monitor/komari/internal/metricstore"
 	logger "github.com/komari-monitor/komari/utils/log"
 )
 
@@ -81,6 +83,12 @@ func (a *App) InitStores() error {
 		auditlog.EventLog("error", fmt.Sprintf("Failed to initialize metric store: %v", err))
 		return err
 	}
+	// Sinks are stopped after the batcher (cleanups run LIFO) so the final
+	// report flush is still forwarded.
+	if err := metricsinks.ReloadMetricSinks(); err != nil {
+		logger.Errorf("server", "Failed to start metric sinks: %v", err)
+	}
+	a.addCleanup("metric-sinks", metricsink.Stop)
 	metricstore.StartReportBatcher()
 	a.addCleanup("metric-report-batcher", metricstore.StopReportBatcher)
 	// A store-to-store migration holds the exclusive operation lease. Stop it
@@ -13,8 +13,12 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/komari-monitor/komari/database"
 	"github.com/komari-monitor/komari/database/accounts"
+	"github.com/komari-monitor/komari/database/agentevents"
+	"github.com/komari-monitor/komari/database/agentlogs"
+	"github.com/komari-monitor/komari/database/agentoutbox"
 	"github.com/komari-monitor/komari/database/auditlog"
 	d_notification "github.com/komari-monitor/komari/database/notification"
+	"github.com/komari-monitor/komari/database/processsnapshots"
 	"github.com/komari-monitor/komari/database/tasks"
 	"github.com/komari-monitor/komari/internal/config"
 	"github.com/komari-monitor/komari/internal/lifecycle"
@@ -25,7 +29,11 @@ import (
 	logger "github.com/komari-monitor/komari/utils/log"
 	"github.com/komari-monitor/komari/utils/messageSender"
 	"github.com/komari-monitor/komari/utils/notifier"
+	"github.com/komari-monitor/komari/web/agentupdate"
 	"github.com/komari-monitor/komari/web/api"
+	"github.com/komari-monitor/komari/web/api/filetransfer"
+	"github.com/komari-monitor/komari/web/backup"
+	"github.com/komari-monitor/komari/web/federation"
 	"github.com/komari-monitor/komari/web/oauth"
 	recoveryweb "github.com/komari-monitor/komari/web/recovery"
 	"github.com/komari-monitor/komari/web/router"
@@ -82,6 +90,16 @@ func (a *App) registerReloadHandlers(cors *security.CorsController) {
 		}
 	})
 	a.reload.Register("cors", func(event config.ConfigEvent) { cors.Update(event) })
+	a.reload.Register("backup-schedule", func(event config.ConfigEvent) {
+		for _, key := range backup.ScheduleConfigKeyList {
+			if event.IsChanged(key) {
+				if err := backup.ReloadSchedule(); err != nil {
+					logger.Errorf("server", "Failed to reload scheduled backup task: %v", err)
+				}
+				return
+			}
+		}
+	})
 }
 
 // BuildRouter constructs the normal application router and starts reloads.
@@ -185,6 +203,7 @@ func (a *App) runCleanups(ctx context.Context) error {
 }
 
 func registerScheduledWork() {
+	agentoutbox.Init()
 	if err := tasks.ReloadPingSchedule(); err != nil {
 		logger.ErrorArgs("server", "Failed to reload ping schedule:", err)
 	}
@@ -207,6 +226,18 @@ func registerScheduledWork() {
 		logger.ErrorArgs("server", "Failed to add expire notification task:", err)
 	}
 	notifier.InitTrafficReportSchedule()
+	if err := backup.ReloadSchedule(); err != nil {
+		logger.ErrorArgs("server", "Failed to register scheduled backup task:", err)
+	}
+	if err := scheduler.AddContextFunc("federation:sync", scheduler.Every(federation.TickInterval), true, federation.SyncDue); err != nil {
+		logger.ErrorArgs("server", "Failed to add federation sync task:", err)
+	}
+	if err := scheduler.AddContextFunc("agentupdate:rollout", scheduler.Every(agentupdate.TickInterval), true, agentupdate.Tick); err != nil {
+		logger.ErrorArgs("server", "Failed to add agent update rollout task:", err)
+	}
+	if err := scheduler.AddContextFunc("agentoutbox:expire", scheduler.Every(agentoutbox.TickInterval), true, agentoutbox.Tick); err != nil {
+		logger.ErrorArgs("server", "Failed to add agent event expiry task:", err)
+	}
 }
 
 const taskResultRetentionDays = 30
@@ -216,6 +247,18 @@ func cleanupScheduledData() {
 	if err := tasks.ClearTaskResultsByTimeBefore(before); err != nil {
 		logger.Errorf("server", "Failed to clean expired task results: %v", err)
 	}
+	if err := processsnapshots.DeleteBefore(time.Now().UTC().Add(-processsnapshots.Retention)); err != nil {
+		logger.Errorf("server", "Failed to clean expired process snapshots: %v", err)
+	}
+	if err := agentevents.DeleteBefore(time.Now().UTC().Add(-agentevents.Retention)); err != nil {
+		logger.Errorf("server", "Failed to clean expired agent events: %v", err)
+	}
+	if err := agentlogs.Cleanup(); err != nil {
+		logger.Errorf("server", "Failed to clean expired agent logs: %v", err)
+	}
+	if err := filetransfer.Prune(time.Now()); err != nil {
+		logger.Errorf("server", "Failed to clean finished file transfers: %v", err)
+	}
 	auditlog.RemoveOldLogs()
 	accounts.RemoveExpiredSessions()
 }
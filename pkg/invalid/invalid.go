@@ -0,0 +1,25 @@
+// Package invalid marks errors caused by invalid request parameters rather
+// than by the database or other internal failures. The RPC layer maps them
+// to InvalidParams and their message is safe to show to the caller.
+package invalid
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Error is a validation failure.
+type Error struct{ msg string }
+
+func (e *Error) Error() string { return e.msg }
+
+// Errorf formats a validation failure.
+func Errorf(format string, args ...any) error {
+	return &Error{msg: fmt.Sprintf(format, args...)}
+}
+
+// Is reports whether err, or any error it wraps, is a validation failure.
+func Is(err error) bool {
+	var target *Error
+	return errors.As(err, &target)
+}
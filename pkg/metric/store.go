@@ -1034,6 +1034,75 @@ func (s *Store) EntityIDs(ctx context.Context, query Query) ([]string, error) {
 	return out, nil
 }
 
+// SeriesKey returns the identity of one series of a metric: the entity plus a
+// fingerprint of its tag set. Keys returned by SeriesKeys use the same format.
+//
+// SeriesKey 返回某指标下一条序列的标识（实体 + 标签指纹），与 SeriesKeys 返回格式一致。
+func SeriesKey(entityID string, tags map[string]string) (string, error) {
+	hash, _, err := tagsFingerprint(tags)
+	if err != nil {
+		return "", err
+	}
+	return entityID + "\x00" + hash, nil
+}
+
+// SeriesKeys lists the distinct series of a metric across exact in-memory
+// samples, hot minute summaries and persisted rollups. Callers use it to
+// enforce cardinality limits on dynamically created metrics.
+//
+// SeriesKeys 列出某指标在内存原始点、热 rollup 和持久化 rollup 中的全部序列，
+// 供调用方对动态创建的指标做基数限制。
+func (s *Store) SeriesKeys(ctx context.Context, metricName string) ([]string, error) {
+	if err := s.ensureOpen(); err != nil {
+		return nil, err
+	}
+	if strings.TrimSpace(metricName) == "" {
+		return nil, fmt.Errorf("%w: metric name is required", ErrInvalidArgument)
+	}
+	seen := make(map[string]struct{})
+	s.rawMu.RLock()
+	for key := range s.raw {
+		if key.metricName == metricName {
+			seen[key.entityID+"\x00"+key.tagsHash] = struct{}{}
+		}
+	}
+	s.rawMu.RUnlock()
+	s.hotMu.RLock()
+	for key := range s.hot {
+		if key.metricName == metricName {
+			seen[key.entityID+"\x00"+key.tagsHash] = struct{}{}
+		}
+	}
+	s.hotMu.RUnlock()
+
+	sqlText := fmt.Sprintf(`SELECT entity_id, tags_hash FROM %s WHERE metric_name = %s`, s.tables.series, s.dialect.placeholder(1))
+	rows, err := s.reader().QueryContext(ctx, sqlText, metricName)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var entityID, tagsHash string
+		if err := rows.Scan(&entityID, &tagsHash); err != nil {
+			_ = rows.Close()
+			return nil, err
+		}
+		seen[entityID+"\x00"+tagsHash] = struct{}{}
+	}
+	if err := rows.Err(); err != nil {
+		_ = rows.Close()
+		return nil, err
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(seen))
+	for key := range seen {
+		out = append(out, key)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
 // Latest loads the newest points for a metric and entity.
 //
 // Latest 查询某指标和实体的最新采样点。
@@ -16,6 +16,8 @@ const (
 	PrincipalUser
 	// PrincipalAPIKey 通过 API Key 认证的调用方
 	PrincipalAPIKey
+	// PrincipalFederation 通过 federation_key 认证的联邦父实例
+	PrincipalFederation
 )
 
 // Principal 调用主体,携带身份信息和能力。
@@ -32,6 +34,7 @@ type Principal struct {
 	//   - PrincipalAnonymous → [RoleGuest]
 	//   - PrincipalAgent → [RoleClient]
 	//   - PrincipalUser / PrincipalAPIKey → [RoleAdmin]
+	//   - PrincipalFederation → [RoleGuest](仅豁免私有站点拦截)
 	// 未来可扩展为多角色(只读 admin / API Key scope 等)。
 	Roles []string
 }
@@ -71,6 +74,15 @@ func NewAPIKeyPrincipal() *Principal {
 	}
 }
 
+// NewFederationPrincipal 创建联邦父实例主体。权限等同访客(隐藏节点与敏感字段照常过滤),
+// 但在私有站点模式下仍可读取节点列表与状态。
+func NewFederationPrincipal() *Principal {
+	return &Principal{
+		Type:  PrincipalFederation,
+		Roles: []string{RoleGuest},
+	}
+}
+
 // PrimaryRole 返回主体的主要角色(兼容现有单角色模型)。
 // 多角色场景下返回权限等级最高的那个。
 func (p *Principal) PrimaryRole() string {
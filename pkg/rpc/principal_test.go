@@ -14,6 +14,7 @@ func TestPrincipalConstructors(t *testing.T) {
 		{"agent", NewAgentPrincipal("c-uuid"), PrincipalAgent, RoleClient, false},
 		{"user", NewUserPrincipal("u-uuid"), PrincipalUser, RoleAdmin, false},
 		{"apikey", NewAPIKeyPrincipal(), PrincipalAPIKey, RoleAdmin, true},
+		{"federation", NewFederationPrincipal(), PrincipalFederation, RoleGuest, false},
 	}
 	for _, tc := range cases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -0,0 +1,377 @@
+// Package selector 实现用于选取客户端的选择器表达式，例如：
+//
+//	group = "prod" and tag in ("db", "cache") and region != "CN"
+//
+// 语法：
+//
+//	expr       = term { "or" term }
+//	term       = factor { "and" factor }
+//	factor     = "not" factor | "(" expr ")" | comparison
+//	comparison = field ( "=" | "==" | "!=" | "=~" | "!~" ) value
+//	           | field [ "not" ] "in" "(" value { "," value } ")"
+//
+// 值可以是单/双引号字符串或不含空白与运算符的裸词。关键字不区分大小写，
+// 等值比较同样不区分大小写；=~ / !~ 的右侧为 Go 正则表达式。
+//
+// 字段可以有多个值（如 tag、group）：= / in / =~ 在任意一个值满足时成立，
+// != / not in / !~ 在没有任何值满足时成立。没有值的字段按空字符串参与比较，
+// 因此 group = "" 匹配未分组的客户端。
+package selector
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// Target 是被选择的对象，按字段名返回其取值。
+type Target interface {
+	Values(field string) []string
+}
+
+// Fields 是选择器支持的字段。
+var Fields = []string{"uuid", "name", "group", "tag", "region", "os", "arch", "virtualization", "hidden"}
+
+// Selector 是解析后的选择器表达式。
+type Selector struct {
+	src  string
+	root node
+}
+
+// Parse 解析选择器表达式。
+func Parse(expr string) (*Selector, error) {
+	toks, err := lex(expr)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, fmt.Errorf("selector is empty")
+	}
+	p := &parser{toks: toks}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if !p.done() {
+		return nil, fmt.Errorf("unexpected %s", p.peek())
+	}
+	return &Selector{src: strings.TrimSpace(expr), root: root}, nil
+}
+
+// Validate 检查表达式能否解析。
+func Validate(expr string) error {
+	_, err := Parse(expr)
+	return err
+}
+
+// Match 判断目标是否满足选择器。
+func (s *Selector) Match(t Target) bool {
+	return s.root.eval(t)
+}
+
+func (s *Selector) String() string {
+	return s.src
+}
+
+type node interface {
+	eval(Target) bool
+}
+
+type andNode struct{ left, right node }
+
+func (n andNode) eval(t Target) bool { return n.left.eval(t) && n.right.eval(t) }
+
+type orNode struct{ left, right node }
+
+func (n orNode) eval(t Target) bool { return n.left.eval(t) || n.right.eval(t) }
+
+type notNode struct{ inner node }
+
+func (n notNode) eval(t Target) bool { return !n.inner.eval(t) }
+
+// compareNode 在字段的任意一个值满足 match 时成立，negate 时取反。
+type compareNode struct {
+	field  string
+	match  func(string) bool
+	negate bool
+}
+
+func (n compareNode) eval(t Target) bool {
+	values := t.Values(n.field)
+	if len(values) == 0 {
+		values = []string{""}
+	}
+	for _, v := range values {
+		if n.match(v) {
+			return !n.negate
+		}
+	}
+	return n.negate
+}
+
+type tokenKind int
+
+const (
+	tokWord tokenKind = iota
+	tokString
+	tokOp
+	tokLParen
+	tokRParen
+	tokComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokString:
+		return fmt.Sprintf("string %q at %d", t.text, t.pos)
+	default:
+		return fmt.Sprintf("%q at %d", t.text, t.pos)
+	}
+}
+
+// keyword 判断裸词是否为指定关键字。带引号的字符串永远不是关键字。
+func (t token) keyword(kw string) bool {
+	return t.kind == tokWord && strings.EqualFold(t.text, kw)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || strings.ContainsRune("_-.:/*@+", r)
+}
+
+func lex(src string) ([]token, error) {
+	var toks []token
+	runes := []rune(src)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '(':
+			toks = append(toks, token{tokLParen, "(", i})
+			i++
+		case r == ')':
+			toks = append(toks, token{tokRParen, ")", i})
+			i++
+		case r == ',':
+			toks = append(toks, token{tokComma, ",", i})
+			i++
+		case r == '=' || r == '!':
+			start := i
+			i++
+			if i < len(runes) && (runes[i] == '=' || runes[i] == '~') {
+				i++
+			}
+			op := string(runes[start:i])
+			if op == "!" {
+				return nil, fmt.Errorf("unexpected '!' at %d, use 'not', '!=' or '!~'", start)
+			}
+			toks = append(toks, token{tokOp, op, start})
+		case r == '"' || r == '\'':
+			start := i
+			quote := r
+			i++
+			var sb strings.Builder
+			closed := false
+			for i < len(runes) {
+				c := runes[i]
+				if c == '\\' && i+1 < len(runes) {
+					sb.WriteRune(runes[i+1])
+					i += 2
+					continue
+				}
+				i++
+				if c == quote {
+					closed = true
+					break
+				}
+				sb.WriteRune(c)
+			}
+			if !closed {
+				return nil, fmt.Errorf("unterminated string starting at %d", start)
+			}
+			toks = append(toks, token{tokString, sb.String(), start})
+		case isWordRune(r):
+			start := i
+			for i < len(runes) && isWordRune(runes[i]) {
+				i++
+			}
+			toks = append(toks, token{tokWord, string(runes[start:i]), start})
+		default:
+			return nil, fmt.Errorf("unexpected character %q at %d", r, i)
+		}
+	}
+	return toks, nil
+}
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func (p *parser) done() bool { return p.pos >= len(p.toks) }
+
+func (p *parser) peek() token {
+	if p.done() {
+		return token{kind: tokWord, text: "end of expression", pos: -1}
+	}
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	p.pos++
+	return t
+}
+
+func (p *parser) parseOr() (node, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && p.peek().keyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = orNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (node, error) {
+	left, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for !p.done() && p.peek().keyword("and") {
+		p.next()
+		right, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		left = andNode{left, right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseNot() (node, error) {
+	if p.done() {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if p.peek().keyword("not") {
+		p.next()
+		inner, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return notNode{inner}, nil
+	}
+	if p.peek().kind == tokLParen {
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek().kind != tokRParen {
+			return nil, fmt.Errorf("expected ')' but got %s", p.peek())
+		}
+		p.next()
+		return inner, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *parser) parseComparison() (node, error) {
+	ft := p.next()
+	if ft.kind != tokWord {
+		return nil, fmt.Errorf("expected field name but got %s", ft)
+	}
+	field := strings.ToLower(ft.text)
+	if !knownField(field) {
+		return nil, fmt.Errorf("unknown field %q at %d, expected one of %s", ft.text, ft.pos, strings.Join(Fields, ", "))
+	}
+
+	op := p.next()
+	switch {
+	case op.kind == tokOp:
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		switch op.text {
+		case "=", "==", "!=":
+			return compareNode{field: field, match: equalFold(value), negate: op.text == "!="}, nil
+		case "=~", "!~":
+			re, err := regexp.Compile(value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid regular expression %q: %v", value, err)
+			}
+			return compareNode{field: field, match: re.MatchString, negate: op.text == "!~"}, nil
+		}
+	case op.keyword("in"):
+		return p.parseIn(field, false)
+	case op.keyword("not") && p.peek().keyword("in"):
+		p.next()
+		return p.parseIn(field, true)
+	}
+	return nil, fmt.Errorf("expected operator after %q but got %s", ft.text, op)
+}
+
+func (p *parser) parseIn(field string, negate bool) (node, error) {
+	if p.peek().kind != tokLParen {
+		return nil, fmt.Errorf("expected '(' after in but got %s", p.peek())
+	}
+	p.next()
+	var values []string
+	for {
+		value, err := p.parseValue()
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		t := p.next()
+		if t.kind == tokRParen {
+			break
+		}
+		if t.kind != tokComma {
+			return nil, fmt.Errorf("expected ',' or ')' but got %s", t)
+		}
+	}
+	return compareNode{field: field, negate: negate, match: func(v string) bool {
+		for _, want := range values {
+			if strings.EqualFold(v, want) {
+				return true
+			}
+		}
+		return false
+	}}, nil
+}
+
+func (p *parser) parseValue() (string, error) {
+	t := p.next()
+	if t.kind == tokString || t.kind == tokWord && t.pos >= 0 {
+		return t.text, nil
+	}
+	return "", fmt.Errorf("expected value but got %s", t)
+}
+
+func equalFold(want string) func(string) bool {
+	return func(v string) bool { return strings.EqualFold(v, want) }
+}
+
+func knownField(field string) bool {
+	for _, f := range Fields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
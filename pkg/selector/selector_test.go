@@ -0,0 +1,65 @@
+package selector
+
+import "testing"
+
+type fakeTarget map[string][]string
+
+func (f fakeTarget) Values(field string) []string { return f[field] }
+
+func TestSelectorMatch(t *testing.T) {
+	db := fakeTarget{
+		"name":   {"hk-db-1"},
+		"group":  {"prod", "HK"},
+		"tag":    {"db", "ssd"},
+		"region": {"HK"},
+	}
+	ungrouped := fakeTarget{"name": {"lab"}, "region": {"CN"}}
+
+	tests := []struct {
+		expr      string
+		db, other bool
+	}{
+		{`group = "prod" and tag in ("db","cache") and region != "CN"`, true, false},
+		{`group = PROD`, true, false},
+		{`group = hk`, true, false},
+		{`group = ""`, false, true},
+		{`tag != ssd`, false, true},
+		{`tag not in (db, cache)`, false, true},
+		{`not (region = CN) and name =~ "^hk-"`, true, false},
+		{`name !~ "^hk-"`, false, true},
+		{`region = CN or tag = db`, true, true},
+		{`region = CN or tag = db and region = US`, false, true},
+	}
+	for _, tt := range tests {
+		s, err := Parse(tt.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tt.expr, err)
+		}
+		if got := s.Match(db); got != tt.db {
+			t.Errorf("%q on db = %v, want %v", tt.expr, got, tt.db)
+		}
+		if got := s.Match(ungrouped); got != tt.other {
+			t.Errorf("%q on ungrouped = %v, want %v", tt.expr, got, tt.other)
+		}
+	}
+}
+
+func TestSelectorParseErrors(t *testing.T) {
+	for _, expr := range []string{
+		``,
+		`group`,
+		`color = red`,
+		`group = "prod`,
+		`tag in (db, cache`,
+		`tag in db`,
+		`(group = prod`,
+		`group = prod and`,
+		`group = prod region = HK`,
+		`name =~ "("`,
+		`! group = prod`,
+	} {
+		if _, err := Parse(expr); err == nil {
+			t.Errorf("Parse(%q) should fail", expr)
+		}
+	}
+}
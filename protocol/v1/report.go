@@ -24,6 +24,11 @@ type Report struct {
 	Network     NetworkReport     `json:"network"`
 	Connections ConnectionsReport `json:"connections"`
 	GPU         *GPUDetailReport  `json:"gpu,omitempty"`
+	Mounts      []MountReport     `json:"mounts,omitempty"`
+	Interfaces  []InterfaceReport `json:"interfaces,omitempty"`
+	DiskIO      []DiskIOReport    `json:"disk_io,omitempty"`
+	Processes   *ProcessReport    `json:"top_processes,omitempty"`
+	Containers  []ContainerReport `json:"containers,omitempty"`
 	Uptime      int64             `json:"uptime"`
 	Process     int               `json:"process"`
 	Message     string            `json:"message"`
@@ -74,6 +79,76 @@ type DiskReport struct {
 	Used  int64 `json:"used"`
 }
 
+// MountReport describes one mounted filesystem. Mounts, Interfaces and DiskIO
+// are optional per-device details sent by v2 agents next to the totals.
+type MountReport struct {
+	Path        string `json:"path"`
+	FSType      string `json:"fs_type,omitempty"`
+	Total       int64  `json:"total"`
+	Used        int64  `json:"used"`
+	InodesTotal int64  `json:"inodes_total,omitempty"`
+	InodesUsed  int64  `json:"inodes_used,omitempty"`
+}
+
+// InterfaceReport describes one network interface. Rates are bytes per second;
+// counters are cumulative since the interface came up.
+type InterfaceReport struct {
+	Name     string `json:"name"`
+	RxRate   int64  `json:"rx_rate"`
+	TxRate   int64  `json:"tx_rate"`
+	RxBytes  int64  `json:"rx_bytes"`
+	TxBytes  int64  `json:"tx_bytes"`
+	RxErrors int64  `json:"rx_errors,omitempty"`
+	TxErrors int64  `json:"tx_errors,omitempty"`
+	RxDrops  int64  `json:"rx_drops,omitempty"`
+	TxDrops  int64  `json:"tx_drops,omitempty"`
+}
+
+// DiskIOReport describes the IO rates of one block device. Utilization is the
+// percentage of time the device was busy.
+type DiskIOReport struct {
+	Device      string  `json:"device"`
+	ReadRate    int64   `json:"read_rate"`
+	WriteRate   int64   `json:"write_rate"`
+	ReadIOPS    float64 `json:"read_iops"`
+	WriteIOPS   float64 `json:"write_iops"`
+	Utilization float64 `json:"utilization"`
+}
+
+// ProcessReport is an optional snapshot of the heaviest processes, sorted by
+// CPU usage and by resident memory. Agents send it at most every few reports.
+type ProcessReport struct {
+	ByCPU    []ProcessInfo `json:"by_cpu,omitempty"`
+	ByMemory []ProcessInfo `json:"by_memory,omitempty"`
+}
+
+type ProcessInfo struct {
+	PID     int     `json:"pid"`
+	Name    string  `json:"name"`
+	User    string  `json:"user,omitempty"`
+	Cmdline string  `json:"cmdline,omitempty"`
+	CPU     float64 `json:"cpu"`
+	RSS     int64   `json:"rss"`
+}
+
+// ContainerReport describes one Docker/Podman container. Agents that support
+// containers send the full list on every report, an empty list included, so
+// the server can tell removed containers apart from agents without support.
+type ContainerReport struct {
+	ID           string  `json:"id"`
+	Name         string  `json:"name"`
+	Image        string  `json:"image,omitempty"`
+	State        string  `json:"state"` // created, running, restarting, paused, exited, dead
+	ExitCode     int     `json:"exit_code,omitempty"`
+	OOMKilled    bool    `json:"oom_killed,omitempty"`
+	RestartCount int     `json:"restart_count"`
+	CPU          float64 `json:"cpu"`
+	MemoryUsed   int64   `json:"memory_used"`
+	MemoryLimit  int64   `json:"memory_limit,omitempty"`
+	NetRx        int64   `json:"net_rx"` // cumulative bytes
+	NetTx        int64   `json:"net_tx"`
+}
+
 type NetworkReport struct {
 	Up        int64 `json:"up"`
 	Down      int64 `json:"down"`
@@ -7,17 +7,26 @@ import (
 )
 
 const (
-	Version               = "2.0"
-	MethodAgentReport     = "agent.report"
-	MethodAgentBasicInfo  = "agent.basicInfo"
-	MethodAgentPingResult = "agent.pingResult"
-	MethodAgentTaskResult = "agent.taskResult"
-	MethodAgentExec       = "agent.exec"
-	MethodAgentPing       = "agent.ping"
-	MethodAgentMessage    = "agent.message"
-	MethodAgentEvent      = "agent.event"
-	MethodAgentTerminal   = "agent.terminal.request"
-	MethodAgentPull       = "agent.pull"
+	Version                 = "2.0"
+	MethodAgentReport       = "agent.report"
+	MethodAgentReportBatch  = "agent.reportBatch"
+	MethodAgentBasicInfo    = "agent.basicInfo"
+	MethodAgentPingResult   = "agent.pingResult"
+	MethodAgentTaskResult   = "agent.taskResult"
+	MethodAgentExec         = "agent.exec"
+	MethodAgentPing         = "agent.ping"
+	MethodAgentMessage      = "agent.message"
+	MethodAgentEvent        = "agent.event"
+	MethodAgentTerminal     = "agent.terminal.request"
+	MethodAgentPull         = "agent.pull"
+	MethodAgentToken        = "agent.rotateToken"
+	MethodAgentUpdate       = "agent.update"
+	MethodAgentConfig       = "agent.config"
+	MethodAgentServices     = "agent.services"
+	MethodAgentServiceStat  = "agent.serviceStatus"
+	MethodAgentLogs         = "agent.logs"
+	MethodAgentFileTransfer = "agent.fileTransfer"
+	MethodAgentTunnel       = "agent.tunnel"
 )
 
 type Request struct {
@@ -53,6 +62,13 @@ type ReportParams struct {
 	AckEventIDs []string  `json:"ack_event_ids,omitempty"`
 }
 
+// ReportBatchParams 补传 Agent 断网期间缓存的上报。每条 Report 必须携带采集时间 UpdatedAt，
+// 且按时间严格递增；服务端按原始时间写入历史数据，不会更新实时状态。
+type ReportBatchParams struct {
+	Reports     []v1.Report `json:"reports"`
+	AckEventIDs []string    `json:"ack_event_ids,omitempty"`
+}
+
 type BasicInfoParams struct {
 	Info map[string]interface{} `json:"info"`
 }
@@ -81,21 +97,143 @@ type PingParams struct {
 	Target string `json:"ping_target"`
 }
 
+// MessageParams 是 Agent 发送的文本消息，服务端按 info 级别的事件记录。
 type MessageParams struct {
 	Type    string `json:"type"`
 	Message string `json:"message"`
 	Data    any    `json:"data,omitempty"`
 }
 
+// EventParams 是 Agent（或主机上的脚本经由 Agent）上报的自定义事件，如 backup.finished。
+// Severity 取 info、warning 或 critical，为空时视为 info；Time 为空时使用服务端接收时间。
 type EventParams struct {
-	Type string `json:"type"`
-	Data any    `json:"data,omitempty"`
+	Type     string     `json:"type"`
+	Severity string     `json:"severity,omitempty"`
+	Message  string     `json:"message,omitempty"`
+	Data     any        `json:"data,omitempty"`
+	Time     *time.Time `json:"time,omitempty"`
+}
+
+// LogsParams 是 Agent 批量上报的日志行。Source 为 AgentConfig.LogSources 中的来源名称，
+// Time 为空时使用服务端接收时间。
+type LogsParams struct {
+	Lines []LogLine `json:"lines"`
+}
+
+type LogLine struct {
+	Source string    `json:"source"`
+	Time   time.Time `json:"time"`
+	Line   string    `json:"line"`
 }
 
 type TerminalRequestParams struct {
 	RequestID string `json:"request_id"`
 }
 
+// RotateTokenParams 通知 Agent 改用新令牌。PreviousTokenExpiresAt 为空表示旧令牌已立即失效。
+type RotateTokenParams struct {
+	Token                  string     `json:"token"`
+	PreviousTokenExpiresAt *time.Time `json:"previous_token_expires_at,omitempty"`
+}
+
+// UpdateParams 通知 Agent 下载并安装指定版本。URL 可以是相对于服务端地址的路径，
+// 此时下载请求需要携带客户端令牌；Agent 必须校验 SHA256 后再替换自身并重启。
+// 下载、校验或安装失败时，Agent 应上报类型为 EventUpdateFailed 的 agent.event，
+// Message 说明原因，Data 携带 UpdateFailedData。
+type UpdateParams struct {
+	RolloutID uint   `json:"rollout_id"`
+	Version   string `json:"version"`
+	URL       string `json:"url"`
+	SHA256    string `json:"sha256"`
+	Size      int64  `json:"size,omitempty"`
+}
+
+// EventUpdateFailed 是 Agent 报告自更新失败时使用的事件类型。
+const EventUpdateFailed = "agent.update.failed"
+
+// UpdateFailedData 是 EventUpdateFailed 事件的附带数据，RolloutID 取自 UpdateParams。
+type UpdateFailedData struct {
+	RolloutID uint   `json:"rollout_id"`
+	Version   string `json:"version,omitempty"`
+}
+
+// ConfigParams 下发服务端管理的 Agent 配置。Config 为空表示恢复使用本地配置。
+// Agent 应用后需在下一次 agent.report / agent.pull 的 ack_event_ids 中带上 EventID，
+// 服务端据此记录已生效的 Version。
+type ConfigParams struct {
+	EventID string `json:"event_id"`
+	Version string `json:"version"`
+	Profile string `json:"profile,omitempty"`
+	Config  any    `json:"config,omitempty"`
+}
+
+// ServicesParams 下发需要监控的服务列表，列表为空表示停止监控。Agent 应替换原有列表。
+type ServicesParams struct {
+	Services []ServiceDefinition `json:"services"`
+}
+
+type ServiceDefinition struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Type   string `json:"type"` // systemd | process
+	Target string `json:"target"`
+}
+
+// ServiceStatusParams 上报服务的检查结果。State 取 running、stopped、failed，
+// Restarts 为服务累计重启次数（systemd NRestarts 或观察到的进程重启次数）。
+type ServiceStatusParams struct {
+	Services []ServiceStatus `json:"services"`
+}
+
+type ServiceStatus struct {
+	ID       uint   `json:"id"`
+	State    string `json:"state"`
+	Restarts int    `json:"restarts,omitempty"`
+	Message  string `json:"message,omitempty"`
+}
+
+// FileTransferParams 通知 Agent 建立文件传输连接：Agent 携带 ?id=TransferID 以 WebSocket
+// 连接 /api/clients/file-transfer。Direction 为 upload 时服务端把文件写入 Agent 的 Path，
+// 为 download 时 Agent 读取 Path 发给服务端，文件不得超过 MaxSize 字节。
+type FileTransferParams struct {
+	TransferID string `json:"transfer_id"`
+	Direction  string `json:"direction"` // upload | download
+	Path       string `json:"path"`
+	Size       int64  `json:"size,omitempty"`   // upload 时的文件大小
+	SHA256     string `json:"sha256,omitempty"` // upload 时的文件校验和
+	Overwrite  bool   `json:"overwrite,omitempty"`
+	MaxSize    int64  `json:"max_size"`
+}
+
+// FileTransferFrame 是文件传输连接上的文本帧，文件内容以二进制帧按顺序传输。
+// upload：服务端发送内容后发送 end（Size、SHA256），Agent 写入并校验后回复 result。
+// download：Agent 先发送 meta（Size），再发送内容与 end（SHA256），服务端校验后回复 result。
+// 任一方出错时可直接发送 OK 为 false 的 result 并关闭连接。
+type FileTransferFrame struct {
+	Type   string `json:"type"` // meta | end | result
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+	OK     bool   `json:"ok,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// TunnelParams 通知 Agent 建立 TCP 转发隧道：Agent 携带 ?id=TunnelID 以 WebSocket 连接
+// /api/clients/tunnel，之后由服务端通过 open 帧按需要求 Agent 连接 Target（host:port，
+// 由 Agent 解析）。同一隧道上的多个 TCP 连接复用这条 WebSocket。
+type TunnelParams struct {
+	TunnelID string `json:"tunnel_id"`
+	Target   string `json:"target"`
+}
+
+// TunnelFrame 是隧道连接上的控制帧。数据以二进制帧传输，前 4 字节为大端序的 Stream，
+// 其余为该连接的字节流。服务端发送 open，Agent 连接 Target 后回复 opened，失败时回复
+// 带 Error 的 close；任一方都可以发送 close 结束一个连接。
+type TunnelFrame struct {
+	Type   string `json:"type"` // open | opened | close
+	Stream uint32 `json:"stream"`
+	Error  string `json:"error,omitempty"`
+}
+
 func Success(id any, result any) Response {
 	return Response{JSONRPC: Version, ID: id, Result: result}
 }
@@ -0,0 +1,74 @@
+package notifier
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentevents"
+	"github.com/komari-monitor/komari/database/models"
+	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+)
+
+type agentEventCooldownKey struct {
+	rule      uint
+	client    string
+	eventType string
+}
+
+var (
+	agentEventCooldownMu sync.Mutex
+	agentEventLastSent   = map[agentEventCooldownKey]time.Time{}
+)
+
+// NotifyAgentEvent 为匹配了通知规则的 Agent 事件发送通知。多条规则匹配时只发送一次，
+// 处于任一规则冷却期内的事件不发送。
+func NotifyAgentEvent(event agentevents.Event, rules []models.AgentEventRule) {
+	if len(rules) == 0 || !agentEventCooldownElapsed(event, rules, event.Time) {
+		return
+	}
+	message := fmt.Sprintf("[%s] %s", event.Severity, event.Type)
+	if event.Message != "" {
+		message += ": " + event.Message
+	}
+	go func() {
+		if err := messageSender.SendNotification(models.EventMessage{
+			Event:   messageevent.AgentEvent,
+			Clients: []models.Client{{UUID: event.ClientUUID}},
+			Time:    event.Time,
+			Emoji:   agentEventEmoji(event.Severity),
+			Message: message,
+		}); err != nil {
+			logger.Errorf("notifier", "Failed to send agent event notification for %s: %v", event.ClientUUID, err)
+		}
+	}()
+}
+
+// agentEventCooldownElapsed 检查并更新各规则的冷却时间。
+func agentEventCooldownElapsed(event agentevents.Event, rules []models.AgentEventRule, now time.Time) bool {
+	agentEventCooldownMu.Lock()
+	defer agentEventCooldownMu.Unlock()
+	for _, rule := range rules {
+		key := agentEventCooldownKey{rule.Id, event.ClientUUID, event.Type}
+		if last, ok := agentEventLastSent[key]; ok && now.Sub(last) < time.Duration(rule.Cooldown)*time.Second {
+			return false
+		}
+	}
+	for _, rule := range rules {
+		agentEventLastSent[agentEventCooldownKey{rule.Id, event.ClientUUID, event.Type}] = now
+	}
+	return true
+}
+
+func agentEventEmoji(severity string) string {
+	switch severity {
+	case models.AgentEventCritical:
+		return "🚨"
+	case models.AgentEventWarning:
+		return "⚠️"
+	default:
+		return "ℹ️"
+	}
+}
@@ -0,0 +1,132 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	"github.com/komari-monitor/komari/internal/config"
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+)
+
+const (
+	// 在 restartLoopWindow 内重启达到 restartLoopCount 次视为反复重启。
+	restartLoopCount  = 3
+	restartLoopWindow = 10 * time.Minute
+)
+
+// containerState 记录容器上一次上报的状态，用于比较状态变化。
+type containerState struct {
+	state     string
+	oomKilled bool
+	restarts  int
+	// restartTimes 为窗口内观察到重启的时间；loopNotified 避免同一轮反复重启重复通知。
+	restartTimes []time.Time
+	loopNotified bool
+}
+
+var (
+	containerStatesMu sync.Mutex
+	containerStates   = map[string]map[string]*containerState{}
+)
+
+// CheckContainerEvents 比较容器列表与上一次上报，在容器异常退出、被 OOM 杀死或
+// 反复重启时发送通知。首次见到的容器只记录状态，不产生通知。
+func CheckContainerEvents(clientUUID string, containers []v1.ContainerReport, now time.Time) {
+	containerStatesMu.Lock()
+	previous := containerStates[clientUUID]
+	next, events := detectContainerEvents(previous, containers, now)
+	containerStates[clientUUID] = next
+	containerStatesMu.Unlock()
+
+	if len(events) == 0 {
+		return
+	}
+	enabled, err := config.GetAs[bool](config.ContainerNotificationKey, true)
+	if err != nil || !enabled {
+		return
+	}
+	go func() {
+		if err := messageSender.SendNotification(models.EventMessage{
+			Event:   messageevent.Container,
+			Clients: []models.Client{{UUID: clientUUID}},
+			Time:    now.UTC(),
+			Emoji:   "🐳",
+			Message: strings.Join(events, "\n"),
+		}); err != nil {
+			logger.Errorf("notifier", "Failed to send container notification for %s: %v", clientUUID, err)
+		}
+	}()
+}
+
+// detectContainerEvents 返回新的容器状态表与需要通知的事件描述。
+func detectContainerEvents(previous map[string]*containerState, containers []v1.ContainerReport, now time.Time) (map[string]*containerState, []string) {
+	next := make(map[string]*containerState, len(containers))
+	var events []string
+	for _, container := range containers {
+		if container.ID == "" {
+			continue
+		}
+		label := containerLabel(container)
+		prev, seen := previous[container.ID]
+		current := &containerState{
+			state:     container.State,
+			oomKilled: container.OOMKilled,
+			restarts:  container.RestartCount,
+		}
+		next[container.ID] = current
+		if !seen {
+			continue
+		}
+
+		current.restartTimes = pruneRestartTimes(prev.restartTimes, now)
+		current.loopNotified = prev.loopNotified && len(current.restartTimes) > 0
+		if increase := container.RestartCount - prev.restarts; increase > 0 {
+			for i := 0; i < increase; i++ {
+				current.restartTimes = append(current.restartTimes, now)
+			}
+		}
+
+		stopped := container.State == "exited" || container.State == "dead"
+		switch {
+		case container.OOMKilled && !prev.oomKilled:
+			events = append(events, fmt.Sprintf("%s was OOM-killed", label))
+		case stopped && prev.state == "running":
+			events = append(events, fmt.Sprintf("%s died with exit code %d", label, container.ExitCode))
+		}
+		if len(current.restartTimes) >= restartLoopCount && !current.loopNotified {
+			current.loopNotified = true
+			events = append(events, fmt.Sprintf("%s restarted %d times in %s", label, len(current.restartTimes), restartLoopWindow))
+		}
+	}
+	return next, events
+}
+
+func pruneRestartTimes(times []time.Time, now time.Time) []time.Time {
+	kept := make([]time.Time, 0, len(times))
+	for _, t := range times {
+		if now.Sub(t) < restartLoopWindow {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
+
+func containerLabel(container v1.ContainerReport) string {
+	name := container.Name
+	if name == "" {
+		name = container.ID
+		if len(name) > 12 {
+			name = name[:12]
+		}
+	}
+	if container.Image != "" {
+		return fmt.Sprintf("Container %s (%s)", name, container.Image)
+	}
+	return "Container " + name
+}
@@ -0,0 +1,44 @@
+package notifier
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	v1 "github.com/komari-monitor/komari/protocol/v1"
+)
+
+func TestDetectContainerEvents(t *testing.T) {
+	now := time.Now().UTC()
+	web := v1.ContainerReport{ID: "abc", Name: "web", Image: "nginx", State: "running"}
+	states, events := detectContainerEvents(nil, []v1.ContainerReport{web}, now)
+	if len(events) != 0 {
+		t.Fatalf("first observation events = %v, want none", events)
+	}
+
+	web.State, web.ExitCode = "exited", 137
+	states, events = detectContainerEvents(states, []v1.ContainerReport{web}, now.Add(time.Minute))
+	if len(events) != 1 || !strings.Contains(events[0], "web (nginx) died with exit code 137") {
+		t.Fatalf("died events = %v", events)
+	}
+
+	web.State, web.OOMKilled = "exited", true
+	states, events = detectContainerEvents(states, []v1.ContainerReport{web}, now.Add(2*time.Minute))
+	if len(events) != 1 || !strings.Contains(events[0], "OOM-killed") {
+		t.Fatalf("OOM events = %v", events)
+	}
+
+	web.OOMKilled = false
+	for i := 1; i <= 3; i++ {
+		web.State, web.RestartCount = "restarting", i
+		states, events = detectContainerEvents(states, []v1.ContainerReport{web}, now.Add(time.Duration(2+i)*time.Minute))
+	}
+	if len(events) != 1 || !strings.Contains(events[0], "restarted 3 times") {
+		t.Fatalf("restart loop events = %v", events)
+	}
+	web.RestartCount = 4
+	_, events = detectContainerEvents(states, []v1.ContainerReport{web}, now.Add(6*time.Minute))
+	if len(events) != 0 {
+		t.Fatalf("repeated restart loop events = %v, want none", events)
+	}
+}
@@ -7,10 +7,13 @@ import (
 	"time"
 
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	"github.com/komari-monitor/komari/database/processsnapshots"
 	"github.com/komari-monitor/komari/database/records"
+	"github.com/komari-monitor/komari/internal/metricstore"
 	"github.com/komari-monitor/komari/internal/scheduler"
 	logger "github.com/komari-monitor/komari/utils/log"
 	"github.com/komari-monitor/komari/utils/messageSender"
@@ -67,7 +70,19 @@ func executeLoadNotificationTask(task models.LoadNotification) {
 	now := time.Now().UTC()
 	windowStart := now.Add(-time.Duration(task.Interval) * time.Minute)
 	overloadClients := make([]string, 0)
-	for _, clientUUID := range task.Clients {
+	for _, clientUUID := range clientselector.Resolve(task.Clients, task.Selector) {
+		// 挂载点、网卡与磁盘指标按设备标签存储，取各时间桶内所有匹配设备的最大值。
+		if metricstore.IsDeviceNotificationMetric(task.Metric) {
+			values, err := records.GetDeviceMetricMaxByClientAndTime(clientUUID, task.Metric, windowStart, now)
+			if err != nil {
+				continue
+			}
+			if checkValueThreshold(values, task) {
+				overloadClients = append(overloadClients, clientUUID)
+			}
+			continue
+		}
+
 		// 仅查询当前通知使用的指标，避免重建完整监控记录。
 		records, err := getMetricRecordsForClient(clientUUID, task.Metric, windowStart, now)
 		if err != nil {
@@ -126,6 +141,24 @@ func checkMetricThreshold(records []models.Record, task models.LoadNotification)
 	return exceededCount >= minRequiredRecords
 }
 
+// checkValueThreshold 检查设备指标的各时间桶最大值是否达到阈值
+func checkValueThreshold(values []float64, task models.LoadNotification) bool {
+	if len(values) == 0 {
+		return false
+	}
+	minRequiredRecords := int(float32(len(values)) * task.Ratio)
+	if minRequiredRecords == 0 {
+		minRequiredRecords = 1
+	}
+	exceededCount := 0
+	for _, value := range values {
+		if float32(value) >= task.Threshold {
+			exceededCount++
+		}
+	}
+	return exceededCount >= minRequiredRecords
+}
+
 // getMetricValue 根据指标名称获取记录中的对应值
 func getMetricValue(record models.Record, metric string) float32 {
 	switch metric {
@@ -203,17 +236,27 @@ func sendLoadNotification(clientUUIDs []string, task models.LoadNotification) {
 	if len(clientUUIDs) == 0 {
 		return
 	}
+	now := time.Now().UTC()
 	eventClients := make([]models.Client, 0, len(clientUUIDs))
+	message := task.Name
 	for _, uuid := range clientUUIDs {
 		eventClients = append(eventClients, models.Client{UUID: uuid})
+		// 附带告警时刻的高占用进程，便于定位原因。
+		name := ""
+		if client, err := clients.GetClientByUUID(uuid); err == nil {
+			name = client.Name
+		}
+		if summary := processsnapshots.AlertSummary(uuid, name, now); summary != "" {
+			message += "\n" + summary
+		}
 	}
 	go func() {
 		if err := messageSender.SendNotification(models.EventMessage{
 			Event:   messageevent.Alert,
 			Clients: eventClients,
-			Time:    time.Now().UTC(),
+			Time:    now,
 			Emoji:   "⚠️",
-			Message: task.Name,
+			Message: message,
 		}); err != nil {
 			logger.Errorf("notifier", "Failed to send load notification for task %d: %v", task.Id, err)
 		}
@@ -0,0 +1,38 @@
+package notifier
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentlogs"
+	"github.com/komari-monitor/komari/database/models"
+	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+)
+
+// maxLogSampleLength 限制通知中附带的日志行长度。
+const maxLogSampleLength = 300
+
+// NotifyLogAlerts 为触发的日志告警发送通知，每条规则一条消息。
+func NotifyLogAlerts(alerts []agentlogs.Alert, now time.Time) {
+	for _, alert := range alerts {
+		message := fmt.Sprintf("%s: %d lines matching %q in the last %d minutes", alert.Rule.Name, alert.Count, alert.Rule.Pattern, alert.Rule.Window)
+		if sample := []rune(alert.Sample); len(sample) > maxLogSampleLength {
+			message += "\n" + string(sample[:maxLogSampleLength]) + "…"
+		} else if len(sample) > 0 {
+			message += "\n" + alert.Sample
+		}
+		go func(clientUUID, message string) {
+			if err := messageSender.SendNotification(models.EventMessage{
+				Event:   messageevent.Log,
+				Clients: []models.Client{{UUID: clientUUID}},
+				Time:    now.UTC(),
+				Emoji:   "📜",
+				Message: message,
+			}); err != nil {
+				logger.Errorf("notifier", "Failed to send log alert for %s: %v", clientUUID, err)
+			}
+		}(alert.ClientUUID, message)
+	}
+}
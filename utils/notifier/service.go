@@ -0,0 +1,54 @@
+package notifier
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	"github.com/komari-monitor/komari/database/servicemonitors"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+)
+
+// NotifyServiceTransitions 为受监控服务的状态变化发送通知，同一客户端的变化合并为一条消息。
+func NotifyServiceTransitions(clientUUID string, transitions []servicemonitors.Transition, now time.Time) {
+	if len(transitions) == 0 {
+		return
+	}
+	lines := make([]string, 0, len(transitions))
+	emoji := "🟢"
+	for _, t := range transitions {
+		line := fmt.Sprintf("Service %s %s", t.Service, serviceEventText(t.Event))
+		if t.Message != "" && t.Event != "recovered" {
+			line += ": " + t.Message
+		}
+		lines = append(lines, line)
+		if t.Event != "recovered" {
+			emoji = "🔧"
+		}
+	}
+	go func() {
+		if err := messageSender.SendNotification(models.EventMessage{
+			Event:   messageevent.Service,
+			Clients: []models.Client{{UUID: clientUUID}},
+			Time:    now.UTC(),
+			Emoji:   emoji,
+			Message: strings.Join(lines, "\n"),
+		}); err != nil {
+			logger.Errorf("notifier", "Failed to send service notification for %s: %v", clientUUID, err)
+		}
+	}()
+}
+
+func serviceEventText(event string) string {
+	switch event {
+	case "restarting":
+		return "is restarting repeatedly"
+	case "recovered":
+		return "is running again"
+	default:
+		return event
+	}
+}
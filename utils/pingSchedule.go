@@ -6,6 +6,7 @@ import (
 	"sync"
 	"time"
 
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/internal/scheduler"
 	v2 "github.com/komari-monitor/komari/protocol/v2"
@@ -82,9 +83,10 @@ func executePingTask(ctx context.Context, task models.PingTask) {
 	}
 }
 
-// targetPingClientUUIDs 根据任务配置计算本次调度需要下发的在线服务器列表。
+// targetPingClientUUIDs 根据任务配置计算本次调度需要下发的服务器列表：
+// 静态 Clients 与选择器当前匹配的服务器取并集。
 func targetPingClientUUIDs(task models.PingTask) []string {
-	return task.Clients
+	return clientselector.Resolve(task.Clients, task.Selector)
 }
 
 // ReloadPingSchedule 加载或重载时间表
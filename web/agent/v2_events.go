@@ -15,27 +15,138 @@ const (
 	v2EventQueueLimit = 128
 	v2EventTTL        = 5 * time.Minute
 	v2PingEventTTL    = 3 * time.Second
+	v2TokenEventTTL   = 24 * time.Hour
+	// V2EventMaxAttempts 是事件未被确认时的最大下发次数，超过后事件被丢弃。
+	V2EventMaxAttempts = 10
 )
 
+// 事件离开队列（未被确认）的原因，与 models.AgentEvent* 状态一致。
+const (
+	V2EventExpired   = "expired"
+	V2EventFailed    = "failed"
+	V2EventCancelled = "cancelled"
+	V2EventReplaced  = "replaced"
+)
+
+// V2EventStore 接收事件队列的变化，用于持久化（见 database/agentoutbox）。
+// 方法在队列锁之外调用。
+type V2EventStore interface {
+	// Persist 在事件入队前调用，返回 true 表示事件已持久化；可调整 event.ExpiresAt。
+	Persist(uuid string, event *v2.Event) bool
+	// Delivered 记录已随响应下发的持久化事件。
+	Delivered(uuid string, ids []string)
+	// Acked 记录 Agent 已确认的持久化事件。
+	Acked(uuid string, ids []string)
+	// Dropped 记录未被确认就离开队列的事件（包括未持久化的事件），reason 见 V2Event* 常量。
+	Dropped(uuid string, events []v2.Event, reason string)
+}
+
+// V2EventInfo 是队列中事件的快照。
+type V2EventInfo struct {
+	v2.Event
+	Attempts int  `json:"attempts"`
+	Durable  bool `json:"durable"`
+}
+
+type v2EventState struct {
+	attempts int
+	durable  bool
+}
+
 type v2EventQueue struct {
 	events []v2.Event
+	state  map[string]*v2EventState
 	signal chan struct{}
 }
 
+// v2EventDrop 是队列锁内收集、解锁后交给 V2EventStore 的丢弃事件。
+type v2EventDrop struct {
+	uuid   string
+	events []v2.Event
+	reason string
+}
+
 var (
 	v2EventMu     sync.Mutex
 	v2EventQueues = make(map[string]*v2EventQueue)
+	v2EventStore  V2EventStore
 )
 
+// SetV2EventStore 设置事件队列的持久化存储，nil 表示仅保存在内存中。
+func SetV2EventStore(store V2EventStore) {
+	v2EventMu.Lock()
+	defer v2EventMu.Unlock()
+	v2EventStore = store
+}
+
+func currentV2EventStore() V2EventStore {
+	v2EventMu.Lock()
+	defer v2EventMu.Unlock()
+	return v2EventStore
+}
+
 func getV2EventQueueLocked(uuid string) *v2EventQueue {
 	q := v2EventQueues[uuid]
 	if q == nil {
-		q = &v2EventQueue{signal: make(chan struct{})}
+		q = &v2EventQueue{state: make(map[string]*v2EventState), signal: make(chan struct{})}
 		v2EventQueues[uuid] = q
 	}
 	return q
 }
 
+func (q *v2EventQueue) stateOf(id string) *v2EventState {
+	st := q.state[id]
+	if st == nil {
+		st = &v2EventState{}
+		q.state[id] = st
+	}
+	return st
+}
+
+// remove 从队列中移除 keep 返回 false 的事件并返回它们。
+func (q *v2EventQueue) remove(keep func(v2.Event) bool) []v2.Event {
+	var removed []v2.Event
+	filtered := q.events[:0]
+	for _, event := range q.events {
+		if keep(event) {
+			filtered = append(filtered, event)
+			continue
+		}
+		removed = append(removed, event)
+		delete(q.state, event.ID)
+	}
+	clear(q.events[len(filtered):])
+	q.events = filtered
+	return removed
+}
+
+func (q *v2EventQueue) notify() {
+	close(q.signal)
+	q.signal = make(chan struct{})
+}
+
+func reportV2EventDrops(store V2EventStore, drops []v2EventDrop) {
+	if store == nil {
+		return
+	}
+	for _, drop := range drops {
+		if len(drop.events) > 0 {
+			store.Dropped(drop.uuid, drop.events, drop.reason)
+		}
+	}
+}
+
+// durableIDsLocked 过滤出已持久化的事件 ID，避免对内存事件访问存储。
+func durableIDsLocked(q *v2EventQueue, ids []string) []string {
+	var durable []string
+	for _, id := range ids {
+		if st := q.state[id]; st != nil && st.durable {
+			durable = append(durable, id)
+		}
+	}
+	return durable
+}
+
 func DispatchV2Event(uuid, method string, params any) bool {
 	if conn := GetConnectedClients()[uuid]; conn != nil {
 		payload := v2.Request{JSONRPC: v2.Version, Method: method, Params: params}
@@ -74,36 +185,153 @@ func IsAgentOnline(uuid string) bool {
 	return IsV2Client(uuid)
 }
 
+// DispatchV2EventWithID 与 DispatchV2Event 相同，但使用调用方生成的事件 ID（见 NewV2EventID），
+// 用于参数中需要携带事件 ID、并等待 Agent 在 ack_event_ids 中确认的事件。
+func DispatchV2EventWithID(uuid, id, method string, params any) bool {
+	if conn := GetConnectedClients()[uuid]; conn != nil {
+		payload := v2.Request{JSONRPC: v2.Version, Method: method, Params: params}
+		if conn.WriteJSON(payload) == nil {
+			return true
+		}
+	}
+	if !IsV2Client(uuid) {
+		return false
+	}
+	enqueueV2Event(uuid, id, method, params)
+	return true
+}
+
 func EnqueueV2Event(uuid, method string, params any) v2.Event {
+	return enqueueV2Event(uuid, NewV2EventID(), method, params)
+}
+
+func enqueueV2Event(uuid, id, method string, params any) v2.Event {
+	event := newV2Event(id, method, params)
+	store := currentV2EventStore()
+	durable := store != nil && store.Persist(uuid, &event)
+
+	v2EventMu.Lock()
+	q := getV2EventQueueLocked(uuid)
+	drops := []v2EventDrop{
+		{uuid, pruneExpiredV2EventsLocked(q), V2EventExpired},
+		{uuid, coalesceV2EventLocked(q, event), V2EventReplaced},
+	}
+	q.events = append(q.events, event)
+	q.stateOf(event.ID).durable = durable
+	if len(q.events) > v2EventQueueLimit {
+		overflow := append([]v2.Event(nil), q.events[:len(q.events)-v2EventQueueLimit]...)
+		for _, dropped := range overflow {
+			delete(q.state, dropped.ID)
+		}
+		q.events = append(q.events[:0], q.events[len(q.events)-v2EventQueueLimit:]...)
+		drops = append(drops, v2EventDrop{uuid, overflow, V2EventFailed})
+	}
+	q.notify()
+	v2EventMu.Unlock()
+
+	reportV2EventDrops(store, drops)
+	return event
+}
+
+func newV2Event(id, method string, params any) v2.Event {
 	now := time.Now().UTC()
 	ttl := v2EventTTL
-	if method == v2.MethodAgentPing {
+	switch method {
+	case v2.MethodAgentPing:
 		ttl = v2PingEventTTL
+	case v2.MethodAgentToken:
+		// 令牌事件需要在整个重叠期内可取，Agent 可能长时间未拉取。
+		ttl = v2TokenEventTTL
 	}
-	event := v2.Event{
-		ID:        newV2EventID(),
+	return v2.Event{
+		ID:        id,
 		Method:    method,
 		Params:    params,
 		CreatedAt: now,
 		ExpiresAt: now.Add(ttl),
 	}
+}
 
+// RestoreV2Events 将持久化的事件放回内存队列（服务端启动时），并将客户端视为 v2 Agent。
+// attempts 为事件已下发的次数。
+func RestoreV2Events(uuid string, events []v2.Event, attempts map[string]int) {
+	if len(events) == 0 {
+		return
+	}
+	SetClientProtocolVersion(uuid, 2)
 	v2EventMu.Lock()
+	defer v2EventMu.Unlock()
 	q := getV2EventQueueLocked(uuid)
-	pruneExpiredV2EventsLocked(q)
-	coalesceV2EventLocked(q, event)
-	q.events = append(q.events, event)
-	if len(q.events) > v2EventQueueLimit {
-		q.events = q.events[len(q.events)-v2EventQueueLimit:]
+	for _, event := range events {
+		if _, exists := q.state[event.ID]; exists {
+			continue
+		}
+		q.events = append(q.events, event)
+		st := q.stateOf(event.ID)
+		st.durable = true
+		st.attempts = attempts[event.ID]
 	}
-	close(q.signal)
-	q.signal = make(chan struct{})
+	q.notify()
+}
+
+// CancelV2Event 从队列中移除尚未确认的事件。
+func CancelV2Event(uuid, id string) (v2.Event, bool) {
+	v2EventMu.Lock()
+	q := v2EventQueues[uuid]
+	if q == nil {
+		v2EventMu.Unlock()
+		return v2.Event{}, false
+	}
+	removed := q.remove(func(event v2.Event) bool { return event.ID != id })
+	store := v2EventStore
 	v2EventMu.Unlock()
 
-	return event
+	if len(removed) == 0 {
+		return v2.Event{}, false
+	}
+	reportV2EventDrops(store, []v2EventDrop{{uuid, removed, V2EventCancelled}})
+	return removed[0], true
 }
 
-func newV2EventID() string {
+// PendingV2Events 返回客户端队列中尚未确认的事件。
+func PendingV2Events(uuid string) []V2EventInfo {
+	v2EventMu.Lock()
+	defer v2EventMu.Unlock()
+	q := v2EventQueues[uuid]
+	if q == nil {
+		return []V2EventInfo{}
+	}
+	infos := make([]V2EventInfo, 0, len(q.events))
+	for _, event := range q.events {
+		info := V2EventInfo{Event: event}
+		if st := q.state[event.ID]; st != nil {
+			info.Attempts = st.attempts
+			info.Durable = st.durable
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// PruneV2Events 清理所有队列中过期的事件。离线 Agent 不会再拉取，过期事件需要
+// 定期清理才能通知存储（例如将 exec 任务标记为失败）。
+func PruneV2Events() {
+	v2EventMu.Lock()
+	var drops []v2EventDrop
+	for uuid, q := range v2EventQueues {
+		drops = append(drops, v2EventDrop{uuid, pruneExpiredV2EventsLocked(q), V2EventExpired})
+		if len(q.events) == 0 {
+			delete(v2EventQueues, uuid)
+			close(q.signal)
+		}
+	}
+	store := v2EventStore
+	v2EventMu.Unlock()
+	reportV2EventDrops(store, drops)
+}
+
+// NewV2EventID 生成事件 ID。
+func NewV2EventID() string {
 	var b [8]byte
 	if _, err := rand.Read(b[:]); err == nil {
 		return hex.EncodeToString(b[:])
@@ -111,21 +339,19 @@ func newV2EventID() string {
 	return fmt.Sprintf("%d", time.Now().UnixNano())
 }
 
-func coalesceV2EventLocked(q *v2EventQueue, event v2.Event) {
+func coalesceV2EventLocked(q *v2EventQueue, event v2.Event) []v2.Event {
 	key := v2EventCoalesceKey(event)
 	if key == "" {
-		return
+		return nil
 	}
-	filtered := q.events[:0]
-	for _, existing := range q.events {
-		if v2EventCoalesceKey(existing) != key {
-			filtered = append(filtered, existing)
-		}
-	}
-	q.events = filtered
+	return q.remove(func(existing v2.Event) bool { return v2EventCoalesceKey(existing) != key })
 }
 
 func v2EventCoalesceKey(event v2.Event) string {
+	// 只有最新的令牌（或更新指令、配置、服务列表）有效，新事件替换尚未送达的旧事件。
+	if event.Method == v2.MethodAgentToken || event.Method == v2.MethodAgentUpdate || event.Method == v2.MethodAgentConfig || event.Method == v2.MethodAgentServices {
+		return event.Method
+	}
 	if event.Method != v2.MethodAgentPing {
 		return ""
 	}
@@ -144,49 +370,77 @@ func bindV2EventParams(raw any, target any) error {
 	return json.Unmarshal(b, target)
 }
 
-func ackV2EventsLocked(q *v2EventQueue, ackIDs []string) {
+func ackV2EventsLocked(q *v2EventQueue, ackIDs []string) []string {
 	if len(ackIDs) == 0 || len(q.events) == 0 {
-		return
+		return nil
 	}
+	durable := durableIDsLocked(q, ackIDs)
 	acked := make(map[string]struct{}, len(ackIDs))
 	for _, id := range ackIDs {
 		acked[id] = struct{}{}
 	}
-	filtered := q.events[:0]
-	for _, event := range q.events {
-		if _, ok := acked[event.ID]; !ok {
-			filtered = append(filtered, event)
-		}
-	}
-	q.events = filtered
+	q.remove(func(event v2.Event) bool {
+		_, ok := acked[event.ID]
+		return !ok
+	})
+	return durable
 }
 
-func pruneExpiredV2EventsLocked(q *v2EventQueue) {
+func pruneExpiredV2EventsLocked(q *v2EventQueue) []v2.Event {
 	if len(q.events) == 0 {
-		return
+		return nil
 	}
 	now := time.Now().UTC()
-	filtered := q.events[:0]
-	for _, event := range q.events {
-		if event.ExpiresAt.IsZero() {
-			filtered = append(filtered, event)
-			continue
+	return q.remove(func(event v2.Event) bool {
+		return event.ExpiresAt.IsZero() || event.ExpiresAt.After(now)
+	})
+}
+
+// exhaustedV2EventsLocked 移除已下发 V2EventMaxAttempts 次仍未确认的事件。
+func exhaustedV2EventsLocked(q *v2EventQueue) []v2.Event {
+	return q.remove(func(event v2.Event) bool {
+		st := q.state[event.ID]
+		return st == nil || st.attempts < V2EventMaxAttempts
+	})
+}
+
+// takeV2Events 确认、清理并取出事件，取出的事件计为一次下发。
+func takeV2Events(uuid string, ackIDs []string, limit int) ([]v2.Event, <-chan struct{}) {
+	v2EventMu.Lock()
+	q := getV2EventQueueLocked(uuid)
+	acked := ackV2EventsLocked(q, ackIDs)
+	drops := []v2EventDrop{
+		{uuid, pruneExpiredV2EventsLocked(q), V2EventExpired},
+		{uuid, exhaustedV2EventsLocked(q), V2EventFailed},
+	}
+	events := takeV2EventsLocked(q, limit)
+	var delivered []string
+	for _, event := range events {
+		st := q.stateOf(event.ID)
+		st.attempts++
+		if st.durable {
+			delivered = append(delivered, event.ID)
 		}
-		if event.ExpiresAt.After(now) {
-			filtered = append(filtered, event)
+	}
+	signal := q.signal
+	store := v2EventStore
+	v2EventMu.Unlock()
+
+	if store != nil {
+		if len(acked) > 0 {
+			store.Acked(uuid, acked)
+		}
+		if len(delivered) > 0 {
+			store.Delivered(uuid, delivered)
 		}
 	}
-	q.events = filtered
+	reportV2EventDrops(store, drops)
+	return events, signal
 }
 
 func TakeV2Events(uuid string, ackIDs []string, limit int) []v2.Event {
-	v2EventMu.Lock()
-	defer v2EventMu.Unlock()
-
-	q := getV2EventQueueLocked(uuid)
-	ackV2EventsLocked(q, ackIDs)
-	pruneExpiredV2EventsLocked(q)
-	return takeV2EventsLocked(q, limit)
+	events, _ := takeV2Events(uuid, ackIDs, limit)
+	return events
 }
 
 func AckV2Events(uuid string, ackIDs []string) {
@@ -194,13 +448,18 @@ func AckV2Events(uuid string, ackIDs []string) {
 		return
 	}
 	v2EventMu.Lock()
-	defer v2EventMu.Unlock()
-
 	q := v2EventQueues[uuid]
 	if q == nil {
+		v2EventMu.Unlock()
 		return
 	}
-	ackV2EventsLocked(q, ackIDs)
+	acked := ackV2EventsLocked(q, ackIDs)
+	store := v2EventStore
+	v2EventMu.Unlock()
+
+	if store != nil && len(acked) > 0 {
+		store.Acked(uuid, acked)
+	}
 }
 
 func takeV2EventsLocked(q *v2EventQueue, limit int) []v2.Event {
@@ -213,17 +472,10 @@ func takeV2EventsLocked(q *v2EventQueue, limit int) []v2.Event {
 }
 
 func WaitV2Events(uuid string, ackIDs []string, timeout time.Duration) []v2.Event {
-	v2EventMu.Lock()
-	q := getV2EventQueueLocked(uuid)
-	ackV2EventsLocked(q, ackIDs)
-	pruneExpiredV2EventsLocked(q)
-	events := takeV2EventsLocked(q, v2EventQueueLimit)
+	events, signal := takeV2Events(uuid, ackIDs, v2EventQueueLimit)
 	if len(events) > 0 || timeout <= 0 {
-		v2EventMu.Unlock()
 		return events
 	}
-	signal := q.signal
-	v2EventMu.Unlock()
 
 	timer := time.NewTimer(timeout)
 	defer timer.Stop()
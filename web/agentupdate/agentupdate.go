@@ -0,0 +1,261 @@
+// Package agentupdate 推进 Agent 分阶段自更新（见 database/agentupdates）。
+//
+// 调度器定期调用 Tick：向当前阶段的在线 v2 Agent 下发 agent.update。Agent 上报目标版本
+// （ObserveVersion）即为成功；Agent 主动报告失败（ReportFailure）或超过批次的超时时间
+// 仍未更新则为失败。失败数达到阈值时自动暂停批次，当前阶段全部结束后进入下一阶段。
+package agentupdate
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentupdates"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/models"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/agent"
+)
+
+// TickInterval 是调度器推进更新批次的间隔。
+const TickInterval = 15 * time.Second
+
+// tickMu 避免 Tick、ObserveVersion 与 ReportFailure 同时修改同一批次。
+var tickMu sync.Mutex
+
+// Tick 推进当前进行中的更新批次。
+func Tick(ctx context.Context) {
+	tickMu.Lock()
+	defer tickMu.Unlock()
+
+	rollout, err := agentupdates.ActiveRollout()
+	if err != nil {
+		logger.ErrorArgs("agentupdate", "Failed to load active rollout:", err)
+		return
+	}
+	if rollout == nil {
+		return
+	}
+	targets, err := agentupdates.ListTargets(rollout.Id)
+	if err != nil {
+		logger.ErrorArgs("agentupdate", "Failed to load rollout targets:", err)
+		return
+	}
+	if err := advance(ctx, rollout, targets); err != nil {
+		logger.Warnf("agentupdate", "Failed to advance rollout %d: %v", rollout.Id, err)
+	}
+}
+
+func advance(ctx context.Context, rollout *models.AgentRollout, targets []models.AgentRolloutTarget) error {
+	now := time.Now()
+	timeout := time.Duration(rollout.Timeout) * time.Second
+
+	// 1. 判定已下发客户端的结果。
+	for i := range targets {
+		t := &targets[i]
+		if t.Status != models.TargetUpdating {
+			continue
+		}
+		client, err := clients.GetClientByUUID(t.ClientUUID)
+		switch {
+		case err == nil && agentupdates.SameVersion(client.Version, rollout.Version):
+			if err := finish(t, models.TargetSucceeded, ""); err != nil {
+				return err
+			}
+		case t.StartedAt != nil && now.Sub(*t.StartedAt) > timeout:
+			if err := finish(t, models.TargetFailed, fmt.Sprintf("not updated to %s within %s", rollout.Version, timeout)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// 2. 失败数达到阈值时自动暂停。
+	if paused, err := checkThreshold(rollout, targets); err != nil || paused {
+		return err
+	}
+	if rollout.Status != models.RolloutRunning {
+		return nil
+	}
+
+	// 3. 向当前及之前阶段中尚未处理的客户端下发更新。
+	releases := map[string]*models.AgentRelease{}
+	for i := range targets {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		t := &targets[i]
+		if t.Status != models.TargetPending || t.Stage > rollout.Stage {
+			continue
+		}
+		if err := dispatch(rollout, t, releases); err != nil {
+			return err
+		}
+	}
+
+	// 4. 当前阶段没有进行中的客户端时推进阶段或完成批次。
+	allDone, stageDone, stageSucceeded, updating := true, true, false, false
+	for _, t := range targets {
+		terminal := t.Status == models.TargetSucceeded || t.Status == models.TargetFailed || t.Status == models.TargetSkipped
+		allDone = allDone && terminal
+		if t.Stage <= rollout.Stage && t.Status == models.TargetUpdating {
+			updating = true
+		}
+		if t.Stage == rollout.Stage {
+			stageDone = stageDone && terminal
+			stageSucceeded = stageSucceeded || t.Status == models.TargetSucceeded
+		}
+	}
+	lastStage := len(rollout.Percentages)
+	if allDone && rollout.Stage >= lastStage {
+		if err := agentupdates.CompleteRollout(rollout.Id); err != nil {
+			return err
+		}
+		auditlog.EventLog("agent_update", fmt.Sprintf("rollout %d to %s completed", rollout.Id, rollout.Version))
+		return nil
+	}
+	// 阶段内有成功的客户端即可推进（离线的客户端上线后仍会补发），否则等待该阶段全部结束。
+	if rollout.Stage < lastStage && !updating && (stageSucceeded || stageDone) {
+		if err := agentupdates.SetStage(rollout.Id, rollout.Stage+1); err != nil {
+			return err
+		}
+		logger.Infof("agentupdate", "Rollout %d advanced to stage %d", rollout.Id, rollout.Stage+1)
+	}
+	return nil
+}
+
+func finish(t *models.AgentRolloutTarget, status, message string) error {
+	changed, err := agentupdates.FinishTarget(t.Id, t.Status, status, message)
+	if err != nil {
+		return err
+	}
+	if changed {
+		t.Status = status
+		t.Error = message
+	}
+	return nil
+}
+
+func checkThreshold(rollout *models.AgentRollout, targets []models.AgentRolloutTarget) (bool, error) {
+	if rollout.Status != models.RolloutRunning {
+		return false, nil
+	}
+	failed := 0
+	for _, t := range targets {
+		if t.Status == models.TargetFailed {
+			failed++
+		}
+	}
+	if failed-rollout.AcknowledgedFailures < rollout.FailureThreshold {
+		return false, nil
+	}
+	reason := fmt.Sprintf("%d client(s) failed to update, reaching the failure threshold of %d", failed-rollout.AcknowledgedFailures, rollout.FailureThreshold)
+	if err := agentupdates.PauseRollout(rollout.Id, reason); err != nil {
+		return false, err
+	}
+	rollout.Status = models.RolloutPaused
+	auditlog.EventLog("agent_update", fmt.Sprintf("rollout %d to %s paused: %s", rollout.Id, rollout.Version, reason))
+	return true, nil
+}
+
+func dispatch(rollout *models.AgentRollout, t *models.AgentRolloutTarget, releases map[string]*models.AgentRelease) error {
+	client, err := clients.GetClientByUUID(t.ClientUUID)
+	if err != nil {
+		return finish(t, models.TargetSkipped, "client no longer exists")
+	}
+	if agentupdates.SameVersion(client.Version, rollout.Version) {
+		return finish(t, models.TargetSucceeded, "")
+	}
+	if !agent.IsAgentOnline(t.ClientUUID) {
+		return nil
+	}
+	if !agent.IsV2Client(t.ClientUUID) {
+		return finish(t, models.TargetSkipped, "agent does not support the v2 protocol")
+	}
+
+	os, arch := agentupdates.NormalizeOS(client.OS), agentupdates.NormalizeArch(client.Arch)
+	key := os + "/" + arch
+	release, ok := releases[key]
+	if !ok {
+		release, err = agentupdates.FindRelease(rollout.Version, os, arch)
+		if err != nil {
+			return err
+		}
+		releases[key] = release
+	}
+	if release == nil {
+		return finish(t, models.TargetSkipped, "no release for "+key)
+	}
+
+	url := release.URL
+	if release.FilePath != "" {
+		url = agentupdates.DownloadPath(release.Id)
+	}
+	params := v2.UpdateParams{
+		RolloutID: rollout.Id,
+		Version:   release.Version,
+		URL:       url,
+		SHA256:    release.SHA256,
+		Size:      release.Size,
+	}
+	if !agent.DispatchV2Event(t.ClientUUID, v2.MethodAgentUpdate, params) {
+		return nil
+	}
+	if err := agentupdates.MarkUpdating(t.Id, release.Id); err != nil {
+		return err
+	}
+	t.Status = models.TargetUpdating
+	return nil
+}
+
+// ObserveVersion 在 Agent 上报基础信息时调用，升级到目标版本即判定成功。
+// 仍以原版本上报不视为失败：下载较慢或重启前的上报都会出现这种情况，
+// 由 ReportFailure 或批次超时判定失败。
+func ObserveVersion(uuid, version string) {
+	if version == "" {
+		return
+	}
+	tickMu.Lock()
+	defer tickMu.Unlock()
+
+	rollout, target := updatingTarget(uuid)
+	if target == nil || !agentupdates.SameVersion(version, rollout.Version) {
+		return
+	}
+	if _, err := agentupdates.FinishTarget(target.Id, models.TargetUpdating, models.TargetSucceeded, ""); err != nil {
+		logger.Warnf("agentupdate", "Failed to record update result for %s: %v", uuid, err)
+	}
+}
+
+// ReportFailure 在 Agent 上报 v2.EventUpdateFailed 事件时调用，将正在更新的客户端判定为失败。
+// rolloutID 非 0 且与当前批次不符时忽略，避免迟到的旧批次事件影响当前批次。
+func ReportFailure(uuid string, rolloutID uint, message string) {
+	tickMu.Lock()
+	defer tickMu.Unlock()
+
+	rollout, target := updatingTarget(uuid)
+	if target == nil || (rolloutID != 0 && rolloutID != rollout.Id) {
+		return
+	}
+	if message == "" {
+		message = "update failed"
+	}
+	if _, err := agentupdates.FinishTarget(target.Id, models.TargetUpdating, models.TargetFailed, "agent reported: "+message); err != nil {
+		logger.Warnf("agentupdate", "Failed to record update failure for %s: %v", uuid, err)
+	}
+}
+
+// updatingTarget 返回当前批次及该客户端处于更新中的目标，没有时 target 为 nil。
+func updatingTarget(uuid string) (*models.AgentRollout, *models.AgentRolloutTarget) {
+	rollout, err := agentupdates.ActiveRollout()
+	if err != nil || rollout == nil {
+		return nil, nil
+	}
+	target, err := agentupdates.GetTarget(rollout.Id, uuid)
+	if err != nil || target == nil || target.Status != models.TargetUpdating {
+		return nil, nil
+	}
+	return rollout, target
+}
@@ -0,0 +1,45 @@
+package agentupdate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentupdates"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func TestTargetFailsOnlyOnReportedFailure(t *testing.T) {
+	db := dbtest.Open(t, "agentupdate")
+
+	rollout := models.AgentRollout{Version: "1.2.0", Status: models.RolloutRunning, Percentages: models.IntArray{100}, Timeout: 600}
+	if err := db.Create(&rollout).Error; err != nil {
+		t.Fatalf("create rollout: %v", err)
+	}
+	started := time.Now().Add(-5 * time.Minute)
+	for _, uuid := range []string{"slow", "broken"} {
+		target := models.AgentRolloutTarget{RolloutID: rollout.Id, ClientUUID: uuid, Status: models.TargetUpdating, FromVersion: "1.1.0", StartedAt: &started}
+		if err := db.Create(&target).Error; err != nil {
+			t.Fatalf("create target: %v", err)
+		}
+	}
+
+	// 下发后数分钟仍以旧版本上报，可能只是下载较慢，不应判定失败。
+	ObserveVersion("slow", "1.1.0")
+	// 其他批次的迟到事件被忽略。
+	ReportFailure("broken", rollout.Id+1, "checksum mismatch")
+	for _, uuid := range []string{"slow", "broken"} {
+		if target, _ := agentupdates.GetTarget(rollout.Id, uuid); target.Status != models.TargetUpdating {
+			t.Fatalf("%s: status = %q, want updating", uuid, target.Status)
+		}
+	}
+
+	ReportFailure("broken", rollout.Id, "checksum mismatch")
+	ObserveVersion("slow", "1.2.0")
+	if target, _ := agentupdates.GetTarget(rollout.Id, "broken"); target.Status != models.TargetFailed || target.Error != "agent reported: checksum mismatch" {
+		t.Fatalf("broken target = %#v", target)
+	}
+	if target, _ := agentupdates.GetTarget(rollout.Id, "slow"); target.Status != models.TargetSucceeded {
+		t.Fatalf("slow target = %#v", target)
+	}
+}
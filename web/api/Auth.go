@@ -100,8 +100,8 @@ var publicPaths = []string{
 // 依赖 IdentityMiddleware 已设置的 role，对未认证的访客在私有站点模式下进行拦截。
 func PrivateSiteMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
-		// 已认证用户直接放行
-		if GetRole(c) != RoleGuest {
+		// 已认证用户及联邦父实例直接放行
+		if GetRole(c) != RoleGuest || isFederationPrincipal(c) {
 			c.Next()
 			return
 		}
@@ -164,6 +164,11 @@ func hasTempAccess(c *gin.Context) bool {
 	return expireAt >= time.Now().Unix()
 }
 
+// ClientToken 返回请求携带的客户端令牌，供长连接在建立后重新校验。
+func ClientToken(c *gin.Context) string {
+	return extractClientToken(c)
+}
+
 func extractClientToken(c *gin.Context) string {
 	token := c.Query("token")
 	if token != "" {
@@ -173,6 +178,12 @@ func extractClientToken(c *gin.Context) string {
 	if token := c.Query("Authorization"); token != "" {
 		return token
 	}
+	// InfluxDB 兼容客户端（Telegraf 等）通过 "Authorization: Token <token>" 传入。
+	if auth := c.GetHeader("Authorization"); strings.HasPrefix(auth, "Token ") {
+		if token := strings.TrimSpace(auth[len("Token "):]); token != "" {
+			return token
+		}
+	}
 
 	if c.Request.Method != http.MethodGet {
 		bodyBytes, err := io.ReadAll(c.Request.Body)
@@ -222,3 +233,20 @@ func isApiKeyValid(apiKey string) bool {
 	}
 	return apiKey == "Bearer "+apiKeyConfig
 }
+
+// isFederationKeyValid 校验联邦只读密钥。与 API Key 相同，要求至少 12 个字符。
+func isFederationKeyValid(authorization string) bool {
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	key, err := config.GetAs[string](config.FederationKeyKey, "")
+	if err != nil || len(key) < 12 {
+		return false
+	}
+	return authorization == "Bearer "+key
+}
+
+func isFederationPrincipal(c *gin.Context) bool {
+	p := GetPrincipal(c)
+	return p != nil && p.Type == rpc.PrincipalFederation
+}
@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"time"
 
+	"github.com/komari-monitor/komari/database/agentupdates"
 	"github.com/komari-monitor/komari/internal/plugin"
-	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/api/filetransfer"
 	"github.com/komari-monitor/komari/web/backup"
 	"github.com/komari-monitor/komari/web/upload"
 )
@@ -17,6 +17,9 @@ func NewArchiveUploadHandler() *upload.Handler {
 		upload.PurposeBackup: finalizeBackupUpload,
 		upload.PurposePlugin: finalizePluginUpload,
 		upload.PurposeTheme:  finalizeThemeUpload,
+
+		upload.PurposeAgentRelease: finalizeAgentReleaseUpload,
+		upload.PurposeFileTransfer: finalizeFileTransferUpload,
 	})
 }
 
@@ -30,7 +33,7 @@ func finalizeBackupUpload(session upload.Session) (upload.Result, error) {
 		restoreLock.Release()
 		return upload.Result{}, fmt.Errorf("open merged backup: %w", err)
 	}
-	if err := restoreLock.SaveUploadedBackup(archive, session.Metadata.Filename); err != nil {
+	if err := restoreLock.SaveUploadedBackup(archive, session.Metadata.Filename, backup.ResolvePassphrase(session.Passphrase)); err != nil {
 		_ = archive.Close()
 		restoreLock.Release()
 		return upload.Result{}, err
@@ -40,12 +43,7 @@ func finalizeBackupUpload(session upload.Session) (upload.Result, error) {
 		return upload.Result{}, fmt.Errorf("close merged backup: %w", err)
 	}
 
-	go func() {
-		logger.InfoArgs("admin-api", "Backup uploaded, restarting service in 2 seconds to apply on startup...")
-		time.Sleep(2 * time.Second)
-		restoreLock.Release()
-		os.Exit(0)
-	}()
+	restoreLock.RestartToApply()
 
 	return upload.Result{
 		Message: "Backup uploaded successfully. The service will restart and apply the backup.",
@@ -68,3 +66,19 @@ func finalizeThemeUpload(session upload.Session) (upload.Result, error) {
 	}
 	return upload.Result{Message: "主题上传成功", Data: info}, nil
 }
+
+func finalizeAgentReleaseUpload(session upload.Session) (upload.Result, error) {
+	file, err := agentupdates.StoreFile(session.ArchivePath)
+	if err != nil {
+		return upload.Result{}, err
+	}
+	return upload.Result{Message: "Agent binary uploaded, register it with admin:addAgentRelease", Data: file}, nil
+}
+
+func finalizeFileTransferUpload(session upload.Session) (upload.Result, error) {
+	file, err := filetransfer.Stage(session.ArchivePath, session.Metadata.Filename)
+	if err != nil {
+		return upload.Result{}, err
+	}
+	return upload.Result{Message: "File staged, send it to clients with admin:sendFile", Data: file}, nil
+}
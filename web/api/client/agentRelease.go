@@ -0,0 +1,42 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/agentupdates"
+	"github.com/komari-monitor/komari/web/api"
+	"gorm.io/gorm"
+)
+
+// DownloadAgentRelease 提供上传到 Komari 的 Agent 二进制，供 agent.update 下载。
+func DownloadAgentRelease(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		api.RespondError(c, http.StatusBadRequest, "Invalid release id")
+		return
+	}
+	release, err := agentupdates.GetRelease(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			api.RespondError(c, http.StatusNotFound, "Release not found")
+			return
+		}
+		api.RespondError(c, http.StatusInternalServerError, "Failed to get release: "+err.Error())
+		return
+	}
+	if release.FilePath == "" {
+		api.RespondError(c, http.StatusNotFound, "Release is not hosted by this server")
+		return
+	}
+	_, path, err := agentupdates.OpenFile(release.FilePath)
+	if err != nil {
+		api.RespondError(c, http.StatusNotFound, "Release file is missing")
+		return
+	}
+	c.Header("X-Checksum-Sha256", release.SHA256)
+	c.FileAttachment(path, fmt.Sprintf("komari-agent-%s-%s-%s", release.Version, release.OS, release.Arch))
+}
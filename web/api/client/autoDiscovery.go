@@ -1,40 +1,96 @@
 package client
 
 import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/gin-gonic/gin"
-	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/enrollment"
+	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/internal/config"
 	"github.com/komari-monitor/komari/utils"
 	"github.com/komari-monitor/komari/web/api"
 )
 
+// RegisterClient 供 Agent 自动注册。Authorization 可以是注册令牌或旧版 auto_discovery_key；
+// 重复执行安装脚本时，带 fingerprint 参数并在 X-Client-Token 中出示原客户端令牌的请求
+// 返回同一客户端。
 func RegisterClient(c *gin.Context) {
-	auth := c.GetHeader("Authorization")
-	if auth == "" {
+	secret, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if !ok || secret == "" {
 		api.RespondError(c, 403, "Invalid AutoDiscovery Key")
 		return
 	}
-	AutoDiscoveryKey, err := config.GetAs[string](config.AutoDiscoveryKeyKey, "")
-	if err != nil {
-		api.RespondError(c, 500, "Failed to get AutoDiscovery Key: "+err.Error())
+	token, err := enrollment.Authenticate(secret)
+	switch {
+	case err == nil:
+	case errors.Is(err, enrollment.ErrInvalidToken):
+		legacy, err := legacyDiscoveryKeyMatches(secret)
+		if err != nil {
+			api.RespondError(c, 500, "Failed to get AutoDiscovery Key: "+err.Error())
+			return
+		}
+		if !legacy {
+			api.RespondError(c, 403, "Invalid AutoDiscovery Key")
+			return
+		}
+	case errors.Is(err, enrollment.ErrTokenDisabled), errors.Is(err, enrollment.ErrTokenExpired):
+		auditEnrollmentRejected(c, token, err)
+		api.RespondError(c, 403, err.Error())
 		return
-	}
-	if AutoDiscoveryKey == "" ||
-		len(AutoDiscoveryKey) < 12 ||
-		"Bearer "+AutoDiscoveryKey != auth {
-
-		api.RespondError(c, 403, "Invalid AutoDiscovery Key")
+	default:
+		api.RespondError(c, 500, "Failed to verify enrollment token: "+err.Error())
 		return
 	}
+
 	name := c.Query("name")
 	if name == "" {
 		name = utils.GenerateRandomString(8)
 	}
 	name = "Auto-" + name
-	uuid, token, err := clients.CreateClientWithName(name)
+	result, err := enrollment.Enroll(enrollment.Request{
+		Token:       token,
+		Name:        name,
+		Fingerprint: c.Query("fingerprint"),
+		ClientToken: c.GetHeader("X-Client-Token"),
+		IP:          c.ClientIP(),
+	})
 	if err != nil {
+		if errors.Is(err, enrollment.ErrTokenExhausted) {
+			auditEnrollmentRejected(c, token, err)
+			api.RespondError(c, 403, err.Error())
+			return
+		}
 		api.RespondError(c, 500, "Failed to create client: "+err.Error())
 		return
 	}
-	api.RespondSuccess(c, gin.H{"uuid": uuid, "token": token})
+
+	via := "auto discovery key"
+	if token != nil {
+		via = fmt.Sprintf("enrollment token:%d (%s)", token.Id, token.Name)
+	}
+	if result.Existing {
+		auditlog.Log(c.ClientIP(), "", fmt.Sprintf("client re-enrolled:%s via %s", result.UUID, via), "info")
+	} else {
+		auditlog.Log(c.ClientIP(), "", fmt.Sprintf("client enrolled:%s (%s) via %s", result.UUID, name, via), "info")
+	}
+	api.RespondSuccess(c, gin.H{"uuid": result.UUID, "token": result.Token})
+}
+
+func legacyDiscoveryKeyMatches(secret string) (bool, error) {
+	key, err := config.GetAs[string](config.AutoDiscoveryKeyKey, "")
+	if err != nil {
+		return false, err
+	}
+	if len(key) < 12 {
+		return false, nil
+	}
+	return subtle.ConstantTimeCompare([]byte(key), []byte(secret)) == 1, nil
+}
+
+func auditEnrollmentRejected(c *gin.Context, token *models.EnrollmentToken, reason error) {
+	auditlog.Log(c.ClientIP(), "", fmt.Sprintf("enrollment rejected via enrollment token:%d (%s): %v", token.Id, token.Name, reason), "warn")
 }
@@ -0,0 +1,145 @@
+package client
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/pkg/metric"
+	"github.com/komari-monitor/komari/web/api"
+)
+
+const (
+	// lineProtocolMaxBody 解压后的请求体上限。
+	lineProtocolMaxBody = 4 << 20
+	// lineProtocolMaxPoints 单次请求展开后的点数上限（measurement.field 计一个点）。
+	lineProtocolMaxPoints = 10000
+	// lineProtocolSource 写入自动创建指标定义的 source 元数据。
+	lineProtocolSource = "line_protocol"
+)
+
+var errIngestBodyTooLarge = errors.New("request body too large")
+
+// WriteLineProtocol 接收 InfluxDB line protocol，把每个 measurement.field
+// 写入调用方客户端的自定义指标。路径与 Telegraf 的 outputs.influxdb
+// (/write) 和 outputs.influxdb_v2 (/api/v2/write) 兼容，把 url 配置为
+// http(s)://<komari>/api/clients 即可；token 通过 ?token= 或
+// "Authorization: Token <token>" 传入。
+func WriteLineProtocol(c *gin.Context) {
+	uuid, ok := lineProtocolClientUUID(c)
+	if !ok {
+		return
+	}
+	precision, err := lineProtocolPrecision(c.Query("precision"))
+	if err != nil {
+		api.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	body, err := readIngestBody(c.Request, lineProtocolMaxBody)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errIngestBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		api.RespondError(c, status, err.Error())
+		return
+	}
+	parsed, err := parseLineProtocol(string(body), precision, time.Now().UTC())
+	if err != nil {
+		api.RespondError(c, http.StatusBadRequest, "Invalid line protocol: "+err.Error())
+		return
+	}
+	points := lineProtocolCustomPoints(uuid, parsed)
+	if len(points) > lineProtocolMaxPoints {
+		api.RespondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("too many points in one request (max %d)", lineProtocolMaxPoints))
+		return
+	}
+	result, err := metricstore.WriteCustomPoints(c.Request.Context(), lineProtocolSource, points)
+	if err != nil {
+		api.RespondError(c, http.StatusInternalServerError, "Failed to write metrics: "+err.Error())
+		return
+	}
+	if result.Rejected > 0 {
+		// 与 InfluxDB 的部分写入一致：已接受的点已落库，其余以 400 报告。
+		api.Respond(c, http.StatusBadRequest, "error", "partial write: "+strings.Join(result.Errors, "; "), result)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// lineProtocolClientUUID 解析写入目标：agent token 对应的客户端；管理员可用 ?uuid= 指定。
+func lineProtocolClientUUID(c *gin.Context) (string, bool) {
+	if v, ok := c.Get("client_uuid"); ok {
+		if uuid, _ := v.(string); uuid != "" {
+			return uuid, true
+		}
+	}
+	if api.GetRole(c) == api.RoleAdmin {
+		uuid := strings.TrimSpace(c.Query("uuid"))
+		if uuid == "" {
+			api.RespondError(c, http.StatusBadRequest, "uuid is required")
+			return "", false
+		}
+		if _, err := clients.GetClientByUUID(uuid); err != nil {
+			api.RespondError(c, http.StatusNotFound, "Client not found")
+			return "", false
+		}
+		return uuid, true
+	}
+	api.RespondError(c, http.StatusUnauthorized, "Unauthorized.")
+	return "", false
+}
+
+// readIngestBody 读取（可选 gzip 压缩的）请求体，解压后超过 limit 字节即报错。
+func readIngestBody(r *http.Request, limit int64) ([]byte, error) {
+	var reader io.Reader = r.Body
+	if strings.EqualFold(strings.TrimSpace(r.Header.Get("Content-Encoding")), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		reader = gz
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, errIngestBodyTooLarge
+	}
+	return data, nil
+}
+
+// lineProtocolCustomPoints 将每个数值字段展开为 <measurement>.<field> 指标。
+// line protocol 不区分 gauge 与 counter，整数字段（如 mem used、disk free）也多为瞬时值，
+// 因此一律按 gauge 创建定义。
+func lineProtocolCustomPoints(uuid string, parsed []lineProtocolPoint) []metricstore.CustomPoint {
+	points := make([]metricstore.CustomPoint, 0, len(parsed))
+	for _, p := range parsed {
+		for field, value := range p.Fields {
+			tags := make(map[string]string, len(p.Tags))
+			for k, v := range p.Tags {
+				tags[k] = v
+			}
+			points = append(points, metricstore.CustomPoint{
+				Point: metric.Point{
+					MetricName: p.Measurement + "." + field,
+					EntityID:   uuid,
+					Timestamp:  p.Timestamp,
+					Value:      value.Value,
+					Tags:       tags,
+				},
+				Type: metric.TypeGauge,
+			})
+		}
+	}
+	return points
+}
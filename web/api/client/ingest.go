@@ -2,14 +2,24 @@ package client
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"time"
 
+	"github.com/komari-monitor/komari/database/agentevents"
+	"github.com/komari-monitor/komari/database/agentlogs"
 	"github.com/komari-monitor/komari/database/clients"
 	"github.com/komari-monitor/komari/internal/metricstore"
 	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/processsnapshots"
+	"github.com/komari-monitor/komari/database/servicemonitors"
 	"github.com/komari-monitor/komari/database/tasks"
 	v1 "github.com/komari-monitor/komari/protocol/v1"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/notifier"
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
+	"github.com/komari-monitor/komari/web/agentupdate"
 )
 
 // ingest.go
@@ -25,10 +35,22 @@ func ingestReport(uuid string, report v1.Report, protocolVersion int, markPresen
 	if err := clients.ReportVerify(report); err != nil {
 		return err
 	}
+	// 进程快照可能含有命令行等敏感信息，单独保存，不进入指标与公开的实时状态。
+	if report.Processes != nil {
+		if _, err := processsnapshots.Observe(uuid, report.Processes, report.UpdatedAt); err != nil {
+			logger.Warnf("client-api", "Failed to save process snapshot for %s: %v", uuid, err)
+		}
+		report.Processes = nil
+	}
 	savedReport, err := metricstore.WriteReport(context.Background(), report)
 	if err != nil {
 		return err
 	}
+	// 支持容器的 Agent 每次都会上报完整列表（可能为空），未上报时不做比较。
+	if report.Containers != nil {
+		metricstore.ObserveContainers(uuid, report.Containers, report.UpdatedAt)
+		notifier.CheckContainerEvents(uuid, report.Containers, report.UpdatedAt)
+	}
 	agent_runtime.RecordReport(savedReport)
 	agent_runtime.SetClientProtocolVersion(uuid, protocolVersion)
 	if markPresence {
@@ -37,12 +59,118 @@ func ingestReport(uuid string, report v1.Report, protocolVersion int, markPresen
 	return nil
 }
 
+const (
+	// reportBatchMaxReports 限制单次补传的上报条数。
+	reportBatchMaxReports = 1000
+	// reportBatchMaxAge 为补传上报允许的最大时间跨度，更早的数据直接拒绝。
+	reportBatchMaxAge = 24 * time.Hour
+	// reportBatchClockSkew 容忍 Agent 与服务端之间的时钟偏差。
+	reportBatchClockSkew = time.Minute
+)
+
+// ingestReportBatch 按原始采集时间保存 Agent 断网期间缓存的上报。
+// 补传的数据只写入历史指标，不会覆盖运行时的最新状态。
+func ingestReportBatch(uuid string, reports []v1.Report, protocolVersion int, markPresence bool) error {
+	if len(reports) == 0 {
+		return nil
+	}
+	if len(reports) > reportBatchMaxReports {
+		return fmt.Errorf("too many reports in batch: %d > %d", len(reports), reportBatchMaxReports)
+	}
+	now := time.Now().UTC()
+	oldest := now.Add(-reportBatchMaxAge)
+	latest := now.Add(reportBatchClockSkew)
+	for i := range reports {
+		report := &reports[i]
+		report.UUID = uuid
+		report.Processes = nil
+		if report.UpdatedAt.IsZero() {
+			return fmt.Errorf("report %d has no updated_at", i)
+		}
+		report.UpdatedAt = report.UpdatedAt.UTC()
+		if report.UpdatedAt.Before(oldest) {
+			return fmt.Errorf("report %d is older than %s", i, reportBatchMaxAge)
+		}
+		if report.UpdatedAt.After(latest) {
+			return fmt.Errorf("report %d is in the future", i)
+		}
+		if i > 0 && !report.UpdatedAt.After(reports[i-1].UpdatedAt) {
+			return fmt.Errorf("report %d is not newer than the previous report", i)
+		}
+		if err := clients.ReportVerify(*report); err != nil {
+			return fmt.Errorf("report %d: %w", i, err)
+		}
+	}
+	if _, err := metricstore.WriteBackfillReports(context.Background(), reports); err != nil {
+		return err
+	}
+	agent_runtime.SetClientProtocolVersion(uuid, protocolVersion)
+	if markPresence {
+		refreshPostPresence(uuid)
+	}
+	return nil
+}
+
+// ingestServiceStatus 保存 Agent 上报的服务检查结果，并通知状态变化。
+func ingestServiceStatus(uuid string, statuses []v2.ServiceStatus) error {
+	now := time.Now().UTC()
+	transitions, err := servicemonitors.Observe(uuid, statuses, now)
+	notifier.NotifyServiceTransitions(uuid, transitions, now)
+	if err != nil {
+		return err
+	}
+	refreshPostPresence(uuid)
+	return nil
+}
+
+// ingestAgentEvent 记录 Agent 上报的自定义事件，并按通知规则发送通知。
+func ingestAgentEvent(uuid string, params v2.EventParams) (*agentevents.Event, error) {
+	event, err := agentevents.Record(uuid, params, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	rules, err := agentevents.MatchingRules(*event)
+	if err != nil {
+		logger.Warnf("client-api", "Failed to match event rules for %s: %v", uuid, err)
+	}
+	notifier.NotifyAgentEvent(*event, rules)
+	if event.Type == v2.EventUpdateFailed {
+		var data v2.UpdateFailedData
+		_ = json.Unmarshal(event.Data, &data)
+		agentupdate.ReportFailure(uuid, data.RolloutID, event.Message)
+	}
+	refreshPostPresence(uuid)
+	return event, nil
+}
+
+// ingestLogs 保存 Agent 上报的日志行，并按日志告警规则发送通知。
+func ingestLogs(uuid string, lines []v2.LogLine) (int, error) {
+	now := time.Now().UTC()
+	saved, err := agentlogs.Ingest(uuid, lines, now)
+	if err != nil {
+		return 0, err
+	}
+	alerts, err := agentlogs.Evaluate(uuid, saved, now)
+	if err != nil {
+		logger.Warnf("client-api", "Failed to evaluate log alert rules for %s: %v", uuid, err)
+	}
+	notifier.NotifyLogAlerts(alerts, now)
+	refreshPostPresence(uuid)
+	return len(saved), nil
+}
+
 // ingestBasicInfo 保存客户端基础信息。fallbackIP 在上报未携带 IP 时用作兜底。
 func ingestBasicInfo(uuid string, info map[string]interface{}, fallbackIP string) error {
 	if info == nil {
 		info = map[string]interface{}{}
 	}
-	return saveClientBasicInfo(info, uuid, fallbackIP)
+	if err := saveClientBasicInfo(info, uuid, fallbackIP); err != nil {
+		return err
+	}
+	if version, ok := info["version"].(string); ok {
+		agentupdate.ObserveVersion(uuid, version)
+	}
+	return nil
 }
 
 // ingestPingResult 保存一条 ping 探测结果。
@@ -0,0 +1,207 @@
+package client
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// lineProtocolPoint 是一行 InfluxDB line protocol 解析后的结果。
+type lineProtocolPoint struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]lineProtocolField
+	Timestamp   time.Time
+}
+
+type lineProtocolField struct {
+	Value float64
+}
+
+// lineProtocolPrecision 把 precision 参数（v1: n/u/ms/s/m/h，v2: ns/us/ms/s）转换为时间单位。
+func lineProtocolPrecision(precision string) (time.Duration, error) {
+	switch strings.ToLower(strings.TrimSpace(precision)) {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us", "µ", "µs":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unsupported precision %q", precision)
+	}
+}
+
+// parseLineProtocol 解析多行 line protocol。空行与 # 注释被忽略；
+// 字符串字段不是数值，会被跳过，布尔字段按 1/0 处理。时间戳早于 now 之前
+// reportBatchMaxAge 或晚于 now 之后 reportBatchClockSkew 时整批拒绝。
+func parseLineProtocol(body string, precision time.Duration, now time.Time) ([]lineProtocolPoint, error) {
+	var points []lineProtocolPoint
+	for lineNo, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		point, err := parseLineProtocolLine(trimmed, precision, now)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNo+1, err)
+		}
+		points = append(points, point)
+	}
+	return points, nil
+}
+
+func parseLineProtocolLine(line string, precision time.Duration, now time.Time) (lineProtocolPoint, error) {
+	point := lineProtocolPoint{Tags: map[string]string{}, Fields: map[string]lineProtocolField{}}
+
+	// 第一段：measurement[,tag=value...]，以未转义的空格结束。
+	keyEnd := scanUnescaped(line, 0, ' ', false)
+	if keyEnd <= 0 {
+		return point, fmt.Errorf("missing fields")
+	}
+	seriesKey := line[:keyEnd]
+	parts := splitUnescaped(seriesKey, ',', false)
+	point.Measurement = unescapeLineProtocol(parts[0])
+	if point.Measurement == "" {
+		return point, fmt.Errorf("missing measurement")
+	}
+	for _, tag := range parts[1:] {
+		kv := splitUnescaped(tag, '=', false)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return point, fmt.Errorf("invalid tag %q", tag)
+		}
+		point.Tags[unescapeLineProtocol(kv[0])] = unescapeLineProtocol(kv[1])
+	}
+
+	// 第二段：field=value[,field=value...]，字符串字段可以包含空格。
+	rest := strings.TrimLeft(line[keyEnd:], " ")
+	fieldEnd := scanUnescaped(rest, 0, ' ', true)
+	if fieldEnd < 0 {
+		fieldEnd = len(rest)
+	}
+	fieldSet := rest[:fieldEnd]
+	if fieldSet == "" {
+		return point, fmt.Errorf("missing fields")
+	}
+	for _, field := range splitUnescaped(fieldSet, ',', true) {
+		kv := splitUnescapedN(field, '=', true, 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return point, fmt.Errorf("invalid field %q", field)
+		}
+		name := unescapeLineProtocol(kv[0])
+		value, ok, err := parseLineProtocolValue(kv[1])
+		if err != nil {
+			return point, fmt.Errorf("field %q: %w", name, err)
+		}
+		if ok {
+			point.Fields[name] = value
+		}
+	}
+
+	// 第三段（可选）：时间戳。
+	point.Timestamp = now
+	if tsText := strings.TrimSpace(rest[fieldEnd:]); tsText != "" {
+		ts, err := strconv.ParseInt(tsText, 10, 64)
+		if err != nil {
+			return point, fmt.Errorf("invalid timestamp %q", tsText)
+		}
+		unit := int64(precision)
+		if ts > math.MaxInt64/unit || ts < math.MinInt64/unit {
+			return point, fmt.Errorf("timestamp %q out of range", tsText)
+		}
+		point.Timestamp = time.Unix(0, ts*unit).UTC()
+		// 与补传上报的时间范围一致，避免写入远早于保留期或未来的数据。
+		if point.Timestamp.Before(now.Add(-reportBatchMaxAge)) {
+			return point, fmt.Errorf("timestamp %q is older than %s", tsText, reportBatchMaxAge)
+		}
+		if point.Timestamp.After(now.Add(reportBatchClockSkew)) {
+			return point, fmt.Errorf("timestamp %q is in the future", tsText)
+		}
+	}
+	return point, nil
+}
+
+// parseLineProtocolValue 返回字段数值；字符串字段返回 ok=false。
+func parseLineProtocolValue(raw string) (lineProtocolField, bool, error) {
+	switch {
+	case strings.HasPrefix(raw, `"`):
+		if len(raw) < 2 || !strings.HasSuffix(raw, `"`) {
+			return lineProtocolField{}, false, fmt.Errorf("unterminated string")
+		}
+		return lineProtocolField{}, false, nil
+	case raw == "t" || raw == "T" || raw == "true" || raw == "True" || raw == "TRUE":
+		return lineProtocolField{Value: 1}, true, nil
+	case raw == "f" || raw == "F" || raw == "false" || raw == "False" || raw == "FALSE":
+		return lineProtocolField{Value: 0}, true, nil
+	case strings.HasSuffix(raw, "i"):
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return lineProtocolField{}, false, fmt.Errorf("invalid integer %q", raw)
+		}
+		return lineProtocolField{Value: float64(v)}, true, nil
+	case strings.HasSuffix(raw, "u"):
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return lineProtocolField{}, false, fmt.Errorf("invalid unsigned integer %q", raw)
+		}
+		return lineProtocolField{Value: float64(v)}, true, nil
+	default:
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return lineProtocolField{}, false, fmt.Errorf("invalid number %q", raw)
+		}
+		return lineProtocolField{Value: v}, true, nil
+	}
+}
+
+// scanUnescaped 返回 sep 在 s 中首次未被反斜杠转义（且不在字段字符串引号内）出现的位置。
+func scanUnescaped(s string, from int, sep byte, quotes bool) int {
+	inQuote := false
+	for i := from; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuote = !inQuote
+		case !inQuote && s[i] == sep:
+			return i
+		}
+	}
+	return -1
+}
+
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	return splitUnescapedN(s, sep, quotes, -1)
+}
+
+func splitUnescapedN(s string, sep byte, quotes bool, n int) []string {
+	var out []string
+	start := 0
+	for n < 0 || len(out) < n-1 {
+		idx := scanUnescaped(s, start, sep, quotes)
+		if idx < 0 {
+			break
+		}
+		out = append(out, s[start:idx])
+		start = idx + 1
+	}
+	return append(out, s[start:])
+}
+
+var lineProtocolUnescaper = strings.NewReplacer(`\,`, ",", `\=`, "=", `\ `, " ", `\"`, `"`, `\\`, `\`)
+
+func unescapeLineProtocol(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return lineProtocolUnescaper.Replace(s)
+}
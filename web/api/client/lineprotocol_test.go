@@ -0,0 +1,102 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+)
+
+func TestParseLineProtocol(t *testing.T) {
+	now := time.Unix(1700000000, 0).UTC()
+	body := "# comment\n" +
+		"cpu,host=web\\ 1,region=eu usage_user=12.5,usage_idle=80i,up=t,note=\"a, b=c\" 1700000000000000000\n" +
+		"\n" +
+		"disk\\,io,path=/ reads=5u\n"
+
+	points, err := parseLineProtocol(body, time.Nanosecond, now)
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(points) != 2 {
+		t.Fatalf("got %d points", len(points))
+	}
+	cpu := points[0]
+	if cpu.Measurement != "cpu" || cpu.Tags["host"] != "web 1" || cpu.Tags["region"] != "eu" {
+		t.Fatalf("unexpected series %+v", cpu)
+	}
+	if len(cpu.Fields) != 3 || cpu.Fields["usage_user"].Value != 12.5 || cpu.Fields["usage_idle"].Value != 80 || cpu.Fields["up"].Value != 1 {
+		t.Fatalf("unexpected fields %+v", cpu.Fields)
+	}
+	if !cpu.Timestamp.Equal(time.Unix(1700000000, 0)) {
+		t.Fatalf("unexpected timestamp %v", cpu.Timestamp)
+	}
+	disk := points[1]
+	if disk.Measurement != "disk,io" || disk.Fields["reads"].Value != 5 || !disk.Timestamp.Equal(now) {
+		t.Fatalf("unexpected point %+v", disk)
+	}
+}
+
+func TestParseLineProtocolPrecision(t *testing.T) {
+	for precision, ts := range map[string]string{"s": "1700000000", "ms": "1700000000000", "ns": "1700000000000000000"} {
+		unit, err := lineProtocolPrecision(precision)
+		if err != nil {
+			t.Fatal(err)
+		}
+		points, err := parseLineProtocol("m v=1 "+ts, unit, time.Unix(1700000000, 0))
+		if err != nil {
+			t.Fatalf("%s: %v", precision, err)
+		}
+		if !points[0].Timestamp.Equal(time.Unix(1700000000, 0)) {
+			t.Fatalf("%s: got %v", precision, points[0].Timestamp)
+		}
+	}
+	if _, err := lineProtocolPrecision("d"); err == nil {
+		t.Fatal("expected unsupported precision error")
+	}
+}
+
+func TestParseLineProtocolErrors(t *testing.T) {
+	for _, line := range []string{
+		"cpu",
+		"cpu,host usage=1",
+		"cpu usage=abc",
+		"cpu usage=1 notatime",
+		"cpu usage=\"open",
+		"m v=1 99999999999999999",
+		"m v=1 1699900000000000000",
+		"m v=1 1700000120000000000",
+	} {
+		unit := time.Nanosecond
+		if line == "m v=1 99999999999999999" {
+			unit = time.Second
+		}
+		if _, err := parseLineProtocol(line, unit, time.Unix(1700000000, 0)); err == nil {
+			t.Fatalf("expected error for %q", line)
+		}
+	}
+}
+
+func TestLineProtocolCustomPoints(t *testing.T) {
+	parsed, err := parseLineProtocol("mem,host=a used=1i,free=2.5", time.Second, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	points := lineProtocolCustomPoints("uuid-1", parsed)
+	if len(points) != 2 {
+		t.Fatalf("got %d points", len(points))
+	}
+	byName := map[string]float64{}
+	for _, p := range points {
+		if p.EntityID != "uuid-1" || p.Tags["host"] != "a" {
+			t.Fatalf("unexpected point %+v", p)
+		}
+		if p.Type != metric.TypeGauge {
+			t.Fatalf("%s: type = %q, integer fields are gauges", p.MetricName, p.Type)
+		}
+		byName[p.MetricName] = p.Value
+	}
+	if byName["mem.used"] != 1 || byName["mem.free"] != 2.5 {
+		t.Fatalf("unexpected values %v", byName)
+	}
+}
@@ -0,0 +1,274 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/pkg/metric"
+	"github.com/komari-monitor/komari/web/api"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	otlpMaxBody   = 8 << 20
+	otlpMaxPoints = 20000
+	otlpSource    = "otlp"
+
+	// otlpClientTokenAttribute 资源属性中显式指定的客户端 token。
+	otlpClientTokenAttribute = "komari.client.token"
+	otlpHostNameAttribute    = "host.name"
+	otlpServiceNameAttribute = "service.name"
+
+	otlpContentTypeProtobuf = "application/x-protobuf"
+	otlpContentTypeJSON     = "application/json"
+)
+
+// ExportOTLPMetrics 实现 OTLP/HTTP metrics 接收端（POST .../v1/metrics），
+// 把 OTEL_EXPORTER_OTLP_ENDPOINT 配置为 http(s)://<komari>/api/clients/otlp
+// 即可。每个 resource 按以下顺序映射到客户端：资源属性 komari.client.token；
+// 请求本身使用的 agent token；管理员 API key 请求时按 host.name 匹配客户端名称。
+func ExportOTLPMetrics(c *gin.Context) {
+	mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+	if mediaType != otlpContentTypeProtobuf && mediaType != otlpContentTypeJSON {
+		api.RespondError(c, http.StatusUnsupportedMediaType, "Content-Type must be application/x-protobuf or application/json")
+		return
+	}
+	body, err := readIngestBody(c.Request, otlpMaxBody)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, errIngestBodyTooLarge) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		api.RespondError(c, status, err.Error())
+		return
+	}
+	var resources []otlpResourceMetrics
+	if mediaType == otlpContentTypeProtobuf {
+		resources, err = decodeOTLPProtobuf(body)
+	} else {
+		resources, err = decodeOTLPJSON(body)
+	}
+	if err != nil {
+		api.RespondError(c, http.StatusBadRequest, "Invalid OTLP payload: "+err.Error())
+		return
+	}
+
+	resolver := newOTLPClientResolver(c)
+	now := time.Now().UTC()
+	var points []metricstore.CustomPoint
+	rejected := 0
+	var messages []string
+	for _, rm := range resources {
+		uuid, err := resolver.resolve(rm.Attributes)
+		if err != nil {
+			for _, m := range rm.Metrics {
+				rejected += m.dataPointCount()
+			}
+			messages = append(messages, err.Error())
+			continue
+		}
+		for _, m := range rm.Metrics {
+			mapped, skipped := otlpCustomPoints(uuid, rm.Attributes, m, now)
+			points = append(points, mapped...)
+			rejected += skipped
+		}
+	}
+	if len(points) > otlpMaxPoints {
+		api.RespondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("too many points in one request (max %d)", otlpMaxPoints))
+		return
+	}
+	result, err := metricstore.WriteCustomPoints(c.Request.Context(), otlpSource, points)
+	if err != nil {
+		api.RespondError(c, http.StatusInternalServerError, "Failed to write metrics: "+err.Error())
+		return
+	}
+	rejected += result.Rejected
+	messages = append(messages, result.Errors...)
+	writeOTLPResponse(c, mediaType, rejected, strings.Join(messages, "; "))
+}
+
+func (m otlpMetric) dataPointCount() int {
+	return len(m.Numbers) + len(m.Histograms) + m.Unsupported
+}
+
+type otlpClientResolver struct {
+	agentUUID string
+	admin     bool
+	cache     map[string]string
+}
+
+func newOTLPClientResolver(c *gin.Context) *otlpClientResolver {
+	r := &otlpClientResolver{admin: api.GetRole(c) == api.RoleAdmin, cache: map[string]string{}}
+	if v, ok := c.Get("client_uuid"); ok {
+		r.agentUUID, _ = v.(string)
+	}
+	return r
+}
+
+func (r *otlpClientResolver) resolve(attrs map[string]string) (string, error) {
+	if token := strings.TrimSpace(attrs[otlpClientTokenAttribute]); token != "" {
+		key := "token\x00" + token
+		if uuid, ok := r.cache[key]; ok {
+			return uuid, nil
+		}
+		uuid, err := clients.GetClientUUIDByToken(token)
+		if err != nil || uuid == "" {
+			return "", fmt.Errorf("resource attribute %s does not match any client", otlpClientTokenAttribute)
+		}
+		r.cache[key] = uuid
+		return uuid, nil
+	}
+	if r.agentUUID != "" {
+		return r.agentUUID, nil
+	}
+	host := strings.TrimSpace(attrs[otlpHostNameAttribute])
+	if !r.admin || host == "" {
+		return "", fmt.Errorf("resource has neither %s nor a matching %s", otlpClientTokenAttribute, otlpHostNameAttribute)
+	}
+	key := "host\x00" + host
+	if uuid, ok := r.cache[key]; ok {
+		return uuid, nil
+	}
+	uuid, err := clients.GetClientUUIDByName(host)
+	if err != nil {
+		return "", fmt.Errorf("host.name %q does not match a client: %v", host, err)
+	}
+	r.cache[key] = uuid
+	return uuid, nil
+}
+
+// otlpCustomPoints 把一个 OTLP metric 展开为自定义指标点，返回无法映射的数据点数。
+//
+//   - gauge → gauge；单调累计 sum → counter，其余 sum → gauge
+//   - histogram → <name>（TypeHistogram，le 标签为桶上界，值为累计计数），
+//     以及 <name>.count / <name>.sum
+func otlpCustomPoints(uuid string, resource map[string]string, m otlpMetric, now time.Time) ([]metricstore.CustomPoint, int) {
+	name := sanitizeOTLPMetricName(m.Name)
+	rejected := m.Unsupported
+	if name == "" {
+		return nil, m.dataPointCount()
+	}
+	baseTags := func(attrs map[string]string) map[string]string {
+		tags := make(map[string]string, len(attrs)+1)
+		if service := resource[otlpServiceNameAttribute]; service != "" {
+			tags[otlpServiceNameAttribute] = service
+		}
+		for k, v := range attrs {
+			tags[k] = v
+		}
+		return tags
+	}
+	timestamp := func(nanos uint64) time.Time {
+		if nanos == 0 || nanos > uint64(1<<63-1) {
+			return now
+		}
+		return time.Unix(0, int64(nanos)).UTC()
+	}
+	cumulative := m.Temporality == otlpTemporalityCumulative
+	point := func(metricName string, tags map[string]string, ts time.Time, value float64, metricType metric.MetricType, unit string) metricstore.CustomPoint {
+		return metricstore.CustomPoint{
+			Point: metric.Point{MetricName: metricName, EntityID: uuid, Timestamp: ts, Value: value, Tags: tags},
+			Type:  metricType,
+			Unit:  unit,
+		}
+	}
+
+	var out []metricstore.CustomPoint
+	switch m.Kind {
+	case otlpKindGauge, otlpKindSum:
+		metricType := metric.TypeGauge
+		if m.Kind == otlpKindSum && m.Monotonic && cumulative {
+			metricType = metric.TypeCounter
+		}
+		for _, p := range m.Numbers {
+			if !p.HasValue {
+				rejected++
+				continue
+			}
+			out = append(out, point(name, baseTags(p.Attributes), timestamp(p.TimeUnixNano), p.Value, metricType, m.Unit))
+		}
+	case otlpKindHistogram:
+		totalsType := metric.TypeGauge
+		if cumulative {
+			totalsType = metric.TypeCounter
+		}
+		for _, p := range m.Histograms {
+			if len(p.BucketCounts) > 0 && len(p.BucketCounts) != len(p.ExplicitBounds)+1 {
+				rejected++
+				continue
+			}
+			ts := timestamp(p.TimeUnixNano)
+			var running uint64
+			for i, count := range p.BucketCounts {
+				running += count
+				le := "+Inf"
+				if i < len(p.ExplicitBounds) {
+					le = strconv.FormatFloat(p.ExplicitBounds[i], 'g', -1, 64)
+				}
+				tags := baseTags(p.Attributes)
+				tags["le"] = le
+				out = append(out, point(name, tags, ts, float64(running), metric.TypeHistogram, m.Unit))
+			}
+			out = append(out, point(name+".count", baseTags(p.Attributes), ts, float64(p.Count), totalsType, ""))
+			if p.HasSum {
+				out = append(out, point(name+".sum", baseTags(p.Attributes), ts, p.Sum, totalsType, m.Unit))
+			}
+		}
+	default:
+		rejected += len(m.Numbers) + len(m.Histograms)
+	}
+	return out, rejected
+}
+
+// sanitizeOTLPMetricName 将 OTel 允许但 Komari 指标名不允许的字符（如 /）替换为 _。
+func sanitizeOTLPMetricName(name string) string {
+	name = strings.TrimSpace(name)
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '.', r == '-', r == ':':
+			sb.WriteRune(r)
+		default:
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+// writeOTLPResponse 返回 ExportMetricsServiceResponse，编码与请求一致。
+func writeOTLPResponse(c *gin.Context, mediaType string, rejected int, message string) {
+	if mediaType == otlpContentTypeJSON {
+		resp := map[string]any{}
+		if rejected > 0 || message != "" {
+			resp["partialSuccess"] = map[string]any{
+				"rejectedDataPoints": strconv.Itoa(rejected),
+				"errorMessage":       message,
+			}
+		}
+		data, _ := json.Marshal(resp)
+		c.Data(http.StatusOK, otlpContentTypeJSON, data)
+		return
+	}
+	var out []byte
+	if rejected > 0 || message != "" {
+		var partial []byte
+		partial = protowire.AppendTag(partial, 1, protowire.VarintType)
+		partial = protowire.AppendVarint(partial, uint64(rejected))
+		if message != "" {
+			partial = protowire.AppendTag(partial, 2, protowire.BytesType)
+			partial = protowire.AppendString(partial, message)
+		}
+		out = protowire.AppendTag(out, 1, protowire.BytesType)
+		out = protowire.AppendBytes(out, partial)
+	}
+	c.Data(http.StatusOK, otlpContentTypeProtobuf, out)
+}
@@ -0,0 +1,560 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// OTLP metrics 的最小解码实现（opentelemetry/proto/metrics/v1），同时支持
+// protobuf 与 JSON 编码。只保留 Komari 用到的字段：资源属性、指标名称/单位、
+// gauge / sum / histogram 数据点；其余类型（exponential histogram、summary）
+// 解码后计为拒绝。
+
+type otlpMetricKind int
+
+const (
+	otlpKindUnsupported otlpMetricKind = iota
+	otlpKindGauge
+	otlpKindSum
+	otlpKindHistogram
+)
+
+// OTLP AggregationTemporality
+const otlpTemporalityCumulative = 2
+
+type otlpResourceMetrics struct {
+	Attributes map[string]string
+	Metrics    []otlpMetric
+}
+
+type otlpMetric struct {
+	Name        string
+	Unit        string
+	Kind        otlpMetricKind
+	Monotonic   bool
+	Temporality int
+	Numbers     []otlpNumberPoint
+	Histograms  []otlpHistogramPoint
+	// Unsupported 记录不支持类型中的数据点数量，用于 partial success。
+	Unsupported int
+}
+
+type otlpNumberPoint struct {
+	Attributes   map[string]string
+	TimeUnixNano uint64
+	Value        float64
+	HasValue     bool
+}
+
+type otlpHistogramPoint struct {
+	Attributes     map[string]string
+	TimeUnixNano   uint64
+	Count          uint64
+	Sum            float64
+	HasSum         bool
+	BucketCounts   []uint64
+	ExplicitBounds []float64
+}
+
+// --- protobuf ---
+
+type pbField struct {
+	num    protowire.Number
+	typ    protowire.Type
+	bytes  []byte
+	scalar uint64
+}
+
+func pbEach(b []byte, fn func(f pbField) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		f := pbField{num: num, typ: typ}
+		switch typ {
+		case protowire.VarintType:
+			f.scalar, n = protowire.ConsumeVarint(b)
+		case protowire.Fixed64Type:
+			f.scalar, n = protowire.ConsumeFixed64(b)
+		case protowire.Fixed32Type:
+			var v uint32
+			v, n = protowire.ConsumeFixed32(b)
+			f.scalar = uint64(v)
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		default:
+			n = protowire.ConsumeFieldValue(num, typ, b)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(f); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pbFixed64s 读取 repeated fixed64/double，兼容 packed 与非 packed 编码。
+func pbFixed64s(f pbField, dst []uint64) ([]uint64, error) {
+	switch f.typ {
+	case protowire.Fixed64Type:
+		return append(dst, f.scalar), nil
+	case protowire.BytesType:
+		b := f.bytes
+		for len(b) > 0 {
+			v, n := protowire.ConsumeFixed64(b)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			dst = append(dst, v)
+			b = b[n:]
+		}
+		return dst, nil
+	default:
+		return dst, nil
+	}
+}
+
+func decodeOTLPProtobuf(body []byte) ([]otlpResourceMetrics, error) {
+	var out []otlpResourceMetrics
+	err := pbEach(body, func(f pbField) error {
+		if f.num != 1 || f.typ != protowire.BytesType {
+			return nil
+		}
+		rm, err := decodePBResourceMetrics(f.bytes)
+		if err != nil {
+			return err
+		}
+		out = append(out, rm)
+		return nil
+	})
+	return out, err
+}
+
+func decodePBResourceMetrics(b []byte) (otlpResourceMetrics, error) {
+	rm := otlpResourceMetrics{Attributes: map[string]string{}}
+	err := pbEach(b, func(f pbField) error {
+		if f.typ != protowire.BytesType {
+			return nil
+		}
+		switch f.num {
+		case 1: // Resource
+			return pbEach(f.bytes, func(rf pbField) error {
+				if rf.num == 1 && rf.typ == protowire.BytesType {
+					return decodePBKeyValue(rf.bytes, rm.Attributes)
+				}
+				return nil
+			})
+		case 2: // ScopeMetrics
+			return pbEach(f.bytes, func(sf pbField) error {
+				if sf.num != 2 || sf.typ != protowire.BytesType {
+					return nil
+				}
+				m, err := decodePBMetric(sf.bytes)
+				if err != nil {
+					return err
+				}
+				rm.Metrics = append(rm.Metrics, m)
+				return nil
+			})
+		}
+		return nil
+	})
+	return rm, err
+}
+
+func decodePBMetric(b []byte) (otlpMetric, error) {
+	var m otlpMetric
+	err := pbEach(b, func(f pbField) error {
+		switch f.num {
+		case 1:
+			m.Name = string(f.bytes)
+		case 3:
+			m.Unit = string(f.bytes)
+		case 5: // Gauge
+			m.Kind = otlpKindGauge
+			return pbEach(f.bytes, func(gf pbField) error {
+				if gf.num == 1 && gf.typ == protowire.BytesType {
+					p, err := decodePBNumberPoint(gf.bytes)
+					if err != nil {
+						return err
+					}
+					m.Numbers = append(m.Numbers, p)
+				}
+				return nil
+			})
+		case 7: // Sum
+			m.Kind = otlpKindSum
+			return pbEach(f.bytes, func(sf pbField) error {
+				switch sf.num {
+				case 1:
+					p, err := decodePBNumberPoint(sf.bytes)
+					if err != nil {
+						return err
+					}
+					m.Numbers = append(m.Numbers, p)
+				case 2:
+					m.Temporality = int(sf.scalar)
+				case 3:
+					m.Monotonic = sf.scalar != 0
+				}
+				return nil
+			})
+		case 9: // Histogram
+			m.Kind = otlpKindHistogram
+			return pbEach(f.bytes, func(hf pbField) error {
+				switch hf.num {
+				case 1:
+					p, err := decodePBHistogramPoint(hf.bytes)
+					if err != nil {
+						return err
+					}
+					m.Histograms = append(m.Histograms, p)
+				case 2:
+					m.Temporality = int(hf.scalar)
+				}
+				return nil
+			})
+		case 10, 11: // ExponentialHistogram, Summary
+			m.Kind = otlpKindUnsupported
+			return pbEach(f.bytes, func(df pbField) error {
+				if df.num == 1 {
+					m.Unsupported++
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	return m, err
+}
+
+func decodePBNumberPoint(b []byte) (otlpNumberPoint, error) {
+	p := otlpNumberPoint{Attributes: map[string]string{}}
+	err := pbEach(b, func(f pbField) error {
+		switch f.num {
+		case 7:
+			return decodePBKeyValue(f.bytes, p.Attributes)
+		case 3:
+			p.TimeUnixNano = f.scalar
+		case 4:
+			p.Value, p.HasValue = math.Float64frombits(f.scalar), true
+		case 6:
+			p.Value, p.HasValue = float64(int64(f.scalar)), true
+		}
+		return nil
+	})
+	return p, err
+}
+
+func decodePBHistogramPoint(b []byte) (otlpHistogramPoint, error) {
+	p := otlpHistogramPoint{Attributes: map[string]string{}}
+	err := pbEach(b, func(f pbField) error {
+		var err error
+		switch f.num {
+		case 9:
+			return decodePBKeyValue(f.bytes, p.Attributes)
+		case 3:
+			p.TimeUnixNano = f.scalar
+		case 4:
+			p.Count = f.scalar
+		case 5:
+			p.Sum, p.HasSum = math.Float64frombits(f.scalar), true
+		case 6:
+			p.BucketCounts, err = pbFixed64s(f, p.BucketCounts)
+		case 7:
+			var bits []uint64
+			bits, err = pbFixed64s(f, nil)
+			for _, v := range bits {
+				p.ExplicitBounds = append(p.ExplicitBounds, math.Float64frombits(v))
+			}
+		}
+		return err
+	})
+	return p, err
+}
+
+func decodePBKeyValue(b []byte, dst map[string]string) error {
+	var key, value string
+	err := pbEach(b, func(f pbField) error {
+		switch f.num {
+		case 1:
+			key = string(f.bytes)
+		case 2:
+			v, err := decodePBAnyValue(f.bytes)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+		return nil
+	})
+	if err == nil && key != "" {
+		dst[key] = value
+	}
+	return err
+}
+
+func decodePBAnyValue(b []byte) (string, error) {
+	var out string
+	err := pbEach(b, func(f pbField) error {
+		switch f.num {
+		case 1:
+			out = string(f.bytes)
+		case 2:
+			out = strconv.FormatBool(f.scalar != 0)
+		case 3:
+			out = strconv.FormatInt(int64(f.scalar), 10)
+		case 4:
+			out = strconv.FormatFloat(math.Float64frombits(f.scalar), 'g', -1, 64)
+		case 5, 6: // ArrayValue / KeyValueList：按 JSON 形式展平
+			var items []string
+			err := pbEach(f.bytes, func(item pbField) error {
+				if item.num != 1 {
+					return nil
+				}
+				if f.num == 5 {
+					v, err := decodePBAnyValue(item.bytes)
+					items = append(items, strconv.Quote(v))
+					return err
+				}
+				kv := map[string]string{}
+				if err := decodePBKeyValue(item.bytes, kv); err != nil {
+					return err
+				}
+				for k, v := range kv {
+					items = append(items, strconv.Quote(k)+":"+strconv.Quote(v))
+				}
+				return nil
+			})
+			if f.num == 5 {
+				out = "[" + strings.Join(items, ",") + "]"
+			} else {
+				out = "{" + strings.Join(items, ",") + "}"
+			}
+			return err
+		case 7:
+			out = base64.StdEncoding.EncodeToString(f.bytes)
+		}
+		return nil
+	})
+	return out, err
+}
+
+// --- JSON（OTLP/HTTP JSON 编码，字段为 lowerCamelCase，64 位整数可能是字符串）---
+
+type otlpJSONUint64 uint64
+
+func (v *otlpJSONUint64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid uint64 %s", b)
+	}
+	*v = otlpJSONUint64(n)
+	return nil
+}
+
+type otlpJSONInt64 int64
+
+func (v *otlpJSONInt64) UnmarshalJSON(b []byte) error {
+	s := strings.Trim(string(b), `"`)
+	if s == "" || s == "null" {
+		*v = 0
+		return nil
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid int64 %s", b)
+	}
+	*v = otlpJSONInt64(n)
+	return nil
+}
+
+type otlpJSONKeyValue struct {
+	Key   string           `json:"key"`
+	Value otlpJSONAnyValue `json:"value"`
+}
+
+type otlpJSONAnyValue struct {
+	StringValue *string        `json:"stringValue"`
+	BoolValue   *bool          `json:"boolValue"`
+	IntValue    *otlpJSONInt64 `json:"intValue"`
+	DoubleValue *float64       `json:"doubleValue"`
+	ArrayValue  *struct {
+		Values []otlpJSONAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpJSONKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+	BytesValue *string `json:"bytesValue"`
+}
+
+func (v otlpJSONAnyValue) String() string {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return strconv.FormatBool(*v.BoolValue)
+	case v.IntValue != nil:
+		return strconv.FormatInt(int64(*v.IntValue), 10)
+	case v.DoubleValue != nil:
+		return strconv.FormatFloat(*v.DoubleValue, 'g', -1, 64)
+	case v.ArrayValue != nil:
+		items := make([]string, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			items = append(items, strconv.Quote(item.String()))
+		}
+		return "[" + strings.Join(items, ",") + "]"
+	case v.KvlistValue != nil:
+		items := make([]string, 0, len(v.KvlistValue.Values))
+		for _, kv := range v.KvlistValue.Values {
+			items = append(items, strconv.Quote(kv.Key)+":"+strconv.Quote(kv.Value.String()))
+		}
+		sort.Strings(items)
+		return "{" + strings.Join(items, ",") + "}"
+	case v.BytesValue != nil:
+		return *v.BytesValue
+	default:
+		return ""
+	}
+}
+
+func otlpJSONAttributes(kvs []otlpJSONKeyValue) map[string]string {
+	out := make(map[string]string, len(kvs))
+	for _, kv := range kvs {
+		if kv.Key != "" {
+			out[kv.Key] = kv.Value.String()
+		}
+	}
+	return out
+}
+
+type otlpJSONNumberPoint struct {
+	Attributes   []otlpJSONKeyValue `json:"attributes"`
+	TimeUnixNano otlpJSONUint64     `json:"timeUnixNano"`
+	AsDouble     *float64           `json:"asDouble"`
+	AsInt        *otlpJSONInt64     `json:"asInt"`
+}
+
+type otlpJSONHistogramPoint struct {
+	Attributes     []otlpJSONKeyValue `json:"attributes"`
+	TimeUnixNano   otlpJSONUint64     `json:"timeUnixNano"`
+	Count          otlpJSONUint64     `json:"count"`
+	Sum            *float64           `json:"sum"`
+	BucketCounts   []otlpJSONUint64   `json:"bucketCounts"`
+	ExplicitBounds []float64          `json:"explicitBounds"`
+}
+
+type otlpJSONDataPoints struct {
+	DataPoints []json.RawMessage `json:"dataPoints"`
+}
+
+type otlpJSONRequest struct {
+	ResourceMetrics []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeMetrics []struct {
+			Metrics []struct {
+				Name  string `json:"name"`
+				Unit  string `json:"unit"`
+				Gauge *struct {
+					DataPoints []otlpJSONNumberPoint `json:"dataPoints"`
+				} `json:"gauge"`
+				Sum *struct {
+					DataPoints             []otlpJSONNumberPoint `json:"dataPoints"`
+					AggregationTemporality int                   `json:"aggregationTemporality"`
+					IsMonotonic            bool                  `json:"isMonotonic"`
+				} `json:"sum"`
+				Histogram *struct {
+					DataPoints             []otlpJSONHistogramPoint `json:"dataPoints"`
+					AggregationTemporality int                      `json:"aggregationTemporality"`
+				} `json:"histogram"`
+				ExponentialHistogram *otlpJSONDataPoints `json:"exponentialHistogram"`
+				Summary              *otlpJSONDataPoints `json:"summary"`
+			} `json:"metrics"`
+		} `json:"scopeMetrics"`
+	} `json:"resourceMetrics"`
+}
+
+func decodeOTLPJSON(body []byte) ([]otlpResourceMetrics, error) {
+	var req otlpJSONRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		return nil, err
+	}
+	out := make([]otlpResourceMetrics, 0, len(req.ResourceMetrics))
+	for _, jrm := range req.ResourceMetrics {
+		rm := otlpResourceMetrics{Attributes: otlpJSONAttributes(jrm.Resource.Attributes)}
+		for _, scope := range jrm.ScopeMetrics {
+			for _, jm := range scope.Metrics {
+				m := otlpMetric{Name: jm.Name, Unit: jm.Unit}
+				switch {
+				case jm.Gauge != nil:
+					m.Kind = otlpKindGauge
+					m.Numbers = otlpJSONNumbers(jm.Gauge.DataPoints)
+				case jm.Sum != nil:
+					m.Kind = otlpKindSum
+					m.Temporality = jm.Sum.AggregationTemporality
+					m.Monotonic = jm.Sum.IsMonotonic
+					m.Numbers = otlpJSONNumbers(jm.Sum.DataPoints)
+				case jm.Histogram != nil:
+					m.Kind = otlpKindHistogram
+					m.Temporality = jm.Histogram.AggregationTemporality
+					for _, jp := range jm.Histogram.DataPoints {
+						p := otlpHistogramPoint{
+							Attributes:     otlpJSONAttributes(jp.Attributes),
+							TimeUnixNano:   uint64(jp.TimeUnixNano),
+							Count:          uint64(jp.Count),
+							ExplicitBounds: jp.ExplicitBounds,
+						}
+						if jp.Sum != nil {
+							p.Sum, p.HasSum = *jp.Sum, true
+						}
+						for _, c := range jp.BucketCounts {
+							p.BucketCounts = append(p.BucketCounts, uint64(c))
+						}
+						m.Histograms = append(m.Histograms, p)
+					}
+				case jm.ExponentialHistogram != nil:
+					m.Unsupported = len(jm.ExponentialHistogram.DataPoints)
+				case jm.Summary != nil:
+					m.Unsupported = len(jm.Summary.DataPoints)
+				}
+				rm.Metrics = append(rm.Metrics, m)
+			}
+		}
+		out = append(out, rm)
+	}
+	return out, nil
+}
+
+func otlpJSONNumbers(points []otlpJSONNumberPoint) []otlpNumberPoint {
+	out := make([]otlpNumberPoint, 0, len(points))
+	for _, jp := range points {
+		p := otlpNumberPoint{Attributes: otlpJSONAttributes(jp.Attributes), TimeUnixNano: uint64(jp.TimeUnixNano)}
+		switch {
+		case jp.AsDouble != nil:
+			p.Value, p.HasValue = *jp.AsDouble, true
+		case jp.AsInt != nil:
+			p.Value, p.HasValue = float64(*jp.AsInt), true
+		}
+		out = append(out, p)
+	}
+	return out
+}
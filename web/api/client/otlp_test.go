@@ -0,0 +1,159 @@
+package client
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/metric"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func pbBytes(b []byte, num protowire.Number, v []byte) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, v)
+}
+
+func pbString(b []byte, num protowire.Number, v string) []byte {
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func pbFixed64(b []byte, num protowire.Number, v uint64) []byte {
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, v)
+}
+
+func pbKeyValue(key, value string) []byte {
+	return pbBytes(pbString(nil, 1, key), 2, pbString(nil, 1, value))
+}
+
+func TestDecodeOTLPProtobuf(t *testing.T) {
+	ts := uint64(time.Unix(1700000000, 0).UnixNano())
+
+	gaugePoint := pbBytes(nil, 7, pbKeyValue("queue", "jobs"))
+	gaugePoint = pbFixed64(gaugePoint, 3, ts)
+	gaugePoint = pbFixed64(gaugePoint, 4, math.Float64bits(3.5))
+	gauge := pbString(nil, 1, "app.queue.depth")
+	gauge = pbString(gauge, 3, "{item}")
+	gauge = pbBytes(gauge, 5, pbBytes(nil, 1, gaugePoint))
+
+	sumPoint := pbFixed64(nil, 3, ts)
+	sumPoint = protowire.AppendTag(sumPoint, 6, protowire.Fixed64Type)
+	sumPoint = protowire.AppendFixed64(sumPoint, uint64(int64(42)))
+	sumBody := pbBytes(nil, 1, sumPoint)
+	sumBody = protowire.AppendTag(sumBody, 2, protowire.VarintType)
+	sumBody = protowire.AppendVarint(sumBody, otlpTemporalityCumulative)
+	sumBody = protowire.AppendTag(sumBody, 3, protowire.VarintType)
+	sumBody = protowire.AppendVarint(sumBody, 1)
+	sum := pbBytes(pbString(nil, 1, "http.server.requests"), 7, sumBody)
+
+	var packedCounts, packedBounds []byte
+	for _, c := range []uint64{1, 2, 3} {
+		packedCounts = protowire.AppendFixed64(packedCounts, c)
+	}
+	for _, b := range []float64{0.1, 1} {
+		packedBounds = protowire.AppendFixed64(packedBounds, math.Float64bits(b))
+	}
+	histPoint := pbFixed64(nil, 3, ts)
+	histPoint = pbFixed64(histPoint, 4, 6)
+	histPoint = pbFixed64(histPoint, 5, math.Float64bits(4.2))
+	histPoint = pbBytes(histPoint, 6, packedCounts)
+	histPoint = pbBytes(histPoint, 7, packedBounds)
+	histBody := pbBytes(nil, 1, histPoint)
+	histBody = protowire.AppendTag(histBody, 2, protowire.VarintType)
+	histBody = protowire.AppendVarint(histBody, otlpTemporalityCumulative)
+	hist := pbBytes(pbString(nil, 1, "http/server/duration"), 9, histBody)
+
+	scope := pbBytes(nil, 2, gauge)
+	scope = pbBytes(scope, 2, sum)
+	scope = pbBytes(scope, 2, hist)
+	resource := pbBytes(nil, 1, pbKeyValue("host.name", "web-1"))
+	resource = pbBytes(resource, 1, pbKeyValue("service.name", "api"))
+	rm := pbBytes(pbBytes(nil, 1, resource), 2, scope)
+	body := pbBytes(nil, 1, rm)
+
+	resources, err := decodeOTLPProtobuf(body)
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resources) != 1 || resources[0].Attributes["host.name"] != "web-1" || len(resources[0].Metrics) != 3 {
+		t.Fatalf("unexpected resources %+v", resources)
+	}
+
+	var points []string
+	counts := map[metric.MetricType]int{}
+	now := time.Now()
+	for _, m := range resources[0].Metrics {
+		mapped, rejected := otlpCustomPoints("uuid-1", resources[0].Attributes, m, now)
+		if rejected != 0 {
+			t.Fatalf("metric %s: %d rejected", m.Name, rejected)
+		}
+		for _, p := range mapped {
+			if p.EntityID != "uuid-1" || p.Tags["service.name"] != "api" || !p.Timestamp.Equal(time.Unix(1700000000, 0)) {
+				t.Fatalf("unexpected point %+v", p)
+			}
+			points = append(points, p.MetricName+"|"+p.Tags["le"])
+			counts[p.Type]++
+		}
+	}
+	want := []string{
+		"app.queue.depth|",
+		"http.server.requests|",
+		"http_server_duration|0.1", "http_server_duration|1", "http_server_duration|+Inf",
+		"http_server_duration.count|", "http_server_duration.sum|",
+	}
+	if len(points) != len(want) {
+		t.Fatalf("got %v", points)
+	}
+	for i := range want {
+		if points[i] != want[i] {
+			t.Fatalf("point %d = %s, want %s", i, points[i], want[i])
+		}
+	}
+	if counts[metric.TypeHistogram] != 3 || counts[metric.TypeCounter] != 3 || counts[metric.TypeGauge] != 1 {
+		t.Fatalf("unexpected types %v", counts)
+	}
+}
+
+func TestDecodeOTLPJSON(t *testing.T) {
+	body := `{"resourceMetrics":[{"resource":{"attributes":[{"key":"komari.client.token","value":{"stringValue":"tok"}}]},
+	"scopeMetrics":[{"metrics":[
+		{"name":"temp","unit":"Cel","gauge":{"dataPoints":[{"attributes":[{"key":"room","value":{"intValue":"3"}}],"timeUnixNano":"1700000000000000000","asDouble":21.5}]}},
+		{"name":"bytes","sum":{"aggregationTemporality":1,"isMonotonic":true,"dataPoints":[{"asInt":"10"}]}},
+		{"name":"lat","histogram":{"aggregationTemporality":2,"dataPoints":[{"count":"3","sum":1.5,"bucketCounts":["1","2"],"explicitBounds":[0.5]}]}},
+		{"name":"summary","summary":{"dataPoints":[{},{}]}}
+	]}]}]}`
+	resources, err := decodeOTLPJSON([]byte(body))
+	if err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if len(resources) != 1 || resources[0].Attributes[otlpClientTokenAttribute] != "tok" {
+		t.Fatalf("unexpected resources %+v", resources)
+	}
+	now := time.Unix(1800000000, 0)
+	total, rejectedTotal := 0, 0
+	for _, m := range resources[0].Metrics {
+		mapped, rejected := otlpCustomPoints("uuid-2", resources[0].Attributes, m, now)
+		total += len(mapped)
+		rejectedTotal += rejected
+		switch m.Name {
+		case "temp":
+			if mapped[0].Value != 21.5 || mapped[0].Tags["room"] != "3" || mapped[0].Unit != "Cel" {
+				t.Fatalf("unexpected gauge %+v", mapped[0])
+			}
+		case "bytes":
+			// Delta sums are stored as gauges of the per-interval increment.
+			if mapped[0].Value != 10 || mapped[0].Type != metric.TypeGauge || !mapped[0].Timestamp.Equal(now) {
+				t.Fatalf("unexpected sum %+v", mapped[0])
+			}
+		case "lat":
+			if mapped[1].Tags["le"] != "+Inf" || mapped[1].Value != 3 {
+				t.Fatalf("unexpected histogram bucket %+v", mapped[1])
+			}
+		}
+	}
+	if total != 6 || rejectedTotal != 2 {
+		t.Fatalf("got %d points, %d rejected", total, rejectedTotal)
+	}
+}
@@ -3,6 +3,7 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"sync"
@@ -19,6 +20,7 @@ import (
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
 	"github.com/komari-monitor/komari/web/api"
 	"github.com/komari-monitor/komari/web/connection"
+	"gorm.io/gorm"
 )
 
 const (
@@ -26,8 +28,36 @@ const (
 	// 因为目前server没有存agent的信息上报间隔。只有写一个默认的
 	readWait        = 11 * time.Second
 	postPresenceTTL = 35 * time.Second
+	// 长连接重新校验令牌的间隔：令牌轮换的重叠期结束或旧令牌被吊销后，
+	// 以旧令牌建立的连接最迟在该间隔后断开
+	tokenRecheckInterval = time.Minute
 )
 
+// tokenGuard 记录长连接建立时使用的令牌，并定期确认它仍然有效。
+type tokenGuard struct {
+	uuid      string
+	token     string
+	checkedAt time.Time
+}
+
+func newTokenGuard(uuid, token string) *tokenGuard {
+	return &tokenGuard{uuid: uuid, token: token, checkedAt: time.Now()}
+}
+
+// valid 在距上次校验超过 tokenRecheckInterval 时重新查询令牌。
+// 查询出错（非记录不存在）时不断开连接，等待下次校验。
+func (g *tokenGuard) valid(now time.Time) bool {
+	if now.Sub(g.checkedAt) < tokenRecheckInterval {
+		return true
+	}
+	g.checkedAt = now
+	uuid, err := clients.GetClientUUIDByToken(g.token)
+	if err != nil {
+		return !errors.Is(err, gorm.ErrRecordNotFound)
+	}
+	return uuid == g.uuid
+}
+
 // postPresenceEntry 保存单个客户端的 POST 上报会话状态
 type postPresenceEntry struct {
 	connID     int64
@@ -203,7 +233,12 @@ func WebSocketReport(c *gin.Context) {
 	// 首先处理第一次ws conn收到的消息
 	processMessage(conn, message, uuid)
 
+	guard := newTokenGuard(uuid, token)
 	for {
+		if !guard.valid(time.Now()) {
+			logger.Infof("client-api", "Client %s token is no longer valid, closing connection %d", uuid, conn.ID)
+			return
+		}
 		conn.SetReadDeadline(time.Now().Add(readWait))
 
 		_, message, err := conn.ReadMessage()
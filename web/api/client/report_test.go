@@ -0,0 +1,45 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func TestTokenGuardRejectsExpiredPreviousToken(t *testing.T) {
+	db := dbtest.Open(t, "token_guard")
+
+	clientUUID := "client-token-guard"
+	oldToken := "token-guard-old"
+	if err := db.Create(&models.Client{UUID: clientUUID, Token: models.HashClientToken(oldToken), Name: "token_guard"}).Error; err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	newToken, _, err := clients.RotateToken(clientUUID, time.Hour)
+	if err != nil {
+		t.Fatalf("rotate token: %v", err)
+	}
+
+	oldGuard := newTokenGuard(clientUUID, oldToken)
+	newGuard := newTokenGuard(clientUUID, newToken)
+	now := oldGuard.checkedAt
+	if !oldGuard.valid(now.Add(tokenRecheckInterval)) {
+		t.Fatal("old token should stay valid during the overlap")
+	}
+
+	if err := clients.RevokePreviousToken(clientUUID); err != nil {
+		t.Fatalf("revoke previous token: %v", err)
+	}
+	// 两次校验之间不查询数据库。
+	if !oldGuard.valid(now.Add(tokenRecheckInterval + time.Second)) {
+		t.Fatal("old token should not be re-checked before the interval elapses")
+	}
+	if oldGuard.valid(now.Add(2 * tokenRecheckInterval)) {
+		t.Fatal("connection using the expired old token should be closed")
+	}
+	if !newGuard.valid(now.Add(2 * tokenRecheckInterval)) {
+		t.Fatal("connection using the new token should stay open")
+	}
+}
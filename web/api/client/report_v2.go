@@ -12,7 +12,11 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/gorilla/websocket"
+	"github.com/komari-monitor/komari/database/agentevents"
+	"github.com/komari-monitor/komari/database/agentprofiles"
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/servicemonitors"
+	"github.com/komari-monitor/komari/pkg/invalid"
 	v2 "github.com/komari-monitor/komari/protocol/v2"
 	"github.com/komari-monitor/komari/utils/notifier"
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
@@ -54,10 +58,25 @@ func handleV2RPC(uuid string, req v2.Request, allowWait bool) v2.Response {
 		if err := ingestReport(uuid, params.Report, 2, true); err != nil {
 			return v2.Error(req.ID, -32000, "failed to save report", err.Error())
 		}
+		agentprofiles.Acknowledge(uuid, params.AckEventIDs)
 		return v2.Success(req.ID, gin.H{
 			"status": "success",
 			"events": agent_runtime.TakeV2Events(uuid, params.AckEventIDs, 8),
 		})
+	case v2.MethodAgentReportBatch:
+		var params v2.ReportBatchParams
+		if err := bindV2Params(req.Params, &params); err != nil {
+			return v2.Error(req.ID, -32602, "invalid report batch params", err.Error())
+		}
+		if err := ingestReportBatch(uuid, params.Reports, 2, true); err != nil {
+			return v2.Error(req.ID, -32000, "failed to save report batch", err.Error())
+		}
+		agentprofiles.Acknowledge(uuid, params.AckEventIDs)
+		return v2.Success(req.ID, gin.H{
+			"status":   "success",
+			"accepted": len(params.Reports),
+			"events":   agent_runtime.TakeV2Events(uuid, params.AckEventIDs, 8),
+		})
 	case v2.MethodAgentBasicInfo:
 		var params v2.BasicInfoParams
 		if err := bindV2Params(req.Params, &params); err != nil {
@@ -66,6 +85,14 @@ func handleV2RPC(uuid string, req v2.Request, allowWait bool) v2.Response {
 		if err := ingestBasicInfo(uuid, params.Info, ""); err != nil {
 			return v2.Error(req.ID, -32000, "failed to save basic info", err.Error())
 		}
+		// Agent 启动时上报基础信息，此时下发服务端配置（轮询模式没有连接事件）。
+		agent_runtime.SetClientProtocolVersion(uuid, 2)
+		if err := agentprofiles.Sync(uuid); err != nil {
+			logger.Warnf("client-api", "Failed to send agent config to %s: %v", uuid, err)
+		}
+		if err := servicemonitors.Sync(uuid); err != nil {
+			logger.Warnf("client-api", "Failed to send monitored services to %s: %v", uuid, err)
+		}
 		return v2.Success(req.ID, gin.H{"status": "success"})
 	case v2.MethodAgentPingResult:
 		var params v2.PingResultParams
@@ -76,6 +103,47 @@ func handleV2RPC(uuid string, req v2.Request, allowWait bool) v2.Response {
 			return v2.Error(req.ID, -32000, "failed to save ping result", err.Error())
 		}
 		return v2.Success(req.ID, gin.H{"status": "success"})
+	case v2.MethodAgentServiceStat:
+		var params v2.ServiceStatusParams
+		if err := bindV2Params(req.Params, &params); err != nil {
+			return v2.Error(req.ID, -32602, "invalid service status params", err.Error())
+		}
+		if err := ingestServiceStatus(uuid, params.Services); err != nil {
+			return v2.Error(req.ID, -32000, "failed to save service status", err.Error())
+		}
+		return v2.Success(req.ID, gin.H{"status": "success"})
+	case v2.MethodAgentEvent, v2.MethodAgentMessage:
+		var params v2.EventParams
+		if req.Method == v2.MethodAgentMessage {
+			var message v2.MessageParams
+			if err := bindV2Params(req.Params, &message); err != nil {
+				return v2.Error(req.ID, -32602, "invalid message params", err.Error())
+			}
+			params = agentevents.FromMessage(message)
+		} else if err := bindV2Params(req.Params, &params); err != nil {
+			return v2.Error(req.ID, -32602, "invalid event params", err.Error())
+		}
+		event, err := ingestAgentEvent(uuid, params)
+		if err != nil {
+			if invalid.Is(err) {
+				return v2.Error(req.ID, -32602, "invalid event params", err.Error())
+			}
+			return v2.Error(req.ID, -32000, "failed to save event", err.Error())
+		}
+		return v2.Success(req.ID, gin.H{"status": "success", "id": event.Id})
+	case v2.MethodAgentLogs:
+		var params v2.LogsParams
+		if err := bindV2Params(req.Params, &params); err != nil {
+			return v2.Error(req.ID, -32602, "invalid logs params", err.Error())
+		}
+		accepted, err := ingestLogs(uuid, params.Lines)
+		if err != nil {
+			if invalid.Is(err) {
+				return v2.Error(req.ID, -32602, "invalid logs params", err.Error())
+			}
+			return v2.Error(req.ID, -32000, "failed to save logs", err.Error())
+		}
+		return v2.Success(req.ID, gin.H{"status": "success", "accepted": accepted})
 	case v2.MethodAgentPull:
 		var params v2.PullParams
 		if err := bindV2Params(req.Params, &params); err != nil {
@@ -83,6 +151,7 @@ func handleV2RPC(uuid string, req v2.Request, allowWait bool) v2.Response {
 		}
 		refreshPostPresence(uuid)
 		agent_runtime.SetClientProtocolVersion(uuid, 2)
+		agentprofiles.Acknowledge(uuid, params.AckEventIDs)
 		timeout := 0 * time.Second
 		if allowWait {
 			timeout = 25 * time.Second
@@ -149,8 +218,19 @@ func WebSocketV2RPC(c *gin.Context) {
 	if !pushQueuedV2Events(conn, uuid) {
 		return
 	}
+	if err := agentprofiles.Sync(uuid); err != nil {
+		logger.Warnf("client-api", "Failed to send agent config to %s: %v", uuid, err)
+	}
+	if err := servicemonitors.Sync(uuid); err != nil {
+		logger.Warnf("client-api", "Failed to send monitored services to %s: %v", uuid, err)
+	}
 
+	guard := newTokenGuard(uuid, api.ClientToken(c))
 	for {
+		if !guard.valid(time.Now()) {
+			logger.Infof("client-api", "Client %s token is no longer valid, closing v2 connection %d", uuid, conn.ID)
+			return
+		}
 		conn.SetReadDeadline(time.Now().Add(readWait))
 		_, message, err := conn.ReadMessage()
 		if err != nil {
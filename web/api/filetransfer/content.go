@@ -0,0 +1,21 @@
+package filetransfer
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/web/api"
+)
+
+// DownloadContent 返回从 Agent 取回的文件。
+func DownloadContent(c *gin.Context) {
+	t, path, err := Content(c.Param("id"))
+	if err != nil {
+		api.RespondError(c, http.StatusNotFound, "Transfer not found or not completed")
+		return
+	}
+	auditlog.Log(c.ClientIP(), c.GetString("uuid"), "content downloaded, file transfer id:"+t.ID+", client:"+t.ClientUUID+", path:"+t.Path, "file_transfer")
+	c.Header("X-Checksum-Sha256", t.SHA256)
+	c.FileAttachment(path, fileName(t.Path))
+}
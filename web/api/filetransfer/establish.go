@@ -0,0 +1,247 @@
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"github.com/komari-monitor/komari/web/api"
+)
+
+// 文本帧类型，见 v2.FileTransferFrame。
+const (
+	frameMeta   = "meta"
+	frameEnd    = "end"
+	frameResult = "result"
+)
+
+// frameConn 是传输所需的 WebSocket 操作，便于在测试中替换。
+type frameConn interface {
+	ReadMessage() (int, []byte, error)
+	WriteMessage(messageType int, data []byte) error
+	SetReadDeadline(t time.Time) error
+}
+
+// EstablishConnection 处理 Agent 收到 agent.fileTransfer 后发起的 WebSocket 连接。
+// 只有传输的目标客户端可以连接，且每个传输只接受一次连接。
+func EstablishConnection(c *gin.Context) {
+	id := c.Query("id")
+	clientUUID := c.GetString("client_uuid")
+	if !api.IsWebSocketUpgrade(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "Require WebSocket upgrade"})
+		return
+	}
+	mu.Lock()
+	t, exists := transfers[id]
+	if !exists || clientUUID == "" || t.ClientUUID != clientUUID || t.Status != StatusPending {
+		mu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "error": "Transfer not found"})
+		return
+	}
+	t.Status = StatusRunning
+	t.startedAt = time.Now().UTC()
+	mu.Unlock()
+
+	conn, err := api.UpgradeSafeConn(c)
+	if err != nil {
+		finish(t, fmt.Errorf("upgrade connection: %w", err))
+		return
+	}
+	go func() {
+		defer conn.Close()
+		var err error
+		if t.Direction == DirectionUpload {
+			err = sendFile(t, conn)
+		} else {
+			err = receiveFile(t, conn, MaxSize())
+		}
+		if err != nil {
+			// Agent 可能已断开，尽力告知失败原因。
+			_ = writeFrame(conn, v2.FileTransferFrame{Type: frameResult, Error: err.Error()})
+		}
+		finish(t, err)
+	}()
+}
+
+func writeFrame(conn frameConn, frame v2.FileTransferFrame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// readFrame 读取下一帧；二进制帧返回 nil frame 与原始数据。
+func readFrame(conn frameConn) (*v2.FileTransferFrame, []byte, error) {
+	if err := conn.SetReadDeadline(time.Now().Add(idleTimeout)); err != nil {
+		return nil, nil, err
+	}
+	messageType, data, err := conn.ReadMessage()
+	if err != nil {
+		return nil, nil, fmt.Errorf("connection lost: %w", err)
+	}
+	if messageType == websocket.BinaryMessage {
+		return nil, data, nil
+	}
+	var frame v2.FileTransferFrame
+	if err := json.Unmarshal(data, &frame); err != nil {
+		return nil, nil, fmt.Errorf("invalid frame: %w", err)
+	}
+	return &frame, nil, nil
+}
+
+func agentError(frame *v2.FileTransferFrame) error {
+	if frame.Error == "" {
+		return errors.New("agent reported failure")
+	}
+	return errors.New("agent: " + frame.Error)
+}
+
+func addProgress(t *Transfer, n int) {
+	mu.Lock()
+	t.Transferred += int64(n)
+	mu.Unlock()
+}
+
+// sendFile 把暂存文件按块发给 Agent，再发送 end 帧并等待 Agent 校验后的 result。
+func sendFile(t *Transfer, conn frameConn) error {
+	f, err := os.Open(t.file)
+	if err != nil {
+		return fmt.Errorf("open staged file: %w", err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	buf := make([]byte, ChunkSize)
+	var sent int64
+	for {
+		n, err := f.Read(buf)
+		if n > 0 {
+			h.Write(buf[:n])
+			if err := conn.WriteMessage(websocket.BinaryMessage, buf[:n]); err != nil {
+				return fmt.Errorf("connection lost: %w", err)
+			}
+			sent += int64(n)
+			addProgress(t, n)
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read staged file: %w", err)
+		}
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if sent != t.Size || sum != t.SHA256 {
+		return errors.New("staged file changed during transfer")
+	}
+	if err := writeFrame(conn, v2.FileTransferFrame{Type: frameEnd, Size: sent, SHA256: sum}); err != nil {
+		return fmt.Errorf("connection lost: %w", err)
+	}
+	for {
+		frame, _, err := readFrame(conn)
+		if err != nil {
+			return err
+		}
+		if frame == nil || frame.Type != frameResult {
+			continue
+		}
+		if !frame.OK {
+			return agentError(frame)
+		}
+		if frame.SHA256 != "" && frame.SHA256 != sum {
+			return errors.New("checksum mismatch on agent")
+		}
+		return nil
+	}
+}
+
+// receiveFile 接收 Agent 发送的文件：meta 帧声明大小，随后是内容与带 SHA256 的 end 帧。
+// 超过 limit 或与声明大小不符时立即中止；校验通过后回复 result 并保留文件供下载。
+func receiveFile(t *Transfer, conn frameConn, limit int64) (err error) {
+	if err := os.MkdirAll(Dir, 0o755); err != nil {
+		return err
+	}
+	partPath := filepath.Join(Dir, t.ID+".part")
+	f, err := os.Create(partPath)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = f.Close()
+		if err != nil {
+			_ = os.Remove(partPath)
+		}
+	}()
+	h := sha256.New()
+	declared := int64(-1)
+	var received int64
+	for {
+		frame, data, err := readFrame(conn)
+		if err != nil {
+			return err
+		}
+		if frame == nil {
+			if declared < 0 {
+				return errors.New("content received before meta")
+			}
+			received += int64(len(data))
+			if received > declared {
+				return fmt.Errorf("agent sent more than the declared %d bytes", declared)
+			}
+			if _, err := f.Write(data); err != nil {
+				return fmt.Errorf("write file: %w", err)
+			}
+			h.Write(data)
+			addProgress(t, len(data))
+			continue
+		}
+		switch frame.Type {
+		case frameMeta:
+			if declared >= 0 {
+				return errors.New("duplicate meta frame")
+			}
+			if frame.Size < 0 || frame.Size > limit {
+				return fmt.Errorf("file exceeds the transfer size limit of %d bytes", limit)
+			}
+			declared = frame.Size
+			mu.Lock()
+			t.Size = declared
+			mu.Unlock()
+		case frameEnd:
+			if declared < 0 || received != declared {
+				return fmt.Errorf("received %d bytes, expected %d", received, declared)
+			}
+			sum := hex.EncodeToString(h.Sum(nil))
+			if frame.SHA256 != sum {
+				return errors.New("checksum mismatch")
+			}
+			if err := f.Close(); err != nil {
+				return fmt.Errorf("write file: %w", err)
+			}
+			final := filepath.Join(Dir, t.ID)
+			if err := os.Rename(partPath, final); err != nil {
+				return fmt.Errorf("store file: %w", err)
+			}
+			mu.Lock()
+			t.SHA256 = sum
+			t.file = final
+			mu.Unlock()
+			_ = writeFrame(conn, v2.FileTransferFrame{Type: frameResult, OK: true, SHA256: sum})
+			return nil
+		case frameResult:
+			if !frame.OK {
+				return agentError(frame)
+			}
+		}
+	}
+}
@@ -0,0 +1,411 @@
+// Package filetransfer 通过与终端相同的 Agent WebSocket 通道在服务端与客户端之间传输文件。
+//
+// 发送文件：管理员先通过分片上传（upload.PurposeFileTransfer）把文件暂存到服务端，再用
+// admin:sendFile 发往一个或多个客户端。取回文件：admin:requestFile 让 Agent 把文件传回服务端，
+// 完成后通过 /api/admin/file-transfers/:id/content 下载。两个方向都由 agent.fileTransfer
+// 事件通知 Agent 连接 /api/clients/file-transfer，按块传输并用 SHA256 校验。
+//
+// 传输状态只保存在内存中；结束后保留 retention，之后由 Prune 连同本地文件一起清理。
+package filetransfer
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"github.com/komari-monitor/komari/utils"
+	"github.com/komari-monitor/komari/web/agent"
+)
+
+// 传输方向
+const (
+	DirectionUpload   = "upload"   // 服务端 → Agent
+	DirectionDownload = "download" // Agent → 服务端
+)
+
+// 传输状态
+const (
+	StatusPending   = "pending" // 等待 Agent 连接
+	StatusRunning   = "running"
+	StatusCompleted = "completed"
+	StatusFailed    = "failed"
+)
+
+const (
+	// ChunkSize 是每个二进制帧携带的最大字节数。
+	ChunkSize = 64 << 10
+	// DefaultMaxSizeMB 是 file_transfer_max_size_mb 的默认值。
+	DefaultMaxSizeMB = 1024
+
+	connectTimeout = time.Minute
+	idleTimeout    = time.Minute
+	retention      = time.Hour
+	maxPathLength  = 4096
+)
+
+// Dir 保存暂存待发送的文件（staged 子目录，以 SHA256 命名）与从 Agent 取回的文件（以传输 ID 命名）。
+var Dir = filepath.Join(".", "data", "transfers")
+
+// ErrNotFound 表示传输不存在、已被清理，或（取回内容时）尚未完成。
+var ErrNotFound = errors.New("file transfer not found")
+
+// Requester 是发起传输的管理员，用于审计。
+type Requester struct {
+	UserUUID string
+	IP       string
+}
+
+// Transfer 是一次到单个客户端的文件传输。
+type Transfer struct {
+	ID          string     `json:"id"`
+	ClientUUID  string     `json:"client_uuid"`
+	Direction   string     `json:"direction"`
+	Path        string     `json:"path"` // Agent 上的文件路径
+	Size        int64      `json:"size"` // 取回文件时在 Agent 报告大小之前为 0
+	Transferred int64      `json:"transferred"`
+	SHA256      string     `json:"sha256,omitempty"`
+	Status      string     `json:"status"`
+	Error       string     `json:"error,omitempty"`
+	UserUUID    string     `json:"user_uuid"`
+	CreatedAt   time.Time  `json:"created_at"`
+	FinishedAt  *time.Time `json:"finished_at,omitempty"`
+
+	requesterIP string
+	overwrite   bool
+	file        string // 发送时为暂存文件；取回完成后为服务端保存的文件
+	startedAt   time.Time
+}
+
+var (
+	mu        sync.Mutex
+	transfers = map[string]*Transfer{}
+)
+
+// MaxSize 返回单次传输允许的最大字节数。
+func MaxSize() int64 {
+	mb, err := config.GetAs[int](config.FileTransferMaxSizeMBKey, DefaultMaxSizeMB)
+	if err != nil || mb <= 0 {
+		mb = DefaultMaxSizeMB
+	}
+	return int64(mb) << 20
+}
+
+func stagedDir() string {
+	return filepath.Join(Dir, "staged")
+}
+
+// StagedFile 是暂存在服务端、等待发送给 Agent 的文件。
+type StagedFile struct {
+	File   string `json:"file"` // 传给 admin:sendFile 的标识，即文件的 SHA256
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// Stage 计算上传文件的校验和并把它移入暂存目录。超过 MaxSize 的文件会被拒绝。
+func Stage(src, name string) (*StagedFile, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return nil, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	_ = f.Close()
+	if err != nil {
+		return nil, err
+	}
+	if limit := MaxSize(); size > limit {
+		return nil, invalid.Errorf("file exceeds the transfer size limit of %d bytes", limit)
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if err := os.MkdirAll(stagedDir(), 0o755); err != nil {
+		return nil, err
+	}
+	dst := filepath.Join(stagedDir(), sum)
+	if _, err := os.Stat(dst); err == nil {
+		now := time.Now()
+		_ = os.Chtimes(dst, now, now)
+	} else if err := os.Rename(src, dst); err != nil {
+		return nil, fmt.Errorf("stage file: %w", err)
+	}
+	return &StagedFile{File: sum, Name: filepath.Base(name), Size: size, SHA256: sum}, nil
+}
+
+func validStagedName(name string) bool {
+	if len(name) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(name)
+	return err == nil
+}
+
+// normalizePath 校验 Agent 上的目标路径。Agent 可能运行在 Windows 上，
+// 因此同时接受 /etc/app.conf 与 C:\app\app.conf 两种绝对路径。
+func normalizePath(p string) (string, error) {
+	p = strings.TrimSpace(p)
+	if p == "" || len(p) > maxPathLength || strings.ContainsRune(p, 0) {
+		return "", invalid.Errorf("path is required and must be at most %d characters", maxPathLength)
+	}
+	abs := strings.HasPrefix(p, "/") || len(p) >= 3 && p[1] == ':' && (p[2] == '\\' || p[2] == '/') &&
+		(p[0] >= 'a' && p[0] <= 'z' || p[0] >= 'A' && p[0] <= 'Z')
+	if !abs {
+		return "", invalid.Errorf("path must be absolute")
+	}
+	return p, nil
+}
+
+// SendFile 把暂存文件发往客户端的 path，每个客户端一次传输。离线或不支持文件传输的
+// 客户端对应的传输直接标记为失败。
+func SendFile(by Requester, file, path string, clientUUIDs []string, overwrite bool) ([]Transfer, error) {
+	path, err := normalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if !validStagedName(file) {
+		return nil, invalid.Errorf("invalid staged file %q", file)
+	}
+	staged := filepath.Join(stagedDir(), file)
+	info, err := os.Stat(staged)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, invalid.Errorf("staged file %q not found", file)
+		}
+		return nil, err
+	}
+	if limit := MaxSize(); info.Size() > limit {
+		return nil, invalid.Errorf("file exceeds the transfer size limit of %d bytes", limit)
+	}
+	var targets []string
+	for _, uuid := range clientUUIDs {
+		if uuid != "" && !slices.Contains(targets, uuid) {
+			targets = append(targets, uuid)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, invalid.Errorf("clients is required")
+	}
+	for _, uuid := range targets {
+		if _, err := clients.GetClientByUUID(uuid); err != nil {
+			return nil, invalid.Errorf("client %s not found", uuid)
+		}
+	}
+	// 发送期间暂存文件不应被 Prune 清理。
+	now := time.Now()
+	_ = os.Chtimes(staged, now, now)
+
+	out := make([]Transfer, 0, len(targets))
+	for _, uuid := range targets {
+		t := newTransfer(by, uuid, DirectionUpload, path)
+		t.Size = info.Size()
+		t.SHA256 = file
+		t.file = staged
+		t.overwrite = overwrite
+		out = append(out, start(t))
+	}
+	return out, nil
+}
+
+// RequestFile 让客户端把 path 上的文件传回服务端。
+func RequestFile(by Requester, clientUUID, path string) (*Transfer, error) {
+	path, err := normalizePath(path)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := clients.GetClientByUUID(clientUUID); err != nil {
+		return nil, invalid.Errorf("client %s not found", clientUUID)
+	}
+	t := start(newTransfer(by, clientUUID, DirectionDownload, path))
+	return &t, nil
+}
+
+func newTransfer(by Requester, clientUUID, direction, path string) *Transfer {
+	return &Transfer{
+		ID:          utils.GenerateRandomString(32),
+		ClientUUID:  clientUUID,
+		Direction:   direction,
+		Path:        path,
+		Status:      StatusPending,
+		UserUUID:    by.UserUUID,
+		CreatedAt:   time.Now().UTC(),
+		requesterIP: by.IP,
+	}
+}
+
+// start 登记传输并通知 Agent 连接；Agent 未在 connectTimeout 内连接时传输失败。
+func start(t *Transfer) Transfer {
+	mu.Lock()
+	transfers[t.ID] = t
+	mu.Unlock()
+	auditlog.Log(t.requesterIP, t.UserUUID, fmt.Sprintf("%s requested, file transfer id:%s, client:%s, path:%s, size:%d",
+		t.Direction, t.ID, t.ClientUUID, t.Path, t.Size), "file_transfer")
+
+	switch {
+	case agent.GetConnectedClients()[t.ClientUUID] == nil && !agent.IsV2Client(t.ClientUUID):
+		finish(t, errors.New("client offline"))
+	case !agent.IsV2Client(t.ClientUUID):
+		finish(t, errors.New("agent does not support file transfer"))
+	default:
+		params := v2.FileTransferParams{
+			TransferID: t.ID,
+			Direction:  t.Direction,
+			Path:       t.Path,
+			Overwrite:  t.overwrite,
+			MaxSize:    MaxSize(),
+		}
+		if t.Direction == DirectionUpload {
+			params.Size, params.SHA256 = t.Size, t.SHA256
+		}
+		if !agent.DispatchV2Event(t.ClientUUID, v2.MethodAgentFileTransfer, params) {
+			finish(t, errors.New("client offline"))
+			break
+		}
+		time.AfterFunc(connectTimeout, func() {
+			mu.Lock()
+			pending := t.Status == StatusPending
+			mu.Unlock()
+			if pending {
+				finish(t, errors.New("agent did not connect in time"))
+			}
+		})
+	}
+	return snapshot(t)
+}
+
+// finish 记录传输结果并写入审计日志。err 为 nil 表示成功。
+func finish(t *Transfer, err error) {
+	now := time.Now().UTC()
+	mu.Lock()
+	if t.Status == StatusCompleted || t.Status == StatusFailed {
+		mu.Unlock()
+		return
+	}
+	t.FinishedAt = &now
+	if err != nil {
+		t.Status = StatusFailed
+		t.Error = err.Error()
+	} else {
+		t.Status = StatusCompleted
+	}
+	done := *t
+	mu.Unlock()
+
+	if err != nil {
+		auditlog.Log(done.requesterIP, done.UserUUID, fmt.Sprintf("%s failed, file transfer id:%s, client:%s, path:%s, transferred:%d, error:%s",
+			done.Direction, done.ID, done.ClientUUID, done.Path, done.Transferred, done.Error), "file_transfer")
+		return
+	}
+	var duration time.Duration
+	if !done.startedAt.IsZero() {
+		duration = now.Sub(done.startedAt)
+	}
+	auditlog.Log(done.requesterIP, done.UserUUID, fmt.Sprintf("%s completed, file transfer id:%s, client:%s, path:%s, size:%d, sha256:%s, duration:%s",
+		done.Direction, done.ID, done.ClientUUID, done.Path, done.Size, done.SHA256, duration), "file_transfer")
+}
+
+func snapshot(t *Transfer) Transfer {
+	mu.Lock()
+	defer mu.Unlock()
+	return *t
+}
+
+// List 按创建时间倒序列出传输；clientUUID 为空时返回全部客户端。
+func List(clientUUID string) []Transfer {
+	mu.Lock()
+	out := make([]Transfer, 0, len(transfers))
+	for _, t := range transfers {
+		if clientUUID == "" || t.ClientUUID == clientUUID {
+			out = append(out, *t)
+		}
+	}
+	mu.Unlock()
+	slices.SortFunc(out, func(a, b Transfer) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out
+}
+
+// Get 返回传输的当前状态与进度。
+func Get(id string) (Transfer, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := transfers[id]
+	if !ok {
+		return Transfer{}, false
+	}
+	return *t, true
+}
+
+// Content 返回已完成的取回传输及其在服务端保存的文件路径。
+func Content(id string) (Transfer, string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := transfers[id]
+	if !ok || t.Direction != DirectionDownload || t.Status != StatusCompleted || t.file == "" {
+		return Transfer{}, "", ErrNotFound
+	}
+	return *t, t.file, nil
+}
+
+// Prune 清理结束超过 retention 的传输及其文件、不再使用的暂存文件，以及服务端重启后
+// 遗留的文件。由定时清理任务调用。
+func Prune(now time.Time) error {
+	cutoff := now.Add(-retention)
+	inUse := map[string]bool{}
+	mu.Lock()
+	for id, t := range transfers {
+		if t.FinishedAt != nil && t.FinishedAt.Before(cutoff) {
+			delete(transfers, id)
+			continue
+		}
+		inUse[filepath.Base(t.file)] = true
+		inUse[t.ID] = true
+		inUse[t.ID+".part"] = true
+	}
+	mu.Unlock()
+
+	var errs []error
+	for _, dir := range []string{Dir, stagedDir()} {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+			continue
+		}
+		for _, entry := range entries {
+			if entry.IsDir() || inUse[entry.Name()] {
+				continue
+			}
+			info, err := entry.Info()
+			if err != nil || info.ModTime().After(cutoff) {
+				continue
+			}
+			if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil && !os.IsNotExist(err) {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// fileName 返回 Agent 路径中的文件名，兼容 Windows 分隔符。
+func fileName(p string) string {
+	if i := strings.LastIndexAny(p, `/\`); i >= 0 {
+		p = p[i+1:]
+	}
+	if p == "" {
+		return "download"
+	}
+	return p
+}
@@ -0,0 +1,168 @@
+package filetransfer
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+type frame struct {
+	messageType int
+	data        []byte
+}
+
+// fakeConn 按顺序返回预置的入站帧，并记录写出的帧。
+type fakeConn struct {
+	incoming []frame
+	written  []frame
+}
+
+func (c *fakeConn) ReadMessage() (int, []byte, error) {
+	if len(c.incoming) == 0 {
+		return 0, nil, errors.New("closed")
+	}
+	f := c.incoming[0]
+	c.incoming = c.incoming[1:]
+	return f.messageType, f.data, nil
+}
+
+func (c *fakeConn) WriteMessage(messageType int, data []byte) error {
+	c.written = append(c.written, frame{messageType, append([]byte(nil), data...)})
+	return nil
+}
+
+func (c *fakeConn) SetReadDeadline(time.Time) error { return nil }
+
+func textFrame(t *testing.T, f v2.FileTransferFrame) frame {
+	t.Helper()
+	data, err := json.Marshal(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return frame{websocket.TextMessage, data}
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestReceiveFileVerifiesSizeAndChecksum(t *testing.T) {
+	Dir = t.TempDir()
+	content := bytes.Repeat([]byte("komari"), 20000)
+
+	tr := &Transfer{ID: "ok", Direction: DirectionDownload}
+	conn := &fakeConn{incoming: []frame{
+		textFrame(t, v2.FileTransferFrame{Type: frameMeta, Size: int64(len(content))}),
+		{websocket.BinaryMessage, content[:ChunkSize]},
+		{websocket.BinaryMessage, content[ChunkSize:]},
+		textFrame(t, v2.FileTransferFrame{Type: frameEnd, SHA256: checksum(content)}),
+	}}
+	if err := receiveFile(tr, conn, 1<<20); err != nil {
+		t.Fatalf("receiveFile: %v", err)
+	}
+	got, err := os.ReadFile(filepath.Join(Dir, "ok"))
+	if err != nil || !bytes.Equal(got, content) {
+		t.Fatalf("stored file mismatch: %v", err)
+	}
+	if tr.Transferred != int64(len(content)) || tr.Size != int64(len(content)) || tr.SHA256 != checksum(content) {
+		t.Fatalf("unexpected progress: %+v", tr)
+	}
+	if len(conn.written) != 1 || !strings.Contains(string(conn.written[0].data), `"ok":true`) {
+		t.Fatalf("agent was not sent a successful result: %+v", conn.written)
+	}
+
+	cases := map[string][]frame{
+		"checksum mismatch": {
+			textFrame(t, v2.FileTransferFrame{Type: frameMeta, Size: 3}),
+			{websocket.BinaryMessage, []byte("abc")},
+			textFrame(t, v2.FileTransferFrame{Type: frameEnd, SHA256: checksum([]byte("abd"))}),
+		},
+		"exceeds the transfer size limit": {
+			textFrame(t, v2.FileTransferFrame{Type: frameMeta, Size: 1 << 21}),
+		},
+		"more than the declared": {
+			textFrame(t, v2.FileTransferFrame{Type: frameMeta, Size: 2}),
+			{websocket.BinaryMessage, []byte("abc")},
+		},
+		"agent: permission denied": {
+			textFrame(t, v2.FileTransferFrame{Type: frameResult, Error: "permission denied"}),
+		},
+	}
+	for want, frames := range cases {
+		tr := &Transfer{ID: "bad", Direction: DirectionDownload}
+		err := receiveFile(tr, &fakeConn{incoming: frames}, 1<<20)
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q error, got %v", want, err)
+		}
+		if _, err := os.Stat(filepath.Join(Dir, "bad.part")); !os.IsNotExist(err) {
+			t.Fatalf("partial file was not removed after %q", want)
+		}
+		if _, err := os.Stat(filepath.Join(Dir, "bad")); !os.IsNotExist(err) {
+			t.Fatalf("failed transfer stored a file after %q", want)
+		}
+	}
+}
+
+func TestSendFileStreamsChunksAndWaitsForResult(t *testing.T) {
+	Dir = t.TempDir()
+	content := bytes.Repeat([]byte{0, 1, 2, 3}, ChunkSize/2)
+	staged := filepath.Join(Dir, "staged-file")
+	if err := os.WriteFile(staged, content, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	sum := checksum(content)
+
+	tr := &Transfer{Direction: DirectionUpload, file: staged, Size: int64(len(content)), SHA256: sum}
+	conn := &fakeConn{incoming: []frame{textFrame(t, v2.FileTransferFrame{Type: frameResult, OK: true, SHA256: sum})}}
+	if err := sendFile(tr, conn); err != nil {
+		t.Fatalf("sendFile: %v", err)
+	}
+	var received []byte
+	for _, f := range conn.written[:len(conn.written)-1] {
+		if f.messageType != websocket.BinaryMessage || len(f.data) > ChunkSize {
+			t.Fatalf("unexpected content frame of %d bytes", len(f.data))
+		}
+		received = append(received, f.data...)
+	}
+	if !bytes.Equal(received, content) || tr.Transferred != int64(len(content)) {
+		t.Fatalf("content was not streamed intact")
+	}
+	var end v2.FileTransferFrame
+	if err := json.Unmarshal(conn.written[len(conn.written)-1].data, &end); err != nil || end.Type != frameEnd || end.SHA256 != sum {
+		t.Fatalf("unexpected end frame: %+v (%v)", end, err)
+	}
+
+	tr = &Transfer{Direction: DirectionUpload, file: staged, Size: int64(len(content)), SHA256: sum}
+	conn = &fakeConn{incoming: []frame{textFrame(t, v2.FileTransferFrame{Type: frameResult, Error: "file exists"})}}
+	if err := sendFile(tr, conn); err == nil || !strings.Contains(err.Error(), "file exists") {
+		t.Fatalf("expected agent failure, got %v", err)
+	}
+}
+
+func TestNormalizePath(t *testing.T) {
+	for _, p := range []string{"/etc/app.conf", `C:\app\app.conf`, "d:/data/x"} {
+		if _, err := normalizePath(p); err != nil {
+			t.Fatalf("%q rejected: %v", p, err)
+		}
+	}
+	for _, p := range []string{"", "relative/file", "app.conf", "/etc/\x00x"} {
+		if _, err := normalizePath(p); !invalid.Is(err) {
+			t.Fatalf("%q accepted", p)
+		}
+	}
+	if fileName(`C:\app\app.conf`) != "app.conf" || fileName("/var/log/syslog") != "syslog" {
+		t.Fatal("fileName does not handle agent path separators")
+	}
+}
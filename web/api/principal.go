@@ -3,7 +3,7 @@ package api
 // principal.go
 // 统一身份识别。IdentifyPrincipal 是全局唯一的主体识别入口,替代此前散落在
 // IdentityMiddleware / transport.detectPermissionGroup / transport.buildContextMeta
-// 三处的重复逻辑。识别优先级:API Key > Session(用户) > Client Token(agent) > 匿名。
+// 三处的重复逻辑。识别优先级:API Key > Federation Key > Session(用户) > Client Token(agent) > 匿名。
 
 import (
 	"github.com/gin-gonic/gin"
@@ -15,12 +15,16 @@ import (
 const principalContextKey = "principal"
 
 // IdentifyPrincipal 识别当前请求的调用主体。不写入任何状态,可安全多次调用。
-// 识别优先级与历史 IdentityMiddleware 一致:API Key > Session > Client Token > 匿名。
+// 识别优先级与历史 IdentityMiddleware 一致:API Key > Session > Client Token > 匿名,
+// 联邦只读密钥紧随 API Key 之后。
 func IdentifyPrincipal(c *gin.Context) *rpc.Principal {
 	// 1. API Key(Authorization: Bearer <key>)
 	if isApiKeyValid(c.GetHeader("Authorization")) {
 		return rpc.NewAPIKeyPrincipal()
 	}
+	if isFederationKeyValid(c.GetHeader("Authorization")) {
+		return rpc.NewFederationPrincipal()
+	}
 
 	// 2. Session(管理员用户)
 	if session, err := c.Cookie("session_token"); err == nil && session != "" {
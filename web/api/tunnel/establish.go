@@ -0,0 +1,102 @@
+package tunnel
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/web/api"
+	"github.com/komari-monitor/komari/web/connection"
+)
+
+// EstablishConnection 处理 Agent 收到 agent.tunnel 后发起的 WebSocket 连接。
+// 只有隧道的目标客户端可以连接，且每条隧道只接受一次连接。
+func EstablishConnection(c *gin.Context) {
+	id := c.Query("id")
+	clientUUID := c.GetString("client_uuid")
+	if !api.IsWebSocketUpgrade(c) {
+		c.JSON(http.StatusBadRequest, gin.H{"status": "error", "error": "Require WebSocket upgrade"})
+		return
+	}
+	mu.Lock()
+	t, exists := tunnels[id]
+	if !exists || clientUUID == "" || t.ClientUUID != clientUUID || t.Status != StatusConnecting {
+		mu.Unlock()
+		c.JSON(http.StatusNotFound, gin.H{"status": "error", "error": "Tunnel not found"})
+		return
+	}
+	t.Status = StatusOpen
+	mu.Unlock()
+
+	conn, err := api.UpgradeSafeConn(c)
+	if err != nil {
+		closeTunnel(t, "agent connection failed")
+		return
+	}
+	mu.Lock()
+	t.agent = conn
+	t.LastActive = time.Now().UTC()
+	closed := t.Status == StatusClosed
+	mu.Unlock()
+	if closed {
+		_ = conn.Close()
+		return
+	}
+	close(t.ready)
+	go func() {
+		_ = serve(t, conn)
+		closeTunnel(t, "agent disconnected")
+	}()
+}
+
+// Connect 把管理员的 WebSocket 连接作为隧道上的一个 TCP 连接，二进制帧即字节流。
+// 用于无法访问服务端本机监听端口的场景。
+func Connect(c *gin.Context) {
+	t, ok := lookupOpen(c.Param("id"))
+	if !ok {
+		api.RespondError(c, http.StatusNotFound, "Tunnel not found")
+		return
+	}
+	if !api.IsWebSocketUpgrade(c) {
+		api.RespondError(c, http.StatusBadRequest, "Require WebSocket upgrade")
+		return
+	}
+	conn, err := api.UpgradeSafeConn(c)
+	if err != nil {
+		return
+	}
+	auditlog.Log(c.ClientIP(), c.GetString("uuid"), "websocket connected, tunnel id:"+t.ID+", client:"+t.ClientUUID+", target:"+t.Target, "tunnel")
+	go attach(t, &wsStream{conn: conn})
+}
+
+// wsStream 把 WebSocket 连接适配为字节流。
+type wsStream struct {
+	conn    *connection.SafeConn
+	pending []byte
+}
+
+func (w *wsStream) Read(p []byte) (int, error) {
+	for len(w.pending) == 0 {
+		_, data, err := w.conn.ReadMessage()
+		if err != nil {
+			return 0, err
+		}
+		w.pending = data
+	}
+	n := copy(p, w.pending)
+	w.pending = w.pending[n:]
+	return n, nil
+}
+
+func (w *wsStream) Write(p []byte) (int, error) {
+	if err := w.conn.WriteMessage(websocket.BinaryMessage, p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (w *wsStream) Close() error {
+	return w.conn.Close()
+}
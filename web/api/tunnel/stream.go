@@ -0,0 +1,207 @@
+package tunnel
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+// 控制帧类型，见 v2.TunnelFrame。
+const (
+	frameOpen   = "open"
+	frameOpened = "opened"
+	frameClose  = "close"
+)
+
+// frameConn 是 Agent 隧道连接所需的 WebSocket 操作，便于在测试中替换。
+type frameConn interface {
+	ReadMessage() (int, []byte, error)
+	WriteMessage(messageType int, data []byte) error
+	Close() error
+}
+
+// stream 是隧道上的一个 TCP 连接（或一个管理员 WebSocket 连接）。
+type stream struct {
+	id       uint32
+	conn     io.ReadWriteCloser
+	opened   chan struct{} // Agent 已连接目标
+	out      chan []byte   // 待写入 conn 的数据
+	done     chan struct{}
+	openOnce sync.Once
+	once     sync.Once
+}
+
+func (s *stream) markOpened() {
+	s.openOnce.Do(func() { close(s.opened) })
+}
+
+func (s *stream) close() {
+	s.once.Do(func() {
+		close(s.done)
+		_ = s.conn.Close()
+	})
+}
+
+func writeControl(conn frameConn, frame v2.TunnelFrame) error {
+	data, err := json.Marshal(frame)
+	if err != nil {
+		return err
+	}
+	return conn.WriteMessage(websocket.TextMessage, data)
+}
+
+// attach 把一个本地连接接入隧道：等待 Agent 就绪，请求 Agent 连接目标，随后双向转发。
+func attach(t *Tunnel, conn io.ReadWriteCloser) {
+	select {
+	case <-t.ready:
+	case <-t.done:
+		_ = conn.Close()
+		return
+	}
+	s, err := openStream(t, conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+	select {
+	case <-s.opened:
+	case <-s.done:
+		return
+	}
+	buf := make([]byte, bufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if n > 0 {
+			frame := make([]byte, 4+n)
+			binary.BigEndian.PutUint32(frame, s.id)
+			copy(frame[4:], buf[:n])
+			if err := t.agent.WriteMessage(websocket.BinaryMessage, frame); err != nil {
+				closeTunnel(t, "agent disconnected")
+				return
+			}
+			mu.Lock()
+			t.BytesSent += int64(n)
+			t.LastActive = time.Now().UTC()
+			mu.Unlock()
+		}
+		if err != nil {
+			endStream(t, s, true)
+			return
+		}
+	}
+}
+
+func openStream(t *Tunnel, conn io.ReadWriteCloser) (*stream, error) {
+	mu.Lock()
+	if t.Status != StatusOpen {
+		mu.Unlock()
+		return nil, errors.New("tunnel is not open")
+	}
+	if len(t.streams) >= maxStreams {
+		mu.Unlock()
+		return nil, errors.New("too many connections")
+	}
+	t.nextStream++
+	s := &stream{
+		id:     t.nextStream,
+		conn:   conn,
+		opened: make(chan struct{}),
+		out:    make(chan []byte, streamQueue),
+		done:   make(chan struct{}),
+	}
+	t.streams[s.id] = s
+	t.Streams = len(t.streams)
+	t.TotalStreams++
+	t.LastActive = time.Now().UTC()
+	mu.Unlock()
+
+	go writeLoop(t, s)
+	if err := writeControl(t.agent, v2.TunnelFrame{Type: frameOpen, Stream: s.id}); err != nil {
+		closeTunnel(t, "agent disconnected")
+		return nil, err
+	}
+	return s, nil
+}
+
+// endStream 结束一个连接；notify 为 true 时通知 Agent 关闭对应的目标连接。
+func endStream(t *Tunnel, s *stream, notify bool) {
+	mu.Lock()
+	removed := t.streams[s.id] == s
+	if removed {
+		delete(t.streams, s.id)
+		t.Streams = len(t.streams)
+	}
+	open := t.Status == StatusOpen
+	mu.Unlock()
+	s.close()
+	if removed && notify && open {
+		_ = writeControl(t.agent, v2.TunnelFrame{Type: frameClose, Stream: s.id})
+	}
+}
+
+// writeLoop 把 Agent 发来的数据写入本地连接。每个连接单独写入，
+// 一个慢连接只会在其队列写满后阻塞隧道。
+func writeLoop(t *Tunnel, s *stream) {
+	for {
+		select {
+		case data := <-s.out:
+			if _, err := s.conn.Write(data); err != nil {
+				endStream(t, s, true)
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// serve 读取 Agent 连接上的帧并分发给对应的连接，直到连接断开。
+func serve(t *Tunnel, conn frameConn) error {
+	for {
+		messageType, data, err := conn.ReadMessage()
+		if err != nil {
+			return err
+		}
+		if messageType == websocket.BinaryMessage {
+			if len(data) < 4 {
+				return errors.New("malformed data frame")
+			}
+			id, payload := binary.BigEndian.Uint32(data), data[4:]
+			mu.Lock()
+			s := t.streams[id]
+			t.BytesReceived += int64(len(payload))
+			t.LastActive = time.Now().UTC()
+			mu.Unlock()
+			if s == nil || len(payload) == 0 {
+				continue
+			}
+			select {
+			case s.out <- payload:
+			case <-s.done:
+			}
+			continue
+		}
+		var frame v2.TunnelFrame
+		if err := json.Unmarshal(data, &frame); err != nil {
+			return errors.New("malformed control frame")
+		}
+		mu.Lock()
+		s := t.streams[frame.Stream]
+		mu.Unlock()
+		if s == nil {
+			continue
+		}
+		switch frame.Type {
+		case frameOpened:
+			s.markOpened()
+		case frameClose:
+			endStream(t, s, false)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+// Package tunnel 通过 Agent 转发 TCP 连接，用于访问只监听在节点本机的服务。
+//
+// 管理员用 admin:openTunnel 指定客户端与目标 host:port（以 Agent 的视角解析），服务端
+// 在本机回环地址上监听一个端口；也可以通过 /api/admin/tunnels/:id/connect 的 WebSocket
+// 连接隧道。Agent 收到 agent.tunnel 事件后以一条 WebSocket 连接 /api/clients/tunnel，
+// 隧道上的所有 TCP 连接都复用这条连接（帧格式见 v2.TunnelFrame）。
+//
+// 隧道在 idle timeout 内没有数据时自动关闭；关闭时在审计日志中记录双向字节数。
+// 隧道状态只保存在内存中，服务端重启后全部失效。
+package tunnel
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+	"github.com/komari-monitor/komari/utils"
+	"github.com/komari-monitor/komari/web/agent"
+)
+
+// 隧道状态
+const (
+	StatusConnecting = "connecting" // 等待 Agent 连接
+	StatusOpen       = "open"
+	StatusClosed     = "closed"
+)
+
+const (
+	// DefaultIdleTimeout 与 MaxIdleTimeout 限制隧道在没有数据时保持打开的时间。
+	DefaultIdleTimeout = 10 * time.Minute
+	MinIdleTimeout     = 30 * time.Second
+	MaxIdleTimeout     = 24 * time.Hour
+
+	maxTunnels     = 32
+	maxStreams     = 64
+	connectTimeout = time.Minute
+	retention      = time.Hour
+	bufferSize     = 32 << 10
+	streamQueue    = 64
+)
+
+// ErrNotFound 表示隧道不存在或已被清理。
+var ErrNotFound = errors.New("tunnel not found")
+
+// Requester 是打开隧道的管理员，用于审计。
+type Requester struct {
+	UserUUID string
+	IP       string
+}
+
+// Tunnel 是一条经由 Agent 的 TCP 转发隧道。
+type Tunnel struct {
+	ID            string     `json:"id"`
+	ClientUUID    string     `json:"client_uuid"`
+	Target        string     `json:"target"` // Agent 视角的 host:port
+	Listen        string     `json:"listen"` // 服务端监听地址
+	Status        string     `json:"status"`
+	Error         string     `json:"error,omitempty"` // 关闭原因
+	IdleTimeout   int        `json:"idle_timeout"`    // 秒
+	BytesSent     int64      `json:"bytes_sent"`      // 发往目标的字节数
+	BytesReceived int64      `json:"bytes_received"`  // 从目标收到的字节数
+	Streams       int        `json:"streams"`         // 当前连接数
+	TotalStreams  int        `json:"total_streams"`
+	UserUUID      string     `json:"user_uuid"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastActive    time.Time  `json:"last_active"`
+	ClosedAt      *time.Time `json:"closed_at,omitempty"`
+
+	requesterIP string
+	idle        time.Duration
+	listener    net.Listener
+	agent       frameConn
+	ready       chan struct{} // Agent 连接后关闭
+	done        chan struct{} // 隧道关闭后关闭
+	streams     map[uint32]*stream
+	nextStream  uint32
+}
+
+var (
+	mu      sync.Mutex
+	tunnels = map[string]*Tunnel{}
+)
+
+// validateTarget 校验 host:port 形式的目标地址。
+func validateTarget(target string) error {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil || host == "" {
+		return invalid.Errorf("target must be host:port")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		return invalid.Errorf("target port must be between 1 and 65535")
+	}
+	return nil
+}
+
+// validateListen 校验服务端监听地址。隧道可以访问节点的内部服务，因此只允许监听回环地址；
+// 需要从其他机器访问时使用 WebSocket 端点。
+func validateListen(listen string) error {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return invalid.Errorf("listen must be host:port")
+	}
+	if n, err := strconv.Atoi(port); err != nil || n < 0 || n > 65535 {
+		return invalid.Errorf("listen port must be between 0 and 65535")
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return invalid.Errorf("listen must be a loopback address, use the WebSocket endpoint for remote access")
+	}
+	return nil
+}
+
+// Open 打开一条到客户端 target 的隧道。listen 为空时监听 127.0.0.1 上的随机端口，
+// idleTimeout 为 0 时使用 DefaultIdleTimeout。
+func Open(by Requester, clientUUID, target, listen string, idleTimeout time.Duration) (*Tunnel, error) {
+	if err := validateTarget(target); err != nil {
+		return nil, err
+	}
+	if listen == "" {
+		listen = "127.0.0.1:0"
+	}
+	if err := validateListen(listen); err != nil {
+		return nil, err
+	}
+	if idleTimeout == 0 {
+		idleTimeout = DefaultIdleTimeout
+	}
+	if idleTimeout < MinIdleTimeout || idleTimeout > MaxIdleTimeout {
+		return nil, invalid.Errorf("idle_timeout must be between %d and %d seconds", int(MinIdleTimeout.Seconds()), int(MaxIdleTimeout.Seconds()))
+	}
+	if _, err := clients.GetClientByUUID(clientUUID); err != nil {
+		return nil, invalid.Errorf("client %s not found", clientUUID)
+	}
+	if agent.GetConnectedClients()[clientUUID] == nil && !agent.IsV2Client(clientUUID) {
+		return nil, invalid.Errorf("client offline")
+	}
+	if !agent.IsV2Client(clientUUID) {
+		return nil, invalid.Errorf("agent does not support tunnels")
+	}
+
+	mu.Lock()
+	active := 0
+	for _, t := range tunnels {
+		if t.Status != StatusClosed {
+			active++
+		}
+	}
+	mu.Unlock()
+	if active >= maxTunnels {
+		return nil, invalid.Errorf("at most %d tunnels can be open at the same time", maxTunnels)
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, invalid.Errorf("listen on %s: %v", listen, err)
+	}
+
+	now := time.Now().UTC()
+	t := newTunnel(by, clientUUID, target, idleTimeout, now)
+	t.listener = ln
+	t.Listen = ln.Addr().String()
+	mu.Lock()
+	tunnels[t.ID] = t
+	mu.Unlock()
+	auditlog.Log(t.requesterIP, t.UserUUID, fmt.Sprintf("opened, tunnel id:%s, client:%s, target:%s, listen:%s", t.ID, t.ClientUUID, t.Target, t.Listen), "tunnel")
+
+	go acceptLoop(t)
+	go watchIdle(t)
+	if !agent.DispatchV2Event(clientUUID, v2.MethodAgentTunnel, v2.TunnelParams{TunnelID: t.ID, Target: t.Target}) {
+		closeTunnel(t, "client offline")
+	} else {
+		time.AfterFunc(connectTimeout, func() {
+			mu.Lock()
+			connecting := t.Status == StatusConnecting
+			mu.Unlock()
+			if connecting {
+				closeTunnel(t, "agent did not connect in time")
+			}
+		})
+	}
+	out := snapshot(t)
+	return &out, nil
+}
+
+func newTunnel(by Requester, clientUUID, target string, idle time.Duration, now time.Time) *Tunnel {
+	return &Tunnel{
+		ID:          utils.GenerateRandomString(32),
+		ClientUUID:  clientUUID,
+		Target:      target,
+		Status:      StatusConnecting,
+		IdleTimeout: int(idle.Seconds()),
+		UserUUID:    by.UserUUID,
+		CreatedAt:   now,
+		LastActive:  now,
+		requesterIP: by.IP,
+		idle:        idle,
+		ready:       make(chan struct{}),
+		done:        make(chan struct{}),
+		streams:     map[uint32]*stream{},
+	}
+}
+
+// Close 由管理员关闭隧道。
+func Close(id string) error {
+	mu.Lock()
+	t, ok := tunnels[id]
+	mu.Unlock()
+	if !ok {
+		return ErrNotFound
+	}
+	closeTunnel(t, "closed by admin")
+	return nil
+}
+
+// closeTunnel 关闭监听、Agent 连接与全部 TCP 连接，并记录字节数。重复调用无副作用。
+func closeTunnel(t *Tunnel, reason string) {
+	now := time.Now().UTC()
+	mu.Lock()
+	if t.Status == StatusClosed {
+		mu.Unlock()
+		return
+	}
+	t.Status = StatusClosed
+	t.Error = reason
+	t.ClosedAt = &now
+	close(t.done)
+	streams := make([]*stream, 0, len(t.streams))
+	for _, s := range t.streams {
+		streams = append(streams, s)
+	}
+	t.streams = map[uint32]*stream{}
+	t.Streams = 0
+	closed := *t
+	mu.Unlock()
+
+	for _, s := range streams {
+		s.close()
+	}
+	if closed.listener != nil {
+		_ = closed.listener.Close()
+	}
+	if closed.agent != nil {
+		_ = closed.agent.Close()
+	}
+	auditlog.Log(closed.requesterIP, closed.UserUUID, fmt.Sprintf("closed, tunnel id:%s, client:%s, target:%s, reason:%s, connections:%d, sent:%d, received:%d, duration:%s",
+		closed.ID, closed.ClientUUID, closed.Target, reason, closed.TotalStreams, closed.BytesSent, closed.BytesReceived, now.Sub(closed.CreatedAt).Round(time.Second)), "tunnel")
+	time.AfterFunc(retention, func() {
+		mu.Lock()
+		delete(tunnels, t.ID)
+		mu.Unlock()
+	})
+}
+
+// watchIdle 在隧道超过 idle timeout 没有数据或新连接时关闭隧道。
+func watchIdle(t *Tunnel) {
+	interval := min(t.idle/4, 30*time.Second)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-t.done:
+			return
+		case now := <-ticker.C:
+			mu.Lock()
+			idle := now.Sub(t.LastActive) >= t.idle
+			mu.Unlock()
+			if idle {
+				closeTunnel(t, "idle timeout")
+				return
+			}
+		}
+	}
+}
+
+func acceptLoop(t *Tunnel) {
+	for {
+		conn, err := t.listener.Accept()
+		if err != nil {
+			return
+		}
+		go attach(t, conn)
+	}
+}
+
+func snapshot(t *Tunnel) Tunnel {
+	mu.Lock()
+	defer mu.Unlock()
+	return *t
+}
+
+// List 按创建时间倒序列出隧道；clientUUID 为空时返回全部客户端。
+func List(clientUUID string) []Tunnel {
+	mu.Lock()
+	out := make([]Tunnel, 0, len(tunnels))
+	for _, t := range tunnels {
+		if clientUUID == "" || t.ClientUUID == clientUUID {
+			out = append(out, *t)
+		}
+	}
+	mu.Unlock()
+	slices.SortFunc(out, func(a, b Tunnel) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return out
+}
+
+// lookupOpen 返回未关闭的隧道。
+func lookupOpen(id string) (*Tunnel, bool) {
+	mu.Lock()
+	defer mu.Unlock()
+	t, ok := tunnels[id]
+	if !ok || t.Status == StatusClosed {
+		return nil, false
+	}
+	return t, true
+}
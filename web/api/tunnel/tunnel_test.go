@@ -0,0 +1,238 @@
+package tunnel
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/pkg/invalid"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
+)
+
+type message struct {
+	messageType int
+	data        []byte
+}
+
+// fakeAgent 是服务端看到的 Agent 连接：Agent 对每个 open 回复 opened，
+// 并把收到的数据转为大写后原路返回。
+type fakeAgent struct {
+	toAgent   chan message
+	fromAgent chan message
+	closeOnce sync.Once
+	closed    chan struct{}
+
+	mu     sync.Mutex
+	closes []uint32
+}
+
+func newFakeAgent() *fakeAgent {
+	a := &fakeAgent{toAgent: make(chan message, 16), fromAgent: make(chan message, 16), closed: make(chan struct{})}
+	go a.run()
+	return a
+}
+
+func (a *fakeAgent) run() {
+	for {
+		select {
+		case m := <-a.toAgent:
+			if m.messageType == websocket.BinaryMessage {
+				a.fromAgent <- message{websocket.BinaryMessage, append(m.data[:4:4], bytes.ToUpper(m.data[4:])...)}
+				continue
+			}
+			var frame v2.TunnelFrame
+			_ = json.Unmarshal(m.data, &frame)
+			switch frame.Type {
+			case frameOpen:
+				data, _ := json.Marshal(v2.TunnelFrame{Type: frameOpened, Stream: frame.Stream})
+				a.fromAgent <- message{websocket.TextMessage, data}
+			case frameClose:
+				a.mu.Lock()
+				a.closes = append(a.closes, frame.Stream)
+				a.mu.Unlock()
+			}
+		case <-a.closed:
+			return
+		}
+	}
+}
+
+func (a *fakeAgent) ReadMessage() (int, []byte, error) {
+	select {
+	case m := <-a.fromAgent:
+		return m.messageType, m.data, nil
+	case <-a.closed:
+		return 0, nil, errors.New("closed")
+	}
+}
+
+func (a *fakeAgent) WriteMessage(messageType int, data []byte) error {
+	select {
+	case a.toAgent <- message{messageType, append([]byte(nil), data...)}:
+		return nil
+	case <-a.closed:
+		return errors.New("closed")
+	}
+}
+
+func (a *fakeAgent) Close() error {
+	a.closeOnce.Do(func() { close(a.closed) })
+	return nil
+}
+
+func (a *fakeAgent) closedStreams() []uint32 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]uint32(nil), a.closes...)
+}
+
+// startTunnel 模拟 Open 与 EstablishConnection，但不经过事件下发与 WebSocket 升级。
+func startTunnel(t *testing.T, idle time.Duration) (*Tunnel, *fakeAgent) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tn := newTunnel(Requester{UserUUID: "admin"}, "node", "127.0.0.1:5432", idle, time.Now().UTC())
+	tn.listener = ln
+	tn.Listen = ln.Addr().String()
+	a := newFakeAgent()
+	tn.agent = a
+	tn.Status = StatusOpen
+	mu.Lock()
+	tunnels[tn.ID] = tn
+	mu.Unlock()
+	close(tn.ready)
+	go acceptLoop(tn)
+	go watchIdle(tn)
+	go func() {
+		_ = serve(tn, a)
+		closeTunnel(tn, "agent disconnected")
+	}()
+	return tn, a
+}
+
+func waitFor(t *testing.T, what string, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatalf("timed out waiting for %s", what)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestTunnelRelaysAndCountsBytes(t *testing.T) {
+	dbtest.Open(t, "tunnel")
+	tn, a := startTunnel(t, time.Minute)
+
+	conn, err := net.Dial("tcp", tn.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatal(err)
+	}
+	reply := make([]byte, 4)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := io.ReadFull(conn, reply); err != nil || string(reply) != "PING" {
+		t.Fatalf("reply = %q, %v", reply, err)
+	}
+	got := snapshot(tn)
+	if got.BytesSent != 4 || got.BytesReceived != 4 || got.Streams != 1 || got.TotalStreams != 1 {
+		t.Fatalf("unexpected accounting: %+v", got)
+	}
+
+	_ = conn.Close()
+	waitFor(t, "agent to be told the stream closed", func() bool { return len(a.closedStreams()) == 1 })
+	if snapshot(tn).Streams != 0 {
+		t.Fatal("closed stream is still counted")
+	}
+
+	if err := Close(tn.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := snapshot(tn); got.Status != StatusClosed || got.Error != "closed by admin" {
+		t.Fatalf("unexpected state after close: %+v", got)
+	}
+	if c, err := net.Dial("tcp", tn.Listen); err == nil {
+		_ = c.Close()
+		t.Fatal("listener is still accepting after close")
+	}
+	if err := Close("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("close missing tunnel: %v", err)
+	}
+}
+
+func TestTunnelClosesWhenAgentSendsClose(t *testing.T) {
+	dbtest.Open(t, "tunnel")
+	tn, a := startTunnel(t, time.Minute)
+	conn, err := net.Dial("tcp", tn.Listen)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	waitFor(t, "stream to open", func() bool { return snapshot(tn).Streams == 1 })
+
+	frame, _ := json.Marshal(v2.TunnelFrame{Type: frameClose, Stream: 1, Error: "connection refused"})
+	a.fromAgent <- message{websocket.TextMessage, frame}
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	if _, err := conn.Read(make([]byte, 1)); err == nil {
+		t.Fatal("local connection was not closed")
+	}
+	waitFor(t, "stream to be removed", func() bool { return snapshot(tn).Streams == 0 })
+	if len(a.closedStreams()) != 0 {
+		t.Fatal("server echoed close for a stream the agent closed")
+	}
+
+	// 数据帧中未知的 stream 被忽略，格式错误的帧断开隧道。
+	unknown := make([]byte, 5)
+	binary.BigEndian.PutUint32(unknown, 99)
+	a.fromAgent <- message{websocket.BinaryMessage, unknown}
+	a.fromAgent <- message{websocket.BinaryMessage, []byte{1}}
+	waitFor(t, "tunnel to close", func() bool { return snapshot(tn).Status == StatusClosed })
+	if got := snapshot(tn); got.Error != "agent disconnected" {
+		t.Fatalf("close reason = %q", got.Error)
+	}
+}
+
+func TestTunnelIdleTimeout(t *testing.T) {
+	dbtest.Open(t, "tunnel")
+	tn, _ := startTunnel(t, 100*time.Millisecond)
+	waitFor(t, "idle tunnel to close", func() bool { return snapshot(tn).Status == StatusClosed })
+	if got := snapshot(tn); got.Error != "idle timeout" {
+		t.Fatalf("close reason = %q", got.Error)
+	}
+}
+
+func TestValidateAddresses(t *testing.T) {
+	for _, target := range []string{"127.0.0.1:5432", "db.internal:3306", "[::1]:8080"} {
+		if err := validateTarget(target); err != nil {
+			t.Fatalf("%q rejected: %v", target, err)
+		}
+	}
+	for _, target := range []string{"", "localhost", ":80", "host:0", "host:70000"} {
+		if err := validateTarget(target); !invalid.Is(err) {
+			t.Fatalf("%q accepted", target)
+		}
+	}
+	for _, listen := range []string{"127.0.0.1:0", "localhost:15432", "[::1]:9000"} {
+		if err := validateListen(listen); err != nil {
+			t.Fatalf("%q rejected: %v", listen, err)
+		}
+	}
+	for _, listen := range []string{"0.0.0.0:15432", ":15432", "192.168.1.2:80", "example.com:80"} {
+		if err := validateListen(listen); !invalid.Is(err) {
+			t.Fatalf("%q accepted", listen)
+		}
+	}
+}
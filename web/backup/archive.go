@@ -0,0 +1,241 @@
+package backup
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/cmd/flags"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+// ArchiveOptions controls optional content of a generated backup archive.
+type ArchiveOptions struct {
+	// IncludeMetrics adds a consistent snapshot of the SQLite metric store as
+	// metrics.db. External metric databases are skipped and must be backed up
+	// with their own tooling.
+	IncludeMetrics bool
+	// Passphrase, when non-empty, encrypts the archive (see crypt.go) and
+	// appends EncryptedExt to the published file name.
+	Passphrase string
+}
+
+// CreateArchive 将白名单文件、主库快照以及可选的 metrics 快照打包为 destZip。
+// 打包先写入临时目录，成功后才原子发布，失败时不会留下半成品。
+// 返回实际发布的路径：加密时为 destZip + ".enc"。
+func CreateArchive(ctx context.Context, destZip string, opts ArchiveOptions) (string, error) {
+	tempDir, err := os.MkdirTemp("", "komari-backup-*")
+	if err != nil {
+		return "", fmt.Errorf("create temporary directory: %w", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	// 内容隔离到 content/ 子目录，避免输出 zip 被打包进去
+	contentDir := filepath.Join(tempDir, "content")
+	if err := os.MkdirAll(contentDir, 0o755); err != nil {
+		return "", fmt.Errorf("create content directory: %w", err)
+	}
+	if err := copyWhitelistedFiles(contentDir); err != nil {
+		return "", fmt.Errorf("copy data files: %w", err)
+	}
+
+	// MySQL/PostgreSQL 主库导出为同结构的 SQLite 文件，恢复时在启动阶段导回外部库。
+	destDB := filepath.Join(contentDir, "komari.db")
+	if flags.IsSQLite() {
+		if err := backupSQLiteTo(ctx, destDB); err != nil {
+			return "", fmt.Errorf("back up sqlite database: %w", err)
+		}
+	} else if _, err := dbcore.ExportSQLite(ctx, destDB); err != nil {
+		return "", fmt.Errorf("export %s database: %w", flags.DatabaseType, err)
+	}
+
+	if opts.IncludeMetrics {
+		err := metricstore.SnapshotSQLite(ctx, filepath.Join(contentDir, "metrics.db"))
+		switch {
+		case errors.Is(err, metricstore.ErrSnapshotUnsupported), errors.Is(err, metricstore.ErrStoreNotInitialized):
+			logger.Warnf("backup", "Metric store snapshot skipped: %v", err)
+		case err != nil:
+			return "", fmt.Errorf("snapshot metric store: %w", err)
+		}
+	}
+
+	tempZipPath := filepath.Join(tempDir, "output.zip")
+	if err := writeArchive(tempZipPath, contentDir); err != nil {
+		return "", err
+	}
+	if opts.Passphrase != "" {
+		encryptedPath := tempZipPath + EncryptedExt
+		if err := EncryptFile(tempZipPath, encryptedPath, opts.Passphrase); err != nil {
+			return "", fmt.Errorf("encrypt backup archive: %w", err)
+		}
+		tempZipPath = encryptedPath
+		destZip += EncryptedExt
+	}
+	if err := publishFile(tempZipPath, destZip); err != nil {
+		return "", err
+	}
+	return destZip, nil
+}
+
+// writeArchive 将 contentDir 与备份标记文件写入 zipPath。
+func writeArchive(zipPath, contentDir string) error {
+	out, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("create temp zip: %w", err)
+	}
+	zipWriter := zip.NewWriter(out)
+	if err := walkDirToZip(zipWriter, contentDir); err != nil {
+		zipWriter.Close()
+		out.Close()
+		return fmt.Errorf("archive temp folder: %w", err)
+	}
+	if err := writeBackupMarkup(zipWriter); err != nil {
+		zipWriter.Close()
+		out.Close()
+		return fmt.Errorf("write backup markup: %w", err)
+	}
+	if err := zipWriter.Close(); err != nil {
+		out.Close()
+		return fmt.Errorf("finalize zip: %w", err)
+	}
+	return out.Close()
+}
+
+// publishFile 先复制到目标目录下的临时文件再重命名，保证目标路径上只会出现完整文件。
+func publishFile(src, dest string) error {
+	if err := os.MkdirAll(filepath.Dir(dest), 0o755); err != nil {
+		return fmt.Errorf("create backup directory: %w", err)
+	}
+	temp, err := os.CreateTemp(filepath.Dir(dest), ".backup-*.tmp")
+	if err != nil {
+		return fmt.Errorf("create archive temp file: %w", err)
+	}
+	tempPath := temp.Name()
+	if err := temp.Close(); err != nil {
+		os.Remove(tempPath)
+		return fmt.Errorf("close archive temp file: %w", err)
+	}
+	defer os.Remove(tempPath)
+	if err := copyFile(src, tempPath); err != nil {
+		return fmt.Errorf("archive backup: %w", err)
+	}
+	if err := os.Rename(tempPath, dest); err != nil {
+		return fmt.Errorf("publish backup archive: %w", err)
+	}
+	return nil
+}
+
+// copyFile 复制单个文件到目标路径（会确保父目录存在）
+func copyFile(srcPath, destPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directory: %v", err)
+	}
+
+	src, err := os.Open(srcPath)
+	if err != nil {
+		return fmt.Errorf("failed to open source file: %v", err)
+	}
+	defer src.Close()
+
+	dest, err := os.Create(destPath)
+	if err != nil {
+		return fmt.Errorf("failed to create destination file: %v", err)
+	}
+	defer dest.Close()
+
+	if _, err = io.Copy(dest, src); err != nil {
+		return fmt.Errorf("failed to copy file: %v", err)
+	}
+	return nil
+}
+
+// walkDirToZip 将 contentDir 的内容写入 zip writer。
+// 注意：contentDir 不应包含被 walk 的 zip 文件本身。
+func walkDirToZip(zipWriter *zip.Writer, contentDir string) error {
+	return filepath.Walk(contentDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(contentDir, p)
+		if err != nil {
+			return err
+		}
+		if rel == "." {
+			return nil
+		}
+		zipPath := filepath.ToSlash(rel)
+		if info.IsDir() {
+			_, err := zipWriter.CreateHeader(&zip.FileHeader{
+				Name:     zipPath + "/",
+				Method:   zip.Deflate,
+				Modified: info.ModTime(),
+			})
+			return err
+		}
+		f, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w, err := zipWriter.CreateHeader(&zip.FileHeader{
+			Name:     zipPath,
+			Method:   zip.Deflate,
+			Modified: info.ModTime(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(w, f)
+		return err
+	})
+}
+
+// writeBackupMarkup 追加备份标记文件到 zip。
+func writeBackupMarkup(zipWriter *zip.Writer) error {
+	now := time.Now().UTC()
+	markupContent := "此文件为 Komari 备份标记文件，请勿删除。\nThis is a Komari backup markup file, please do not delete.\n\n备份时间 / Backup Time: " + now.Format(time.RFC3339Nano)
+	markupWriter, err := zipWriter.CreateHeader(&zip.FileHeader{
+		Name:     "komari-backup-markup",
+		Method:   zip.Deflate,
+		Modified: now,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = markupWriter.Write([]byte(markupContent))
+	return err
+}
+
+// backupSQLiteTo 使用 SQLite VACUUM INTO 将当前数据库一致性备份到指定路径。
+// destDBPath 会先删除以防 SQLite 报 "output file already exists"。
+func backupSQLiteTo(ctx context.Context, destDBPath string) error {
+	if err := os.MkdirAll(filepath.Dir(destDBPath), 0o755); err != nil {
+		return fmt.Errorf("failed to create parent directory for db: %v", err)
+	}
+
+	// 确保目标文件不存在（VACUUM INTO 要求目标文件不存在）
+	_ = os.Remove(destDBPath)
+
+	db := dbcore.GetDBInstance()
+	sqlDB, err := db.DB()
+	if err != nil {
+		return fmt.Errorf("failed to get underlying database connection: %v", err)
+	}
+
+	// Windows 下 VACUUM INTO 传绝对路径时，统一使用正斜杠避免路径解析歧义
+	safePath := filepath.ToSlash(destDBPath)
+	safePath = strings.ReplaceAll(safePath, "'", "''")
+	vacuumSQL := fmt.Sprintf("VACUUM INTO '%s'", safePath)
+	if _, err = sqlDB.ExecContext(ctx, vacuumSQL); err != nil {
+		return fmt.Errorf("sqlite VACUUM INTO failed: %v", err)
+	}
+	return nil
+}
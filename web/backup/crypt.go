@@ -0,0 +1,366 @@
+package backup
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/komari-monitor/komari/internal/config"
+	"golang.org/x/crypto/argon2"
+)
+
+// EncryptionConfig 控制下载与定时备份是否加密。启用后归档以 .zip.enc 保存，
+// 恢复时需要同一口令；口令丢失将无法恢复备份。
+type EncryptionConfig struct {
+	Enabled    bool   `json:"backup_encryption_enabled" default:"false"`
+	Passphrase string `json:"backup_encryption_passphrase" default:""`
+}
+
+const (
+	EncryptionEnabledKey    = "backup_encryption_enabled"
+	EncryptionPassphraseKey = "backup_encryption_passphrase"
+
+	minPassphraseLength = 8
+)
+
+// EncryptionPassphrase 返回当前生效的备份口令；未启用加密时返回空字符串。
+func EncryptionPassphrase() (string, error) {
+	cfg, err := config.GetManyAs[EncryptionConfig]()
+	if err != nil {
+		return "", fmt.Errorf("load backup encryption config: %w", err)
+	}
+	if !cfg.Enabled {
+		return "", nil
+	}
+	if cfg.Passphrase == "" {
+		return "", fmt.Errorf("backup encryption is enabled but no passphrase is configured")
+	}
+	return cfg.Passphrase, nil
+}
+
+// ResolvePassphrase 返回用于恢复的口令：优先使用调用方提供的口令，
+// 否则回退到已保存的备份口令（即使当前未启用加密）。
+// 只能在数据库已初始化后调用，安装向导阶段须显式传入口令。
+func ResolvePassphrase(passphrase string) string {
+	if passphrase != "" {
+		return passphrase
+	}
+	cfg, err := config.GetManyAs[EncryptionConfig]()
+	if err != nil {
+		return ""
+	}
+	return cfg.Passphrase
+}
+
+// ValidateEncryptionSettings 在设置落库前校验本次变更中的加密配置：
+// 启用加密时（含已启用再修改口令）口令不得短于 8 个字符。
+func ValidateEncryptionSettings(cfg map[string]any) error {
+	_, touchedEnabled := cfg[EncryptionEnabledKey]
+	_, touchedPassphrase := cfg[EncryptionPassphraseKey]
+	if !touchedEnabled && !touchedPassphrase {
+		return nil
+	}
+	current, err := config.GetManyAs[EncryptionConfig]()
+	if err != nil {
+		return fmt.Errorf("load backup encryption config: %w", err)
+	}
+	enabled, passphrase := current.Enabled, current.Passphrase
+	if touchedEnabled {
+		v, ok := cfg[EncryptionEnabledKey].(bool)
+		if !ok {
+			return fmt.Errorf("%s must be a boolean", EncryptionEnabledKey)
+		}
+		enabled = v
+	}
+	if touchedPassphrase {
+		v, ok := cfg[EncryptionPassphraseKey].(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", EncryptionPassphraseKey)
+		}
+		passphrase = v
+	}
+	if enabled && len([]rune(strings.TrimSpace(passphrase))) < minPassphraseLength {
+		return fmt.Errorf("%s must be at least %d characters when encryption is enabled", EncryptionPassphraseKey, minPassphraseLength)
+	}
+	return nil
+}
+
+// 加密归档格式（所有整数为大端）：
+//
+//	magic "KMRBKENC" | version u8 | argon2 time u32 | argon2 memory(KiB) u32 | argon2 threads u8 |
+//	salt [16] | nonce prefix [7] | chunk size u32 | key check [16] | chunks...
+//
+// 密钥由 Argon2id 从口令派生。明文按 chunk size 分块，每块使用 AES-256-GCM 加密，
+// nonce = prefix || 块序号 u32 || 末块标志 u8，整个头部作为附加认证数据，
+// 因此参数篡改、块重排与截断都会被检测到。加密时向后探测一个字节，把标志 1 放在最后一个
+// 明文块上（可能不足 chunk size）；只有明文为空时才写出一个标志为 1 的空块。解密端同样按
+// 块在流中是否为最后一块推断标志，因此只有最后一块的标志为 1，在块边界截断也无法通过认证。
+// key check 是 HMAC-SHA256(key, "komari-backup-key-check") 的前 16 字节，
+// 用于在解密前区分口令错误与文件损坏。
+const (
+	encryptedMagic   = "KMRBKENC"
+	encryptedVersion = 1
+	encryptedHeader  = 8 + 1 + 4 + 4 + 1 + 16 + 7 + 4 + 16
+	encryptChunkSize = 1 << 20
+
+	argonTime    = 3
+	argonMemory  = 64 * 1024 // KiB
+	argonThreads = 4
+
+	// 解密时接受的参数上限，防止恶意头部耗尽内存或 CPU。
+	maxArgonTime   = 16
+	maxArgonMemory = 1 << 20 // KiB, 1 GiB
+	maxChunkSize   = 16 << 20
+
+	// EncryptedExt 是加密归档追加的扩展名（backup-*.zip.enc）。
+	EncryptedExt = ".enc"
+)
+
+var (
+	ErrWrongPassphrase    = errors.New("wrong passphrase for encrypted backup")
+	ErrPassphraseRequired = errors.New("backup is encrypted; a passphrase is required")
+	ErrCorruptArchive     = errors.New("encrypted backup is corrupted or truncated")
+)
+
+type encryptionHeader struct {
+	raw        []byte
+	time       uint32
+	memory     uint32
+	threads    uint8
+	salt       []byte
+	prefix     []byte
+	chunkSize  uint32
+	keyCheck   []byte
+	derivedKey []byte
+}
+
+func deriveKey(passphrase string, h *encryptionHeader) {
+	h.derivedKey = argon2.IDKey([]byte(passphrase), h.salt, h.time, h.memory, h.threads, 32)
+}
+
+func keyCheck(key []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte("komari-backup-key-check"))
+	return mac.Sum(nil)[:16]
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[7:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptStream 以口令加密 r 的全部内容写入 w。
+func EncryptStream(w io.Writer, r io.Reader, passphrase string) error {
+	if passphrase == "" {
+		return ErrPassphraseRequired
+	}
+	h := &encryptionHeader{
+		time: argonTime, memory: argonMemory, threads: argonThreads,
+		salt: make([]byte, 16), prefix: make([]byte, 7), chunkSize: encryptChunkSize,
+	}
+	if _, err := rand.Read(h.salt); err != nil {
+		return err
+	}
+	if _, err := rand.Read(h.prefix); err != nil {
+		return err
+	}
+	deriveKey(passphrase, h)
+
+	var header bytes.Buffer
+	header.WriteString(encryptedMagic)
+	header.WriteByte(encryptedVersion)
+	binary.Write(&header, binary.BigEndian, h.time)
+	binary.Write(&header, binary.BigEndian, h.memory)
+	header.WriteByte(h.threads)
+	header.Write(h.salt)
+	header.Write(h.prefix)
+	binary.Write(&header, binary.BigEndian, h.chunkSize)
+	header.Write(keyCheck(h.derivedKey))
+	aad := header.Bytes()
+	if _, err := w.Write(aad); err != nil {
+		return err
+	}
+
+	aead, err := newGCM(h.derivedKey)
+	if err != nil {
+		return err
+	}
+	br := bufio.NewReaderSize(r, int(h.chunkSize))
+	plain := make([]byte, h.chunkSize)
+	sealed := make([]byte, 0, int(h.chunkSize)+aead.Overhead())
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, plain)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			// 恰好读满一块时探测是否还有后续数据，决定本块是否为末块。
+			if _, peekErr := br.Peek(1); peekErr == io.EOF {
+				last = true
+			}
+		}
+		if counter == ^uint32(0) && !last {
+			return fmt.Errorf("backup archive is too large to encrypt")
+		}
+		sealed = aead.Seal(sealed[:0], chunkNonce(h.prefix, counter, last), plain[:n], aad)
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// readEncryptionHeader 解析并校验头部，派生密钥并核对口令。
+func readEncryptionHeader(r io.Reader, passphrase string) (*encryptionHeader, error) {
+	raw := make([]byte, encryptedHeader)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, ErrCorruptArchive
+	}
+	if string(raw[:8]) != encryptedMagic {
+		return nil, fmt.Errorf("not an encrypted Komari backup")
+	}
+	if raw[8] != encryptedVersion {
+		return nil, fmt.Errorf("unsupported encrypted backup version %d", raw[8])
+	}
+	h := &encryptionHeader{
+		raw:       raw,
+		time:      binary.BigEndian.Uint32(raw[9:13]),
+		memory:    binary.BigEndian.Uint32(raw[13:17]),
+		threads:   raw[17],
+		salt:      raw[18:34],
+		prefix:    raw[34:41],
+		chunkSize: binary.BigEndian.Uint32(raw[41:45]),
+		keyCheck:  raw[45:61],
+	}
+	if h.time == 0 || h.time > maxArgonTime || h.memory == 0 || h.memory > maxArgonMemory ||
+		h.threads == 0 || h.chunkSize == 0 || h.chunkSize > maxChunkSize {
+		return nil, ErrCorruptArchive
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	deriveKey(passphrase, h)
+	if !hmac.Equal(keyCheck(h.derivedKey), h.keyCheck) {
+		return nil, ErrWrongPassphrase
+	}
+	return h, nil
+}
+
+// DecryptStream 解密 EncryptStream 产生的数据并写入 w。
+func DecryptStream(w io.Writer, r io.Reader, passphrase string) error {
+	h, err := readEncryptionHeader(r, passphrase)
+	if err != nil {
+		return err
+	}
+	aead, err := newGCM(h.derivedKey)
+	if err != nil {
+		return err
+	}
+	sealedSize := int(h.chunkSize) + aead.Overhead()
+	br := bufio.NewReaderSize(r, sealedSize)
+	sealed := make([]byte, sealedSize)
+	plain := make([]byte, 0, h.chunkSize)
+	for counter := uint32(0); ; counter++ {
+		n, err := io.ReadFull(br, sealed)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return err
+		}
+		last := err != nil
+		if !last {
+			if _, peekErr := br.Peek(1); peekErr == io.EOF {
+				last = true
+			}
+		}
+		plain, err = aead.Open(plain[:0], chunkNonce(h.prefix, counter, last), sealed[:n], h.raw)
+		if err != nil {
+			return ErrCorruptArchive
+		}
+		if _, err := w.Write(plain); err != nil {
+			return err
+		}
+		if last {
+			return nil
+		}
+	}
+}
+
+// IsEncrypted 判断文件是否为加密归档。
+func IsEncrypted(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	magic := make([]byte, len(encryptedMagic))
+	if _, err := io.ReadFull(f, magic); err != nil {
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return false, nil
+		}
+		return false, err
+	}
+	return string(magic) == encryptedMagic, nil
+}
+
+// EncryptFile 加密 src 写入 dst。
+func EncryptFile(src, dst, passphrase string) error {
+	return transformFile(src, dst, func(w io.Writer, r io.Reader) error {
+		return EncryptStream(w, r, passphrase)
+	})
+}
+
+// DecryptFile 解密 src 写入 dst；失败时删除 dst。
+func DecryptFile(src, dst, passphrase string) error {
+	return transformFile(src, dst, func(w io.Writer, r io.Reader) error {
+		return DecryptStream(w, r, passphrase)
+	})
+}
+
+func transformFile(src, dst string, transform func(io.Writer, io.Reader) error) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	bw := bufio.NewWriterSize(out, 1<<20)
+	err = transform(bw, in)
+	if err == nil {
+		err = bw.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(dst)
+	}
+	return err
+}
@@ -0,0 +1,93 @@
+package backup
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEncryptStreamRoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, encryptChunkSize - 1, encryptChunkSize, 2*encryptChunkSize + 17} {
+		plain := make([]byte, size)
+		rand.Read(plain)
+		var sealed bytes.Buffer
+		if err := EncryptStream(&sealed, bytes.NewReader(plain), "correct horse"); err != nil {
+			t.Fatalf("size %d: EncryptStream: %v", size, err)
+		}
+		var opened bytes.Buffer
+		if err := DecryptStream(&opened, bytes.NewReader(sealed.Bytes()), "correct horse"); err != nil {
+			t.Fatalf("size %d: DecryptStream: %v", size, err)
+		}
+		if !bytes.Equal(opened.Bytes(), plain) {
+			t.Fatalf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestDecryptStreamRejectsWrongPassphraseAndTampering(t *testing.T) {
+	plain := bytes.Repeat([]byte("komari"), encryptChunkSize/3)
+	var sealed bytes.Buffer
+	if err := EncryptStream(&sealed, bytes.NewReader(plain), "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	data := sealed.Bytes()
+
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(data), "wrong horse"); !errors.Is(err, ErrWrongPassphrase) {
+		t.Fatalf("wrong passphrase = %v, want ErrWrongPassphrase", err)
+	}
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(data), ""); !errors.Is(err, ErrPassphraseRequired) {
+		t.Fatalf("empty passphrase = %v, want ErrPassphraseRequired", err)
+	}
+
+	// 截掉末块后剩余的块不带末块标志，必须被拒绝。
+	truncated := data[:encryptedHeader+encryptChunkSize+16]
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(truncated), "correct horse"); !errors.Is(err, ErrCorruptArchive) {
+		t.Fatalf("truncated archive = %v, want ErrCorruptArchive", err)
+	}
+
+	tampered := bytes.Clone(data)
+	tampered[len(tampered)-20] ^= 0x01
+	if err := DecryptStream(&bytes.Buffer{}, bytes.NewReader(tampered), "correct horse"); !errors.Is(err, ErrCorruptArchive) {
+		t.Fatalf("tampered archive = %v, want ErrCorruptArchive", err)
+	}
+}
+
+func TestSaveUploadedBackupDecryptsEncryptedArchive(t *testing.T) {
+	t.Chdir(t.TempDir())
+	archive := writeTestArchive(t, map[string]string{"komari-backup-markup": "marker"})
+	encrypted := archive + EncryptedExt
+	if err := EncryptFile(archive, encrypted, "correct horse"); err != nil {
+		t.Fatal(err)
+	}
+	if err := VerifyArchive(encrypted, "correct horse"); err != nil {
+		t.Fatalf("VerifyArchive: %v", err)
+	}
+
+	open := func() *os.File {
+		f, err := os.Open(encrypted)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { f.Close() })
+		return f
+	}
+	err := SaveUploadedBackup(open(), "backup.zip.enc", "wrong horse")
+	if !errors.Is(err, ErrWrongPassphrase) || !strings.Contains(err.Error(), "wrong passphrase") {
+		t.Fatalf("wrong passphrase = %v", err)
+	}
+	if err := SaveUploadedBackup(open(), "backup.zip.enc", "correct horse"); err != nil {
+		t.Fatalf("SaveUploadedBackup: %v", err)
+	}
+	staged := filepath.Join("data", "backup.zip")
+	if err := ValidateArchive(staged); err != nil {
+		t.Fatalf("staged backup.zip is not the decrypted archive: %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join("data", ".backup-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+}
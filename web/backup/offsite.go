@@ -0,0 +1,223 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/komari-monitor/komari/database/backupdestinations"
+	"github.com/komari-monitor/komari/database/models"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/backup/remote"
+)
+
+const (
+	uploadTimeout   = time.Hour
+	downloadTimeout = time.Hour
+)
+
+// ErrUnencryptedUpload 表示目标不允许接收未加密的归档。
+var ErrUnencryptedUpload = errors.New("destination only accepts encrypted backups; enable backup encryption or allow unencrypted uploads for this destination")
+
+// 上传记录状态
+const (
+	UploadSuccess = "success"
+	UploadFailed  = "failed"
+)
+
+// uploadToDestinations 把刚生成的定时备份上传到全部启用的异地目标，
+// 并按各目标自己的保留策略轮转远端归档。单个目标失败只通知，不影响其他目标。
+func uploadToDestinations(ctx context.Context, archive ArchiveInfo) {
+	destinations, err := backupdestinations.ListEnabledBackupDestinations()
+	if err != nil {
+		notifyBackupFailure(fmt.Errorf("load backup destinations: %w", err))
+		return
+	}
+	for _, dest := range destinations {
+		if err := uploadArchive(ctx, dest, archive.Name); err != nil {
+			notifyBackupFailure(fmt.Errorf("upload %s to %q: %w", archive.Name, dest.Name, err))
+			continue
+		}
+		if err := applyRemoteRetention(ctx, dest); err != nil {
+			logger.Warnf("backup", "Failed to rotate backups on %q: %v", dest.Name, err)
+		}
+	}
+}
+
+// UploadArchive 把 ./data/backup 中已保存的归档上传到指定目标。
+func UploadArchive(ctx context.Context, destinationID uint, name string) error {
+	dest, err := backupdestinations.GetBackupDestination(destinationID)
+	if err != nil {
+		return err
+	}
+	return uploadArchive(ctx, *dest, name)
+}
+
+// uploadArchive 上传并记录结果到上传历史。
+func uploadArchive(ctx context.Context, dest models.BackupDestination, name string) error {
+	path, err := ArchivePath(name)
+	if err != nil {
+		return err
+	}
+	record := models.BackupUpload{DestinationID: dest.Id, Archive: name, Status: UploadSuccess}
+	if info, statErr := os.Stat(path); statErr == nil {
+		record.Size = info.Size()
+	}
+
+	started := time.Now()
+	err = func() error {
+		if !dest.AllowUnencrypted {
+			encrypted, err := IsEncrypted(path)
+			if err != nil {
+				return err
+			}
+			if !encrypted {
+				return ErrUnencryptedUpload
+			}
+		}
+		target, err := remote.New(dest)
+		if err != nil {
+			return err
+		}
+		uploadCtx, cancel := context.WithTimeout(ctx, uploadTimeout)
+		defer cancel()
+		return target.Upload(uploadCtx, name, path)
+	}()
+	record.Duration = time.Since(started).Milliseconds()
+	if err != nil {
+		record.Status = UploadFailed
+		record.Error = err.Error()
+	}
+	if recordErr := backupdestinations.RecordUpload(&record); recordErr != nil {
+		logger.Warnf("backup", "Failed to record upload of %s to %q: %v", name, dest.Name, recordErr)
+	}
+	if err == nil {
+		logger.Infof("backup", "Uploaded %s to %q in %dms", name, dest.Name, record.Duration)
+	}
+	return err
+}
+
+// applyRemoteRetention 删除远端超出该目标保留策略的 scheduled-*.zip。
+func applyRemoteRetention(ctx context.Context, dest models.BackupDestination) error {
+	target, err := remote.New(dest)
+	if err != nil {
+		return err
+	}
+	objects, err := target.List(ctx)
+	if err != nil {
+		return err
+	}
+	scheduled := make([]ArchiveInfo, 0, len(objects))
+	for _, object := range objects {
+		if archiveKind(object.Name) == KindScheduled {
+			scheduled = append(scheduled, ArchiveInfo{Name: object.Name, Kind: KindScheduled, Size: object.Size, Modified: object.Modified})
+		}
+	}
+	sortArchivesNewestFirst(scheduled)
+	var errs []error
+	for _, archive := range selectExpired(scheduled, dest.KeepLast, dest.KeepDaily, dest.KeepWeekly) {
+		if err := target.Delete(ctx, archive.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.Infof("backup", "Rotated out %s on %q", archive.Name, dest.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// ListRemoteArchives 按时间倒序列出目标中的归档。
+func ListRemoteArchives(ctx context.Context, destinationID uint) ([]remote.Object, error) {
+	dest, err := backupdestinations.GetBackupDestination(destinationID)
+	if err != nil {
+		return nil, err
+	}
+	return listRemote(ctx, *dest)
+}
+
+func listRemote(ctx context.Context, dest models.BackupDestination) ([]remote.Object, error) {
+	target, err := remote.New(dest)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := target.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(objects, func(i, j int) bool {
+		if !objects[i].Modified.Equal(objects[j].Modified) {
+			return objects[i].Modified.After(objects[j].Modified)
+		}
+		return objects[i].Name > objects[j].Name
+	})
+	return objects, nil
+}
+
+// RestoreFromRemote 从目标下载归档并交给 SaveUploadedBackup 暂存，下次启动时恢复。
+// 加密归档使用 passphrase 解密，为空时使用已保存的备份口令。
+// 成功时返回仍持有的恢复锁，调用方应调用 RestartToApply。
+func RestoreFromRemote(ctx context.Context, destinationID uint, name, passphrase string) (*RestoreLock, error) {
+	if err := remote.ValidateName(name); err != nil {
+		return nil, err
+	}
+	if !IsArchiveName(name) {
+		return nil, ErrInvalidArchiveName
+	}
+	dest, err := backupdestinations.GetBackupDestination(destinationID)
+	if err != nil {
+		return nil, err
+	}
+	target, err := remote.New(*dest)
+	if err != nil {
+		return nil, err
+	}
+
+	lock, err := AcquireRestoreLock()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll("./data", 0755); err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("create data directory: %w", err)
+	}
+	temp, err := os.CreateTemp("./data", ".backup-remote-*.zip")
+	if err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("create temporary backup: %w", err)
+	}
+	defer os.Remove(temp.Name())
+	defer temp.Close()
+
+	downloadCtx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	if err := target.Download(downloadCtx, name, &limitedWriter{w: temp, n: MaxArchiveSize}); err != nil {
+		lock.Release()
+		return nil, fmt.Errorf("download %s from %q: %w", name, dest.Name, err)
+	}
+	if _, err := temp.Seek(0, 0); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	if err := lock.SaveUploadedBackup(temp, name, ResolvePassphrase(passphrase)); err != nil {
+		lock.Release()
+		return nil, err
+	}
+	return lock, nil
+}
+
+// limitedWriter 在写入超过 n 字节时报错，避免远端返回超大文件占满磁盘。
+type limitedWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (l *limitedWriter) Write(p []byte) (int, error) {
+	if int64(len(p)) > l.n {
+		return 0, fmt.Errorf("backup archive exceeds the %d byte limit", MaxArchiveSize)
+	}
+	l.n -= int64(len(p))
+	return l.w.Write(p)
+}
@@ -0,0 +1,77 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/backupdestinations"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/web/backup/remote"
+	"golang.org/x/net/webdav"
+)
+
+func TestOffsiteUploadRetentionAndRestore(t *testing.T) {
+	dbtest.Open(t, "backup_offsite")
+
+	server := httptest.NewServer(&webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()})
+	defer server.Close()
+	dest := models.BackupDestination{Name: "dav", Type: remote.TypeWebDAV, URL: server.URL, Path: "komari", Enabled: true, KeepLast: 1, AllowUnencrypted: true}
+	if err := backupdestinations.CreateBackupDestination(&dest); err != nil {
+		t.Fatalf("create destination: %v", err)
+	}
+
+	archive := writeTestArchive(t, map[string]string{"komari-backup-markup": "marker"})
+	if err := os.MkdirAll(ArchiveDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	var names []string
+	for i := 0; i < 2; i++ {
+		name := NewArchiveName(KindScheduled, time.Now().Add(time.Duration(i)*time.Second))
+		data, _ := os.ReadFile(archive)
+		if err := os.WriteFile(filepath.Join(ArchiveDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		uploadToDestinations(ctx, ArchiveInfo{Name: name, Kind: KindScheduled})
+		names = append(names, name)
+		time.Sleep(1100 * time.Millisecond) // WebDAV modification times have second precision
+	}
+
+	objects, err := ListRemoteArchives(ctx, dest.Id)
+	if err != nil {
+		t.Fatalf("ListRemoteArchives: %v", err)
+	}
+	if len(objects) != 1 || objects[0].Name != names[1] {
+		t.Fatalf("remote archives after rotation = %+v, want only %s", objects, names[1])
+	}
+	uploads, err := backupdestinations.ListUploads(dest.Id, 10)
+	if err != nil || len(uploads) != 2 || uploads[0].Status != UploadSuccess || uploads[0].Archive != names[1] {
+		t.Fatalf("upload history = %+v, %v", uploads, err)
+	}
+
+	strict := models.BackupDestination{Name: "strict", Type: remote.TypeWebDAV, URL: server.URL, Path: "strict", KeepLast: 1}
+	if err := backupdestinations.CreateBackupDestination(&strict); err != nil {
+		t.Fatalf("create destination: %v", err)
+	}
+	if err := UploadArchive(ctx, strict.Id, names[1]); !errors.Is(err, ErrUnencryptedUpload) {
+		t.Fatalf("unencrypted upload to strict destination = %v, want ErrUnencryptedUpload", err)
+	}
+
+	if _, err := RestoreFromRemote(ctx, dest.Id, "missing.zip", ""); err == nil {
+		t.Fatal("restoring a missing archive succeeded")
+	}
+	lock, err := RestoreFromRemote(ctx, dest.Id, names[1], "")
+	if err != nil {
+		t.Fatalf("RestoreFromRemote: %v", err)
+	}
+	lock.Release()
+	if err := ValidateArchive(filepath.Join("data", "backup.zip")); err != nil {
+		t.Fatalf("staged backup.zip is invalid: %v", err)
+	}
+}
@@ -0,0 +1,117 @@
+// Package remote uploads backup archives to off-site destinations
+// (S3-compatible object storage and WebDAV) and fetches them back for restore.
+package remote
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+const (
+	TypeS3     = "s3"
+	TypeWebDAV = "webdav"
+)
+
+// ErrNotFound 表示远端不存在指定归档。
+var ErrNotFound = errors.New("remote archive not found")
+
+// Object 描述远端目录中的一个归档。
+type Object struct {
+	Name     string    `json:"name"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// Target 是一个异地备份目标。name 均为不含目录的归档文件名，
+// 目标内的目录前缀由配置决定。
+type Target interface {
+	// Upload 上传本地文件 localPath 为 name。
+	Upload(ctx context.Context, name, localPath string) error
+	// List 列出目录前缀下的 .zip 与 .zip.enc 归档。
+	List(ctx context.Context) ([]Object, error)
+	// Download 把 name 写入 w。
+	Download(ctx context.Context, name string, w io.Writer) error
+	// Delete 删除 name。
+	Delete(ctx context.Context, name string) error
+}
+
+var httpClient = &http.Client{}
+
+// New 根据配置构造目标。
+func New(dest models.BackupDestination) (Target, error) {
+	if err := Validate(dest); err != nil {
+		return nil, err
+	}
+	switch dest.Type {
+	case TypeS3:
+		return newS3(dest)
+	case TypeWebDAV:
+		return newWebDAV(dest)
+	}
+	return nil, fmt.Errorf("unsupported backup destination type %q", dest.Type)
+}
+
+// Validate 校验目标配置。
+func Validate(dest models.BackupDestination) error {
+	if strings.TrimSpace(dest.Name) == "" {
+		return fmt.Errorf("name is required")
+	}
+	u, err := url.Parse(strings.TrimSpace(dest.URL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an absolute http(s) URL")
+	}
+	if dest.KeepLast < 0 || dest.KeepDaily < 0 || dest.KeepWeekly < 0 {
+		return fmt.Errorf("retention values must not be negative")
+	}
+	switch dest.Type {
+	case TypeS3:
+		if strings.TrimSpace(dest.Bucket) == "" {
+			return fmt.Errorf("bucket is required for s3 destinations")
+		}
+		if dest.Username == "" || dest.Password == "" {
+			return fmt.Errorf("access key and secret key are required for s3 destinations")
+		}
+	case TypeWebDAV:
+	default:
+		return fmt.Errorf("type must be %q or %q", TypeS3, TypeWebDAV)
+	}
+	return nil
+}
+
+// ValidateName 拒绝包含路径的归档名，防止越出目标目录。
+func ValidateName(name string) error {
+	if name == "" || name != path.Base(name) || strings.ContainsAny(name, `/\`) || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("invalid archive name %q", name)
+	}
+	return nil
+}
+
+// isArchiveObject 判断远端对象名是否为备份归档。
+func isArchiveObject(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".zip.enc")
+}
+
+// cleanPrefix 把用户配置的目录规范化为 "a/b/" 形式（空目录返回 ""）。
+func cleanPrefix(p string) string {
+	p = strings.Trim(path.Clean("/"+strings.TrimSpace(p)), "/")
+	if p == "" {
+		return ""
+	}
+	return p + "/"
+}
+
+// readErrorBody 读取有限长度的错误响应体，用于错误信息。
+func readErrorBody(resp *http.Response) string {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return strings.TrimSpace(string(body))
+}
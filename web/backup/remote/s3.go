@@ -0,0 +1,408 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+const (
+	// s3PartSize 是分片上传的分片大小；不超过该大小的文件使用单次 PUT。
+	// S3 要求除最后一片外每片至少 5 MiB。
+	s3PartSize     = 16 << 20
+	emptySHA256Hex = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Target 是 S3 兼容对象存储目标，请求使用 AWS Signature Version 4 签名。
+type s3Target struct {
+	endpoint  *url.URL
+	region    string
+	bucket    string
+	prefix    string
+	pathStyle bool
+	accessKey string
+	secretKey string
+	partSize  int64
+	now       func() time.Time
+}
+
+func newS3(dest models.BackupDestination) (*s3Target, error) {
+	endpoint, err := url.Parse(strings.TrimRight(strings.TrimSpace(dest.URL), "/"))
+	if err != nil {
+		return nil, err
+	}
+	region := strings.TrimSpace(dest.Region)
+	if region == "" {
+		region = "us-east-1"
+	}
+	return &s3Target{
+		endpoint:  endpoint,
+		region:    region,
+		bucket:    strings.TrimSpace(dest.Bucket),
+		prefix:    cleanPrefix(dest.Path),
+		pathStyle: dest.PathStyle,
+		accessKey: dest.Username,
+		secretKey: dest.Password,
+		partSize:  s3PartSize,
+		now:       time.Now,
+	}, nil
+}
+
+// objectURL 构造对象（key 为空时为 bucket）的 URL 及查询参数。
+func (t *s3Target) objectURL(key string, query url.Values) *url.URL {
+	u := *t.endpoint
+	escapedKey := s3EscapePath(key)
+	if t.pathStyle {
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + t.bucket + "/" + key
+		u.RawPath = strings.TrimRight(t.endpoint.EscapedPath(), "/") + "/" + s3EscapePath(t.bucket) + "/" + escapedKey
+	} else {
+		u.Host = t.bucket + "." + u.Host
+		u.Path = strings.TrimRight(u.Path, "/") + "/" + key
+		u.RawPath = strings.TrimRight(t.endpoint.EscapedPath(), "/") + "/" + escapedKey
+	}
+	u.RawQuery = s3CanonicalQuery(query)
+	return &u
+}
+
+// do 发送签名请求；body 为 nil 时视为空负载。非 2xx 响应转为错误。
+func (t *s3Target) do(ctx context.Context, method, key string, query url.Values, body []byte, header http.Header) (*http.Response, error) {
+	u := t.objectURL(key, query)
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.URL = u
+	req.ContentLength = int64(len(body))
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	payloadHash := emptySHA256Hex
+	if len(body) > 0 {
+		sum := sha256.Sum256(body)
+		payloadHash = hex.EncodeToString(sum[:])
+	}
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	signV4(req, payloadHash, t.accessKey, t.secretKey, t.region, "s3", t.now().UTC())
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound && method == http.MethodGet && key != "" {
+			return nil, ErrNotFound
+		}
+		return nil, s3ResponseError(method, resp)
+	}
+	return resp, nil
+}
+
+func s3ResponseError(method string, resp *http.Response) error {
+	body := readErrorBody(resp)
+	var s3err struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	if xml.Unmarshal([]byte(body), &s3err) == nil && s3err.Code != "" {
+		return fmt.Errorf("s3 %s: %s: %s (HTTP %d)", method, s3err.Code, s3err.Message, resp.StatusCode)
+	}
+	return fmt.Errorf("s3 %s: HTTP %d: %s", method, resp.StatusCode, body)
+}
+
+func (t *s3Target) Upload(ctx context.Context, name, localPath string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	key := t.prefix + name
+	header := http.Header{"Content-Type": {"application/zip"}}
+	if info.Size() <= t.partSize {
+		body, err := io.ReadAll(f)
+		if err != nil {
+			return err
+		}
+		resp, err := t.do(ctx, http.MethodPut, key, nil, body, header)
+		if err != nil {
+			return err
+		}
+		return resp.Body.Close()
+	}
+	return t.multipartUpload(ctx, key, f, header)
+}
+
+type s3CompletedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+func (t *s3Target) multipartUpload(ctx context.Context, key string, r io.Reader, header http.Header) error {
+	resp, err := t.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil, header)
+	if err != nil {
+		return err
+	}
+	var initiated struct {
+		UploadID string `xml:"UploadId"`
+	}
+	err = xml.NewDecoder(resp.Body).Decode(&initiated)
+	resp.Body.Close()
+	if err != nil || initiated.UploadID == "" {
+		return fmt.Errorf("s3 initiate multipart upload: invalid response: %v", err)
+	}
+
+	parts, err := t.uploadParts(ctx, key, initiated.UploadID, r)
+	if err == nil {
+		err = t.completeMultipart(ctx, key, initiated.UploadID, parts)
+	}
+	if err != nil {
+		// 中止未完成的分片上传，避免残留分片持续计费。
+		abortCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), 30*time.Second)
+		defer cancel()
+		if resp, abortErr := t.do(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {initiated.UploadID}}, nil, nil); abortErr == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+	return nil
+}
+
+func (t *s3Target) uploadParts(ctx context.Context, key, uploadID string, r io.Reader) ([]s3CompletedPart, error) {
+	var parts []s3CompletedPart
+	buf := make([]byte, t.partSize)
+	for number := 1; ; number++ {
+		n, err := io.ReadFull(r, buf)
+		if err == io.EOF {
+			break
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return nil, err
+		}
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		resp, doErr := t.do(ctx, http.MethodPut, key, query, buf[:n], nil)
+		if doErr != nil {
+			return nil, fmt.Errorf("upload part %d: %w", number, doErr)
+		}
+		resp.Body.Close()
+		parts = append(parts, s3CompletedPart{PartNumber: number, ETag: resp.Header.Get("ETag")})
+		if err == io.ErrUnexpectedEOF {
+			break
+		}
+	}
+	return parts, nil
+}
+
+func (t *s3Target) completeMultipart(ctx context.Context, key, uploadID string, parts []s3CompletedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name          `xml:"CompleteMultipartUpload"`
+		Parts   []s3CompletedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body, http.Header{"Content-Type": {"application/xml"}})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// CompleteMultipartUpload 可能在 200 响应体中返回错误。
+	result := readErrorBody(resp)
+	if strings.Contains(result, "<Error>") {
+		var s3err struct {
+			Code    string `xml:"Code"`
+			Message string `xml:"Message"`
+		}
+		_ = xml.Unmarshal([]byte(result), &s3err)
+		return fmt.Errorf("s3 complete multipart upload: %s: %s", s3err.Code, s3err.Message)
+	}
+	return nil
+}
+
+func (t *s3Target) List(ctx context.Context) ([]Object, error) {
+	var objects []Object
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {t.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		resp, err := t.do(ctx, http.MethodGet, "", query, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		var result struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&result)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("s3 list objects: %w", err)
+		}
+		for _, c := range result.Contents {
+			name := strings.TrimPrefix(c.Key, t.prefix)
+			if strings.Contains(name, "/") || !isArchiveObject(name) {
+				continue
+			}
+			objects = append(objects, Object{Name: name, Size: c.Size, Modified: c.LastModified.UTC()})
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			break
+		}
+		token = result.NextContinuationToken
+	}
+	return objects, nil
+}
+
+func (t *s3Target) Download(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodGet, t.prefix+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (t *s3Target) Delete(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	resp, err := t.do(ctx, http.MethodDelete, t.prefix+name, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// signV4 为请求添加 AWS Signature Version 4 的 Authorization 头。
+// 签名 host、content-type（若存在）以及全部 x-amz-* 头。
+func signV4(req *http.Request, payloadHash, accessKey, secretKey, region, service string, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if strings.HasPrefix(lk, "x-amz-") || lk == "content-type" {
+			headers[lk] = strings.Join(v, ",")
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + strings.TrimSpace(headers[k]) + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalURI := req.URL.EscapedPath()
+	if canonicalURI == "" {
+		canonicalURI = "/"
+	}
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI,
+		s3CanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+secretKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, service)
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		accessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// s3Escape 按 RFC 3986 编码（仅保留非保留字符），用于查询参数和路径段。
+func s3Escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// s3EscapePath 逐段编码对象 key，保留分隔符 '/'。
+func s3EscapePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = s3Escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// s3CanonicalQuery 按 key、value 排序并编码查询参数。
+func s3CanonicalQuery(query url.Values) string {
+	if len(query) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var parts []string
+	for _, k := range keys {
+		values := append([]string(nil), query[k]...)
+		sort.Strings(values)
+		for _, v := range values {
+			parts = append(parts, s3Escape(k)+"="+s3Escape(v))
+		}
+	}
+	return strings.Join(parts, "&")
+}
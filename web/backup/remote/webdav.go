@@ -0,0 +1,229 @@
+package remote
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+const propfindBody = `<?xml version="1.0" encoding="utf-8"?>
+<d:propfind xmlns:d="DAV:"><d:prop><d:resourcetype/><d:getcontentlength/><d:getlastmodified/></d:prop></d:propfind>`
+
+// webdavTarget 是 WebDAV 目标，使用 Basic 认证。
+type webdavTarget struct {
+	base     *url.URL // 以 "/" 结尾的目录 URL
+	dirs     []string // 需要逐级创建的目录（相对服务根）
+	username string
+	password string
+}
+
+func newWebDAV(dest models.BackupDestination) (*webdavTarget, error) {
+	base, err := url.Parse(strings.TrimSpace(dest.URL))
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimRight(base.Path, "/")
+	prefix := cleanPrefix(dest.Path)
+	var dirs []string
+	current := root
+	for _, segment := range strings.Split(strings.TrimSuffix(prefix, "/"), "/") {
+		if segment == "" {
+			continue
+		}
+		current += "/" + segment
+		dirs = append(dirs, current+"/")
+	}
+	base.Path = root + "/" + prefix
+	base.RawPath = ""
+	return &webdavTarget{base: base, dirs: dirs, username: dest.Username, password: dest.Password}, nil
+}
+
+func (t *webdavTarget) fileURL(name string) string {
+	u := *t.base
+	u.Path += name
+	return u.String()
+}
+
+func (t *webdavTarget) request(ctx context.Context, method, target string, body io.Reader, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return httpClient.Do(req)
+}
+
+func (t *webdavTarget) check(method string, resp *http.Response, err error) error {
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		if resp.StatusCode == http.StatusNotFound {
+			return ErrNotFound
+		}
+		return fmt.Errorf("webdav %s: HTTP %d: %s", method, resp.StatusCode, readErrorBody(resp))
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// ensureDirs 逐级 MKCOL 目标目录；已存在时服务端返回 405，忽略即可。
+func (t *webdavTarget) ensureDirs(ctx context.Context) error {
+	for _, dir := range t.dirs {
+		u := *t.base
+		u.Path = dir
+		resp, err := t.request(ctx, "MKCOL", u.String(), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusMethodNotAllowed &&
+			(resp.StatusCode < 200 || resp.StatusCode > 299) {
+			return fmt.Errorf("webdav MKCOL %s: HTTP %d", dir, resp.StatusCode)
+		}
+	}
+	return nil
+}
+
+func (t *webdavTarget) Upload(ctx context.Context, name, localPath string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	if err := t.ensureDirs(ctx); err != nil {
+		return err
+	}
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, t.fileURL(name), f)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = info.Size()
+	req.Header.Set("Content-Type", "application/zip")
+	if t.username != "" || t.password != "" {
+		req.SetBasicAuth(t.username, t.password)
+	}
+	resp, err := httpClient.Do(req)
+	return t.check(http.MethodPut, resp, err)
+}
+
+type davMultistatus struct {
+	Responses []struct {
+		Href     string `xml:"href"`
+		Propstat []struct {
+			Prop struct {
+				ResourceType struct {
+					Collection *struct{} `xml:"collection"`
+				} `xml:"resourcetype"`
+				ContentLength string `xml:"getcontentlength"`
+				LastModified  string `xml:"getlastmodified"`
+			} `xml:"prop"`
+			Status string `xml:"status"`
+		} `xml:"propstat"`
+	} `xml:"response"`
+}
+
+func (t *webdavTarget) List(ctx context.Context) ([]Object, error) {
+	resp, err := t.request(ctx, "PROPFIND", t.base.String(), strings.NewReader(propfindBody), http.Header{
+		"Depth":        {"1"},
+		"Content-Type": {"application/xml; charset=utf-8"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return []Object{}, nil
+	}
+	if resp.StatusCode != http.StatusMultiStatus && resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("webdav PROPFIND: HTTP %d: %s", resp.StatusCode, readErrorBody(resp))
+	}
+	var ms davMultistatus
+	if err := xml.NewDecoder(resp.Body).Decode(&ms); err != nil {
+		return nil, fmt.Errorf("webdav PROPFIND: %w", err)
+	}
+
+	objects := []Object{}
+	for _, r := range ms.Responses {
+		href, err := url.Parse(r.Href)
+		if err != nil {
+			continue
+		}
+		name := path.Base(strings.TrimRight(href.Path, "/"))
+		if strings.HasSuffix(href.Path, "/") || !isArchiveObject(name) {
+			continue
+		}
+		obj := Object{Name: name}
+		isCollection := false
+		for _, ps := range r.Propstat {
+			if !strings.Contains(ps.Status, " 200") {
+				continue
+			}
+			if ps.Prop.ResourceType.Collection != nil {
+				isCollection = true
+			}
+			if ps.Prop.ContentLength != "" {
+				obj.Size, _ = strconv.ParseInt(ps.Prop.ContentLength, 10, 64)
+			}
+			if ps.Prop.LastModified != "" {
+				if modified, err := http.ParseTime(ps.Prop.LastModified); err == nil {
+					obj.Modified = modified.UTC()
+				}
+			}
+		}
+		if isCollection {
+			continue
+		}
+		objects = append(objects, obj)
+	}
+	return objects, nil
+}
+
+func (t *webdavTarget) Download(ctx context.Context, name string, w io.Writer) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	resp, err := t.request(ctx, http.MethodGet, t.fileURL(name), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webdav GET: HTTP %d: %s", resp.StatusCode, readErrorBody(resp))
+	}
+	_, err = io.Copy(w, resp.Body)
+	return err
+}
+
+func (t *webdavTarget) Delete(ctx context.Context, name string) error {
+	if err := ValidateName(name); err != nil {
+		return err
+	}
+	resp, err := t.request(ctx, http.MethodDelete, t.fileURL(name), nil, nil)
+	return t.check(http.MethodDelete, resp, err)
+}
@@ -0,0 +1,67 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/models"
+	"golang.org/x/net/webdav"
+)
+
+func TestWebDAVTargetRoundTrip(t *testing.T) {
+	handler := &webdav.Handler{FileSystem: webdav.NewMemFS(), LockSystem: webdav.NewMemLS()}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if user, pass, ok := r.BasicAuth(); !ok || user != "u" || pass != "p" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	target, err := New(models.BackupDestination{
+		Name: "dav", Type: TypeWebDAV, URL: server.URL + "/", Path: "backups/komari", Username: "u", Password: "p",
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+	ctx := context.Background()
+
+	objects, err := target.List(ctx)
+	if err != nil || len(objects) != 0 {
+		t.Fatalf("List before upload = %v, %v", objects, err)
+	}
+
+	local := filepath.Join(t.TempDir(), "a.zip")
+	os.WriteFile(local, []byte("archive"), 0o644)
+	for _, name := range []string{"scheduled-1.zip", "scheduled-2.zip"} {
+		if err := target.Upload(ctx, name, local); err != nil {
+			t.Fatalf("Upload %s: %v", name, err)
+		}
+	}
+
+	objects, err = target.List(ctx)
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(objects) != 2 || objects[0].Size != 7 || objects[0].Modified.IsZero() {
+		t.Fatalf("unexpected objects %+v", objects)
+	}
+
+	var buf bytes.Buffer
+	if err := target.Download(ctx, "scheduled-2.zip", &buf); err != nil || buf.String() != "archive" {
+		t.Fatalf("Download = %q, %v", buf.String(), err)
+	}
+	if err := target.Delete(ctx, "scheduled-2.zip"); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if err := target.Download(ctx, "scheduled-2.zip", io.Discard); err != ErrNotFound {
+		t.Fatalf("Download after delete = %v, want ErrNotFound", err)
+	}
+}
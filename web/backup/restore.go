@@ -1,4 +1,5 @@
-// Package backup contains the shared upload preparation for backup restores.
+// Package backup creates, stores and rotates backup archives and prepares
+// uploaded archives for restore.
 package backup
 
 import (
@@ -7,8 +8,10 @@ import (
 	"io"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
+	"time"
+
+	logger "github.com/komari-monitor/komari/utils/log"
 )
 
 var restoreMutex sync.Mutex
@@ -36,21 +39,33 @@ func (l *RestoreLock) Release() {
 	l.once.Do(restoreMutex.Unlock)
 }
 
+// RestartToApply exits the process shortly after a backup was staged so the
+// startup restore path applies it. The lock is held until the process exits.
+func (l *RestoreLock) RestartToApply() {
+	go func() {
+		logger.InfoArgs("backup", "Backup staged, restarting service in 2 seconds to apply on startup...")
+		time.Sleep(2 * time.Second)
+		l.Release()
+		os.Exit(0)
+	}()
+}
+
 // SaveUploadedBackup validates a Komari backup and stages it for restoration
-// during the next process startup.
-func SaveUploadedBackup(file io.Reader, filename string) error {
+// during the next process startup. Encrypted archives are decrypted with
+// passphrase; see ResolvePassphrase for the configured fallback.
+func SaveUploadedBackup(file io.Reader, filename, passphrase string) error {
 	lock, err := AcquireRestoreLock()
 	if err != nil {
 		return err
 	}
 	defer lock.Release()
-	return lock.SaveUploadedBackup(file, filename)
+	return lock.SaveUploadedBackup(file, filename, passphrase)
 }
 
 // SaveUploadedBackup stages a backup while the caller holds the restore lock.
-func (l *RestoreLock) SaveUploadedBackup(file io.Reader, filename string) error {
-	if !strings.HasSuffix(strings.ToLower(filename), ".zip") {
-		return fmt.Errorf("uploaded file must be a ZIP archive")
+func (l *RestoreLock) SaveUploadedBackup(file io.Reader, filename, passphrase string) error {
+	if !IsArchiveName(filename) {
+		return fmt.Errorf("uploaded file must be a ZIP archive or an encrypted .zip.enc backup")
 	}
 	if err := os.MkdirAll("./data", 0755); err != nil {
 		return fmt.Errorf("create data directory: %w", err)
@@ -77,6 +92,19 @@ func (l *RestoreLock) SaveUploadedBackup(file io.Reader, filename string) error
 		return fmt.Errorf("close uploaded backup: %w", err)
 	}
 
+	encrypted, err := IsEncrypted(tempPath)
+	if err != nil {
+		return fmt.Errorf("read uploaded backup: %w", err)
+	}
+	if encrypted {
+		plainPath, err := decryptToTemp(tempPath, "./data", passphrase)
+		if err != nil {
+			return fmt.Errorf("decrypt backup: %w", err)
+		}
+		defer os.Remove(plainPath)
+		tempPath = plainPath
+	}
+
 	if err := ValidateArchive(tempPath); err != nil {
 		return err
 	}
@@ -107,6 +135,40 @@ func (l *RestoreLock) SaveUploadedBackup(file io.Reader, filename string) error
 	return nil
 }
 
+// VerifyArchive validates a stored archive, decrypting it to a temporary file
+// first when it is encrypted.
+func VerifyArchive(path, passphrase string) error {
+	encrypted, err := IsEncrypted(path)
+	if err != nil {
+		return fmt.Errorf("open backup archive: %w", err)
+	}
+	if !encrypted {
+		return ValidateArchive(path)
+	}
+	plainPath, err := decryptToTemp(path, filepath.Dir(path), passphrase)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(plainPath)
+	return ValidateArchive(plainPath)
+}
+
+// decryptToTemp decrypts an encrypted archive into a hidden temporary file in
+// dir and returns its path. The caller removes the file.
+func decryptToTemp(path, dir, passphrase string) (string, error) {
+	temp, err := os.CreateTemp(dir, ".backup-decrypted-*.zip")
+	if err != nil {
+		return "", fmt.Errorf("create temporary backup: %w", err)
+	}
+	tempPath := temp.Name()
+	temp.Close()
+	if err := DecryptFile(path, tempPath, passphrase); err != nil {
+		os.Remove(tempPath)
+		return "", err
+	}
+	return tempPath, nil
+}
+
 // ValidateArchive checks the backup marker and bounds archive expansion before
 // the startup restore path extracts it into data/.
 func ValidateArchive(path string) error {
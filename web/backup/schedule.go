@@ -0,0 +1,232 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	messageevent "github.com/komari-monitor/komari/database/models/messageEvent"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/internal/scheduler"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+)
+
+// ScheduleConfig 保存定时备份配置。
+//
+// 轮转只作用于 scheduled-*.zip：保留最近 KeepLast 个，另外每天保留最新一个
+// （最近 KeepDaily 天）、每 ISO 周保留最新一个（最近 KeepWeekly 周），三者取并集。
+type ScheduleConfig struct {
+	Enabled        bool   `json:"backup_schedule_enabled" default:"false"`
+	Cron           string `json:"backup_schedule_cron" default:"0 0 3 * * *"` // 支持 5/6 段 cron 或 @every
+	KeepLast       int    `json:"backup_keep_last" default:"7"`
+	KeepDaily      int    `json:"backup_keep_daily" default:"7"`
+	KeepWeekly     int    `json:"backup_keep_weekly" default:"4"`
+	IncludeMetrics bool   `json:"backup_include_metrics" default:"false"` // 附带 SQLite metrics 库快照
+}
+
+// ScheduleConfigKeys 配置键
+const (
+	ScheduleEnabledKey = "backup_schedule_enabled"
+	ScheduleCronKey    = "backup_schedule_cron"
+	KeepLastKey        = "backup_keep_last"
+	KeepDailyKey       = "backup_keep_daily"
+	KeepWeeklyKey      = "backup_keep_weekly"
+	IncludeMetricsKey  = "backup_include_metrics"
+)
+
+// ScheduleConfigKeyList 列出全部定时备份配置键，用于判断配置变更是否需要重载。
+var ScheduleConfigKeyList = []string{
+	ScheduleEnabledKey, ScheduleCronKey, KeepLastKey, KeepDailyKey, KeepWeeklyKey, IncludeMetricsKey,
+}
+
+const (
+	scheduleJobName        = "backup:scheduled"
+	scheduledBackupTimeout = 2 * time.Hour
+)
+
+// ErrBackupInProgress 表示已有定时备份正在执行。
+var ErrBackupInProgress = errors.New("a scheduled backup is already running")
+
+var scheduledMu sync.Mutex
+
+// ReloadSchedule 按当前配置注册或移除定时备份任务。
+func ReloadSchedule() error {
+	scheduler.Remove(scheduleJobName)
+	cfg, err := config.GetManyAs[ScheduleConfig]()
+	if err != nil {
+		return err
+	}
+	if !cfg.Enabled {
+		return nil
+	}
+	return scheduler.AddContextFunc(scheduleJobName, cfg.Cron, false, func(ctx context.Context) {
+		if _, err := RunScheduledBackup(ctx); err != nil && !errors.Is(err, ErrBackupInProgress) {
+			logger.Errorf("backup", "Scheduled backup failed: %v", err)
+		}
+	})
+}
+
+// RunScheduledBackup 生成一个 scheduled-*.zip 归档，校验其完整性后执行轮转，
+// 并上传到启用的异地目标。失败时删除不完整的归档并发送通知。
+func RunScheduledBackup(ctx context.Context) (ArchiveInfo, error) {
+	if !scheduledMu.TryLock() {
+		return ArchiveInfo{}, ErrBackupInProgress
+	}
+	defer scheduledMu.Unlock()
+
+	info, err := runScheduledBackup(ctx)
+	if err != nil {
+		notifyBackupFailure(err)
+		return ArchiveInfo{}, err
+	}
+	logger.Infof("backup", "Scheduled backup %s created (%d bytes)", info.Name, info.Size)
+	return info, nil
+}
+
+func runScheduledBackup(ctx context.Context) (ArchiveInfo, error) {
+	cfg, err := config.GetManyAs[ScheduleConfig]()
+	if err != nil {
+		return ArchiveInfo{}, fmt.Errorf("load backup schedule config: %w", err)
+	}
+	ctx, cancel := context.WithTimeout(ctx, scheduledBackupTimeout)
+	defer cancel()
+
+	passphrase, err := EncryptionPassphrase()
+	if err != nil {
+		return ArchiveInfo{}, err
+	}
+
+	path, err := CreateArchive(ctx, filepath.Join(ArchiveDir, NewArchiveName(KindScheduled, time.Now())),
+		ArchiveOptions{IncludeMetrics: cfg.IncludeMetrics, Passphrase: passphrase})
+	if err != nil {
+		return ArchiveInfo{}, err
+	}
+	name := filepath.Base(path)
+	if err := VerifyArchive(path, passphrase); err != nil {
+		_ = os.Remove(path)
+		return ArchiveInfo{}, fmt.Errorf("verify %s: %w", name, err)
+	}
+	stat, err := os.Stat(path)
+	if err != nil {
+		return ArchiveInfo{}, err
+	}
+
+	if err := applyRetention(cfg); err != nil {
+		// 归档本身已成功，轮转失败只影响旧文件清理。
+		logger.Warnf("backup", "Failed to rotate scheduled backups: %v", err)
+	}
+	info := ArchiveInfo{Name: name, Kind: KindScheduled, Size: stat.Size(), Modified: stat.ModTime().UTC()}
+	uploadToDestinations(ctx, info)
+	return info, nil
+}
+
+// applyRetention 删除超出保留策略的 scheduled-*.zip。
+func applyRetention(cfg *ScheduleConfig) error {
+	archives, err := ListArchives()
+	if err != nil {
+		return err
+	}
+	scheduled := make([]ArchiveInfo, 0, len(archives))
+	for _, archive := range archives {
+		if archive.Kind == KindScheduled {
+			scheduled = append(scheduled, archive)
+		}
+	}
+	var errs []error
+	for _, archive := range selectExpired(scheduled, cfg.KeepLast, cfg.KeepDaily, cfg.KeepWeekly) {
+		if err := DeleteArchive(archive.Name); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.Infof("backup", "Rotated out scheduled backup %s", archive.Name)
+	}
+	return errors.Join(errs...)
+}
+
+// selectExpired 返回不在保留集合中的归档。archives 须按时间倒序排列。
+// 至少保留最新的一个，避免配置全为 0 时清空所有备份。
+func selectExpired(archives []ArchiveInfo, keepLast, keepDaily, keepWeekly int) []ArchiveInfo {
+	keepLast = max(keepLast, 1)
+	days := make(map[string]struct{})
+	weeks := make(map[string]struct{})
+	var expired []ArchiveInfo
+	for i, archive := range archives {
+		keep := i < keepLast
+		// 日/周分桶使用系统本地时区，与 cron 的墙上时间一致。
+		t := archive.Modified.Local()
+		day := t.Format("2006-01-02")
+		if _, seen := days[day]; !seen && len(days) < keepDaily {
+			days[day] = struct{}{}
+			keep = true
+		}
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%02d", year, week)
+		if _, seen := weeks[weekKey]; !seen && len(weeks) < keepWeekly {
+			weeks[weekKey] = struct{}{}
+			keep = true
+		}
+		if !keep {
+			expired = append(expired, archive)
+		}
+	}
+	return expired
+}
+
+func notifyBackupFailure(cause error) {
+	message := "Scheduled backup failed: " + cause.Error()
+	auditlog.EventLog("error", message)
+	if err := messageSender.SendEvent(models.EventMessage{
+		Event:   messageevent.Backup,
+		Time:    time.Now(),
+		Emoji:   "💾",
+		Message: message,
+	}); err != nil {
+		logger.Warnf("backup", "Failed to send backup failure notification: %v", err)
+	}
+}
+
+// ValidateScheduleSettings 在设置落库前校验本次变更中的定时备份配置。
+func ValidateScheduleSettings(cfg map[string]any) error {
+	if v, ok := cfg[ScheduleCronKey]; ok {
+		spec, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("%s must be a string", ScheduleCronKey)
+		}
+		spec = strings.TrimSpace(spec)
+		if _, err := scheduler.Parse(spec); err != nil {
+			return fmt.Errorf("invalid %s: %v", ScheduleCronKey, err)
+		}
+		cfg[ScheduleCronKey] = spec
+	}
+	for _, key := range []string{KeepLastKey, KeepDailyKey, KeepWeeklyKey} {
+		v, ok := cfg[key]
+		if !ok {
+			continue
+		}
+		if !isNonNegativeInteger(v) {
+			return fmt.Errorf("%s must be a non-negative integer", key)
+		}
+	}
+	return nil
+}
+
+func isNonNegativeInteger(v any) bool {
+	switch n := v.(type) {
+	case int:
+		return n >= 0
+	case int64:
+		return n >= 0
+	case float64:
+		return n >= 0 && n == math.Trunc(n) && n <= math.MaxInt32
+	}
+	return false
+}
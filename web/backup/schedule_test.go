@@ -0,0 +1,135 @@
+package backup
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func scheduledArchives(times ...time.Time) []ArchiveInfo {
+	archives := make([]ArchiveInfo, 0, len(times))
+	for _, t := range times {
+		archives = append(archives, ArchiveInfo{Name: NewArchiveName(KindScheduled, t), Kind: KindScheduled, Modified: t})
+	}
+	return archives
+}
+
+func expiredNames(archives []ArchiveInfo) map[string]bool {
+	names := make(map[string]bool, len(archives))
+	for _, archive := range archives {
+		names[archive.Name] = true
+	}
+	return names
+}
+
+func TestSelectExpiredKeepsUnionOfPolicies(t *testing.T) {
+	// Newest first: two backups per day over ten days, all at local noon/morning.
+	base := time.Date(2025, 3, 20, 12, 0, 0, 0, time.Local)
+	var times []time.Time
+	for day := 0; day < 10; day++ {
+		times = append(times, base.AddDate(0, 0, -day), base.AddDate(0, 0, -day).Add(-6*time.Hour))
+	}
+	archives := scheduledArchives(times...)
+
+	expired := expiredNames(selectExpired(archives, 3, 4, 2))
+	kept := make([]time.Time, 0)
+	for _, archive := range archives {
+		if !expired[archive.Name] {
+			kept = append(kept, archive.Modified)
+		}
+	}
+
+	want := map[time.Time]bool{
+		times[0]: true, times[1]: true, times[2]: true, // keep-last 3
+		times[4]: true, times[6]: true, // newest of 18th and 17th (daily 4: 20th,19th,18th,17th)
+	}
+	// Weekly 2: newest of the current ISO week (already kept) and newest of the previous week.
+	_, currentWeek := base.ISOWeek()
+	for _, tm := range times {
+		if _, w := tm.ISOWeek(); w != currentWeek {
+			want[tm] = true
+			break
+		}
+	}
+	if len(kept) != len(want) {
+		t.Fatalf("kept %d archives %v, want %d", len(kept), kept, len(want))
+	}
+	for _, tm := range kept {
+		if !want[tm] {
+			t.Fatalf("unexpectedly kept %v", tm)
+		}
+	}
+}
+
+func TestSelectExpiredAlwaysKeepsNewest(t *testing.T) {
+	now := time.Now()
+	archives := scheduledArchives(now, now.Add(-time.Hour))
+	expired := selectExpired(archives, 0, 0, 0)
+	if len(expired) != 1 || expired[0].Name != archives[1].Name {
+		t.Fatalf("expired = %v, want only the older archive", expired)
+	}
+}
+
+func TestValidateScheduleSettings(t *testing.T) {
+	cfg := map[string]any{ScheduleCronKey: " 0 0 3 * * * ", KeepLastKey: float64(5)}
+	if err := ValidateScheduleSettings(cfg); err != nil {
+		t.Fatalf("valid settings rejected: %v", err)
+	}
+	if cfg[ScheduleCronKey] != "0 0 3 * * *" {
+		t.Fatalf("cron spec was not trimmed: %q", cfg[ScheduleCronKey])
+	}
+	for _, bad := range []map[string]any{
+		{ScheduleCronKey: "every day"},
+		{KeepDailyKey: float64(-1)},
+		{KeepWeeklyKey: 1.5},
+		{KeepLastKey: "7"},
+	} {
+		if err := ValidateScheduleSettings(bad); err == nil {
+			t.Fatalf("invalid settings accepted: %v", bad)
+		}
+	}
+}
+
+func TestArchiveStore(t *testing.T) {
+	previous := ArchiveDir
+	ArchiveDir = t.TempDir()
+	t.Cleanup(func() { ArchiveDir = previous })
+
+	older := time.Now().Add(-time.Hour)
+	for name, mtime := range map[string]time.Time{
+		"backup-1.zip":    older,
+		"scheduled-2.zip": time.Now(),
+		".backup-x.tmp":   time.Now(),
+		"notes.txt":       time.Now(),
+	} {
+		path := filepath.Join(ArchiveDir, name)
+		if err := os.WriteFile(path, []byte("zip"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(path, mtime, mtime); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	archives, err := ListArchives()
+	if err != nil {
+		t.Fatalf("ListArchives: %v", err)
+	}
+	if len(archives) != 2 || archives[0].Name != "scheduled-2.zip" || archives[0].Kind != KindScheduled ||
+		archives[1].Kind != KindManual {
+		t.Fatalf("unexpected archives %+v", archives)
+	}
+
+	for _, name := range []string{"../backup-1.zip", "notes.txt", ".backup-x.tmp", ""} {
+		if _, err := ArchivePath(name); err != ErrInvalidArchiveName {
+			t.Fatalf("ArchivePath(%q) error = %v, want ErrInvalidArchiveName", name, err)
+		}
+	}
+	if err := DeleteArchive("backup-1.zip"); err != nil {
+		t.Fatalf("DeleteArchive: %v", err)
+	}
+	if _, err := ArchivePath("backup-1.zip"); !os.IsNotExist(err) {
+		t.Fatalf("deleted archive still resolvable: %v", err)
+	}
+}
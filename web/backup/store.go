@@ -0,0 +1,140 @@
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ArchiveDir 是服务端保存备份归档的目录，Docker 挂载 ./data 后可持久化。
+var ArchiveDir = filepath.Join(".", "data", "backup")
+
+// 归档文件名前缀，区分不同来源的备份。
+const (
+	KindManual     = "manual"      // 管理员下载时生成：backup-*.zip
+	KindScheduled  = "scheduled"   // 定时任务生成：scheduled-*.zip
+	KindUpgrade    = "upgrade"     // 版本升级前自动生成：upgrade-*.zip
+	KindPreRestore = "pre-restore" // 恢复前自动生成：pre-restore-*.zip
+	KindOther      = "other"
+)
+
+var archivePrefixes = []struct {
+	prefix string
+	kind   string
+}{
+	{"backup-", KindManual},
+	{"scheduled-", KindScheduled},
+	{"upgrade-", KindUpgrade},
+	{"pre-restore-", KindPreRestore},
+}
+
+// ErrInvalidArchiveName 表示归档名不是 ArchiveDir 下的单个 .zip/.zip.enc 文件名。
+var ErrInvalidArchiveName = errors.New("invalid backup archive name")
+
+// ArchiveInfo 描述一个已保存的备份归档。
+type ArchiveInfo struct {
+	Name     string    `json:"name"`
+	Kind     string    `json:"kind"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+}
+
+// NewArchiveName 生成带 UTC 时间戳的归档文件名，如 backup-20250101-030000.000000.zip。
+func NewArchiveName(kind string, now time.Time) string {
+	prefix := "backup-"
+	for _, p := range archivePrefixes {
+		if p.kind == kind {
+			prefix = p.prefix
+		}
+	}
+	return prefix + now.UTC().Format("20060102-150405.000000") + ".zip"
+}
+
+// IsArchiveName 判断文件名是否为备份归档（.zip 或加密的 .zip.enc）。
+func IsArchiveName(name string) bool {
+	lower := strings.ToLower(name)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".zip"+EncryptedExt)
+}
+
+func archiveKind(name string) string {
+	for _, p := range archivePrefixes {
+		if strings.HasPrefix(name, p.prefix) {
+			return p.kind
+		}
+	}
+	return KindOther
+}
+
+// ListArchives 按修改时间倒序列出 ArchiveDir 下的全部归档。
+// 目录不存在时返回空列表。
+func ListArchives() ([]ArchiveInfo, error) {
+	entries, err := os.ReadDir(ArchiveDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []ArchiveInfo{}, nil
+		}
+		return nil, err
+	}
+	archives := make([]ArchiveInfo, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !IsArchiveName(name) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		archives = append(archives, ArchiveInfo{
+			Name:     name,
+			Kind:     archiveKind(name),
+			Size:     info.Size(),
+			Modified: info.ModTime().UTC(),
+		})
+	}
+	sortArchivesNewestFirst(archives)
+	return archives, nil
+}
+
+func sortArchivesNewestFirst(archives []ArchiveInfo) {
+	sort.Slice(archives, func(i, j int) bool {
+		if !archives[i].Modified.Equal(archives[j].Modified) {
+			return archives[i].Modified.After(archives[j].Modified)
+		}
+		return archives[i].Name > archives[j].Name
+	})
+}
+
+// ArchivePath 校验归档名并返回其完整路径。名称不能包含路径分隔符，
+// 且必须指向已存在的归档文件。
+func ArchivePath(name string) (string, error) {
+	if name == "" || name != filepath.Base(name) || strings.ContainsAny(name, `/\`) ||
+		strings.HasPrefix(name, ".") || !IsArchiveName(name) {
+		return "", ErrInvalidArchiveName
+	}
+	path := filepath.Join(ArchiveDir, name)
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	if !info.Mode().IsRegular() {
+		return "", ErrInvalidArchiveName
+	}
+	return path, nil
+}
+
+// DeleteArchive 删除一个已保存的归档。
+func DeleteArchive(name string) error {
+	path, err := ArchivePath(name)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return fmt.Errorf("delete backup archive: %w", err)
+	}
+	return nil
+}
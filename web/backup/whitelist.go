@@ -1,4 +1,4 @@
-package admin
+package backup
 
 import (
 	"fmt"
@@ -9,15 +9,15 @@ import (
 // backupWhitelist 定义需要备份的 ./data/ 下文件/目录（相对路径）。
 // 目录项会递归包含其下所有文件。新增需持久化数据时在此追加。
 //
-// 注意：komari.db 不在白名单中——database 通过 DownloadBackup 中的
-// SQLite VACUUM INTO 或 copyFile 单独备份，确保一致性快照。
+// 注意：komari.db 与 metrics.db 不在白名单中——两者由 CreateArchive 单独备份：
+// SQLite 主库使用 VACUUM INTO，MySQL/PostgreSQL 主库导出为 SQLite；metrics.db
+// 仅在 ArchiveOptions.IncludeMetrics 时以 VACUUM INTO 快照写入，确保一致性。
 var backupWhitelist = []string{
 	"favicon.ico",
 	"font.ttf",
 	"theme/",
 	"plugin/",
 	"plguin-data/",
-	"metrics.db",
 }
 
 // copyWhitelistedFiles 将白名单中存在的文件/目录复制到临时目录。
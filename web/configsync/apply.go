@@ -0,0 +1,478 @@
+package configsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/notification"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/utils"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Result 汇总 Apply 的结果。调用方负责与运行时状态相关的后续处理：
+// 清理已删除客户端的连接与指标、重载正在使用的提供者。
+type Result struct {
+	Changes               []Change `json:"changes"`
+	DeletedClients        []string `json:"deleted_clients,omitempty"`
+	ChangedMessageSenders []string `json:"changed_message_senders,omitempty"`
+	ChangedOidcProviders  []string `json:"changed_oidc_providers,omitempty"`
+}
+
+// Apply 执行计划。实体变更在一个事务中完成；设置随后通过 config.SetMany 写入，
+// 以便触发各模块已注册的配置重载。再次应用同一文档不会产生任何变更。
+func (p *Plan) Apply() (*Result, error) {
+	result := &Result{Changes: p.Changes}
+	if len(p.Changes) == 0 {
+		return result, nil
+	}
+	touched := make(map[string]bool)
+	for _, change := range p.Changes {
+		touched[change.Section] = true
+		switch change.Section {
+		case SectionClients:
+			if change.Action == ActionDelete {
+				result.DeletedClients = append(result.DeletedClients, change.Key)
+			}
+		case SectionMessageSenders:
+			result.ChangedMessageSenders = append(result.ChangedMessageSenders, change.Key)
+		case SectionOidcProviders:
+			result.ChangedOidcProviders = append(result.ChangedOidcProviders, change.Key)
+		}
+	}
+
+	var deletedPingTasks []uint
+	err := dbcore.GetDBInstance().Transaction(func(tx *gorm.DB) error {
+		groupIDs, err := p.applyGroups(tx)
+		if err != nil {
+			return fmt.Errorf("apply groups: %w", err)
+		}
+		if err := p.applyClients(tx, groupIDs); err != nil {
+			return fmt.Errorf("apply clients: %w", err)
+		}
+		pingTaskIDs, deleted, err := p.applyPingTasks(tx)
+		if err != nil {
+			return fmt.Errorf("apply ping tasks: %w", err)
+		}
+		deletedPingTasks = deleted
+		loadIDs, err := p.applyLoadNotifications(tx)
+		if err != nil {
+			return fmt.Errorf("apply load notifications: %w", err)
+		}
+		// 分组策略引用 Ping 任务与负载通知，待二者创建后再写入。
+		if err := p.applyGroupPolicies(tx, groupIDs, pingTaskIDs, loadIDs); err != nil {
+			return fmt.Errorf("apply group policies: %w", err)
+		}
+		if err := p.applyOfflineNotifications(tx); err != nil {
+			return fmt.Errorf("apply offline notifications: %w", err)
+		}
+		if err := p.applyTrafficReports(tx); err != nil {
+			return fmt.Errorf("apply traffic reports: %w", err)
+		}
+		if err := applyProviders(tx, p.doc.MessageSenders, p.current.messageSenders, func(p Provider, addition string) any {
+			return &models.MessageSenderProvider{Name: p.Name, Addition: addition}
+		}, &models.MessageSenderProvider{}); err != nil {
+			return fmt.Errorf("apply message senders: %w", err)
+		}
+		if err := applyProviders(tx, p.doc.OidcProviders, p.current.oidcProviders, func(p Provider, addition string) any {
+			return &models.OidcProvider{Name: p.Name, Addition: addition}
+		}, &models.OidcProvider{}); err != nil {
+			return fmt.Errorf("apply OIDC providers: %w", err)
+		}
+		// 客户端最后删除，确保先移除引用它的通知配置。
+		if p.doc.Clients != nil {
+			for _, uuid := range result.DeletedClients {
+				if err := tx.Delete(&models.Client{}, "uuid = ?", uuid).Error; err != nil {
+					return fmt.Errorf("delete client %s: %w", uuid, err)
+				}
+			}
+		}
+		return p.deleteGroups(tx)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if len(p.settings) > 0 {
+		if err := config.SetMany(p.settings); err != nil {
+			return nil, fmt.Errorf("apply settings: %w", err)
+		}
+	}
+	if len(deletedPingTasks) > 0 {
+		if err := tasks.DeletePingRecords(deletedPingTasks); err != nil {
+			logger.Warnf("configsync", "Failed to delete records of removed ping tasks: %v", err)
+		}
+	}
+	if touched[SectionPingTasks] || touched[SectionClients] {
+		if err := tasks.ReloadPingSchedule(); err != nil {
+			logger.Warnf("configsync", "Failed to reload ping schedule: %v", err)
+		}
+	}
+	if touched[SectionLoadNotifications] {
+		if err := notification.ReloadLoadNotificationSchedule(); err != nil {
+			logger.Warnf("configsync", "Failed to reload load notification schedule: %v", err)
+		}
+	}
+	return result, nil
+}
+
+// applyGroups 创建或更新分组及其上级关系，返回应用后的分组名称 → ID。
+// 策略由 applyGroupPolicies 写入，多余的分组由 deleteGroups 在客户端处理完后删除。
+func (p *Plan) applyGroups(tx *gorm.DB) (map[string]uint, error) {
+	ids := make(map[string]uint, len(p.current.groups))
+	for _, g := range p.current.groups {
+		ids[g.Name] = g.id
+	}
+	if p.doc.Groups == nil {
+		return ids, nil
+	}
+	changed := p.changedKeys(SectionGroups)
+	for _, g := range p.doc.Groups {
+		if !changed[g.Name] {
+			continue
+		}
+		fields := map[string]any{"description": g.Description, "sort_order": g.SortOrder}
+		if id, ok := ids[g.Name]; ok {
+			if err := tx.Model(&models.ClientGroup{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		row := models.ClientGroup{Name: g.Name, Description: g.Description, SortOrder: g.SortOrder}
+		if err := tx.Select("*").Omit("id").Create(&row).Error; err != nil {
+			return nil, err
+		}
+		ids[g.Name] = row.Id
+	}
+	// 上级可能是本次新建的分组，全部分组存在后再设置。
+	for _, g := range p.doc.Groups {
+		if !changed[g.Name] {
+			continue
+		}
+		var parent *uint
+		if g.Parent != "" {
+			id := ids[g.Parent]
+			parent = &id
+		}
+		if err := tx.Model(&models.ClientGroup{}).Where("id = ?", ids[g.Name]).Update("parent_id", parent).Error; err != nil {
+			return nil, err
+		}
+	}
+	return ids, nil
+}
+
+// applyGroupPolicies 把有变更的分组策略中的名称解析为 ID 后写入。
+// 与客户端相同，文档中的成员关系已是物化后的结果，这里不再向成员下发策略。
+func (p *Plan) applyGroupPolicies(tx *gorm.DB, groupIDs, pingTaskIDs, loadIDs map[string]uint) error {
+	if p.doc.Groups == nil {
+		return nil
+	}
+	changed := p.changedKeys(SectionGroups)
+	for _, g := range p.doc.Groups {
+		if !changed[g.Name] {
+			continue
+		}
+		policy := models.GroupPolicy{
+			Offline:       g.Policy.Offline,
+			TrafficReport: g.Policy.TrafficReport,
+			Billing:       g.Policy.Billing,
+		}
+		for _, name := range g.Policy.PingTasks {
+			policy.PingTasks = append(policy.PingTasks, pingTaskIDs[name])
+		}
+		for _, name := range g.Policy.LoadNotifications {
+			policy.LoadNotifications = append(policy.LoadNotifications, loadIDs[name])
+		}
+		if g.Policy.AgentProfile != "" {
+			id := p.current.agentProfiles[g.Policy.AgentProfile]
+			policy.AgentProfile = &id
+		}
+		if err := tx.Model(&models.ClientGroup{}).Where("id = ?", groupIDs[g.Name]).Update("policy", policy).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleteGroups 删除文档中没有的分组。计划已确认应用后没有客户端或分组引用它们。
+func (p *Plan) deleteGroups(tx *gorm.DB) error {
+	if p.doc.Groups == nil {
+		return nil
+	}
+	wanted := make(map[string]struct{}, len(p.doc.Groups))
+	for _, g := range p.doc.Groups {
+		wanted[g.Name] = struct{}{}
+	}
+	for _, g := range p.current.groups {
+		if _, ok := wanted[g.Name]; !ok {
+			if err := tx.Delete(&models.ClientGroup{}, g.id).Error; err != nil {
+				return fmt.Errorf("delete group %s: %w", g.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (p *Plan) applyClients(tx *gorm.DB, groupIDs map[string]uint) error {
+	if p.doc.Clients == nil {
+		return nil
+	}
+	existing := make(map[string]struct{}, len(p.current.clients))
+	for _, c := range p.current.clients {
+		existing[c.UUID] = struct{}{}
+	}
+	now := time.Now().UTC()
+	for _, c := range p.doc.Clients {
+		fields := map[string]any{
+			"name":               c.Name,
+			"group":              c.Group,
+			"tags":               strings.Join(c.Tags, ";"),
+			"weight":             c.Weight,
+			"hidden":             c.Hidden,
+			"remark":             c.Remark,
+			"public_remark":      c.PublicRemark,
+			"price":              c.Price,
+			"billing_cycle":      c.BillingCycle,
+			"auto_renewal":       c.AutoRenewal,
+			"currency":           c.Currency,
+			"expired_at":         c.ExpiredAt,
+			"traffic_limit":      c.TrafficLimit,
+			"traffic_limit_type": c.TrafficLimitType,
+			"updated_at":         now,
+		}
+		// 文档中的 Ping 任务与通知配置已是物化后的结果，这里只关联分组，不再下发分组策略。
+		var groupID *uint
+		if c.Group != "" {
+			id := groupIDs[c.Group]
+			groupID = &id
+		}
+		fields["group_id"] = groupID
+		if _, ok := existing[c.UUID]; ok {
+			if err := tx.Model(&models.Client{}).Where("uuid = ?", c.UUID).Updates(fields).Error; err != nil {
+				return err
+			}
+			continue
+		}
+		// 新客户端生成新的 Token（只保存哈希），Agent 需要在管理面板中轮换令牌取得明文后接入。
+		if err := tx.Create(&models.Client{
+			UUID:      c.UUID,
+			Token:     models.HashClientToken(utils.GenerateToken()),
+			CreatedAt: now,
+			UpdatedAt: now,
+		}).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&models.Client{}).Where("uuid = ?", c.UUID).Updates(fields).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// changedKeys 返回计划中 section 分区有变更的实体标识。
+func (p *Plan) changedKeys(section string) map[string]bool {
+	keys := make(map[string]bool)
+	for _, change := range p.Changes {
+		if change.Section == section {
+			keys[change.Key] = true
+		}
+	}
+	return keys
+}
+
+// applyPingTasks 按计划时读取的当前配置找到对应的行（同名任务以导出时的唯一名称区分），
+// 返回应用后的任务名称 → ID，以及被删除的任务 id（提交后再清理其历史记录）。
+func (p *Plan) applyPingTasks(tx *gorm.DB) (map[string]uint, []uint, error) {
+	ids := make(map[string]uint, len(p.current.pingTasks))
+	for _, task := range p.current.pingTasks {
+		ids[task.Name] = task.id
+	}
+	if p.doc.PingTasks == nil {
+		return ids, nil, nil
+	}
+	changed := p.changedKeys(SectionPingTasks)
+	wanted := make(map[string]struct{}, len(p.doc.PingTasks))
+	for _, task := range p.doc.PingTasks {
+		wanted[task.Name] = struct{}{}
+		if !changed[task.Name] {
+			continue
+		}
+		fields := map[string]any{
+			"name":        task.Name,
+			"type":        task.Type,
+			"target":      task.Target,
+			"interval":    task.Interval,
+			"weight":      task.Weight,
+			"all_clients": task.DefaultOn,
+			"clients":     models.StringArray(task.Clients),
+			"selector":    task.Selector,
+		}
+		if id, ok := ids[task.Name]; ok {
+			if err := tx.Model(&models.PingTask{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+		row := models.PingTask{Name: task.Name, Type: task.Type, Target: task.Target, Clients: models.StringArray(task.Clients)}
+		if err := tx.Create(&row).Error; err != nil {
+			return nil, nil, err
+		}
+		ids[task.Name] = row.Id
+		if err := tx.Model(&models.PingTask{}).Where("id = ?", row.Id).Updates(fields).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	var deleted []uint
+	for _, task := range p.current.pingTasks {
+		if _, ok := wanted[task.Name]; !ok {
+			deleted = append(deleted, task.id)
+		}
+	}
+	if len(deleted) > 0 {
+		if err := tx.Where("id IN ?", deleted).Delete(&models.PingTask{}).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return ids, deleted, nil
+}
+
+// applyLoadNotifications 返回应用后的规则名称 → ID。
+func (p *Plan) applyLoadNotifications(tx *gorm.DB) (map[string]uint, error) {
+	ids := make(map[string]uint, len(p.current.loadNotifications))
+	for _, n := range p.current.loadNotifications {
+		ids[n.Name] = n.id
+	}
+	if p.doc.LoadNotifications == nil {
+		return ids, nil
+	}
+	changed := p.changedKeys(SectionLoadNotifications)
+	wanted := make(map[string]struct{}, len(p.doc.LoadNotifications))
+	for _, n := range p.doc.LoadNotifications {
+		wanted[n.Name] = struct{}{}
+		if !changed[n.Name] {
+			continue
+		}
+		fields := map[string]any{
+			"name":      n.Name,
+			"metric":    n.Metric,
+			"threshold": n.Threshold,
+			"ratio":     n.Ratio,
+			"interval":  n.Interval,
+			"clients":   models.StringArray(n.Clients),
+			"selector":  n.Selector,
+		}
+		if id, ok := ids[n.Name]; ok {
+			if err := tx.Model(&models.LoadNotification{}).Where("id = ?", id).Updates(fields).Error; err != nil {
+				return nil, err
+			}
+			continue
+		}
+		row := models.LoadNotification{
+			Name: n.Name, Metric: n.Metric, Threshold: n.Threshold, Ratio: n.Ratio, Interval: n.Interval,
+			Clients: models.StringArray(n.Clients), Selector: n.Selector,
+		}
+		if err := tx.Create(&row).Error; err != nil {
+			return nil, err
+		}
+		ids[n.Name] = row.Id
+	}
+	for _, n := range p.current.loadNotifications {
+		if _, ok := wanted[n.Name]; !ok {
+			if err := tx.Delete(&models.LoadNotification{}, n.id).Error; err != nil {
+				return nil, err
+			}
+		}
+	}
+	return ids, nil
+}
+
+func (p *Plan) applyOfflineNotifications(tx *gorm.DB) error {
+	if p.doc.OfflineNotifications == nil {
+		return nil
+	}
+	wanted := make([]string, 0, len(p.doc.OfflineNotifications))
+	rows := make([]models.OfflineNotification, 0, len(p.doc.OfflineNotifications))
+	for _, n := range p.doc.OfflineNotifications {
+		wanted = append(wanted, n.Client)
+		rows = append(rows, models.OfflineNotification{Client: n.Client, Enable: n.Enable, GracePeriod: n.GracePeriod})
+	}
+	if err := deleteExcept(tx, &models.OfflineNotification{}, wanted); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Model(&models.OfflineNotification{}).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "client"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enable", "grace_period"}),
+		}).
+		Select("client", "enable", "grace_period").Create(&rows).Error
+}
+
+func (p *Plan) applyTrafficReports(tx *gorm.DB) error {
+	if p.doc.TrafficReports == nil {
+		return nil
+	}
+	wanted := make([]string, 0, len(p.doc.TrafficReports))
+	rows := make([]models.TrafficReportNotification, 0, len(p.doc.TrafficReports))
+	for _, r := range p.doc.TrafficReports {
+		wanted = append(wanted, r.Client)
+		rows = append(rows, models.TrafficReportNotification{
+			Client: r.Client, Enable: r.Enable, Daily: r.Daily, Weekly: r.Weekly, Monthly: r.Monthly,
+		})
+	}
+	if err := deleteExcept(tx, &models.TrafficReportNotification{}, wanted); err != nil {
+		return err
+	}
+	if len(rows) == 0 {
+		return nil
+	}
+	return tx.Model(&models.TrafficReportNotification{}).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "client"}},
+			DoUpdates: clause.AssignmentColumns([]string{"enable", "daily", "weekly", "monthly"}),
+		}).
+		Select("client", "enable", "daily", "weekly", "monthly").Create(&rows).Error
+}
+
+// deleteExcept 删除 client 不在 keep 中的行。
+func deleteExcept(tx *gorm.DB, model any, keep []string) error {
+	query := tx.Where("1 = 1")
+	if len(keep) > 0 {
+		query = tx.Where("client NOT IN ?", keep)
+	}
+	return query.Delete(model).Error
+}
+
+func applyProviders(tx *gorm.DB, desired, current []Provider, build func(Provider, string) any, model any) error {
+	if desired == nil {
+		return nil
+	}
+	wanted := make(map[string]struct{}, len(desired))
+	for _, provider := range desired {
+		wanted[provider.Name] = struct{}{}
+		addition, err := json.Marshal(provider.Config)
+		if err != nil {
+			return err
+		}
+		if err := tx.Save(build(provider, string(addition))).Error; err != nil {
+			return err
+		}
+	}
+	for _, provider := range current {
+		if _, ok := wanted[provider.Name]; !ok {
+			if err := tx.Where("name = ?", provider.Name).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,376 @@
+package configsync
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/dbcore/dbtest"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/notification"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/internal/config"
+	_ "github.com/komari-monitor/komari/utils/messageSender/bark"
+	_ "github.com/komari-monitor/komari/utils/messageSender/telegram"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	db := dbtest.Open(t, "configsync_roundtrip")
+
+	uuid, token, err := clients.CreateClientWithName("alpha")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	if _, err := tasks.AddPingTask([]string{uuid}, "", false, "gateway", "10.0.0.1", "icmp", 60); err != nil {
+		t.Fatalf("add ping task: %v", err)
+	}
+	if err := config.Set("api_key", "super-secret-api-key"); err != nil {
+		t.Fatal(err)
+	}
+	sender := models.MessageSenderProvider{Name: "telegram", Addition: `{"bot_token":"bot-secret","chat_id":"42","endpoint":"https://api.telegram.org/bot"}`}
+	if err := db.Create(&sender).Error; err != nil {
+		t.Fatalf("create sender: %v", err)
+	}
+
+	doc, err := Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	for _, format := range []string{FormatYAML, FormatJSON} {
+		content, err := Marshal(doc, format)
+		if err != nil {
+			t.Fatalf("Marshal %s: %v", format, err)
+		}
+		for _, secret := range []string{token, "super-secret-api-key", "bot-secret"} {
+			if strings.Contains(string(content), secret) {
+				t.Fatalf("%s export leaks secret %q", format, secret)
+			}
+		}
+		parsed, err := Parse(content)
+		if err != nil {
+			t.Fatalf("Parse %s: %v", format, err)
+		}
+		plan, err := BuildPlan(parsed)
+		if err != nil {
+			t.Fatalf("BuildPlan %s: %v", format, err)
+		}
+		if len(plan.Changes) != 0 {
+			t.Fatalf("re-importing the %s export should be a no-op, got %+v", format, plan.Changes)
+		}
+	}
+
+	// 修改：重命名客户端、删除全部 Ping 任务、新增负载通知。
+	doc.Clients[0].Name = "alpha-renamed"
+	doc.PingTasks = []PingTask{}
+	doc.LoadNotifications = []LoadNotification{{Name: "cpu-high", Metric: "cpu", Threshold: 90, Ratio: 0.8, Interval: 15, Clients: []string{uuid}}}
+	doc.OfflineNotifications = nil // 缺省分区保持不变
+	plan, err := BuildPlan(doc)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	want := map[string]string{
+		SectionClients + "/" + uuid:            ActionUpdate,
+		SectionPingTasks + "/gateway":          ActionDelete,
+		SectionLoadNotifications + "/cpu-high": ActionCreate,
+	}
+	if len(plan.Changes) != len(want) {
+		t.Fatalf("changes = %+v, want %v", plan.Changes, want)
+	}
+	for _, c := range plan.Changes {
+		if want[c.Section+"/"+c.Key] != c.Action {
+			t.Fatalf("unexpected change %+v", c)
+		}
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	client, err := clients.GetClientByUUID(uuid)
+	if err != nil || client.Name != "alpha-renamed" || client.Token != models.HashClientToken(token) {
+		t.Fatalf("client after apply = %+v, %v", client, err)
+	}
+	if pings, _ := tasks.GetAllPingTasks(); len(pings) != 0 {
+		t.Fatalf("ping tasks after apply = %+v", pings)
+	}
+	if key, _ := config.GetAs[string]("api_key"); key != "super-secret-api-key" {
+		t.Fatalf("redacted api_key was overwritten with %q", key)
+	}
+	var stored models.MessageSenderProvider
+	if err := db.Where("name = ?", "telegram").First(&stored).Error; err != nil || !strings.Contains(stored.Addition, "bot-secret") {
+		t.Fatalf("redacted provider secret was not kept: %+v, %v", stored, err)
+	}
+
+	// 再次应用同一文档应无变更。
+	plan, err = BuildPlan(doc)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Fatalf("second apply should be a no-op, got %+v", plan.Changes)
+	}
+}
+
+func TestBuildPlanRejectsInvalidDocuments(t *testing.T) {
+	dbtest.Open(t, "configsync_invalid")
+
+	if _, err := Parse([]byte("version: 99\n")); err == nil {
+		t.Fatal("unsupported version should be rejected")
+	}
+	if _, err := Parse([]byte("version: 1\nunknown_section: []\n")); err == nil {
+		t.Fatal("unknown fields should be rejected")
+	}
+	doc, err := Parse([]byte("version: 1\nping_tasks:\n  - name: p\n    type: icmp\n    target: 1.1.1.1\n    interval: 60\n    clients: [missing-client]\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := BuildPlan(doc); err == nil {
+		t.Fatal("ping task referencing an unknown client should be rejected")
+	}
+	doc, err = Parse([]byte("version: 1\nmessage_senders:\n  - name: bark\n    config:\n      device_key: <redacted>\n"))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if _, err := BuildPlan(doc); err == nil {
+		t.Fatal("redacted secret without a current value should be rejected")
+	}
+}
+
+func TestRoundTripWithDuplicateNames(t *testing.T) {
+	dbtest.Open(t, "configsync_duplicates")
+
+	uuid, _, err := clients.CreateClientWithName("beta")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	first, err := tasks.AddPingTask([]string{uuid}, "", false, "dns", "1.1.1.1", "icmp", 60)
+	if err != nil {
+		t.Fatalf("add ping task: %v", err)
+	}
+	second, err := tasks.AddPingTask([]string{uuid}, "", false, "dns", "8.8.8.8", "icmp", 60)
+	if err != nil {
+		t.Fatalf("add ping task: %v", err)
+	}
+	for _, threshold := range []float32{80, 95} {
+		if _, err := notification.AddLoadNotification([]string{uuid}, "", "cpu", "cpu", threshold, 0.5, 10); err != nil {
+			t.Fatalf("add load notification: %v", err)
+		}
+	}
+
+	doc, err := Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	content, err := Marshal(doc, FormatYAML)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	parsed, err := Parse(content)
+	if err != nil {
+		t.Fatalf("exported document with duplicate names should parse: %v", err)
+	}
+	// 同一进程中的测试共用数据库，只统计本测试创建的实体。
+	named := func(names []string, name string) int {
+		n := 0
+		for _, v := range names {
+			if v == name || strings.HasPrefix(v, name+" #") {
+				n++
+			}
+		}
+		return n
+	}
+	var pingNames, loadNames []string
+	for _, task := range parsed.PingTasks {
+		pingNames = append(pingNames, task.Name)
+	}
+	for _, n := range parsed.LoadNotifications {
+		loadNames = append(loadNames, n.Name)
+	}
+	if named(pingNames, "dns") != 2 || named(loadNames, "cpu") != 2 {
+		t.Fatalf("duplicates were collapsed: %+v %+v", parsed.PingTasks, parsed.LoadNotifications)
+	}
+	plan, err := BuildPlan(parsed)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Fatalf("re-importing should be a no-op, got %+v", plan.Changes)
+	}
+
+	// 修改第二个同名任务只影响该行，第一个任务保持不变。
+	renamed := fmt.Sprintf("dns #%d", second)
+	for i := range parsed.PingTasks {
+		if parsed.PingTasks[i].Name == renamed {
+			parsed.PingTasks[i].Target = "9.9.9.9"
+		}
+	}
+	plan, err = BuildPlan(parsed)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Changes) != 1 || plan.Changes[0].Key != renamed || plan.Changes[0].Action != ActionUpdate {
+		t.Fatalf("changes = %+v", plan.Changes)
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	pings, _ := tasks.GetAllPingTasks()
+	targets := map[uint]string{}
+	for _, p := range pings {
+		targets[p.Id] = p.Target
+	}
+	if targets[first] != "1.1.1.1" || targets[second] != "9.9.9.9" {
+		t.Fatalf("ping tasks after apply = %+v", pings)
+	}
+	loads, _ := notification.GetAllLoadNotifications()
+	loadNames = loadNames[:0]
+	for _, n := range loads {
+		loadNames = append(loadNames, n.Name)
+	}
+	if named(loadNames, "cpu") != 2 {
+		t.Fatalf("load notifications after apply = %+v", loads)
+	}
+}
+
+func TestGroupsRoundTrip(t *testing.T) {
+	dbtest.Open(t, "configsync_groups")
+
+	uuid, _, err := clients.CreateClientWithName("gamma")
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	taskID, err := tasks.AddPingTask([]string{uuid}, "", false, "edge-probe", "1.0.0.1", "icmp", 60)
+	if err != nil {
+		t.Fatalf("add ping task: %v", err)
+	}
+	region := models.ClientGroup{Name: "region-eu"}
+	if err := clientgroups.CreateGroup(&region); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	grace := 300
+	site := models.ClientGroup{Name: "site-fra", ParentID: &region.Id, Policy: models.GroupPolicy{
+		PingTasks: []uint{taskID},
+		Offline:   &models.OfflineNotificationPolicy{GracePeriod: &grace},
+	}}
+	if err := clientgroups.CreateGroup(&site); err != nil {
+		t.Fatalf("create group: %v", err)
+	}
+	if err := clientgroups.SetClientGroup(uuid, &site.Id); err != nil {
+		t.Fatalf("set client group: %v", err)
+	}
+
+	doc, err := Export()
+	if err != nil {
+		t.Fatalf("Export: %v", err)
+	}
+	content, err := Marshal(doc, FormatYAML)
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	parsed, err := Parse(content)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	var exported *Group
+	for i := range parsed.Groups {
+		if parsed.Groups[i].Name == "site-fra" {
+			exported = &parsed.Groups[i]
+		}
+	}
+	if exported == nil || exported.Parent != "region-eu" || len(exported.Policy.PingTasks) != 1 || exported.Policy.PingTasks[0] != "edge-probe" {
+		t.Fatalf("exported group = %+v", exported)
+	}
+	plan, err := BuildPlan(parsed)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if len(plan.Changes) != 0 {
+		t.Fatalf("re-importing should be a no-op, got %+v", plan.Changes)
+	}
+
+	// 新建的分组与其上级、引用的新 Ping 任务在同一次应用中创建，客户端改挂到新分组。
+	parsed.PingTasks = append(parsed.PingTasks, PingTask{Name: "core-probe", Type: "icmp", Target: "9.9.9.9", Interval: 30, Clients: []string{uuid}})
+	parsed.Groups = append(parsed.Groups,
+		Group{Name: "rack-2", Parent: "site-ams", Policy: GroupPolicy{PingTasks: []string{"core-probe"}}},
+		Group{Name: "site-ams", Parent: "region-eu"},
+	)
+	for i := range parsed.Clients {
+		if parsed.Clients[i].UUID == uuid {
+			parsed.Clients[i].Group = "rack-2"
+		}
+	}
+	kept := parsed.Groups[:0]
+	for _, g := range parsed.Groups {
+		if g.Name != "site-fra" {
+			kept = append(kept, g)
+		}
+	}
+	parsed.Groups = kept
+	plan, err = BuildPlan(parsed)
+	if err != nil {
+		t.Fatalf("BuildPlan: %v", err)
+	}
+	if _, err := plan.Apply(); err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+
+	groups, _ := clientgroups.ListGroups()
+	byName := map[string]models.ClientGroup{}
+	for _, g := range groups {
+		byName[g.Name] = g
+	}
+	rack, ams := byName["rack-2"], byName["site-ams"]
+	if _, ok := byName["site-fra"]; ok {
+		t.Fatal("group missing from the document should be deleted")
+	}
+	if ams.ParentID == nil || *ams.ParentID != region.Id || rack.ParentID == nil || *rack.ParentID != ams.Id {
+		t.Fatalf("parents after apply: site-ams %+v, rack-2 %+v", ams, rack)
+	}
+	pings, _ := tasks.GetAllPingTasks()
+	var coreID uint
+	for _, p := range pings {
+		if p.Name == "core-probe" {
+			coreID = p.Id
+		}
+	}
+	if len(rack.Policy.PingTasks) != 1 || rack.Policy.PingTasks[0] != coreID {
+		t.Fatalf("rack-2 policy = %+v, want ping task %d", rack.Policy, coreID)
+	}
+	client, err := clients.GetClientByUUID(uuid)
+	if err != nil || client.Group != "rack-2" || client.GroupID == nil || *client.GroupID != rack.Id {
+		t.Fatalf("client after apply = %+v, %v", client, err)
+	}
+	plan, err = BuildPlan(parsed)
+	if err != nil || len(plan.Changes) != 0 {
+		t.Fatalf("second apply should be a no-op, got %+v, %v", plan, err)
+	}
+
+	// 客户端只能引用存在的分组；分组不能成环。
+	for i := range parsed.Clients {
+		if parsed.Clients[i].UUID == uuid {
+			parsed.Clients[i].Group = "missing-group"
+		}
+	}
+	if _, err := BuildPlan(parsed); err == nil || !strings.Contains(err.Error(), "missing-group") {
+		t.Fatalf("unknown client group should be rejected, got %v", err)
+	}
+	if _, err := Parse([]byte("version: 1\ngroups:\n  - name: a\n    parent: b\n  - name: b\n    parent: a\n")); err == nil {
+		t.Fatal("group cycle should be rejected")
+	}
+}
+
+func TestResolveSecretEnvPrefix(t *testing.T) {
+	t.Setenv("KOMARI_SECRET_BOT_TOKEN", "from-env")
+	t.Setenv("DATABASE_PASSWORD", "do-not-read")
+
+	if v, err := resolveSecret("${env:KOMARI_SECRET_BOT_TOKEN}", nil, false); err != nil || v != "from-env" {
+		t.Fatalf("resolveSecret = %v, %v", v, err)
+	}
+	if v, err := resolveSecret("${env:DATABASE_PASSWORD}", nil, false); err == nil || v != nil {
+		t.Fatalf("variables without the %s prefix should be rejected, got %v", EnvSecretPrefix, v)
+	}
+	if _, err := resolveSecret("${env:KOMARI_SECRET_MISSING}", nil, false); err == nil {
+		t.Fatal("unset variables should be rejected")
+	}
+}
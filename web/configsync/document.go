@@ -0,0 +1,327 @@
+// Package configsync exports Komari's logical configuration as one versioned
+// YAML/JSON document and applies such documents back idempotently
+// (config-as-code).
+//
+// Sections that are absent (or null) in an imported document are left
+// untouched; a present section is authoritative, so entities missing from it
+// are deleted. Settings are merged key by key and never deleted.
+package configsync
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/selector"
+	"gopkg.in/yaml.v3"
+)
+
+// DocumentVersion 是当前导出文档的格式版本。
+const DocumentVersion = 1
+
+// 文档格式
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// maxDocumentSize 限制导入文档大小。
+const maxDocumentSize = 8 << 20
+
+// Document 是完整的声明式配置。
+type Document struct {
+	Version    int        `json:"version"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+
+	Theme    *string        `json:"theme,omitempty"`
+	Settings map[string]any `json:"settings,omitempty"`
+
+	Groups               []Group               `json:"groups"`
+	Clients              []Client              `json:"clients"`
+	PingTasks            []PingTask            `json:"ping_tasks"`
+	LoadNotifications    []LoadNotification    `json:"load_notifications"`
+	OfflineNotifications []OfflineNotification `json:"offline_notifications"`
+	TrafficReports       []TrafficReport       `json:"traffic_reports"`
+	MessageSenders       []Provider            `json:"message_senders"`
+	OidcProviders        []Provider            `json:"oidc_providers"`
+}
+
+// Group 以名称标识，Parent 为上级分组的名称。
+type Group struct {
+	id uint // 对应的数据库行，仅用于当前配置
+
+	Name        string      `json:"name"`
+	Parent      string      `json:"parent"`
+	Description string      `json:"description"`
+	SortOrder   int         `json:"sort_order"`
+	Policy      GroupPolicy `json:"policy"`
+}
+
+// GroupPolicy 对应 models.GroupPolicy。Ping 任务与负载通知以文档中的名称引用；
+// Agent 配置模板不在文档中，以名称引用服务端已有的模板。
+type GroupPolicy struct {
+	PingTasks         []string                          `json:"ping_tasks,omitempty"`
+	LoadNotifications []string                          `json:"load_notifications,omitempty"`
+	Offline           *models.OfflineNotificationPolicy `json:"offline_notification,omitempty"`
+	TrafficReport     *models.TrafficReportPolicy       `json:"traffic_report,omitempty"`
+	Billing           *models.BillingPolicy             `json:"billing,omitempty"`
+	AgentProfile      string                            `json:"agent_profile,omitempty"`
+}
+
+// Client 是客户端的可配置部分。Token 与 Agent 上报的硬件信息不导出。
+// Group 引用 groups 分区中的分组名称。
+type Client struct {
+	UUID             string     `json:"uuid"`
+	Name             string     `json:"name"`
+	Group            string     `json:"group"`
+	Tags             []string   `json:"tags"`
+	Weight           int        `json:"weight"`
+	Hidden           bool       `json:"hidden"`
+	Remark           string     `json:"remark"`
+	PublicRemark     string     `json:"public_remark"`
+	Price            float64    `json:"price"`
+	BillingCycle     int        `json:"billing_cycle"`
+	AutoRenewal      bool       `json:"auto_renewal"`
+	Currency         string     `json:"currency"`
+	ExpiredAt        *time.Time `json:"expired_at"`
+	TrafficLimit     int64      `json:"traffic_limit"`
+	TrafficLimitType string     `json:"traffic_limit_type"`
+}
+
+// PingTask 以名称标识。服务端已有多个同名任务时，除 id 最小的一个外，
+// 导出的名称附加 " #<id>" 以保持唯一（见 uniqueName）。
+type PingTask struct {
+	id uint // 对应的数据库行，仅用于当前配置
+
+	Name      string   `json:"name"`
+	Type      string   `json:"type"`
+	Target    string   `json:"target"`
+	Interval  int      `json:"interval"`
+	Weight    int      `json:"weight"`
+	DefaultOn bool     `json:"default_on"`
+	Clients   []string `json:"clients"`
+	Selector  string   `json:"selector,omitempty"`
+}
+
+// LoadNotification 以名称标识，同名时的处理与 PingTask 相同。
+type LoadNotification struct {
+	id uint // 对应的数据库行，仅用于当前配置
+
+	Name      string   `json:"name"`
+	Metric    string   `json:"metric"`
+	Threshold float32  `json:"threshold"`
+	Ratio     float32  `json:"ratio"`
+	Interval  int      `json:"interval"`
+	Clients   []string `json:"clients"`
+	Selector  string   `json:"selector,omitempty"`
+}
+
+// OfflineNotification 以客户端 UUID 标识。
+type OfflineNotification struct {
+	Client      string `json:"client"`
+	Enable      bool   `json:"enable"`
+	GracePeriod int    `json:"grace_period"`
+}
+
+// TrafficReport 以客户端 UUID 标识。
+type TrafficReport struct {
+	Client  string `json:"client"`
+	Enable  bool   `json:"enable"`
+	Daily   bool   `json:"daily"`
+	Weekly  bool   `json:"weekly"`
+	Monthly bool   `json:"monthly"`
+}
+
+// Provider 是消息发送器或 OIDC 提供者的配置，Config 为展开后的 addition。
+// 敏感字段导出为 RedactedValue，或可写成 "${env:KOMARI_SECRET_NAME}" 引用服务端环境变量（见 EnvSecretPrefix）。
+type Provider struct {
+	Name   string         `json:"name"`
+	Config map[string]any `json:"config"`
+}
+
+// Parse 解析 YAML 或 JSON 文档（JSON 是 YAML 的子集）。
+func Parse(content []byte) (*Document, error) {
+	if len(content) > maxDocumentSize {
+		return nil, fmt.Errorf("config document exceeds %d bytes", maxDocumentSize)
+	}
+	if len(bytes.TrimSpace(content)) == 0 {
+		return nil, errors.New("config document is empty")
+	}
+	var generic any
+	if err := yaml.Unmarshal(content, &generic); err != nil {
+		return nil, fmt.Errorf("parse config document: %w", err)
+	}
+	if _, ok := generic.(map[string]any); !ok {
+		return nil, errors.New("config document must be a mapping")
+	}
+	// 经由 JSON 转换，使 YAML 与 JSON 共用同一套 json tag。
+	raw, err := json.Marshal(generic)
+	if err != nil {
+		return nil, fmt.Errorf("parse config document: %w", err)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	var doc Document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("parse config document: %w", err)
+	}
+	if doc.Version != DocumentVersion {
+		return nil, fmt.Errorf("unsupported config document version %d (expected %d)", doc.Version, DocumentVersion)
+	}
+	if err := doc.validate(); err != nil {
+		return nil, err
+	}
+	return &doc, nil
+}
+
+// Marshal 按 format 序列化文档。
+func Marshal(doc *Document, format string) ([]byte, error) {
+	raw, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(format) {
+	case FormatJSON:
+		return append(raw, '\n'), nil
+	case FormatYAML, "yml", "":
+		// 解析为 yaml.Node 以保留字段顺序，再清除 JSON 的流式/引号风格。
+		var node yaml.Node
+		if err := yaml.Unmarshal(raw, &node); err != nil {
+			return nil, err
+		}
+		resetStyle(&node)
+		var buf bytes.Buffer
+		encoder := yaml.NewEncoder(&buf)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(&node); err != nil {
+			return nil, err
+		}
+		if err := encoder.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q", format)
+	}
+}
+
+func resetStyle(node *yaml.Node) {
+	node.Style = 0
+	if node.Kind == yaml.ScalarNode && strings.Contains(node.Value, "\n") {
+		node.Style = yaml.LiteralStyle
+	}
+	for _, child := range node.Content {
+		resetStyle(child)
+	}
+}
+
+// validate 检查文档内部一致性：标识不能为空或重复。
+// 与数据库相关的引用检查在生成计划时进行。
+func (doc *Document) validate() error {
+	var errs []error
+	checkKeys := func(section string, n int, key func(int) string) {
+		seen := make(map[string]struct{}, n)
+		for i := 0; i < n; i++ {
+			k := key(i)
+			if strings.TrimSpace(k) == "" {
+				errs = append(errs, fmt.Errorf("%s[%d]: identifier is required", section, i))
+				continue
+			}
+			if _, dup := seen[k]; dup {
+				errs = append(errs, fmt.Errorf("%s[%d]: duplicate identifier %q", section, i, k))
+			}
+			seen[k] = struct{}{}
+		}
+	}
+	checkKeys("groups", len(doc.Groups), func(i int) string { return doc.Groups[i].Name })
+	checkKeys("clients", len(doc.Clients), func(i int) string { return doc.Clients[i].UUID })
+	checkKeys("ping_tasks", len(doc.PingTasks), func(i int) string { return doc.PingTasks[i].Name })
+	checkKeys("load_notifications", len(doc.LoadNotifications), func(i int) string { return doc.LoadNotifications[i].Name })
+	checkKeys("offline_notifications", len(doc.OfflineNotifications), func(i int) string { return doc.OfflineNotifications[i].Client })
+	checkKeys("traffic_reports", len(doc.TrafficReports), func(i int) string { return doc.TrafficReports[i].Client })
+	checkKeys("message_senders", len(doc.MessageSenders), func(i int) string { return doc.MessageSenders[i].Name })
+	checkKeys("oidc_providers", len(doc.OidcProviders), func(i int) string { return doc.OidcProviders[i].Name })
+
+	errs = append(errs, doc.validateGroups()...)
+	for i, task := range doc.PingTasks {
+		if task.Target == "" || task.Type == "" || task.Interval <= 0 {
+			errs = append(errs, fmt.Errorf("ping_tasks[%d]: type, target and a positive interval are required", i))
+		}
+		if task.Selector != "" {
+			if err := selector.Validate(task.Selector); err != nil {
+				errs = append(errs, fmt.Errorf("ping_tasks[%d]: invalid selector: %w", i, err))
+			}
+		}
+	}
+	for i, n := range doc.LoadNotifications {
+		if n.Metric == "" || n.Threshold == 0 || len(n.Clients) == 0 && n.Selector == "" {
+			errs = append(errs, fmt.Errorf("load_notifications[%d]: metric, threshold and clients or a selector are required", i))
+		}
+		if n.Selector != "" {
+			if err := selector.Validate(n.Selector); err != nil {
+				errs = append(errs, fmt.Errorf("load_notifications[%d]: invalid selector: %w", i, err))
+			}
+		}
+		if n.Interval <= 0 || n.Interval > 4*60 {
+			errs = append(errs, fmt.Errorf("load_notifications[%d]: interval must be between 1 and 240 minutes", i))
+		}
+		if n.Ratio <= 0 || n.Ratio > 1 {
+			errs = append(errs, fmt.Errorf("load_notifications[%d]: ratio must be between 0 and 1", i))
+		}
+	}
+	for i, n := range doc.OfflineNotifications {
+		if n.GracePeriod <= 0 {
+			errs = append(errs, fmt.Errorf("offline_notifications[%d]: grace_period must be a positive integer", i))
+		}
+	}
+	for i, r := range doc.TrafficReports {
+		if r.Enable && !r.Daily && !r.Weekly && !r.Monthly {
+			errs = append(errs, fmt.Errorf("traffic_reports[%d]: at least one cadence must be selected when enabled", i))
+		}
+	}
+	for i := range doc.Clients {
+		if doc.Clients[i].ExpiredAt != nil {
+			utc := doc.Clients[i].ExpiredAt.UTC()
+			doc.Clients[i].ExpiredAt = &utc
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// validateGroups 检查分组名称、策略取值与上级关系：上级必须是文档中的其他分组，且不能成环。
+func (doc *Document) validateGroups() []error {
+	var errs []error
+	parents := make(map[string]string, len(doc.Groups))
+	for _, g := range doc.Groups {
+		parents[g.Name] = g.Parent
+	}
+	for i, g := range doc.Groups {
+		if len(g.Name) > 100 {
+			errs = append(errs, fmt.Errorf("groups[%d]: name must be at most 100 characters", i))
+		}
+		if err := clientgroups.ValidatePolicy(models.GroupPolicy{
+			Offline: g.Policy.Offline, TrafficReport: g.Policy.TrafficReport, Billing: g.Policy.Billing,
+		}); err != nil {
+			errs = append(errs, fmt.Errorf("groups[%d]: %w", i, err))
+		}
+		if g.Parent == "" {
+			continue
+		}
+		if _, ok := parents[g.Parent]; !ok {
+			errs = append(errs, fmt.Errorf("groups[%d]: unknown parent group %q", i, g.Parent))
+			continue
+		}
+		for cur, steps := g.Parent, 0; cur != ""; cur, steps = parents[cur], steps+1 {
+			if cur == g.Name || steps > len(doc.Groups) {
+				errs = append(errs, fmt.Errorf("groups[%d]: parent chain of %q contains a cycle", i, g.Name))
+				break
+			}
+		}
+	}
+	return errs
+}
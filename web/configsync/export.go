@@ -0,0 +1,339 @@
+package configsync
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/dbcore"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/notification"
+	"github.com/komari-monitor/komari/database/tasks"
+	"github.com/komari-monitor/komari/internal/config"
+	"gorm.io/gorm"
+)
+
+// Export 读取当前配置并生成文档，敏感值替换为 RedactedValue。
+// 各列表按稳定顺序排列，便于在 git 中审阅差异。
+func Export() (*Document, error) {
+	state, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	doc := &Document{
+		Version:              DocumentVersion,
+		ExportedAt:           &now,
+		Settings:             map[string]any{},
+		Groups:               state.groups,
+		Clients:              state.clients,
+		PingTasks:            state.pingTasks,
+		LoadNotifications:    state.loadNotifications,
+		OfflineNotifications: state.offlineNotifications,
+		TrafficReports:       state.trafficReports,
+		MessageSenders:       redactProviders(state.messageSenders),
+		OidcProviders:        redactProviders(state.oidcProviders),
+	}
+	theme := state.theme
+	doc.Theme = &theme
+	for key, value := range state.settings {
+		if isSecretSetting(key) {
+			value = redact(value)
+		}
+		doc.Settings[key] = value
+	}
+	return doc, nil
+}
+
+// state 是当前服务端配置，与 Document 使用相同的表示，便于逐字段比较。
+type state struct {
+	theme                string
+	settings             map[string]any
+	groups               []Group
+	agentProfiles        map[string]uint // 名称 → ID，用于解析分组策略引用
+	clients              []Client
+	pingTasks            []PingTask
+	loadNotifications    []LoadNotification
+	offlineNotifications []OfflineNotification
+	trafficReports       []TrafficReport
+	messageSenders       []Provider
+	oidcProviders        []Provider
+}
+
+func loadState() (*state, error) {
+	s := &state{settings: map[string]any{}}
+
+	all, err := config.GetAll()
+	if err != nil {
+		return nil, fmt.Errorf("load settings: %w", err)
+	}
+	s.theme = "default"
+	if theme, ok := all[config.ThemeKey].(string); ok && theme != "" {
+		s.theme = theme
+	}
+	for key, value := range all {
+		if !isExcludedSetting(key) {
+			s.settings[key] = value
+		}
+	}
+
+	clientList, err := clients.GetAllClientBasicInfo()
+	if err != nil {
+		return nil, fmt.Errorf("load clients: %w", err)
+	}
+	sort.SliceStable(clientList, func(i, j int) bool {
+		if clientList[i].Weight != clientList[j].Weight {
+			return clientList[i].Weight < clientList[j].Weight
+		}
+		return clientList[i].UUID < clientList[j].UUID
+	})
+	s.clients = make([]Client, 0, len(clientList))
+	for _, c := range clientList {
+		s.clients = append(s.clients, clientFromModel(c))
+	}
+
+	pingTasks, err := tasks.GetAllPingTasks()
+	if err != nil {
+		return nil, fmt.Errorf("load ping tasks: %w", err)
+	}
+	first := make(map[string]uint, len(pingTasks))
+	for _, t := range pingTasks {
+		if id, ok := first[t.Name]; !ok || t.Id < id {
+			first[t.Name] = t.Id
+		}
+	}
+	s.pingTasks = make([]PingTask, 0, len(pingTasks))
+	for _, t := range pingTasks {
+		s.pingTasks = append(s.pingTasks, PingTask{
+			id:        t.Id,
+			Name:      uniqueName(t.Name, t.Id, first),
+			Type:      t.Type,
+			Target:    t.Target,
+			Interval:  t.Interval,
+			Weight:    t.Weight,
+			DefaultOn: t.DefaultOn,
+			Clients:   sortedStrings(t.Clients),
+			Selector:  t.Selector,
+		})
+	}
+
+	loads, err := notification.GetAllLoadNotifications()
+	if err != nil {
+		return nil, fmt.Errorf("load load notifications: %w", err)
+	}
+	first = make(map[string]uint, len(loads))
+	for _, n := range loads {
+		if id, ok := first[n.Name]; !ok || n.Id < id {
+			first[n.Name] = n.Id
+		}
+	}
+	s.loadNotifications = make([]LoadNotification, 0, len(loads))
+	for _, n := range loads {
+		s.loadNotifications = append(s.loadNotifications, LoadNotification{
+			id:        n.Id,
+			Name:      uniqueName(n.Name, n.Id, first),
+			Metric:    n.Metric,
+			Threshold: n.Threshold,
+			Ratio:     n.Ratio,
+			Interval:  n.Interval,
+			Clients:   sortedStrings(n.Clients),
+			Selector:  n.Selector,
+		})
+	}
+	sort.SliceStable(s.loadNotifications, func(i, j int) bool {
+		return s.loadNotifications[i].Name < s.loadNotifications[j].Name
+	})
+
+	db := dbcore.GetDBInstance()
+	if err := s.loadGroups(db); err != nil {
+		return nil, err
+	}
+
+	var offline []models.OfflineNotification
+	if err := db.Order("client ASC").Find(&offline).Error; err != nil {
+		return nil, fmt.Errorf("load offline notifications: %w", err)
+	}
+	s.offlineNotifications = make([]OfflineNotification, 0, len(offline))
+	for _, n := range offline {
+		s.offlineNotifications = append(s.offlineNotifications, OfflineNotification{
+			Client: n.Client, Enable: n.Enable, GracePeriod: n.GracePeriod,
+		})
+	}
+
+	var reports []models.TrafficReportNotification
+	if err := db.Order("client ASC").Find(&reports).Error; err != nil {
+		return nil, fmt.Errorf("load traffic report notifications: %w", err)
+	}
+	s.trafficReports = make([]TrafficReport, 0, len(reports))
+	for _, r := range reports {
+		s.trafficReports = append(s.trafficReports, TrafficReport{
+			Client: r.Client, Enable: r.Enable, Daily: r.Daily, Weekly: r.Weekly, Monthly: r.Monthly,
+		})
+	}
+
+	var senders []models.MessageSenderProvider
+	if err := db.Order("name ASC").Find(&senders).Error; err != nil {
+		return nil, fmt.Errorf("load message senders: %w", err)
+	}
+	s.messageSenders = make([]Provider, 0, len(senders))
+	for _, p := range senders {
+		s.messageSenders = append(s.messageSenders, Provider{Name: p.Name, Config: decodeAddition(p.Addition)})
+	}
+
+	var oidc []models.OidcProvider
+	if err := db.Order("name ASC").Find(&oidc).Error; err != nil {
+		return nil, fmt.Errorf("load OIDC providers: %w", err)
+	}
+	s.oidcProviders = make([]Provider, 0, len(oidc))
+	for _, p := range oidc {
+		s.oidcProviders = append(s.oidcProviders, Provider{Name: p.Name, Config: decodeAddition(p.Addition)})
+	}
+	return s, nil
+}
+
+// loadGroups 读取分组，策略中的 ID 转换为文档中的名称；已不存在的引用被忽略。
+func (s *state) loadGroups(db *gorm.DB) error {
+	var profiles []models.AgentProfile
+	if err := db.Select("id", "name").Find(&profiles).Error; err != nil {
+		return fmt.Errorf("load agent profiles: %w", err)
+	}
+	s.agentProfiles = make(map[string]uint, len(profiles))
+	profileNames := make(map[uint]string, len(profiles))
+	for _, profile := range profiles {
+		s.agentProfiles[profile.Name] = profile.Id
+		profileNames[profile.Id] = profile.Name
+	}
+	pingNames := make(map[uint]string, len(s.pingTasks))
+	for _, task := range s.pingTasks {
+		pingNames[task.id] = task.Name
+	}
+	loadNames := make(map[uint]string, len(s.loadNotifications))
+	for _, n := range s.loadNotifications {
+		loadNames[n.id] = n.Name
+	}
+	names := func(ids []uint, byID map[uint]string) []string {
+		out := []string{}
+		for _, id := range ids {
+			if name, ok := byID[id]; ok {
+				out = append(out, name)
+			}
+		}
+		return sortedStrings(out)
+	}
+
+	groups, err := clientgroups.ListGroups()
+	if err != nil {
+		return fmt.Errorf("load client groups: %w", err)
+	}
+	groupNames := make(map[uint]string, len(groups))
+	for _, g := range groups {
+		groupNames[g.Id] = g.Name
+	}
+	s.groups = make([]Group, 0, len(groups))
+	for _, g := range groups {
+		group := Group{
+			id:          g.Id,
+			Name:        g.Name,
+			Description: g.Description,
+			SortOrder:   g.SortOrder,
+			Policy: GroupPolicy{
+				PingTasks:         names(g.Policy.PingTasks, pingNames),
+				LoadNotifications: names(g.Policy.LoadNotifications, loadNames),
+				Offline:           g.Policy.Offline,
+				TrafficReport:     g.Policy.TrafficReport,
+				Billing:           g.Policy.Billing,
+			},
+		}
+		if g.ParentID != nil {
+			group.Parent = groupNames[*g.ParentID]
+		}
+		if g.Policy.AgentProfile != nil {
+			group.Policy.AgentProfile = profileNames[*g.Policy.AgentProfile]
+		}
+		s.groups = append(s.groups, group)
+	}
+	return nil
+}
+
+// uniqueName 使同名实体在文档中可以区分：first 记录每个名称 id 最小的实体，
+// 该实体保留原名，其余实体的名称附加 " #<id>"。应用文档时通过 id 找回对应的行，
+// 因此导出后再导入不会产生变更。
+func uniqueName(name string, id uint, first map[string]uint) string {
+	if first[name] == id {
+		return name
+	}
+	return fmt.Sprintf("%s #%d", name, id)
+}
+
+func clientFromModel(c models.Client) Client {
+	var expiredAt *time.Time
+	if c.ExpiredAt != nil {
+		utc := c.ExpiredAt.UTC()
+		expiredAt = &utc
+	}
+	return Client{
+		UUID:             c.UUID,
+		Name:             c.Name,
+		Group:            c.Group,
+		Tags:             splitTags(c.Tags),
+		Weight:           c.Weight,
+		Hidden:           c.Hidden,
+		Remark:           c.Remark,
+		PublicRemark:     c.PublicRemark,
+		Price:            c.Price,
+		BillingCycle:     c.BillingCycle,
+		AutoRenewal:      c.AutoRenewal,
+		Currency:         c.Currency,
+		ExpiredAt:        expiredAt,
+		TrafficLimit:     c.TrafficLimit,
+		TrafficLimitType: c.TrafficLimitType,
+	}
+}
+
+// splitTags 把以 ';' 分隔的标签拆为列表，去除空项。
+func splitTags(tags string) []string {
+	out := []string{}
+	for _, tag := range strings.Split(tags, ";") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			out = append(out, tag)
+		}
+	}
+	return out
+}
+
+func sortedStrings(values []string) []string {
+	out := slices.Clone(values)
+	if out == nil {
+		out = []string{}
+	}
+	slices.Sort(out)
+	return out
+}
+
+func decodeAddition(addition string) map[string]any {
+	config := map[string]any{}
+	if strings.TrimSpace(addition) != "" {
+		_ = json.Unmarshal([]byte(addition), &config)
+	}
+	return config
+}
+
+func redactProviders(providers []Provider) []Provider {
+	out := make([]Provider, 0, len(providers))
+	for _, p := range providers {
+		config := make(map[string]any, len(p.Config))
+		for key, value := range p.Config {
+			if isSecretProviderField(key) {
+				value = redact(value)
+			}
+			config[key] = value
+		}
+		out = append(out, Provider{Name: p.Name, Config: config})
+	}
+	return out
+}
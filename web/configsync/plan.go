@@ -0,0 +1,508 @@
+package configsync
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/komari-monitor/komari/internal/config"
+	msfactory "github.com/komari-monitor/komari/utils/messageSender/factory"
+	oauthfactory "github.com/komari-monitor/komari/web/oauth/factory"
+)
+
+// 变更动作
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionDelete = "delete"
+)
+
+// 文档分区名，同时用于 Change.Section。
+const (
+	SectionTheme                = "theme"
+	SectionSettings             = "settings"
+	SectionGroups               = "groups"
+	SectionClients              = "clients"
+	SectionPingTasks            = "ping_tasks"
+	SectionLoadNotifications    = "load_notifications"
+	SectionOfflineNotifications = "offline_notifications"
+	SectionTrafficReports       = "traffic_reports"
+	SectionMessageSenders       = "message_senders"
+	SectionOidcProviders        = "oidc_providers"
+)
+
+// Change 描述一个实体的变更。
+type Change struct {
+	Section string        `json:"section"`
+	Key     string        `json:"key"`
+	Action  string        `json:"action"`
+	Fields  []FieldChange `json:"fields,omitempty"`
+}
+
+// FieldChange 描述一个字段的变更，敏感值显示为 RedactedValue。
+type FieldChange struct {
+	Field string `json:"field"`
+	From  any    `json:"from"`
+	To    any    `json:"to"`
+}
+
+// Plan 是文档与当前配置之间的差异。Changes 可直接作为 dry-run 结果返回，
+// Apply 执行这些变更。
+type Plan struct {
+	Changes []Change `json:"changes"`
+
+	doc      *Document // 敏感值已解析的目标配置
+	current  *state
+	settings map[string]any // 需要写入的设置（含主题）
+}
+
+// BuildPlan 比较 doc 与当前配置，解析敏感值引用并校验引用关系。
+func BuildPlan(doc *Document) (*Plan, error) {
+	current, err := loadState()
+	if err != nil {
+		return nil, err
+	}
+	// 在副本上解析敏感值与规范化，不修改调用方的文档。
+	var resolved Document
+	raw, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(raw, &resolved); err != nil {
+		return nil, err
+	}
+	p := &Plan{Changes: []Change{}, doc: &resolved, current: current, settings: map[string]any{}}
+	var errs []error
+	collect := func(err error) {
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	collect(p.planTheme())
+	collect(p.planSettings())
+	collect(p.planGroups())
+	finalClients := p.planClients()
+	collect(p.checkClientGroups())
+	collect(p.planPingTasks(finalClients))
+	collect(p.planLoadNotifications(finalClients))
+	collect(p.planOfflineNotifications(finalClients))
+	collect(p.planTrafficReports(finalClients))
+	collect(p.planProviders(SectionMessageSenders, &p.doc.MessageSenders, current.messageSenders, config.NotificationMethodKey, "none", func(name string) bool {
+		_, ok := msfactory.GetConstructor(name)
+		return ok
+	}))
+	collect(p.planProviders(SectionOidcProviders, &p.doc.OidcProviders, current.oidcProviders, config.OAuthProviderKey, "github", func(name string) bool {
+		_, ok := oauthfactory.GetConstructor(name)
+		return ok
+	}))
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SettingChanges 返回将要写入的设置键值（含主题），供调用方在应用前复用设置校验。
+func (p *Plan) SettingChanges() map[string]any {
+	out := make(map[string]any, len(p.settings))
+	for k, v := range p.settings {
+		out[k] = v
+	}
+	return out
+}
+
+func (p *Plan) add(section, key, action string, fields []FieldChange) {
+	p.Changes = append(p.Changes, Change{Section: section, Key: key, Action: action, Fields: fields})
+}
+
+func isValidThemeName(name string) bool {
+	return name != "" && name != "." && name != ".." && !strings.ContainsAny(name, `/\`)
+}
+
+func (p *Plan) planTheme() error {
+	if p.doc.Theme == nil || *p.doc.Theme == p.current.theme {
+		return nil
+	}
+	theme := *p.doc.Theme
+	if theme != "default" {
+		if !isValidThemeName(theme) {
+			return fmt.Errorf("theme: invalid theme name %q", theme)
+		}
+		if _, err := os.Stat(filepath.Join(".", "data", "theme", theme, "komari-theme.json")); err != nil {
+			return fmt.Errorf("theme: %q is not installed on this server", theme)
+		}
+	}
+	p.settings[config.ThemeKey] = theme
+	p.add(SectionTheme, config.ThemeKey, ActionUpdate, []FieldChange{{Field: config.ThemeKey, From: p.current.theme, To: theme}})
+	return nil
+}
+
+func (p *Plan) planSettings() error {
+	if p.doc.Settings == nil {
+		return nil
+	}
+	keys := sortedKeys(p.doc.Settings)
+	desired := make(map[string]any, len(keys))
+	var errs []error
+	for _, key := range keys {
+		value := p.doc.Settings[key]
+		if key == config.ThemeKey {
+			errs = append(errs, fmt.Errorf("settings.%s: use the top-level theme field", key))
+			continue
+		}
+		if isExcludedSetting(key) {
+			errs = append(errs, fmt.Errorf("settings.%s: setting is server-specific and cannot be imported", key))
+			continue
+		}
+		current, exists := p.current.settings[key]
+		if isSecretSetting(key) {
+			resolved, err := resolveSecret(value, current, exists)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("settings.%s: %w", key, err))
+				continue
+			}
+			value = resolved
+		}
+		desired[key] = value
+		if exists && jsonEqual(current, value) {
+			continue
+		}
+		action := ActionUpdate
+		if !exists {
+			action = ActionCreate
+		}
+		from, to := current, value
+		if isSecretSetting(key) {
+			from, to = redact(from), redact(to)
+		}
+		p.settings[key] = value
+		p.add(SectionSettings, key, action, []FieldChange{{Field: key, From: from, To: to}})
+	}
+	p.doc.Settings = desired
+	return errors.Join(errs...)
+}
+
+// finalKeys 返回应用后存在的实体标识：分区缺省时为当前配置中的实体。
+func finalKeys[T any](desired, current []T, key func(T) string) map[string]struct{} {
+	items := desired
+	if items == nil {
+		items = current
+	}
+	out := make(map[string]struct{}, len(items))
+	for _, item := range items {
+		out[key(item)] = struct{}{}
+	}
+	return out
+}
+
+// planGroups 比较分组，并检查策略引用的 Ping 任务、负载通知（以应用后的文档为准）与 Agent 配置模板。
+func (p *Plan) planGroups() error {
+	if p.doc.Groups == nil {
+		return nil
+	}
+	pingTasks := finalKeys(p.doc.PingTasks, p.current.pingTasks, func(t PingTask) string { return t.Name })
+	loads := finalKeys(p.doc.LoadNotifications, p.current.loadNotifications, func(n LoadNotification) string { return n.Name })
+	for i := range p.doc.Groups {
+		policy := &p.doc.Groups[i].Policy
+		policy.PingTasks = sortedStrings(policy.PingTasks)
+		policy.LoadNotifications = sortedStrings(policy.LoadNotifications)
+	}
+	return planNamed(p, SectionGroups, p.doc.Groups, p.current.groups,
+		func(g Group) string { return g.Name },
+		func(g Group) error {
+			var errs []error
+			for _, name := range g.Policy.PingTasks {
+				if _, ok := pingTasks[name]; !ok {
+					errs = append(errs, fmt.Errorf("%s %q: unknown ping task %q", SectionGroups, g.Name, name))
+				}
+			}
+			for _, name := range g.Policy.LoadNotifications {
+				if _, ok := loads[name]; !ok {
+					errs = append(errs, fmt.Errorf("%s %q: unknown load notification %q", SectionGroups, g.Name, name))
+				}
+			}
+			if name := g.Policy.AgentProfile; name != "" {
+				if _, ok := p.current.agentProfiles[name]; !ok {
+					errs = append(errs, fmt.Errorf("%s %q: agent profile %q does not exist on this server", SectionGroups, g.Name, name))
+				}
+			}
+			return errors.Join(errs...)
+		})
+}
+
+// checkClientGroups 检查应用后的每个客户端所属分组都存在。
+func (p *Plan) checkClientGroups() error {
+	groups := finalKeys(p.doc.Groups, p.current.groups, func(g Group) string { return g.Name })
+	clientList := p.doc.Clients
+	if clientList == nil {
+		clientList = p.current.clients
+	}
+	var errs []error
+	for _, c := range clientList {
+		if _, ok := groups[c.Group]; c.Group != "" && !ok {
+			errs = append(errs, fmt.Errorf("%s %q: unknown group %q, add it to the groups section", SectionClients, c.UUID, c.Group))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// planClients 返回应用后存在的客户端集合，用于校验其他分区的引用。
+func (p *Plan) planClients() map[string]struct{} {
+	final := make(map[string]struct{})
+	if p.doc.Clients == nil {
+		for _, c := range p.current.clients {
+			final[c.UUID] = struct{}{}
+		}
+		return final
+	}
+	current := make(map[string]Client, len(p.current.clients))
+	for _, c := range p.current.clients {
+		current[c.UUID] = c
+	}
+	for i := range p.doc.Clients {
+		c := &p.doc.Clients[i]
+		if c.Tags == nil {
+			c.Tags = []string{}
+		}
+		final[c.UUID] = struct{}{}
+		existing, ok := current[c.UUID]
+		if !ok {
+			p.add(SectionClients, c.UUID, ActionCreate, diffFields(nil, *c, "uuid", nil))
+			continue
+		}
+		if fields := diffFields(existing, *c, "uuid", nil); len(fields) > 0 {
+			p.add(SectionClients, c.UUID, ActionUpdate, fields)
+		}
+	}
+	for _, c := range p.current.clients {
+		if _, ok := final[c.UUID]; !ok {
+			p.add(SectionClients, c.UUID, ActionDelete, nil)
+		}
+	}
+	return final
+}
+
+func checkClientRefs(section, key string, refs []string, final map[string]struct{}) error {
+	for _, ref := range refs {
+		if _, ok := final[ref]; !ok {
+			return fmt.Errorf("%s %q: unknown client %q", section, key, ref)
+		}
+	}
+	return nil
+}
+
+func (p *Plan) planPingTasks(final map[string]struct{}) error {
+	if p.doc.PingTasks == nil {
+		return nil
+	}
+	for i := range p.doc.PingTasks {
+		p.doc.PingTasks[i].Clients = sortedStrings(p.doc.PingTasks[i].Clients)
+	}
+	return planNamed(p, SectionPingTasks, p.doc.PingTasks, p.current.pingTasks,
+		func(t PingTask) string { return t.Name },
+		func(t PingTask) error { return checkClientRefs(SectionPingTasks, t.Name, t.Clients, final) })
+}
+
+func (p *Plan) planLoadNotifications(final map[string]struct{}) error {
+	if p.doc.LoadNotifications == nil {
+		return nil
+	}
+	for i := range p.doc.LoadNotifications {
+		p.doc.LoadNotifications[i].Clients = sortedStrings(p.doc.LoadNotifications[i].Clients)
+	}
+	return planNamed(p, SectionLoadNotifications, p.doc.LoadNotifications, p.current.loadNotifications,
+		func(n LoadNotification) string { return n.Name },
+		func(n LoadNotification) error {
+			return checkClientRefs(SectionLoadNotifications, n.Name, n.Clients, final)
+		})
+}
+
+func (p *Plan) planOfflineNotifications(final map[string]struct{}) error {
+	if p.doc.OfflineNotifications == nil {
+		return nil
+	}
+	return planNamed(p, SectionOfflineNotifications, p.doc.OfflineNotifications, p.current.offlineNotifications,
+		func(n OfflineNotification) string { return n.Client },
+		func(n OfflineNotification) error {
+			return checkClientRefs(SectionOfflineNotifications, n.Client, []string{n.Client}, final)
+		})
+}
+
+func (p *Plan) planTrafficReports(final map[string]struct{}) error {
+	if p.doc.TrafficReports == nil {
+		return nil
+	}
+	return planNamed(p, SectionTrafficReports, p.doc.TrafficReports, p.current.trafficReports,
+		func(r TrafficReport) string { return r.Client },
+		func(r TrafficReport) error {
+			return checkClientRefs(SectionTrafficReports, r.Client, []string{r.Client}, final)
+		})
+}
+
+// planNamed 比较以 key 标识的实体列表。当前配置中同名实体不唯一时无法确定对应关系，报错。
+func planNamed[T any](p *Plan, section string, desired, current []T, key func(T) string, check func(T) error) error {
+	existing := make(map[string]T, len(current))
+	var errs []error
+	for _, item := range current {
+		k := key(item)
+		if _, dup := existing[k]; dup {
+			errs = append(errs, fmt.Errorf("%s: several existing entries are identified by %q; rename them before importing", section, k))
+		}
+		existing[k] = item
+	}
+	wanted := make(map[string]struct{}, len(desired))
+	for _, item := range desired {
+		k := key(item)
+		wanted[k] = struct{}{}
+		if err := check(item); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		old, ok := existing[k]
+		if !ok {
+			p.add(section, k, ActionCreate, diffFields(nil, item, "", nil))
+			continue
+		}
+		if fields := diffFields(old, item, "", nil); len(fields) > 0 {
+			p.add(section, k, ActionUpdate, fields)
+		}
+	}
+	for _, item := range current {
+		k := key(item)
+		if _, ok := wanted[k]; !ok {
+			p.add(section, k, ActionDelete, nil)
+			wanted[k] = struct{}{} // 重复项只报告一次
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Plan) planProviders(section string, desired *[]Provider, current []Provider, activeKey, activeDefault string, known func(string) bool) error {
+	if *desired == nil {
+		return nil
+	}
+	existing := make(map[string]Provider, len(current))
+	for _, provider := range current {
+		existing[provider.Name] = provider
+	}
+	var errs []error
+	wanted := make(map[string]struct{}, len(*desired))
+	for i := range *desired {
+		provider := &(*desired)[i]
+		wanted[provider.Name] = struct{}{}
+		if !known(provider.Name) {
+			errs = append(errs, fmt.Errorf("%s: unknown provider %q", section, provider.Name))
+			continue
+		}
+		if provider.Config == nil {
+			provider.Config = map[string]any{}
+		}
+		old, exists := existing[provider.Name]
+		for field, value := range provider.Config {
+			if !isSecretProviderField(field) {
+				continue
+			}
+			currentValue, has := old.Config[field]
+			resolved, err := resolveSecret(value, currentValue, has)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s %q: %s: %w", section, provider.Name, field, err))
+				continue
+			}
+			provider.Config[field] = resolved
+		}
+		if !exists {
+			p.add(section, provider.Name, ActionCreate, diffFields(nil, provider.Config, "", isSecretProviderField))
+			continue
+		}
+		if fields := diffFields(old.Config, provider.Config, "", isSecretProviderField); len(fields) > 0 {
+			p.add(section, provider.Name, ActionUpdate, fields)
+		}
+	}
+
+	active := activeDefault
+	if v, ok := p.current.settings[activeKey].(string); ok {
+		active = v
+	}
+	if v, ok := p.settings[activeKey].(string); ok {
+		active = v
+	}
+	for _, provider := range current {
+		if _, ok := wanted[provider.Name]; ok {
+			continue
+		}
+		if provider.Name == active {
+			errs = append(errs, fmt.Errorf("%s: cannot delete %q because it is selected by %s", section, provider.Name, activeKey))
+			continue
+		}
+		p.add(section, provider.Name, ActionDelete, nil)
+	}
+	return errors.Join(errs...)
+}
+
+// diffFields 以 JSON 表示逐字段比较 old 与new（old 为 nil 表示新建），
+// 跳过 skip 字段；secret 为真的字段在结果中显示为 RedactedValue。
+func diffFields(old, new any, skip string, secret func(string) bool) []FieldChange {
+	oldMap := toJSONMap(old)
+	newMap := toJSONMap(new)
+	keys := make(map[string]struct{}, len(oldMap)+len(newMap))
+	for k := range oldMap {
+		keys[k] = struct{}{}
+	}
+	for k := range newMap {
+		keys[k] = struct{}{}
+	}
+	var fields []FieldChange
+	for _, k := range sortedKeys(keys) {
+		if k == skip {
+			continue
+		}
+		from, to := oldMap[k], newMap[k]
+		if reflect.DeepEqual(from, to) {
+			continue
+		}
+		if secret != nil && secret(k) {
+			from, to = redact(from), redact(to)
+		}
+		fields = append(fields, FieldChange{Field: k, From: from, To: to})
+	}
+	return fields
+}
+
+func toJSONMap(v any) map[string]any {
+	out := map[string]any{}
+	if v == nil {
+		return out
+	}
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return out
+	}
+	_ = json.Unmarshal(raw, &out)
+	return out
+}
+
+func jsonEqual(a, b any) bool {
+	ra, errA := json.Marshal(a)
+	rb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var va, vb any
+	_ = json.Unmarshal(ra, &va)
+	_ = json.Unmarshal(rb, &vb)
+	return reflect.DeepEqual(va, vb)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
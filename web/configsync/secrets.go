@@ -0,0 +1,98 @@
+package configsync
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// RedactedValue 替换导出文档中的敏感值。导入时遇到该值表示保留服务端当前值，
+// 因此导出的文档可以原样再次导入而不会清空密钥。
+const RedactedValue = "<redacted>"
+
+// secretSettingKeys 是需要脱敏的系统设置键。
+var secretSettingKeys = map[string]struct{}{
+	"api_key":                      {},
+	"auto_discovery_key":           {},
+	"backup_encryption_passphrase": {},
+	"federation_key":               {},
+}
+
+// excludedSettingKeys 是不属于逻辑配置的设置：内部状态、部署相关的数据库连接，
+// 以及单独导出的主题。
+var excludedSettingKeys = map[string]struct{}{
+	"id":                      {},
+	"updated_at":              {},
+	"system_version":          {},
+	"theme":                   {},
+	"metric_db_driver":        {},
+	"metric_db_dsn":           {},
+	"metric_table_prefix":     {},
+	"metric_max_open_conns":   {},
+	"metric_max_idle_conns":   {},
+	"metric_migration_target": {},
+}
+
+// secretProviderField 匹配提供者配置中的敏感字段：密码、令牌、密钥，
+// 以及可能内嵌 sendkey 的 api_url 和可能携带认证头的 headers。
+var secretProviderField = regexp.MustCompile(`(?i)(pass(word|wd)?|secret|token|key|^api_url|^headers)$`)
+
+var envReference = regexp.MustCompile(`^\$\{env:([A-Za-z_][A-Za-z0-9_]*)\}$`)
+
+// EnvSecretPrefix 限制 "${env:NAME}" 可以读取的环境变量，避免导入文档读出
+// 服务端进程的其他环境变量（例如数据库连接串）并写入可被导出的配置。
+const EnvSecretPrefix = "KOMARI_SECRET_"
+
+func isExcludedSetting(key string) bool {
+	if _, ok := excludedSettingKeys[key]; ok {
+		return true
+	}
+	return strings.HasPrefix(key, "internal_") || strings.HasPrefix(key, "migration_")
+}
+
+func isSecretSetting(key string) bool {
+	_, ok := secretSettingKeys[key]
+	return ok
+}
+
+func isSecretProviderField(key string) bool {
+	return secretProviderField.MatchString(key)
+}
+
+// redact 返回导出用的值：非空敏感值替换为 RedactedValue。
+func redact(value any) any {
+	if s, ok := value.(string); ok && s == "" {
+		return value
+	}
+	if value == nil {
+		return nil
+	}
+	return RedactedValue
+}
+
+// resolveSecret 解析导入文档中的敏感值：RedactedValue 保留 current，
+// "${env:NAME}" 读取服务端以 EnvSecretPrefix 开头的环境变量，其他值原样使用。
+func resolveSecret(value, current any, hasCurrent bool) (any, error) {
+	s, ok := value.(string)
+	if !ok {
+		return value, nil
+	}
+	if s == RedactedValue {
+		if !hasCurrent {
+			return nil, fmt.Errorf("value is %s but no current value exists on this server", RedactedValue)
+		}
+		return current, nil
+	}
+	if m := envReference.FindStringSubmatch(s); m != nil {
+		if !strings.HasPrefix(m[1], EnvSecretPrefix) {
+			return nil, fmt.Errorf("environment variable %s cannot be referenced, only names starting with %s are allowed", m[1], EnvSecretPrefix)
+		}
+		v, ok := os.LookupEnv(m[1])
+		if !ok {
+			return nil, fmt.Errorf("environment variable %s is not set", m[1])
+		}
+		return v, nil
+	}
+	return value, nil
+}
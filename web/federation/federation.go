@@ -0,0 +1,333 @@
+// Package federation 实现联邦模式的父实例侧：定期通过子实例的 /api/rpc2
+// 拉取 common:getNodes 与 common:getNodesLatestStatus，缓存为只读的远程节点，
+// 并附带来源标签（origin）合并进本实例的节点接口。
+//
+// 子实例以 federation_key 识别父实例，权限等同访客：隐藏节点与敏感字段由子实例
+// 自行过滤。通知仍由各子实例独立发送，父实例不会对远程节点触发任何通知。
+package federation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/komari-monitor/komari/database/federationchildren"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	logger "github.com/komari-monitor/komari/utils/log"
+)
+
+const (
+	// DefaultInterval 默认拉取间隔（秒）
+	DefaultInterval = 10
+	// MinInterval / MaxInterval 拉取间隔的允许范围（秒）
+	MinInterval = 5
+	MaxInterval = 3600
+
+	// TickInterval 是调度器检查到期子实例的间隔，应不大于 MinInterval。
+	TickInterval = 5 * time.Second
+
+	// staleAfter 个拉取周期内未成功同步时，远程节点的状态一律视为离线。
+	staleAfter = 3
+
+	requestTimeout   = 15 * time.Second
+	maxResponseBytes = 32 << 20
+)
+
+// 合并进节点接口时附加到远程节点上的字段。
+const (
+	OriginField = "origin"
+	RemoteField = "remote"
+)
+
+var httpClient = &http.Client{Timeout: requestTimeout}
+
+// snapshot 是某个子实例最近一次同步的结果。
+type snapshot struct {
+	name       string
+	interval   time.Duration
+	nodes      map[string]map[string]any
+	status     map[string]map[string]any
+	syncedAt   time.Time // 最近一次成功同步
+	attemptAt  time.Time // 最近一次尝试
+	lastError  string
+	inProgress bool
+}
+
+func (s *snapshot) stale(now time.Time) bool {
+	return s.syncedAt.IsZero() || now.Sub(s.syncedAt) > staleAfter*s.interval
+}
+
+var (
+	mu        sync.RWMutex
+	snapshots = map[uint]*snapshot{}
+)
+
+// ChildStatus 是子实例的同步状态，供管理接口展示。
+type ChildStatus struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Nodes       int        `json:"nodes"`
+	LastSync    *time.Time `json:"last_sync,omitempty"`
+	LastAttempt *time.Time `json:"last_attempt,omitempty"`
+	LastError   string     `json:"last_error,omitempty"`
+	Stale       bool       `json:"stale"`
+}
+
+// Validate 校验子实例配置，并把间隔规范到允许范围内。
+func Validate(child *models.FederationChild) error {
+	child.Name = strings.TrimSpace(child.Name)
+	if child.Name == "" || len(child.Name) > 100 {
+		return errors.New("name is required and must be at most 100 characters")
+	}
+	child.URL = strings.TrimRight(strings.TrimSpace(child.URL), "/")
+	u, err := url.Parse(child.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http(s) URL")
+	}
+	if len(child.Key) < 12 {
+		return errors.New("key must be at least 12 characters")
+	}
+	if child.Interval == 0 {
+		child.Interval = DefaultInterval
+	}
+	if child.Interval < MinInterval || child.Interval > MaxInterval {
+		return fmt.Errorf("interval must be between %d and %d seconds", MinInterval, MaxInterval)
+	}
+	return nil
+}
+
+// Fetch 从子实例拉取节点列表与最新状态，不写入缓存。
+func Fetch(ctx context.Context, child models.FederationChild) (nodes, status map[string]map[string]any, err error) {
+	batch := []*rpc.JsonRpcRequest{
+		rpc.NewRequest(1, "common:getNodes", nil),
+		rpc.NewRequest(2, "common:getNodesLatestStatus", nil),
+	}
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return nil, nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, child.URL+"/api/rpc2", bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+child.Key)
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, nil, fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+
+	var responses []struct {
+		ID     int               `json:"id"`
+		Result json.RawMessage   `json:"result"`
+		Error  *rpc.JsonRpcError `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseBytes)).Decode(&responses); err != nil {
+		return nil, nil, fmt.Errorf("decode response: %w", err)
+	}
+	for _, r := range responses {
+		if r.Error != nil {
+			return nil, nil, fmt.Errorf("child returned error %d: %s", r.Error.Code, r.Error.Message)
+		}
+		var target *map[string]map[string]any
+		switch r.ID {
+		case 1:
+			target = &nodes
+		case 2:
+			target = &status
+		default:
+			continue
+		}
+		if err := json.Unmarshal(r.Result, target); err != nil {
+			return nil, nil, fmt.Errorf("decode result %d: %w", r.ID, err)
+		}
+	}
+	if nodes == nil || status == nil {
+		return nil, nil, errors.New("incomplete response from child")
+	}
+	return nodes, status, nil
+}
+
+// Sync 立即同步一个子实例并更新缓存。失败时保留上一次成功的结果。
+func Sync(ctx context.Context, child models.FederationChild) error {
+	interval := time.Duration(child.Interval) * time.Second
+	if interval <= 0 {
+		interval = DefaultInterval * time.Second
+	}
+	mu.Lock()
+	s, ok := snapshots[child.Id]
+	if !ok {
+		s = &snapshot{}
+		snapshots[child.Id] = s
+	}
+	if s.inProgress {
+		mu.Unlock()
+		return nil
+	}
+	s.name, s.interval, s.inProgress = child.Name, interval, true
+	s.attemptAt = time.Now()
+	mu.Unlock()
+
+	reqCtx, cancel := context.WithTimeout(ctx, requestTimeout)
+	nodes, status, err := Fetch(reqCtx, child)
+	cancel()
+
+	mu.Lock()
+	defer mu.Unlock()
+	s.inProgress = false
+	if err != nil {
+		s.lastError = err.Error()
+		return err
+	}
+	s.nodes, s.status = nodes, status
+	s.syncedAt = time.Now()
+	s.lastError = ""
+	return nil
+}
+
+// SyncDue 同步所有到期的已启用子实例，并清理已删除或停用子实例的缓存。
+// 由调度器每 TickInterval 调用一次。
+func SyncDue(ctx context.Context) {
+	children, err := federationchildren.ListEnabledFederationChildren()
+	if err != nil {
+		logger.Errorf("federation", "Failed to list federation children: %v", err)
+		return
+	}
+	enabled := make(map[uint]struct{}, len(children))
+	now := time.Now()
+	var wg sync.WaitGroup
+	for _, child := range children {
+		enabled[child.Id] = struct{}{}
+		mu.RLock()
+		s, ok := snapshots[child.Id]
+		due := !ok || now.Sub(s.attemptAt) >= time.Duration(child.Interval)*time.Second-time.Second
+		mu.RUnlock()
+		if !due {
+			continue
+		}
+		wg.Add(1)
+		go func(child models.FederationChild) {
+			defer wg.Done()
+			if err := Sync(ctx, child); err != nil {
+				logger.Warnf("federation", "Failed to sync federation child %s: %v", child.Name, err)
+			}
+		}(child)
+	}
+	wg.Wait()
+
+	mu.Lock()
+	for id := range snapshots {
+		if _, ok := enabled[id]; !ok {
+			delete(snapshots, id)
+		}
+	}
+	mu.Unlock()
+}
+
+// Forget 丢弃子实例的缓存，删除或停用子实例后调用。
+func Forget(id uint) {
+	mu.Lock()
+	delete(snapshots, id)
+	mu.Unlock()
+}
+
+// orderedSnapshots 按子实例 ID 排序返回缓存，使 UUID 冲突时的取舍稳定。
+// 调用方需持有读锁。
+func orderedSnapshots() []*snapshot {
+	ids := make([]uint, 0, len(snapshots))
+	for id := range snapshots {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	out := make([]*snapshot, 0, len(ids))
+	for _, id := range ids {
+		out = append(out, snapshots[id])
+	}
+	return out
+}
+
+// Nodes 返回全部远程节点（以 UUID 为键），每个节点附带 origin 与 remote 字段。
+// 多个子实例存在相同 UUID 时保留 ID 较小的子实例的节点。
+func Nodes() map[string]map[string]any {
+	mu.RLock()
+	defer mu.RUnlock()
+	out := map[string]map[string]any{}
+	for _, s := range orderedSnapshots() {
+		for uuid, node := range s.nodes {
+			if _, exists := out[uuid]; exists {
+				continue
+			}
+			out[uuid] = withOrigin(node, s.name)
+		}
+	}
+	return out
+}
+
+// LatestStatus 返回远程节点的最新状态（以 UUID 为键）。子实例长时间同步失败时，
+// 其节点的 online 字段置为 false。
+func LatestStatus() map[string]map[string]any {
+	mu.RLock()
+	defer mu.RUnlock()
+	now := time.Now()
+	out := map[string]map[string]any{}
+	for _, s := range orderedSnapshots() {
+		stale := s.stale(now)
+		for uuid, status := range s.status {
+			if _, exists := out[uuid]; exists {
+				continue
+			}
+			entry := withOrigin(status, s.name)
+			if stale {
+				entry["online"] = false
+			}
+			out[uuid] = entry
+		}
+	}
+	return out
+}
+
+// Statuses 返回各子实例的同步状态（以子实例 ID 为键）。
+func Statuses() map[uint]ChildStatus {
+	mu.RLock()
+	defer mu.RUnlock()
+	now := time.Now()
+	out := make(map[uint]ChildStatus, len(snapshots))
+	for id, s := range snapshots {
+		status := ChildStatus{ID: id, Name: s.name, Nodes: len(s.nodes), LastError: s.lastError, Stale: s.stale(now)}
+		if !s.syncedAt.IsZero() {
+			t := s.syncedAt
+			status.LastSync = &t
+		}
+		if !s.attemptAt.IsZero() {
+			t := s.attemptAt
+			status.LastAttempt = &t
+		}
+		out[id] = status
+	}
+	return out
+}
+
+func withOrigin(entry map[string]any, origin string) map[string]any {
+	out := make(map[string]any, len(entry)+2)
+	for k, v := range entry {
+		out[k] = v
+	}
+	out[OriginField] = origin
+	out[RemoteField] = true
+	return out
+}
@@ -0,0 +1,119 @@
+package federation
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/komari-monitor/komari/database/models"
+)
+
+func newChildServer(t *testing.T, key string) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/rpc2" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer "+key {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode([]map[string]any{
+				{"jsonrpc": "2.0", "id": 1, "error": map[string]any{"code": -32003, "message": "Private site enabled, please login first"}},
+			})
+			return
+		}
+		json.NewEncoder(w).Encode([]map[string]any{
+			{"jsonrpc": "2.0", "id": 1, "result": map[string]any{
+				"node-a": map[string]any{"uuid": "node-a", "name": "tokyo-1", "weight": 1},
+			}},
+			{"jsonrpc": "2.0", "id": 2, "result": map[string]any{
+				"node-a": map[string]any{"client": "node-a", "cpu": 12.5, "online": true},
+			}},
+		})
+	}))
+}
+
+func TestSyncMergesRemoteNodesWithOrigin(t *testing.T) {
+	const key = "child-federation-key"
+	server := newChildServer(t, key)
+	defer server.Close()
+	t.Cleanup(func() { Forget(1); Forget(2) })
+
+	child := models.FederationChild{Id: 1, Name: "asia", URL: server.URL, Key: key, Enabled: true, Interval: 10}
+	if err := Sync(context.Background(), child); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	nodes := Nodes()
+	node, ok := nodes["node-a"]
+	if !ok || node[OriginField] != "asia" || node[RemoteField] != true || node["name"] != "tokyo-1" {
+		t.Fatalf("remote nodes = %+v", nodes)
+	}
+	status := LatestStatus()["node-a"]
+	if status["online"] != true || status[OriginField] != "asia" {
+		t.Fatalf("remote status = %+v", status)
+	}
+
+	// 同一 UUID 出现在另一个子实例时保留 ID 较小者。
+	other := models.FederationChild{Id: 2, Name: "europe", URL: server.URL, Key: key, Enabled: true, Interval: 10}
+	if err := Sync(context.Background(), other); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if got := Nodes()["node-a"][OriginField]; got != "asia" {
+		t.Fatalf("duplicate UUID origin = %v, want asia", got)
+	}
+
+	// 长时间未成功同步的子实例，其节点状态视为离线。
+	mu.Lock()
+	snapshots[1].syncedAt = time.Now().Add(-time.Hour)
+	snapshots[2].syncedAt = time.Now().Add(-time.Hour)
+	mu.Unlock()
+	if got := LatestStatus()["node-a"]["online"]; got != false {
+		t.Fatalf("stale status online = %v, want false", got)
+	}
+	if !Statuses()[1].Stale {
+		t.Fatal("child status should be stale")
+	}
+}
+
+func TestSyncKeepsLastResultOnFailure(t *testing.T) {
+	const key = "child-federation-key"
+	server := newChildServer(t, key)
+	defer server.Close()
+	t.Cleanup(func() { Forget(3) })
+
+	child := models.FederationChild{Id: 3, Name: "us", URL: server.URL, Key: key, Enabled: true, Interval: 10}
+	if err := Sync(context.Background(), child); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	child.Key = "wrong-federation-key"
+	if err := Sync(context.Background(), child); err == nil {
+		t.Fatal("Sync with a wrong key should fail")
+	}
+	status := Statuses()[3]
+	if status.Nodes != 1 || status.LastError == "" || status.LastSync == nil {
+		t.Fatalf("status after failure = %+v", status)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	child := models.FederationChild{Name: " asia ", URL: "https://komari.example.com/", Key: "child-federation-key"}
+	if err := Validate(&child); err != nil {
+		t.Fatalf("Validate: %v", err)
+	}
+	if child.Name != "asia" || child.URL != "https://komari.example.com" || child.Interval != DefaultInterval {
+		t.Fatalf("normalized child = %+v", child)
+	}
+	for _, bad := range []models.FederationChild{
+		{Name: "", URL: "https://a.example", Key: "child-federation-key"},
+		{Name: "a", URL: "ftp://a.example", Key: "child-federation-key"},
+		{Name: "a", URL: "https://a.example", Key: "short"},
+		{Name: "a", URL: "https://a.example", Key: "child-federation-key", Interval: 1},
+	} {
+		if err := Validate(&bad); err == nil {
+			t.Fatalf("Validate(%+v) should fail", bad)
+		}
+	}
+}
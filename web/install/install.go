@@ -98,7 +98,7 @@ func (c *Controller) finalizeBackupUpload(session upload.Session) (upload.Result
 		return upload.Result{}, fmt.Errorf("open merged backup: %w", err)
 	}
 	defer archive.Close()
-	if err := backup.SaveUploadedBackup(archive, session.Metadata.Filename); err != nil {
+	if err := backup.SaveUploadedBackup(archive, session.Metadata.Filename, session.Passphrase); err != nil {
 		return upload.Result{}, err
 	}
 	go func() {
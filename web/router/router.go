@@ -5,8 +5,10 @@ import (
 	"github.com/komari-monitor/komari/web/api"
 	"github.com/komari-monitor/komari/web/api/admin"
 	"github.com/komari-monitor/komari/web/api/client"
+	"github.com/komari-monitor/komari/web/api/filetransfer"
 	public_api "github.com/komari-monitor/komari/web/api/public"
 	"github.com/komari-monitor/komari/web/api/terminal"
+	"github.com/komari-monitor/komari/web/api/tunnel"
 	"github.com/komari-monitor/komari/web/public"
 	jsonRpc "github.com/komari-monitor/komari/web/rpc/jsonrpc"
 )
@@ -71,6 +73,15 @@ func registerAgentRoutes(r *gin.Engine) {
 		tokenAuthorized.GET("/v2/rpc", client.WebSocketV2RPC)
 		tokenAuthorized.POST("/v2/rpc", client.UploadV2RPC)
 		tokenAuthorized.GET("/terminal", terminal.EstablishConnection)
+		tokenAuthorized.GET("/file-transfer", filetransfer.EstablishConnection)
+		tokenAuthorized.GET("/tunnel", tunnel.EstablishConnection)
+		// InfluxDB line protocol 写入（兼容 Telegraf outputs.influxdb / influxdb_v2 的路径）。
+		tokenAuthorized.POST("/write", client.WriteLineProtocol)
+		tokenAuthorized.POST("/api/v2/write", client.WriteLineProtocol)
+		// OTLP/HTTP metrics（OTEL_EXPORTER_OTLP_ENDPOINT=<komari>/api/clients/otlp）。
+		tokenAuthorized.POST("/otlp/v1/metrics", client.ExportOTLPMetrics)
+		// Agent 自更新下载上传到本机的二进制。
+		tokenAuthorized.GET("/agent-releases/:id/download", client.DownloadAgentRelease)
 
 		// JSON 接口 -> RPC2 (client: 命名空间)。
 		tokenAuthorized.POST("/task/result", jsonRpc.Bind("client:taskResult", jsonRpc.WithRaw()))
@@ -86,6 +97,7 @@ func registerAdminRoutes(r *gin.Engine) {
 
 	// --- 二进制/流/重定向类，保留 REST handler ---
 	g.GET("/download/backup", admin.DownloadBackup)
+	g.GET("/backups/download/:name", admin.DownloadStoredBackup)
 	uploadHandler := admin.NewArchiveUploadHandler()
 	uploadGroup := g.Group("/upload")
 	{
@@ -157,6 +169,8 @@ func registerAdminRoutes(r *gin.Engine) {
 		settings.GET("/oidc", jsonRpc.Bind("admin:getOidcProvider", jsonRpc.WithQuery("provider")))
 		settings.POST("/message-sender", jsonRpc.Bind("admin:setMessageSenderProvider"))
 		settings.GET("/message-sender", jsonRpc.Bind("admin:getMessageSenderProvider", jsonRpc.WithQuery("provider")))
+		settings.GET("/config/export", jsonRpc.Bind("admin:exportConfig", jsonRpc.WithQuery("format")))
+		settings.POST("/config/import", api.RequireSensitive2FA(), jsonRpc.Bind("admin:importConfig"))
 	}
 
 	// database storage inspection and maintenance
@@ -174,11 +188,92 @@ func registerAdminRoutes(r *gin.Engine) {
 		clientGroup.GET("/:uuid", jsonRpc.Bind("admin:getClient", jsonRpc.WithPath("uuid"), jsonRpc.WithRaw()))
 		clientGroup.POST("/:uuid/edit", jsonRpc.Bind("admin:editClient", jsonRpc.WithPath("uuid")))
 		clientGroup.POST("/:uuid/remove", jsonRpc.Bind("admin:removeClient", jsonRpc.WithPath("uuid")))
-		clientGroup.GET("/:uuid/token", jsonRpc.Bind("admin:getClientToken", jsonRpc.WithPath("uuid"), jsonRpc.WithFlat()))
+		clientGroup.POST("/:uuid/token/rotate", jsonRpc.Bind("admin:rotateClientToken", jsonRpc.WithPath("uuid")))
+		clientGroup.POST("/:uuid/token/revoke-previous", jsonRpc.Bind("admin:revokePreviousClientToken", jsonRpc.WithPath("uuid")))
 		clientGroup.POST("/order", jsonRpc.Bind("admin:orderClients"))
+		clientGroup.POST("/:uuid/group", jsonRpc.Bind("admin:setClientGroup", jsonRpc.WithPath("uuid")))
+		clientGroup.GET("/:uuid/policy", jsonRpc.Bind("admin:getClientPolicy", jsonRpc.WithPath("uuid")))
+		clientGroup.POST("/:uuid/policy/overrides", jsonRpc.Bind("admin:setClientPolicyOverrides", jsonRpc.WithPath("uuid")))
+		clientGroup.POST("/:uuid/agent-profile", jsonRpc.Bind("admin:setClientAgentProfile", jsonRpc.WithPath("uuid")))
+		clientGroup.GET("/:uuid/events", jsonRpc.Bind("admin:listAgentEvents", jsonRpc.WithPath("uuid"), jsonRpc.WithQuery("history")))
+		clientGroup.POST("/:uuid/events/cancel", jsonRpc.Bind("admin:cancelAgentEvent", jsonRpc.WithPath("uuid")))
+		clientGroup.GET("/:uuid/processes", jsonRpc.Bind("admin:getProcessSnapshot", jsonRpc.WithPath("uuid"), jsonRpc.WithQuery("time")))
+		clientGroup.GET("/:uuid/logs", jsonRpc.Bind("admin:searchLogs", jsonRpc.WithPath("uuid"), jsonRpc.WithQuery("source", "q", "regex", "start", "end", "limit")))
+		clientGroup.GET("/:uuid/logs/sources", jsonRpc.Bind("admin:listLogSources", jsonRpc.WithPath("uuid")))
 		clientGroup.GET("/:uuid/terminal", api.RequireSensitive2FA(), terminal.RequestTerminal)
 	}
 
+	// client groups
+	clientGroups := g.Group("/client-groups")
+	{
+		clientGroups.GET("/", jsonRpc.Bind("admin:listClientGroups"))
+		clientGroups.POST("/add", jsonRpc.Bind("admin:addClientGroup"))
+		clientGroups.POST("/edit", jsonRpc.Bind("admin:editClientGroup"))
+		clientGroups.POST("/delete", jsonRpc.Bind("admin:deleteClientGroup"))
+	}
+
+	// enrollment tokens
+	enrollmentTokens := g.Group("/enrollment-tokens")
+	{
+		enrollmentTokens.GET("/", jsonRpc.Bind("admin:listEnrollmentTokens"))
+		enrollmentTokens.POST("/add", jsonRpc.Bind("admin:addEnrollmentToken"))
+		enrollmentTokens.POST("/edit", jsonRpc.Bind("admin:editEnrollmentToken"))
+		enrollmentTokens.POST("/delete", jsonRpc.Bind("admin:deleteEnrollmentToken"))
+	}
+
+	// agent profiles
+	agentProfiles := g.Group("/agent-profiles")
+	{
+		agentProfiles.GET("/", jsonRpc.Bind("admin:listAgentProfiles"))
+		agentProfiles.POST("/add", jsonRpc.Bind("admin:addAgentProfile"))
+		agentProfiles.POST("/edit", jsonRpc.Bind("admin:editAgentProfile"))
+		agentProfiles.POST("/delete", jsonRpc.Bind("admin:deleteAgentProfile"))
+		agentProfiles.GET("/status", jsonRpc.Bind("admin:listAgentProfileStatus", jsonRpc.WithQuery("outdated_only")))
+	}
+
+	// file transfers（与终端相同，需敏感操作二次验证）
+	fileTransfers := g.Group("/file-transfers")
+	{
+		fileTransfers.GET("/", jsonRpc.Bind("admin:listFileTransfers", jsonRpc.WithQuery("uuid")))
+		fileTransfers.GET("/:id", jsonRpc.Bind("admin:getFileTransfer", jsonRpc.WithPath("id")))
+		fileTransfers.POST("/send", api.RequireSensitive2FA(), jsonRpc.Bind("admin:sendFile"))
+		fileTransfers.POST("/request", api.RequireSensitive2FA(), jsonRpc.Bind("admin:requestFile"))
+		fileTransfers.GET("/:id/content", api.RequireSensitive2FA(), filetransfer.DownloadContent)
+	}
+
+	// tunnels（与终端相同，需敏感操作二次验证）
+	tunnels := g.Group("/tunnels")
+	{
+		tunnels.GET("/", jsonRpc.Bind("admin:listTunnels", jsonRpc.WithQuery("uuid")))
+		tunnels.POST("/open", api.RequireSensitive2FA(), jsonRpc.Bind("admin:openTunnel"))
+		tunnels.POST("/close", jsonRpc.Bind("admin:closeTunnel"))
+		tunnels.GET("/:id/connect", api.RequireSensitive2FA(), tunnel.Connect)
+	}
+
+	// service monitors
+	serviceMonitors := g.Group("/service-monitors")
+	{
+		serviceMonitors.GET("/", jsonRpc.Bind("admin:listServiceMonitors"))
+		serviceMonitors.POST("/add", jsonRpc.Bind("admin:addServiceMonitor"))
+		serviceMonitors.POST("/edit", jsonRpc.Bind("admin:editServiceMonitor"))
+		serviceMonitors.POST("/delete", jsonRpc.Bind("admin:deleteServiceMonitor"))
+		serviceMonitors.GET("/status", jsonRpc.Bind("admin:listServiceStatus", jsonRpc.WithQuery("uuid")))
+	}
+
+	// agent updates
+	agentUpdates := g.Group("/agent-updates")
+	{
+		agentUpdates.GET("/releases", jsonRpc.Bind("admin:listAgentReleases"))
+		agentUpdates.POST("/releases/add", jsonRpc.Bind("admin:addAgentRelease"))
+		agentUpdates.POST("/releases/delete", jsonRpc.Bind("admin:deleteAgentRelease"))
+		agentUpdates.GET("/rollouts", jsonRpc.Bind("admin:listAgentRollouts"))
+		agentUpdates.POST("/rollouts/get", jsonRpc.Bind("admin:getAgentRollout"))
+		agentUpdates.POST("/rollouts/add", jsonRpc.Bind("admin:createAgentRollout"))
+		agentUpdates.POST("/rollouts/pause", jsonRpc.Bind("admin:pauseAgentRollout"))
+		agentUpdates.POST("/rollouts/resume", jsonRpc.Bind("admin:resumeAgentRollout"))
+		agentUpdates.POST("/rollouts/cancel", jsonRpc.Bind("admin:cancelAgentRollout"))
+	}
+
 	// records
 	record := g.Group("/record")
 	{
@@ -226,6 +321,13 @@ func registerAdminRoutes(r *gin.Engine) {
 		pluginGroup.GET("/:short/*filepath", admin.ServePluginFile)
 	}
 
+	// events reported by agents (agent.event / agent.message)
+	reportedEvents := g.Group("/reported-events")
+	{
+		reportedEvents.GET("/", jsonRpc.Bind("admin:listReportedEvents", jsonRpc.WithQuery("uuid", "type", "severity", "start", "end", "limit")))
+		reportedEvents.POST("/delete", jsonRpc.Bind("admin:deleteReportedEvents"))
+	}
+
 	// notifications
 	notificationGroup := g.Group("/notification")
 	{
@@ -240,6 +342,20 @@ func registerAdminRoutes(r *gin.Engine) {
 			loadAlert.POST("/delete", jsonRpc.Bind("admin:deleteLoadNotification"))
 			loadAlert.POST("/edit", jsonRpc.Bind("admin:editLoadNotification"))
 		}
+		agentEventRules := notificationGroup.Group("/agent-event")
+		{
+			agentEventRules.GET("/", jsonRpc.Bind("admin:listAgentEventRules"))
+			agentEventRules.POST("/add", jsonRpc.Bind("admin:addAgentEventRule"))
+			agentEventRules.POST("/edit", jsonRpc.Bind("admin:editAgentEventRule"))
+			agentEventRules.POST("/delete", jsonRpc.Bind("admin:deleteAgentEventRule"))
+		}
+		logAlert := notificationGroup.Group("/log-alert")
+		{
+			logAlert.GET("/", jsonRpc.Bind("admin:listLogAlertRules"))
+			logAlert.POST("/add", jsonRpc.Bind("admin:addLogAlertRule"))
+			logAlert.POST("/edit", jsonRpc.Bind("admin:editLogAlertRule"))
+			logAlert.POST("/delete", jsonRpc.Bind("admin:deleteLogAlertRule"))
+		}
 		trafficReport := notificationGroup.Group("/traffic-report")
 		{
 			trafficReport.GET("/", jsonRpc.Bind("admin:listTrafficReportNotifications"))
@@ -258,4 +374,44 @@ func registerAdminRoutes(r *gin.Engine) {
 		pingTask.POST("/edit", jsonRpc.Bind("admin:editPingTask"))
 		pingTask.POST("/order", jsonRpc.Bind("admin:orderPingTask"))
 	}
+
+	// selector expressions
+	g.POST("/selector/preview", jsonRpc.Bind("admin:previewSelector"))
+
+	// metric forwarding sinks
+	metricSink := g.Group("/metric-sinks")
+	{
+		metricSink.GET("/", jsonRpc.Bind("admin:listMetricSinks"))
+		metricSink.GET("/status", jsonRpc.Bind("admin:getMetricSinkStatus"))
+		metricSink.POST("/add", jsonRpc.Bind("admin:addMetricSink"))
+		metricSink.POST("/edit", jsonRpc.Bind("admin:editMetricSink"))
+		metricSink.POST("/delete", jsonRpc.Bind("admin:deleteMetricSink"))
+	}
+
+	// federation child instances
+	federationGroup := g.Group("/federation")
+	{
+		federationGroup.GET("/children", jsonRpc.Bind("admin:listFederationChildren"))
+		federationGroup.POST("/children/add", jsonRpc.Bind("admin:addFederationChild"))
+		federationGroup.POST("/children/edit", jsonRpc.Bind("admin:editFederationChild"))
+		federationGroup.POST("/children/delete", jsonRpc.Bind("admin:deleteFederationChild"))
+		federationGroup.POST("/children/test", jsonRpc.Bind("admin:testFederationChild"))
+	}
+
+	// stored backups
+	backups := g.Group("/backups")
+	{
+		backups.GET("/", jsonRpc.Bind("admin:listBackups"))
+		backups.POST("/run", jsonRpc.Bind("admin:runBackup"))
+		backups.POST("/delete", jsonRpc.Bind("admin:deleteBackup"))
+		backups.POST("/upload", jsonRpc.Bind("admin:uploadBackup"))
+		backups.POST("/upload-history", jsonRpc.Bind("admin:getBackupUploads"))
+		backups.GET("/destinations", jsonRpc.Bind("admin:listBackupDestinations"))
+		backups.POST("/destinations/add", jsonRpc.Bind("admin:addBackupDestination"))
+		backups.POST("/destinations/edit", jsonRpc.Bind("admin:editBackupDestination"))
+		backups.POST("/destinations/delete", jsonRpc.Bind("admin:deleteBackupDestination"))
+		backups.POST("/destinations/test", jsonRpc.Bind("admin:testBackupDestination"))
+		backups.POST("/destinations/archives", jsonRpc.Bind("admin:listRemoteBackups"))
+		backups.POST("/destinations/restore", jsonRpc.Bind("admin:restoreRemoteBackup"))
+	}
 }
@@ -0,0 +1,194 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentevents"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.agentevent.go
+// Agent 上报的自定义事件（agent.event / agent.message）及其通知规则（admin 命名空间）。
+
+func init() {
+	RegisterWithGroupAndMeta("listReportedEvents", rpc.RoleAdmin, adminListReportedEvents, &rpc.MethodMeta{
+		Name:    "admin:listReportedEvents",
+		Summary: "List events reported by agents, newest first",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: false},
+			{Name: "type", Type: "string", Required: false, Description: "Exact type, or a prefix ending with *"},
+			{Name: "severity", Type: "string", Required: false, Description: "Minimum severity: info | warning | critical"},
+			{Name: "start", Type: "string", Required: false, Description: "RFC3339 time"},
+			{Name: "end", Type: "string", Required: false, Description: "RFC3339 time"},
+			{Name: "limit", Type: "number", Required: false, Description: "At most 1000"},
+		},
+		Returns: "{ id, client_uuid, type, severity, message, data, time }[]",
+	})
+	RegisterWithGroupAndMeta("deleteReportedEvents", rpc.RoleAdmin, adminDeleteReportedEvents, &rpc.MethodMeta{
+		Name:    "admin:deleteReportedEvents",
+		Summary: "Delete agent events by ids",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number[]", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("listAgentEventRules", rpc.RoleAdmin, adminListAgentEventRules, &rpc.MethodMeta{
+		Name:    "admin:listAgentEventRules",
+		Summary: "List agent event notification rules",
+		Returns: "AgentEventRule[]",
+	})
+	RegisterWithGroupAndMeta("addAgentEventRule", rpc.RoleAdmin, adminAddAgentEventRule, &rpc.MethodMeta{
+		Name:    "admin:addAgentEventRule",
+		Summary: "Create an agent event notification rule",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "clients", Type: "string[]", Required: false, Description: "Empty clients and selector match every client"},
+			{Name: "selector", Type: "string", Required: false},
+			{Name: "types", Type: "string[]", Required: false, Description: "Event types, backup.* matches a prefix; empty matches all"},
+			{Name: "min_severity", Type: "string", Required: false, Description: "info | warning | critical"},
+			{Name: "cooldown", Type: "number", Required: false, Description: "Seconds between notifications for the same client and type"},
+			{Name: "enabled", Type: "boolean", Required: false},
+		},
+		Returns: "AgentEventRule",
+	})
+	RegisterWithGroupAndMeta("editAgentEventRule", rpc.RoleAdmin, adminEditAgentEventRule, &rpc.MethodMeta{
+		Name:    "admin:editAgentEventRule",
+		Summary: "Update an agent event notification rule",
+		Returns: "AgentEventRule",
+	})
+	RegisterWithGroupAndMeta("deleteAgentEventRule", rpc.RoleAdmin, adminDeleteAgentEventRule, &rpc.MethodMeta{
+		Name:    "admin:deleteAgentEventRule",
+		Summary: "Delete agent event notification rules by ids",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number[]", Required: true},
+		},
+		Returns: "null",
+	})
+}
+
+func agentEventError(action string, err error) *rpc.JsonRpcError {
+	return mapError(err, "Agent event or rule not found", "Failed to "+action)
+}
+
+func adminListReportedEvents(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID     string      `json:"uuid"`
+		Type     string      `json:"type"`
+		Severity string      `json:"severity"`
+		Start    *time.Time  `json:"start"`
+		End      *time.Time  `json:"end"`
+		Limit    json.Number `json:"limit"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	query := agentevents.Query{ClientUUID: params.UUID, Type: params.Type, MinSeverity: params.Severity}
+	if params.Start != nil {
+		query.Start = *params.Start
+	}
+	if params.End != nil {
+		query.End = *params.End
+	}
+	if params.Limit != "" {
+		limit, err := params.Limit.Int64()
+		if err != nil || limit < 0 {
+			return nil, rpc.MakeError(rpc.InvalidParams, "Invalid limit parameter", nil)
+		}
+		query.Limit = int(limit)
+	}
+	events, err := agentevents.List(query)
+	if err != nil {
+		return nil, agentEventError("list agent events", err)
+	}
+	return events, nil
+}
+
+func adminDeleteReportedEvents(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID []uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if len(params.ID) == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := agentevents.Delete(params.ID); err != nil {
+		return nil, agentEventError("delete agent events", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete reported events:%v", params.ID), "warn")
+	return nil, nil
+}
+
+func adminListAgentEventRules(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	rules, err := agentevents.ListRules()
+	if err != nil {
+		return nil, agentEventError("list agent event rules", err)
+	}
+	return rules, nil
+}
+
+func adminAddAgentEventRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	rule := models.AgentEventRule{Enabled: true}
+	if err := req.BindParams(&rule); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := validateSelector(rule.Selector); err != nil {
+		return nil, err
+	}
+	if err := agentevents.CreateRule(&rule); err != nil {
+		return nil, agentEventError("create agent event rule", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create agent event rule:%d (%s)", rule.Id, rule.Name), "info")
+	return rule, nil
+}
+
+func adminEditAgentEventRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := agentevents.GetRule(probe.ID)
+	if err != nil {
+		return nil, agentEventError("get agent event rule", err)
+	}
+	// 在已有规则上覆盖请求字段，未提供的字段保持不变。
+	rule := *existing
+	if err := req.BindParams(&rule); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	rule.Id = existing.Id
+	if err := validateSelector(rule.Selector); err != nil {
+		return nil, err
+	}
+	if err := agentevents.UpdateRule(&rule); err != nil {
+		return nil, agentEventError("update agent event rule", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update agent event rule:%d (%s)", rule.Id, rule.Name), "info")
+	return rule, nil
+}
+
+func adminDeleteAgentEventRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID []uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if len(params.ID) == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := agentevents.DeleteRules(params.ID); err != nil {
+		return nil, agentEventError("delete agent event rules", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete agent event rules:%v", params.ID), "warn")
+	return nil, nil
+}
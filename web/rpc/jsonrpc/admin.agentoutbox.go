@@ -0,0 +1,68 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/komari-monitor/komari/database/agentoutbox"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.agentoutbox.go
+// 查看与取消发往 v2 Agent 的待处理事件（admin 命名空间）。开启 agent_event_outbox_enabled
+// 后事件会持久化，重启后仍可送达。
+
+func init() {
+	RegisterWithGroupAndMeta("listAgentEvents", rpc.RoleAdmin, adminListAgentEvents, &rpc.MethodMeta{
+		Name:    "admin:listAgentEvents",
+		Summary: "List events queued for a v2 agent",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "history", Type: "boolean", Required: false, Description: "Also return recently finished persisted events"},
+		},
+		Returns: "{ id, client_uuid, method, params, status, attempts, max_attempts, expires_at, delivered_at, acked_at, closed_at, created_at, durable }[]",
+	})
+	RegisterWithGroupAndMeta("cancelAgentEvent", rpc.RoleAdmin, adminCancelAgentEvent, &rpc.MethodMeta{
+		Name:    "admin:cancelAgentEvent",
+		Summary: "Cancel an event the agent has not acknowledged yet; a cancelled exec event fails its task",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "id", Type: "string", Required: true},
+		},
+		Returns: "null",
+	})
+}
+
+func adminListAgentEvents(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID    string `json:"uuid"`
+		History any    `json:"history"` // REST 查询参数为字符串
+	}
+	req.BindParams(&params)
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	history := params.History == true || params.History == "true" || params.History == "1"
+	events, err := agentoutbox.List(params.UUID, history)
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list agent events: "+err.Error(), nil)
+	}
+	return events, nil
+}
+
+func adminCancelAgentEvent(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+		ID   string `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.UUID == "" || params.ID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "uuid and id are required", nil)
+	}
+	if err := agentoutbox.Cancel(params.UUID, params.ID); err != nil {
+		return nil, mapError(err, "Event not found", "Failed to cancel agent event")
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, "cancel agent event:"+params.ID+" for client:"+params.UUID, "warn")
+	return nil, nil
+}
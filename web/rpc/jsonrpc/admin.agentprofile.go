@@ -0,0 +1,175 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/komari-monitor/komari/database/agentprofiles"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"gorm.io/gorm"
+)
+
+// admin.agentprofile.go
+// 服务端管理的 Agent 配置（admin 命名空间）。配置可以直接分配给客户端，也可以通过
+// 分组策略 agent_profile 分配；变更会通过 agent.config 事件下发给在线的 v2 Agent。
+
+func init() {
+	RegisterWithGroupAndMeta("listAgentProfiles", rpc.RoleAdmin, adminListAgentProfiles, &rpc.MethodMeta{
+		Name:    "admin:listAgentProfiles",
+		Summary: "List agent configuration profiles",
+		Returns: "AgentProfile[]",
+	})
+	RegisterWithGroupAndMeta("addAgentProfile", rpc.RoleAdmin, adminAddAgentProfile, &rpc.MethodMeta{
+		Name:    "admin:addAgentProfile",
+		Summary: "Create an agent configuration profile",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "description", Type: "string", Required: false},
+			{Name: "config", Type: "object", Required: false, Description: "{ report_interval?, collectors?, exclude_interfaces?, exclude_mounts?, allow_terminal?, allow_exec? }"},
+		},
+		Returns: "AgentProfile",
+	})
+	RegisterWithGroupAndMeta("editAgentProfile", rpc.RoleAdmin, adminEditAgentProfile, &rpc.MethodMeta{
+		Name:    "admin:editAgentProfile",
+		Summary: "Update an agent configuration profile and push it to online clients using it",
+		Returns: "AgentProfile",
+	})
+	RegisterWithGroupAndMeta("deleteAgentProfile", rpc.RoleAdmin, adminDeleteAgentProfile, &rpc.MethodMeta{
+		Name:    "admin:deleteAgentProfile",
+		Summary: "Delete an agent configuration profile; clients using it fall back to their local configuration",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("setClientAgentProfile", rpc.RoleAdmin, adminSetClientAgentProfile, &rpc.MethodMeta{
+		Name:    "admin:setClientAgentProfile",
+		Summary: "Assign an agent configuration profile to a client; null removes the assignment",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "profile_id", Type: "number", Required: false},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("listAgentProfileStatus", rpc.RoleAdmin, adminListAgentProfileStatus, &rpc.MethodMeta{
+		Name:    "admin:listAgentProfileStatus",
+		Summary: "Show which configuration version each client has acknowledged",
+		Params: []rpc.ParamMeta{
+			{Name: "outdated_only", Type: "boolean", Required: false, Description: "Only list clients not running their current profile"},
+		},
+		Returns: "{ uuid, name, profile_id, profile, version, applied_version, applied_at, sent_at, outdated, online }[]",
+	})
+}
+
+func agentProfileError(action string, err error) *rpc.JsonRpcError {
+	return mapError(err, "Agent profile not found", "Failed to "+action+" agent profile")
+}
+
+func adminListAgentProfiles(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	profiles, err := agentprofiles.ListProfiles()
+	if err != nil {
+		return nil, agentProfileError("list", err)
+	}
+	return profiles, nil
+}
+
+func adminAddAgentProfile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var profile models.AgentProfile
+	if err := req.BindParams(&profile); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := agentprofiles.CreateProfile(&profile); err != nil {
+		return nil, agentProfileError("create", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create agent profile:%d (%s)", profile.Id, profile.Name), "info")
+	return profile, nil
+}
+
+func adminEditAgentProfile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := agentprofiles.GetProfile(probe.ID)
+	if err != nil {
+		return nil, agentProfileError("get", err)
+	}
+	// 在已有配置上覆盖请求字段，未提供的字段保持不变。
+	profile := *existing
+	if err := req.BindParams(&profile); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	profile.Id = existing.Id
+	if err := agentprofiles.UpdateProfile(&profile); err != nil {
+		return nil, agentProfileError("update", err)
+	}
+	updated, err := agentprofiles.GetProfile(profile.Id)
+	if err != nil {
+		return nil, agentProfileError("get", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update agent profile:%d (%s)", updated.Id, updated.Name), "info")
+	return updated, nil
+}
+
+func adminDeleteAgentProfile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := agentprofiles.DeleteProfile(params.ID); err != nil {
+		return nil, agentProfileError("delete", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete agent profile:%d", params.ID), "warn")
+	return nil, nil
+}
+
+func adminSetClientAgentProfile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID      string `json:"uuid"`
+		ProfileID *uint  `json:"profile_id"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	if err := agentprofiles.AssignClient(params.UUID, params.ProfileID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Client not found", nil)
+		}
+		return nil, agentProfileError("assign", err)
+	}
+	actor, ip := auditActor(ctx)
+	if params.ProfileID == nil {
+		auditlog.Log(ip, actor, "remove agent profile from client:"+params.UUID, "info")
+	} else {
+		auditlog.Log(ip, actor, fmt.Sprintf("assign agent profile:%d to client:%s", *params.ProfileID, params.UUID), "info")
+	}
+	return nil, nil
+}
+
+func adminListAgentProfileStatus(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		OutdatedOnly any `json:"outdated_only"` // REST 查询参数为字符串
+	}
+	req.BindParams(&params)
+	outdatedOnly := params.OutdatedOnly == true || params.OutdatedOnly == "true" || params.OutdatedOnly == "1"
+	status, err := agentprofiles.ListStatus(outdatedOnly)
+	if err != nil {
+		return nil, agentProfileError("list", err)
+	}
+	return status, nil
+}
@@ -0,0 +1,239 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/komari-monitor/komari/database/agentupdates"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.agentupdate.go
+// Agent 自更新的管理方法（admin 命名空间）：登记各平台的版本，并按金丝雀分组与
+// 百分比阶段创建更新批次。批次由后台任务推进，失败数达到阈值时自动暂停。
+
+func init() {
+	RegisterWithGroupAndMeta("listAgentReleases", rpc.RoleAdmin, adminListAgentReleases, &rpc.MethodMeta{
+		Name:    "admin:listAgentReleases",
+		Summary: "List registered agent releases",
+		Returns: "AgentRelease[]",
+	})
+	RegisterWithGroupAndMeta("addAgentRelease", rpc.RoleAdmin, adminAddAgentRelease, &rpc.MethodMeta{
+		Name:    "admin:addAgentRelease",
+		Summary: "Register an agent release for one platform, either by download url or by a binary uploaded with purpose agent-release",
+		Params: []rpc.ParamMeta{
+			{Name: "version", Type: "string", Required: true},
+			{Name: "os", Type: "string", Required: true, Description: "linux, windows, darwin or freebsd"},
+			{Name: "arch", Type: "string", Required: true, Description: "amd64, arm64, 386, arm ..."},
+			{Name: "url", Type: "string", Required: false, Description: "External download url, requires sha256"},
+			{Name: "sha256", Type: "string", Required: false},
+			{Name: "file", Type: "string", Required: false, Description: "File returned by the agent-release upload"},
+		},
+		Returns: "AgentRelease",
+	})
+	RegisterWithGroupAndMeta("deleteAgentRelease", rpc.RoleAdmin, adminDeleteAgentRelease, &rpc.MethodMeta{
+		Name:    "admin:deleteAgentRelease",
+		Summary: "Delete an agent release and its uploaded binary if no longer referenced",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("listAgentRollouts", rpc.RoleAdmin, adminListAgentRollouts, &rpc.MethodMeta{
+		Name:    "admin:listAgentRollouts",
+		Summary: "List agent update rollouts, newest first",
+		Returns: "AgentRollout[]",
+	})
+	RegisterWithGroupAndMeta("getAgentRollout", rpc.RoleAdmin, adminGetAgentRollout, &rpc.MethodMeta{
+		Name:    "admin:getAgentRollout",
+		Summary: "Get a rollout with the per-client progress",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "{ rollout: AgentRollout, targets: AgentRolloutTarget[] }",
+	})
+	RegisterWithGroupAndMeta("createAgentRollout", rpc.RoleAdmin, adminCreateAgentRollout, &rpc.MethodMeta{
+		Name:    "admin:createAgentRollout",
+		Summary: "Start a staged rollout of an agent version; only one rollout can be active at a time",
+		Params: []rpc.ParamMeta{
+			{Name: "version", Type: "string", Required: true},
+			{Name: "canary_group_id", Type: "number", Required: false, Description: "Clients in this group and its subgroups form stage 0"},
+			{Name: "percentages", Type: "number[]", Required: false, Description: "Cumulative percentages of the remaining clients per stage, e.g. [10, 50, 100]"},
+			{Name: "failure_threshold", Type: "number", Required: false, Description: "Pause after this many failures, default 1"},
+			{Name: "timeout", Type: "number", Required: false, Description: "Seconds before an unfinished update counts as failed, default 600"},
+		},
+		Returns: "AgentRollout",
+	})
+	RegisterWithGroupAndMeta("pauseAgentRollout", rpc.RoleAdmin, adminPauseAgentRollout, &rpc.MethodMeta{
+		Name:    "admin:pauseAgentRollout",
+		Summary: "Pause a running rollout; updates already sent are still tracked",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+			{Name: "reason", Type: "string", Required: false},
+		},
+		Returns: "AgentRollout",
+	})
+	RegisterWithGroupAndMeta("resumeAgentRollout", rpc.RoleAdmin, adminResumeAgentRollout, &rpc.MethodMeta{
+		Name:    "admin:resumeAgentRollout",
+		Summary: "Resume a paused rollout; existing failures no longer count towards the threshold",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+			{Name: "retry_failed", Type: "boolean", Required: false, Description: "Queue failed clients again"},
+		},
+		Returns: "AgentRollout",
+	})
+	RegisterWithGroupAndMeta("cancelAgentRollout", rpc.RoleAdmin, adminCancelAgentRollout, &rpc.MethodMeta{
+		Name:    "admin:cancelAgentRollout",
+		Summary: "Cancel a rollout; clients not yet updated are skipped",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "AgentRollout",
+	})
+}
+
+func agentUpdateError(action, what string, err error) *rpc.JsonRpcError {
+	return mapError(err, what+" not found", "Failed to "+action+" "+what)
+}
+
+func adminListAgentReleases(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	releases, err := agentupdates.ListReleases()
+	if err != nil {
+		return nil, agentUpdateError("list", "agent releases", err)
+	}
+	return releases, nil
+}
+
+func adminAddAgentRelease(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var release models.AgentRelease
+	if err := req.BindParams(&release); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := agentupdates.CreateRelease(&release); err != nil {
+		return nil, agentUpdateError("create", "agent release", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("add agent release:%d (%s %s/%s)", release.Id, release.Version, release.OS, release.Arch), "info")
+	return release, nil
+}
+
+func adminDeleteAgentRelease(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	release, err := agentupdates.DeleteRelease(params.ID)
+	if err != nil {
+		return nil, agentUpdateError("delete", "agent release", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete agent release:%d (%s %s/%s)", release.Id, release.Version, release.OS, release.Arch), "warn")
+	return nil, nil
+}
+
+func adminListAgentRollouts(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	rollouts, err := agentupdates.ListRollouts()
+	if err != nil {
+		return nil, agentUpdateError("list", "rollouts", err)
+	}
+	return rollouts, nil
+}
+
+func bindRolloutID(req *rpc.JsonRpcRequest) (uint, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return 0, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	return params.ID, nil
+}
+
+func adminGetAgentRollout(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	id, rpcErr := bindRolloutID(req)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	rollout, err := agentupdates.GetRollout(id)
+	if err != nil {
+		return nil, agentUpdateError("get", "rollout", err)
+	}
+	targets, err := agentupdates.ListTargets(id)
+	if err != nil {
+		return nil, agentUpdateError("get", "rollout", err)
+	}
+	return map[string]any{"rollout": rollout, "targets": targets}, nil
+}
+
+func adminCreateAgentRollout(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var rollout models.AgentRollout
+	if err := req.BindParams(&rollout); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := agentupdates.CreateRollout(&rollout); err != nil {
+		return nil, agentUpdateError("create", "rollout", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("start agent rollout:%d to %s (stages %v)", rollout.Id, rollout.Version, []int(rollout.Percentages)), "warn")
+	return rollout, nil
+}
+
+func adminPauseAgentRollout(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID     uint   `json:"id"`
+		Reason string `json:"reason"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if params.Reason == "" {
+		params.Reason = "paused by administrator"
+	}
+	if err := agentupdates.PauseRollout(params.ID, params.Reason); err != nil {
+		return nil, agentUpdateError("pause", "rollout", err)
+	}
+	return rolloutAudited(ctx, params.ID, "pause")
+}
+
+func adminResumeAgentRollout(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID          uint `json:"id"`
+		RetryFailed bool `json:"retry_failed"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := agentupdates.ResumeRollout(params.ID, params.RetryFailed); err != nil {
+		return nil, agentUpdateError("resume", "rollout", err)
+	}
+	return rolloutAudited(ctx, params.ID, "resume")
+}
+
+func adminCancelAgentRollout(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	id, rpcErr := bindRolloutID(req)
+	if rpcErr != nil {
+		return nil, rpcErr
+	}
+	if err := agentupdates.CancelRollout(id); err != nil {
+		return nil, agentUpdateError("cancel", "rollout", err)
+	}
+	return rolloutAudited(ctx, id, "cancel")
+}
+
+func rolloutAudited(ctx context.Context, id uint, action string) (any, *rpc.JsonRpcError) {
+	rollout, err := agentupdates.GetRollout(id)
+	if err != nil {
+		return nil, agentUpdateError("get", "rollout", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("%s agent rollout:%d (%s)", action, rollout.Id, rollout.Version), "warn")
+	return rollout, nil
+}
@@ -0,0 +1,353 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/backupdestinations"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"github.com/komari-monitor/komari/web/backup"
+	"github.com/komari-monitor/komari/web/backup/remote"
+	"gorm.io/gorm"
+)
+
+// admin.backup.go
+// 服务端已保存备份归档及异地备份目标的管理方法（admin 命名空间）。下载走 REST
+// 流接口 /api/admin/backups/download/:name。
+
+func init() {
+	RegisterWithGroupAndMeta("listBackups", rpc.RoleAdmin, adminListBackups, &rpc.MethodMeta{
+		Name:    "admin:listBackups",
+		Summary: "List backup archives stored in ./data/backup",
+		Returns: "{ name: string, kind: string, size: number, modified: string }[]",
+	})
+	RegisterWithGroupAndMeta("runBackup", rpc.RoleAdmin, adminRunBackup, &rpc.MethodMeta{
+		Name:    "admin:runBackup",
+		Summary: "Run the scheduled backup job now, including verification and rotation",
+		Returns: "{ name: string, kind: string, size: number, modified: string }",
+	})
+	RegisterWithGroupAndMeta("deleteBackup", rpc.RoleAdmin, adminDeleteBackup, &rpc.MethodMeta{
+		Name:    "admin:deleteBackup",
+		Summary: "Delete a stored backup archive by name",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true, Description: "archive file name"},
+		},
+		Returns: "null",
+	})
+
+	RegisterWithGroupAndMeta("listBackupDestinations", rpc.RoleAdmin, adminListBackupDestinations, &rpc.MethodMeta{
+		Name:    "admin:listBackupDestinations",
+		Summary: "List off-site backup destinations (S3 / WebDAV)",
+		Returns: "BackupDestination[]",
+	})
+	RegisterWithGroupAndMeta("addBackupDestination", rpc.RoleAdmin, adminAddBackupDestination, &rpc.MethodMeta{
+		Name:    "admin:addBackupDestination",
+		Summary: "Create an off-site backup destination",
+		Returns: "BackupDestination",
+	})
+	RegisterWithGroupAndMeta("editBackupDestination", rpc.RoleAdmin, adminEditBackupDestination, &rpc.MethodMeta{
+		Name:    "admin:editBackupDestination",
+		Summary: "Edit an off-site backup destination; an empty password keeps the stored one",
+		Returns: "BackupDestination",
+	})
+	RegisterWithGroupAndMeta("deleteBackupDestination", rpc.RoleAdmin, adminDeleteBackupDestination, &rpc.MethodMeta{
+		Name:    "admin:deleteBackupDestination",
+		Summary: "Delete an off-site backup destination and its upload history",
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("testBackupDestination", rpc.RoleAdmin, adminTestBackupDestination, &rpc.MethodMeta{
+		Name:    "admin:testBackupDestination",
+		Summary: "Check that a destination is reachable by listing its archives",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "{ archives: number }",
+	})
+	RegisterWithGroupAndMeta("uploadBackup", rpc.RoleAdmin, adminUploadBackup, &rpc.MethodMeta{
+		Name:    "admin:uploadBackup",
+		Summary: "Upload a stored backup archive to a destination",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true, Description: "destination id"},
+			{Name: "name", Type: "string", Required: true, Description: "archive file name"},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("getBackupUploads", rpc.RoleAdmin, adminGetBackupUploads, &rpc.MethodMeta{
+		Name:    "admin:getBackupUploads",
+		Summary: "Get upload status history, newest first",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Description: "destination id, omit for all"},
+			{Name: "limit", Type: "number", Description: "default 50"},
+		},
+		Returns: "BackupUpload[]",
+	})
+	RegisterWithGroupAndMeta("listRemoteBackups", rpc.RoleAdmin, adminListRemoteBackups, &rpc.MethodMeta{
+		Name:    "admin:listRemoteBackups",
+		Summary: "List archives stored on a destination",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "{ name: string, size: number, modified: string }[]",
+	})
+	RegisterWithGroupAndMeta("restoreRemoteBackup", rpc.RoleAdmin, adminRestoreRemoteBackup, &rpc.MethodMeta{
+		Name:    "admin:restoreRemoteBackup",
+		Summary: "Download an archive from a destination, stage it for restore and restart",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true, Description: "destination id"},
+			{Name: "name", Type: "string", Required: true, Description: "archive file name"},
+			{Name: "passphrase", Type: "string", Required: false, Description: "passphrase for encrypted archives; defaults to the configured backup passphrase"},
+		},
+		Returns: "{ message: string }",
+	})
+}
+
+// redactBackupDestination 返回给前端时隐藏密码 / Secret Key。
+func redactBackupDestination(dest models.BackupDestination) models.BackupDestination {
+	dest.Password = ""
+	return dest
+}
+
+// destinationLookupError 把查询目标时的错误映射为 RPC 错误。
+func destinationLookupError(err error) *rpc.JsonRpcError {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rpc.MakeError(rpc.NotFound, "Backup destination not found", nil)
+	}
+	return rpc.MakeError(rpc.InternalError, "Failed to get backup destination: "+err.Error(), nil)
+}
+
+func adminListBackups(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	archives, err := backup.ListArchives()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list backups: "+err.Error(), nil)
+	}
+	return archives, nil
+}
+
+func adminRunBackup(ctx context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	info, err := backup.RunScheduledBackup(context.WithoutCancel(ctx))
+	if errors.Is(err, backup.ErrBackupInProgress) {
+		return nil, rpc.MakeError(rpc.Aborted, err.Error(), nil)
+	}
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Backup failed: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, "run backup: "+info.Name, "info")
+	return info, nil
+}
+
+func adminDeleteBackup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		Name string `json:"name"`
+	}
+	if err := req.BindParams(&params); err != nil || params.Name == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "name is required", nil)
+	}
+	if err := backup.DeleteArchive(params.Name); err != nil {
+		switch {
+		case errors.Is(err, backup.ErrInvalidArchiveName):
+			return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+		case os.IsNotExist(err):
+			return nil, rpc.MakeError(rpc.NotFound, "Backup not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to delete backup: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, "delete backup: "+params.Name, "warn")
+	return nil, nil
+}
+
+func adminListBackupDestinations(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	destinations, err := backupdestinations.ListBackupDestinations()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list backup destinations: "+err.Error(), nil)
+	}
+	for i := range destinations {
+		destinations[i] = redactBackupDestination(destinations[i])
+	}
+	return destinations, nil
+}
+
+func adminAddBackupDestination(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	dest := models.BackupDestination{Enabled: true, PathStyle: true, KeepLast: 7, KeepDaily: 7, KeepWeekly: 4}
+	if err := req.BindParams(&dest); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	dest.Id = 0
+	if err := remote.Validate(dest); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := backupdestinations.CreateBackupDestination(&dest); err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to create backup destination: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create backup destination:%d (%s %s)", dest.Id, dest.Type, dest.Name), "info")
+	return redactBackupDestination(dest), nil
+}
+
+func adminEditBackupDestination(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := backupdestinations.GetBackupDestination(probe.ID)
+	if err != nil {
+		return nil, destinationLookupError(err)
+	}
+	// 在已有配置上覆盖请求字段，未提供的字段保持不变。
+	dest := *existing
+	dest.Password = ""
+	if err := req.BindParams(&dest); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	dest.Id = existing.Id
+	dest.CreatedAt = existing.CreatedAt
+	if dest.Password == "" {
+		dest.Password = existing.Password
+	}
+	if err := remote.Validate(dest); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := backupdestinations.UpdateBackupDestination(&dest); err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to update backup destination: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update backup destination:%d (%s %s)", dest.Id, dest.Type, dest.Name), "info")
+	return redactBackupDestination(dest), nil
+}
+
+func adminDeleteBackupDestination(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := backupdestinations.DeleteBackupDestination(params.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Backup destination not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to delete backup destination: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete backup destination:%d", params.ID), "warn")
+	return nil, nil
+}
+
+func adminTestBackupDestination(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	testCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	objects, err := backup.ListRemoteArchives(testCtx, params.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, destinationLookupError(err)
+		}
+		return nil, rpc.MakeError(rpc.Unavailable, "Backup destination test failed: "+err.Error(), nil)
+	}
+	return map[string]any{"archives": len(objects)}, nil
+}
+
+func adminUploadBackup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID   uint   `json:"id"`
+		Name string `json:"name"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 || params.Name == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id and name are required", nil)
+	}
+	if err := backup.UploadArchive(context.WithoutCancel(ctx), params.ID, params.Name); err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, destinationLookupError(err)
+		case errors.Is(err, backup.ErrInvalidArchiveName), errors.Is(err, backup.ErrUnencryptedUpload):
+			return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+		case os.IsNotExist(err):
+			return nil, rpc.MakeError(rpc.NotFound, "Backup not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Upload failed: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("upload backup %s to destination:%d", params.Name, params.ID), "info")
+	return nil, nil
+}
+
+func adminGetBackupUploads(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	params := struct {
+		ID    uint `json:"id"`
+		Limit int  `json:"limit"`
+	}{Limit: 50}
+	req.BindParams(&params)
+	if params.Limit <= 0 || params.Limit > 1000 {
+		params.Limit = 50
+	}
+	uploads, err := backupdestinations.ListUploads(params.ID, params.Limit)
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list backup uploads: "+err.Error(), nil)
+	}
+	return uploads, nil
+}
+
+func adminListRemoteBackups(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	listCtx, cancel := context.WithTimeout(ctx, time.Minute)
+	defer cancel()
+	objects, err := backup.ListRemoteArchives(listCtx, params.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, destinationLookupError(err)
+		}
+		return nil, rpc.MakeError(rpc.Unavailable, "Failed to list remote backups: "+err.Error(), nil)
+	}
+	return objects, nil
+}
+
+func adminRestoreRemoteBackup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID         uint   `json:"id"`
+		Name       string `json:"name"`
+		Passphrase string `json:"passphrase"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 || params.Name == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id and name are required", nil)
+	}
+	lock, err := backup.RestoreFromRemote(context.WithoutCancel(ctx), params.ID, params.Name, params.Passphrase)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			return nil, destinationLookupError(err)
+		case errors.Is(err, remote.ErrNotFound):
+			return nil, rpc.MakeError(rpc.NotFound, "Remote backup not found", nil)
+		case errors.Is(err, backup.ErrWrongPassphrase), errors.Is(err, backup.ErrPassphraseRequired):
+			return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Restore failed: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("restore backup %s from destination:%d", params.Name, params.ID), "warn")
+	lock.RestartToApply()
+	return map[string]any{"message": "Backup downloaded successfully. The service will restart and apply the backup."}, nil
+}
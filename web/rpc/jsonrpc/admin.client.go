@@ -2,13 +2,19 @@ package jsonrpc
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/komari-monitor/komari/database/auditlog"
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/internal/metricstore"
 	"github.com/komari-monitor/komari/database/records"
 	"github.com/komari-monitor/komari/pkg/rpc"
+	v2 "github.com/komari-monitor/komari/protocol/v2"
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
+	"gorm.io/gorm"
 )
 
 // admin.client.go
@@ -18,7 +24,7 @@ import (
 func init() {
 	RegisterWithGroupAndMeta("addClient", rpc.RoleAdmin, adminAddClient, &rpc.MethodMeta{
 		Name:    "admin:addClient",
-		Summary: "Create a new client",
+		Summary: "Create a new client; the token is only returned here and by admin:rotateClientToken",
 		Params: []rpc.ParamMeta{
 			{Name: "name", Type: "string", Required: false, Description: "Optional client name"},
 		},
@@ -53,13 +59,23 @@ func init() {
 		Summary: "List all clients (basic info)",
 		Returns: "Client[]",
 	})
-	RegisterWithGroupAndMeta("getClientToken", rpc.RoleAdmin, adminGetClientToken, &rpc.MethodMeta{
-		Name:    "admin:getClientToken",
-		Summary: "Get a client's token by UUID",
+	// admin:getClientToken 已移除：令牌只保存哈希，明文仅在 addClient 与 rotateClientToken 的返回中出现一次。
+	RegisterWithGroupAndMeta("rotateClientToken", rpc.RoleAdmin, adminRotateClientToken, &rpc.MethodMeta{
+		Name:    "admin:rotateClientToken",
+		Summary: "Issue a new token for a client and push it to a connected v2 agent; the old token stays valid for the overlap period, after which connections still using it are closed",
 		Params: []rpc.ParamMeta{
 			{Name: "uuid", Type: "string", Required: true, Description: "Client UUID"},
+			{Name: "overlap", Type: "number", Required: false, Description: "seconds the old token remains valid (default 3600, 0 revokes it immediately, max 604800)"},
 		},
-		Returns: "{ token: string }",
+		Returns: "{ token: string, previous_token_expires_at: string | null, delivered: boolean }",
+	})
+	RegisterWithGroupAndMeta("revokePreviousClientToken", rpc.RoleAdmin, adminRevokePreviousClientToken, &rpc.MethodMeta{
+		Name:    "admin:revokePreviousClientToken",
+		Summary: "End a token rotation's overlap period so the old token stops working and connections using it are closed",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true, Description: "Client UUID"},
+		},
+		Returns: "null",
 	})
 	RegisterWithGroupAndMeta("clearRecords", rpc.RoleAdmin, adminClearRecords, &rpc.MethodMeta{
 		Name:    "admin:clearRecords",
@@ -111,9 +127,15 @@ func adminEditClient(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.Js
 	if uuid == "" {
 		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
 	}
-	if err := clients.SaveClient(update); err != nil {
-		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
+	if err := editClientGroupFields(uuid, update); err != nil {
+		return nil, mapError(err, "", "Failed to update client group")
+	}
+	if len(update) > 1 {
+		if err := clients.SaveClient(update); err != nil {
+			return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
+		}
 	}
+	clientselector.Invalidate()
 	actor, ip := auditActor(ctx)
 	auditlog.Log(ip, actor, "edit client:"+uuid, "info")
 	return nil, nil
@@ -131,6 +153,7 @@ func adminRemoveClient(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.
 		return nil, rpc.MakeError(rpc.InternalError, "Failed to delete client"+err.Error(), nil)
 	}
 	metricstore.DeleteEntityAsync(params.UUID)
+	clientselector.Invalidate()
 	actor, ip := auditActor(ctx)
 	auditlog.Log(ip, actor, "delete client:"+params.UUID, "warn")
 	agent_runtime.DeleteConnectedClients(params.UUID)
@@ -161,19 +184,68 @@ func adminListClients(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonR
 	return cls, nil
 }
 
-func adminGetClientToken(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+const (
+	defaultTokenOverlap = time.Hour
+	maxTokenOverlap     = 7 * 24 * time.Hour
+)
+
+func adminRotateClientToken(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
 	var params struct {
-		UUID string `json:"uuid"`
+		UUID    string `json:"uuid"`
+		Overlap *int   `json:"overlap"`
 	}
 	req.BindParams(&params)
 	if params.UUID == "" {
 		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
 	}
-	token, err := clients.GetClientTokenByUUID(params.UUID)
+	overlap := defaultTokenOverlap
+	if params.Overlap != nil {
+		overlap = time.Duration(*params.Overlap) * time.Second
+		if overlap < 0 || overlap > maxTokenOverlap {
+			return nil, rpc.MakeError(rpc.InvalidParams, "overlap must be between 0 and 604800 seconds", nil)
+		}
+	}
+	token, previousExpiresAt, err := clients.RotateToken(params.UUID, overlap)
 	if err != nil {
-		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Client not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to rotate client token: "+err.Error(), nil)
+	}
+	delivered := agent_runtime.DispatchV2Event(params.UUID, v2.MethodAgentToken, v2.RotateTokenParams{
+		Token:                  token,
+		PreviousTokenExpiresAt: previousExpiresAt,
+	})
+	// 有重叠期时，以旧令牌建立的长连接在重叠期结束后的下一次令牌校验中断开。
+	if overlap == 0 {
+		// 吊销：断开以旧令牌建立的连接，Agent 需要使用新令牌重新接入。
+		if conn := agent_runtime.GetConnectedClients()[params.UUID]; conn != nil {
+			conn.Close()
+		}
+		agent_runtime.DeleteConnectedClients(params.UUID)
 	}
-	return map[string]any{"token": token}, nil
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("rotate client token:%s (overlap %ds, delivered %t)", params.UUID, int(overlap.Seconds()), delivered), "warn")
+	return map[string]any{"token": token, "previous_token_expires_at": previousExpiresAt, "delivered": delivered}, nil
+}
+
+func adminRevokePreviousClientToken(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	if err := clients.RevokePreviousToken(params.UUID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Client not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to revoke previous client token: "+err.Error(), nil)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, "revoke previous client token:"+params.UUID, "warn")
+	return nil, nil
 }
 
 func adminClearRecords(ctx context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
@@ -0,0 +1,153 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/komari-monitor/komari/database"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/internal/config"
+	"github.com/komari-monitor/komari/internal/metricstore"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/utils/messageSender"
+	agent_runtime "github.com/komari-monitor/komari/web/agent"
+	"github.com/komari-monitor/komari/web/backup"
+	"github.com/komari-monitor/komari/web/configsync"
+	"github.com/komari-monitor/komari/web/oauth"
+)
+
+// admin.config.go
+// 声明式配置导出 / 导入（config-as-code）的 RPC2 方法（admin 命名空间）。
+
+func init() {
+	RegisterWithGroupAndMeta("exportConfig", rpc.RoleAdmin, adminExportConfig, &rpc.MethodMeta{
+		Name:    "admin:exportConfig",
+		Summary: "Export the logical configuration as a versioned YAML/JSON document; secrets are redacted",
+		Params: []rpc.ParamMeta{
+			{Name: "format", Type: "string", Required: false, Description: "yaml (default) or json"},
+		},
+		Returns: "{ format: string, content: string }",
+	})
+	RegisterWithGroupAndMeta("importConfig", rpc.RoleAdmin, adminImportConfig, &rpc.MethodMeta{
+		Name:    "admin:importConfig",
+		Summary: "Diff (dry_run) or apply a configuration document; present sections are authoritative",
+		Params: []rpc.ParamMeta{
+			{Name: "content", Type: "string", Required: true, Description: "YAML or JSON document"},
+			{Name: "dry_run", Type: "boolean", Required: false, Description: "only return the changes"},
+		},
+		Returns: "{ dry_run: boolean, changes: Change[] }",
+	})
+	// 导入会覆盖整个配置，与 REST 路由一样需要敏感操作二次验证。
+	rpc.MarkSensitive("admin:importConfig")
+}
+
+func adminExportConfig(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	params := struct {
+		Format string `json:"format"`
+	}{Format: configsync.FormatYAML}
+	req.BindParams(&params)
+	if params.Format == "" {
+		params.Format = configsync.FormatYAML
+	}
+	if params.Format != configsync.FormatYAML && params.Format != configsync.FormatJSON {
+		return nil, rpc.MakeError(rpc.InvalidParams, "format must be yaml or json", nil)
+	}
+	doc, err := configsync.Export()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to export config: "+err.Error(), nil)
+	}
+	content, err := configsync.Marshal(doc, params.Format)
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to encode config: "+err.Error(), nil)
+	}
+	return map[string]any{"format": params.Format, "content": string(content)}, nil
+}
+
+func adminImportConfig(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		Content string `json:"content"`
+		DryRun  bool   `json:"dry_run"`
+	}
+	if err := req.BindParams(&params); err != nil || params.Content == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "content is required", nil)
+	}
+	doc, err := configsync.Parse([]byte(params.Content))
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if doc.Settings != nil {
+		removeRetiredLowResourceMode(doc.Settings)
+	}
+	plan, err := configsync.BuildPlan(doc)
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+
+	// 设置变更沿用 editSettings 的校验规则。
+	settings := plan.SettingChanges()
+	if err := validateMetricRollupSettingChanges(settings); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := backup.ValidateScheduleSettings(settings); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := backup.ValidateEncryptionSettings(settings); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if params.DryRun {
+		return map[string]any{"dry_run": true, "changes": plan.Changes}, nil
+	}
+
+	result, err := plan.Apply()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to apply config: "+err.Error(), nil)
+	}
+	for _, uuid := range result.DeletedClients {
+		metricstore.DeleteEntityAsync(uuid)
+		agent_runtime.DeleteConnectedClients(uuid)
+		agent_runtime.DeleteLatestReport(uuid)
+	}
+	reloadImportedProviders(result)
+	if metricKeysTouched(settings) {
+		reloadCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		err := metricstore.Reload(reloadCtx)
+		cancel()
+		if err != nil {
+			return nil, rpc.MakeError(rpc.InternalError, "Config applied but metrics database hot reload failed: "+err.Error(), nil)
+		}
+	}
+
+	if len(result.Changes) > 0 {
+		actor, ip := auditActor(ctx)
+		auditlog.Log(ip, actor, fmt.Sprintf("import config: %d changes", len(result.Changes)), "warn")
+	}
+	return map[string]any{"dry_run": false, "changes": result.Changes}, nil
+}
+
+// reloadImportedProviders 重载被导入修改且正在使用的消息发送器与 OIDC 提供者。
+func reloadImportedProviders(result *configsync.Result) {
+	method, _ := config.GetAs[string](config.NotificationMethodKey, "none")
+	for _, name := range result.ChangedMessageSenders {
+		if name != method {
+			continue
+		}
+		if cfg, err := database.GetMessageSenderConfigByName(name); err == nil {
+			if err := messageSender.LoadProvider(name, cfg.Addition); err != nil {
+				logger.Warnf("configsync", "Failed to reload message sender %s: %v", name, err)
+			}
+		}
+	}
+	provider, _ := config.GetAs[string](config.OAuthProviderKey, "github")
+	for _, name := range result.ChangedOidcProviders {
+		if name != provider {
+			continue
+		}
+		if cfg, err := database.GetOidcConfigByName(name); err == nil {
+			if err := oauth.LoadProvider(name, cfg.Addition); err != nil {
+				logger.Warnf("configsync", "Failed to reload OIDC provider %s: %v", name, err)
+			}
+		}
+	}
+}
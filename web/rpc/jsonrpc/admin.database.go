@@ -114,10 +114,13 @@ func newDatabaseMaintenanceResponse(main, monitoring databaseMaintenanceResult)
 }
 
 func mainDatabaseStatus() databaseStorageStatus {
+	driver := flags.NormalizeDatabaseType(flags.DatabaseType)
 	status := databaseStorageStatus{
-		Driver:   flags.NormalizeDatabaseType(flags.DatabaseType),
-		Location: databaseLocationLocal,
-		Action:   string(metric.MaintenanceVacuum),
+		Driver:   driver,
+		Location: databaseLocationForDriver(metric.Driver(driver)),
+	}
+	if flags.IsSQLite() {
+		status.Action = string(metric.MaintenanceVacuum)
 	}
 	size, err := dbcore.StorageSize()
 	if err != nil {
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/komari-monitor/komari/cmd/flags"
 	"github.com/komari-monitor/komari/database/auditlog"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/internal/metricstore"
@@ -227,7 +228,7 @@ func executeDatabase(ctx context.Context, target, statement string, args []any)
 			return nil, "", err
 		}
 		result, err := db.ExecContext(ctx, statement, args...)
-		return result, metric.DriverSQLite, err
+		return result, mainDatabaseDriver(), err
 	case databaseTargetMetrics:
 		return metricstore.ExecContext(ctx, statement, args...)
 	default:
@@ -244,7 +245,7 @@ func listDatabaseTables(ctx context.Context, target string) (databaseTablesRespo
 	)
 	switch target {
 	case databaseTargetMain:
-		statement, err := tableListSQL(metric.DriverSQLite)
+		statement, err := tableListSQL(mainDatabaseDriver())
 		if err != nil {
 			return databaseTablesResponse{}, err
 		}
@@ -285,7 +286,7 @@ func openDatabaseRows(ctx context.Context, target, statement string, args ...any
 			return nil, "", nil, err
 		}
 		rows, err := db.QueryContext(ctx, statement, args...)
-		return rows, metric.DriverSQLite, func() {}, err
+		return rows, mainDatabaseDriver(), func() {}, err
 	case databaseTargetMetrics:
 		return metricstore.QueryContext(ctx, statement, args...)
 	default:
@@ -335,6 +336,11 @@ func normalizeDatabaseValue(value any) any {
 	}
 }
 
+// mainDatabaseDriver 返回主库驱动；flags 中的类型名与 metric.Driver 取值一致。
+func mainDatabaseDriver() metric.Driver {
+	return metric.Driver(flags.NormalizeDatabaseType(flags.DatabaseType))
+}
+
 func tableListSQL(driver metric.Driver) (string, error) {
 	switch driver {
 	case metric.DriverSQLite:
@@ -0,0 +1,120 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/enrollment"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.enrollment.go
+// Agent 自动注册令牌的管理方法（admin 命名空间）。令牌明文只在创建时返回一次，
+// Agent 以 `Authorization: Bearer <token>` 调用 POST /api/clients/register 注册。
+
+func init() {
+	RegisterWithGroupAndMeta("listEnrollmentTokens", rpc.RoleAdmin, adminListEnrollmentTokens, &rpc.MethodMeta{
+		Name:    "admin:listEnrollmentTokens",
+		Summary: "List enrollment tokens with their usage; token secrets are never returned",
+		Returns: "EnrollmentToken[]",
+	})
+	RegisterWithGroupAndMeta("addEnrollmentToken", rpc.RoleAdmin, adminAddEnrollmentToken, &rpc.MethodMeta{
+		Name:    "admin:addEnrollmentToken",
+		Summary: "Create an enrollment token; the secret is only shown in this response",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "enabled", Type: "boolean", Required: false},
+			{Name: "max_uses", Type: "number", Required: false, Description: "0 means unlimited"},
+			{Name: "expires_at", Type: "string", Required: false, Description: "RFC3339 time, null means never"},
+			{Name: "defaults", Type: "object", Required: false, Description: "{ group_id?, tags?, hidden?, ping_tasks?, offline_notification?: { enable?, grace_period? } }"},
+		},
+		Returns: "{ token: string, enrollment_token: EnrollmentToken }",
+	})
+	RegisterWithGroupAndMeta("editEnrollmentToken", rpc.RoleAdmin, adminEditEnrollmentToken, &rpc.MethodMeta{
+		Name:    "admin:editEnrollmentToken",
+		Summary: "Update an enrollment token; fields not provided keep their current value",
+		Returns: "EnrollmentToken",
+	})
+	RegisterWithGroupAndMeta("deleteEnrollmentToken", rpc.RoleAdmin, adminDeleteEnrollmentToken, &rpc.MethodMeta{
+		Name:    "admin:deleteEnrollmentToken",
+		Summary: "Delete an enrollment token; clients it enrolled are kept",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "null",
+	})
+}
+
+func enrollmentTokenError(action string, err error) *rpc.JsonRpcError {
+	return mapError(err, "Enrollment token not found", "Failed to "+action+" enrollment token")
+}
+
+func adminListEnrollmentTokens(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	tokens, err := enrollment.ListTokens()
+	if err != nil {
+		return nil, enrollmentTokenError("list", err)
+	}
+	return tokens, nil
+}
+
+func adminAddEnrollmentToken(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	token := models.EnrollmentToken{Enabled: true}
+	if err := req.BindParams(&token); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	secret, err := enrollment.CreateToken(&token)
+	if err != nil {
+		return nil, enrollmentTokenError("create", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create enrollment token:%d (%s)", token.Id, token.Name), "info")
+	return map[string]any{"token": secret, "enrollment_token": token}, nil
+}
+
+func adminEditEnrollmentToken(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := enrollment.GetToken(probe.ID)
+	if err != nil {
+		return nil, enrollmentTokenError("get", err)
+	}
+	// 在已有配置上覆盖请求字段，未提供的字段保持不变。
+	token := *existing
+	if err := req.BindParams(&token); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	token.Id = existing.Id
+	if err := enrollment.UpdateToken(&token); err != nil {
+		return nil, enrollmentTokenError("update", err)
+	}
+	updated, err := enrollment.GetToken(token.Id)
+	if err != nil {
+		return nil, enrollmentTokenError("get", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update enrollment token:%d (%s)", updated.Id, updated.Name), "info")
+	return updated, nil
+}
+
+func adminDeleteEnrollmentToken(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := enrollment.DeleteToken(params.ID); err != nil {
+		return nil, enrollmentTokenError("delete", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete enrollment token:%d", params.ID), "warn")
+	return nil, nil
+}
@@ -0,0 +1,218 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/federationchildren"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	logger "github.com/komari-monitor/komari/utils/log"
+	"github.com/komari-monitor/komari/web/federation"
+	"gorm.io/gorm"
+)
+
+// admin.federation.go
+// 联邦子实例的管理方法（admin 命名空间）。远程节点经 common:getNodes /
+// common:getNodesLatestStatus / public:getNodesInformation 只读展示。
+
+func init() {
+	RegisterWithGroupAndMeta("listFederationChildren", rpc.RoleAdmin, adminListFederationChildren, &rpc.MethodMeta{
+		Name:    "admin:listFederationChildren",
+		Summary: "List federation child instances with their sync status; keys are omitted",
+		Returns: "(FederationChild & { status: ChildStatus | null })[]",
+	})
+	RegisterWithGroupAndMeta("addFederationChild", rpc.RoleAdmin, adminAddFederationChild, &rpc.MethodMeta{
+		Name:    "admin:addFederationChild",
+		Summary: "Register a child instance by URL and its federation_key",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true, Description: "origin label shown on remote nodes"},
+			{Name: "url", Type: "string", Required: true, Description: "child site URL"},
+			{Name: "key", Type: "string", Required: true, Description: "federation_key configured on the child"},
+			{Name: "enabled", Type: "boolean", Required: false},
+			{Name: "interval", Type: "number", Required: false, Description: "pull interval in seconds (5-3600, default 10)"},
+		},
+		Returns: "FederationChild",
+	})
+	RegisterWithGroupAndMeta("editFederationChild", rpc.RoleAdmin, adminEditFederationChild, &rpc.MethodMeta{
+		Name:    "admin:editFederationChild",
+		Summary: "Update a federation child; an empty key keeps the stored one",
+		Returns: "FederationChild",
+	})
+	RegisterWithGroupAndMeta("deleteFederationChild", rpc.RoleAdmin, adminDeleteFederationChild, &rpc.MethodMeta{
+		Name:    "admin:deleteFederationChild",
+		Summary: "Delete a federation child and drop its remote nodes",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("testFederationChild", rpc.RoleAdmin, adminTestFederationChild, &rpc.MethodMeta{
+		Name:    "admin:testFederationChild",
+		Summary: "Pull a federation child once and refresh its cached nodes",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "{ nodes: number }",
+	})
+}
+
+type federationChildView struct {
+	models.FederationChild
+	Status *federation.ChildStatus `json:"status"`
+}
+
+// redactFederationChild 返回给前端时隐藏子实例密钥。
+func redactFederationChild(child models.FederationChild) models.FederationChild {
+	child.Key = ""
+	return child
+}
+
+func federationChildLookupError(err error) *rpc.JsonRpcError {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rpc.MakeError(rpc.NotFound, "Federation child not found", nil)
+	}
+	return rpc.MakeError(rpc.InternalError, "Failed to get federation child: "+err.Error(), nil)
+}
+
+// federationChildWriteError 把保存时的错误映射为 RPC 错误，名称冲突视为参数错误。
+func federationChildWriteError(err error) *rpc.JsonRpcError {
+	if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(strings.ToLower(err.Error()), "unique") ||
+		strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+		return rpc.MakeError(rpc.InvalidParams, "A federation child with this name already exists", nil)
+	}
+	return rpc.MakeError(rpc.InternalError, "Failed to save federation child: "+err.Error(), nil)
+}
+
+// syncFederationChildAsync 保存后立即在后台拉取一次，使远程节点尽快出现。
+func syncFederationChildAsync(child models.FederationChild) {
+	if !child.Enabled {
+		federation.Forget(child.Id)
+		return
+	}
+	go func() {
+		if err := federation.Sync(context.Background(), child); err != nil {
+			logger.Warnf("federation", "Failed to sync federation child %s: %v", child.Name, err)
+		}
+	}()
+}
+
+func adminListFederationChildren(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	children, err := federationchildren.ListFederationChildren()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list federation children: "+err.Error(), nil)
+	}
+	statuses := federation.Statuses()
+	views := make([]federationChildView, 0, len(children))
+	for _, child := range children {
+		view := federationChildView{FederationChild: redactFederationChild(child)}
+		if status, ok := statuses[child.Id]; ok {
+			view.Status = &status
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func adminAddFederationChild(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	child := models.FederationChild{Enabled: true, Interval: federation.DefaultInterval}
+	if err := req.BindParams(&child); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	child.Id = 0
+	if err := federation.Validate(&child); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := federationchildren.CreateFederationChild(&child); err != nil {
+		return nil, federationChildWriteError(err)
+	}
+	syncFederationChildAsync(child)
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create federation child:%d (%s %s)", child.Id, child.Name, child.URL), "info")
+	return redactFederationChild(child), nil
+}
+
+func adminEditFederationChild(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := federationchildren.GetFederationChild(probe.ID)
+	if err != nil {
+		return nil, federationChildLookupError(err)
+	}
+	// 在已有配置上覆盖请求字段，未提供的字段保持不变。
+	child := *existing
+	child.Key = ""
+	if err := req.BindParams(&child); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	child.Id = existing.Id
+	child.CreatedAt = existing.CreatedAt
+	if child.Key == "" {
+		child.Key = existing.Key
+	}
+	if err := federation.Validate(&child); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	}
+	if err := federationchildren.UpdateFederationChild(&child); err != nil {
+		return nil, federationChildWriteError(err)
+	}
+	// 地址或密钥可能已变化，丢弃旧缓存后重新拉取。
+	federation.Forget(child.Id)
+	syncFederationChildAsync(child)
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update federation child:%d (%s %s)", child.Id, child.Name, child.URL), "info")
+	return redactFederationChild(child), nil
+}
+
+func adminDeleteFederationChild(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := federationchildren.DeleteFederationChild(params.ID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, federationChildLookupError(err)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to delete federation child: "+err.Error(), nil)
+	}
+	federation.Forget(params.ID)
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete federation child:%d", params.ID), "warn")
+	return nil, nil
+}
+
+func adminTestFederationChild(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	child, err := federationchildren.GetFederationChild(params.ID)
+	if err != nil {
+		return nil, federationChildLookupError(err)
+	}
+	if !child.Enabled {
+		nodes, _, err := federation.Fetch(ctx, *child)
+		if err != nil {
+			return nil, rpc.MakeError(rpc.Unavailable, "Federation child test failed: "+err.Error(), nil)
+		}
+		return map[string]any{"nodes": len(nodes)}, nil
+	}
+	if err := federation.Sync(ctx, *child); err != nil {
+		return nil, rpc.MakeError(rpc.Unavailable, "Federation child test failed: "+err.Error(), nil)
+	}
+	return map[string]any{"nodes": federation.Statuses()[child.Id].Nodes}, nil
+}
@@ -0,0 +1,122 @@
+package jsonrpc
+
+import (
+	"context"
+
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"github.com/komari-monitor/komari/web/api/filetransfer"
+)
+
+// admin.filetransfer.go
+// 在服务端与 Agent 之间传输文件（admin 命名空间）。发送的文件先通过分片上传
+// （purpose=file-transfer）暂存；取回的文件通过 /api/admin/file-transfers/:id/content 下载。
+
+func init() {
+	RegisterWithGroupAndMeta("sendFile", rpc.RoleAdmin, adminSendFile, &rpc.MethodMeta{
+		Name:    "admin:sendFile",
+		Summary: "Send a staged file to a path on one or more clients",
+		Params: []rpc.ParamMeta{
+			{Name: "file", Type: "string", Required: true, Description: "Staged file returned by the file-transfer upload"},
+			{Name: "path", Type: "string", Required: true, Description: "Absolute destination path on the agent"},
+			{Name: "clients", Type: "string[]", Required: false},
+			{Name: "selector", Type: "string", Required: false},
+			{Name: "overwrite", Type: "boolean", Required: false, Description: "Replace an existing file at path"},
+		},
+		Returns: "FileTransfer[]",
+	})
+	RegisterWithGroupAndMeta("requestFile", rpc.RoleAdmin, adminRequestFile, &rpc.MethodMeta{
+		Name:    "admin:requestFile",
+		Summary: "Fetch a file from a client to the server",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "path", Type: "string", Required: true, Description: "Absolute file path on the agent"},
+		},
+		Returns: "FileTransfer",
+	})
+	RegisterWithGroupAndMeta("listFileTransfers", rpc.RoleAdmin, adminListFileTransfers, &rpc.MethodMeta{
+		Name:    "admin:listFileTransfers",
+		Summary: "List recent file transfers with their progress, newest first",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: false, Description: "Only list transfers of this client"},
+		},
+		Returns: "{ id, client_uuid, direction, path, size, transferred, sha256, status, error, user_uuid, created_at, finished_at }[]",
+	})
+	RegisterWithGroupAndMeta("getFileTransfer", rpc.RoleAdmin, adminGetFileTransfer, &rpc.MethodMeta{
+		Name:    "admin:getFileTransfer",
+		Summary: "Get the status and progress of a file transfer",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "string", Required: true},
+		},
+		Returns: "FileTransfer",
+	})
+	// 与终端相同，向客户端写入或读取任意文件属敏感操作。
+	rpc.MarkSensitive("admin:sendFile")
+	rpc.MarkSensitive("admin:requestFile")
+}
+
+func fileTransferError(err error) *rpc.JsonRpcError {
+	return mapError(err, "", "Failed to start file transfer")
+}
+
+func fileTransferRequester(ctx context.Context) filetransfer.Requester {
+	uuid, ip := auditActor(ctx)
+	return filetransfer.Requester{UserUUID: uuid, IP: ip}
+}
+
+func adminSendFile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		File      string   `json:"file"`
+		Path      string   `json:"path"`
+		Clients   []string `json:"clients"`
+		Selector  string   `json:"selector"`
+		Overwrite bool     `json:"overwrite"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid params: "+err.Error(), nil)
+	}
+	targets := clientselector.Resolve(params.Clients, params.Selector)
+	transfers, err := filetransfer.SendFile(fileTransferRequester(ctx), params.File, params.Path, targets, params.Overwrite)
+	if err != nil {
+		return nil, fileTransferError(err)
+	}
+	return transfers, nil
+}
+
+func adminRequestFile(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+		Path string `json:"path"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid params: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "uuid is required", nil)
+	}
+	transfer, err := filetransfer.RequestFile(fileTransferRequester(ctx), params.UUID, params.Path)
+	if err != nil {
+		return nil, fileTransferError(err)
+	}
+	return transfer, nil
+}
+
+func adminListFileTransfers(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	return filetransfer.List(params.UUID), nil
+}
+
+func adminGetFileTransfer(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	req.BindParams(&params)
+	transfer, ok := filetransfer.Get(params.ID)
+	if !ok {
+		return nil, rpc.MakeError(rpc.NotFound, "File transfer not found", nil)
+	}
+	return transfer, nil
+}
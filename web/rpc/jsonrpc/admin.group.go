@@ -0,0 +1,326 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/clientgroups"
+	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"gorm.io/gorm"
+)
+
+// admin.group.go
+// 客户端分组的 RPC2 方法（admin 命名空间）。分组策略会在保存时物化到成员的
+// Ping 任务、负载/离线/流量报告通知与计费字段上，见 database/clientgroups。
+
+func init() {
+	RegisterWithGroupAndMeta("listClientGroups", rpc.RoleAdmin, adminListClientGroups, &rpc.MethodMeta{
+		Name:    "admin:listClientGroups",
+		Summary: "List client groups with member counts and resolved (inherited) policies",
+		Returns: "(ClientGroup & { members: number, effective_policy: GroupPolicy })[]",
+	})
+	RegisterWithGroupAndMeta("addClientGroup", rpc.RoleAdmin, adminAddClientGroup, &rpc.MethodMeta{
+		Name:    "admin:addClientGroup",
+		Summary: "Create a client group",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "parent_id", Type: "number", Required: false, Description: "parent group; omit for a top-level group"},
+			{Name: "description", Type: "string", Required: false},
+			{Name: "sort_order", Type: "number", Required: false},
+			{Name: "policy", Type: "GroupPolicy", Required: false, Description: "settings inherited by members"},
+		},
+		Returns: "ClientGroup",
+	})
+	RegisterWithGroupAndMeta("editClientGroup", rpc.RoleAdmin, adminEditClientGroup, &rpc.MethodMeta{
+		Name:    "admin:editClientGroup",
+		Summary: "Update a client group and apply policy changes to its members and sub-groups",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "ClientGroup",
+	})
+	RegisterWithGroupAndMeta("deleteClientGroup", rpc.RoleAdmin, adminDeleteClientGroup, &rpc.MethodMeta{
+		Name:    "admin:deleteClientGroup",
+		Summary: "Delete a client group; sub-groups and members move to its parent",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("setClientGroup", rpc.RoleAdmin, adminSetClientGroup, &rpc.MethodMeta{
+		Name:    "admin:setClientGroup",
+		Summary: "Move a client into a group (null to ungroup) and apply the group's policies",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true, Description: "Client UUID"},
+			{Name: "group_id", Type: "number | null", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("getClientPolicy", rpc.RoleAdmin, adminGetClientPolicy, &rpc.MethodMeta{
+		Name:    "admin:getClientPolicy",
+		Summary: "Get the policy a client inherits from its group chain and its per-client overrides",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true, Description: "Client UUID"},
+		},
+		Returns: "{ group_id: number | null, chain: string[], policy: GroupPolicy, overrides: string[] }",
+	})
+	RegisterWithGroupAndMeta("setClientPolicyOverrides", rpc.RoleAdmin, adminSetClientPolicyOverrides, &rpc.MethodMeta{
+		Name:    "admin:setClientPolicyOverrides",
+		Summary: "Set which group policies a client no longer inherits",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true, Description: "Client UUID"},
+			{Name: "overrides", Type: "string[]", Required: true, Description: "ping_tasks, load_notifications, offline_notification, traffic_report, billing"},
+		},
+		Returns: "null",
+	})
+}
+
+type clientGroupView struct {
+	models.ClientGroup
+	Members         int64              `json:"members"`
+	EffectivePolicy models.GroupPolicy `json:"effective_policy"`
+}
+
+func clientGroupLookupError(err error) *rpc.JsonRpcError {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rpc.MakeError(rpc.NotFound, "Client group not found", nil)
+	}
+	return rpc.MakeError(rpc.InternalError, "Failed to get client group: "+err.Error(), nil)
+}
+
+// clientGroupWriteError 把保存时的错误映射为 RPC 错误，校验失败与名称冲突视为参数错误。
+func clientGroupWriteError(err error) *rpc.JsonRpcError {
+	if errors.Is(err, gorm.ErrDuplicatedKey) || strings.Contains(strings.ToLower(err.Error()), "unique") ||
+		strings.Contains(strings.ToLower(err.Error()), "duplicate") {
+		return rpc.MakeError(rpc.InvalidParams, "A client group with this name already exists", nil)
+	}
+	return mapError(err, "Client group not found", "Failed to save client group")
+}
+
+func adminListClientGroups(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	groups, err := clientgroups.ListGroups()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to list client groups: "+err.Error(), nil)
+	}
+	counts, err := clientgroups.MemberCounts()
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to count group members: "+err.Error(), nil)
+	}
+	views := make([]clientGroupView, 0, len(groups))
+	for _, group := range groups {
+		policy, err := clientgroups.ResolvePolicy(group.Id)
+		if err != nil {
+			return nil, rpc.MakeError(rpc.InternalError, fmt.Sprintf("Failed to resolve policy of group %d: %v", group.Id, err), nil)
+		}
+		views = append(views, clientGroupView{ClientGroup: group, Members: counts[group.Id], EffectivePolicy: policy})
+	}
+	return views, nil
+}
+
+func adminAddClientGroup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var group models.ClientGroup
+	if err := req.BindParams(&group); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	group.Id = 0
+	if err := clientgroups.CreateGroup(&group); err != nil {
+		return nil, clientGroupWriteError(err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create client group:%d (%s)", group.Id, group.Name), "info")
+	return group, nil
+}
+
+func adminEditClientGroup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID     uint            `json:"id"`
+		Policy json.RawMessage `json:"policy"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := clientgroups.GetGroup(probe.ID)
+	if err != nil {
+		return nil, clientGroupLookupError(err)
+	}
+	// 在已有配置上覆盖请求字段，未提供的字段保持不变；policy 作为整体替换。
+	group := *existing
+	if probe.Policy != nil {
+		group.Policy = models.GroupPolicy{}
+	}
+	if err := req.BindParams(&group); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	group.Id = existing.Id
+	group.CreatedAt = existing.CreatedAt
+	if err := clientgroups.UpdateGroup(&group); err != nil {
+		return nil, clientGroupWriteError(err)
+	}
+	clientselector.Invalidate()
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update client group:%d (%s)", group.Id, group.Name), "info")
+	return group, nil
+}
+
+func adminDeleteClientGroup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if params.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := clientgroups.DeleteGroup(params.ID); err != nil {
+		return nil, mapError(err, "Client group not found", "Failed to delete client group")
+	}
+	clientselector.Invalidate()
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete client group:%d", params.ID), "warn")
+	return nil, nil
+}
+
+func adminSetClientGroup(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID    string `json:"uuid"`
+		GroupID *uint  `json:"group_id"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	if err := clientgroups.SetClientGroup(params.UUID, params.GroupID); err != nil {
+		return nil, mapError(err, "Client not found", "Failed to set client group")
+	}
+	clientselector.Invalidate()
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("set client group:%s -> %s", params.UUID, formatGroupID(params.GroupID)), "info")
+	return nil, nil
+}
+
+func adminGetClientPolicy(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	policy, err := clientgroups.GetClientPolicy(params.UUID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Client not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to resolve client policy: "+err.Error(), nil)
+	}
+	return policy, nil
+}
+
+func adminSetClientPolicyOverrides(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID      string   `json:"uuid"`
+		Overrides []string `json:"overrides"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	if err := clientgroups.SetClientOverrides(params.UUID, params.Overrides); err != nil {
+		return nil, mapError(err, "Client not found", "Failed to set policy overrides")
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("set client policy overrides:%s [%s]", params.UUID, strings.Join(params.Overrides, ",")), "info")
+	return nil, nil
+}
+
+// editClientGroupFields 处理 editClient 中的分组相关字段：group_id / group（旧的名称写法）
+// 变化时改为走分组成员变更，policy_overrides 变化时走覆盖设置；这些字段会从 update 中移除。
+func editClientGroupFields(uuid string, update map[string]interface{}) error {
+	rawID, hasID := update["group_id"]
+	rawName, hasName := update["group"]
+	rawOverrides, hasOverrides := update["policy_overrides"]
+	delete(update, "group_id")
+	delete(update, "group")
+	delete(update, "policy_overrides")
+	if !hasID && !hasName && !hasOverrides {
+		return nil
+	}
+	current, err := clients.GetClientByUUID(uuid)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case hasID:
+		var id *uint
+		if rawID != nil {
+			f, ok := rawID.(float64)
+			if !ok || f <= 0 || f != float64(uint(f)) {
+				return errors.New("group_id must be a positive integer or null")
+			}
+			v := uint(f)
+			id = &v
+		}
+		if !sameGroupID(current.GroupID, id) {
+			if err := clientgroups.SetClientGroup(uuid, id); err != nil {
+				return err
+			}
+		}
+	case hasName:
+		name, ok := rawName.(string)
+		if !ok && rawName != nil {
+			return errors.New("group must be a string")
+		}
+		if strings.TrimSpace(name) != current.Group {
+			if err := clientgroups.SetClientGroupByName(uuid, name); err != nil {
+				return err
+			}
+		}
+	}
+
+	if hasOverrides {
+		var overrides []string
+		if list, ok := rawOverrides.([]interface{}); ok {
+			for _, item := range list {
+				key, ok := item.(string)
+				if !ok {
+					return errors.New("policy_overrides must be an array of strings")
+				}
+				overrides = append(overrides, key)
+			}
+		} else if rawOverrides != nil {
+			return errors.New("policy_overrides must be an array of strings")
+		}
+		if strings.Join(overrides, ",") != strings.Join(current.PolicyOverrides, ",") {
+			if err := clientgroups.SetClientOverrides(uuid, overrides); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func sameGroupID(a, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
+
+func formatGroupID(id *uint) string {
+	if id == nil {
+		return "none"
+	}
+	return fmt.Sprint(*id)
+}
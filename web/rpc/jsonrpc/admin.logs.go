@@ -0,0 +1,205 @@
+package jsonrpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/komari-monitor/komari/database/agentlogs"
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.logs.go
+// 搜索 Agent 采集的日志（agent.logs）并管理日志告警规则（admin 命名空间）。
+
+func init() {
+	RegisterWithGroupAndMeta("searchLogs", rpc.RoleAdmin, adminSearchLogs, &rpc.MethodMeta{
+		Name:    "admin:searchLogs",
+		Summary: "Search log lines collected from an agent, newest first",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "source", Type: "string", Required: false},
+			{Name: "q", Type: "string", Required: false, Description: "Case-insensitive text, or a regular expression when regex is true"},
+			{Name: "regex", Type: "boolean", Required: false},
+			{Name: "start", Type: "string", Required: false, Description: "RFC3339 time"},
+			{Name: "end", Type: "string", Required: false, Description: "RFC3339 time"},
+			{Name: "limit", Type: "number", Required: false, Description: "At most 1000"},
+		},
+		Returns: "{ lines: { id, client_uuid, source, time, line }[], truncated }",
+	})
+	RegisterWithGroupAndMeta("listLogSources", rpc.RoleAdmin, adminListLogSources, &rpc.MethodMeta{
+		Name:    "admin:listLogSources",
+		Summary: "List log sources an agent has shipped lines for",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+		},
+		Returns: "string[]",
+	})
+	RegisterWithGroupAndMeta("listLogAlertRules", rpc.RoleAdmin, adminListLogAlertRules, &rpc.MethodMeta{
+		Name:    "admin:listLogAlertRules",
+		Summary: "List log alert rules",
+		Returns: "LogAlertRule[]",
+	})
+	RegisterWithGroupAndMeta("addLogAlertRule", rpc.RoleAdmin, adminAddLogAlertRule, &rpc.MethodMeta{
+		Name:    "admin:addLogAlertRule",
+		Summary: "Create a log alert rule",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "pattern", Type: "string", Required: true},
+			{Name: "regex", Type: "boolean", Required: false},
+			{Name: "threshold", Type: "number", Required: true, Description: "Alert when more lines than this match within the window"},
+			{Name: "window", Type: "number", Required: true, Description: "Window in minutes"},
+			{Name: "source", Type: "string", Required: false},
+			{Name: "clients", Type: "string[]", Required: false, Description: "Empty clients and selector match every client"},
+			{Name: "selector", Type: "string", Required: false},
+			{Name: "enabled", Type: "boolean", Required: false},
+		},
+		Returns: "LogAlertRule",
+	})
+	RegisterWithGroupAndMeta("editLogAlertRule", rpc.RoleAdmin, adminEditLogAlertRule, &rpc.MethodMeta{
+		Name:    "admin:editLogAlertRule",
+		Summary: "Update a log alert rule",
+		Returns: "LogAlertRule",
+	})
+	RegisterWithGroupAndMeta("deleteLogAlertRule", rpc.RoleAdmin, adminDeleteLogAlertRule, &rpc.MethodMeta{
+		Name:    "admin:deleteLogAlertRule",
+		Summary: "Delete log alert rules by ids",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number[]", Required: true},
+		},
+		Returns: "null",
+	})
+}
+
+func agentLogError(action string, err error) *rpc.JsonRpcError {
+	return mapError(err, "Log alert rule not found", "Failed to "+action)
+}
+
+func adminSearchLogs(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID   string      `json:"uuid"`
+		Source string      `json:"source"`
+		Query  string      `json:"q"`
+		Regex  any         `json:"regex"` // REST 查询参数为字符串
+		Start  *time.Time  `json:"start"`
+		End    *time.Time  `json:"end"`
+		Limit  json.Number `json:"limit"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	query := agentlogs.SearchQuery{
+		ClientUUID: params.UUID,
+		Source:     params.Source,
+		Text:       params.Query,
+		Regex:      params.Regex == true || params.Regex == "true" || params.Regex == "1",
+	}
+	if params.Start != nil {
+		query.Start = *params.Start
+	}
+	if params.End != nil {
+		query.End = *params.End
+	}
+	if params.Limit != "" {
+		limit, err := params.Limit.Int64()
+		if err != nil || limit < 0 {
+			return nil, rpc.MakeError(rpc.InvalidParams, "Invalid limit parameter", nil)
+		}
+		query.Limit = int(limit)
+	}
+	result, err := agentlogs.Search(query)
+	if err != nil {
+		return nil, agentLogError("search logs", err)
+	}
+	return result, nil
+}
+
+func adminListLogSources(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	sources, err := agentlogs.Sources(params.UUID)
+	if err != nil {
+		return nil, agentLogError("list log sources", err)
+	}
+	return sources, nil
+}
+
+func adminListLogAlertRules(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	rules, err := agentlogs.ListRules()
+	if err != nil {
+		return nil, agentLogError("list log alert rules", err)
+	}
+	return rules, nil
+}
+
+func adminAddLogAlertRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	rule := models.LogAlertRule{Enabled: true}
+	if err := req.BindParams(&rule); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := validateSelector(rule.Selector); err != nil {
+		return nil, err
+	}
+	if err := agentlogs.CreateRule(&rule); err != nil {
+		return nil, agentLogError("create log alert rule", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create log alert rule:%d (%s)", rule.Id, rule.Name), "info")
+	return rule, nil
+}
+
+func adminEditLogAlertRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := agentlogs.GetRule(probe.ID)
+	if err != nil {
+		return nil, agentLogError("get log alert rule", err)
+	}
+	// 在已有规则上覆盖请求字段，未提供的字段保持不变。
+	rule := *existing
+	if err := req.BindParams(&rule); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	rule.Id = existing.Id
+	if err := validateSelector(rule.Selector); err != nil {
+		return nil, err
+	}
+	if err := agentlogs.UpdateRule(&rule); err != nil {
+		return nil, agentLogError("update log alert rule", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update log alert rule:%d (%s)", rule.Id, rule.Name), "info")
+	return rule, nil
+}
+
+func adminDeleteLogAlertRule(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID []uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if len(params.ID) == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := agentlogs.DeleteRules(params.ID); err != nil {
+		return nil, agentLogError("delete log alert rules", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete log alert rules:%v", params.ID), "warn")
+	return nil, nil
+}
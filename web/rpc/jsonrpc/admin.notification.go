@@ -61,6 +61,7 @@ func reg(name string, h rpc.Handler, summary string) {
 func adminAddLoadNotification(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
 	var params struct {
 		Clients   []string `json:"clients"`
+		Selector  string   `json:"selector"`
 		Name      string   `json:"name"`
 		Metric    string   `json:"metric"`
 		Threshold float32  `json:"threshold"`
@@ -68,8 +69,11 @@ func adminAddLoadNotification(_ context.Context, req *rpc.JsonRpcRequest) (any,
 		Interval  int      `json:"interval"`
 	}
 	req.BindParams(&params)
-	if len(params.Clients) == 0 || params.Metric == "" || params.Threshold == 0 || params.Ratio == 0 || params.Interval == 0 {
-		return nil, rpc.MakeError(rpc.InvalidParams, "clients, metric, threshold, ratio and interval are required", nil)
+	if (len(params.Clients) == 0 && params.Selector == "") || params.Metric == "" || params.Threshold == 0 || params.Ratio == 0 || params.Interval == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "clients or selector, metric, threshold, ratio and interval are required", nil)
+	}
+	if err := validateSelector(params.Selector); err != nil {
+		return nil, err
 	}
 	if params.Interval > 4*60 || params.Interval <= 0 {
 		return nil, rpc.MakeError(rpc.InvalidParams, "Interval must be between 1 and 240 minutes", nil)
@@ -77,7 +81,7 @@ func adminAddLoadNotification(_ context.Context, req *rpc.JsonRpcRequest) (any,
 	if params.Ratio <= 0 || params.Ratio > 1 {
 		return nil, rpc.MakeError(rpc.InvalidParams, "Ratio must be between 0 and 1", nil)
 	}
-	taskID, err := notification.AddLoadNotification(params.Clients, params.Name, params.Metric, params.Threshold, params.Ratio, params.Interval)
+	taskID, err := notification.AddLoadNotification(params.Clients, params.Selector, params.Name, params.Metric, params.Threshold, params.Ratio, params.Interval)
 	if err != nil {
 		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
 	}
@@ -105,6 +109,14 @@ func adminEditLoadNotification(_ context.Context, req *rpc.JsonRpcRequest) (any,
 	if err := req.BindParams(&params); err != nil {
 		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request data", nil)
 	}
+	for _, n := range params.Notifications {
+		if n == nil {
+			return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request data", nil)
+		}
+		if err := validateSelector(n.Selector); err != nil {
+			return nil, err
+		}
+	}
 	if err := notification.EditLoadNotification(params.Notifications); err != nil {
 		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
 	}
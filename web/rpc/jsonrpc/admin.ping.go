@@ -42,6 +42,7 @@ func init() {
 func adminAddPingTask(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
 	var params struct {
 		Clients   []string `json:"clients"`
+		Selector  string   `json:"selector"`
 		DefaultOn bool     `json:"default_on"`
 		Name      string   `json:"name"`
 		Target    string   `json:"target"`
@@ -52,10 +53,13 @@ func adminAddPingTask(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.Jso
 	if params.Name == "" || params.Target == "" || params.TaskType == "" || params.Interval == 0 {
 		return nil, rpc.MakeError(rpc.InvalidParams, "name, target, type and interval are required", nil)
 	}
-	if !params.DefaultOn && len(params.Clients) == 0 {
-		return nil, rpc.MakeError(rpc.InvalidParams, "clients is required when default_on is false", nil)
+	if !params.DefaultOn && len(params.Clients) == 0 && params.Selector == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "clients or selector is required when default_on is false", nil)
 	}
-	taskID, err := tasks.AddPingTask(params.Clients, params.DefaultOn, params.Name, params.Target, params.TaskType, params.Interval)
+	if err := validateSelector(params.Selector); err != nil {
+		return nil, err
+	}
+	taskID, err := tasks.AddPingTask(params.Clients, params.Selector, params.DefaultOn, params.Name, params.Target, params.TaskType, params.Interval)
 	if err != nil {
 		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
 	}
@@ -88,6 +92,9 @@ func adminEditPingTask(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.Js
 		if task == nil {
 			return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request data", nil)
 		}
+		if err := validateSelector(task.Selector); err != nil {
+			return nil, err
+		}
 	}
 	if err := tasks.EditPingTask(params.Tasks); err != nil {
 		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
@@ -0,0 +1,51 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/komari-monitor/komari/database/processsnapshots"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"gorm.io/gorm"
+)
+
+// admin.processes.go
+// 查询 Agent 上报的高占用进程快照（admin 命名空间），用于告警后的事后排查。
+
+func init() {
+	RegisterWithGroupAndMeta("getProcessSnapshot", rpc.RoleAdmin, adminGetProcessSnapshot, &rpc.MethodMeta{
+		Name:    "admin:getProcessSnapshot",
+		Summary: "Get the top processes snapshot closest to a point in time",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "time", Type: "string", Required: false, Description: "RFC3339 time, defaults to now"},
+		},
+		Returns: "{ client_uuid, time, processes: { by_cpu, by_memory } }",
+	})
+}
+
+func adminGetProcessSnapshot(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string     `json:"uuid"`
+		Time *time.Time `json:"time"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request body: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid or missing UUID", nil)
+	}
+	at := time.Now().UTC()
+	if params.Time != nil {
+		at = params.Time.UTC()
+	}
+	snapshot, err := processsnapshots.Closest(params.UUID, at)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "No process snapshot found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to get process snapshot: "+err.Error(), nil)
+	}
+	return snapshot, nil
+}
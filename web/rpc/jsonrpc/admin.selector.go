@@ -0,0 +1,61 @@
+package jsonrpc
+
+import (
+	"context"
+	"strings"
+
+	"github.com/komari-monitor/komari/database/clientselector"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"github.com/komari-monitor/komari/pkg/selector"
+)
+
+// admin.selector.go
+// 选择器表达式的预览方法（admin 命名空间）。Ping 任务与负载通知的 selector 字段
+// 在调度时求值，这里返回表达式当前匹配的客户端，便于保存前确认。
+
+func init() {
+	RegisterWithGroupAndMeta("previewSelector", rpc.RoleAdmin, adminPreviewSelector, &rpc.MethodMeta{
+		Name:    "admin:previewSelector",
+		Summary: "List the clients a selector expression currently matches",
+		Params: []rpc.ParamMeta{
+			{Name: "selector", Type: "string", Required: true, Description: `e.g. group = "prod" and tag in ("db","cache") and region != "CN"`},
+		},
+		Returns: "{ fields: string[], clients: { uuid: string, name: string }[] }",
+	})
+}
+
+// validateSelector 校验可选的选择器表达式，空字符串视为未设置。
+func validateSelector(expr string) *rpc.JsonRpcError {
+	if strings.TrimSpace(expr) == "" {
+		return nil
+	}
+	if err := selector.Validate(expr); err != nil {
+		return rpc.MakeError(rpc.InvalidParams, "Invalid selector: "+err.Error(), nil)
+	}
+	return nil
+}
+
+func adminPreviewSelector(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		Selector string `json:"selector"`
+	}
+	req.BindParams(&params)
+	if _, err := selector.Parse(params.Selector); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid selector: "+err.Error(), nil)
+	}
+	// 预览总是读取最新的客户端信息。
+	clientselector.Invalidate()
+	matched, err := clientselector.Match(params.Selector)
+	if err != nil {
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to evaluate selector: "+err.Error(), nil)
+	}
+	type matchedClient struct {
+		UUID string `json:"uuid"`
+		Name string `json:"name"`
+	}
+	out := make([]matchedClient, 0, len(matched))
+	for _, t := range matched {
+		out = append(out, matchedClient{UUID: t.UUID, Name: t.Name})
+	}
+	return map[string]any{"fields": selector.Fields, "clients": out}, nil
+}
@@ -0,0 +1,142 @@
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/komari-monitor/komari/database/auditlog"
+	"github.com/komari-monitor/komari/database/models"
+	"github.com/komari-monitor/komari/database/servicemonitors"
+	"github.com/komari-monitor/komari/pkg/rpc"
+)
+
+// admin.service.go
+// 在 Agent 上监控 systemd 单元或进程的存活状态（admin 命名空间）。定义变更会通过
+// agent.services 事件下发给适用的在线 v2 Agent。
+
+func init() {
+	RegisterWithGroupAndMeta("listServiceMonitors", rpc.RoleAdmin, adminListServiceMonitors, &rpc.MethodMeta{
+		Name:    "admin:listServiceMonitors",
+		Summary: "List monitored services",
+		Returns: "ServiceMonitor[]",
+	})
+	RegisterWithGroupAndMeta("addServiceMonitor", rpc.RoleAdmin, adminAddServiceMonitor, &rpc.MethodMeta{
+		Name:    "admin:addServiceMonitor",
+		Summary: "Create a monitored service and push it to the matching agents",
+		Params: []rpc.ParamMeta{
+			{Name: "name", Type: "string", Required: true},
+			{Name: "type", Type: "string", Required: true, Description: "systemd | process"},
+			{Name: "target", Type: "string", Required: true, Description: "systemd unit or process name"},
+			{Name: "clients", Type: "string[]", Required: false},
+			{Name: "selector", Type: "string", Required: false},
+			{Name: "enabled", Type: "boolean", Required: false},
+		},
+		Returns: "ServiceMonitor",
+	})
+	RegisterWithGroupAndMeta("editServiceMonitor", rpc.RoleAdmin, adminEditServiceMonitor, &rpc.MethodMeta{
+		Name:    "admin:editServiceMonitor",
+		Summary: "Update a monitored service and push the new list to affected agents",
+		Returns: "ServiceMonitor",
+	})
+	RegisterWithGroupAndMeta("deleteServiceMonitor", rpc.RoleAdmin, adminDeleteServiceMonitor, &rpc.MethodMeta{
+		Name:    "admin:deleteServiceMonitor",
+		Summary: "Delete monitored services and their status history",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "number[]", Required: true},
+		},
+		Returns: "null",
+	})
+	RegisterWithGroupAndMeta("listServiceStatus", rpc.RoleAdmin, adminListServiceStatus, &rpc.MethodMeta{
+		Name:    "admin:listServiceStatus",
+		Summary: "Show the latest reported state of monitored services",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: false, Description: "Only list services of this client"},
+		},
+		Returns: "{ client_uuid, service_id, service, state, restarts, message, since, checked_at }[]",
+	})
+}
+
+func serviceMonitorError(action string, err error) *rpc.JsonRpcError {
+	return mapError(err, "Service monitor not found", "Failed to "+action+" service monitor")
+}
+
+func adminListServiceMonitors(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	monitors, err := servicemonitors.List()
+	if err != nil {
+		return nil, serviceMonitorError("list", err)
+	}
+	return monitors, nil
+}
+
+func adminAddServiceMonitor(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	monitor := models.ServiceMonitor{Enabled: true}
+	if err := req.BindParams(&monitor); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	if err := validateSelector(monitor.Selector); err != nil {
+		return nil, err
+	}
+	if err := servicemonitors.Create(&monitor); err != nil {
+		return nil, serviceMonitorError("create", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("create service monitor:%d (%s)", monitor.Id, monitor.Name), "info")
+	return monitor, nil
+}
+
+func adminEditServiceMonitor(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var probe struct {
+		ID uint `json:"id"`
+	}
+	req.BindParams(&probe)
+	if probe.ID == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	existing, err := servicemonitors.Get(probe.ID)
+	if err != nil {
+		return nil, serviceMonitorError("get", err)
+	}
+	// 在已有定义上覆盖请求字段，未提供的字段保持不变。
+	monitor := *existing
+	if err := req.BindParams(&monitor); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid request: "+err.Error(), nil)
+	}
+	monitor.Id = existing.Id
+	if err := validateSelector(monitor.Selector); err != nil {
+		return nil, err
+	}
+	if err := servicemonitors.Update(&monitor); err != nil {
+		return nil, serviceMonitorError("update", err)
+	}
+	updated, err := servicemonitors.Get(monitor.Id)
+	if err != nil {
+		return nil, serviceMonitorError("get", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("update service monitor:%d (%s)", updated.Id, updated.Name), "info")
+	return updated, nil
+}
+
+func adminDeleteServiceMonitor(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID []uint `json:"id"`
+	}
+	req.BindParams(&params)
+	if len(params.ID) == 0 {
+		return nil, rpc.MakeError(rpc.InvalidParams, "id is required", nil)
+	}
+	if err := servicemonitors.Delete(params.ID); err != nil {
+		return nil, serviceMonitorError("delete", err)
+	}
+	actor, ip := auditActor(ctx)
+	auditlog.Log(ip, actor, fmt.Sprintf("delete service monitors:%v", params.ID), "warn")
+	return nil, nil
+}
+
+func adminListServiceStatus(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	return servicemonitors.ListStatus(params.UUID), nil
+}
@@ -0,0 +1,90 @@
+package jsonrpc
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"github.com/komari-monitor/komari/web/api/tunnel"
+)
+
+// admin.tunnel.go
+// 经由 Agent 转发 TCP 连接的隧道（admin 命名空间）。隧道打开后可以连接服务端本机的
+// 监听端口，或通过 /api/admin/tunnels/:id/connect 的 WebSocket 使用。
+
+func init() {
+	RegisterWithGroupAndMeta("openTunnel", rpc.RoleAdmin, adminOpenTunnel, &rpc.MethodMeta{
+		Name:    "admin:openTunnel",
+		Summary: "Open a TCP tunnel to host:port as seen from a client",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: true},
+			{Name: "target", Type: "string", Required: true, Description: "host:port resolved by the agent, e.g. 127.0.0.1:5432"},
+			{Name: "listen", Type: "string", Required: false, Description: "Loopback address the server listens on (default 127.0.0.1:0)"},
+			{Name: "idle_timeout", Type: "number", Required: false, Description: "Seconds without traffic before the tunnel closes (default 600)"},
+		},
+		Returns: "Tunnel",
+	})
+	RegisterWithGroupAndMeta("listTunnels", rpc.RoleAdmin, adminListTunnels, &rpc.MethodMeta{
+		Name:    "admin:listTunnels",
+		Summary: "List open and recently closed tunnels with byte counters",
+		Params: []rpc.ParamMeta{
+			{Name: "uuid", Type: "string", Required: false, Description: "Only list tunnels of this client"},
+		},
+		Returns: "{ id, client_uuid, target, listen, status, error, idle_timeout, bytes_sent, bytes_received, streams, total_streams, user_uuid, created_at, last_active, closed_at }[]",
+	})
+	RegisterWithGroupAndMeta("closeTunnel", rpc.RoleAdmin, adminCloseTunnel, &rpc.MethodMeta{
+		Name:    "admin:closeTunnel",
+		Summary: "Close a tunnel and all of its connections",
+		Params: []rpc.ParamMeta{
+			{Name: "id", Type: "string", Required: true},
+		},
+		Returns: "null",
+	})
+	// 隧道可以访问节点上仅对本机开放的服务，与终端同属敏感操作。
+	rpc.MarkSensitive("admin:openTunnel")
+}
+
+func adminOpenTunnel(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID        string `json:"uuid"`
+		Target      string `json:"target"`
+		Listen      string `json:"listen"`
+		IdleTimeout int    `json:"idle_timeout"`
+	}
+	if err := req.BindParams(&params); err != nil {
+		return nil, rpc.MakeError(rpc.InvalidParams, "Invalid params: "+err.Error(), nil)
+	}
+	if params.UUID == "" {
+		return nil, rpc.MakeError(rpc.InvalidParams, "uuid is required", nil)
+	}
+	uuid, ip := auditActor(ctx)
+	t, err := tunnel.Open(tunnel.Requester{UserUUID: uuid, IP: ip}, params.UUID, params.Target, params.Listen,
+		time.Duration(params.IdleTimeout)*time.Second)
+	if err != nil {
+		return nil, mapError(err, "", "Failed to open tunnel")
+	}
+	return t, nil
+}
+
+func adminListTunnels(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		UUID string `json:"uuid"`
+	}
+	req.BindParams(&params)
+	return tunnel.List(params.UUID), nil
+}
+
+func adminCloseTunnel(_ context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
+	var params struct {
+		ID string `json:"id"`
+	}
+	req.BindParams(&params)
+	if err := tunnel.Close(params.ID); err != nil {
+		if errors.Is(err, tunnel.ErrNotFound) {
+			return nil, rpc.MakeError(rpc.NotFound, "Tunnel not found", nil)
+		}
+		return nil, rpc.MakeError(rpc.InternalError, "Failed to close tunnel: "+err.Error(), nil)
+	}
+	return nil, nil
+}
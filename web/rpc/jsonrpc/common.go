@@ -10,6 +10,7 @@ import (
 
 	"github.com/komari-monitor/komari/database"
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/database/tasks"
@@ -18,6 +19,7 @@ import (
 	"github.com/komari-monitor/komari/protocol/v1"
 	"github.com/komari-monitor/komari/utils"
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
+	"github.com/komari-monitor/komari/web/federation"
 
 	cache "github.com/patrickmn/go-cache"
 )
@@ -248,27 +250,52 @@ func getNodes(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpcEr
 			node.Remark = ""
 			node.Version = ""
 			node.Token = ""
+			node.PolicyOverrides = nil
 			filtered = append(filtered, node)
 		}
 		cinfo = filtered
 	}
+	remote := remoteNodes(ctx)
 	if params.UUID != "" {
 		for _, node := range cinfo {
 			if node.UUID == params.UUID {
 				return node, nil
 			}
 		}
+		if node, ok := remote[params.UUID]; ok {
+			return node, nil
+		}
 		return nil, rpc.MakeError(rpc.InvalidParams, "Node not found", params.UUID)
 	}
 
 	// 返回以 uuid 为键的字典（每个 value 自身也包含 uuid 字段）
-	nodeMap := make(map[string]models.Client, len(cinfo))
+	nodeMap := make(map[string]any, len(cinfo)+len(remote))
+	for uuid, node := range remote {
+		nodeMap[uuid] = node
+	}
 	for _, node := range cinfo {
-		nodeMap[node.UUID] = node
+		nodeMap[node.UUID] = node // 与远程节点 UUID 冲突时本地节点优先
 	}
 	return nodeMap, nil
 }
 
+// remoteNodes 返回联邦子实例的只读远程节点。联邦父实例自身调用时不返回，
+// 避免多级联邦时节点被重复上卷或形成环路。
+func remoteNodes(ctx context.Context) map[string]map[string]any {
+	if meta := rpc.MetaFromContext(ctx); meta != nil && meta.Principal != nil && meta.Principal.Type == rpc.PrincipalFederation {
+		return nil
+	}
+	return federation.Nodes()
+}
+
+// remoteLatestStatus 返回远程节点的最新状态，规则同 remoteNodes。
+func remoteLatestStatus(ctx context.Context) map[string]map[string]any {
+	if meta := rpc.MetaFromContext(ctx); meta != nil && meta.Principal != nil && meta.Principal.Type == rpc.PrincipalFederation {
+		return nil
+	}
+	return federation.LatestStatus()
+}
+
 func getPublicInfo(_ context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
 	info, err := database.GetPublicInfo()
 	if err != nil {
@@ -311,9 +338,13 @@ func getNodesLatestStatus(ctx context.Context, req *rpc.JsonRpcRequest) (any, *r
 		}
 	}
 
+	remote := remoteLatestStatus(ctx)
 	// 如果指定 uuid 但找不到，直接返回 not found
 	if params.UUID != "" {
 		if _, ok := latest[params.UUID]; !ok {
+			if status, ok := remote[params.UUID]; ok {
+				return status, nil
+			}
 			return nil, rpc.MakeError(rpc.InvalidParams, "Node not found", params.UUID)
 		}
 	}
@@ -349,6 +380,7 @@ func getNodesLatestStatus(ctx context.Context, req *rpc.JsonRpcRequest) (any, *r
 
 	// 预取所有 ping 任务
 	pingTasks, _ := tasks.GetAllPingTasks()
+	pingTasks = clientselector.ExpandPingTasks(pingTasks)
 
 	appendOne := func(uuid string, rep *v1.Report) {
 		if rep == nil {
@@ -399,12 +431,27 @@ func getNodesLatestStatus(ctx context.Context, req *rpc.JsonRpcRequest) (any, *r
 				appendOne(uuid, rep)
 			}
 		}
-		return respMap, nil
+		return mergeRemoteStatus(respMap, remote, selected), nil
 	}
 	for uuid, rep := range latest {
 		appendOne(uuid, rep)
 	}
-	return respMap, nil
+	return mergeRemoteStatus(respMap, remote, nil), nil
+}
+
+// mergeRemoteStatus 把远程节点状态并入本地结果；selected 非空时只合并选中的节点。
+// UUID 冲突时本地节点优先。
+func mergeRemoteStatus[T any](local map[string]T, remote map[string]map[string]any, selected map[string]bool) map[string]any {
+	out := make(map[string]any, len(local)+len(remote))
+	for uuid, status := range remote {
+		if selected == nil || selected[uuid] {
+			out[uuid] = status
+		}
+	}
+	for uuid, status := range local {
+		out[uuid] = status
+	}
+	return out
 }
 
 func getMe(ctx context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
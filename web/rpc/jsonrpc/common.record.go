@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/models"
 	recordsdb "github.com/komari-monitor/komari/database/records"
 	"github.com/komari-monitor/komari/database/tasks"
@@ -268,6 +269,7 @@ func getRecords(ctx context.Context, req *rpc.JsonRpcRequest) (any, *rpc.JsonRpc
 		if err != nil {
 			return nil, rpc.MakeError(rpc.InternalError, "Failed to fetch ping tasks", err.Error())
 		}
+		pingTasks = clientselector.ExpandPingTasks(pingTasks)
 		toList := make([]map[string]any, 0, len(pingTasks))
 		for _, t := range pingTasks {
 			if taskId != -1 && t.Id != uint(taskId) {
@@ -0,0 +1,22 @@
+package jsonrpc
+
+import (
+	"errors"
+
+	"github.com/komari-monitor/komari/pkg/invalid"
+	"github.com/komari-monitor/komari/pkg/rpc"
+	"gorm.io/gorm"
+)
+
+// mapError 把业务层返回的错误映射为 RPC 错误：参数校验失败（invalid.Is）返回 InvalidParams
+// 并原样带回错误消息；记录不存在且 notFound 非空时返回 NotFound；其余返回 InternalError，
+// 消息为 failed 加原始错误。
+func mapError(err error, notFound, failed string) *rpc.JsonRpcError {
+	switch {
+	case invalid.Is(err):
+		return rpc.MakeError(rpc.InvalidParams, err.Error(), nil)
+	case notFound != "" && errors.Is(err, gorm.ErrRecordNotFound):
+		return rpc.MakeError(rpc.NotFound, notFound, nil)
+	}
+	return rpc.MakeError(rpc.InternalError, failed+": "+err.Error(), nil)
+}
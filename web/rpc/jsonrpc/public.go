@@ -2,11 +2,13 @@ package jsonrpc
 
 import (
 	"context"
+	"sort"
 	"strconv"
 	"time"
 
 	"github.com/komari-monitor/komari/database"
 	"github.com/komari-monitor/komari/database/clients"
+	"github.com/komari-monitor/komari/database/clientselector"
 	"github.com/komari-monitor/komari/database/dbcore"
 	"github.com/komari-monitor/komari/database/models"
 	"github.com/komari-monitor/komari/database/records"
@@ -14,6 +16,7 @@ import (
 	"github.com/komari-monitor/komari/pkg/rpc"
 	"github.com/komari-monitor/komari/utils"
 	agent_runtime "github.com/komari-monitor/komari/web/agent"
+	"github.com/komari-monitor/komari/web/federation"
 )
 
 // public.go
@@ -64,7 +67,42 @@ func publicGetNodesInformation(ctx context.Context, _ *rpc.JsonRpcRequest) (any,
 		j++
 	}
 	clientList = clientList[:j]
-	return clientList, nil
+	remote := remoteNodes(ctx)
+	if len(remote) == 0 {
+		return clientList, nil
+	}
+	// 远程节点按来源与权重追加在本地节点之后。
+	nodes := make([]any, 0, len(clientList)+len(remote))
+	local := make(map[string]struct{}, len(clientList))
+	for _, c := range clientList {
+		nodes = append(nodes, c)
+		local[c.UUID] = struct{}{}
+	}
+	remoteList := make([]map[string]any, 0, len(remote))
+	for uuid, node := range remote {
+		if _, ok := local[uuid]; !ok {
+			remoteList = append(remoteList, node)
+		}
+	}
+	sort.SliceStable(remoteList, func(a, b int) bool {
+		oa, _ := remoteList[a][federation.OriginField].(string)
+		ob, _ := remoteList[b][federation.OriginField].(string)
+		if oa != ob {
+			return oa < ob
+		}
+		wa, _ := remoteList[a]["weight"].(float64)
+		wb, _ := remoteList[b]["weight"].(float64)
+		if wa != wb {
+			return wa < wb
+		}
+		ua, _ := remoteList[a]["uuid"].(string)
+		ub, _ := remoteList[b]["uuid"].(string)
+		return ua < ub
+	})
+	for _, node := range remoteList {
+		nodes = append(nodes, node)
+	}
+	return nodes, nil
 }
 
 func publicGetPublicSettings(ctx context.Context, _ *rpc.JsonRpcRequest) (any, *rpc.JsonRpcError) {
@@ -210,6 +248,7 @@ func publicGetPublicPingTasks(_ context.Context, _ *rpc.JsonRpcRequest) (any, *r
 	if err != nil {
 		return nil, rpc.MakeError(rpc.InternalError, err.Error(), nil)
 	}
+	pingTasks = clientselector.ExpandPingTasks(pingTasks)
 	type publicPingTask struct {
 		Id        uint     `json:"id"`
 		Weight    int      `json:"weight"`
@@ -398,6 +437,7 @@ func publicGetPingRecords(ctx context.Context, req *rpc.JsonRpcRequest) (any, *r
 		if err != nil {
 			return nil, rpc.MakeError(rpc.InternalError, "Failed to fetch ping tasks: "+err.Error(), nil)
 		}
+		pingTasks = clientselector.ExpandPingTasks(pingTasks)
 		tasksList := make([]map[string]any, 0, len(pingTasks))
 		for _, t := range pingTasks {
 			if taskId != -1 && t.Id != uint(taskId) {
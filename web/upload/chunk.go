@@ -22,6 +22,10 @@ const (
 	PurposeBackup Purpose = "backup"
 	PurposePlugin Purpose = "plugin"
 	PurposeTheme  Purpose = "theme"
+	// PurposeAgentRelease 上传 Agent 二进制，随后通过 admin:addAgentRelease 登记为版本。
+	PurposeAgentRelease Purpose = "agent-release"
+	// PurposeFileTransfer 上传待发送给 Agent 的文件，随后通过 admin:sendFile 下发。
+	PurposeFileTransfer Purpose = "file-transfer"
 )
 
 var ErrNotFound = errors.New("upload not found")
@@ -37,6 +41,8 @@ type Session struct {
 	Metadata    Metadata
 	Directory   string
 	ArchivePath string
+	// Passphrase 是合并时随请求提交的备份口令，仅在内存中传给 Finalizer，不落盘。
+	Passphrase string
 }
 
 type Store struct {
@@ -201,7 +207,8 @@ func (s *Store) load(uploadID string) (Session, error) {
 }
 
 func isKnownPurpose(purpose Purpose) bool {
-	return purpose == PurposeBackup || purpose == PurposePlugin || purpose == PurposeTheme
+	return purpose == PurposeBackup || purpose == PurposePlugin || purpose == PurposeTheme || purpose == PurposeAgentRelease ||
+		purpose == PurposeFileTransfer
 }
 
 func validUploadID(uploadID string) bool {
@@ -76,7 +76,8 @@ func (h *Handler) Chunk(c *gin.Context) {
 
 func (h *Handler) Merge(c *gin.Context) {
 	var request struct {
-		UploadID string `json:"upload_id" binding:"required"`
+		UploadID   string `json:"upload_id" binding:"required"`
+		Passphrase string `json:"passphrase"` // 加密备份的口令，可选
 	}
 	if err := c.ShouldBindJSON(&request); err != nil {
 		api.RespondError(c, http.StatusBadRequest, fmt.Sprintf("invalid request: %v", err))
@@ -89,6 +90,7 @@ func (h *Handler) Merge(c *gin.Context) {
 		return
 	}
 	defer h.Store.Cancel(session.ID)
+	session.Passphrase = request.Passphrase
 
 	finalize, ok := h.Finalizers[session.Metadata.Purpose]
 	if !ok {